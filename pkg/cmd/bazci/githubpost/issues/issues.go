@@ -20,6 +20,7 @@ import (
 	"os"
 	"regexp"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/cockroachdb/cockroach/pkg/build"
 	"github.com/cockroachdb/cockroach/pkg/util/version"
@@ -41,10 +42,19 @@ const (
 )
 
 func enforceMaxLength(s string) string {
-	if len(s) > githubIssueBodyMaximumLength {
-		return s[:githubIssueBodyMaximumLength]
+	return truncateUTF8(s, githubIssueBodyMaximumLength)
+}
+
+// truncateUTF8 truncates s to at most n bytes, without splitting a multi-byte
+// character.
+func truncateUTF8(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	for n > 0 && !utf8.RuneStart(s[n]) {
+		n--
 	}
-	return s
+	return s[:n]
 }
 
 // context augments context.Context with a logger.
@@ -289,9 +299,13 @@ func releaseLabel(branch string) string {
 	return fmt.Sprintf("branch-%s", branch)
 }
 
+// buildIssueQueries returns the queries for the existing issue to adopt, for
+// the related issues on other branches and, if the request has a fingerprint,
+// for the issues to adopt which have that fingerprint in their body. The
+// existing and fingerprint queries select for the same labels.
 func buildIssueQueries(
 	repo string, org string, branch string, title string, req PostRequest,
-) (existingIssueQuery string, relatedIssuesQuery string) {
+) (existingIssueQuery string, relatedIssuesQuery string, fingerprintQuery string) {
 	base := fmt.Sprintf(
 		`repo:%q user:%q is:issue is:open in:title sort:created-desc %q`,
 		repo, org, title)
@@ -325,16 +339,22 @@ func buildIssueQueries(
 		}
 	}
 
-	existingIssueQuery = base + labelsQuery(
+	existingLabels := labelsQuery(
 		append(mustHave, releaseLabel(branch)),
 		append(mustNotHave, noReuseLabel),
 	)
+	existingIssueQuery = base + existingLabels
 	// The related issues query selects for branches.
 	relatedIssuesQuery = base + labelsQuery(
 		mustHave,
 		append(mustNotHave, releaseLabel(branch)),
 	)
-	return existingIssueQuery, relatedIssuesQuery
+	if req.Fingerprint != "" {
+		fingerprintQuery = fmt.Sprintf(
+			`repo:%q user:%q is:issue is:open in:body sort:created-desc %q`,
+			repo, org, req.Fingerprint) + existingLabels
+	}
+	return existingIssueQuery, relatedIssuesQuery, fingerprintQuery
 }
 
 type TestFailureType string
@@ -379,7 +399,7 @@ func (p *poster) post(
 	// We carry out two searches below, one attempting to find an issue that we
 	// adopt (i.e. add a comment to) and one finding "related issues", i.e. those
 	// that would match if it weren't for their branch label.
-	qExisting, qRelated := buildIssueQueries(p.Repo, p.Org, p.Branch, title, req)
+	qExisting, qRelated, qFingerprint := buildIssueQueries(p.Repo, p.Org, p.Branch, title, req)
 
 	rExisting, _, err := p.searchIssues(ctx, qExisting, &github.SearchOptions{
 		ListOptions: github.ListOptions{
@@ -405,6 +425,20 @@ func (p *poster) post(
 	}
 
 	existingIssues := filterByPrefixTitleMatch(rExisting, title)
+	if len(existingIssues) == 0 && qFingerprint != "" {
+		rFingerprint, _, err := p.searchIssues(ctx, qFingerprint, &github.SearchOptions{
+			ListOptions: github.ListOptions{
+				PerPage: 10,
+			},
+		})
+		if err != nil {
+			// As above, keep going.
+			p.l.Printf("error trying to find GitHub issues with fingerprint %s: %v", req.Fingerprint, err)
+		} else if len(rFingerprint.Issues) > 0 {
+			existingIssues = rFingerprint.Issues
+			p.l.Printf("found existing GitHub issue with fingerprint %s", req.Fingerprint)
+		}
+	}
 	var foundIssue *int
 	if len(existingIssues) > 0 {
 		// We found an existing issue to post a comment into.
@@ -425,6 +459,12 @@ func (p *poster) post(
 	}
 
 	body := enforceMaxLength(r.buf.String())
+	if req.Fingerprint != "" {
+		// The fingerprint is added after enforcing the maximum length so that
+		// it is never truncated, as it is used to search for the issue.
+		footer := fmt.Sprintf("\n<sub>Failure fingerprint: %s</sub>\n", req.Fingerprint)
+		body = truncateUTF8(body, githubIssueBodyMaximumLength-len(footer)) + footer
+	}
 
 	createLabels := []string{RobotLabel}
 	createLabels = append(createLabels, req.labels()...)
@@ -567,6 +607,12 @@ type PostRequest struct {
 	// ProjectColumnID is the id of the GitHub project column to add the issue to,
 	// or 0 if none.
 	ProjectColumnID int
+
+	// Fingerprint, if set, identifies the failure independently of the issue
+	// title. It is included in the body of the issue or comment, and if no
+	// existing issue matches the title, an open issue with the same fingerprint
+	// is commented on instead of creating a new one.
+	Fingerprint string
 }
 
 func (r PostRequest) labels() []string {
@@ -77,6 +77,7 @@ func TestPost(t *testing.T) {
 		reproCmd             string
 		skipTestFailure      bool
 		reproTitle, reproURL string
+		fingerprint          string
 	}
 
 	testCases := []testCase{
@@ -179,6 +180,13 @@ test logs left over in: /go/src/github.com/cockroachdb/cockroach/artifacts/logTe
 			message:         "Something went wrong",
 			skipTestFailure: true,
 		},
+		{
+			name:        "fingerprint",
+			packageName: "roachtest",
+			testName:    "kv/splits",
+			message:     "boom",
+			fingerprint: "roachtest-fp-0123456789ab",
+		},
 	}
 
 	testByName := func(t *testing.T, name string) testCase {
@@ -196,6 +204,9 @@ test logs left over in: /go/src/github.com/cockroachdb/cockroach/artifacts/logTe
 		foundOnlyMatchingIssue       = "matching-issue"
 		foundMatchingAndRelatedIssue = "matching-and-related-issue"
 		foundOnlyRelatedIssue        = "related-issue"
+		// foundFingerprintIssue is for the test cases with a fingerprint: no
+		// issue matches the title, but one has the fingerprint.
+		foundFingerprintIssue = "fingerprint-issue"
 	)
 
 	type issueFactory func(string, string) github.Issue
@@ -253,11 +264,23 @@ test logs left over in: /go/src/github.com/cockroachdb/cockroach/artifacts/logTe
 		}},
 	}
 
+	// fingerprintIssue is an issue with a different title, found by its
+	// fingerprint.
+	fingerprintIssue := func(packageName, _ string) github.Issue {
+		return github.Issue{
+			Title:  github.String(fmt.Sprintf("%s: other/test failed [failure reason]", packageName)),
+			Number: matchingIssue.Number,
+			Labels: matchingIssue.Labels,
+		}
+	}
+
 	// This test determines from the file name what logic to run. The first
 	// subgroup determines the test case (from the above slice). The second
-	// determines whether matching/related issues exist.
+	// determines whether matching/related issues exist. The third search, by
+	// fingerprint, only happens for the test cases with a fingerprint when no
+	// issue matches the title.
 	foundIssueScenarios := map[string][][]issueFactory{
-		foundNoIssue: {{}, {}},
+		foundNoIssue: {{}, {}, {}},
 		foundOnlyMatchingIssue: {
 			// only first matching issue is reported as there's an exact
 			// title match
@@ -275,7 +298,9 @@ test logs left over in: /go/src/github.com/cockroachdb/cockroach/artifacts/logTe
 			// only second related issue is reported as there's an exact
 			// title match
 			issuesWithSuffix(relatedIssue, "-similar", ""),
+			{},
 		},
+		foundFingerprintIssue: {{}, {}, {fingerprintIssue}},
 	}
 	var sKeys []string
 	for k := range foundIssueScenarios {
@@ -398,6 +423,7 @@ test logs left over in: /go/src/github.com/cockroachdb/cockroach/artifacts/logTe
 				MentionOnCreate: []string{"@cockroachdb/idonotexistbecausethisisatest"},
 				HelpCommand:     repro,
 				ExtraParams:     map[string]string{"ROACHTEST_cloud": "gce"},
+				Fingerprint:     c.fingerprint,
 			}
 			if c.skipTestFailure {
 				// Override the default.
@@ -412,7 +438,7 @@ test logs left over in: /go/src/github.com/cockroachdb/cockroach/artifacts/logTe
 				require.True(t, createdIssue)
 				require.False(t, createdComment)
 				require.Equal(t, TestFailureNewIssue, issue.Type)
-			case foundOnlyMatchingIssue, foundMatchingAndRelatedIssue:
+			case foundOnlyMatchingIssue, foundMatchingAndRelatedIssue, foundFingerprintIssue:
 				require.False(t, createdIssue)
 				require.True(t, createdComment)
 				require.Equal(t, TestFailureIssueComment, issue.Type)
@@ -519,8 +545,15 @@ func TestDataDriven(t *testing.T) {
 			if arg, ok := d.Arg("label-match-set"); ok {
 				req.AdoptIssueLabelMatchSet = arg.Vals
 			}
-			existing, related := buildIssueQueries("repo", "org", "master", "foo: bar failed", req)
-			return fmt.Sprintf("Existing issue query:\n  %s\nRelated issues query:\n  %s", existing, related)
+			if d.HasArg("fingerprint") {
+				d.ScanArgs(t, "fingerprint", &req.Fingerprint)
+			}
+			existing, related, fingerprint := buildIssueQueries("repo", "org", "master", "foo: bar failed", req)
+			res := fmt.Sprintf("Existing issue query:\n  %s\nRelated issues query:\n  %s", existing, related)
+			if fingerprint != "" {
+				res += fmt.Sprintf("\nFingerprint query:\n  %s", fingerprint)
+			}
+			return res
 
 		default:
 			t.Fatalf("unknown command %q", d.Cmd)
@@ -529,3 +562,11 @@ func TestDataDriven(t *testing.T) {
 	})
 
 }
+
+func TestTruncateUTF8(t *testing.T) {
+	require.Equal(t, "abc", truncateUTF8("abc", 5))
+	require.Equal(t, "ab", truncateUTF8("abc", 2))
+	// "é" is two bytes long, and isn't split.
+	require.Equal(t, "a", truncateUTF8("aéb", 2))
+	require.Equal(t, "aé", truncateUTF8("aéb", 3))
+}
@@ -0,0 +1,147 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/roachtestflags"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/logger"
+	"github.com/cockroachdb/cockroach/pkg/util/humanizeutil"
+)
+
+// testLogName is the name of the log of a test run in its artifacts dir.
+const testLogName = "test.log"
+
+// artifactPolicy returns the policy for the artifacts of the given test, which
+// combines the policy of the test with the one set through flags.
+func artifactPolicy(spec *registry.TestSpec) registry.ArtifactPolicy {
+	return spec.Artifacts.Merge(registry.ArtifactPolicy{
+		OnlyOnFailure:  roachtestflags.ArtifactsOnlyOnFailure,
+		MaxLogFileSize: int64(roachtestflags.ArtifactsMaxLogSizeMB) << 20,
+		Compress:       roachtestflags.ArtifactsCompress,
+	})
+}
+
+// applyArtifactPolicy prunes and compresses the artifacts in the given dir as
+// per the policy. The test log, which is still being written to, and the perf
+// and go coverage artifacts, which are post-processed as is, are left alone.
+func applyArtifactPolicy(
+	l *logger.Logger, dir string, failed bool, policy registry.ArtifactPolicy,
+) error {
+	if policy == (registry.ArtifactPolicy{}) {
+		return nil
+	}
+	if policy.OnlyOnFailure && !failed {
+		list, err := filterDirEntries(dir, func(entry os.DirEntry) bool {
+			return entry.Name() != testLogName && !isPostProcessedArtifact(entry)
+		})
+		if err != nil {
+			return err
+		}
+		for _, name := range list {
+			if err := os.RemoveAll(filepath.Join(dir, name)); err != nil {
+				return err
+			}
+		}
+		if len(list) > 0 {
+			l.Printf("discarded %d artifacts of passing test: %s", len(list), strings.Join(list, ", "))
+		}
+	}
+
+	return filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+		if filepath.Dir(path) == dir && (entry.Name() == testLogName || isPostProcessedArtifact(entry)) {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// Symlinks (e.g. to the current cockroach log) are left as is.
+		if !entry.Type().IsRegular() {
+			return nil
+		}
+		if policy.MaxLogFileSize > 0 && strings.HasSuffix(entry.Name(), ".log") {
+			info, err := entry.Info()
+			if err != nil {
+				return err
+			}
+			if info.Size() > policy.MaxLogFileSize {
+				l.Printf("discarded %s (%s, larger than %s)", path,
+					humanizeutil.IBytes(info.Size()), humanizeutil.IBytes(policy.MaxLogFileSize))
+				return os.Remove(path)
+			}
+		}
+		if policy.Compress && !isCompressed(entry.Name()) {
+			return gzipFile(path)
+		}
+		return nil
+	})
+}
+
+// isPostProcessedArtifact returns whether the given entry of the artifacts dir
+// of a test holds perf or go coverage artifacts, which are post-processed as
+// is (see zipArtifacts).
+func isPostProcessedArtifact(entry os.DirEntry) bool {
+	if !entry.IsDir() {
+		return entry.Name() == "stats.json"
+	}
+	return strings.HasSuffix(entry.Name(), "."+perfArtifactsDir) ||
+		strings.HasSuffix(entry.Name(), "."+goCoverArtifactsDir)
+}
+
+// isCompressed returns whether the file with the given name is already
+// compressed, in which case compressing it again is a waste.
+func isCompressed(name string) bool {
+	for _, ext := range []string{".gz", ".zip", ".tgz", ".zst", ".bz2", ".xz", ".snappy"} {
+		if strings.HasSuffix(name, ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// gzipFile replaces the given file with a gzipped <path>.gz file.
+func gzipFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(path + ".gz")
+	if err != nil {
+		return err
+	}
+	w := gzip.NewWriter(out)
+	if _, err := io.Copy(w, in); err != nil {
+		_ = out.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		_ = out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
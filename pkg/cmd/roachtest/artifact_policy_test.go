@@ -0,0 +1,109 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArtifactPolicyMerge(t *testing.T) {
+	p := registry.ArtifactPolicy{MaxLogFileSize: 10}
+	require.Equal(t, registry.ArtifactPolicy{MaxLogFileSize: 10, Compress: true},
+		p.Merge(registry.ArtifactPolicy{Compress: true}))
+	require.Equal(t, registry.ArtifactPolicy{MaxLogFileSize: 5, OnlyOnFailure: true},
+		p.Merge(registry.ArtifactPolicy{MaxLogFileSize: 5, OnlyOnFailure: true}))
+	require.Equal(t, registry.ArtifactPolicy{MaxLogFileSize: 10},
+		registry.ArtifactPolicy{}.Merge(p))
+}
+
+func TestApplyArtifactPolicy(t *testing.T) {
+	makeArtifacts := func(t *testing.T) string {
+		dir := t.TempDir()
+		for name, size := range map[string]int{
+			"test.log":                               100,
+			"failure_1.log":                          1,
+			"1.perf/stats.json":                      1,
+			"logs/1.unredacted/cockroach.log":        100,
+			"logs/1.unredacted/cockroach.stderr.log": 1,
+			"logs/1.dmesg.txt":                       1,
+			"debug.zip":                              100,
+		} {
+			path := filepath.Join(dir, name)
+			require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+			require.NoError(t, os.WriteFile(path, make([]byte, size), 0644))
+		}
+		return dir
+	}
+	ls := func(t *testing.T, dir string) []string {
+		var files []string
+		require.NoError(t, filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				rel, _ := filepath.Rel(dir, path)
+				files = append(files, rel)
+			}
+			return err
+		}))
+		sort.Strings(files)
+		return files
+	}
+
+	for _, tc := range []struct {
+		name     string
+		failed   bool
+		policy   registry.ArtifactPolicy
+		expected []string
+	}{
+		{
+			name: "keep all",
+			expected: []string{
+				"1.perf/stats.json", "debug.zip", "failure_1.log", "logs/1.dmesg.txt",
+				"logs/1.unredacted/cockroach.log", "logs/1.unredacted/cockroach.stderr.log", "test.log",
+			},
+		},
+		{
+			name:     "only on failure, passed",
+			policy:   registry.ArtifactPolicy{OnlyOnFailure: true},
+			expected: []string{"1.perf/stats.json", "test.log"},
+		},
+		{
+			name:   "only on failure, failed",
+			failed: true,
+			policy: registry.ArtifactPolicy{OnlyOnFailure: true, MaxLogFileSize: 10},
+			expected: []string{
+				"1.perf/stats.json", "debug.zip", "failure_1.log", "logs/1.dmesg.txt",
+				"logs/1.unredacted/cockroach.stderr.log", "test.log",
+			},
+		},
+		{
+			name:   "compress",
+			failed: true,
+			policy: registry.ArtifactPolicy{Compress: true},
+			expected: []string{
+				"1.perf/stats.json", "debug.zip", "failure_1.log.gz", "logs/1.dmesg.txt.gz",
+				"logs/1.unredacted/cockroach.log.gz", "logs/1.unredacted/cockroach.stderr.log.gz",
+				"test.log",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := makeArtifacts(t)
+			require.NoError(t, applyArtifactPolicy(nilLogger(), dir, tc.failed, tc.policy))
+			require.Equal(t, tc.expected, ls(t, dir))
+		})
+	}
+}
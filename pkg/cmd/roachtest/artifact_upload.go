@@ -0,0 +1,191 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/roachprod/logger"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	// artifactUploadConcurrency is the maximum number of artifacts dirs
+	// uploaded concurrently.
+	artifactUploadConcurrency = 4
+	// artifactUploadTimeout is how long the upload of the artifacts of a test
+	// run can take.
+	artifactUploadTimeout = 30 * time.Minute
+	// artifactRetainUntilKey is the metadata key of the uploaded artifacts
+	// which holds the date after which they can be deleted, e.g. by a lifecycle
+	// rule of the bucket.
+	artifactRetainUntilKey = "retain-until"
+)
+
+// artifactUploader uploads the artifacts of the test runs to a GCS or S3
+// bucket as soon as they complete, in the background. See --artifacts-upload.
+// All methods are no-ops on a nil artifactUploader.
+type artifactUploader struct {
+	// dest is the gs:// or s3:// URL the artifacts dirs are uploaded to, with
+	// the same layout as in the artifacts root dir.
+	dest      string
+	retention time.Duration
+	// sem limits the number of concurrent uploads.
+	sem chan struct{}
+	wg  sync.WaitGroup
+	mu  struct {
+		syncutil.Mutex
+		uploaded int
+		failed   []string
+	}
+}
+
+// newArtifactUploader returns an uploader to the given URL, which tags the
+// artifacts to be retained for the given duration. Returns nil if the URL is
+// empty.
+func newArtifactUploader(dest string, retention time.Duration) (*artifactUploader, error) {
+	if dest == "" {
+		return nil, nil
+	}
+	if !strings.HasPrefix(dest, "gs://") && !strings.HasPrefix(dest, "s3://") {
+		return nil, errors.Newf("unsupported artifacts upload URL %q: expected gs:// or s3://", dest)
+	}
+	return &artifactUploader{
+		dest:      strings.TrimSuffix(dest, "/"),
+		retention: retention,
+		sem:       make(chan struct{}, artifactUploadConcurrency),
+	}, nil
+}
+
+// uploadCmd returns the command, and its arguments, which uploads the given
+// dir to the given URL with the given metadata.
+func uploadCmd(dir, dest string, metadata map[string]string) []string {
+	keys := make([]string, 0, len(metadata))
+	for k := range metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	if strings.HasPrefix(dest, "s3://") {
+		kvs := make([]string, len(keys))
+		for i, k := range keys {
+			kvs[i] = k + "=" + metadata[k]
+		}
+		return []string{"aws", "s3", "sync", "--only-show-errors", dir, dest, "--metadata", strings.Join(kvs, ",")}
+	}
+	args := []string{"gsutil", "-q", "-m"}
+	for _, k := range keys {
+		args = append(args, "-h", fmt.Sprintf("x-goog-meta-%s:%s", k, metadata[k]))
+	}
+	return append(args, "rsync", "-r", dir, dest)
+}
+
+// upload uploads the given artifacts dir, relative to the given artifacts root
+// dir, in the background, with the given metadata in addition to the date
+// until which it is to be retained.
+func (u *artifactUploader) upload(
+	l *logger.Logger, rootDir, dir string, metadata map[string]string,
+) {
+	if u == nil {
+		return
+	}
+	rel, err := filepath.Rel(rootDir, dir)
+	if err != nil {
+		l.Printf("not uploading artifacts in %s: %s", dir, err)
+		return
+	}
+	dest := u.dest + "/" + filepath.ToSlash(rel)
+	md := map[string]string{
+		artifactRetainUntilKey: timeutil.Now().Add(u.retention).UTC().Format("2006-01-02"),
+	}
+	for k, v := range metadata {
+		md[k] = v
+	}
+	args := uploadCmd(dir, dest, md)
+
+	u.wg.Add(1)
+	go func() {
+		defer u.wg.Done()
+		u.sem <- struct{}{}
+		defer func() { <-u.sem }()
+
+		// The upload outlives the test run, and isn't interrupted with it.
+		ctx, cancel := context.WithTimeout(context.Background(), artifactUploadTimeout)
+		defer cancel()
+		start := timeutil.Now()
+		out, err := exec.CommandContext(ctx, args[0], args[1:]...).CombinedOutput()
+
+		u.mu.Lock()
+		defer u.mu.Unlock()
+		if err != nil {
+			l.Printf("failed to upload artifacts in %s to %s: %s\n%s", dir, dest, err, out)
+			u.mu.failed = append(u.mu.failed, rel)
+			return
+		}
+		l.Printf("uploaded artifacts in %s to %s in %s", dir, dest, timeutil.Since(start))
+		u.mu.uploaded++
+	}()
+}
+
+// browseURL returns the URL at which the uploaded artifacts at the given path,
+// relative to the artifacts root dir, can be browsed in the cloud console. The
+// path is a dir if isDir is set, and a file otherwise. Returns an empty string
+// if the artifacts are not uploaded.
+func (u *artifactUploader) browseURL(rel string, isDir bool) string {
+	if u == nil {
+		return ""
+	}
+	dest := u.dest + "/" + filepath.ToSlash(rel)
+	if path, ok := strings.CutPrefix(dest, "s3://"); ok {
+		bucket, key, _ := strings.Cut(path, "/")
+		if isDir {
+			return fmt.Sprintf("https://s3.console.aws.amazon.com/s3/buckets/%s?prefix=%s/",
+				bucket, url.QueryEscape(key))
+		}
+		return fmt.Sprintf("https://s3.console.aws.amazon.com/s3/object/%s?prefix=%s",
+			bucket, url.QueryEscape(key))
+	}
+	path := strings.TrimPrefix(dest, "gs://")
+	if isDir {
+		return "https://console.cloud.google.com/storage/browser/" + path + "/"
+	}
+	return "https://console.cloud.google.com/storage/browser/_details/" + path
+}
+
+// wait waits for the uploads in progress, and returns a summary of the uploads,
+// or an empty string if there were none.
+func (u *artifactUploader) wait() string {
+	if u == nil {
+		return ""
+	}
+	u.wg.Wait()
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if u.mu.uploaded == 0 && len(u.mu.failed) == 0 {
+		return ""
+	}
+	summary := fmt.Sprintf("uploaded the artifacts of %d test runs to %s", u.mu.uploaded, u.dest)
+	if len(u.mu.failed) > 0 {
+		summary += fmt.Sprintf("; failed to upload the artifacts of %d test runs:\n%s",
+			len(u.mu.failed), strings.Join(u.mu.failed, "\n"))
+	}
+	return summary
+}
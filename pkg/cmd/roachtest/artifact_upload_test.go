@@ -0,0 +1,55 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestArtifactUploader(t *testing.T) {
+	u, err := newArtifactUploader("", time.Hour)
+	require.NoError(t, err)
+	require.Nil(t, u)
+	// A nil uploader uploads nothing.
+	u.upload(nilLogger(), "/artifacts", "/artifacts/foo/run_1", nil)
+	require.Empty(t, u.wait())
+
+	_, err = newArtifactUploader("/tmp/artifacts", time.Hour)
+	require.ErrorContains(t, err, "expected gs:// or s3://")
+
+	u, err = newArtifactUploader("gs://bucket/artifacts/", time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, "gs://bucket/artifacts", u.dest)
+	require.Equal(t, "https://console.cloud.google.com/storage/browser/bucket/artifacts/foo/run_1/",
+		u.browseURL("foo/run_1", true /* isDir */))
+
+	u, err = newArtifactUploader("s3://bucket/artifacts", time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, "https://s3.console.aws.amazon.com/s3/object/bucket?prefix=artifacts%2Ffoo%2Frun_1%2Ftest.log",
+		u.browseURL("foo/run_1/test.log", false /* isDir */))
+}
+
+func TestUploadCmd(t *testing.T) {
+	md := map[string]string{"test": "foo", "retain-until": "2024-09-01"}
+	require.Equal(t, []string{
+		"gsutil", "-q", "-m",
+		"-h", "x-goog-meta-retain-until:2024-09-01",
+		"-h", "x-goog-meta-test:foo",
+		"rsync", "-r", "/artifacts/foo/run_1", "gs://bucket/foo/run_1",
+	}, uploadCmd("/artifacts/foo/run_1", "gs://bucket/foo/run_1", md))
+	require.Equal(t, []string{
+		"aws", "s3", "sync", "--only-show-errors", "/artifacts/foo/run_1", "s3://bucket/foo/run_1",
+		"--metadata", "retain-until=2024-09-01,test=foo",
+	}, uploadCmd("/artifacts/foo/run_1", "s3://bucket/foo/run_1", md))
+}
@@ -0,0 +1,110 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+	"github.com/cockroachdb/errors"
+)
+
+// checkpointFile is the name of the file, in the artifacts directory, which
+// records the progress of a run. See --resume-from.
+const checkpointFile = "checkpoint.json"
+
+// checkpointEntry is the result of a completed test run.
+type checkpointEntry struct {
+	Name   string `json:"name"`
+	Run    int    `json:"run"`
+	Status string `json:"status"`
+}
+
+// passed returns whether the test run does not need to run again.
+func (e checkpointEntry) passed() bool {
+	return e.Status != reportStatusFailure
+}
+
+// runCheckpoint persists the results of the completed test runs, so that a run
+// that was interrupted (e.g. because the runner process was killed) can be
+// resumed with --resume-from.
+type runCheckpoint struct {
+	path string
+	mu   struct {
+		syncutil.Mutex
+		entries []checkpointEntry
+	}
+}
+
+func newRunCheckpoint(dir string) *runCheckpoint {
+	return &runCheckpoint{path: filepath.Join(dir, checkpointFile)}
+}
+
+// loadCheckpointEntries loads the entries of the checkpoint in the given
+// artifacts directory.
+func loadCheckpointEntries(dir string) ([]checkpointEntry, error) {
+	data, err := os.ReadFile(filepath.Join(dir, checkpointFile))
+	if err != nil {
+		return nil, errors.Wrap(err, "reading checkpoint")
+	}
+	var entries []checkpointEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, errors.Wrap(err, "decoding checkpoint")
+	}
+	return entries, nil
+}
+
+// record adds the result of a completed test run to the checkpoint, and
+// persists it. The file is replaced atomically, so that a runner killed at any
+// point leaves a valid checkpoint behind.
+func (c *runCheckpoint) record(entries ...checkpointEntry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.mu.entries = append(c.mu.entries, entries...)
+	data, err := json.MarshalIndent(c.mu.entries, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0755); err != nil {
+		return err
+	}
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return errors.Wrap(err, "writing checkpoint")
+	}
+	return errors.Wrap(os.Rename(tmp, c.path), "writing checkpoint")
+}
+
+// remainingTests returns the tests which still need to run, given the entries
+// of the checkpoint of a previous run, along with the entries of the test runs
+// which are skipped. A test is skipped if all its count runs passed in the
+// previous run; otherwise, all its runs are run again.
+func remainingTests(
+	tests []registry.TestSpec, count int, prev []checkpointEntry,
+) (remaining []registry.TestSpec, skipped []checkpointEntry) {
+	passed := make(map[string][]checkpointEntry)
+	for _, e := range prev {
+		if e.passed() {
+			passed[e.Name] = append(passed[e.Name], e)
+		}
+	}
+	for _, t := range tests {
+		if runs := passed[t.Name]; len(runs) >= count {
+			skipped = append(skipped, runs...)
+			continue
+		}
+		remaining = append(remaining, t)
+	}
+	return remaining, skipped
+}
@@ -0,0 +1,57 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	_, err := loadCheckpointEntries(dir)
+	require.Error(t, err)
+
+	cp := newRunCheckpoint(dir)
+	entries := []checkpointEntry{
+		{Name: "a", Run: 1, Status: reportStatusSuccess},
+		{Name: "b", Run: 1, Status: reportStatusFailure},
+		{Name: "c", Run: 1, Status: reportStatusFlaky},
+		{Name: "d", Run: 1, Status: reportStatusSuccess},
+		{Name: "d", Run: 2, Status: reportStatusSuccess},
+	}
+	for _, e := range entries {
+		require.NoError(t, cp.record(e))
+	}
+	loaded, err := loadCheckpointEntries(dir)
+	require.NoError(t, err)
+	require.Equal(t, entries, loaded)
+
+	tests := []registry.TestSpec{{Name: "a"}, {Name: "b"}, {Name: "c"}, {Name: "d"}, {Name: "e"}}
+	names := func(tests []registry.TestSpec) []string {
+		var res []string
+		for _, t := range tests {
+			res = append(res, t.Name)
+		}
+		return res
+	}
+
+	remaining, skipped := remainingTests(tests, 1 /* count */, loaded)
+	require.Equal(t, []string{"b", "e"}, names(remaining))
+	require.Len(t, skipped, 4)
+
+	// With --count=2, only the tests which passed both runs are skipped.
+	remaining, skipped = remainingTests(tests, 2 /* count */, loaded)
+	require.Equal(t, []string{"a", "b", "c", "e"}, names(remaining))
+	require.Equal(t, entries[3:], skipped)
+}
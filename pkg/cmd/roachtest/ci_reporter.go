@@ -0,0 +1,268 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/roachtestflags"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	ciReporterTeamCity      = "teamcity"
+	ciReporterGitHubActions = "github"
+	ciReporterJSONLines     = "jsonl"
+)
+
+// ciOutput prints a line of output for the CI system.
+type ciOutput func(format string, args ...interface{})
+
+// printCIOutput is a ciOutput which prints to stdout.
+func printCIOutput(format string, args ...interface{}) {
+	if len(format) == 0 || format[len(format)-1] != '\n' {
+		format += "\n"
+	}
+	fmt.Fprintf(os.Stdout, format, args...)
+}
+
+// ciReporter reports the progress of a run in the format understood by the CI
+// system running roachtest. See --ci-reporter.
+type ciReporter interface {
+	// testIgnored reports a test which was skipped or not selected. The
+	// duration is zero for tests which were not started.
+	testIgnored(out ciOutput, name, reason string, duration time.Duration)
+	// testStarted reports the start of a run of a test. It is only called once
+	// the run has finished, so that skipped tests aren't reported twice.
+	testStarted(out ciOutput, name, runID string)
+	// testFailed reports the failure of a run of a test, before testFinished.
+	testFailed(out ciOutput, name, runID, details string)
+	// testFinished reports the end of a run of a test.
+	testFinished(out ciOutput, name, runID string, duration time.Duration)
+	// testArtifacts prepares and publishes the artifacts of a finished run.
+	testArtifacts(out ciOutput, t *testImpl) error
+	// beginGroup and endGroup delimit the output of a run of a test.
+	beginGroup(out ciOutput, name string)
+	endGroup(out ciOutput)
+	// runFinished reports the end of the run.
+	runFinished(out ciOutput, r *testRunner, literalArtifactsDir string) error
+}
+
+// ciReporterFromFlags returns the reporter selected by --ci-reporter. For
+// backwards compatibility, --github and --teamcity select their respective
+// reporters when --ci-reporter isn't set, with --github taking precedence.
+func ciReporterFromFlags() (ciReporter, error) {
+	name := roachtestflags.CIReporter
+	if name == "" {
+		if roachtestflags.GitHubActions {
+			name = ciReporterGitHubActions
+		} else if roachtestflags.TeamCity {
+			name = ciReporterTeamCity
+		}
+	}
+	switch name {
+	case "":
+		return noopCIReporter{}, nil
+	case ciReporterTeamCity:
+		return teamCityReporter{}, nil
+	case ciReporterGitHubActions:
+		// Nesting won't work properly if we're running multiple tests
+		// concurrently. Therefore, we only group log lines if parallelism is 1
+		// (which is true for local roachtests that we run in GitHub Actions).
+		return githubActionsReporter{groupOutput: roachtestflags.Parallelism == 1}, nil
+	case ciReporterJSONLines:
+		return jsonLinesReporter{}, nil
+	default:
+		return nil, errors.Newf("unknown CI reporter %q; expected one of %s, %s or %s",
+			name, ciReporterTeamCity, ciReporterGitHubActions, ciReporterJSONLines)
+	}
+}
+
+// noopCIReporter doesn't report anything. It is used when roachtest doesn't
+// run in CI.
+type noopCIReporter struct{}
+
+var _ ciReporter = noopCIReporter{}
+
+func (noopCIReporter) testIgnored(ciOutput, string, string, time.Duration)  {}
+func (noopCIReporter) testStarted(ciOutput, string, string)                 {}
+func (noopCIReporter) testFailed(ciOutput, string, string, string)          {}
+func (noopCIReporter) testFinished(ciOutput, string, string, time.Duration) {}
+func (noopCIReporter) testArtifacts(ciOutput, *testImpl) error              { return nil }
+func (noopCIReporter) beginGroup(ciOutput, string)                          {}
+func (noopCIReporter) endGroup(ciOutput)                                    {}
+func (noopCIReporter) runFinished(ciOutput, *testRunner, string) error      { return nil }
+
+// teamCityReporter emits TeamCity service messages.
+// See https://www.jetbrains.com/help/teamcity/service-messages.html
+type teamCityReporter struct {
+	noopCIReporter
+}
+
+var _ ciReporter = teamCityReporter{}
+
+func (teamCityReporter) testIgnored(out ciOutput, name, reason string, duration time.Duration) {
+	if duration == 0 {
+		out("##teamcity[testIgnored name='%s' message='%s']", name, TeamCityEscape(reason))
+		return
+	}
+	out("##teamcity[testIgnored name='%s' message='%s' duration='%d']",
+		name, TeamCityEscape(reason), duration.Milliseconds())
+}
+
+func (teamCityReporter) testStarted(out ciOutput, name, runID string) {
+	out("##teamcity[testStarted name='%s' flowId='%s']", name, runID)
+}
+
+func (teamCityReporter) testFailed(out ciOutput, name, runID, details string) {
+	// If `##teamcity[testFailed ...]` is not present before `##teamCity[testFinished ...]`,
+	// TeamCity regards the test as successful.
+	out("##teamcity[testFailed name='%s' details='%s' flowId='%s']",
+		name, TeamCityEscape(details), runID)
+}
+
+func (teamCityReporter) testFinished(out ciOutput, name, runID string, duration time.Duration) {
+	out("##teamcity[testFinished name='%s' flowId='%s' duration='%d']",
+		name, runID, duration.Milliseconds())
+}
+
+func (teamCityReporter) testArtifacts(out ciOutput, t *testImpl) error {
+	// Zip the artifacts. This improves the TeamCity UX where we can navigate
+	// through zip files just fine, but we can't download subtrees of the
+	// artifacts storage. By zipping we get this capability as we can just
+	// download the zip file for the failing test instead.
+	if err := zipArtifacts(t); err != nil {
+		return errors.Wrap(err, "unable to zip artifacts")
+	}
+
+	if t.artifactsSpec != "" {
+		// Tell TeamCity to collect this test's artifacts now. The TC job
+		// also collects the artifacts directory wholesale at the end, but
+		// here we make sure that the artifacts for any test that has already
+		// finished are available in the UI even before the job as a whole
+		// has completed. We're using the exact same destination to avoid
+		// duplication of any of the artifacts.
+		out("##teamcity[publishArtifacts '%s']", t.artifactsSpec)
+	}
+	return nil
+}
+
+func (teamCityReporter) runFinished(out ciOutput, _ *testRunner, literalArtifactsDir string) error {
+	// Collect the runner logs.
+	out("##teamcity[publishArtifacts '%s']", filepath.Join(literalArtifactsDir, runnerLogsDir))
+	return nil
+}
+
+// githubActionsReporter emits GitHub Actions workflow commands, and writes a
+// summary of the run to GITHUB_STEP_SUMMARY.
+// See https://docs.github.com/en/actions/using-workflows/workflow-commands-for-github-actions
+type githubActionsReporter struct {
+	noopCIReporter
+	// groupOutput is set if the output of each test should be grouped.
+	groupOutput bool
+}
+
+var _ ciReporter = githubActionsReporter{}
+
+func (githubActionsReporter) testFailed(out ciOutput, name, _, details string) {
+	for _, line := range strings.Split(strings.TrimSpace(details), "\n") {
+		out("::error title=%s failed::%s", name, line)
+	}
+}
+
+func (g githubActionsReporter) beginGroup(out ciOutput, name string) {
+	if g.groupOutput {
+		out("::group::%s", name)
+	}
+}
+
+func (g githubActionsReporter) endGroup(out ciOutput) {
+	if g.groupOutput {
+		out("::endgroup::")
+	}
+}
+
+func (githubActionsReporter) runFinished(_ ciOutput, r *testRunner, _ string) error {
+	if err := maybeDumpSummaryMarkdown(r); err != nil {
+		return errors.Wrap(err, "failed to write to GITHUB_STEP_SUMMARY file")
+	}
+	return nil
+}
+
+// jsonLinesReporter emits one JSON object per line for each event, for CI
+// systems without dedicated support. Every object has a "time" and an "event"
+// field.
+type jsonLinesReporter struct {
+	noopCIReporter
+}
+
+var _ ciReporter = jsonLinesReporter{}
+
+// ciEvent is an event emitted by the jsonLinesReporter.
+type ciEvent struct {
+	Time       time.Time `json:"time"`
+	Event      string    `json:"event"`
+	Test       string    `json:"test,omitempty"`
+	RunID      string    `json:"run_id,omitempty"`
+	Message    string    `json:"message,omitempty"`
+	DurationMs int64     `json:"duration_ms,omitempty"`
+	// The fields below are only set for the run_finished event.
+	Passed  *int `json:"passed,omitempty"`
+	Failed  *int `json:"failed,omitempty"`
+	Flaky   *int `json:"flaky,omitempty"`
+	Skipped *int `json:"skipped,omitempty"`
+}
+
+func (jsonLinesReporter) emit(out ciOutput, e ciEvent) {
+	e.Time = timeutil.Now().UTC()
+	data, err := json.Marshal(e)
+	if err != nil {
+		// ciEvent only contains types which can be marshaled.
+		panic(err)
+	}
+	out("%s", data)
+}
+
+func (j jsonLinesReporter) testIgnored(
+	out ciOutput, name, reason string, duration time.Duration,
+) {
+	j.emit(out, ciEvent{Event: "ignored", Test: name, Message: reason, DurationMs: duration.Milliseconds()})
+}
+
+func (j jsonLinesReporter) testStarted(out ciOutput, name, runID string) {
+	j.emit(out, ciEvent{Event: "started", Test: name, RunID: runID})
+}
+
+func (j jsonLinesReporter) testFailed(out ciOutput, name, runID, details string) {
+	j.emit(out, ciEvent{Event: "failed", Test: name, RunID: runID, Message: details})
+}
+
+func (j jsonLinesReporter) testFinished(
+	out ciOutput, name, runID string, duration time.Duration,
+) {
+	j.emit(out, ciEvent{Event: "finished", Test: name, RunID: runID, DurationMs: duration.Milliseconds()})
+}
+
+func (j jsonLinesReporter) runFinished(out ciOutput, r *testRunner, _ string) error {
+	r.status.Lock()
+	passed, failed, flaky, skipped := len(r.status.pass), len(r.status.fail), len(r.status.flaky), len(r.status.skip)
+	r.status.Unlock()
+	j.emit(out, ciEvent{
+		Event: "run_finished", Passed: &passed, Failed: &failed, Flaky: &flaky, Skipped: &skipped,
+	})
+	return nil
+}
@@ -0,0 +1,148 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/roachtestflags"
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCIReporterFromFlags(t *testing.T) {
+	defer func(name string, teamCity, github bool) {
+		roachtestflags.CIReporter, roachtestflags.TeamCity, roachtestflags.GitHubActions = name, teamCity, github
+	}(roachtestflags.CIReporter, roachtestflags.TeamCity, roachtestflags.GitHubActions)
+
+	testCases := []struct {
+		name     string
+		teamCity bool
+		github   bool
+		expected ciReporter
+	}{
+		{expected: noopCIReporter{}},
+		{teamCity: true, expected: teamCityReporter{}},
+		{github: true, expected: githubActionsReporter{groupOutput: roachtestflags.Parallelism == 1}},
+		{teamCity: true, github: true, expected: githubActionsReporter{groupOutput: roachtestflags.Parallelism == 1}},
+		{name: "jsonl", teamCity: true, expected: jsonLinesReporter{}},
+	}
+	for _, tc := range testCases {
+		roachtestflags.CIReporter, roachtestflags.TeamCity, roachtestflags.GitHubActions = tc.name, tc.teamCity, tc.github
+		ci, err := ciReporterFromFlags()
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, ci)
+	}
+
+	roachtestflags.CIReporter = "jenkins"
+	_, err := ciReporterFromFlags()
+	require.ErrorContains(t, err, `unknown CI reporter "jenkins"`)
+}
+
+func TestCIReporters(t *testing.T) {
+	var lines []string
+	out := func(format string, args ...interface{}) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}
+	report := func(ci ciReporter) []string {
+		lines = nil
+		ci.testIgnored(out, "skipped", "flaky", 0)
+		ci.beginGroup(out, "foo")
+		ci.testStarted(out, "foo", "foo#2")
+		ci.testFailed(out, "foo", "foo#2", "boom\n[it broke]")
+		ci.testFinished(out, "foo", "foo#2", 1500*time.Millisecond)
+		ci.endGroup(out)
+		return lines
+	}
+
+	require.Equal(t, []string{
+		"##teamcity[testIgnored name='skipped' message='flaky']",
+		"##teamcity[testStarted name='foo' flowId='foo#2']",
+		"##teamcity[testFailed name='foo' details='boom|n|[it broke|]' flowId='foo#2']",
+		"##teamcity[testFinished name='foo' flowId='foo#2' duration='1500']",
+	}, report(teamCityReporter{}))
+
+	require.Equal(t, []string{
+		"::group::foo",
+		"::error title=foo failed::boom",
+		"::error title=foo failed::[it broke]",
+		"::endgroup::",
+	}, report(githubActionsReporter{groupOutput: true}))
+	require.Len(t, report(githubActionsReporter{}), 2)
+
+	var events []ciEvent
+	for _, line := range report(jsonLinesReporter{}) {
+		var e ciEvent
+		require.NoError(t, json.Unmarshal([]byte(line), &e))
+		require.False(t, e.Time.IsZero())
+		e.Time = time.Time{}
+		events = append(events, e)
+	}
+	require.Equal(t, []ciEvent{
+		{Event: "ignored", Test: "skipped", Message: "flaky"},
+		{Event: "started", Test: "foo", RunID: "foo#2"},
+		{Event: "failed", Test: "foo", RunID: "foo#2", Message: "boom\n[it broke]"},
+		{Event: "finished", Test: "foo", RunID: "foo#2", DurationMs: 1500},
+	}, events)
+}
+
+func TestFailureDetailsMarkdown(t *testing.T) {
+	f := newFailure(errors.New("boom\nmore details"), nil)
+	errMsg, source := summarizeFailure(f)
+	require.Equal(t, "boom", errMsg)
+	require.Contains(t, source, "ci_reporter_test.go:")
+
+	tests := []testReportForGitHub{
+		{
+			name: "foo", status: testResultFailure, errMsg: "boom", source: "foo.go:12",
+			artifactsDir: "foo/run_1",
+		},
+		{name: "bar", status: testResultSuccess},
+	}
+	u, err := newArtifactUploader("gs://bucket/artifacts", time.Hour)
+	require.NoError(t, err)
+	var b strings.Builder
+	require.NoError(t, writeFailureDetailsMarkdown(&b, tests, "https://github.com/o/r/actions/runs/1", u.browseURL))
+	require.Equal(t, `
+## Failures
+
+### `+"`foo`"+`
+
+`+"```"+`
+(foo.go:12) boom
+`+"```"+`
+
+- Artifacts: [`+"`foo/run_1`"+`](https://console.cloud.google.com/storage/browser/bucket/artifacts/foo/run_1/)
+- Test log: [`+"`foo/run_1/test.log`"+`](https://console.cloud.google.com/storage/browser/_details/bucket/artifacts/foo/run_1/test.log)
+- Runner log: [`+"`foo`"+` in the job log](https://github.com/o/r/actions/runs/1)
+`, b.String())
+
+	// Without uploaded artifacts, only their paths are given.
+	var noUploads *artifactUploader
+	b.Reset()
+	require.NoError(t, writeFailureDetailsMarkdown(&b, tests, "", noUploads.browseURL))
+	require.Contains(t, b.String(), "- Artifacts: `foo/run_1`\n- Test log: `foo/run_1/test.log`\n")
+	require.NotContains(t, b.String(), "Runner log")
+
+	// Backticks in the error can't close its code block.
+	tests[0].errMsg = "unexpected ```"
+	b.Reset()
+	require.NoError(t, writeFailureDetailsMarkdown(&b, tests, "", noUploads.browseURL))
+	require.Contains(t, b.String(), "````\n(foo.go:12) unexpected ```\n````\n")
+
+	b.Reset()
+	require.NoError(t, writeFailureDetailsMarkdown(&b, tests[1:], "", noUploads.browseURL))
+	require.Empty(t, b.String())
+}
@@ -0,0 +1,361 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/spec"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/logger"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/vm"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/vm/gce"
+	"github.com/cockroachdb/errors"
+)
+
+// infraSkippedReason is the reason reported for the tests which were not
+// scheduled because their cluster exceeds the cloud quota.
+const infraSkippedReason = "infra-skipped: the cluster exceeds the remaining cloud quota"
+
+// gceDefaultRegion and gceARM64DefaultRegion are the regions of the default
+// zone roachprod creates the clusters of non-geo tests in, when their zones
+// aren't pinned.
+const (
+	gceDefaultRegion      = "us-east1"
+	gceARM64DefaultRegion = "us-central1"
+)
+
+// regionQuotas maps the quota metrics of a region, e.g. N2_CPUS, to their
+// remaining quota.
+type regionQuotas map[string]float64
+
+// infraSkippedTest is a test which was not scheduled because its cluster
+// exceeds the remaining quota of its region.
+type infraSkippedTest struct {
+	name   string
+	owner  string
+	region string
+	metric string
+	// need is the number of CPUs of the cluster, and remaining the remaining
+	// quota of the metric in the region.
+	need      int
+	remaining float64
+}
+
+// cloudQuotaCheck checks that the clusters of the tests of a run fit in the
+// remaining CPU quota of the GCE regions they would be created in, before the
+// tests are scheduled, so that the tests which can't get a cluster are skipped
+// up front instead of failing on quota errors mid-run. See --check-cloud-quota.
+//
+// The quota of a region is fetched once, and each cluster is checked against
+// it on its own; the concurrency of the run is then limited to the remaining
+// quota, see cpuLimit. Geo tests, whose nodes span several regions, are not
+// checked. All methods are no-ops on a nil cloudQuotaCheck.
+type cloudQuotaCheck struct {
+	// fetch returns the remaining quotas of the given region.
+	fetch func(ctx context.Context, region string) (regionQuotas, error)
+	// arm64 is set if the tests whose arch isn't pinned may be given ARM64 at
+	// runtime, see archForTest, in which case their cluster is also checked
+	// as an ARM64 one.
+	arm64 bool
+	// fallbackZones are the zones the tests whose zones aren't pinned are moved
+	// to, in order of preference, if their default region lacks quota.
+	fallbackZones []string
+	// quotas caches the quotas of the regions fetched so far. The quotas of a
+	// region are nil if they couldn't be fetched.
+	quotas map[string]regionQuotas
+	// used holds the remaining quota of the metrics of the regions the
+	// clusters of the scheduled tests count against, and largest the number
+	// of CPUs of the largest of these clusters. unknown is set if the quota
+	// of some of these clusters is unknown.
+	used    map[quotaKey]float64
+	largest int
+	unknown bool
+
+	rerouted []string
+	skipped  []infraSkippedTest
+}
+
+// quotaKey identifies a quota metric of a region.
+type quotaKey struct {
+	region, metric string
+}
+
+func newCloudQuotaCheck(
+	fetch func(ctx context.Context, region string) (regionQuotas, error), fallbackZones string,
+) *cloudQuotaCheck {
+	c := &cloudQuotaCheck{
+		fetch:  fetch,
+		quotas: make(map[string]regionQuotas),
+		used:   make(map[quotaKey]float64),
+	}
+	for _, zone := range strings.Split(fallbackZones, ",") {
+		if zone = strings.TrimSpace(zone); zone != "" {
+			c.fallbackZones = append(c.fallbackZones, zone)
+		}
+	}
+	return c
+}
+
+// newGCEQuotaCheck returns a cloudQuotaCheck of the quotas of the default GCE
+// project. arm64 is set if tests may be given ARM64 at runtime.
+func newGCEQuotaCheck(fallbackZones string, arm64 bool) *cloudQuotaCheck {
+	project := gce.DefaultProject()
+	c := newCloudQuotaCheck(func(ctx context.Context, region string) (regionQuotas, error) {
+		return fetchGCERegionQuotas(ctx, project, region)
+	}, fallbackZones)
+	c.arm64 = arm64
+	return c
+}
+
+// fetchGCERegionQuotas returns the remaining quotas of the given region of the
+// given GCE project.
+func fetchGCERegionQuotas(ctx context.Context, project, region string) (regionQuotas, error) {
+	args := []string{"compute", "regions", "describe", region, "--format=json"}
+	if project != "" {
+		args = append(args, "--project", project)
+	}
+	out, err := exec.CommandContext(ctx, "gcloud", args...).Output()
+	if err != nil {
+		return nil, errors.Wrapf(err, "describing region %s", region)
+	}
+	var desc struct {
+		Quotas []struct {
+			Metric string  `json:"metric"`
+			Limit  float64 `json:"limit"`
+			Usage  float64 `json:"usage"`
+		} `json:"quotas"`
+	}
+	if err := json.Unmarshal(out, &desc); err != nil {
+		return nil, errors.Wrapf(err, "parsing the description of region %s", region)
+	}
+	quotas := make(regionQuotas, len(desc.Quotas))
+	for _, q := range desc.Quotas {
+		quotas[q.Metric] = q.Limit - q.Usage
+	}
+	return quotas, nil
+}
+
+// gceQuotaMetric returns the regional quota metric which the CPUs of the
+// given cluster count against.
+func gceQuotaMetric(s *spec.ClusterSpec) string {
+	machineType := s.GCE.MachineType
+	if machineType == "" {
+		machineType, _ = spec.SelectGCEMachineType(s.CPUs, s.Mem, s.Arch)
+	}
+	family, _, _ := strings.Cut(machineType, "-")
+	switch family {
+	case "n1", "e2", "f1", "g1":
+		// The older machine families share the generic CPU quota.
+		return "CPUS"
+	}
+	return strings.ToUpper(family) + "_CPUS"
+}
+
+// gceRegion returns the region of the given zone, e.g. us-east1 for
+// us-east1-b.
+func gceRegion(zone string) string {
+	if i := strings.LastIndex(zone, "-"); i > 0 {
+		return zone[:i]
+	}
+	return zone
+}
+
+// gceTestRegion returns the region the cluster of a non-geo test would be
+// created in, given the zones of the run, and whether its zones are pinned by
+// the test or the run.
+func gceTestRegion(s *spec.ClusterSpec, runZones string) (region string, pinned bool) {
+	zones := s.GCE.Zones
+	if zones == "" {
+		zones = runZones
+	}
+	if zones != "" {
+		zone, _, _ := strings.Cut(zones, ",")
+		return gceRegion(strings.TrimSpace(zone)), true
+	}
+	if s.Arch == vm.ArchARM64 {
+		return gceARM64DefaultRegion, false
+	}
+	return gceDefaultRegion, false
+}
+
+// remaining returns the remaining quota of the given metric in the given
+// region, and whether it's known.
+func (c *cloudQuotaCheck) remaining(
+	ctx context.Context, l *logger.Logger, region, metric string,
+) (float64, bool) {
+	quotas, ok := c.quotas[region]
+	if !ok {
+		var err error
+		if quotas, err = c.fetch(ctx, region); err != nil {
+			l.PrintfCtx(ctx, "failed to fetch the quotas of %s, not checking them: %s", region, err)
+		}
+		c.quotas[region] = quotas
+	}
+	v, ok := quotas[metric]
+	return v, ok
+}
+
+// fallbackZone returns the first fallback zone whose region has enough quota
+// of the given metric for the given number of CPUs, or an empty string if
+// there is none.
+func (c *cloudQuotaCheck) fallbackZone(
+	ctx context.Context, l *logger.Logger, metric string, need int,
+) string {
+	for _, zone := range c.fallbackZones {
+		if remaining, ok := c.remaining(ctx, l, gceRegion(zone), metric); ok && float64(need) <= remaining {
+			return zone
+		}
+	}
+	return ""
+}
+
+// fits returns whether the given cluster fits in the remaining quota of its
+// region, given the zones of the run, and records the quota it counts against
+// if so. Clusters fit if the quota of their region is unknown.
+func (c *cloudQuotaCheck) fits(
+	ctx context.Context, l *logger.Logger, s *spec.ClusterSpec, runZones string,
+) bool {
+	key := quotaKey{metric: gceQuotaMetric(s)}
+	key.region, _ = gceTestRegion(s, runZones)
+	remaining, ok := c.remaining(ctx, l, key.region, key.metric)
+	if !ok {
+		c.unknown = true
+		return true
+	}
+	if float64(s.TotalCPUs()) > remaining {
+		return false
+	}
+	c.used[key] = remaining
+	return true
+}
+
+// filter returns the tests whose cluster fits in the remaining quota of its
+// region, given the zones of the run. The tests whose zones aren't pinned are
+// moved to a fallback zone if it has quota for them, and the tests which don't
+// fit anywhere are recorded as infra-skipped and left out. Tests are kept if
+// the quota of their region is unknown.
+//
+// The tests whose arch isn't pinned, and which may thus be given ARM64 at
+// runtime, are pinned to AMD64 if their cluster doesn't fit as an ARM64 one,
+// or if they are moved to a fallback zone, so that they don't end up with a
+// cluster which wasn't checked.
+func (c *cloudQuotaCheck) filter(
+	ctx context.Context, l *logger.Logger, tests []registry.TestSpec, runZones string,
+) []registry.TestSpec {
+	if c == nil {
+		return tests
+	}
+	res := make([]registry.TestSpec, 0, len(tests))
+	for _, t := range tests {
+		need := t.Cluster.TotalCPUs()
+		if need == 0 || t.Skip != "" {
+			res = append(res, t)
+			continue
+		}
+		if t.Cluster.Geo {
+			c.largest = max(c.largest, need)
+			res = append(res, t)
+			continue
+		}
+		if c.arm64 && t.Cluster.Arch == "" {
+			s := t.Cluster
+			s.Arch = vm.ArchARM64
+			if !c.fits(ctx, l, &s, runZones) {
+				t.Cluster.Arch = vm.ArchAMD64
+				c.rerouted = append(c.rerouted, fmt.Sprintf("%s (to %s)", t.Name, vm.ArchAMD64))
+			}
+		}
+		metric := gceQuotaMetric(&t.Cluster)
+		region, pinned := gceTestRegion(&t.Cluster, runZones)
+		remaining, _ := c.remaining(ctx, l, region, metric)
+		if c.fits(ctx, l, &t.Cluster, runZones) {
+			c.largest = max(c.largest, need)
+			res = append(res, t)
+			continue
+		}
+		if !pinned {
+			if zone := c.fallbackZone(ctx, l, metric, need); zone != "" {
+				t.Cluster.GCE.Zones = zone
+				if c.arm64 && t.Cluster.Arch == "" {
+					t.Cluster.Arch = vm.ArchAMD64
+				}
+				c.fits(ctx, l, &t.Cluster, runZones)
+				c.largest = max(c.largest, need)
+				c.rerouted = append(c.rerouted, fmt.Sprintf("%s (to %s)", t.Name, zone))
+				res = append(res, t)
+				continue
+			}
+		}
+		c.skipped = append(c.skipped, infraSkippedTest{
+			name:      t.Name,
+			owner:     string(t.Owner),
+			region:    region,
+			metric:    metric,
+			need:      need,
+			remaining: remaining,
+		})
+	}
+	return res
+}
+
+// cpuLimit returns the number of CPUs the clusters of the scheduled tests can
+// use concurrently given the remaining quota, i.e. the sum of the remaining
+// quota of the metrics of the regions they count against, and whether it's
+// known. The limit is never lower than the largest cluster, so that every
+// test can still run.
+func (c *cloudQuotaCheck) cpuLimit() (int, bool) {
+	if c == nil || c.unknown || len(c.used) == 0 {
+		return 0, false
+	}
+	var limit float64
+	for _, remaining := range c.used {
+		limit += remaining
+	}
+	return max(int(limit), c.largest), true
+}
+
+// infraSkippedTests returns the tests which were not scheduled because of the
+// quota, in the order of the run.
+func (c *cloudQuotaCheck) infraSkippedTests() []infraSkippedTest {
+	if c == nil {
+		return nil
+	}
+	return c.skipped
+}
+
+// summary returns a description of the tests which were moved to another
+// zone or not scheduled because of the quota, or an empty string if there are
+// none.
+func (c *cloudQuotaCheck) summary() string {
+	if c == nil {
+		return ""
+	}
+	var parts []string
+	if len(c.rerouted) > 0 {
+		parts = append(parts, fmt.Sprintf("%d tests moved to a fallback zone or to AMD64, as their default region lacks quota:\n%s",
+			len(c.rerouted), strings.Join(c.rerouted, "\n")))
+	}
+	if len(c.skipped) > 0 {
+		tests := make([]string, len(c.skipped))
+		for i, t := range c.skipped {
+			tests[i] = fmt.Sprintf("%s (%d %s needed in %s, %.0f remaining)",
+				t.name, t.need, t.metric, t.region, t.remaining)
+		}
+		parts = append(parts, fmt.Sprintf("%d tests infra-skipped, as their cluster exceeds the remaining cloud quota:\n%s",
+			len(tests), strings.Join(tests, "\n")))
+	}
+	return strings.Join(parts, "\n")
+}
@@ -0,0 +1,149 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/spec"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/vm"
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGCEQuotaMetric(t *testing.T) {
+	s := spec.MakeClusterSpec(3, spec.CPU(8))
+	require.Equal(t, "N2_CPUS", gceQuotaMetric(&s))
+	s = spec.MakeClusterSpec(3, spec.CPU(8), spec.Arch(vm.ArchARM64))
+	require.Equal(t, "T2A_CPUS", gceQuotaMetric(&s))
+	s = spec.MakeClusterSpec(3, spec.CPU(8), spec.GCEMachineType("n1-standard-8"))
+	require.Equal(t, "CPUS", gceQuotaMetric(&s))
+	s = spec.MakeClusterSpec(3, spec.CPU(8), spec.GCEMachineType("c2-standard-8"))
+	require.Equal(t, "C2_CPUS", gceQuotaMetric(&s))
+}
+
+func TestGCETestRegion(t *testing.T) {
+	s := spec.MakeClusterSpec(3)
+	region, pinned := gceTestRegion(&s, "")
+	require.Equal(t, gceDefaultRegion, region)
+	require.False(t, pinned)
+
+	region, pinned = gceTestRegion(&s, "europe-west2-b,us-west1-b")
+	require.Equal(t, "europe-west2", region)
+	require.True(t, pinned)
+
+	s = spec.MakeClusterSpec(3, spec.GCEZones("us-west1-b"))
+	region, pinned = gceTestRegion(&s, "europe-west2-b")
+	require.Equal(t, "us-west1", region)
+	require.True(t, pinned)
+
+	s = spec.MakeClusterSpec(3, spec.Arch(vm.ArchARM64))
+	region, _ = gceTestRegion(&s, "")
+	require.Equal(t, gceARM64DefaultRegion, region)
+}
+
+func TestCloudQuotaCheck(t *testing.T) {
+	quotas := map[string]regionQuotas{
+		"us-east1":    {"N2_CPUS": 40},
+		"us-central1": {"N2_CPUS": 10},
+		"us-west1":    {"N2_CPUS": 100},
+	}
+	fetch := func(_ context.Context, region string) (regionQuotas, error) {
+		if q, ok := quotas[region]; ok {
+			return q, nil
+		}
+		return nil, errors.Newf("unknown region %s", region)
+	}
+	c := newCloudQuotaCheck(fetch, "us-central1-b, us-west1-b")
+	require.Equal(t, []string{"us-central1-b", "us-west1-b"}, c.fallbackZones)
+
+	tests := []registry.TestSpec{
+		// Fits in the default region.
+		{Name: "small", Owner: "kv", Cluster: spec.MakeClusterSpec(4, spec.CPU(8))},
+		// Moved to the first fallback zone with enough quota.
+		{Name: "large", Owner: "kv", Cluster: spec.MakeClusterSpec(8, spec.CPU(8))},
+		// Pinned to a region without enough quota.
+		{Name: "pinned", Owner: "sql", Cluster: spec.MakeClusterSpec(8, spec.CPU(8), spec.GCEZones("us-east1-c"))},
+		// Doesn't fit anywhere.
+		{Name: "huge", Owner: "sql", Cluster: spec.MakeClusterSpec(16, spec.CPU(16))},
+		// The quota of the region is unknown.
+		{Name: "unknown", Owner: "kv", Cluster: spec.MakeClusterSpec(16, spec.CPU(16), spec.GCEZones("asia-east1-a"))},
+		// Geo and skipped tests are not checked.
+		{Name: "geo", Owner: "kv", Cluster: spec.MakeClusterSpec(16, spec.CPU(16), spec.Geo())},
+		{Name: "skipped", Owner: "kv", Cluster: spec.MakeClusterSpec(16, spec.CPU(16)), Skip: "flaky"},
+	}
+	res := c.filter(context.Background(), nilLogger(), tests, "" /* runZones */)
+	var names []string
+	for _, t := range res {
+		names = append(names, t.Name)
+	}
+	require.Equal(t, []string{"small", "large", "unknown", "geo", "skipped"}, names)
+	require.Equal(t, "", res[0].Cluster.GCE.Zones)
+	require.Equal(t, "us-west1-b", res[1].Cluster.GCE.Zones)
+	// The tests passed in are left as is.
+	require.Equal(t, "", tests[1].Cluster.GCE.Zones)
+
+	require.Equal(t, []infraSkippedTest{
+		{name: "pinned", owner: "sql", region: "us-east1", metric: "N2_CPUS", need: 64, remaining: 40},
+		{name: "huge", owner: "sql", region: "us-east1", metric: "N2_CPUS", need: 256, remaining: 40},
+	}, c.infraSkippedTests())
+	require.Equal(t, `1 tests moved to a fallback zone or to AMD64, as their default region lacks quota:
+large (to us-west1-b)
+2 tests infra-skipped, as their cluster exceeds the remaining cloud quota:
+pinned (64 N2_CPUS needed in us-east1, 40 remaining)
+huge (256 N2_CPUS needed in us-east1, 40 remaining)`, c.summary())
+	// The quota of asia-east1 is unknown, so the concurrency isn't limited.
+	_, ok := c.cpuLimit()
+	require.False(t, ok)
+
+	// A nil check keeps all the tests.
+	var nilCheck *cloudQuotaCheck
+	require.Len(t, nilCheck.filter(context.Background(), nilLogger(), tests, ""), len(tests))
+	require.Empty(t, nilCheck.infraSkippedTests())
+	require.Empty(t, nilCheck.summary())
+	_, ok = nilCheck.cpuLimit()
+	require.False(t, ok)
+}
+
+func TestCloudQuotaCheckARM64(t *testing.T) {
+	quotas := map[string]regionQuotas{
+		"us-east1":    {"N2_CPUS": 40},
+		"us-central1": {"T2A_CPUS": 16},
+	}
+	fetch := func(_ context.Context, region string) (regionQuotas, error) {
+		return quotas[region], nil
+	}
+	c := newCloudQuotaCheck(fetch, "" /* fallbackZones */)
+	c.arm64 = true
+
+	tests := []registry.TestSpec{
+		// Fits as both an AMD64 and an ARM64 cluster.
+		{Name: "small", Owner: "kv", Cluster: spec.MakeClusterSpec(2, spec.CPU(8))},
+		// Only fits as an AMD64 cluster, so it's pinned to AMD64.
+		{Name: "large", Owner: "kv", Cluster: spec.MakeClusterSpec(4, spec.CPU(8))},
+		// Pinned to ARM64, so only checked as such.
+		{Name: "arm64", Owner: "kv", Cluster: spec.MakeClusterSpec(2, spec.CPU(4), spec.Arch(vm.ArchARM64))},
+	}
+	res := c.filter(context.Background(), nilLogger(), tests, "" /* runZones */)
+	require.Len(t, res, 3)
+	require.Equal(t, vm.CPUArch(""), res[0].Cluster.Arch)
+	require.Equal(t, vm.ArchAMD64, res[1].Cluster.Arch)
+	require.Equal(t, vm.ArchARM64, res[2].Cluster.Arch)
+	require.Empty(t, c.infraSkippedTests())
+
+	// The clusters count against the N2 quota of us-east1 and the T2A quota of
+	// us-central1.
+	limit, ok := c.cpuLimit()
+	require.True(t, ok)
+	require.Equal(t, 56, limit)
+}
@@ -20,6 +20,7 @@ import (
 	"io/fs"
 	"math/rand"
 	"net"
+	"net/http"
 	"net/url"
 	"os"
 	"os/exec"
@@ -52,7 +53,9 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/roachprod/prometheus"
 	"github.com/cockroachdb/cockroach/pkg/roachprod/vm"
 	"github.com/cockroachdb/cockroach/pkg/roachprod/vm/gce"
+	"github.com/cockroachdb/cockroach/pkg/util/ctxgroup"
 	"github.com/cockroachdb/cockroach/pkg/util/httputil"
+	"github.com/cockroachdb/cockroach/pkg/util/humanizeutil"
 	"github.com/cockroachdb/cockroach/pkg/util/log"
 	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
 	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
@@ -339,6 +342,13 @@ func initBinariesAndLibraries() {
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "WARN: unable to find %q for %q: %s\n", "cockroach-ea", vm.ArchARM64, err)
 		}
+	} else if defaultArch != vm.ArchARM64 && roachtestflags.Cloud != spec.Local {
+		// Mixed-architecture clusters need arm64 binaries even if arm64 clusters
+		// are not otherwise provisioned. Tests using them fail to set up if the
+		// binaries are missing, so don't bail out here.
+		cockroach[vm.ArchARM64], _ = resolveBinary("cockroach", cockroachPath, vm.ArchARM64, false, false)
+		workload[vm.ArchARM64], _ = resolveBinary("workload", workloadPath, vm.ArchARM64, false, false)
+		cockroachEA[vm.ArchARM64], _ = resolveBinary("cockroach-ea", cockroachEAPath, vm.ArchARM64, false, true)
 	}
 	if roachtestflags.FIPSProbability > 0 && defaultArch != vm.ArchFIPS {
 		fmt.Printf("Locating and verifying binaries for os=%q, arch=%q\n", defaultOSName, vm.ArchFIPS)
@@ -354,7 +364,7 @@ func initBinariesAndLibraries() {
 	// In v20.2 or higher, optionally expect certain library files to exist.
 	// Since they may not be found in older versions, do not hard error if they are not found.
 	for _, arch := range []vm.CPUArch{vm.ArchAMD64, vm.ArchARM64, vm.ArchFIPS} {
-		if roachtestflags.ARM64Probability == 0 && defaultArch != vm.ArchARM64 && arch == vm.ArchARM64 {
+		if cockroach[vm.ArchARM64] == "" && arch == vm.ArchARM64 {
 			// arm64 isn't used, skip finding libs for it.
 			continue
 		}
@@ -398,6 +408,9 @@ func initBinariesAndLibraries() {
 }
 
 type clusterRegistry struct {
+	// metrics, if set, record the destruction of clusters.
+	metrics *runnerMetrics
+
 	mu struct {
 		syncutil.Mutex
 		clusters map[string]*clusterImpl
@@ -437,8 +450,10 @@ func (r *clusterRegistry) unregisterCluster(c *clusterImpl) bool {
 		// method to be called defensively.
 		return false
 	}
-	if err := c.removeLabels([]string{VmLabelTestRunID}); err != nil && c.l != nil {
-		c.l.Printf("failed to remove label from cluster [%s] - %s", c.name, err)
+	if !c.destroyState.destroyFailed {
+		if err := c.removeLabels([]string{VmLabelTestRunID}); err != nil && c.l != nil {
+			c.l.Printf("failed to remove label from cluster [%s] - %s", c.name, err)
+		}
 	}
 	delete(r.mu.clusters, c.name)
 	if c.tag != "" {
@@ -456,6 +471,26 @@ func (r *clusterRegistry) countForTag(tag string) int {
 	return r.mu.tagCount[tag]
 }
 
+// isRegistered returns whether the cluster with the given name is registered.
+func (r *clusterRegistry) isRegistered(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.mu.clusters[name] != nil
+}
+
+// isSaved returns whether the cluster with the given name was saved for
+// debugging.
+func (r *clusterRegistry) isSaved(name string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for c := range r.mu.savedClusters {
+		if c.name == name {
+			return true
+		}
+	}
+	return false
+}
+
 // markClusterAsSaved marks c such that it will not be destroyed by
 // destroyAllClusters.
 // msg is a message recording the reason why the cluster is being saved (i.e.
@@ -466,6 +501,13 @@ func (r *clusterRegistry) markClusterAsSaved(c *clusterImpl, msg string) {
 	r.mu.Unlock()
 }
 
+// unmarkClusterAsSaved undoes markClusterAsSaved.
+func (r *clusterRegistry) unmarkClusterAsSaved(c *clusterImpl) {
+	r.mu.Lock()
+	delete(r.mu.savedClusters, c)
+	r.mu.Unlock()
+}
+
 type clusterWithMsg struct {
 	*clusterImpl
 	savedMsg string
@@ -502,30 +544,21 @@ func (r *clusterRegistry) destroyAllClusters(ctx context.Context, l *logger.Logg
 		defer close(done)
 
 		var clusters []*clusterImpl
-		savedClusters := make(map[*clusterImpl]struct{})
 		r.mu.Lock()
 		for _, c := range r.mu.clusters {
-			clusters = append(clusters, c)
-		}
-		for c := range r.mu.savedClusters {
-			savedClusters[c] = struct{}{}
+			if _, ok := r.mu.savedClusters[c]; !ok {
+				clusters = append(clusters, c)
+			}
 		}
 		r.mu.Unlock()
 
-		var wg sync.WaitGroup
-		wg.Add(len(clusters))
-		for _, c := range clusters {
-			go func(c *clusterImpl) {
-				defer wg.Done()
-				if _, ok := savedClusters[c]; !ok {
-					// We don't close the logger here since the cluster may be still in use
-					// by a test, and so the logger might still be needed.
-					c.Destroy(ctx, dontCloseLogger, l)
-				}
-			}(c)
+		// We don't close the loggers here since the clusters may be still in use
+		// by a test, and so the loggers might still be needed.
+		start := timeutil.Now()
+		destroyClusters(ctx, l, clusters, dontCloseLogger, roachtestflags.TeardownConcurrency)
+		if len(clusters) > 0 {
+			l.PrintfCtx(ctx, "destroyed %d clusters in %s", len(clusters), timeutil.Since(start))
 		}
-
-		wg.Wait()
 	}()
 
 	select {
@@ -534,6 +567,33 @@ func (r *clusterRegistry) destroyAllClusters(ctx context.Context, l *logger.Logg
 	}
 }
 
+// destroyClusters destroys the given clusters, at most concurrency at a time
+// unless it is 0, and blocks until they're destroyed. The nodes of each
+// cluster are wiped or destroyed in parallel by roachprod.
+func destroyClusters(
+	ctx context.Context, l *logger.Logger, clusters []*clusterImpl, lo closeLoggerOpt, concurrency int,
+) {
+	if concurrency <= 0 {
+		concurrency = len(clusters)
+	}
+	sem := make(chan struct{}, max(concurrency, 1))
+	var wg sync.WaitGroup
+	wg.Add(len(clusters))
+	for _, c := range clusters {
+		sem <- struct{}{}
+		go func(c *clusterImpl) {
+			defer func() { <-sem }()
+			defer wg.Done()
+			destroyCluster(c, ctx, lo, l)
+		}(c)
+	}
+	wg.Wait()
+}
+
+// destroyCluster destroys a cluster for destroyClusters. It can be overridden
+// by tests.
+var destroyCluster = (*clusterImpl).Destroy
+
 func makeClusterName(name string) string {
 	return vm.DNSSafeName(name)
 }
@@ -667,11 +727,22 @@ type clusterImpl struct {
 	// destroyState contains state related to the cluster's destruction.
 	destroyState destroyState
 
+	// tainted, if set, is the reason why the cluster is unfit for reuse by
+	// other tests after a failure. See clusterPool.
+	tainted string
+
+	// labels are the custom labels the cluster was created with, if it was
+	// created by roachtest. See clusterConfig.labels.
+	labels map[string]string
+
 	// grafanaTags contains the cluster and test information that grafana will separate
 	// test runs by. This is used by the roachtest grafana API to create appropriately
 	// tagged grafana annotations. If empty, grafana is not available.
 	grafanaTags               []string
 	disableGrafanaAnnotations atomic.Bool
+	// internalGrafanaStarted is set while the grafana instance started by the
+	// test through StartGrafana is running; annotations are also added to it.
+	internalGrafanaStarted atomic.Bool
 
 	// State that can be accessed concurrently (in particular, read from the UI
 	// HTML generator).
@@ -688,6 +759,14 @@ func (c *clusterImpl) Name() string {
 	return c.name
 }
 
+// taint marks the cluster as unfit for reuse by other tests, for the given
+// reason. The first reason is retained.
+func (c *clusterImpl) taint(reason string) {
+	if c.tainted == "" {
+		c.tainted = reason
+	}
+}
+
 // Spec returns the spec underlying the cluster.
 func (c *clusterImpl) Spec() spec.ClusterSpec {
 	return c.spec
@@ -732,6 +811,10 @@ type destroyState struct {
 	// an existing roachprod cluster.
 	// If not set, Destroy() only wipes the cluster.
 	owned bool
+	// destroyFailed is set if `roachprod destroy` failed, in which case the
+	// cluster keeps its test run ID label, for the leaked cluster detector to
+	// report it.
+	destroyFailed bool
 
 	mu struct {
 		syncutil.Mutex
@@ -783,6 +866,21 @@ type clusterConfig struct {
 	// cluster using the cluster's name; snapshots are taken when the test times
 	// out.
 	sideEyeToken string
+	// labels are added to the VMs and disks of the cluster, on top of the
+	// default ones.
+	labels map[string]string
+}
+
+// withLabels returns a copy of labels with the given extra labels added.
+func withLabels(labels, extra map[string]string) map[string]string {
+	res := make(map[string]string, len(labels)+len(extra))
+	for k, v := range labels {
+		res[k] = v
+	}
+	for k, v := range extra {
+		res[k] = v
+	}
+	return res
 }
 
 // clusterFactory is a creator of clusters.
@@ -941,10 +1039,32 @@ func (f *clusterFactory) newCluster(
 	}
 
 	createFlagsOverride(&createVMOpts)
+	createVMOpts.CustomLabels = withLabels(createVMOpts.CustomLabels, cfg.labels)
 	// Make sure expiration is changed if --lifetime override flag
 	// is passed.
 	cfg.spec.Lifetime = createVMOpts.Lifetime
 
+	// The arm64 nodes of mixed-architecture clusters are created with their
+	// own options.
+	var arm64CreateVMOpts vm.CreateOpts
+	arm64ProviderOptsContainer := vm.CreateProviderOptionsContainer()
+	if cfg.spec.ARM64Nodes > 0 {
+		arm64Params := params
+		arm64Params.PreferredArch = vm.ArchARM64
+		var arm64ProviderOpts vm.ProviderOpts
+		var arm64Arch vm.CPUArch
+		arm64CreateVMOpts, arm64ProviderOpts, _, arm64Arch, err = cfg.spec.RoachprodOpts(arm64Params)
+		if err != nil {
+			return nil, nil, err
+		}
+		if arm64Arch != vm.ArchARM64 {
+			return nil, nil, errors.Errorf("unable to select an arm64 machine type for mixed-architecture cluster")
+		}
+		arm64ProviderOptsContainer.SetProviderOpts(clusterCloud.String(), arm64ProviderOpts)
+		createFlagsOverride(&arm64CreateVMOpts)
+		arm64CreateVMOpts.CustomLabels = withLabels(arm64CreateVMOpts.CustomLabels, cfg.labels)
+	}
+
 	// Attempt to create a cluster several times to be able to move past
 	// temporary flakiness in the cloud providers.
 	maxAttempts := 3
@@ -983,7 +1103,8 @@ func (f *clusterFactory) newCluster(
 			destroyState: destroyState{
 				owned: true,
 			},
-			l: l,
+			labels: cfg.labels,
+			l:      l,
 		}
 		c.status("creating cluster")
 
@@ -994,15 +1115,25 @@ func (f *clusterFactory) newCluster(
 		// There isn't a point to creating a different sized vm for local clusters, so skip it.
 		if cfg.spec.WorkloadNode && !cfg.localCluster {
 			opts = []*cloud.ClusterCreateOpts{
-				{Nodes: cfg.spec.NodeCount - 1, CreateOpts: createVMOpts, ProviderOptsContainer: providerOptsContainer},
-				{Nodes: 1, CreateOpts: createVMOpts, ProviderOptsContainer: workloadProviderOptsContainer},
+				{Nodes: cfg.spec.NodeCount - cfg.spec.NumWorkloadNodes(), CreateOpts: createVMOpts, ProviderOptsContainer: providerOptsContainer},
+				{Nodes: cfg.spec.NumWorkloadNodes(), CreateOpts: createVMOpts, ProviderOptsContainer: workloadProviderOptsContainer},
 			}
 		}
+		if cfg.spec.ARM64Nodes > 0 {
+			// The arm64 nodes are the last CockroachDB nodes, i.e. they come
+			// before any workload nodes.
+			arm64CreateVMOpts.ClusterName = c.name
+			opts[0].Nodes -= cfg.spec.ARM64Nodes
+			opts = append([]*cloud.ClusterCreateOpts{
+				opts[0],
+				{Nodes: cfg.spec.ARM64Nodes, CreateOpts: arm64CreateVMOpts, ProviderOptsContainer: arm64ProviderOptsContainer},
+			}, opts[1:]...)
+		}
 		err = create(ctx, l, cfg.username, opts...)
 		if err == nil {
 			// Start the Side-Eye agents on all the nodes if we are configured to do so. Side-Eye
 			// doesn't currently support ARM64, so skip those clusters.
-			if cfg.sideEyeToken != "" && !cfg.localCluster && c.arch != vm.ArchARM64 {
+			if cfg.sideEyeToken != "" && !cfg.localCluster && c.arch != vm.ArchARM64 && cfg.spec.ARM64Nodes == 0 {
 				if err := c.StartSideEyeAgents(ctx, l, cfg.sideEyeToken); err != nil {
 					l.Errorf("failed to start Side-Eye agents. Continuing without them.\nError: %s", err)
 				}
@@ -1028,7 +1159,7 @@ func (f *clusterFactory) newCluster(
 		l.PrintfCtx(ctx, "cluster creation failed, cleaning up in case it was partially created: %s", err)
 		c.Destroy(ctx, closeLogger, l)
 		if i >= maxAttempts {
-			return nil, nil, err
+			return nil, nil, markCloudAPIError(err)
 		}
 		// Try again to create the cluster.
 	}
@@ -1139,6 +1270,16 @@ func (c *clusterImpl) Save(ctx context.Context, msg string, l *logger.Logger) {
 	c.destroyState.mu.Unlock()
 }
 
+// destroySaved destroys a cluster which was saved for debugging.
+func (c *clusterImpl) destroySaved(ctx context.Context, l *logger.Logger) {
+	c.r.unmarkClusterAsSaved(c)
+	c.destroyState.mu.Lock()
+	c.destroyState.mu.saved = false
+	c.destroyState.mu.savedMsg = ""
+	c.destroyState.mu.Unlock()
+	c.Destroy(ctx, closeLogger, l)
+}
+
 var errClusterNotFound = errors.New("cluster not found")
 
 // validateCluster takes a cluster and checks that the reality corresponds to
@@ -1185,7 +1326,17 @@ func (c *clusterImpl) lister() option.NodeLister {
 	if c.f != nil { // accommodates poorly set up tests
 		fatalf = c.f.Fatalf
 	}
-	return option.NodeLister{NodeCount: c.spec.NodeCount, WorkloadNodeProvisioned: c.spec.WorkloadNode, Fatalf: fatalf}
+	var roles []string
+	for _, r := range c.spec.WorkloadRoles() {
+		roles = append(roles, string(r))
+	}
+	return option.NodeLister{
+		NodeCount:               c.spec.NodeCount,
+		WorkloadNodeProvisioned: c.spec.WorkloadNode,
+		WorkloadNodeCount:       c.spec.WorkloadNodeCount,
+		WorkloadNodeRoles:       roles,
+		Fatalf:                  fatalf,
+	}
 }
 
 func (c *clusterImpl) All() option.NodeListOption {
@@ -1212,6 +1363,10 @@ func (c *clusterImpl) WorkloadNode() option.NodeListOption {
 	return c.lister().WorkloadNode()
 }
 
+func (c *clusterImpl) WorkloadNodesWithRole(role spec.WorkloadRole) option.NodeListOption {
+	return c.lister().WorkloadNodesWithRole(string(role))
+}
+
 // FetchLogs downloads the logs from the cluster using `roachprod get`.
 // The logs will be placed in the test's artifacts dir.
 func (c *clusterImpl) FetchLogs(ctx context.Context, l *logger.Logger) error {
@@ -1383,6 +1538,94 @@ COCKROACH_DEBUG_TS_IMPORT_FILE=tsdump.gob cockroach start-single-node --insecure
 	})
 }
 
+// profileDuration is the duration of the CPU profiles fetched by
+// FetchProfiles.
+const profileDuration = 10 * time.Second
+
+// clusterProfiles are the profiles fetched by FetchProfiles from every node,
+// keyed by the suffix of the file they are saved to.
+var clusterProfiles = map[string]string{
+	"cpu.pprof":      fmt.Sprintf("debug/pprof/profile?seconds=%d", int(profileDuration.Seconds())),
+	"heap.pprof":     "debug/pprof/heap",
+	"mutex.pprof":    "debug/pprof/mutex",
+	"goroutines.txt": "debug/pprof/goroutine?debug=2",
+}
+
+// FetchProfiles downloads CPU, heap and mutex profiles, as well as goroutine
+// dumps, from the status endpoints of the CockroachDB nodes. They are placed
+// in the profiles dir of the test's artifacts dir, e.g. profiles/1.heap.pprof
+// for the heap profile of n1.
+func (c *clusterImpl) FetchProfiles(ctx context.Context, l *logger.Logger) error {
+	if c.spec.NodeCount == 0 {
+		// No nodes can happen during unit tests and implies nothing to do.
+		return nil
+	}
+
+	l.Printf("fetching profiles\n")
+	c.status("fetching profiles")
+
+	return timeutil.RunWithTimeout(ctx, "fetch profiles", profileDuration+time.Minute, func(ctx context.Context) error {
+		nodes := c.CRDBNodes()
+		adminAddrs, err := c.ExternalAdminUIAddr(ctx, l, nodes)
+		if err != nil {
+			return errors.Wrap(err, "unable to get admin UI address(es)")
+		}
+		dir := filepath.Join(c.t.ArtifactsDir(), "profiles")
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		scheme := "http"
+		if c.IsSecure() {
+			scheme = "https"
+		}
+		client := roachtestutil.DefaultHTTPClient(c, l, roachtestutil.HTTPTimeout(profileDuration+30*time.Second))
+
+		var mu syncutil.Mutex
+		var errs error
+		var wg sync.WaitGroup
+		for i, addr := range adminAddrs {
+			for suffix, path := range clusterProfiles {
+				wg.Add(1)
+				go func(node int, addr, suffix, path string) {
+					defer wg.Done()
+					file := filepath.Join(dir, fmt.Sprintf("%d.%s", node, suffix))
+					profileURL := fmt.Sprintf("%s://%s/%s", scheme, addr, path)
+					if err := fetchProfile(ctx, client, profileURL, file); err != nil {
+						mu.Lock()
+						defer mu.Unlock()
+						errs = errors.CombineErrors(errs, errors.Wrapf(err, "n%d: %s", node, path))
+					}
+				}(nodes[i], addr, suffix, path)
+			}
+		}
+		wg.Wait()
+		return errs
+	})
+}
+
+// fetchProfile downloads the profile at the given URL to the given file.
+func fetchProfile(
+	ctx context.Context, client *roachtestutil.RoachtestHTTPClient, profileURL, file string,
+) error {
+	resp, err := client.Get(ctx, profileURL)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return errors.Newf("unexpected status: %s", resp.Status)
+	}
+	out, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, resp.Body); err != nil {
+		_ = out.Close()
+		return err
+	}
+	return out.Close()
+}
+
 // FetchDebugZip downloads the debug zip from the cluster using `roachprod ssh`.
 // The logs will be placed at `dest`, relative to the test's artifacts dir.
 //
@@ -1390,6 +1633,9 @@ COCKROACH_DEBUG_TS_IMPORT_FILE=tsdump.gob cockroach start-single-node --insecure
 // node, and if that fails, it will try subsequent nodes. The caller may pass a
 // list of nodes via opts if they want to target which node(s) to grab the debug
 // zip from.
+//
+// The collection is bounded by --debug-zip-timeout, and debug zips larger than
+// --debug-zip-max-size are not downloaded.
 func (c *clusterImpl) FetchDebugZip(
 	ctx context.Context, l *logger.Logger, dest string, opts ...option.Option,
 ) error {
@@ -1412,7 +1658,7 @@ func (c *clusterImpl) FetchDebugZip(
 	}
 
 	// Don't hang forever if we can't fetch the debug zip.
-	return timeutil.RunWithTimeout(ctx, "debug zip", 5*time.Minute, func(ctx context.Context) error {
+	return timeutil.RunWithTimeout(ctx, "debug zip", roachtestflags.DebugZipTimeout, func(ctx context.Context) error {
 		const zipName = "debug.zip"
 		path := filepath.Join(c.t.ArtifactsDir(), dest)
 		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
@@ -1446,6 +1692,16 @@ func (c *clusterImpl) FetchDebugZip(
 				l.Printf("%s debug zip failed on node %d: %v", test.DefaultCockroachPath, node, err)
 				continue
 			}
+			if maxSize := int64(roachtestflags.DebugZipMaxSizeMB) << 20; maxSize > 0 {
+				res, err := c.RunWithDetailsSingleNode(ctx, l, option.WithNodes(c.Node(node)), "stat", "-c", "%s", zipName)
+				if err != nil {
+					return errors.Wrap(err, "cluster.FetchDebugZip")
+				}
+				if size, err := strconv.ParseInt(strings.TrimSpace(res.Stdout), 10, 64); err == nil && size > maxSize {
+					return errors.Newf("debug zip on node %d is %s, larger than the %s cap; not downloading it",
+						node, humanizeutil.IBytes(size), humanizeutil.IBytes(maxSize))
+				}
+			}
 			return errors.Wrap(c.Get(ctx, c.l, zipName /* src */, path /* dest */, c.Node(node)), "cluster.FetchDebugZip")
 		}
 		return nil
@@ -1503,13 +1759,17 @@ func (c *clusterImpl) FetchVMSpecs(ctx context.Context, l *logger.Logger) error
 // checkNoDeadNode returns an error if at least one of the nodes that have a populated
 // data dir are found to be not running. It prints both to t.L() and the test
 // output.
-func (c *clusterImpl) assertNoDeadNode(ctx context.Context, t test.Test) error {
+func (c *clusterImpl) assertNoDeadNode(ctx context.Context, t test.Test) (retErr error) {
 	if c.spec.NodeCount == 0 {
 		// No nodes can happen during unit tests and implies nothing to do.
 		return nil
 	}
 
 	t.L().Printf("checking for dead nodes")
+	start := timeutil.Now()
+	defer func() {
+		c.auditOp("monitor", "one-shot", c.All(), start, retErr)
+	}()
 	eventsCh, err := roachprod.Monitor(ctx, t.L(), c.name, install.MonitorOpts{OneShot: true, IgnoreEmptyNodes: true})
 
 	// An error here means there was a problem initialising a SyncedCluster.
@@ -1854,15 +2114,22 @@ func (c *clusterImpl) doDestroy(ctx context.Context, l *logger.Logger) <-chan st
 			// We use a non-cancelable context for running this command. Once we got
 			// here, the cluster cannot be destroyed again, so we really want this
 			// command to succeed.
-			if err := roachprod.Destroy(l, false /* destroyAllMine */, false /* destroyAllLocal */, c.name); err != nil {
+			destroyStart := timeutil.Now()
+			err := roachprod.Destroy(l, false /* destroyAllMine */, false /* destroyAllLocal */, c.name)
+			c.r.metrics.recordClusterDestruction(timeutil.Since(destroyStart), err)
+			if err != nil {
 				l.ErrorfCtx(ctx, "error destroying cluster %s: %s", c, err)
+				c.destroyState.destroyFailed = true
 			} else {
 				l.PrintfCtx(ctx, "destroying cluster %s... done", c)
 			}
 		} else {
 			l.PrintfCtx(ctx, "wiping cluster %s", c)
 			c.status("wiping cluster")
-			if err := roachprod.Wipe(ctx, l, c.name, false /* preserveCerts */); err != nil {
+			wipeStart := timeutil.Now()
+			err := roachprod.Wipe(ctx, l, c.name, false /* preserveCerts */)
+			c.r.metrics.recordClusterWipe(timeutil.Since(wipeStart), err)
+			if err != nil {
 				l.Errorf("%s", err)
 			}
 			if c.localCertsDir != "" {
@@ -1891,6 +2158,39 @@ func (c *clusterImpl) removeLabels(labels []string) error {
 	return roachprod.RemoveLabels(c.l, c.name, labels)
 }
 
+// revertLabels undoes the addition of the given labels, by restoring the
+// values the cluster was created with, if any, and removing the others.
+func (c *clusterImpl) revertLabels(added map[string]string) error {
+	restore, remove := labelsToRevert(c.labels, added)
+	if len(restore) > 0 {
+		if err := c.addLabels(restore); err != nil {
+			return err
+		}
+	}
+	if len(remove) > 0 {
+		return c.removeLabels(remove)
+	}
+	return nil
+}
+
+// labelsToRevert returns the labels to restore to their creation values, and
+// the ones to remove, to undo the addition of the given labels to a cluster
+// created with the given labels.
+func labelsToRevert(
+	creation, added map[string]string,
+) (restore map[string]string, remove []string) {
+	restore = map[string]string{}
+	for k := range added {
+		if v, ok := creation[k]; ok {
+			restore[k] = v
+		} else {
+			remove = append(remove, k)
+		}
+	}
+	sort.Strings(remove)
+	return restore, remove
+}
+
 func (c *clusterImpl) ListSnapshots(
 	ctx context.Context, vslo vm.VolumeSnapshotListOpts,
 ) ([]vm.VolumeSnapshot, error) {
@@ -1921,7 +2221,10 @@ func (c *clusterImpl) ApplySnapshots(ctx context.Context, snapshots []vm.VolumeS
 			vm.TagUsage: "roachtest",
 		},
 	}
-	return roachprod.ApplySnapshots(ctx, c.l, c.name, snapshots, opts)
+	start := timeutil.Now()
+	err := roachprod.ApplySnapshots(ctx, c.l, c.name, snapshots, opts)
+	c.auditOp("apply-snapshots", fmt.Sprintf("%d snapshots", len(snapshots)), c.All(), start, err)
+	return err
 }
 
 // Put a local file to all of the machines in a cluster.
@@ -1942,14 +2245,35 @@ func (c *clusterImpl) PutE(
 
 	c.status("uploading file")
 	defer c.status("")
-	return errors.Wrap(roachprod.Put(ctx, l, c.MakeNodes(nodes...), src, dest, true /* useTreeDist */), "cluster.PutE")
+	start := timeutil.Now()
+	var err error
+	if c.spec.ARM64Nodes > 0 {
+		err = c.putPerArch(ctx, l, src, dest, nodes...)
+	} else {
+		err = roachprod.Put(ctx, l, c.MakeNodes(nodes...), src, dest, true /* useTreeDist */)
+	}
+	c.auditOp("put", src+" "+dest, c.auditNodes(nodes...), start, err)
+	return errors.Wrap(err, "cluster.PutE")
 }
 
 // PutCockroach checks if a test specifies a cockroach binary to upload to all
 // nodes in the cluster. By default, we randomly upload a binary with or without
 // runtime assertions enabled. Note that we upload to all nodes even if they
-// don't use the binary, so that the test runner can always fetch logs.
+// don't use the binary, so that the test runner can always fetch logs. The
+// nodes with a binary override (see --node-binary-override) get their own
+// binary instead.
 func (c *clusterImpl) PutCockroach(ctx context.Context, l *logger.Logger, t *testImpl) error {
+	if err := c.putTestCockroach(ctx, l, t); err != nil {
+		return err
+	}
+	return roachtestutil.PutNodeBinaryOverrides(
+		ctx, l, c, t.NodeBinaryOverride(), test.DefaultCockroachPath, c.All(),
+	)
+}
+
+// putTestCockroach uploads the cockroach binary specified by the test to all
+// nodes in the cluster.
+func (c *clusterImpl) putTestCockroach(ctx context.Context, l *logger.Logger, t *testImpl) error {
 	switch t.spec.CockroachBinary {
 	case registry.RandomizedCockroach:
 		if tests.UsingRuntimeAssertions(t) {
@@ -1984,23 +2308,26 @@ func (c *clusterImpl) PutLibraries(
 		return err
 	}
 
-	for _, libraryFilePath := range libraryFilePaths[c.arch] {
-		libName := libraryNameFromPath(libraryFilePath)
-		if !contains(libraries, nil, libName) {
-			continue
-		}
-		// Get the last extension (e.g., .so) to create a destination file.
-		// N.B. The optional arch-specific extension is elided since the destination doesn't need it, nor does it know
-		// how to resolve it. (E.g., see findLibraryDirectories in geos.go)
-		ext := filepath.Ext(filepath.Base(libraryFilePath))
-		putPath := filepath.Join(libraryDir, libName+ext)
-		if err := c.PutE(
-			ctx,
-			c.l,
-			libraryFilePath,
-			putPath,
-		); err != nil {
-			return errors.Wrap(err, "cluster.PutLibraries")
+	for arch, nodes := range c.nodesByArch() {
+		for _, libraryFilePath := range libraryFilePaths[arch] {
+			libName := libraryNameFromPath(libraryFilePath)
+			if !contains(libraries, nil, libName) {
+				continue
+			}
+			// Get the last extension (e.g., .so) to create a destination file.
+			// N.B. The optional arch-specific extension is elided since the destination doesn't need it, nor does it know
+			// how to resolve it. (E.g., see findLibraryDirectories in geos.go)
+			ext := filepath.Ext(filepath.Base(libraryFilePath))
+			putPath := filepath.Join(libraryDir, libName+ext)
+			if err := c.PutE(
+				ctx,
+				c.l,
+				libraryFilePath,
+				putPath,
+				nodes,
+			); err != nil {
+				return errors.Wrap(err, "cluster.PutLibraries")
+			}
 		}
 	}
 	return nil
@@ -2018,8 +2345,26 @@ func (c *clusterImpl) Stage(
 	}
 	c.status("staging binary")
 	defer c.status("")
-	return errors.Wrap(roachprod.Stage(ctx, l, c.MakeNodes(opts...),
-		c.os, string(c.arch), dir, application, versionOrSHA), "cluster.Stage")
+	cmd := strings.Join([]string{application, versionOrSHA, dir}, " ")
+	if c.spec.ARM64Nodes > 0 {
+		// Each node of a mixed-architecture cluster needs the binary for its own
+		// architecture.
+		for arch, nodes := range c.nodesByArch(opts...) {
+			start := timeutil.Now()
+			err := roachprod.Stage(ctx, l, c.MakeNodes(nodes),
+				c.os, string(arch), dir, application, versionOrSHA)
+			c.auditOp("stage", cmd, nodes, start, err)
+			if err != nil {
+				return errors.Wrap(err, "cluster.Stage")
+			}
+		}
+		return nil
+	}
+	start := timeutil.Now()
+	err := roachprod.Stage(ctx, l, c.MakeNodes(opts...),
+		c.os, string(c.arch), dir, application, versionOrSHA)
+	c.auditOp("stage", cmd, c.auditNodes(opts...), start, err)
+	return errors.Wrap(err, "cluster.Stage")
 }
 
 // Get gets files from remote hosts.
@@ -2031,7 +2376,10 @@ func (c *clusterImpl) Get(
 	}
 	c.status(fmt.Sprintf("getting %v", src))
 	defer c.status("")
-	return errors.Wrap(roachprod.Get(ctx, l, c.MakeNodes(opts...), src, dest), "cluster.Get")
+	start := timeutil.Now()
+	err := roachprod.Get(ctx, l, c.MakeNodes(opts...), src, dest)
+	c.auditOp("get", src+" "+dest, c.auditNodes(opts...), start, err)
+	return errors.Wrap(err, "cluster.Get")
 }
 
 // PutString into the specified file on the remote(s).
@@ -2142,6 +2490,9 @@ func (c *clusterImpl) configureClusterSettingOptions(
 		install.BinaryOption(settings.Binary),
 		defaultClusterSettings,
 		install.ClusterSettingsOption(settings.ClusterSettings),
+		// The settings passed with --cluster-settings are applied last so that
+		// they take precedence over the ones set by the test.
+		install.ClusterSettingsOption(roachtestflags.ClusterSettings),
 	}
 }
 
@@ -2162,6 +2513,11 @@ func (c *clusterImpl) StartE(
 	defer c.clearStatusForClusterOpt(startOpts.RoachtestOpts.Worker)
 
 	startOpts.RoachprodOpts.EncryptedStores = c.encAtRest
+	// Start with one store per disk if the spec asks for multiple stores, unless
+	// the test asked for a specific number of stores.
+	if c.spec.StoresPerNode > 1 && startOpts.RoachprodOpts.StoreCount == 1 {
+		startOpts.RoachprodOpts.StoreCount = c.spec.StoresPerNode
+	}
 
 	// Needed for backward-compat on crdb_internal.ranges{_no_leases}.
 	// Remove in v23.2.
@@ -2180,7 +2536,10 @@ func (c *clusterImpl) StartE(
 
 	clusterSettingsOpts := c.configureClusterSettingOptions(c.clusterSettings, settings)
 
-	if err := roachprod.Start(ctx, l, c.MakeNodes(opts...), startOpts.RoachprodOpts, clusterSettingsOpts...); err != nil {
+	start := timeutil.Now()
+	err := roachprod.Start(ctx, l, c.MakeNodes(opts...), startOpts.RoachprodOpts, clusterSettingsOpts...)
+	c.auditOp("start", startOpts.RoachprodOpts.Target.String(), c.auditNodes(opts...), start, err)
+	if err != nil {
 		return err
 	}
 
@@ -2220,9 +2579,16 @@ func (c *clusterImpl) StartServiceForVirtualClusterE(
 	if len(startOpts.SeparateProcessNodes) > 0 {
 		startOpts.RoachprodOpts.VirtualClusterLocation = c.MakeNodes(startOpts.SeparateProcessNodes)
 	}
-	if err := roachprod.StartServiceForVirtualCluster(
+	start := timeutil.Now()
+	err := roachprod.StartServiceForVirtualCluster(
 		ctx, l, c.MakeNodes(storageCluster), startOpts.RoachprodOpts, clusterSettingsOpts...,
-	); err != nil {
+	)
+	auditNodes := storageCluster
+	if len(startOpts.SeparateProcessNodes) > 0 {
+		auditNodes = startOpts.SeparateProcessNodes
+	}
+	c.auditOp("start-sql", startOpts.RoachprodOpts.VirtualClusterName, auditNodes, start, err)
+	if err != nil {
 		return err
 	}
 
@@ -2259,9 +2625,12 @@ func (c *clusterImpl) StopServiceForVirtualClusterE(
 		nodes = stopOpts.SeparateProcessNodes
 	}
 
-	return roachprod.StopServiceForVirtualCluster(
+	start := timeutil.Now()
+	err := roachprod.StopServiceForVirtualCluster(
 		ctx, l, c.MakeNodes(nodes), c.IsSecure(), stopOpts.RoachprodOpts,
 	)
+	c.auditOp("stop-sql", stopOpts.RoachprodOpts.VirtualClusterName, nodes, start, err)
+	return err
 }
 
 func (c *clusterImpl) StopServiceForVirtualCluster(
@@ -2377,9 +2746,14 @@ func (c *clusterImpl) StopE(
 		opts.Sig = 10 // SIGUSR1
 		opts.Wait = true
 		opts.MaxWait = 10
-		_ = roachprod.Stop(ctx, l, c.MakeNodes(nodes...), opts)
+		start := timeutil.Now()
+		err := roachprod.Stop(ctx, l, c.MakeNodes(nodes...), opts)
+		c.auditOp("stop", fmt.Sprintf("signal %d", opts.Sig), c.auditNodes(nodes...), start, err)
 	}
-	return errors.Wrap(roachprod.Stop(ctx, l, c.MakeNodes(nodes...), stopOpts.RoachprodOpts), "cluster.StopE")
+	start := timeutil.Now()
+	err := roachprod.Stop(ctx, l, c.MakeNodes(nodes...), stopOpts.RoachprodOpts)
+	c.auditOp("stop", fmt.Sprintf("signal %d", stopOpts.RoachprodOpts.Sig), c.auditNodes(nodes...), start, err)
+	return errors.Wrap(err, "cluster.StopE")
 }
 
 // Stop is like StopE, except instead of returning an error, it does
@@ -2406,7 +2780,10 @@ func (c *clusterImpl) SignalE(
 	if c.spec.NodeCount == 0 {
 		return nil // unit tests
 	}
-	return errors.Wrap(roachprod.Signal(ctx, l, c.MakeNodes(nodes...), sig), "cluster.Signal")
+	start := timeutil.Now()
+	err := roachprod.Signal(ctx, l, c.MakeNodes(nodes...), sig)
+	c.auditOp("signal", fmt.Sprintf("signal %d", sig), c.auditNodes(nodes...), start, err)
+	return errors.Wrap(err, "cluster.Signal")
 }
 
 // Signal is like SignalE, except instead of returning an error, it does
@@ -2437,7 +2814,10 @@ func (c *clusterImpl) WipeE(
 	}
 	c.setStatusForClusterOpt("wiping", false, nodes...)
 	defer c.clearStatusForClusterOpt(false)
-	return roachprod.Wipe(ctx, l, c.MakeNodes(nodes...), c.IsSecure())
+	start := timeutil.Now()
+	err := roachprod.Wipe(ctx, l, c.MakeNodes(nodes...), c.IsSecure())
+	c.auditOp("wipe", "", c.auditNodes(nodes...), start, err)
+	return err
 }
 
 // Wipe is like WipeE, except instead of returning an error, it does
@@ -2480,10 +2860,28 @@ func (c *clusterImpl) RunE(ctx context.Context, options install.RunOptions, args
 		c.f.L().Printf("details in %s.log", logFile)
 	}
 	l.Printf("> %s", cmd)
-	if err := roachprod.Run(
+	start := timeutil.Now()
+	var results []install.RunResultDetails
+	options.OnResults = func(completed []*install.RunResultDetails) {
+		for _, res := range completed {
+			results = append(results, *res)
+		}
+	}
+	err = roachprod.Run(
 		ctx, l, c.MakeNodes(nodes), "", "", c.IsSecure(),
 		l.Stdout, l.Stderr, args, options,
-	); err != nil {
+	)
+	// Audit the command before annotating the workload phase, which may take
+	// a while, so that the recorded duration is the command's. The result of
+	// each node is recorded, unless roachprod failed before running the
+	// command anywhere.
+	if len(results) > 0 {
+		c.auditRunResults(cmd, start, results)
+	} else {
+		c.audit(makeCommandAuditEntry("run", cmd, c.auditNodes(nodes), start, err).withExitStatus(0, err))
+	}
+	c.maybeAnnotateWorkloadPhase(ctx, l, cmd, nodes, start, err)
+	if err != nil {
 		if err := ctx.Err(); err != nil {
 			l.Printf("(note: incoming context was canceled: %s)", err)
 			return err
@@ -2547,10 +2945,16 @@ func (c *clusterImpl) RunWithDetails(
 	}
 
 	l.Printf("> %s", cmd)
+	start := timeutil.Now()
 	results, err := roachprod.RunWithDetails(
 		ctx, l, c.MakeNodes(nodes), "" /* SSHOptions */, "", /* processTag */
 		c.IsSecure(), args, options,
 	)
+	if err != nil {
+		c.auditOp("run", cmd, c.auditNodes(nodes), start, err)
+	} else {
+		c.auditRunResults(cmd, start, results)
+	}
 
 	var logFileFull string
 	if l.File != nil {
@@ -2595,7 +2999,10 @@ func createFailedFile(logFile string) {
 func (c *clusterImpl) Reformat(
 	ctx context.Context, l *logger.Logger, node option.NodeListOption, filesystem string,
 ) error {
-	return roachprod.Reformat(ctx, l, c.name, filesystem)
+	start := timeutil.Now()
+	err := roachprod.Reformat(ctx, l, c.name, filesystem)
+	c.auditOp("reformat", filesystem, c.All(), start, err)
+	return err
 }
 
 // Silence unused warning.
@@ -2611,7 +3018,10 @@ func (c *clusterImpl) Install(
 	if len(software) == 0 {
 		return errors.New("Error running cluster.Install: no software passed")
 	}
-	return errors.Wrap(roachprod.Install(ctx, l, c.MakeNodes(nodes), software), "cluster.Install")
+	start := timeutil.Now()
+	err := roachprod.Install(ctx, l, c.MakeNodes(nodes), software)
+	c.auditOp("install", strings.Join(software, " "), c.auditNodes(nodes), start, err)
+	return errors.Wrap(err, "cluster.Install")
 }
 
 // cmdLogFileName comes up with a log file to use for the given argument string.
@@ -2690,7 +3100,7 @@ func addrToAdminUIAddr(addr string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	return fmt.Sprintf("%s:%d", host, webPort), nil
+	return net.JoinHostPort(host, strconv.Itoa(webPort)), nil
 }
 
 func urlToAddr(pgURL string) (string, error) {
@@ -2861,6 +3271,38 @@ func (c *clusterImpl) ConnE(
 	// NB: errors.Wrap returns nil if err is nil.
 	defer func() { retErr = errors.Wrapf(retErr, "connecting to node %d", node) }()
 
+	dataSourceName, err := c.pgDataSourceName(ctx, l, node, opts...)
+	if err != nil {
+		return nil, err
+	}
+	db, err := gosql.Open("postgres", dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+
+	// When running roachtest locally, we set a max connection lifetime
+	// to avoid errors like the following:
+	//
+	// `read tcp 127.0.0.1:63742 -> 127.0.0.1:26257: read: connection reset by peer`
+	//
+	// The pq issue below seems related. This was only observed in local
+	// runs so the lifetime is only applied in that context intentionally;
+	// for cloud runs, we use the connection pool's default behaviour.
+	//
+	// https://github.com/lib/pq/issues/835
+	if c.Cloud() == spec.Local {
+		localConnLifetime := 10 * time.Second
+		db.SetConnMaxLifetime(localConnLifetime)
+	}
+
+	return db, nil
+}
+
+// pgDataSourceName returns the data source name used to connect to the
+// specified node.
+func (c *clusterImpl) pgDataSourceName(
+	ctx context.Context, l *logger.Logger, node int, opts ...func(*option.ConnOption),
+) (string, error) {
 	connOptions := &option.ConnOption{}
 	for _, opt := range opts {
 		opt(connOptions)
@@ -2871,12 +3313,12 @@ func (c *clusterImpl) ConnE(
 		Auth:               connOptions.AuthMode,
 	})
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
 	u, err := url.Parse(urls[0])
 	if err != nil {
-		return nil, err
+		return "", err
 	}
 
 	if connOptions.User != "" {
@@ -2898,27 +3340,7 @@ func (c *clusterImpl) ConnE(
 		vals.Add("connect_timeout", "60")
 		dataSourceName = dataSourceName + "&" + vals.Encode()
 	}
-	db, err := gosql.Open("postgres", dataSourceName)
-	if err != nil {
-		return nil, err
-	}
-
-	// When running roachtest locally, we set a max connection lifetime
-	// to avoid errors like the following:
-	//
-	// `read tcp 127.0.0.1:63742 -> 127.0.0.1:26257: read: connection reset by peer`
-	//
-	// The pq issue below seems related. This was only observed in local
-	// runs so the lifetime is only applied in that context intentionally;
-	// for cloud runs, we use the connection pool's default behaviour.
-	//
-	// https://github.com/lib/pq/issues/835
-	if c.Cloud() == spec.Local {
-		localConnLifetime := 10 * time.Second
-		db.SetConnMaxLifetime(localConnLifetime)
-	}
-
-	return db, nil
+	return dataSourceName, nil
 }
 
 func (c *clusterImpl) MakeNodes(opts ...option.Option) string {
@@ -2947,6 +3369,10 @@ func (c *clusterImpl) Architecture() vm.CPUArch {
 	return c.arch
 }
 
+func (c *clusterImpl) NodeArchitecture(node int) vm.CPUArch {
+	return c.spec.NodeArch(node, c.arch)
+}
+
 // Extend extends the cluster's expiration by d.
 func (c *clusterImpl) Extend(ctx context.Context, d time.Duration, l *logger.Logger) error {
 	if ctx.Err() != nil {
@@ -2969,23 +3395,39 @@ func (c *clusterImpl) Extend(ctx context.Context, d time.Duration, l *logger.Log
 // monitor's semantics around handling expected node deaths breaks down if it's
 // monitoring a workload node.
 func (c *clusterImpl) NewMonitor(ctx context.Context, opts ...option.Option) cluster.Monitor {
-	return newMonitor(ctx, c.t, c, opts...)
+	m := newMonitor(ctx, c.t, c, opts...)
+	nodes := c.auditNodes(opts...)
+	m.audit = func(start time.Time, err error) {
+		c.auditOp("monitor", "", nodes, start, err)
+	}
+	return m
 }
 
 func (c *clusterImpl) StartGrafana(
 	ctx context.Context, l *logger.Logger, promCfg *prometheus.Config,
 ) error {
-	return roachprod.StartGrafana(ctx, l, c.name, c.arch, "", nil, promCfg)
+	if err := roachprod.StartGrafana(ctx, l, c.name, c.arch, "", nil, promCfg); err != nil {
+		return err
+	}
+	c.internalGrafanaStarted.Store(true)
+	return nil
 }
 
 func (c *clusterImpl) StopGrafana(ctx context.Context, l *logger.Logger, dumpDir string) error {
+	c.internalGrafanaStarted.Store(false)
 	return roachprod.StopGrafana(ctx, l, c.name, dumpDir)
 }
 
-// AddGrafanaAnnotation creates a grafana annotation for the centralized grafana instance.
+// AddGrafanaAnnotation creates a grafana annotation for the centralized grafana
+// instance, and for the internal one if the test started it.
 func (c *clusterImpl) AddGrafanaAnnotation(
 	ctx context.Context, l *logger.Logger, req grafana.AddAnnotationRequest,
 ) error {
+	if c.internalGrafanaStarted.Load() {
+		if err := c.AddInternalGrafanaAnnotation(ctx, l, req); err != nil {
+			l.Printf("error adding internal grafana annotation: %v", err)
+		}
+	}
 	if c.disableGrafanaAnnotations.Load() {
 		return nil
 	}
@@ -3030,16 +3472,28 @@ func (c *clusterImpl) WipeForReuse(
 		return errors.New("cluster reuse is disabled for local clusters to guarantee a clean slate for each test")
 	}
 	l.PrintfCtx(ctx, "Using existing cluster: %s (arch=%q). Wiping", c.name, c.arch)
-	if err := roachprod.Wipe(ctx, l, c.MakeNodes(c.All()), false /* preserveCerts */); err != nil {
+	// The DNS records of the cluster are cleared while its nodes are wiped.
+	g := ctxgroup.WithContext(ctx)
+	g.GoCtx(func(ctx context.Context) error {
+		if err := roachprod.Wipe(ctx, l, c.MakeNodes(c.All()), false /* preserveCerts */); err != nil {
+			return err
+		}
+		// We remove the entire shared user directory between tests to ensure we
+		// aren't reusing files from previous tests, i.e. cockroach binaries, perf
+		// artifacts. N.B. we don't remove the entire home directory to safeguard
+		// against this ever running locally and deleting someone's local
+		// directory.
+		if err := c.RunE(ctx, option.WithNodes(c.All()), fmt.Sprintf("rm -rf /home/%s/*", config.SharedUser)); err != nil {
+			return errors.Wrapf(err, "failed to remove home directory")
+		}
+		return nil
+	})
+	g.GoCtx(func(ctx context.Context) error {
+		return c.DestroyDNS(ctx, l)
+	})
+	if err := g.Wait(); err != nil {
 		return err
 	}
-	// We remove the entire shared user directory between tests to ensure we aren't
-	// reusing files from previous tests, i.e. cockroach binaries, perf artifacts.
-	// N.B. we don't remove the entire home directory to safeguard against this ever
-	// running locally and deleting someone's local directory.
-	if err := c.RunE(ctx, option.WithNodes(c.All()), fmt.Sprintf("rm -rf /home/%s/*", config.SharedUser)); err != nil {
-		return errors.Wrapf(err, "failed to remove home directory")
-	}
 	if c.localCertsDir != "" {
 		if err := os.RemoveAll(c.localCertsDir); err != nil {
 			return errors.Wrapf(err,
@@ -3052,11 +3506,8 @@ func (c *clusterImpl) WipeForReuse(
 	// about Grafana not being available is always printed at least once
 	// for every test.
 	c.disableGrafanaAnnotations.Store(false)
+	c.internalGrafanaStarted.Store(false)
 
-	// Clear DNS records for the cluster.
-	if err := c.DestroyDNS(ctx, l); err != nil {
-		return err
-	}
 	// Overwrite the spec of the cluster with the one coming from the test. In
 	// particular, this overwrites the reuse policy to reflect what the test
 	// intends to do with it.
@@ -3075,8 +3526,17 @@ func (c *clusterImpl) DestroyDNS(ctx context.Context, l *logger.Logger) error {
 func (c *clusterImpl) MaybeExtendCluster(
 	ctx context.Context, l *logger.Logger, testSpec *registry.TestSpec,
 ) error {
-	timeout := testTimeout(testSpec)
-	minExp := timeutil.Now().Add(timeout + time.Hour)
+	timeout := scaledTestTimeout(testSpec, c.Cloud(), c.arch)
+	return c.maybeExtendClusterUntil(ctx, l, timeutil.Now().Add(timeout))
+}
+
+// maybeExtendClusterUntil extends the cluster, if needed, so that it survives
+// until the given deadline of the test plus enough headroom after the test
+// finishes so that the next test can be selected.
+func (c *clusterImpl) maybeExtendClusterUntil(
+	ctx context.Context, l *logger.Logger, deadline time.Time,
+) error {
+	minExp := deadline.Add(time.Hour)
 	if c.expiration.Before(minExp) {
 		extend := minExp.Sub(c.expiration)
 		l.PrintfCtx(ctx, "cluster needs to survive until %s, but has expiration: %s. Extending.",
@@ -3120,32 +3580,34 @@ func (c *clusterImpl) UpdateSideEyeEnvironmentName(
 }
 
 // CaptureSideEyeSnapshot asks the Side-Eye service to take a snapshot of the
-// cockroach processes running on this cluster. All errors are logged and
-// swallowed.
+// cockroach processes running on this cluster, and returns its URL. All errors
+// are logged and swallowed; an empty URL is returned if no snapshot was taken.
 func (c *clusterImpl) CaptureSideEyeSnapshot(
 	ctx context.Context, l *logger.Logger, client *sideeyeclient.SideEyeClient,
-) {
+) string {
 	l.PrintfCtx(ctx, "capturing snapshot of the cluster with Side-Eye...")
 
-	if c.arch == vm.ArchARM64 {
+	if c.arch == vm.ArchARM64 || c.spec.ARM64Nodes > 0 {
 		l.Printf("Side-Eye does not support ARM64 machines; skipping snapshot")
-		return
+		return ""
 	}
 
 	envName := c.sideEyeEnvName()
 	if envName == "" {
 		l.PrintfCtx(ctx, "cluster does not have Side-Eye agents set up; skipping snapshot")
-		return
+		return ""
 	}
 
 	snapURL, ok := roachprod.CaptureSideEyeSnapshot(ctx, l, envName, client)
-	if ok {
-		l.PrintfCtx(ctx, "captured Side-eye Snapshot: %s", snapURL)
-		annotation := fmt.Sprintf("Captured Side-Eye snaphost: %s", snapURL)
-		if err := c.AddGrafanaAnnotation(ctx, l, grafana.AddAnnotationRequest{Text: annotation}); err != nil {
-			l.PrintfCtx(ctx, "error adding Grafana annotation for snapshot: %s", err)
-		}
+	if !ok {
+		return ""
+	}
+	l.PrintfCtx(ctx, "captured Side-eye Snapshot: %s", snapURL)
+	annotation := fmt.Sprintf("Captured Side-Eye snaphost: %s", snapURL)
+	if err := c.AddGrafanaAnnotation(ctx, l, grafana.AddAnnotationRequest{Text: annotation}); err != nil {
+		l.PrintfCtx(ctx, "error adding Grafana annotation for snapshot: %s", err)
 	}
+	return snapURL
 }
 
 // archForTest determines the CPU architecture to use for a test. If the test
@@ -39,6 +39,7 @@ type Cluster interface {
 	Nodes(ns ...int) option.NodeListOption
 	Node(i int) option.NodeListOption
 	WorkloadNode() option.NodeListOption
+	WorkloadNodesWithRole(role spec.WorkloadRole) option.NodeListOption
 
 	// Uploading and downloading from/to nodes.
 
@@ -143,6 +144,10 @@ type Cluster interface {
 	IsSecure() bool
 	// Architecture returns CPU architecture of the nodes.
 	Architecture() vm.CPUArch
+	// NodeArchitecture returns the CPU architecture of the given node; it
+	// only differs from Architecture on mixed-architecture clusters (see
+	// spec.MixedArch).
+	NodeArchitecture(node int) vm.CPUArch
 
 	// Deleting CockroachDB data and logs on nodes.
 
@@ -0,0 +1,97 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"context"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/spec"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/logger"
+	"github.com/cockroachdb/cockroach/pkg/util/quotapool"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+)
+
+// clusterPool holds the clusters of failed tests which have been wiped and can
+// be handed to other workers, instead of being destroyed. See --cluster-pool.
+//
+// Workers that need a new cluster take a pooled cluster compatible with some
+// of the remaining tests before acquiring quota for a new one; a pooled
+// cluster holds on to the quota allocation of the test that used it. When no
+// remaining test is compatible with a pooled cluster, it is destroyed, so that
+// idle pooled clusters never prevent workers from acquiring quota.
+type clusterPool struct {
+	mu struct {
+		syncutil.Mutex
+		clusters []pooledCluster
+	}
+}
+
+type pooledCluster struct {
+	c     *clusterImpl
+	alloc *quotapool.IntAlloc
+}
+
+// put wipes the given cluster and adds it to the pool, along with its quota
+// allocation. Returns false if the cluster is tainted or can't be wiped, in
+// which case the caller remains responsible for the cluster and allocation.
+func (p *clusterPool) put(
+	ctx context.Context, l *logger.Logger, c *clusterImpl, alloc *quotapool.IntAlloc,
+) bool {
+	if c.tainted == "" {
+		// Safety wipe: make sure that nothing left behind by the failed test is
+		// running on the cluster by the time another test picks it up.
+		if err := c.WipeForReuse(ctx, l, c.spec); err != nil {
+			c.taint(err.Error())
+		}
+	}
+	if c.tainted != "" {
+		l.PrintfCtx(ctx, "Not pooling cluster %s: %s", c.Name(), c.tainted)
+		return false
+	}
+	l.PrintfCtx(ctx, "Pooling cluster %s for reuse by other tests", c.Name())
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.mu.clusters = append(p.mu.clusters, pooledCluster{c: c, alloc: alloc})
+	return true
+}
+
+// take removes from the pool a cluster compatible with some of the tests
+// remaining in the work pool, if any. The pooled clusters which are not
+// compatible with any remaining test are returned to be destroyed.
+func (p *clusterPool) take(
+	work *workPool, cloud spec.Cloud,
+) (taken *pooledCluster, unusable []pooledCluster) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	clusters := p.mu.clusters
+	p.mu.clusters = nil
+	for i := range clusters {
+		pc := clusters[i]
+		switch {
+		case taken != nil:
+			p.mu.clusters = append(p.mu.clusters, pc)
+		case work.hasCompatibleTest(pc.c.spec, cloud):
+			taken = &pc
+		default:
+			unusable = append(unusable, pc)
+		}
+	}
+	return taken, unusable
+}
+
+// drain removes all the clusters from the pool.
+func (p *clusterPool) drain() []pooledCluster {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	clusters := p.mu.clusters
+	p.mu.clusters = nil
+	return clusters
+}
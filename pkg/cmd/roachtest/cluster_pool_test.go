@@ -0,0 +1,58 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/spec"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClusterPool(t *testing.T) {
+	ctx := context.Background()
+	small, large := spec.MakeClusterSpec(3), spec.MakeClusterSpec(5)
+	work := newWorkPool([]registry.TestSpec{{Name: "small", Cluster: small}}, 1 /* count */)
+
+	var p clusterPool
+	// Local clusters can't be wiped for reuse, so they are never pooled.
+	require.False(t, p.put(ctx, nilLogger(), &clusterImpl{name: "local", spec: small}, nil))
+	// Tainted clusters are never pooled.
+	tainted := &clusterImpl{name: "tainted", spec: small}
+	tainted.taint("test timed out")
+	tainted.taint("infrastructure flake")
+	require.Equal(t, "test timed out", tainted.tainted)
+	require.False(t, p.put(ctx, nilLogger(), tainted, nil))
+	require.Empty(t, p.drain())
+
+	p.mu.clusters = []pooledCluster{
+		{c: &clusterImpl{name: "large", spec: large}},
+		{c: &clusterImpl{name: "small-1", spec: small}},
+		{c: &clusterImpl{name: "small-2", spec: small}},
+	}
+	taken, unusable := p.take(work, spec.GCE)
+	require.NotNil(t, taken)
+	require.Equal(t, "small-1", taken.c.Name())
+	require.Len(t, unusable, 1)
+	require.Equal(t, "large", unusable[0].c.Name())
+
+	// Once the compatible test has been selected, the remaining pooled cluster
+	// is unusable.
+	res := work.selectTestForCluster(ctx, small, newClusterRegistry(), spec.GCE)
+	require.False(t, res.noWork)
+	taken, unusable = p.take(work, spec.GCE)
+	require.Nil(t, taken)
+	require.Len(t, unusable, 1)
+	require.Equal(t, "small-2", unusable[0].c.Name())
+	require.Empty(t, p.drain())
+}
@@ -11,6 +11,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"strings"
@@ -18,12 +19,14 @@ import (
 	"time"
 
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/option"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/roachtestflags"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/spec"
 	test2 "github.com/cockroachdb/cockroach/pkg/cmd/roachtest/test"
 	"github.com/cockroachdb/cockroach/pkg/roachprod/logger"
 	"github.com/cockroachdb/cockroach/pkg/roachprod/vm"
 	"github.com/cockroachdb/cockroach/pkg/roachprod/vm/azure"
 	"github.com/cockroachdb/cockroach/pkg/testutils/datapathutils"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
 	"github.com/cockroachdb/cockroach/pkg/util/version"
 	"github.com/cockroachdb/datadriven"
 	"github.com/cockroachdb/errors"
@@ -91,6 +94,10 @@ func (t testWrapper) DeprecatedWorkload() string {
 	return "./dummy-path/to/workload"
 }
 
+func (t testWrapper) Seed() int64 {
+	return 0
+}
+
 func (t testWrapper) IsBuildVersion(s string) bool {
 	panic("implement me")
 }
@@ -103,6 +110,10 @@ func (t testWrapper) VersionsBinaryOverride() map[string]string {
 	panic("implement me")
 }
 
+func (t testWrapper) NodeBinaryOverride() map[int]string {
+	panic("implement me")
+}
+
 func (t testWrapper) SkipInit() bool {
 	panic("implement me")
 }
@@ -139,6 +150,9 @@ func (t testWrapper) GoCoverArtifactsDir() string {
 	return ""
 }
 
+// CollectDebugZipOnFailure is part of the test.Test interface.
+func (t testWrapper) CollectDebugZipOnFailure(string, ...int) {}
+
 // logger is part of the testI interface.
 func (t testWrapper) L() *logger.Logger {
 	return t.l
@@ -728,3 +742,57 @@ func TestVerifyLibraries(t *testing.T) {
 		})
 	}
 }
+
+func TestDestroyAllClusters(t *testing.T) {
+	// Track the maximum number of clusters destroyed concurrently. Each destroy
+	// waits for a bit, so that concurrent destroys overlap.
+	var mu syncutil.Mutex
+	var inFlight, maxInFlight int
+	defer func(f func(*clusterImpl, context.Context, closeLoggerOpt, *logger.Logger)) {
+		destroyCluster = f
+	}(destroyCluster)
+	destroyCluster = func(c *clusterImpl, ctx context.Context, lo closeLoggerOpt, l *logger.Logger) {
+		mu.Lock()
+		inFlight++
+		maxInFlight = max(maxInFlight, inFlight)
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		c.Destroy(ctx, lo, l)
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	}
+	defer func(n int) { roachtestflags.TeardownConcurrency = n }(roachtestflags.TeardownConcurrency)
+	roachtestflags.TeardownConcurrency = 3
+
+	cr := newClusterRegistry()
+	var saved *clusterImpl
+	for i := 0; i < 5; i++ {
+		// Clusters without nodes are only unregistered when destroyed.
+		c := &clusterImpl{name: fmt.Sprintf("c%d", i), r: cr}
+		c.destroyState.owned = true
+		cr.mu.clusters[c.name] = c
+		saved = c
+	}
+	cr.mu.savedClusters[saved] = "saved for debugging"
+
+	cr.destroyAllClusters(context.Background(), nilLogger())
+	require.Len(t, cr.mu.clusters, 1)
+	require.Contains(t, cr.mu.clusters, saved.name)
+	// All the clusters are destroyed at most --teardown-concurrency at a time.
+	require.Equal(t, 3, maxInFlight)
+
+	// The clusters of the pool are destroyed at most --teardown-concurrency at
+	// a time.
+	var pooled []*clusterImpl
+	for i := 0; i < 5; i++ {
+		c := &clusterImpl{name: fmt.Sprintf("p%d", i), r: cr}
+		c.destroyState.owned = true
+		cr.mu.clusters[c.name] = c
+		pooled = append(pooled, c)
+	}
+	maxInFlight = 0
+	destroyClusters(context.Background(), nilLogger(), pooled, dontCloseLogger, 2 /* concurrency */)
+	require.Len(t, cr.mu.clusters, 1)
+	require.Equal(t, 2, maxInFlight)
+}
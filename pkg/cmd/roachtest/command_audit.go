@@ -0,0 +1,175 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/option"
+	rperrors "github.com/cockroachdb/cockroach/pkg/roachprod/errors"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/install"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+)
+
+// commandAuditFile is the name of the file in the artifacts dir of a test
+// holding its command audit log.
+const commandAuditFile = "commands.jsonl"
+
+// commandAuditEntry is a roachprod operation run on the cluster of a test.
+type commandAuditEntry struct {
+	Start time.Time `json:"start"`
+	// Op is the roachprod operation, e.g. run, put or start.
+	Op string `json:"op"`
+	// Command is the command run, for run operations, or a description of the
+	// arguments of the operation otherwise.
+	Command         string  `json:"command,omitempty"`
+	Nodes           []int   `json:"nodes"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	// ExitStatus is the exit status of the command, for run operations, if
+	// known.
+	ExitStatus *int   `json:"exit_status,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+func makeCommandAuditEntry(
+	op, cmd string, nodes option.NodeListOption, start time.Time, err error,
+) commandAuditEntry {
+	e := commandAuditEntry{
+		Start:           start,
+		Op:              op,
+		Command:         cmd,
+		Nodes:           nodes,
+		DurationSeconds: timeutil.Since(start).Seconds(),
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	return e
+}
+
+// withExitStatus sets the exit status of a run operation. If the operation
+// failed with the given error, the status is taken from the error instead, if
+// it holds one.
+func (e commandAuditEntry) withExitStatus(status int, err error) commandAuditEntry {
+	if err != nil {
+		var ok bool
+		if status, ok = rperrors.GetExitCode(err); !ok {
+			return e
+		}
+	}
+	e.ExitStatus = &status
+	return e
+}
+
+// commandAuditLog records the roachprod operations run on behalf of a test,
+// e.g. the commands run on its nodes over SSH, one JSON object per line, so
+// that what the test runner did can be reconstructed when triaging failures.
+// All methods are no-ops on a nil commandAuditLog.
+type commandAuditLog struct {
+	path string
+	mu   struct {
+		syncutil.Mutex
+		// failed is set once an entry couldn't be written, after which
+		// nothing is recorded anymore.
+		failed bool
+	}
+}
+
+// newCommandAuditLog returns a log written to the given artifacts dir, or nil
+// if there is none.
+func newCommandAuditLog(artifactsDir string) *commandAuditLog {
+	if artifactsDir == "" {
+		return nil
+	}
+	return &commandAuditLog{path: filepath.Join(artifactsDir, commandAuditFile)}
+}
+
+// record appends the given entries to the log. The file is opened for each
+// record, which is cheap next to the operations recorded, so that it's always
+// complete when the artifacts are collected.
+func (a *commandAuditLog) record(entries ...commandAuditEntry) error {
+	if a == nil {
+		return nil
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.mu.failed {
+		return nil
+	}
+	err := func() error {
+		f, err := os.OpenFile(a.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(f)
+		for _, e := range entries {
+			if err := enc.Encode(e); err != nil {
+				_ = f.Close()
+				return err
+			}
+		}
+		return f.Close()
+	}()
+	a.mu.failed = err != nil
+	return err
+}
+
+// auditNodes returns the nodes selected by the given options, or all the
+// nodes if none are.
+func (c *clusterImpl) auditNodes(opts ...option.Option) option.NodeListOption {
+	var nodes option.NodeListOption
+	for _, o := range opts {
+		if s, ok := o.(nodeSelector); ok {
+			nodes = s.Merge(nodes)
+		}
+	}
+	if len(nodes) == 0 {
+		return c.All()
+	}
+	return nodes
+}
+
+// audit records the given entries in the command audit log of the test using
+// the cluster, if any.
+func (c *clusterImpl) audit(entries ...commandAuditEntry) {
+	impl, ok := c.t.(*testImpl)
+	if !ok {
+		return
+	}
+	if err := impl.commands.record(entries...); err != nil {
+		c.l.Printf("failed to write the command audit log, not recording commands anymore: %s", err)
+	}
+}
+
+// auditOp records the roachprod operation on the given nodes, started at the
+// given time, in the command audit log of the test using the cluster.
+func (c *clusterImpl) auditOp(
+	op, cmd string, nodes option.NodeListOption, start time.Time, err error,
+) {
+	c.audit(makeCommandAuditEntry(op, cmd, nodes, start, err))
+}
+
+// auditRunResults records a command run on several nodes, with the result of
+// each node, in the command audit log of the test using the cluster.
+func (c *clusterImpl) auditRunResults(
+	cmd string, start time.Time, results []install.RunResultDetails,
+) {
+	entries := make([]commandAuditEntry, len(results))
+	for i, res := range results {
+		entries[i] = makeCommandAuditEntry("run", cmd, option.NodeListOption{int(res.Node)}, start, res.Err).
+			withExitStatus(res.RemoteExitStatus, nil)
+	}
+	c.audit(entries...)
+}
@@ -0,0 +1,135 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/option"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/spec"
+	"github.com/cockroachdb/cockroach/pkg/roachprod"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/cloud"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/vm"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// readCommandAuditLog returns the entries of the command audit log in the
+// given artifacts dir.
+func readCommandAuditLog(t *testing.T, artifactsDir string) []commandAuditEntry {
+	f, err := os.Open(filepath.Join(artifactsDir, commandAuditFile))
+	require.NoError(t, err)
+	defer f.Close()
+	var entries []commandAuditEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e commandAuditEntry
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &e))
+		entries = append(entries, e)
+	}
+	require.NoError(t, scanner.Err())
+	return entries
+}
+
+func TestCommandAuditLog(t *testing.T) {
+	// A nil log records nothing.
+	require.Nil(t, newCommandAuditLog(""))
+	var nilLog *commandAuditLog
+	require.NoError(t, nilLog.record(commandAuditEntry{Op: "run"}))
+
+	dir := t.TempDir()
+	a := newCommandAuditLog(dir)
+	start := timeutil.Now().Add(-time.Second).UTC().Truncate(time.Second)
+	ok := makeCommandAuditEntry("run", "./cockroach version", option.NodeListOption{1, 2}, start, nil).
+		withExitStatus(0, nil)
+	require.Equal(t, 0, *ok.ExitStatus)
+	require.GreaterOrEqual(t, ok.DurationSeconds, 1.0)
+	failed := makeCommandAuditEntry("run", "false", option.NodeListOption{3}, start, nil).
+		withExitStatus(1, nil)
+	// The exit status is unknown if the error doesn't hold one.
+	sshErr := makeCommandAuditEntry("run", "true", option.NodeListOption{1}, start, errors.New("ssh: timeout")).
+		withExitStatus(0, errors.New("ssh: timeout"))
+	require.Nil(t, sshErr.ExitStatus)
+	put := makeCommandAuditEntry("put", "./cockroach ./cockroach", option.NodeListOption{1, 2, 3}, start, nil)
+
+	require.NoError(t, a.record(ok))
+	require.NoError(t, a.record(failed, sshErr, put))
+
+	entries := readCommandAuditLog(t, dir)
+	require.Equal(t, []commandAuditEntry{ok, failed, sshErr, put}, entries)
+	require.Equal(t, "ssh: timeout", entries[2].Error)
+}
+
+// TestClusterCommandAudit runs commands and uploads a file on a local cluster
+// through a clusterImpl, and checks what they record in the command audit log
+// of the test using it.
+func TestClusterCommandAudit(t *testing.T) {
+	// Local clusters live in the home directory.
+	t.Setenv("HOME", t.TempDir())
+	require.NoError(t, roachprod.InitDirs())
+
+	ctx := context.Background()
+	l := nilLogger()
+	const name = "local-audit"
+	require.NoError(t, roachprod.Create(ctx, l, "" /* username */, &cloud.ClusterCreateOpts{
+		Nodes:      2,
+		CreateOpts: vm.CreateOpts{ClusterName: name},
+	}))
+	defer func() { _ = roachprod.Destroy(l, false /* destroyAllMine */, false /* destroyAllLocal */, name) }()
+
+	artifactsDir := t.TempDir()
+	tt := &testImpl{
+		spec:         &registry.TestSpec{Name: "audit"},
+		l:            l,
+		artifactsDir: artifactsDir,
+		commands:     newCommandAuditLog(artifactsDir),
+	}
+	c := &clusterImpl{name: name, spec: spec.MakeClusterSpec(2), t: tt, f: tt, l: l}
+
+	require.NoError(t, c.RunE(ctx, option.WithNodes(c.All()), "true"))
+	// The command fails on n2 only. n1 isn't abandoned when it does, so that
+	// both results are recorded.
+	require.Error(t, c.RunE(ctx, option.WithNodes(c.All()).WithFailSlow(),
+		`test "$ROACHPROD" != `+name+`/2 || exit 3`))
+	src := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(src, []byte("audit"), 0644))
+	require.NoError(t, c.PutE(ctx, l, src, "file", c.Node(1)))
+
+	type result struct {
+		op         string
+		nodes      []int
+		exitStatus int
+		failed     bool
+	}
+	var results []result
+	for _, e := range readCommandAuditLog(t, artifactsDir) {
+		r := result{op: e.Op, nodes: e.Nodes, exitStatus: -1, failed: e.Error != ""}
+		if e.ExitStatus != nil {
+			r.exitStatus = *e.ExitStatus
+		}
+		results = append(results, r)
+	}
+	require.ElementsMatch(t, []result{
+		{op: "run", nodes: []int{1}},
+		{op: "run", nodes: []int{2}},
+		{op: "run", nodes: []int{1}},
+		{op: "run", nodes: []int{2}, exitStatus: 3, failed: true},
+		{op: "put", nodes: []int{1}, exitStatus: -1},
+	}, results)
+}
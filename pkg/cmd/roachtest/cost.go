@@ -0,0 +1,214 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/spec"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+)
+
+// cloudPrices are the approximate on-demand prices, in USD per hour, used to
+// estimate the cost of the clusters of a run. They are in the ballpark of the
+// list prices of the general purpose machine types and SSD volumes that
+// roachprod uses by default; they are not meant to match a cloud bill.
+type cloudPrices struct {
+	perCPUHour        float64
+	perGBMemHour      float64
+	perGBDiskHour     float64
+	perGBLocalSSDHour float64
+	// spotFactor is the fraction of the on-demand price paid for spot VMs.
+	spotFactor float64
+}
+
+var pricesByCloud = map[spec.Cloud]cloudPrices{
+	spec.GCE: {
+		perCPUHour:        0.0316,
+		perGBMemHour:      0.0042,
+		perGBDiskHour:     0.17 / 730,
+		perGBLocalSSDHour: 0.08 / 730,
+		spotFactor:        0.3,
+	},
+	spec.AWS: {
+		perCPUHour:        0.0336,
+		perGBMemHour:      0.0045,
+		perGBDiskHour:     0.08 / 730,
+		perGBLocalSSDHour: 0.08 / 730,
+		spotFactor:        0.35,
+	},
+	spec.Azure: {
+		perCPUHour:        0.0336,
+		perGBMemHour:      0.0045,
+		perGBDiskHour:     0.12 / 730,
+		perGBLocalSSDHour: 0.08 / 730,
+		spotFactor:        0.3,
+	},
+}
+
+const (
+	// defaultVolumeSizeGB is the size of the persistent volume roachprod
+	// attaches to each node when the spec doesn't specify one.
+	defaultVolumeSizeGB = 500
+	// localSSDSizeGB is the size of a local SSD.
+	localSSDSizeGB = 375
+)
+
+// memPerCPUGB returns the approximate memory, in GB per CPU, of the machine
+// types picked for the given setting.
+func memPerCPUGB(m spec.MemPerCPU) float64 {
+	switch m {
+	case spec.High:
+		return 8
+	case spec.Low:
+		return 1
+	default:
+		return 4
+	}
+}
+
+// estimateClusterCost returns the estimated cost, in USD, of running a
+// cluster with the given spec on the given cloud for the given duration.
+// Returns 0 for clouds without prices, e.g. local clusters.
+func estimateClusterCost(s spec.ClusterSpec, cloud spec.Cloud, d time.Duration) float64 {
+	p, ok := pricesByCloud[cloud]
+	if !ok || s.NodeCount == 0 {
+		return 0
+	}
+	cpus := float64(s.TotalCPUs())
+	hourly := cpus*p.perCPUHour + cpus*memPerCPUGB(s.Mem)*p.perGBMemHour
+
+	disks := float64(max(s.SSDs, 1))
+	switch {
+	case s.VolumeSize > 0:
+		hourly += float64(s.NodeCount) * disks * float64(s.VolumeSize) * p.perGBDiskHour
+	case s.LocalSSD == spec.LocalSSDPreferOn:
+		hourly += float64(s.NodeCount) * disks * localSSDSizeGB * p.perGBLocalSSDHour
+	default:
+		hourly += float64(s.NodeCount) * defaultVolumeSizeGB * p.perGBDiskHour
+	}
+	if s.UseSpotVMs {
+		hourly *= p.spotFactor
+	}
+	return hourly * d.Hours()
+}
+
+// expectedTestDuration returns how long the given test is expected to run on
+// the given cloud: the average duration of its previous runs if known, and its
+// timeout, scaled like the runner does, otherwise.
+func expectedTestDuration(t *registry.TestSpec, cloud spec.Cloud) time.Duration {
+	if d := t.AvgDuration(); d > 0 {
+		return d
+	}
+	return scaledTestTimeout(t, cloud, t.Cluster.Arch)
+}
+
+// estimateTestCost returns the estimated cost, in USD, of a run of the given
+// test.
+func estimateTestCost(t *registry.TestSpec, cloud spec.Cloud) float64 {
+	return estimateClusterCost(t.Cluster, cloud, expectedTestDuration(t, cloud))
+}
+
+// costTracker keeps track of the estimated and actual cost of the test runs,
+// and enforces the budget set by --max-total-cost.
+//
+// The cost of a test run is reserved when it is scheduled, using its
+// estimated cost, and replaced by its actual cost (based on how long it ran)
+// once it is done. A test run is not scheduled if its estimated cost, on top
+// of the costs reserved so far, exceeds the budget.
+type costTracker struct {
+	cloud spec.Cloud
+	// budget is the maximum total cost, in USD; 0 means no limit.
+	budget float64
+	mu     struct {
+		syncutil.Mutex
+		// reserved is the cost of the completed test runs, plus the estimated
+		// cost of the running ones.
+		reserved float64
+		// estimated and actual are the costs of the completed test runs.
+		estimated, actual float64
+		// refused are the test runs which were not scheduled because of the
+		// budget.
+		refused []notRunTest
+	}
+}
+
+func newCostTracker(cloud spec.Cloud, budget float64) *costTracker {
+	return &costTracker{cloud: cloud, budget: budget}
+}
+
+// reserve reserves the estimated cost of a run of the given test, and returns
+// it. Returns false if the run would exceed the budget, in which case it must
+// not be scheduled, unless force is set.
+func (c *costTracker) reserve(t *registry.TestSpec, runNum int, force bool) (float64, bool) {
+	est := estimateTestCost(t, c.cloud)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !force && c.budget > 0 && c.mu.reserved+est > c.budget {
+		c.mu.refused = append(c.mu.refused, notRunTest{name: t.Name, owner: string(t.Owner), runNum: runNum})
+		return est, false
+	}
+	c.mu.reserved += est
+	return est, true
+}
+
+// release releases the estimated cost reserved for a test run which ended up
+// not being run.
+func (c *costTracker) release(est float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.mu.reserved -= est
+}
+
+// complete replaces the estimated cost reserved for a test run with the
+// actual cost of running the given cluster for the given duration.
+func (c *costTracker) complete(est float64, s spec.ClusterSpec, d time.Duration) {
+	actual := estimateClusterCost(s, c.cloud, d)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.mu.reserved += actual - est
+	c.mu.estimated += est
+	c.mu.actual += actual
+}
+
+// refusedTests returns the test runs which were not scheduled because of the
+// budget, sorted by name and run.
+func (c *costTracker) refusedTests() []notRunTest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	res := append([]notRunTest(nil), c.mu.refused...)
+	sortNotRunTests(res)
+	return res
+}
+
+// summary returns a description of the costs of the completed test runs, and
+// of the test runs which were not scheduled because of the budget.
+func (c *costTracker) summary() string {
+	refused := c.refusedTests()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	msg := fmt.Sprintf("estimated cost: $%.2f, actual cost: $%.2f", c.mu.estimated, c.mu.actual)
+	if c.budget > 0 {
+		msg += fmt.Sprintf(" (budget: $%.2f)", c.budget)
+	}
+	if len(refused) > 0 {
+		runs := make([]string, len(refused))
+		for i, t := range refused {
+			runs[i] = fmt.Sprintf("%s (run %d)", t.name, t.runNum)
+		}
+		msg += fmt.Sprintf("\n%d test runs not scheduled, as they would exceed the budget:\n%s",
+			len(runs), strings.Join(runs, "\n"))
+	}
+	return msg
+}
@@ -0,0 +1,72 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/spec"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimateClusterCost(t *testing.T) {
+	s := spec.MakeClusterSpec(4, spec.CPU(8))
+	hour := estimateClusterCost(s, spec.GCE, time.Hour)
+	require.Greater(t, hour, 0.0)
+	require.InDelta(t, 2*hour, estimateClusterCost(s, spec.GCE, 2*time.Hour), 1e-9)
+
+	// Local clusters are free.
+	require.Zero(t, estimateClusterCost(s, spec.Local, time.Hour))
+
+	// Spot VMs are cheaper.
+	spot := s
+	spot.UseSpotVMs = true
+	require.Less(t, estimateClusterCost(spot, spec.GCE, time.Hour), hour)
+
+	// Larger clusters are more expensive.
+	large := spec.MakeClusterSpec(8, spec.CPU(8))
+	require.Greater(t, estimateClusterCost(large, spec.GCE, time.Hour), hour)
+}
+
+func TestCostTracker(t *testing.T) {
+	test := registry.TestSpec{
+		Name:    "foo",
+		Cluster: spec.MakeClusterSpec(4, spec.CPU(8)),
+		Timeout: time.Hour,
+	}
+	est := estimateTestCost(&test, spec.GCE)
+
+	// The budget allows for two runs of the test.
+	c := newCostTracker(spec.GCE, 2.5*est)
+	_, ok := c.reserve(&test, 1, false /* force */)
+	require.True(t, ok)
+	_, ok = c.reserve(&test, 2, false /* force */)
+	require.True(t, ok)
+	_, ok = c.reserve(&test, 3, false /* force */)
+	require.False(t, ok)
+	// Retries are always scheduled.
+	_, ok = c.reserve(&test, 2, true /* force */)
+	require.True(t, ok)
+
+	// The first run finishes early, leaving budget for another run.
+	c.complete(est, test.Cluster, 0)
+	c.release(est)
+	_, ok = c.reserve(&test, 4, false /* force */)
+	require.True(t, ok)
+
+	require.Equal(t, []notRunTest{{name: "foo", runNum: 3}}, c.refusedTests())
+	summary := c.summary()
+	require.Contains(t, summary, "actual cost: $0.00")
+	require.Contains(t, summary, "1 test runs not scheduled")
+	require.Contains(t, summary, "foo (run 3)")
+}
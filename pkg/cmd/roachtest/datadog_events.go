@@ -0,0 +1,90 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/roachtestflags"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/config"
+)
+
+const (
+	// operationDashboardURL is the URL of the dashboard of the centralized
+	// grafana instance which shows the metrics of a cluster, selected by its
+	// cluster variable.
+	operationDashboardURL = "https://grafana.testeng.crdb.io/d/overview"
+	// operationDashboardLookback is how long before the start of an operation
+	// the dashboard linked from its events starts, so that the state of the
+	// cluster before the operation is visible.
+	operationDashboardLookback = 15 * time.Minute
+)
+
+// operationEventLinks are the diagnostics of a run of an operation which are
+// attached to its Datadog events, so that they can be reached from the event.
+type operationEventLinks struct {
+	clusterName string
+	runID       uint64
+	// logs is the URL of the logs of the run, if --operation-logs-url is set.
+	logs string
+	// dashboard is the URL of the grafana dashboard of the cluster during the
+	// run. It is empty for local clusters, which aren't monitored.
+	dashboard string
+}
+
+// makeOperationEventLinks returns the links of the given run of an operation
+// on the given cluster, which started at the given time.
+func makeOperationEventLinks(
+	clusterName, opName string, runID uint64, start time.Time,
+) operationEventLinks {
+	links := operationEventLinks{clusterName: clusterName, runID: runID}
+	if tmpl := roachtestflags.OperationLogsURL; tmpl != "" {
+		links.logs = strings.NewReplacer(
+			"{cluster}", url.QueryEscape(clusterName),
+			"{operation}", url.QueryEscape(opName),
+			"{run-id}", strconv.FormatUint(runID, 10),
+		).Replace(tmpl)
+	}
+	if !config.IsLocalClusterName(clusterName) {
+		links.dashboard = fmt.Sprintf("%s?var-cluster=%s&from=%d&to=now",
+			operationDashboardURL, url.QueryEscape(clusterName),
+			start.Add(-operationDashboardLookback).UnixMilli())
+	}
+	return links
+}
+
+// tags returns the tags of the event which identify the cluster and the run,
+// so that the events of either can be searched for.
+func (l operationEventLinks) tags() []string {
+	return []string{
+		fmt.Sprintf("cluster:%s", l.clusterName),
+		fmt.Sprintf("operation-run-id:%d", l.runID),
+	}
+}
+
+// text returns the body of the event, in the markdown understood by Datadog.
+func (l operationEventLinks) text() string {
+	var b strings.Builder
+	b.WriteString("%%% \n")
+	fmt.Fprintf(&b, "**Cluster:** `%s`  \n", l.clusterName)
+	if l.logs != "" {
+		fmt.Fprintf(&b, "**Logs:** [operation logs](%s)  \n", l.logs)
+	}
+	if l.dashboard != "" {
+		fmt.Fprintf(&b, "**Dashboard:** [grafana](%s)  \n", l.dashboard)
+	}
+	b.WriteString("\n %%%")
+	return b.String()
+}
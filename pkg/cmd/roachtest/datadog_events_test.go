@@ -0,0 +1,39 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/roachtestflags"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOperationEventLinks(t *testing.T) {
+	defer func(u string) { roachtestflags.OperationLogsURL = u }(roachtestflags.OperationLogsURL)
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	roachtestflags.OperationLogsURL = ""
+	links := makeOperationEventLinks("local", "add-column", 42, start)
+	require.Empty(t, links.logs)
+	require.Empty(t, links.dashboard)
+	require.Equal(t, []string{"cluster:local", "operation-run-id:42"}, links.tags())
+	require.Equal(t, "%%% \n**Cluster:** `local`  \n\n %%%", links.text())
+
+	roachtestflags.OperationLogsURL = "https://logs.example.com/{cluster}/{operation}?run={run-id}"
+	links = makeOperationEventLinks("drt-chaos", "network-partition/full", 42, start)
+	require.Equal(t, "https://logs.example.com/drt-chaos/network-partition%2Ffull?run=42", links.logs)
+	require.Equal(t, "https://grafana.testeng.crdb.io/d/overview?var-cluster=drt-chaos&from=1714563900000&to=now",
+		links.dashboard)
+	require.Contains(t, links.text(), "[operation logs]("+links.logs+")")
+	require.Contains(t, links.text(), "[grafana]("+links.dashboard+")")
+}
@@ -0,0 +1,132 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV2"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/logger"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+)
+
+// The names of the metrics submitted to Datadog.
+const (
+	ddMetricOperationDuration = "roachtest.operation.duration_seconds"
+	ddMetricOperationFailures = "roachtest.operation.failures"
+	ddMetricTestDuration      = "roachtest.test.duration_seconds"
+	ddMetricTestFailures      = "roachtest.test.failures"
+	ddMetricClusterCreation   = "roachtest.cluster.creation_seconds"
+	ddMetricClusterFailures   = "roachtest.cluster.creation_failures"
+)
+
+// hasDatadogContext returns whether the given context is configured to
+// communicate with Datadog. See newDatadogContext.
+func hasDatadogContext(ctx context.Context) bool {
+	_, hasAPIKeys := ctx.Value(datadog.ContextAPIKeys).(map[string]datadog.APIKey)
+	_, hasServerVariables := ctx.Value(datadog.ContextServerVariables).(map[string]string)
+	return hasAPIKeys && hasServerVariables
+}
+
+// datadogMetrics submits metrics to Datadog, tagged with the tags given via
+// --datadog-tags or DD_TAGS. Submission is best effort: errors are logged, and
+// don't affect the test or operation. All methods are no-ops on a nil
+// datadogMetrics.
+type datadogMetrics struct {
+	api  *datadogV2.MetricsApi
+	tags []string
+}
+
+// newDatadogMetrics returns a client submitting metrics to Datadog, or nil if
+// the given context is not configured to communicate with Datadog. The
+// metrics must be submitted with a context derived from the given one.
+func newDatadogMetrics(ctx context.Context, tags []string) *datadogMetrics {
+	if !hasDatadogContext(ctx) {
+		return nil
+	}
+	return &datadogMetrics{
+		api:  datadogV2.NewMetricsApi(datadog.NewAPIClient(datadog.NewConfiguration())),
+		tags: tags,
+	}
+}
+
+func (m *datadogMetrics) submit(
+	ctx context.Context,
+	l *logger.Logger,
+	name string,
+	typ datadogV2.MetricIntakeType,
+	value float64,
+	tags ...string,
+) {
+	if m == nil {
+		return
+	}
+	series := datadogV2.MetricSeries{
+		Metric: name,
+		Type:   typ.Ptr(),
+		Points: []datadogV2.MetricPoint{{
+			Timestamp: datadog.PtrInt64(timeutil.Now().Unix()),
+			Value:     datadog.PtrFloat64(value),
+		}},
+		Tags: append(append([]string(nil), m.tags...), tags...),
+	}
+	if _, _, err := m.api.SubmitMetrics(ctx, datadogV2.MetricPayload{
+		Series: []datadogV2.MetricSeries{series},
+	}); err != nil {
+		l.PrintfCtx(ctx, "failed to submit metric %s to Datadog: %s", name, err)
+	}
+}
+
+// recordOperation submits the duration of an operation run, and whether it
+// failed.
+func (m *datadogMetrics) recordOperation(
+	ctx context.Context, l *logger.Logger, name, clusterName string, duration time.Duration, failed bool,
+) {
+	tags := []string{
+		fmt.Sprintf("operation-name:%s", name),
+		fmt.Sprintf("cluster:%s", clusterName),
+	}
+	m.submit(ctx, l, ddMetricOperationDuration, datadogV2.METRICINTAKETYPE_GAUGE, duration.Seconds(), tags...)
+	if failed {
+		m.submit(ctx, l, ddMetricOperationFailures, datadogV2.METRICINTAKETYPE_COUNT, 1, tags...)
+	}
+}
+
+// recordTest submits the duration of a finished test run, and whether it
+// failed.
+func (m *datadogMetrics) recordTest(ctx context.Context, l *logger.Logger, t *testImpl) {
+	status := testReportStatus(t)
+	tags := []string{
+		fmt.Sprintf("test:%s", t.Name()),
+		fmt.Sprintf("owner:%s", t.spec.Owner),
+		fmt.Sprintf("status:%s", status),
+	}
+	m.submit(ctx, l, ddMetricTestDuration, datadogV2.METRICINTAKETYPE_GAUGE, t.duration().Seconds(), tags...)
+	if status == reportStatusFailure {
+		m.submit(ctx, l, ddMetricTestFailures, datadogV2.METRICINTAKETYPE_COUNT, 1, tags...)
+	}
+}
+
+// recordClusterCreation submits the time it took to create a cluster for the
+// given test, or the failure to create it.
+func (m *datadogMetrics) recordClusterCreation(
+	ctx context.Context, l *logger.Logger, testName string, duration time.Duration, err error,
+) {
+	tags := []string{fmt.Sprintf("test:%s", testName)}
+	if err != nil {
+		m.submit(ctx, l, ddMetricClusterFailures, datadogV2.METRICINTAKETYPE_COUNT, 1, tags...)
+		return
+	}
+	m.submit(ctx, l, ddMetricClusterCreation, datadogV2.METRICINTAKETYPE_GAUGE, duration.Seconds(), tags...)
+}
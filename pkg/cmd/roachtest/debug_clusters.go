@@ -0,0 +1,154 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/roachprod/logger"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+)
+
+const (
+	// debugClusterCheckInterval is how often the clusters kept for debugging
+	// are checked.
+	debugClusterCheckInterval = 5 * time.Minute
+	// debugClusterReminderInterval is how often reminders are sent about the
+	// clusters kept for debugging.
+	debugClusterReminderInterval = time.Hour
+)
+
+// keptCluster is a cluster kept for debugging managed by the
+// debugClusterLifecycle.
+type keptCluster struct {
+	// deadline is the time at which the cluster is destroyed.
+	deadline     time.Time
+	lastReminder time.Time
+}
+
+// debugClusterLifecycle manages the clusters kept for debugging by --debug and
+// --debug-always, when --debug-max-lifetime is set, so that they don't leak
+// until they expire: the expiration of a cluster is set to its deadline,
+// --debug-max-lifetime after it was kept, as soon as it is kept, reminders are
+// sent about it, and it is destroyed at its deadline if the run isn't over by
+// then. Setting the expiration up front keeps the clusters kept by the last
+// tests of the run, which the runner doesn't wait for, alive until their
+// deadline, and no longer.
+type debugClusterLifecycle struct {
+	cr          *clusterRegistry
+	notifier    *webhookNotifier
+	stdout      io.Writer
+	maxLifetime time.Duration
+	// kept are the managed clusters. It is only accessed by run.
+	kept map[*clusterImpl]*keptCluster
+}
+
+func newDebugClusterLifecycle(
+	cr *clusterRegistry, notifier *webhookNotifier, stdout io.Writer, maxLifetime time.Duration,
+) *debugClusterLifecycle {
+	return &debugClusterLifecycle{
+		cr:          cr,
+		notifier:    notifier,
+		stdout:      stdout,
+		maxLifetime: maxLifetime,
+		kept:        make(map[*clusterImpl]*keptCluster),
+	}
+}
+
+// run manages the clusters kept for debugging until the context is canceled
+// or runDone is closed. The runner doesn't wait for the clusters which are
+// still kept once the run is done: they are checked one last time, and are
+// left to expire at their deadline.
+func (lc *debugClusterLifecycle) run(
+	ctx context.Context, l *logger.Logger, runDone <-chan struct{},
+) {
+	ticker := time.NewTicker(debugClusterCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			lc.leave(ctx, l)
+			return
+		case <-runDone:
+			lc.check(ctx, l, timeutil.Now())
+			lc.leave(ctx, l)
+			return
+		case <-ticker.C:
+			lc.check(ctx, l, timeutil.Now())
+		}
+	}
+}
+
+// check manages each of the clusters kept for debugging.
+func (lc *debugClusterLifecycle) check(ctx context.Context, l *logger.Logger, now time.Time) {
+	for _, saved := range lc.cr.savedClusters() {
+		c := saved.clusterImpl
+		if c.IsLocal() {
+			// Local clusters don't expire, and aren't accessed over SSH.
+			continue
+		}
+		kc, ok := lc.kept[c]
+		if !ok {
+			kc = &keptCluster{deadline: now.Add(lc.maxLifetime), lastReminder: now}
+			lc.kept[c] = kc
+			if d := debugClusterExtension(c.expiration, kc.deadline); d != 0 {
+				l.PrintfCtx(ctx, "setting the expiration of cluster %s to %s",
+					c, kc.deadline.Format(time.RFC3339))
+				if err := c.Extend(ctx, d, l); err != nil {
+					l.PrintfCtx(ctx, "failed to extend cluster %s: %v", c, err)
+				}
+			}
+			lc.remind(ctx, l, c, saved.savedMsg, kc.deadline.Sub(now))
+		}
+
+		if !now.Before(kc.deadline) {
+			shout(ctx, l, lc.stdout, "destroying cluster %s kept for debugging: --debug-max-lifetime elapsed", c)
+			c.destroySaved(ctx, l)
+			delete(lc.kept, c)
+			continue
+		}
+
+		if now.Sub(kc.lastReminder) >= debugClusterReminderInterval {
+			kc.lastReminder = now
+			lc.remind(ctx, l, c, saved.savedMsg, kc.deadline.Sub(now))
+		}
+	}
+}
+
+// leave notifies that the clusters still kept for debugging are no longer
+// managed, and are left to expire.
+func (lc *debugClusterLifecycle) leave(ctx context.Context, l *logger.Logger) {
+	for c := range lc.kept {
+		shout(ctx, l, lc.stdout, "cluster %s kept for debugging is left to expire at %s",
+			c, c.expiration.Format(time.RFC3339))
+	}
+}
+
+// remind notifies that the given cluster is kept for debugging, and when it
+// will be destroyed.
+func (lc *debugClusterLifecycle) remind(
+	ctx context.Context, l *logger.Logger, c *clusterImpl, msg string, remaining time.Duration,
+) {
+	text := fmt.Sprintf("cluster %s is kept for debugging (%s) and will be destroyed in %s",
+		c, msg, remaining.Round(time.Minute))
+	shout(ctx, l, lc.stdout, "%s", text)
+	lc.notifier.debugClusterReminder(ctx, l, text)
+}
+
+// debugClusterExtension returns by how much to extend a cluster with the given
+// expiration so that it expires at the given deadline. It is negative if the
+// cluster expires after the deadline, which shortens its lifetime.
+func debugClusterExtension(expiration, deadline time.Time) time.Duration {
+	return deadline.Sub(expiration).Round(time.Minute)
+}
@@ -0,0 +1,30 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugClusterExtension(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	deadline := now.Add(4 * time.Hour)
+
+	// The cluster expires before its deadline; it is extended up to it.
+	require.Equal(t, 3*time.Hour+50*time.Minute, debugClusterExtension(now.Add(10*time.Minute), deadline))
+	// The cluster expires after its deadline; its lifetime is shortened.
+	require.Equal(t, -8*time.Hour, debugClusterExtension(now.Add(12*time.Hour), deadline))
+	// The cluster already expires at its deadline.
+	require.Zero(t, debugClusterExtension(deadline.Add(10*time.Second), deadline))
+}
@@ -0,0 +1,79 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/option"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/logger"
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	// delveUnit is the systemd unit delve runs in on the nodes.
+	delveUnit = "roachtest-delve"
+	// delvePort is the port delve listens on, on the loopback interface of the
+	// nodes only; it's reached through an SSH tunnel.
+	delvePort = 2345
+)
+
+// delveAttachCmd returns the command which attaches a headless delve server to
+// the oldest cockroach process on a node, i.e. the one of the system
+// interface, and lets it continue running.
+func delveAttachCmd() string {
+	// NB: the brackets keep pgrep from matching the shell running the command.
+	return fmt.Sprintf(`pid=$(pgrep -o -f '[c]ockroach start') || { echo "cockroach is not running"; exit 1; }
+sudo systemctl stop %[1]s 2>/dev/null; sudo systemctl reset-failed %[1]s 2>/dev/null;
+sudo systemd-run --unit=%[1]s dlv attach "$pid" --headless --accept-multiclient --continue \
+  --api-version=2 --listen=127.0.0.1:%[2]d`, delveUnit, delvePort)
+}
+
+// delveInstructions returns the instructions to connect to the delve servers
+// attached to cockroach on the given nodes of the given cluster.
+func delveInstructions(clusterName string, nodes option.NodeListOption) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "delve is attached to cockroach on nodes %s of cluster %s. To debug node N, run:\n",
+		nodes.String()[1:], clusterName)
+	fmt.Fprintf(&b, "  roachprod ssh %s:N -O '-L %d:localhost:%d'\n", clusterName, delvePort, delvePort)
+	fmt.Fprintf(&b, "and, while that session is open, in another terminal:\n")
+	fmt.Fprintf(&b, "  dlv connect localhost:%d\n", delvePort)
+	fmt.Fprintf(&b, "Halting the process in the debugger stops the node, and exiting the debugger "+
+		"with 'quit -c' leaves it running.")
+	return b.String()
+}
+
+// attachDelve installs delve on the CockroachDB nodes of the cluster, attaches
+// it to the cockroach processes and returns the instructions to connect to
+// it. See --debug-with-delve.
+func (c *clusterImpl) attachDelve(ctx context.Context, l *logger.Logger) (string, error) {
+	if c.IsLocal() {
+		return "", errors.New("delve is not supported on local clusters")
+	}
+	nodes := c.CRDBNodes()
+	if err := c.Install(ctx, l, nodes, "delve"); err != nil {
+		return "", errors.Wrap(err, "installing delve")
+	}
+	var attached option.NodeListOption
+	for _, node := range nodes {
+		if err := c.RunE(ctx, option.WithNodes(c.Node(node)), delveAttachCmd()); err != nil {
+			l.PrintfCtx(ctx, "not attaching delve on n%d: %s", node, err)
+			continue
+		}
+		attached = append(attached, node)
+	}
+	if len(attached) == 0 {
+		return "", errors.New("cockroach is not running on any node")
+	}
+	return delveInstructions(c.Name(), attached), nil
+}
@@ -0,0 +1,30 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/option"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDelve(t *testing.T) {
+	cmd := delveAttachCmd()
+	require.Contains(t, cmd, "pgrep -o -f '[c]ockroach start'")
+	require.Contains(t, cmd, `sudo systemd-run --unit=roachtest-delve dlv attach "$pid" --headless`)
+	require.Contains(t, cmd, "--listen=127.0.0.1:2345")
+
+	instructions := delveInstructions("foo", option.NodeListOption{1, 3})
+	require.Contains(t, instructions, "nodes 1,3 of cluster foo")
+	require.Contains(t, instructions, "roachprod ssh foo:N -O '-L 2345:localhost:2345'")
+	require.Contains(t, instructions, "dlv connect localhost:2345")
+}
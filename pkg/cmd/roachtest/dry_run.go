@@ -0,0 +1,642 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"context"
+	gosql "database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachprod/grafana"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/cluster"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/option"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/spec"
+	"github.com/cockroachdb/cockroach/pkg/roachprod"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/install"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/logger"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/prometheus"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/vm"
+	"github.com/cockroachdb/cockroach/pkg/sql/parser"
+	"github.com/cockroachdb/cockroach/pkg/sql/sem/tree"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+	"github.com/cockroachdb/errors"
+)
+
+// dryRunCluster is a cluster which records, instead of executing, everything
+// that could mutate the underlying cluster: roachprod commands, file uploads,
+// starting and stopping nodes, resizing, and SQL statements other than
+// read-only ones. See run-operation --dry-run.
+//
+// Only the methods which are known not to mutate the cluster, such as
+// resolving nodes and addresses, are delegated to the underlying cluster; all
+// the other ones are recorded. The underlying cluster is intentionally not
+// embedded, so that methods added to cluster.Cluster need to be explicitly
+// added here too.
+//
+// Read-only SQL statements are executed, so that operations can inspect the
+// cluster to decide what to do. The recorded steps are the ones an operation
+// would execute given the current state of the cluster; note that commands
+// which are recorded don't return any output.
+type dryRunCluster struct {
+	impl *clusterImpl
+	l    *logger.Logger
+
+	mu struct {
+		syncutil.Mutex
+		steps []string
+	}
+}
+
+var _ cluster.DynamicCluster = &dryRunCluster{}
+
+func newDryRunCluster(c *dynamicClusterImpl, l *logger.Logger) *dryRunCluster {
+	return &dryRunCluster{impl: c.clusterImpl, l: l}
+}
+
+// record records a step which would have been executed.
+func (c *dryRunCluster) record(format string, args ...interface{}) {
+	step := fmt.Sprintf(format, args...)
+	c.l.Printf("dry-run: would %s", step)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.mu.steps = append(c.mu.steps, step)
+}
+
+// steps returns the steps recorded so far.
+func (c *dryRunCluster) steps() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.mu.steps...)
+}
+
+func (c *dryRunCluster) nodes(opts ...option.Option) string {
+	return c.impl.MakeNodes(opts...)
+}
+
+func (c *dryRunCluster) runOptionNodes(options install.RunOptions) string {
+	nodes := make(option.NodeListOption, 0, len(options.Nodes))
+	for _, n := range options.Nodes {
+		nodes = append(nodes, int(n))
+	}
+	return c.nodes(nodes)
+}
+
+// The following methods don't mutate the cluster, and are delegated to the
+// underlying cluster.
+
+func (c *dryRunCluster) All() option.NodeListOption       { return c.impl.All() }
+func (c *dryRunCluster) CRDBNodes() option.NodeListOption { return c.impl.CRDBNodes() }
+func (c *dryRunCluster) Range(begin, end int) option.NodeListOption {
+	return c.impl.Range(begin, end)
+}
+func (c *dryRunCluster) Nodes(ns ...int) option.NodeListOption { return c.impl.Nodes(ns...) }
+func (c *dryRunCluster) Node(i int) option.NodeListOption      { return c.impl.Node(i) }
+func (c *dryRunCluster) WorkloadNode() option.NodeListOption   { return c.impl.WorkloadNode() }
+func (c *dryRunCluster) WorkloadNodesWithRole(role spec.WorkloadRole) option.NodeListOption {
+	return c.impl.WorkloadNodesWithRole(role)
+}
+func (c *dryRunCluster) MakeNodes(opts ...option.Option) string { return c.impl.MakeNodes(opts...) }
+func (c *dryRunCluster) SetRandomSeed(seed int64)               { c.impl.SetRandomSeed(seed) }
+
+func (c *dryRunCluster) Spec() spec.ClusterSpec   { return c.impl.Spec() }
+func (c *dryRunCluster) Name() string             { return c.impl.Name() }
+func (c *dryRunCluster) Cloud() spec.Cloud        { return c.impl.Cloud() }
+func (c *dryRunCluster) IsLocal() bool            { return c.impl.IsLocal() }
+func (c *dryRunCluster) IsSecure() bool           { return c.impl.IsSecure() }
+func (c *dryRunCluster) Architecture() vm.CPUArch { return c.impl.Architecture() }
+func (c *dryRunCluster) NodeArchitecture(node int) vm.CPUArch {
+	return c.impl.NodeArchitecture(node)
+}
+
+func (c *dryRunCluster) Get(
+	ctx context.Context, l *logger.Logger, src, dest string, opts ...option.Option,
+) error {
+	return c.impl.Get(ctx, l, src, dest, opts...)
+}
+
+func (c *dryRunCluster) NewMonitor(ctx context.Context, opts ...option.Option) cluster.Monitor {
+	return c.impl.NewMonitor(ctx, opts...)
+}
+
+func (c *dryRunCluster) InternalAddr(
+	ctx context.Context, l *logger.Logger, node option.NodeListOption,
+) ([]string, error) {
+	return c.impl.InternalAddr(ctx, l, node)
+}
+
+func (c *dryRunCluster) InternalIP(
+	ctx context.Context, l *logger.Logger, node option.NodeListOption,
+) ([]string, error) {
+	return c.impl.InternalIP(ctx, l, node)
+}
+
+func (c *dryRunCluster) ExternalAddr(
+	ctx context.Context, l *logger.Logger, node option.NodeListOption,
+) ([]string, error) {
+	return c.impl.ExternalAddr(ctx, l, node)
+}
+
+func (c *dryRunCluster) ExternalIP(
+	ctx context.Context, l *logger.Logger, node option.NodeListOption,
+) ([]string, error) {
+	return c.impl.ExternalIP(ctx, l, node)
+}
+
+func (c *dryRunCluster) SQLPorts(
+	ctx context.Context, l *logger.Logger, node option.NodeListOption, tenant string, sqlInstance int,
+) ([]int, error) {
+	return c.impl.SQLPorts(ctx, l, node, tenant, sqlInstance)
+}
+
+func (c *dryRunCluster) InternalPGUrl(
+	ctx context.Context, l *logger.Logger, node option.NodeListOption, opts roachprod.PGURLOptions,
+) ([]string, error) {
+	return c.impl.InternalPGUrl(ctx, l, node, opts)
+}
+
+func (c *dryRunCluster) ExternalPGUrl(
+	ctx context.Context, l *logger.Logger, node option.NodeListOption, opts roachprod.PGURLOptions,
+) ([]string, error) {
+	return c.impl.ExternalPGUrl(ctx, l, node, opts)
+}
+
+func (c *dryRunCluster) InternalAdminUIAddr(
+	ctx context.Context, l *logger.Logger, node option.NodeListOption,
+) ([]string, error) {
+	return c.impl.InternalAdminUIAddr(ctx, l, node)
+}
+
+func (c *dryRunCluster) ExternalAdminUIAddr(
+	ctx context.Context, l *logger.Logger, node option.NodeListOption,
+) ([]string, error) {
+	return c.impl.ExternalAdminUIAddr(ctx, l, node)
+}
+
+func (c *dryRunCluster) AdminUIPorts(
+	ctx context.Context, l *logger.Logger, node option.NodeListOption, tenant string, sqlInstance int,
+) ([]int, error) {
+	return c.impl.AdminUIPorts(ctx, l, node, tenant, sqlInstance)
+}
+
+func (c *dryRunCluster) ListSnapshots(
+	ctx context.Context, vslo vm.VolumeSnapshotListOpts,
+) ([]vm.VolumeSnapshot, error) {
+	return c.impl.ListSnapshots(ctx, vslo)
+}
+
+func (c *dryRunCluster) GetPreemptedVMs(
+	ctx context.Context, l *logger.Logger,
+) ([]vm.PreemptedVM, error) {
+	return c.impl.GetPreemptedVMs(ctx, l)
+}
+
+// The following methods could mutate the cluster, and are recorded.
+
+func (c *dryRunCluster) Put(ctx context.Context, src, dest string, opts ...option.Option) {
+	c.record("put %s to %s on %s", src, dest, c.nodes(opts...))
+}
+
+func (c *dryRunCluster) PutE(
+	ctx context.Context, l *logger.Logger, src, dest string, opts ...option.Option,
+) error {
+	c.Put(ctx, src, dest, opts...)
+	return nil
+}
+
+func (c *dryRunCluster) PutString(
+	ctx context.Context, content, dest string, mode os.FileMode, opts ...option.Option,
+) error {
+	c.record("write %d bytes to %s on %s", len(content), dest, c.nodes(opts...))
+	return nil
+}
+
+func (c *dryRunCluster) StartE(
+	ctx context.Context,
+	l *logger.Logger,
+	startOpts option.StartOpts,
+	settings install.ClusterSettings,
+	opts ...option.Option,
+) error {
+	c.record("start cockroach on %s", c.nodes(opts...))
+	return nil
+}
+
+func (c *dryRunCluster) Start(
+	ctx context.Context,
+	l *logger.Logger,
+	startOpts option.StartOpts,
+	settings install.ClusterSettings,
+	opts ...option.Option,
+) {
+	_ = c.StartE(ctx, l, startOpts, settings, opts...)
+}
+
+func (c *dryRunCluster) StopE(
+	ctx context.Context, l *logger.Logger, stopOpts option.StopOpts, opts ...option.Option,
+) error {
+	c.record("stop cockroach on %s (signal %d, wait %t)",
+		c.nodes(opts...), stopOpts.RoachprodOpts.Sig, stopOpts.RoachprodOpts.Wait)
+	return nil
+}
+
+func (c *dryRunCluster) Stop(
+	ctx context.Context, l *logger.Logger, stopOpts option.StopOpts, opts ...option.Option,
+) {
+	_ = c.StopE(ctx, l, stopOpts, opts...)
+}
+
+func (c *dryRunCluster) SignalE(
+	ctx context.Context, l *logger.Logger, sig int, opts ...option.Option,
+) error {
+	c.record("send signal %d to cockroach on %s", sig, c.nodes(opts...))
+	return nil
+}
+
+func (c *dryRunCluster) Signal(
+	ctx context.Context, l *logger.Logger, sig int, opts ...option.Option,
+) {
+	_ = c.SignalE(ctx, l, sig, opts...)
+}
+
+func (c *dryRunCluster) StopCockroachGracefullyOnNode(
+	ctx context.Context, l *logger.Logger, node int,
+) error {
+	c.record("gracefully stop cockroach on %s", c.nodes(c.Node(node)))
+	return nil
+}
+
+func (c *dryRunCluster) WipeE(ctx context.Context, l *logger.Logger, opts ...option.Option) error {
+	c.record("wipe %s", c.nodes(opts...))
+	return nil
+}
+
+func (c *dryRunCluster) Wipe(ctx context.Context, opts ...option.Option) {
+	_ = c.WipeE(ctx, c.l, opts...)
+}
+
+func (c *dryRunCluster) Install(
+	ctx context.Context, l *logger.Logger, nodes option.NodeListOption, software ...string,
+) error {
+	c.record("install %s on %s", strings.Join(software, ", "), c.nodes(nodes))
+	return nil
+}
+
+func (c *dryRunCluster) RunWithDetails(
+	ctx context.Context, testLogger *logger.Logger, options install.RunOptions, args ...string,
+) ([]install.RunResultDetails, error) {
+	c.record("run %q on %s", strings.Join(args, " "), c.runOptionNodes(options))
+	return nil, nil
+}
+
+func (c *dryRunCluster) Run(ctx context.Context, options install.RunOptions, args ...string) {
+	_ = c.RunE(ctx, options, args...)
+}
+
+func (c *dryRunCluster) RunE(ctx context.Context, options install.RunOptions, args ...string) error {
+	_, err := c.RunWithDetails(ctx, c.l, options, args...)
+	return err
+}
+
+func (c *dryRunCluster) RunWithDetailsSingleNode(
+	ctx context.Context, testLogger *logger.Logger, options install.RunOptions, args ...string,
+) (install.RunResultDetails, error) {
+	_, err := c.RunWithDetails(ctx, testLogger, options, args...)
+	return install.RunResultDetails{}, err
+}
+
+func (c *dryRunCluster) PutLibraries(
+	ctx context.Context, libraryDir string, libraries []string,
+) error {
+	c.record("put libraries %s from %s", strings.Join(libraries, ", "), libraryDir)
+	return nil
+}
+
+func (c *dryRunCluster) Stage(
+	ctx context.Context,
+	l *logger.Logger,
+	application, versionOrSHA, dir string,
+	opts ...option.Option,
+) error {
+	c.record("stage %s %s to %s on %s", application, versionOrSHA, dir, c.nodes(opts...))
+	return nil
+}
+
+func (c *dryRunCluster) StartServiceForVirtualClusterE(
+	ctx context.Context,
+	l *logger.Logger,
+	startOpts option.StartOpts,
+	settings install.ClusterSettings,
+) error {
+	c.record("start virtual cluster %s", startOpts.RoachprodOpts.VirtualClusterName)
+	return nil
+}
+
+func (c *dryRunCluster) StartServiceForVirtualCluster(
+	ctx context.Context,
+	l *logger.Logger,
+	startOpts option.StartOpts,
+	settings install.ClusterSettings,
+) {
+	_ = c.StartServiceForVirtualClusterE(ctx, l, startOpts, settings)
+}
+
+func (c *dryRunCluster) StopServiceForVirtualClusterE(
+	ctx context.Context, l *logger.Logger, stopOpts option.StopOpts,
+) error {
+	c.record("stop virtual cluster %s", stopOpts.RoachprodOpts.VirtualClusterName)
+	return nil
+}
+
+func (c *dryRunCluster) StopServiceForVirtualCluster(
+	ctx context.Context, l *logger.Logger, stopOpts option.StopOpts,
+) {
+	_ = c.StopServiceForVirtualClusterE(ctx, l, stopOpts)
+}
+
+func (c *dryRunCluster) DestroyDNS(ctx context.Context, l *logger.Logger) error {
+	c.record("destroy the DNS records of the cluster")
+	return nil
+}
+
+func (c *dryRunCluster) Reformat(
+	ctx context.Context, l *logger.Logger, node option.NodeListOption, filesystem string,
+) error {
+	c.record("reformat %s with %s", c.nodes(node), filesystem)
+	return nil
+}
+
+func (c *dryRunCluster) GitClone(
+	ctx context.Context, l *logger.Logger, src, dest, branch string, node option.NodeListOption,
+) error {
+	c.record("clone %s@%s to %s on %s", src, branch, dest, c.nodes(node))
+	return nil
+}
+
+func (c *dryRunCluster) FetchTimeseriesData(ctx context.Context, l *logger.Logger) error {
+	c.record("fetch timeseries data")
+	return nil
+}
+
+func (c *dryRunCluster) FetchDebugZip(
+	ctx context.Context, l *logger.Logger, dest string, opts ...option.Option,
+) error {
+	c.record("fetch debug zip from %s to %s", c.nodes(opts...), dest)
+	return nil
+}
+
+func (c *dryRunCluster) RefetchCertsFromNode(ctx context.Context, node int) error {
+	c.record("refetch certs from %s", c.nodes(c.Node(node)))
+	return nil
+}
+
+func (c *dryRunCluster) StartGrafana(
+	ctx context.Context, l *logger.Logger, promCfg *prometheus.Config,
+) error {
+	c.record("start grafana")
+	return nil
+}
+
+func (c *dryRunCluster) StopGrafana(ctx context.Context, l *logger.Logger, dumpDir string) error {
+	c.record("stop grafana")
+	return nil
+}
+
+func (c *dryRunCluster) AddGrafanaAnnotation(
+	ctx context.Context, l *logger.Logger, req grafana.AddAnnotationRequest,
+) error {
+	c.record("add grafana annotation %q", req.Text)
+	return nil
+}
+
+func (c *dryRunCluster) AddInternalGrafanaAnnotation(
+	ctx context.Context, l *logger.Logger, req grafana.AddAnnotationRequest,
+) error {
+	c.record("add internal grafana annotation %q", req.Text)
+	return nil
+}
+
+func (c *dryRunCluster) CreateSnapshot(
+	ctx context.Context, snapshotPrefix string,
+) ([]vm.VolumeSnapshot, error) {
+	c.record("create volume snapshots %s", snapshotPrefix)
+	return nil, nil
+}
+
+func (c *dryRunCluster) DeleteSnapshots(ctx context.Context, snapshots ...vm.VolumeSnapshot) error {
+	c.record("delete %d volume snapshots", len(snapshots))
+	return nil
+}
+
+func (c *dryRunCluster) ApplySnapshots(ctx context.Context, snapshots []vm.VolumeSnapshot) error {
+	c.record("apply %d volume snapshots", len(snapshots))
+	return nil
+}
+
+// Grow implements the cluster.DynamicCluster interface.
+func (c *dryRunCluster) Grow(ctx context.Context, l *logger.Logger, nodeCount int) error {
+	c.record("grow the cluster by %d nodes", nodeCount)
+	return nil
+}
+
+// Shrink implements the cluster.DynamicCluster interface.
+func (c *dryRunCluster) Shrink(ctx context.Context, l *logger.Logger, nodeCount int) error {
+	c.record("shrink the cluster by %d nodes", nodeCount)
+	return nil
+}
+
+func (c *dryRunCluster) Conn(
+	ctx context.Context, l *logger.Logger, node int, opts ...func(*option.ConnOption),
+) *gosql.DB {
+	db, err := c.ConnE(ctx, l, node, opts...)
+	if err != nil {
+		c.impl.f.Fatal(err)
+	}
+	return db
+}
+
+// ConnE returns a connection to the specified node which executes read-only
+// statements, and records the other ones.
+func (c *dryRunCluster) ConnE(
+	ctx context.Context, l *logger.Logger, node int, opts ...func(*option.ConnOption),
+) (*gosql.DB, error) {
+	dataSourceName, err := c.impl.pgDataSourceName(ctx, l, node, opts...)
+	if err != nil {
+		return nil, errors.Wrapf(err, "connecting to node %d", node)
+	}
+	// Opening a DB doesn't connect to it; this is only to get hold of the
+	// driver.
+	db, err := gosql.Open("postgres", dataSourceName)
+	if err != nil {
+		return nil, errors.Wrapf(err, "connecting to node %d", node)
+	}
+	drv := db.Driver()
+	_ = db.Close()
+	return gosql.OpenDB(&dryRunConnector{
+		dataSourceName: dataSourceName,
+		drv:            drv,
+		record: func(stmt string) {
+			c.record("execute on n%d: %s", node, stmt)
+		},
+	}), nil
+}
+
+// isReadOnlyStatement returns whether the given SQL is executed in a dry-run,
+// i.e. whether all its statements are read-only: SELECT, SHOW, EXPLAIN
+// without ANALYZE, and session variable statements (SET, RESET and USE),
+// which don't affect the cluster. Anything else, including SQL which doesn't
+// parse, is recorded.
+//
+// The connections of a dry-run are read-only sessions, so that a SELECT
+// which writes, e.g. via a data-modifying CTE, fails instead of executing.
+// Session variables which would lift that are therefore not read-only.
+func isReadOnlyStatement(sql string) bool {
+	stmts, err := parser.Parse(sql)
+	if err != nil {
+		return false
+	}
+	for _, stmt := range stmts {
+		switch s := stmt.AST.(type) {
+		case *tree.Select, *tree.ParenSelect, *tree.Explain:
+		case *tree.SetVar:
+			switch name := strings.ToLower(s.Name); {
+			case s.ResetAll, name == "default_transaction_read_only", name == "transaction_read_only":
+				return false
+			}
+		default:
+			if !strings.HasPrefix(stmt.AST.StatementTag(), "SHOW ") {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// dryRunConnector opens connections which execute read-only statements,
+// and record the other ones instead of executing them.
+type dryRunConnector struct {
+	dataSourceName string
+	drv            driver.Driver
+	record         func(stmt string)
+}
+
+var _ driver.Connector = &dryRunConnector{}
+
+// Connect implements the driver.Connector interface.
+func (c *dryRunConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.drv.Open(c.dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+	execer, ok := conn.(driver.ExecerContext)
+	if !ok {
+		_ = conn.Close()
+		return nil, errors.AssertionFailedf("%T doesn't implement driver.ExecerContext", conn)
+	}
+	if _, err := execer.ExecContext(ctx, "SET default_transaction_read_only = true", nil); err != nil {
+		_ = conn.Close()
+		return nil, errors.Wrap(err, "making the session read-only")
+	}
+	return &dryRunConn{Conn: conn, record: c.record}, nil
+}
+
+// Driver implements the driver.Connector interface.
+func (c *dryRunConnector) Driver() driver.Driver {
+	return c.drv
+}
+
+type dryRunConn struct {
+	driver.Conn
+	record func(stmt string)
+}
+
+var _ driver.ExecerContext = &dryRunConn{}
+var _ driver.QueryerContext = &dryRunConn{}
+
+func describeStatement(query string, args []driver.NamedValue) string {
+	if len(args) == 0 {
+		return query
+	}
+	vals := make([]string, len(args))
+	for i, a := range args {
+		vals[i] = fmt.Sprint(a.Value)
+	}
+	return fmt.Sprintf("%s [args: %s]", query, strings.Join(vals, ", "))
+}
+
+// Prepare implements the driver.Conn interface.
+func (c *dryRunConn) Prepare(query string) (driver.Stmt, error) {
+	if isReadOnlyStatement(query) {
+		return c.Conn.Prepare(query)
+	}
+	return &dryRunStmt{query: query, record: c.record}, nil
+}
+
+// ExecContext implements the driver.ExecerContext interface.
+func (c *dryRunConn) ExecContext(
+	ctx context.Context, query string, args []driver.NamedValue,
+) (driver.Result, error) {
+	if execer, ok := c.Conn.(driver.ExecerContext); ok && isReadOnlyStatement(query) {
+		return execer.ExecContext(ctx, query, args)
+	}
+	c.record(describeStatement(query, args))
+	return driver.RowsAffected(0), nil
+}
+
+// QueryContext implements the driver.QueryerContext interface.
+func (c *dryRunConn) QueryContext(
+	ctx context.Context, query string, args []driver.NamedValue,
+) (driver.Rows, error) {
+	if queryer, ok := c.Conn.(driver.QueryerContext); ok && isReadOnlyStatement(query) {
+		return queryer.QueryContext(ctx, query, args)
+	}
+	c.record(describeStatement(query, args))
+	return emptyRows{}, nil
+}
+
+// dryRunStmt is a prepared statement which is recorded instead of executed.
+type dryRunStmt struct {
+	query  string
+	record func(stmt string)
+}
+
+var _ driver.Stmt = &dryRunStmt{}
+
+func (s *dryRunStmt) Close() error  { return nil }
+func (s *dryRunStmt) NumInput() int { return -1 }
+
+func (s *dryRunStmt) describe(args []driver.Value) string {
+	named := make([]driver.NamedValue, len(args))
+	for i, a := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: a}
+	}
+	return describeStatement(s.query, named)
+}
+
+func (s *dryRunStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.record(s.describe(args))
+	return driver.RowsAffected(0), nil
+}
+
+func (s *dryRunStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.record(s.describe(args))
+	return emptyRows{}, nil
+}
+
+// emptyRows is the result of the statements which are not executed.
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string              { return nil }
+func (emptyRows) Close() error                   { return nil }
+func (emptyRows) Next(dest []driver.Value) error { return io.EOF }
@@ -0,0 +1,55 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/option"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsReadOnlyStatement(t *testing.T) {
+	for stmt, expected := range map[string]bool{
+		"SELECT database_name FROM [SHOW DATABASES]": true,
+		"show tables":                               true,
+		"USE tpcc":                                  true,
+		"SET statement_timeout = '10s'":             true,
+		"EXPLAIN SELECT 1":                          true,
+		"SET CLUSTER SETTING foo = 'bar'":           false,
+		"EXPLAIN ANALYZE SELECT 1":                  false,
+		"CREATE INDEX foo ON bar (baz)":             false,
+		"  drop database foo CASCADE":               false,
+		"BACKUP TABLE foo INTO 'nodelocal://1/foo'": false,
+		"SELECT 1; DROP TABLE foo":                  false,
+		"/* SELECT */ DELETE FROM foo":              false,
+		"SET default_transaction_read_only = false": false,
+		"SHOW JOBS":                                 true,
+		"not even sql":                              false,
+	} {
+		require.Equal(t, expected, isReadOnlyStatement(stmt), stmt)
+	}
+}
+
+func TestDryRunCluster(t *testing.T) {
+	ctx := context.Background()
+	c := newDryRunCluster(&dynamicClusterImpl{&clusterImpl{name: "foo"}}, nilLogger())
+
+	c.Run(ctx, option.WithNodes(c.Node(2)), "./cockroach.sh")
+	c.Stop(ctx, c.l, option.DefaultStopOpts(), c.Node(1))
+	require.NoError(t, c.Grow(ctx, c.l, 3))
+	require.Equal(t, []string{
+		`run "./cockroach.sh" on foo:2`,
+		"stop cockroach on foo:1 (signal 9, wait false)",
+		"grow the cluster by 3 nodes",
+	}, c.steps())
+}
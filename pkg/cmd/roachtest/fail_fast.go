@@ -0,0 +1,95 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+)
+
+// failFast stops scheduling the runs of the tests which failed, or of all the
+// tests once one failed, and keeps track of the runs it skipped. See
+// --fail-fast and --fail-fast-run.
+type failFast struct {
+	// test is set if the remaining runs of a failed test are skipped.
+	test bool
+	// run is set if the remaining runs of all the tests are skipped once a
+	// test failed.
+	run bool
+	mu  struct {
+		syncutil.Mutex
+		// failed is the first failed test run, if run is set.
+		failed string
+		// skipped is the number of skipped runs of each test.
+		skipped map[string]int
+	}
+}
+
+func newFailFast(test, run bool) *failFast {
+	f := &failFast{test: test || run, run: run}
+	f.mu.skipped = make(map[string]int)
+	return f
+}
+
+// failed is called once the given run of a test failed for good, i.e. it won't
+// be retried or requeued. It removes the runs which won't be scheduled anymore
+// from the work pool, and returns their number.
+func (f *failFast) failed(work *workPool, name string, runNum int) int {
+	if !f.test {
+		return 0
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	tests := []string{name}
+	if f.run {
+		if f.mu.failed == "" {
+			f.mu.failed = fmt.Sprintf("%s (run %d)", name, runNum)
+		}
+		for _, t := range work.workRemaining() {
+			tests = append(tests, t.spec.Name)
+		}
+	}
+	var skipped int
+	for _, t := range tests {
+		if n := work.skipTest(t); n > 0 {
+			f.mu.skipped[t] += n
+			skipped += n
+		}
+	}
+	return skipped
+}
+
+// summary returns the runs which were skipped, or an empty string if there are
+// none.
+func (f *failFast) summary() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.mu.skipped) == 0 {
+		return ""
+	}
+	var total int
+	tests := make([]string, 0, len(f.mu.skipped))
+	for t, n := range f.mu.skipped {
+		total += n
+		tests = append(tests, fmt.Sprintf("%s (%d runs)", t, n))
+	}
+	sort.Strings(tests)
+	if f.mu.failed != "" {
+		return fmt.Sprintf("%d test runs not run, as %s failed and --fail-fast-run is set:\n%s",
+			total, f.mu.failed, strings.Join(tests, "\n"))
+	}
+	return fmt.Sprintf("%d test runs not run, as earlier runs of their test failed and --fail-fast is set:\n%s",
+		total, strings.Join(tests, "\n"))
+}
@@ -0,0 +1,51 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFailFast(t *testing.T) {
+	specs := []registry.TestSpec{{Name: "a"}, {Name: "b"}, {Name: "c"}}
+
+	t.Run("off", func(t *testing.T) {
+		work := newWorkPool(specs, 3 /* count */)
+		f := newFailFast(false /* test */, false /* run */)
+		require.Zero(t, f.failed(work, "a", 1))
+		require.Equal(t, 9, work.runsRemaining())
+		require.Empty(t, f.summary())
+	})
+
+	t.Run("test", func(t *testing.T) {
+		work := newWorkPool(specs, 3 /* count */)
+		f := newFailFast(true /* test */, false /* run */)
+		require.Equal(t, 3, f.failed(work, "a", 1))
+		require.Equal(t, 6, work.runsRemaining())
+		// The test was already removed from the pool.
+		require.Zero(t, f.failed(work, "a", 2))
+		require.Equal(t, "3 test runs not run, as earlier runs of their test failed and --fail-fast is set:\n"+
+			"a (3 runs)", f.summary())
+	})
+
+	t.Run("run", func(t *testing.T) {
+		work := newWorkPool(specs, 2 /* count */)
+		f := newFailFast(false /* test */, true /* run */)
+		require.Equal(t, 6, f.failed(work, "b", 1))
+		require.Zero(t, work.runsRemaining())
+		require.Zero(t, f.failed(work, "c", 1))
+		require.Equal(t, "6 test runs not run, as b (run 1) failed and --fail-fast-run is set:\n"+
+			"a (2 runs)\nb (2 runs)\nc (2 runs)", f.summary())
+	})
+}
@@ -0,0 +1,123 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"regexp"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// failureCategory is a coarse classification of a test failure, reported in
+// GitHub issues.
+type failureCategory string
+
+const (
+	infraFlakeFailure failureCategory = "infra-flake"
+	timeoutFailure    failureCategory = "timeout"
+	oomFailure        failureCategory = "oom"
+	diskFullFailure   failureCategory = "disk-full"
+	panicFailure      failureCategory = "panic"
+	assertionFailure  failureCategory = "assertion"
+)
+
+var (
+	timeoutFailureRE = regexp.MustCompile(`test timed out`)
+	oomFailureRE     = regexp.MustCompile(`(?i)\bout of memory\b|\boom[- ]?kill|exit status 137`)
+)
+
+// categorizeFailures returns the category of the given failures. Infra flakes
+// are identified by the caller (see failuresAsErrorWithOwnership), and node
+// deaths by the monitor (see nodeDeathError); other failures are categorized
+// by their messages.
+func categorizeFailures(failures []failure, infraFlake bool) failureCategory {
+	if infraFlake {
+		return infraFlakeFailure
+	}
+	if cause, ok := nodeDeathCauseOf(failures); ok {
+		switch cause {
+		case nodeDeathOOMKill:
+			return oomFailure
+		case nodeDeathDiskFull:
+			return diskFullFailure
+		case nodeDeathPanic:
+			return panicFailure
+		}
+	}
+	for _, f := range failures {
+		if f.squashedErr != nil && timeoutFailureRE.MatchString(f.squashedErr.Error()) {
+			return timeoutFailure
+		}
+	}
+	for _, f := range failures {
+		if f.squashedErr != nil && oomFailureRE.MatchString(f.squashedErr.Error()) {
+			return oomFailure
+		}
+	}
+	return assertionFailure
+}
+
+// fingerprintNormalizers replace the parts of failure messages that typically
+// vary from one occurrence of a failure to the next.
+var fingerprintNormalizers = []struct {
+	re   *regexp.Regexp
+	repl string
+}{
+	{regexp.MustCompile(`[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}`), "<uuid>"},
+	{regexp.MustCompile(`0x[0-9a-fA-F]+`), "<hex>"},
+	{regexp.MustCompile(`[0-9]+`), "<n>"},
+	{regexp.MustCompile(`\s+`), " "},
+}
+
+// failureFingerprint returns a fingerprint of the first of the given failures,
+// computed from the category, the normalized first line of its message and the
+// function that reported it, as well as the name of the test, so that the
+// failures of unrelated tests are never matched. The function reporting infra
+// flakes varies with the step of the test that hit them, so it is only part
+// of the fingerprint of other failures. It returns the empty string if there
+// are no failures.
+func failureFingerprint(testName string, category failureCategory, failures []failure) string {
+	var err error
+	for _, f := range failures {
+		if err = f.squashedErr; err == nil && len(f.errors) > 0 {
+			err = f.errors[0]
+		}
+		if err != nil {
+			break
+		}
+	}
+	if err == nil {
+		return ""
+	}
+
+	msg, _, _ := strings.Cut(err.Error(), "\n")
+	for _, n := range fingerprintNormalizers {
+		msg = n.re.ReplaceAllString(msg, n.repl)
+	}
+	var site string
+	if st := errors.GetReportableStackTrace(err); st != nil && len(st.Frames) > 0 {
+		// Frames are ordered from the outermost to the innermost call.
+		site = st.Frames[len(st.Frames)-1].Function
+	}
+	if category == infraFlakeFailure {
+		site = ""
+	}
+
+	h := sha1.New()
+	for _, s := range []string{string(category), testName, site, strings.TrimSpace(msg)} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return "roachtest-fp-" + hex.EncodeToString(h.Sum(nil))[:12]
+}
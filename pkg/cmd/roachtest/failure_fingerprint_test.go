@@ -0,0 +1,60 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCategorizeFailures(t *testing.T) {
+	fail := func(msg string) []failure {
+		return []failure{{squashedErr: errors.New(msg)}}
+	}
+	require.Equal(t, infraFlakeFailure, categorizeFailures(fail("test timed out (1h)"), true /* infraFlake */))
+	require.Equal(t, timeoutFailure, categorizeFailures(fail("test timed out (1h)"), false /* infraFlake */))
+	require.Equal(t, oomFailure, categorizeFailures(fail("COMMAND_PROBLEM: exit status 137"), false /* infraFlake */))
+	require.Equal(t, oomFailure, categorizeFailures(fail("runtime: out of memory"), false /* infraFlake */))
+	require.Equal(t, assertionFailure, categorizeFailures(fail("expected 3 rows, got 2"), false /* infraFlake */))
+
+	death := func(cause nodeDeathCause) []failure {
+		err := errors.Wrap(&nodeDeathError{event: "n1: cockroach process died", cause: cause}, "monitor failure")
+		return []failure{{squashedErr: err, errors: []error{err}}}
+	}
+	require.Equal(t, oomFailure, categorizeFailures(death(nodeDeathOOMKill), false /* infraFlake */))
+	require.Equal(t, diskFullFailure, categorizeFailures(death(nodeDeathDiskFull), false /* infraFlake */))
+	require.Equal(t, panicFailure, categorizeFailures(death(nodeDeathPanic), false /* infraFlake */))
+	require.Equal(t, assertionFailure, categorizeFailures(death(nodeDeathUnknown), false /* infraFlake */))
+}
+
+func TestFailureFingerprint(t *testing.T) {
+	fingerprint := func(testName, msg string, category failureCategory) string {
+		// The failures are created on the same line so that their stacks match.
+		return failureFingerprint(testName, category, []failure{{squashedErr: errors.New(msg)}})
+	}
+	fp := fingerprint("foo", "n3 at 10.0.0.1: expected 3 rows, got 2", assertionFailure)
+	require.Regexp(t, `^roachtest-fp-[0-9a-f]{12}$`, fp)
+
+	// Numbers are ignored, and so is anything after the first line.
+	require.Equal(t, fp, fingerprint("foo", "n1 at 10.0.0.7: expected 5 rows, got 4\nmore details", assertionFailure))
+	require.NotEqual(t, fp, fingerprint("foo", "n3 at 10.0.0.1: expected 3 rows, got none", assertionFailure))
+	require.NotEqual(t, fp, fingerprint("bar", "n3 at 10.0.0.1: expected 3 rows, got 2", assertionFailure))
+	require.NotEqual(t, fp, fingerprint("foo", "n3 at 10.0.0.1: expected 3 rows, got 2", timeoutFailure))
+
+	// Infra flakes of different tests are not matched.
+	require.NotEqual(t,
+		fingerprint("foo", "ssh: connection refused", infraFlakeFailure),
+		fingerprint("bar", "ssh: connection refused", infraFlakeFailure))
+
+	require.Empty(t, failureFingerprint("foo", assertionFailure, nil))
+}
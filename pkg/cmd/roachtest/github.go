@@ -21,7 +21,6 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/roachtestflags"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/spec"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/test"
-	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/tests"
 	"github.com/cockroachdb/cockroach/pkg/internal/team"
 	rperrors "github.com/cockroachdb/cockroach/pkg/roachprod/errors"
 	"github.com/cockroachdb/cockroach/pkg/roachprod/logger"
@@ -162,6 +161,7 @@ func (g *githubIssues) createPostRequest(
 	message string,
 	metamorphicBuild bool,
 	coverageBuild bool,
+	flaky bool,
 ) (issues.PostRequest, error) {
 	var mention []string
 	var projColID int
@@ -200,6 +200,7 @@ func (g *githubIssues) createPostRequest(
 	const infraFlakeLabel = "X-infra-flake"
 	const metamorphicLabel = "B-metamorphic-enabled"
 	const coverageLabel = "B-coverage-enabled"
+	const flakyLabel = "X-flaky-test"
 	labels := []string{"O-roachtest"}
 	if infraFlake {
 		labels = append(labels, infraFlakeLabel)
@@ -217,9 +218,22 @@ func (g *githubIssues) createPostRequest(
 		if coverageBuild {
 			labels = append(labels, coverageLabel)
 		}
+		if flaky {
+			labels = append(labels, flakyLabel)
+		}
+		// Label the issues of tests in which a node died, by the cause of its
+		// death, e.g. X-node-oom-kill.
+		if cause, ok := nodeDeathCauseOf(failures); ok && cause != nodeDeathUnknown {
+			labels = append(labels, "X-node-"+string(cause))
+		}
 	}
 	labels = append(labels, spec.ExtraLabels...)
 
+	// The fingerprint allows failures to be matched with existing issues even
+	// if their titles differ.
+	category := categorizeFailures(failures, infraFlake)
+	fingerprint := failureFingerprint(testName, category, failures)
+
 	teams, err := g.teamLoader()
 	if err != nil {
 		return issues.PostRequest{}, err
@@ -251,6 +265,7 @@ func (g *githubIssues) createPostRequest(
 		roachtestPrefix("ssd"):              fmt.Sprintf("%d", spec.Cluster.SSDs),
 		roachtestPrefix("metamorphicBuild"): fmt.Sprintf("%t", metamorphicBuild),
 		roachtestPrefix("coverageBuild"):    fmt.Sprintf("%t", coverageBuild),
+		roachtestPrefix("failureCategory"):  string(category),
 	}
 	// Emit CPU architecture only if it was specified; otherwise, it's captured below, assuming cluster was created.
 	if spec.Cluster.Arch != "" {
@@ -285,6 +300,11 @@ func (g *githubIssues) createPostRequest(
 				"there should be a similar issue without the "+coverageLabel+" label. If there isn't one, it is "+
 				"possible that this failure is related to the code coverage infrastructure or overhead.")
 	}
+	if flaky {
+		topLevelNotes = append(topLevelNotes,
+			"This test failed, but passed when it was retried on a fresh cluster. The failure may be "+
+				"a flake, but should be investigated nevertheless.")
+	}
 	if metamorphicBuild {
 		topLevelNotes = append(topLevelNotes,
 			"This build has metamorphic test constants enabled. If the same failure was hit in a "+
@@ -299,12 +319,13 @@ func (g *githubIssues) createPostRequest(
 		TestName:        issueName,
 		Labels:          labels,
 		// Keep issues separate unless the if these labels don't match.
-		AdoptIssueLabelMatchSet: []string{infraFlakeLabel, coverageLabel, metamorphicLabel},
+		AdoptIssueLabelMatchSet: []string{infraFlakeLabel, coverageLabel, metamorphicLabel, flakyLabel},
 		TopLevelNotes:           topLevelNotes,
 		Message:                 issueMessage,
 		Artifacts:               artifacts,
 		ExtraParams:             clusterParams,
 		HelpCommand:             generateHelpCommand(testName, issueClusterName, roachtestflags.Cloud, start, end),
+		Fingerprint:             fingerprint,
 	}, nil
 }
 
@@ -317,19 +338,28 @@ func (g *githubIssues) MaybePost(
 		return nil, nil
 	}
 
-	var metamorphicBuild bool
-	switch t.spec.CockroachBinary {
-	case registry.StandardCockroach:
-		metamorphicBuild = false
-	case registry.RuntimeAssertionsCockroach:
-		metamorphicBuild = true
-	default:
-		metamorphicBuild = tests.UsingRuntimeAssertions(t)
+	metamorphicBuild := t.usingRuntimeAssertions()
+	// If the test passed on a retry, the issue is filed for its first failed
+	// attempt.
+	start, end, failures := t.start, t.end, t.failures()
+	flaky := t.firstFailure != nil && !t.Failed()
+	if flaky {
+		f := t.firstFailure
+		start, end, failures = f.start, f.end, f.failures()
 	}
-	postRequest, err := g.createPostRequest(t.Name(), t.start, t.end, t.spec, t.failures(), message, metamorphicBuild, t.goCoverEnabled)
+	postRequest, err := g.createPostRequest(t.Name(), start, end, t.spec, failures, message, metamorphicBuild, t.goCoverEnabled, flaky)
 	if err != nil {
 		return nil, err
 	}
+	// Point the reader to the exact command that runs this test again with the
+	// same seed and metamorphic choices.
+	postRequest.ExtraParams[roachtestPrefix("seed")] = fmt.Sprintf("%d", t.seed)
+	reproCommand := issues.ReproductionCommandFromString(t.reproCommand(g.cluster))
+	helpCommand := postRequest.HelpCommand
+	postRequest.HelpCommand = func(r *issues.Renderer) {
+		reproCommand(r)
+		helpCommand(r)
+	}
 	opts := issues.DefaultOptionsFromEnv()
 
 	return g.issuePoster(
@@ -169,6 +169,7 @@ func TestCreatePostRequest(t *testing.T) {
 				"localSSD":         "false",
 				"metamorphicBuild": "false",
 				"coverageBuild":    "false",
+				"failureCategory":  "assertion",
 			}),
 		},
 		// 2.
@@ -194,6 +195,7 @@ func TestCreatePostRequest(t *testing.T) {
 				"localSSD":         "true",
 				"metamorphicBuild": "true",
 				"coverageBuild":    "false",
+				"failureCategory":  "infra-flake",
 			}),
 		},
 		// 3. Assert that release-blocker label doesn't exist when
@@ -214,6 +216,7 @@ func TestCreatePostRequest(t *testing.T) {
 				"cpu":              "4",
 				"metamorphicBuild": "false",
 				"coverageBuild":    "false",
+				"failureCategory":  "infra-flake",
 			}),
 		},
 		// 4. Simulate failure loading TEAMS.yaml
@@ -251,6 +254,7 @@ func TestCreatePostRequest(t *testing.T) {
 				"localSSD":         "false",
 				"metamorphicBuild": "false",
 				"coverageBuild":    "false",
+				"failureCategory":  "assertion",
 			}),
 		},
 		// 7. Verify that release-blocker label is not applied on metamorphic builds
@@ -272,6 +276,7 @@ func TestCreatePostRequest(t *testing.T) {
 				"localSSD":         "false",
 				"metamorphicBuild": "true",
 				"coverageBuild":    "false",
+				"failureCategory":  "assertion",
 			}),
 		},
 		// 8. Verify that release-blocker label is not applied on coverage builds (for
@@ -294,6 +299,7 @@ func TestCreatePostRequest(t *testing.T) {
 				"localSSD":         "false",
 				"metamorphicBuild": "false",
 				"coverageBuild":    "true",
+				"failureCategory":  "assertion",
 			}),
 		},
 		// 9. Verify preemption failure are routed to test-eng and marked as infra-flake, when the
@@ -435,7 +441,7 @@ func TestCreatePostRequest(t *testing.T) {
 
 			req, err := github.createPostRequest(
 				testName, ti.start, ti.end, testSpec, testCase.failures,
-				testCase.message, testCase.metamorphicBuild, testCase.coverageBuild,
+				testCase.message, testCase.metamorphicBuild, testCase.coverageBuild, false, /* flaky */
 			)
 			if testCase.loadTeamsFailed {
 				// Assert that if TEAMS.yaml cannot be loaded then function errors.
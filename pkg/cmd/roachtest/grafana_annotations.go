@@ -0,0 +1,74 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachprod/grafana"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/option"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/logger"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+)
+
+// workloadPhaseRE matches workload commands, capturing the phase (e.g. run)
+// and the workload (e.g. kv).
+var workloadPhaseRE = regexp.MustCompile(`\bworkload\s+(init|run|fixtures\s+(?:import|load|make))\s+([\w-]+)`)
+
+// workloadPhase returns a description of the workload phase run by the given
+// command, e.g. "workload run kv", or false if it doesn't run a workload.
+func workloadPhase(cmd string) (string, bool) {
+	m := workloadPhaseRE.FindStringSubmatch(cmd)
+	if m == nil {
+		return "", false
+	}
+	return fmt.Sprintf("workload %s %s", strings.Join(strings.Fields(m[1]), " "), m[2]), true
+}
+
+// grafanaAvailable returns whether annotations can be added for the cluster,
+// either because grafana is set up for the test run or because the test
+// started its own instance.
+func (c *clusterImpl) grafanaAvailable() bool {
+	return len(c.grafanaTags) > 0 || c.internalGrafanaStarted.Load()
+}
+
+// maybeAnnotateWorkloadPhase adds a grafana annotation over the time range of
+// the given command if it ran a workload, so that dashboards show the phases
+// of the test. Errors are logged.
+func (c *clusterImpl) maybeAnnotateWorkloadPhase(
+	ctx context.Context,
+	l *logger.Logger,
+	cmd string,
+	nodes option.NodeListOption,
+	start time.Time,
+	runErr error,
+) {
+	phase, ok := workloadPhase(cmd)
+	if !ok || !c.grafanaAvailable() || ctx.Err() != nil {
+		return
+	}
+	text := fmt.Sprintf("%s on %s%s", phase, c.name, nodes)
+	if runErr != nil {
+		text += " (failed)"
+	}
+	if err := c.AddGrafanaAnnotation(ctx, l, grafana.AddAnnotationRequest{
+		Text:      text,
+		Tags:      []string{"workload"},
+		StartTime: start.UnixMilli(),
+		EndTime:   timeutil.Now().UnixMilli(),
+	}); err != nil {
+		l.Printf("error adding grafana annotation for %s: %v", phase, err)
+	}
+}
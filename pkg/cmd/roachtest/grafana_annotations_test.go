@@ -0,0 +1,31 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkloadPhase(t *testing.T) {
+	for cmd, expected := range map[string]string{
+		"./cockroach workload run kv --duration=10m {pgurl:1-3}":        "workload run kv",
+		"./workload init tpcc --warehouses=100 {pgurl:1}":               "workload init tpcc",
+		"./cockroach workload fixtures import tpcc --warehouses=1000":   "workload fixtures import tpcc",
+		"./cockroach workload run querybench --query-file=tpch-queries": "workload run querybench",
+		"./cockroach sql --url={pgurl:1} -e 'SELECT 1'":                 "",
+	} {
+		phase, ok := workloadPhase(cmd)
+		require.Equal(t, expected != "", ok, cmd)
+		require.Equal(t, expected, phase, cmd)
+	}
+}
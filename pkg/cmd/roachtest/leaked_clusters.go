@@ -0,0 +1,172 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
+	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+	"github.com/cockroachdb/cockroach/pkg/roachprod"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/logger"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/vm"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+)
+
+// leakedClusterDetector finds the clusters labeled with the ID of the run
+// which the run doesn't know about anymore, i.e. which should have been
+// destroyed, and reports them to stdout and as a Datadog event. See
+// --leaked-cluster-check-interval.
+type leakedClusterDetector struct {
+	cr     *clusterRegistry
+	stdout io.Writer
+	// events is nil if the runner isn't configured to communicate with Datadog.
+	events *datadogV1.EventsApi
+	tags   []string
+	// list returns the names of the live clusters labeled with the ID of the
+	// run.
+	list func(l *logger.Logger) ([]string, error)
+
+	// suspects are the clusters which were not registered on the previous
+	// check. A cluster is only reported by the periodic checks once it's
+	// unregistered on two of them in a row, as it's labeled before it's
+	// registered when created.
+	suspects map[string]struct{}
+	// reported are the clusters which were already reported.
+	reported map[string]struct{}
+}
+
+func newLeakedClusterDetector(
+	ctx context.Context, cr *clusterRegistry, stdout io.Writer,
+) *leakedClusterDetector {
+	d := &leakedClusterDetector{
+		cr:       cr,
+		stdout:   stdout,
+		tags:     getDatadogTags(),
+		list:     listRunClusters,
+		suspects: make(map[string]struct{}),
+		reported: make(map[string]struct{}),
+	}
+	if hasDatadogContext(ctx) {
+		d.events = datadogV1.NewEventsApi(datadog.NewAPIClient(datadog.NewConfiguration()))
+	}
+	return d
+}
+
+// listRunClusters returns the names of the live clusters labeled with the ID
+// of the run.
+func listRunClusters(l *logger.Logger) ([]string, error) {
+	cld, err := roachprod.List(l, false /* listMine */, "" /* clusterNamePattern */, vm.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	label := vm.SanitizeLabel(runID)
+	var names []string
+	for name, c := range cld.Clusters {
+		for _, v := range c.VMs {
+			if v.Labels[VmLabelTestRunID] == label {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// run checks for leaked clusters at the given interval until the context is
+// canceled or done is closed.
+func (d *leakedClusterDetector) run(
+	ctx context.Context, l *logger.Logger, interval time.Duration, done <-chan struct{},
+) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-done:
+			return
+		case <-ticker.C:
+			d.check(ctx, l, false /* final */)
+		}
+	}
+}
+
+// check reports the clusters leaked so far, and returns them. The final check
+// runs once the run destroyed its clusters, and reports all the labeled
+// clusters which weren't saved for debugging.
+func (d *leakedClusterDetector) check(ctx context.Context, l *logger.Logger, final bool) []string {
+	names, err := d.list(l)
+	if err != nil {
+		l.PrintfCtx(ctx, "failed to list the clusters of the run to check for leaked clusters: %s", err)
+		return nil
+	}
+	suspects := make(map[string]struct{})
+	var leaked []string
+	for _, name := range names {
+		if _, ok := d.reported[name]; ok || d.cr.isSaved(name) {
+			continue
+		}
+		if !final {
+			if d.cr.isRegistered(name) {
+				continue
+			}
+			suspects[name] = struct{}{}
+			if _, ok := d.suspects[name]; !ok {
+				continue
+			}
+		}
+		d.reported[name] = struct{}{}
+		leaked = append(leaked, name)
+	}
+	d.suspects = suspects
+	if len(leaked) > 0 {
+		d.report(ctx, l, leaked)
+	}
+	return leaked
+}
+
+func (d *leakedClusterDetector) report(ctx context.Context, l *logger.Logger, leaked []string) {
+	title := fmt.Sprintf("roachtest run %s leaked %d clusters", runID, len(leaked))
+	shout(ctx, l, d.stdout, "%s, which should have been destroyed: %s",
+		title, strings.Join(leaked, ", "))
+	if d.events == nil {
+		return
+	}
+	var b strings.Builder
+	b.WriteString("%%% \n")
+	fmt.Fprintf(&b, "**Run:** `%s`  \n", runID)
+	for _, name := range leaked {
+		fmt.Fprintf(&b, "- `%s`\n", name)
+	}
+	b.WriteString("\n %%%")
+	alertType := datadogV1.EVENTALERTTYPE_WARNING
+	hostname, _ := os.Hostname()
+	if _, _, err := d.events.CreateEvent(ctx, datadogV1.EventCreateRequest{
+		AggregationKey: datadog.PtrString(fmt.Sprintf("leaked-clusters-%s", runID)),
+		AlertType:      &alertType,
+		DateHappened:   datadog.PtrInt64(timeutil.Now().Unix()),
+		Host:           &hostname,
+		SourceTypeName: datadog.PtrString("roachtest"),
+		Tags:           append(append([]string(nil), d.tags...), fmt.Sprintf("test-run-id:%s", vm.SanitizeLabel(runID))),
+		Text:           b.String(),
+		Title:          title,
+	}); err != nil {
+		l.PrintfCtx(ctx, "failed to send the leaked clusters event to Datadog: %s", err)
+	}
+}
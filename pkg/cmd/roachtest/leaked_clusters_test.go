@@ -0,0 +1,50 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/roachprod/logger"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLeakedClusterDetector(t *testing.T) {
+	ctx := context.Background()
+	cr := newClusterRegistry()
+	registered := &clusterImpl{name: "registered"}
+	saved := &clusterImpl{name: "saved"}
+	cr.mu.clusters[registered.name] = registered
+	cr.mu.clusters[saved.name] = saved
+	cr.markClusterAsSaved(saved, "failed")
+
+	live := []string{"creating", "registered", "saved"}
+	d := newLeakedClusterDetector(ctx, cr, io.Discard)
+	d.list = func(*logger.Logger) ([]string, error) { return live, nil }
+
+	// A cluster which isn't registered yet is only reported if it still isn't
+	// on the next check.
+	require.Empty(t, d.check(ctx, nilLogger(), false /* final */))
+	cr.mu.clusters["creating"] = &clusterImpl{name: "creating"}
+	require.Empty(t, d.check(ctx, nilLogger(), false /* final */))
+
+	// Once unregistered, the cluster is reported on the second check, and
+	// only once.
+	delete(cr.mu.clusters, "registered")
+	require.Empty(t, d.check(ctx, nilLogger(), false /* final */))
+	require.Equal(t, []string{"registered"}, d.check(ctx, nilLogger(), false /* final */))
+	require.Empty(t, d.check(ctx, nilLogger(), false /* final */))
+
+	// The final check reports all the remaining clusters which weren't saved.
+	require.Equal(t, []string{"creating"}, d.check(ctx, nilLogger(), true /* final */))
+}
@@ -25,10 +25,12 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/operations"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/roachtestflags"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/spec"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/tests"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/testselector"
 	"github.com/cockroachdb/cockroach/pkg/roachprod"
 	"github.com/cockroachdb/cockroach/pkg/roachprod/config"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
 	"github.com/cockroachdb/errors"
 	_ "github.com/lib/pq" // register postgres driver
 	"github.com/spf13/cobra"
@@ -86,8 +88,14 @@ Tests are restricted by the specified --cloud (gce by default). Use
 --cloud=all to show tests for all clouds.
 
 Use --bench to restrict to benchmarks.
-Use --suite to restrict to tests that are part of the given suite.
+Use --suite to restrict to tests that are part of the given suite; if several
+suites are given, tests that are part of any of them are listed.
 Use --owner to restrict to tests that have the given owner.
+Use --filter to restrict to tests matching a boolean expression over the
+suites, owner, clouds and name of the tests; the terms suite:<suite>,
+owner:<owner>, cloud:<cloud>, name:<regex> and benchmark can be combined with
+!, &&, || and parentheses. If --filter is repeated, tests matching any of the
+expressions are listed, once.
 
 If patterns are specified, only tests that match either of the given patterns
 are listed.
@@ -100,6 +108,15 @@ Examples:
 
    # match weekly kv owned tests
    roachtest list --suite weekly --owner kv
+
+   # match nightly or weekly kv owned tests which aren't benchmarks
+   roachtest list --filter '(suite:nightly || suite:weekly) && owner:kv && !benchmark'
+
+   # match nightly or weekly tests
+   roachtest list --suite nightly,weekly
+
+   # match nightly kv owned tests, and benchmarks which match tpch
+   roachtest list --filter 'suite:nightly && owner:kv' --filter 'benchmark && name:tpch'
 `,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			r := makeTestRegistry()
@@ -121,7 +138,10 @@ Examples:
 			for _, s := range specs {
 				var skip string
 				if s.Skip != "" {
-					skip = " (skipped: " + s.Skip + ")"
+					skip = " (skipped: " + s.SkipReason() + ")"
+					if s.SkipExpired(timeutil.Now()) {
+						skip += " [stale skip]"
+					}
 				}
 				fmt.Printf("%s [%s]%s\n", s.Name, s.Owner, skip)
 			}
@@ -138,7 +158,7 @@ Examples:
 		Long: `Run automated tests on existing or ephemeral cockroach clusters.
 
 roachtest run takes a list of regex patterns and runs tests matching the tests
-as well as the --cloud, --suite, --owner flags. See "help list" for more details
+as well as the --cloud, --suite, --owner, --filter flags. See "help list" for more details
 on specifying tests.
 
 If all invoked tests passed, the exit status is zero. If at least one test
@@ -168,12 +188,20 @@ the cluster nodes on start.
 		SilenceUsage: true,
 		Use:          "bench [regex...]",
 		Short:        "run automated benchmarks on cockroach cluster",
-		Long:         `Run automated benchmarks on existing or ephemeral cockroach clusters.`,
+		Long: `Run automated benchmarks on existing or ephemeral cockroach clusters.
+
+The histograms in the perf artifacts of the benchmarks are converted into the
+OpenMetrics format (unless --openmetrics=false), and can be uploaded to the
+roachperf bucket directly with --roachperf-upload.
+`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if err := initRunFlagsBinariesAndLibraries(cmd); err != nil {
 				return err
 			}
 			roachtestflags.OnlyBenchmarks = true
+			if roachtestflags.Changed(&roachtestflags.ExportOpenMetrics) == nil {
+				roachtestflags.ExportOpenMetrics = true
+			}
 			filter, err := makeTestFilter(args)
 			if err != nil {
 				return err
@@ -185,6 +213,45 @@ the cluster nodes on start.
 	}
 	roachtestflags.AddRunFlags(benchCmd.Flags())
 
+	var rerunFailedCmd = &cobra.Command{
+		// Don't display usage when tests fail.
+		SilenceUsage: true,
+		Use:          "rerun-failed",
+		Short:        "run the tests which failed in a previous run again",
+		Long: `Run the tests which failed in a previous run again.
+
+The previous run is the one whose artifacts are in the directory given by
+--artifacts. Its failed tests are found in its checkpoint (see --resume-from),
+and are run with the same flags and --global-seed, so that they make the same
+random choices. Flags passed to rerun-failed take precedence over the ones of
+the previous run. The artifacts of the new run are written to a
+rerun_<timestamp> subdirectory of the previous run's artifacts directory.
+`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			prevDir := roachtestflags.ArtifactsDir
+			failed, err := prepareRerunFailed(prevDir)
+			if err != nil {
+				return err
+			}
+			if len(failed) == 0 {
+				fmt.Printf("no failed tests in %s; nothing to rerun\n", prevDir)
+				return nil
+			}
+			if err := initRunFlagsBinariesAndLibraries(cmd); err != nil {
+				return err
+			}
+			filter, err := makeTestFilter([]string{exactNamesPattern(failed)})
+			if err != nil {
+				return err
+			}
+			fmt.Printf("\nRerunning %d failed tests of %s: %s.\n\n", len(failed), prevDir, filter.String())
+			cmd.SilenceUsage = true
+			return runTests(tests.RegisterTests, filter)
+		},
+	}
+	roachtestflags.AddRunFlags(rerunFailedCmd.Flags())
+
 	var runOperationCmd = &cobra.Command{
 		// Don't display usage when the command fails.
 		SilenceUsage: true,
@@ -193,7 +260,19 @@ the cluster nodes on start.
 		Long: `Run an automated operation on an existing roachprod cluster.
 If multiple operations are matched by the passed-in regex filter, one operation
 is chosen at random and run. The provided cluster name must already exist in roachprod;
-this command does no setup/teardown of clusters.`,
+this command does no setup/teardown of clusters. Only the operations compatible with
+--cloud are matched.
+
+The cluster can be a local roachprod cluster (e.g. "local"), in which case --cloud
+defaults to local, and --certs-dir to the certificates of its first node if it is
+secure. This is handy to develop and smoke-test operations without cloud access.
+
+With --schedule, the command instead runs as a long-running scheduler, running
+one of the matched operations, picked according to --operation-weight, on each
+tick of the schedule until interrupted.
+
+With --resume-cleanups, the command instead runs the pending cleanups of the
+matched operations on the cluster, i.e. the ones which didn't run or failed.`,
 		Args: cobra.ExactArgs(2),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			fmt.Printf("\nRunning operation %s on %s.\n\n", args[1], args[0])
@@ -206,6 +285,7 @@ this command does no setup/teardown of clusters.`,
 	rootCmd.AddCommand(listCmd)
 	rootCmd.AddCommand(runCmd)
 	rootCmd.AddCommand(benchCmd)
+	rootCmd.AddCommand(rerunFailedCmd)
 	rootCmd.AddCommand(runOperationCmd)
 
 	var err error
@@ -239,7 +319,7 @@ func testsToRun(
 	r testRegistryImpl,
 	filter *registry.TestFilter,
 	runSkipped bool,
-	selectProbability float64,
+	selectProbability roachtestflags.SelectProbabilities,
 	print bool,
 ) ([]registry.TestSpec, error) {
 	specs, hint := filter.FilterWithHint(r.AllTests())
@@ -253,7 +333,7 @@ func testsToRun(
 
 	// selective-tests is considered only if the select-probability is 1.0. This is because select probability already
 	// takes care of running limited tests.
-	if roachtestflags.SelectiveTests && roachtestflags.SelectProbability == 1.0 {
+	if roachtestflags.SelectiveTests && selectProbability.IsOne() {
 		fmt.Printf("selective Test enabled\n")
 		// the test categorization must be complete in 30 seconds
 		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -261,17 +341,22 @@ func testsToRun(
 		updateSpecForSelectiveTests(ctx, specs)
 	}
 
+	var ci ciReporter = noopCIReporter{}
+	if print {
+		var err error
+		if ci, err = ciReporterFromFlags(); err != nil {
+			return nil, err
+		}
+	}
+
 	var notSkipped []registry.TestSpec
 	for _, s := range specs {
 		if s.Skip == "" || runSkipped {
 			notSkipped = append(notSkipped, s)
 		} else {
-			if print && roachtestflags.TeamCity {
-				fmt.Fprintf(os.Stdout, "##teamcity[testIgnored name='%s' message='%s']\n",
-					s.Name, TeamCityEscape(s.Skip))
-			}
 			if print {
-				fmt.Fprintf(os.Stdout, "--- SKIP: %s (%s)\n\t%s\n", s.Name, "0.00s", s.Skip)
+				ci.testIgnored(printCIOutput, s.Name, s.SkipReason(), 0 /* duration */)
+				fmt.Fprintf(os.Stdout, "--- SKIP: %s (%s)\n\t%s\n", s.Name, "0.00s", s.SkipReason())
 			}
 		}
 	}
@@ -287,24 +372,59 @@ func testsToRun(
 			if matches, r := filter.Matches(&s); !matches {
 				reason := filter.MatchFailReasonString(r)
 				// This test matches the "relaxed" filter but not the original filter.
-				if roachtestflags.TeamCity {
-					fmt.Fprintf(os.Stdout, "##teamcity[testIgnored name='%s' message='%s']\n", s.Name, reason)
-				}
+				ci.testIgnored(printCIOutput, s.Name, reason, 0 /* duration */)
 				fmt.Fprintf(os.Stdout, "--- SKIP: %s (%s)\n\t%s\n", s.Name, "0.00s", reason)
 			}
 		}
 	}
 
-	return selectSpecs(notSkipped, selectProbability, true, print), nil
+	return selectSpecs(notSkipped, selectProbability, true, print, ci), nil
+}
+
+// staleSkips returns the given tests which are skipped past their
+// SkipExpiry.
+func staleSkips(specs []registry.TestSpec, now time.Time) []registry.TestSpec {
+	var stale []registry.TestSpec
+	for _, s := range specs {
+		if s.SkipExpired(now) {
+			stale = append(stale, s)
+		}
+	}
+	return stale
+}
+
+// staleSkipsSummary returns the summary of the given stale skips printed at
+// the end of a run, or an empty string if there are none.
+func staleSkipsSummary(stale []registry.TestSpec) string {
+	if len(stale) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d tests are skipped past their skip expiry; unskip them or extend the expiry:", len(stale))
+	for _, s := range stale {
+		fmt.Fprintf(&b, "\n\t%s [%s]: %s", s.Name, s.Owner, s.SkipReason())
+	}
+	return b.String()
 }
 
 // updateSpecForSelectiveTests is responsible for updating the test spec skip and skip details
 // based on the test categorization criteria.
 func updateSpecForSelectiveTests(ctx context.Context, specs []registry.TestSpec) {
+	// The tests are selected based on their history in a single suite.
+	var suite string
+	switch suites := roachtestflags.Suites; len(suites) {
+	case 0:
+	case 1:
+		suite = suites[0]
+	default:
+		fmt.Printf("running all tests! selective tests don't support multiple suites: %s\n",
+			strings.Join(suites, ","))
+		return
+	}
 	selectedTestsCount := 0
 	// run and select 35% of successful tests which gives a window of 3 days for all tests to run
 	selectedTests, err := testselector.CategoriseTests(ctx,
-		testselector.NewDefaultSelectTestsReq(35, roachtestflags.Cloud, roachtestflags.Suite))
+		testselector.NewDefaultSelectTestsReq(35, roachtestflags.Cloud, suite))
 	if err != nil {
 		fmt.Printf("running all tests! error selecting tests: %v\n", err)
 		return
@@ -314,7 +434,7 @@ func updateSpecForSelectiveTests(ctx context.Context, specs []registry.TestSpec)
 		tdMap[td.Name] = td
 	}
 	for i := range specs {
-		if testShouldBeSkipped(tdMap, specs[i], roachtestflags.Suite) {
+		if testShouldBeSkipped(tdMap, specs[i], suite) {
 			specs[i].Skip = "test selector"
 			specs[i].SkipDetails = "test skipped because it is stable and selective-tests is set."
 		} else {
@@ -323,6 +443,7 @@ func updateSpecForSelectiveTests(ctx context.Context, specs []registry.TestSpec)
 		if td, ok := tdMap[specs[i].Name]; ok {
 			// populate the stats as obtained from the test selector
 			specs[i].SetStats(td.AvgDurationInMillis, td.LastFailureIsPreempt)
+			specs[i].SetHistory(td.FailureRate, td.RecentlyAdded)
 		}
 	}
 	fmt.Printf("%d out of %d tests selected for the run!\n", selectedTestsCount, len(specs))
@@ -340,33 +461,70 @@ func testShouldBeSkipped(
 	return ok && test.Skip == "" && !td.Selected
 }
 
-func opsToRun(r testRegistryImpl, filter string) ([]registry.OperationSpec, error) {
+func opsToRun(
+	r testRegistryImpl, filter string, cloud spec.Cloud,
+) ([]registry.OperationSpec, error) {
 	regex, err := regexp.Compile(filter)
 	if err != nil {
 		return nil, err
 	}
 	var filteredOps []registry.OperationSpec
+	var incompatible int
 	for _, opSpec := range r.AllOperations() {
-		if regex.MatchString(opSpec.Name) {
-			filteredOps = append(filteredOps, opSpec)
+		if !regex.MatchString(opSpec.Name) {
+			continue
+		}
+		if !opSpec.CompatibleClouds.Contains(cloud) {
+			incompatible++
+			continue
 		}
+		filteredOps = append(filteredOps, opSpec)
 	}
 	if len(filteredOps) == 0 {
+		if incompatible > 0 {
+			return nil, errors.Newf("no matching operations to run on cloud %s; %d matching "+
+				"operations are incompatible with it", cloud, incompatible)
+		}
 		return nil, errors.New("no matching operations to run")
 	}
 	return filteredOps, nil
 }
 
-// selectSpecs returns a random sample of the given test specs.
+// testSelectProbability returns the probability of the given test being
+// selected to run: the one of its owner if it's overridden, else the highest
+// of the ones of its suites which are, else the default one.
+func testSelectProbability(p roachtestflags.SelectProbabilities, s *registry.TestSpec) float64 {
+	if v, ok := p.Owners[string(s.Owner)]; ok {
+		return v
+	}
+	v, overridden := p.Default, false
+	if s.Suites.IsInitialized() {
+		for suite, suiteV := range p.Suites {
+			if s.Suites.Contains(suite) && (!overridden || suiteV > v) {
+				v, overridden = suiteV, true
+			}
+		}
+	}
+	return v
+}
+
+// selectSpecs returns a random sample of the given test specs, each selected
+// with its probability; see testSelectProbability.
 // If atLeastOnePerPrefix is true, it guarantees that at least one test is
 // selected for each prefix (e.g. kv0/, acceptance/).
 // This assumes that specs are sorted by name, which is the case for
 // testRegistryImpl.AllTests().
+// If print is set, the tests which aren't selected are printed, and reported
+// to ci.
 // TODO(smg260): Perhaps expose `atLeastOnePerPrefix` via CLI
 func selectSpecs(
-	specs []registry.TestSpec, samplePct float64, atLeastOnePerPrefix bool, print bool,
+	specs []registry.TestSpec,
+	selectProbability roachtestflags.SelectProbabilities,
+	atLeastOnePerPrefix bool,
+	print bool,
+	ci ciReporter,
 ) []registry.TestSpec {
-	if samplePct == 1 || len(specs) == 0 {
+	if selectProbability.IsOne() || len(specs) == 0 {
 		return specs
 	}
 
@@ -397,7 +555,7 @@ func selectSpecs(
 			}
 		}
 
-		if rand.Float64() < samplePct {
+		if rand.Float64() < testSelectProbability(selectProbability, &s) {
 			sampled = append(sampled, s)
 			selectedIdxs = append(selectedIdxs, i)
 			prefixSelected = true
@@ -421,12 +579,8 @@ func selectSpecs(
 	for _, i := range selectedIdxs {
 		for j := p; j < i; j++ {
 			s := specs[j]
-			if print && roachtestflags.TeamCity {
-				fmt.Fprintf(os.Stdout, "##teamcity[testIgnored name='%s' message='excluded via sampling']\n",
-					s.Name)
-			}
-
 			if print {
+				ci.testIgnored(printCIOutput, s.Name, "excluded via sampling", 0 /* duration */)
 				fmt.Fprintf(os.Stdout, "--- SKIP: %s (%s)\n\texcluded via sampling\n", s.Name, "0.00s")
 			}
 		}
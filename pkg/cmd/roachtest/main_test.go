@@ -17,10 +17,13 @@ import (
 	"testing"
 
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/cluster"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/operation"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/roachtestflags"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/spec"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/test"
 	"github.com/cockroachdb/cockroach/pkg/internal/team"
+	"github.com/stretchr/testify/require"
 )
 
 func init() {
@@ -56,7 +59,7 @@ func TestSampleSpecs(t *testing.T) {
 
 	for _, f := range []float64{0.01, 0.5, 1.0} {
 		t.Run(fmt.Sprintf("Sample-%.3f", f), func(t *testing.T) {
-			specs, _ := testsToRun(r, filter, false /* runSkipped */, f /* selectProbability */, false /* print */)
+			specs, _ := testsToRun(r, filter, false /* runSkipped */, roachtestflags.SelectProbabilities{Default: f}, false /* print */)
 
 			matched := map[string]int{"abc": 0, "def": 0, "ghi": 0, "jkl": 0}
 			for _, s := range specs {
@@ -78,7 +81,7 @@ func TestSampleSpecs(t *testing.T) {
 	}
 	for _, f := range []float64{0.01, 0.5, 1.0} {
 		t.Run(fmt.Sprintf("Sample-abc-%.3f", f), func(t *testing.T) {
-			specs, _ := testsToRun(r, filter, false /* runSkipped */, f /* selectProbability */, false /* print */)
+			specs, _ := testsToRun(r, filter, false /* runSkipped */, roachtestflags.SelectProbabilities{Default: f}, false /* print */)
 
 			matched := map[string]int{"abc": 0, "def": 0, "ghi": 0, "jkl": 0}
 			for _, s := range specs {
@@ -98,3 +101,62 @@ func TestSampleSpecs(t *testing.T) {
 		})
 	}
 }
+
+func TestTestSelectProbability(t *testing.T) {
+	p, err := roachtestflags.ParseSelectProbabilities("0.5,nightly=0.3,weekly=0.8,owner:kv=0.1")
+	require.NoError(t, err)
+	nightly := &registry.TestSpec{Owner: OwnerUnitTest, Suites: registry.Suites(registry.Nightly)}
+	both := &registry.TestSpec{Owner: OwnerUnitTest, Suites: registry.Suites(registry.Nightly, registry.Weekly)}
+	orm := &registry.TestSpec{Owner: OwnerUnitTest, Suites: registry.Suites(registry.ORM)}
+	kv := &registry.TestSpec{Owner: registry.OwnerKV, Suites: registry.Suites(registry.Weekly)}
+	require.Equal(t, 0.3, testSelectProbability(p, nightly))
+	require.Equal(t, 0.8, testSelectProbability(p, both))
+	require.Equal(t, 0.5, testSelectProbability(p, orm))
+	require.Equal(t, 0.1, testSelectProbability(p, kv))
+
+	// A suite with a probability of 0 is not run, except for one test per
+	// prefix.
+	r := makeRegistry("abc/1", "abc/2", "abc/3", "def/1")
+	filter, err := registry.NewTestFilter([]string{})
+	require.NoError(t, err)
+	p, err = roachtestflags.ParseSelectProbabilities("nightly=0")
+	require.NoError(t, err)
+	specs, err := testsToRun(r, filter, false /* runSkipped */, p, false /* print */)
+	require.NoError(t, err)
+	require.Len(t, specs, 2)
+}
+
+func TestOpsToRun(t *testing.T) {
+	r := makeTestRegistry()
+	dummyRun := func(context.Context, operation.Operation, cluster.Cluster) registry.OperationCleanup {
+		return nil
+	}
+	r.AddOperation(registry.OperationSpec{
+		Name: "add-column", Owner: OwnerUnitTest, Run: dummyRun, CompatibleClouds: registry.AllClouds,
+	})
+	r.AddOperation(registry.OperationSpec{
+		Name: "disk-stall", Owner: OwnerUnitTest, Run: dummyRun, CompatibleClouds: registry.OnlyGCE,
+	})
+
+	names := func(specs []registry.OperationSpec) []string {
+		var res []string
+		for _, s := range specs {
+			res = append(res, s.Name)
+		}
+		return res
+	}
+
+	specs, err := opsToRun(r, ".*", spec.GCE)
+	require.NoError(t, err)
+	require.Equal(t, []string{"add-column", "disk-stall"}, names(specs))
+
+	specs, err = opsToRun(r, ".*", spec.Local)
+	require.NoError(t, err)
+	require.Equal(t, []string{"add-column"}, names(specs))
+
+	_, err = opsToRun(r, "disk-stall", spec.Local)
+	require.ErrorContains(t, err, "1 matching operations are incompatible")
+
+	_, err = opsToRun(r, "resize", spec.GCE)
+	require.ErrorContains(t, err, "no matching operations to run")
+}
@@ -0,0 +1,83 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"context"
+	"path/filepath"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/option"
+	"github.com/cockroachdb/cockroach/pkg/roachprod"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/logger"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/vm"
+	"github.com/cockroachdb/errors"
+)
+
+// nodesByArch groups the nodes selected by the given options, or all the nodes
+// if none are selected, by CPU architecture. All the nodes have the cluster's
+// architecture unless it is a mixed-architecture cluster.
+func (c *clusterImpl) nodesByArch(opts ...option.Option) map[vm.CPUArch]option.NodeListOption {
+	var nodes option.NodeListOption
+	for _, o := range opts {
+		if s, ok := o.(nodeSelector); ok {
+			nodes = s.Merge(nodes)
+		}
+	}
+	if len(nodes) == 0 {
+		nodes = c.All()
+	}
+	byArch := make(map[vm.CPUArch]option.NodeListOption)
+	for _, n := range nodes {
+		arch := c.NodeArchitecture(n)
+		byArch[arch] = append(byArch[arch], n)
+	}
+	return byArch
+}
+
+// binaryForArch returns the binary to use on nodes with the given architecture
+// in place of src. If src is one of the cockroach or workload binaries found
+// for the cluster's architecture, the corresponding binary for arch is
+// returned; other files, including binary overrides, are returned as is.
+func (c *clusterImpl) binaryForArch(src string, arch vm.CPUArch) (string, error) {
+	if arch == c.arch || src == "" {
+		return src, nil
+	}
+	for _, binaries := range []map[vm.CPUArch]string{cockroach, cockroachEA, workload} {
+		if binaries[c.arch] != src {
+			continue
+		}
+		if binaries[arch] == "" {
+			return "", errors.Errorf("no %s binary found for %q; required by mixed-architecture cluster", filepath.Base(src), arch)
+		}
+		return binaries[arch], nil
+	}
+	return src, nil
+}
+
+// putPerArch puts a local file to the selected nodes of a mixed-architecture
+// cluster, substituting binaries with those for each node's architecture.
+func (c *clusterImpl) putPerArch(
+	ctx context.Context, l *logger.Logger, src, dest string, opts ...option.Option,
+) error {
+	for arch, nodes := range c.nodesByArch(opts...) {
+		archSrc, err := c.binaryForArch(src, arch)
+		if err != nil {
+			return err
+		}
+		if archSrc != src {
+			l.Printf("uploading %s to %s nodes %s", archSrc, arch, nodes)
+		}
+		if err := roachprod.Put(ctx, l, c.MakeNodes(nodes), archSrc, dest, true /* useTreeDist */); err != nil {
+			return err
+		}
+	}
+	return nil
+}
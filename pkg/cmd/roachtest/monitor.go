@@ -12,9 +12,9 @@ package main
 
 import (
 	"context"
-	"fmt"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/cluster"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/option"
@@ -22,6 +22,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/roachprod"
 	"github.com/cockroachdb/cockroach/pkg/roachprod/install"
 	"github.com/cockroachdb/cockroach/pkg/roachprod/logger"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
 	"github.com/cockroachdb/errors"
 	"golang.org/x/sync/errgroup"
 )
@@ -44,12 +45,16 @@ type monitorImpl struct {
 		WorkerStatus(...interface{})
 	}
 	l            *logger.Logger
+	c            cluster.Cluster
 	nodes        string
 	ctx          context.Context
 	cancel       func()
 	userGroup    *errgroup.Group // user-provided functions
 	monitorGroup *errgroup.Group // monitor goroutine
 	monitorOnce  sync.Once       // guarantees monitor goroutine is only started once
+	// audit, if set, records the monitoring of the nodes, once it ends, in the
+	// command audit log of the test.
+	audit func(start time.Time, err error)
 
 	expDeaths int32 // atomically
 }
@@ -68,6 +73,7 @@ func newMonitor(
 	m := &monitorImpl{
 		t:     t,
 		l:     t.L(),
+		c:     c,
 		nodes: c.MakeNodes(opts...),
 	}
 	m.ctx, m.cancel = context.WithCancel(ctx)
@@ -160,14 +166,24 @@ func (m *monitorImpl) Wait() {
 // callers are expected to call `Wait` or `WaitForNodeDeath`.
 func (m *monitorImpl) startNodeMonitor() {
 	m.monitorOnce.Do(func() {
-		m.monitorGroup.Go(func() error {
+		m.monitorGroup.Go(func() (retErr error) {
 			defer m.cancel() // stop user-tasks
+			if m.audit != nil {
+				start := timeutil.Now()
+				defer func() { m.audit(start, retErr) }()
+			}
 
 			eventsCh, err := roachprod.Monitor(m.ctx, m.l, m.nodes, install.MonitorOpts{})
 			if err != nil {
 				return errors.Wrap(err, "monitor node command failure")
 			}
 
+			// started is the time at which the cockroach process of each node
+			// was seen running, or at which the previous one died, to diagnose
+			// its death. The processes running when the monitor starts are
+			// assumed to have started then.
+			monitorStart := timeutil.Now()
+			started := make(map[install.Node]time.Time)
 			for info := range eventsCh {
 				var expectedDeathStr string
 				var retErr error
@@ -194,14 +210,26 @@ func (m *monitorImpl) startNodeMonitor() {
 							registry.InfraFlake,
 						)
 					}
+				case install.MonitorProcessRunning:
+					started[info.Node] = timeutil.Now()
 				case install.MonitorProcessDead:
 					isExpectedDeath := atomic.AddInt32(&m.expDeaths, -1) >= 0
 					if isExpectedDeath {
 						expectedDeathStr = ": expected"
 					}
 
+					processStart, ok := started[info.Node]
+					if !ok {
+						processStart = monitorStart
+					}
+					started[info.Node] = timeutil.Now()
 					if !isExpectedDeath {
-						retErr = fmt.Errorf("unexpected node event: %s", info)
+						// Look for the cause of the death, so that OOM kills, full disks
+						// and panics can be told apart in the failure.
+						retErr = &nodeDeathError{
+							event: info.String(),
+							cause: diagnoseNodeDeath(m.ctx, m.l, m.c, info.Node, processStart, e.ExitCode),
+						}
 					}
 				}
 
@@ -0,0 +1,138 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/cluster"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/option"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/install"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/logger"
+)
+
+// nodeDeathCause is the cause of an unexpected death of a cockroach process,
+// as determined by diagnoseNodeDeath.
+type nodeDeathCause string
+
+const (
+	nodeDeathUnknown  nodeDeathCause = "unknown"
+	nodeDeathOOMKill  nodeDeathCause = "oom-kill"
+	nodeDeathDiskFull nodeDeathCause = "disk-full"
+	nodeDeathPanic    nodeDeathCause = "panic"
+)
+
+// The exit codes of cockroach processes which identify the cause of their
+// death; see pkg/cli/exit.
+const (
+	exitCodeGoPanic  = "2"
+	exitCodeDiskFull = "10"
+	exitCodeSIGKILL  = "137"
+)
+
+var (
+	// oomKillRE matches the kernel messages logged when the OOM killer kills
+	// a cockroach process.
+	oomKillRE = regexp.MustCompile(`(?i)(out of memory|oom-kill|oom_reaper).*cockroach`)
+	// diskFullRE matches the errors of a process which ran out of disk space.
+	diskFullRE = regexp.MustCompile(`(?i)no space left on device`)
+	// panicRE matches the output of a Go panic, or of a fatal runtime error.
+	panicRE = regexp.MustCompile(`(?m)^(panic: |fatal error: )`)
+)
+
+// diagnoseNodeDeathCmd returns the command printing, on a node, the kernel
+// messages and journal entries logged since the cockroach process was started
+// at the given time, and the end of the standard error of cockroach since it
+// was last started. Older messages are left out, so that e.g. the OOM kill or
+// the panic of a previous process isn't mistaken for the cause of the death.
+// The kernel messages are read from the journal, as the dmesg of some of the
+// images we use doesn't support --since. The standard error is appended to by
+// every process, and the start script marks the start of each.
+func diagnoseNodeDeathCmd(since time.Time) string {
+	return fmt.Sprintf(`sudo journalctl -k --no-pager --since @%[1]d -n 200 2>/dev/null; `+
+		`sudo journalctl --no-pager --since @%[1]d -n 200 2>/dev/null; `+
+		`tac logs/cockroach.stderr.log 2>/dev/null | sed '/^cockroach start: /q' | tac | tail -n 100; true`,
+		since.Unix())
+}
+
+// classifyNodeDeath determines the cause of the death of a cockroach process
+// from its exit code and the diagnostic output of its node.
+func classifyNodeDeath(exitCode string, diagnostics string) nodeDeathCause {
+	switch {
+	case exitCode == exitCodeDiskFull || diskFullRE.MatchString(diagnostics):
+		return nodeDeathDiskFull
+	case (exitCode == exitCodeSIGKILL || exitCode == "") && oomKillRE.MatchString(diagnostics):
+		// The OOM killer kills processes with SIGKILL, but it also leaves a
+		// trace in the kernel log which tells it apart from other SIGKILLs. The
+		// trace may be about another cockroach process of the node, e.g. a
+		// workload, if the process exited otherwise.
+		return nodeDeathOOMKill
+	case exitCode == exitCodeGoPanic || panicRE.MatchString(diagnostics):
+		return nodeDeathPanic
+	default:
+		return nodeDeathUnknown
+	}
+}
+
+// diagnoseNodeDeath inspects the node on which a cockroach process, started
+// at the given time, died to determine the cause of its death. The diagnostics
+// are only available on remote clusters; the death is classified from its exit
+// code otherwise.
+func diagnoseNodeDeath(
+	ctx context.Context,
+	l *logger.Logger,
+	c cluster.Cluster,
+	node install.Node,
+	started time.Time,
+	exitCode string,
+) nodeDeathCause {
+	var diagnostics string
+	if !c.IsLocal() {
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		res, err := c.RunWithDetailsSingleNode(
+			ctx, l, option.WithNodes(option.NodeListOption{int(node)}), diagnoseNodeDeathCmd(started),
+		)
+		if err != nil {
+			l.Printf("failed to diagnose the death of n%d: %v", node, err)
+		}
+		diagnostics = res.Stdout
+	}
+	return classifyNodeDeath(strings.TrimSpace(exitCode), diagnostics)
+}
+
+// nodeDeathError is the error returned by the monitor when a cockroach process
+// dies unexpectedly.
+type nodeDeathError struct {
+	event string
+	cause nodeDeathCause
+}
+
+func (e *nodeDeathError) Error() string {
+	if e.cause == nodeDeathUnknown {
+		return fmt.Sprintf("unexpected node event: %s", e.event)
+	}
+	return fmt.Sprintf("unexpected node event: %s (%s)", e.event, e.cause)
+}
+
+// nodeDeathCauseOf returns the cause of the first node death among the given
+// failures, if any.
+func nodeDeathCauseOf(failures []failure) (nodeDeathCause, bool) {
+	var deathErr *nodeDeathError
+	if !failuresMatchingError(failures, &deathErr) {
+		return "", false
+	}
+	return deathErr.cause, true
+}
@@ -0,0 +1,98 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClassifyNodeDeath(t *testing.T) {
+	testCases := []struct {
+		name        string
+		exitCode    string
+		diagnostics string
+		expected    nodeDeathCause
+	}{
+		{
+			name:     "oom kill",
+			exitCode: "137",
+			diagnostics: "[Tue Oct  1 10:00:00 2024] Out of memory: Killed process 1234 (cockroach) " +
+				"total-vm:8000000kB, anon-rss:7000000kB",
+			expected: nodeDeathOOMKill,
+		},
+		{
+			name:     "oom kill of another process",
+			exitCode: "2",
+			diagnostics: "[Tue Oct  1 10:00:00 2024] Out of memory: Killed process 1234 (cockroach) " +
+				"total-vm:8000000kB, anon-rss:7000000kB",
+			expected: nodeDeathPanic,
+		},
+		{
+			name:     "sigkill without oom",
+			exitCode: "137",
+			expected: nodeDeathUnknown,
+		},
+		{
+			name:     "disk full exit code",
+			exitCode: "10",
+			expected: nodeDeathDiskFull,
+		},
+		{
+			name:        "disk full error",
+			exitCode:    "7",
+			diagnostics: "F241001 10:00:00.000000 1 storage/pebble.go:123 write /mnt/data1/000123.sst: no space left on device",
+			expected:    nodeDeathDiskFull,
+		},
+		{
+			name:     "panic exit code",
+			exitCode: "2",
+			expected: nodeDeathPanic,
+		},
+		{
+			name:        "panic output",
+			exitCode:    "1",
+			diagnostics: "panic: runtime error: index out of range [3] with length 3\n\ngoroutine 1 [running]:",
+			expected:    nodeDeathPanic,
+		},
+		{
+			name:     "unknown",
+			exitCode: "1",
+			expected: nodeDeathUnknown,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, classifyNodeDeath(tc.exitCode, tc.diagnostics))
+		})
+	}
+}
+
+func TestNodeDeathError(t *testing.T) {
+	err := &nodeDeathError{event: "n2: cockroach process for system interface died (exit code 137)", cause: nodeDeathOOMKill}
+	require.Equal(t, "unexpected node event: n2: cockroach process for system interface died (exit code 137) (oom-kill)", err.Error())
+
+	cause, ok := nodeDeathCauseOf([]failure{{squashedErr: err, errors: []error{err}}})
+	require.True(t, ok)
+	require.Equal(t, nodeDeathOOMKill, cause)
+
+	_, ok = nodeDeathCauseOf(nil)
+	require.False(t, ok)
+}
+
+func TestDiagnoseNodeDeathCmd(t *testing.T) {
+	cmd := diagnoseNodeDeathCmd(time.Unix(1700000000, 0))
+	require.Contains(t, cmd, "journalctl -k --no-pager --since @1700000000 ")
+	require.Contains(t, cmd, "journalctl --no-pager --since @1700000000 ")
+	require.Contains(t, cmd, "sed '/^cockroach start: /q'")
+}
@@ -0,0 +1,172 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/roachprod/logger"
+	"github.com/cockroachdb/cockroach/pkg/util/httputil"
+	"github.com/cockroachdb/errors"
+)
+
+// defaultWebhookKey is the key, in --notify-webhook, of the webhook which
+// receives the messages about the run as a whole, and the failures of the
+// teams without a webhook of their own.
+const defaultWebhookKey = "default"
+
+// webhookNotifier posts messages about the progress of a run to
+// Slack-compatible incoming webhooks, so that failures are visible without
+// polling CI.
+type webhookNotifier struct {
+	// webhooks maps owner teams, and defaultWebhookKey, to webhook URLs.
+	webhooks map[string]string
+	client   *httputil.Client
+}
+
+// newWebhookNotifier returns a notifier posting to the given webhooks, or nil
+// if there are none.
+func newWebhookNotifier(webhooks map[string]string) *webhookNotifier {
+	if len(webhooks) == 0 {
+		return nil
+	}
+	return &webhookNotifier{
+		webhooks: webhooks,
+		client:   httputil.NewClientWithTimeout(10 * time.Second),
+	}
+}
+
+// webhookFor returns the webhook for the given owner team, falling back to the
+// default one. Returns an empty string if there is no such webhook.
+func (n *webhookNotifier) webhookFor(owner string) string {
+	if url, ok := n.webhooks[owner]; ok {
+		return url
+	}
+	return n.webhooks[defaultWebhookKey]
+}
+
+// post posts the given text to the given webhook. Failures to notify are
+// logged, and don't affect the run.
+func (n *webhookNotifier) post(ctx context.Context, l *logger.Logger, url, text string) {
+	if url == "" {
+		return
+	}
+	if err := func() error {
+		body, err := json.Marshal(struct {
+			Text string `json:"text"`
+		}{Text: text})
+		if err != nil {
+			return err
+		}
+		resp, err := n.client.Post(ctx, url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return errors.Newf("unexpected status %s", resp.Status)
+		}
+		return nil
+	}(); err != nil {
+		l.PrintfCtx(ctx, "failed to post to notification webhook: %s", err)
+	}
+}
+
+// runStarted notifies the default webhook that a run has started.
+func (n *webhookNotifier) runStarted(ctx context.Context, l *logger.Logger, numTests int) {
+	if n == nil {
+		return
+	}
+	text := fmt.Sprintf("roachtest run %s started: %d tests", runID, numTests)
+	if link := teamCityBuildLink(); link != "" {
+		text += "\n" + link
+	}
+	n.post(ctx, l, n.webhooks[defaultWebhookKey], text)
+}
+
+// testFailed notifies the webhook of the team owning the failure that a test
+// failed, with the given output.
+func (n *webhookNotifier) testFailed(
+	ctx context.Context, l *logger.Logger, t *testImpl, output string,
+) {
+	if n == nil {
+		return
+	}
+	owner := testOwner(t)
+	// Keep the message short; the full output is in the artifacts.
+	if lines := strings.SplitN(output, "\n", 21); len(lines) > 20 {
+		output = strings.Join(lines[:20], "\n") + "\n..."
+	}
+	text := fmt.Sprintf("roachtest %s (owner: %s) failed:\n```\n%s\n```\nartifacts: %s",
+		t.Name(), owner, output, t.ArtifactsDir())
+	n.post(ctx, l, n.webhookFor(owner), text)
+}
+
+// runFinished posts the summary of the run to the default webhook, and the
+// list of their failures to the webhooks of the teams with failures.
+func (n *webhookNotifier) runFinished(ctx context.Context, l *logger.Logger, r *testRunner) {
+	if n == nil {
+		return
+	}
+	r.status.Lock()
+	failures := make(map[string][]string)
+	for t := range r.status.fail {
+		owner := testOwner(t)
+		failures[owner] = append(failures[owner], t.Name())
+	}
+	summary := fmt.Sprintf("roachtest run %s finished: %d passed, %d failed, %d flaky, %d skipped",
+		runID, len(r.status.pass), len(r.status.fail), len(r.status.flaky), len(r.status.skip))
+	r.status.Unlock()
+
+	var all []string
+	for owner, tests := range failures {
+		sort.Strings(tests)
+		if url, ok := n.webhooks[owner]; ok {
+			n.post(ctx, l, url, fmt.Sprintf("%s\nfailures owned by %s:\n%s",
+				summary, owner, strings.Join(tests, "\n")))
+		}
+		all = append(all, tests...)
+	}
+	sort.Strings(all)
+	text := summary
+	if len(all) > 0 {
+		text += "\nfailures:\n" + strings.Join(all, "\n")
+	}
+	if link := teamCityBuildLink(); link != "" {
+		text += "\n" + link
+	}
+	n.post(ctx, l, n.webhooks[defaultWebhookKey], text)
+}
+
+// debugClusterReminder posts a reminder about a cluster kept for debugging to
+// the default webhook.
+func (n *webhookNotifier) debugClusterReminder(ctx context.Context, l *logger.Logger, text string) {
+	if n == nil {
+		return
+	}
+	n.post(ctx, l, n.webhooks[defaultWebhookKey], "roachtest: "+text)
+}
+
+// testOwner returns the team owning the failures of the given test, which is
+// the owner of the test unless a failure specifies a different one.
+func testOwner(t *testImpl) string {
+	if errWithOwner := failuresAsErrorWithOwnership(t.failures()); errWithOwner != nil {
+		return string(errWithOwner.Owner)
+	}
+	return string(t.spec.Owner)
+}
@@ -0,0 +1,78 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookNotifier(t *testing.T) {
+	ctx := context.Background()
+	require.Nil(t, newWebhookNotifier(nil))
+
+	var mu syncutil.Mutex
+	posted := make(map[string][]string)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg struct{ Text string }
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&msg))
+		mu.Lock()
+		defer mu.Unlock()
+		posted[r.URL.Path] = append(posted[r.URL.Path], msg.Text)
+	}))
+	defer srv.Close()
+
+	n := newWebhookNotifier(map[string]string{
+		defaultWebhookKey: srv.URL + "/default",
+		"kv":              srv.URL + "/kv",
+	})
+	l := nilLogger()
+	newTest := func(name string, owner registry.Owner) *testImpl {
+		t := &testImpl{
+			spec:         &registry.TestSpec{Name: name, Owner: owner},
+			artifactsDir: "artifacts/" + name,
+			l:            nilLogger(),
+		}
+		t.Errorf("boom")
+		return t
+	}
+	kvTest, sqlTest := newTest("kv/splits", "kv"), newTest("sql/schema", "sql")
+
+	n.runStarted(ctx, l, 2)
+	n.testFailed(ctx, l, kvTest, "boom")
+	n.testFailed(ctx, l, sqlTest, "boom")
+
+	r := &testRunner{}
+	r.status.pass = map[*testImpl]struct{}{}
+	r.status.fail = map[*testImpl]struct{}{kvTest: {}, sqlTest: {}}
+	r.status.flaky = map[*testImpl]struct{}{}
+	r.status.skip = map[*testImpl]struct{}{}
+	n.runFinished(ctx, l, r)
+
+	mu.Lock()
+	defer mu.Unlock()
+	require.Len(t, posted["/default"], 3)
+	require.Contains(t, posted["/default"][0], "started: 2 tests")
+	require.Contains(t, posted["/default"][1], "sql/schema (owner: sql) failed")
+	require.Contains(t, posted["/default"][2], "0 passed, 2 failed")
+	require.Contains(t, posted["/default"][2], "kv/splits\nsql/schema")
+	require.Len(t, posted["/kv"], 2)
+	require.Contains(t, posted["/kv"][0], "kv/splits (owner: kv) failed")
+	require.Contains(t, posted["/kv"][0], "artifacts: artifacts/kv/splits")
+	require.Contains(t, posted["/kv"][1], "failures owned by kv:\nkv/splits")
+}
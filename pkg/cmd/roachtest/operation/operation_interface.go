@@ -33,4 +33,8 @@ type Operation interface {
 
 	L() *logger.Logger
 	Status(args ...interface{})
+	// AddEventTags adds tags, in the key:value form, to the Datadog events
+	// emitted about the operation from then on, e.g. to identify the nodes
+	// it targets.
+	AddEventTags(tags ...string)
 }
@@ -49,6 +49,10 @@ type operationImpl struct {
 		failures []error
 
 		status string
+
+		// eventTags are the tags added to the Datadog events of the operation
+		// with AddEventTags.
+		eventTags []string
 	}
 }
 
@@ -139,6 +143,20 @@ func (o *operationImpl) addFailure(depth int, format string, args ...interface{}
 	o.L().Printf("operation failure #%d: %s", failureNum, msg)
 }
 
+// AddEventTags implements the Operation interface.
+func (o *operationImpl) AddEventTags(tags ...string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.mu.eventTags = append(o.mu.eventTags, tags...)
+}
+
+// eventTags returns the tags added with AddEventTags.
+func (o *operationImpl) eventTags() []string {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return append([]string(nil), o.mu.eventTags...)
+}
+
 func (o *operationImpl) Failed() bool {
 	o.mu.RLock()
 	defer o.mu.RUnlock()
@@ -146,4 +164,12 @@ func (o *operationImpl) Failed() bool {
 	return len(o.mu.failures) > 0
 }
 
+// failuresSince returns the failures of the operation past the first n ones.
+func (o *operationImpl) failuresSince(n int) []error {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+
+	return append([]error(nil), o.mu.failures[n:]...)
+}
+
 var _ operation.Operation = &operationImpl{}
@@ -0,0 +1,208 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/logger"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+	"github.com/cockroachdb/errors"
+	"github.com/robfig/cron/v3"
+)
+
+// operationScheduler runs operations against a cluster on a cron schedule,
+// for as long as its context isn't canceled. See run-operation --schedule.
+//
+// On each tick of the schedule, one operation is picked at random among the
+// operations which are not in their cooldown period, with probabilities
+// proportional to their weights. Ticks that elapse while an operation runs are
+// skipped, so at most one operation runs at a time.
+type operationScheduler struct {
+	schedule cron.Schedule
+	ops      []registry.OperationSpec
+	// weights maps operation names to their relative weight. Operations
+	// missing from weights have a weight of 1.
+	weights map[string]float64
+	// defaultCooldown is the cooldown of the operations which don't specify
+	// one.
+	defaultCooldown time.Duration
+	rng             *rand.Rand
+
+	// statePath, if set, is the file in which state is persisted.
+	statePath string
+	state     schedulerState
+}
+
+// schedulerState is the state of the operation scheduler which survives its
+// restarts.
+type schedulerState struct {
+	// LastRun maps operation names to the time at which they last started.
+	LastRun map[string]time.Time `json:"last_run"`
+}
+
+// parseOperationWeights parses the --operation-weight flag.
+func parseOperationWeights(weights map[string]string) (map[string]float64, error) {
+	res := make(map[string]float64, len(weights))
+	for name, w := range weights {
+		weight, err := strconv.ParseFloat(w, 64)
+		if err != nil || weight < 0 {
+			return nil, errors.Newf("invalid weight %q for operation %s", w, name)
+		}
+		res[name] = weight
+	}
+	return res, nil
+}
+
+func newOperationScheduler(
+	schedule string,
+	ops []registry.OperationSpec,
+	weights map[string]float64,
+	defaultCooldown time.Duration,
+	statePath string,
+	rng *rand.Rand,
+) (*operationScheduler, error) {
+	sched, err := cron.ParseStandard(schedule)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid schedule %q", schedule)
+	}
+	s := &operationScheduler{
+		schedule:        sched,
+		ops:             ops,
+		weights:         weights,
+		defaultCooldown: defaultCooldown,
+		rng:             rng,
+		statePath:       statePath,
+		state:           schedulerState{LastRun: make(map[string]time.Time)},
+	}
+	if statePath != "" {
+		data, err := os.ReadFile(statePath)
+		switch {
+		case os.IsNotExist(err):
+		case err != nil:
+			return nil, errors.Wrap(err, "reading scheduler state")
+		default:
+			if err := json.Unmarshal(data, &s.state); err != nil {
+				return nil, errors.Wrap(err, "decoding scheduler state")
+			}
+			if s.state.LastRun == nil {
+				s.state.LastRun = make(map[string]time.Time)
+			}
+		}
+	}
+	return s, nil
+}
+
+func (s *operationScheduler) weight(op *registry.OperationSpec) float64 {
+	if w, ok := s.weights[op.Name]; ok {
+		return w
+	}
+	return 1
+}
+
+func (s *operationScheduler) cooldown(op *registry.OperationSpec) time.Duration {
+	if op.Cooldown != 0 {
+		return op.Cooldown
+	}
+	return s.defaultCooldown
+}
+
+// pick returns an operation to run at the given time, or nil if all the
+// operations are disabled or in their cooldown period.
+func (s *operationScheduler) pick(now time.Time) *registry.OperationSpec {
+	var eligible []*registry.OperationSpec
+	var total float64
+	for i := range s.ops {
+		op := &s.ops[i]
+		if op.Skip != "" || s.weight(op) == 0 {
+			continue
+		}
+		if last, ok := s.state.LastRun[op.Name]; ok && now.Sub(last) < s.cooldown(op) {
+			continue
+		}
+		eligible = append(eligible, op)
+		total += s.weight(op)
+	}
+	if len(eligible) == 0 {
+		return nil
+	}
+	x := s.rng.Float64() * total
+	for _, op := range eligible {
+		if x -= s.weight(op); x < 0 {
+			return op
+		}
+	}
+	return eligible[len(eligible)-1]
+}
+
+// recordRun records that the given operation started at the given time, and
+// persists the state. The file is replaced atomically, so that a scheduler
+// killed at any point leaves a valid state behind.
+func (s *operationScheduler) recordRun(name string, start time.Time) error {
+	s.state.LastRun[name] = start
+	if s.statePath == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.statePath), 0755); err != nil {
+		return err
+	}
+	tmp := s.statePath + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return errors.Wrap(err, "writing scheduler state")
+	}
+	return errors.Wrap(os.Rename(tmp, s.statePath), "writing scheduler state")
+}
+
+// run runs the scheduled operations with runOp until the context is canceled.
+// Failures of the operations are logged, and don't stop the scheduler.
+func (s *operationScheduler) run(
+	ctx context.Context,
+	l *logger.Logger,
+	runOp func(context.Context, *registry.OperationSpec) error,
+) error {
+	for {
+		next := s.schedule.Next(timeutil.Now())
+		l.Printf("next operation scheduled at %s", next)
+		select {
+		case <-ctx.Done():
+			l.Printf("scheduler stopped: %s", ctx.Err())
+			return nil
+		case <-time.After(timeutil.Until(next)):
+		}
+
+		now := timeutil.Now()
+		op := s.pick(now)
+		if op == nil {
+			l.Printf("no operation eligible to run; all operations are disabled or cooling down")
+			continue
+		}
+		// The run is recorded before it starts, so that the cooldown applies
+		// even if the scheduler is restarted while the operation runs.
+		if err := s.recordRun(op.Name, now); err != nil {
+			return err
+		}
+		l.Printf("running scheduled operation %s", op.Name)
+		if err := runOp(ctx, op); err != nil {
+			l.Printf("operation %s failed: %+v", op.Name, err)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"math/rand"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseOperationWeights(t *testing.T) {
+	weights, err := parseOperationWeights(map[string]string{"a": "2", "b": "0.5"})
+	require.NoError(t, err)
+	require.Equal(t, map[string]float64{"a": 2, "b": 0.5}, weights)
+
+	_, err = parseOperationWeights(map[string]string{"a": "foo"})
+	require.Error(t, err)
+	_, err = parseOperationWeights(map[string]string{"a": "-1"})
+	require.Error(t, err)
+}
+
+func TestOperationSchedulerPick(t *testing.T) {
+	ops := []registry.OperationSpec{
+		{Name: "add-index", Cooldown: time.Hour},
+		{Name: "node-kill"},
+		{Name: "disabled"},
+		{Name: "skipped", Skip: "flaky"},
+	}
+	weights := map[string]float64{"add-index": 3, "disabled": 0}
+	s, err := newOperationScheduler(
+		"@every 10m", ops, weights, 10*time.Minute, "" /* statePath */, rand.New(rand.NewSource(1)),
+	)
+	require.NoError(t, err)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	picked := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		picked[s.pick(now).Name]++
+	}
+	require.Len(t, picked, 2)
+	require.Greater(t, picked["add-index"], picked["node-kill"])
+
+	// Operations in their cooldown period aren't picked.
+	require.NoError(t, s.recordRun("add-index", now))
+	require.Equal(t, "node-kill", s.pick(now.Add(time.Minute)).Name)
+	require.NoError(t, s.recordRun("node-kill", now.Add(time.Minute)))
+	require.Nil(t, s.pick(now.Add(5*time.Minute)))
+	require.Equal(t, "node-kill", s.pick(now.Add(30*time.Minute)).Name)
+}
+
+func TestOperationSchedulerState(t *testing.T) {
+	ops := []registry.OperationSpec{{Name: "node-kill"}}
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	newScheduler := func() *operationScheduler {
+		s, err := newOperationScheduler(
+			"*/15 * * * *", ops, nil /* weights */, time.Hour, statePath, rand.New(rand.NewSource(1)),
+		)
+		require.NoError(t, err)
+		return s
+	}
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	s := newScheduler()
+	require.NotNil(t, s.pick(now))
+	require.NoError(t, s.recordRun("node-kill", now))
+
+	// A restarted scheduler honors the cooldown of the previous runs.
+	s = newScheduler()
+	require.Nil(t, s.pick(now.Add(time.Minute)))
+	require.NotNil(t, s.pick(now.Add(2*time.Hour)))
+
+	_, err := newOperationScheduler(
+		"not a schedule", ops, nil /* weights */, 0, "" /* statePath */, rand.New(rand.NewSource(1)),
+	)
+	require.Error(t, err)
+}
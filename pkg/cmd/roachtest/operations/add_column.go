@@ -24,7 +24,9 @@ import (
 )
 
 type cleanupAddedColumn struct {
-	db, table, column string
+	DB     string `json:"db"`
+	Table  string `json:"table"`
+	Column string `json:"column"`
 }
 
 func (cl *cleanupAddedColumn) Cleanup(
@@ -33,8 +35,8 @@ func (cl *cleanupAddedColumn) Cleanup(
 	conn := c.Conn(ctx, o.L(), 1, option.VirtualClusterName(roachtestflags.VirtualCluster))
 	defer conn.Close()
 
-	o.Status(fmt.Sprintf("dropping column %s", cl.column))
-	_, err := conn.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s.%s DROP COLUMN %s CASCADE", cl.db, cl.table, cl.column))
+	o.Status(fmt.Sprintf("dropping column %s", cl.Column))
+	_, err := conn.ExecContext(ctx, fmt.Sprintf("ALTER TABLE %s.%s DROP COLUMN %s CASCADE", cl.DB, cl.Table, cl.Column))
 	if err != nil {
 		o.Fatal(err)
 	}
@@ -72,9 +74,9 @@ func runAddColumn(
 
 	o.Status(fmt.Sprintf("column %s created", colName))
 	return &cleanupAddedColumn{
-		db:     dbName,
-		table:  tableName,
-		column: colName,
+		DB:     dbName,
+		Table:  tableName,
+		Column: colName,
 	}
 }
 
@@ -86,5 +88,6 @@ func registerAddColumn(r registry.Registry) {
 		CompatibleClouds: registry.AllClouds,
 		Dependencies:     []registry.OperationDependency{registry.OperationRequiresPopulatedDatabase},
 		Run:              runAddColumn,
+		NewCleanup:       func() registry.OperationCleanup { return &cleanupAddedColumn{} },
 	})
 }
@@ -24,7 +24,9 @@ import (
 )
 
 type cleanupAddedIndex struct {
-	db, table, index string
+	DB    string `json:"db"`
+	Table string `json:"table"`
+	Index string `json:"index"`
 }
 
 func (cl *cleanupAddedIndex) Cleanup(
@@ -33,8 +35,8 @@ func (cl *cleanupAddedIndex) Cleanup(
 	conn := c.Conn(ctx, o.L(), 1, option.VirtualClusterName(roachtestflags.VirtualCluster))
 	defer conn.Close()
 
-	o.Status(fmt.Sprintf("dropping index %s", cl.index))
-	_, err := conn.ExecContext(ctx, fmt.Sprintf("DROP INDEX %s.%s@%s", cl.db, cl.table, cl.index))
+	o.Status(fmt.Sprintf("dropping index %s", cl.Index))
+	_, err := conn.ExecContext(ctx, fmt.Sprintf("DROP INDEX %s.%s@%s", cl.DB, cl.Table, cl.Index))
 	if err != nil {
 		o.Fatal(err)
 	}
@@ -73,9 +75,9 @@ func runAddIndex(
 
 	o.Status(fmt.Sprintf("index %s created", indexName))
 	return &cleanupAddedIndex{
-		db:    dbName,
-		table: tableName,
-		index: indexName,
+		DB:    dbName,
+		Table: tableName,
+		Index: indexName,
 	}
 }
 
@@ -87,5 +89,6 @@ func registerAddIndex(r registry.Registry) {
 		CompatibleClouds: registry.AllClouds,
 		Dependencies:     []registry.OperationDependency{registry.OperationRequiresPopulatedDatabase},
 		Run:              runAddIndex,
+		NewCleanup:       func() registry.OperationCleanup { return &cleanupAddedIndex{} },
 	})
 }
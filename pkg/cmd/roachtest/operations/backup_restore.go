@@ -26,7 +26,7 @@ import (
 )
 
 type backupRestoreCleanup struct {
-	db string
+	DB string `json:"db"`
 }
 
 func (cl *backupRestoreCleanup) Cleanup(
@@ -35,8 +35,8 @@ func (cl *backupRestoreCleanup) Cleanup(
 	conn := c.Conn(ctx, o.L(), 1, option.VirtualClusterName(roachtestflags.VirtualCluster))
 	defer conn.Close()
 
-	o.Status(fmt.Sprintf("dropping newly created db %s", cl.db))
-	_, err := conn.ExecContext(ctx, fmt.Sprintf("DROP DATABASE %s CASCADE", cl.db))
+	o.Status(fmt.Sprintf("dropping newly created db %s", cl.DB))
+	_, err := conn.ExecContext(ctx, fmt.Sprintf("DROP DATABASE %s CASCADE", cl.DB))
 	if err != nil {
 		o.Fatal(err)
 	}
@@ -121,7 +121,7 @@ outer:
 		o.Fatalf("backup and restore fingerprints do not match: %d != %d", backupFingerprint, restoreFingerprint)
 	}
 
-	return &backupRestoreCleanup{db: restoreDBName}
+	return &backupRestoreCleanup{DB: restoreDBName}
 }
 
 func registerBackupRestore(r registry.Registry) {
@@ -132,5 +132,6 @@ func registerBackupRestore(r registry.Registry) {
 		CompatibleClouds: registry.AllClouds,
 		Dependencies:     []registry.OperationDependency{registry.OperationRequiresPopulatedDatabase},
 		Run:              runBackupRestore,
+		NewCleanup:       func() registry.OperationCleanup { return &backupRestoreCleanup{} },
 	})
 }
@@ -19,48 +19,166 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/operation"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/option"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/roachtestflags"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/roachtestutil"
+	rperrors "github.com/cockroachdb/cockroach/pkg/roachprod/errors"
 	"github.com/cockroachdb/cockroach/pkg/util/randutil"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+)
+
+const (
+	// stallDetectionTimeout is how long a node whose disk is stalled has to
+	// fatal. Nodes fatal once a write to their disk has been stalled for
+	// storage.max_sync_duration (20s by default), unless
+	// storage.max_sync_duration.fatal.enabled is false.
+	stallDetectionTimeout = 2 * time.Minute
+	// slowDiskBytesPerSecond is the throughput of the disks slowed down by the
+	// disk-stall/slow operation.
+	slowDiskBytesPerSecond = 1 << 20 // 1 MiB/s
+)
+
+// Kinds of disk stallers, which the cleanup of the disk stall operations
+// records to unstall the disks with the same kind of staller.
+const (
+	diskStallerDmsetup        = "dmsetup"
+	diskStallerCgroup         = "cgroup"
+	diskStallerCgroupThrottle = "cgroup-throttle"
 )
 
 type cleanupDiskStall struct {
-	nodes   option.NodeListOption
-	staller roachtestutil.DiskStaller
+	Nodes   option.NodeListOption `json:"nodes"`
+	Staller string                `json:"staller"`
+	// BytesPerSecond is the throughput the disk was limited to by a
+	// cgroup-throttle staller.
+	BytesPerSecond int `json:"bytes_per_second,omitempty"`
+}
+
+func newCleanupDiskStall() registry.OperationCleanup {
+	return &cleanupDiskStall{}
+}
+
+// makeStaller returns a disk staller of the kind which stalled the disk.
+func (cl *cleanupDiskStall) makeStaller(
+	o operation.Operation, c cluster.Cluster,
+) roachtestutil.DiskStaller {
+	switch cl.Staller {
+	case diskStallerDmsetup:
+		return roachtestutil.MakeDmsetupDiskStaller(o, c)
+	case diskStallerCgroup:
+		return roachtestutil.MakeCgroupDiskStaller(o, c, true /* readsToo */, false /* logsToo */)
+	case diskStallerCgroupThrottle:
+		return roachtestutil.MakeCgroupDiskThrottler(o, c, true /* readsToo */, cl.BytesPerSecond)
+	default:
+		o.Fatalf("unknown disk staller %q", cl.Staller)
+		return nil
+	}
 }
 
 func (cl *cleanupDiskStall) Cleanup(ctx context.Context, o operation.Operation, c cluster.Cluster) {
-	cl.staller.Unstall(ctx, cl.nodes)
+	cl.makeStaller(o, c).Unstall(ctx, cl.Nodes)
 	o.Status("unstalled nodes; waiting 10 seconds before restarting")
 	time.Sleep(10 * time.Second)
 	// We might need to restart the node if it isn't live.
-	db, err := c.ConnE(ctx, o.L(), cl.nodes[0])
+	db, err := c.ConnE(ctx, o.L(), cl.Nodes[0])
 	if err != nil {
-		c.Run(ctx, option.WithNodes(cl.nodes), "./cockroach.sh")
+		c.Run(ctx, option.WithNodes(cl.Nodes), "./cockroach.sh")
 		return
 	}
 	defer db.Close()
 	_, err = db.Query("SELECT 1")
 	if err != nil {
-		c.Run(ctx, option.WithNodes(cl.nodes), "./cockroach.sh")
+		c.Run(ctx, option.WithNodes(cl.Nodes), "./cockroach.sh")
+	}
+}
+
+// diskStallNodes returns the nodes given by --disk-stall-node, or a random
+// node.
+func diskStallNodes(o operation.Operation, c cluster.Cluster) option.NodeListOption {
+	rng, _ := randutil.NewPseudoRand()
+	nodes, err := selectNodes(rng, len(c.All()), roachtestflags.DiskStallNode, nil /* exclude */)
+	if err != nil {
+		o.Fatalf("invalid --disk-stall-node: %v", err)
 	}
+	return nodes
 }
 
 func runDiskStall(
 	ctx context.Context, o operation.Operation, c cluster.Cluster,
 ) registry.OperationCleanup {
-	rng, _ := randutil.NewPseudoRand()
+	node := diskStallNodes(o, c)
+	cleanup := &cleanupDiskStall{Nodes: node, Staller: diskStallerDmsetup}
 
-	nodes := c.All()
-	nid := nodes[rng.Intn(len(nodes))]
-	ds := roachtestutil.MakeDmsetupDiskStaller(o, c)
+	o.Status(fmt.Sprintf("stalling disk on node %s", node.NodeIDsString()))
+	cleanup.makeStaller(o, c).Stall(ctx, node)
 
-	o.Status(fmt.Sprintf("stalling disk on node %d", nid))
-	ds.Stall(ctx, c.Node(nid))
+	return cleanup
+}
 
-	return &cleanupDiskStall{
-		nodes:   c.Node(nid),
-		staller: ds,
+// diskStallRunner returns the Run function of an operation which stalls the
+// disk of a node, or slows it down if bytesPerSecond is set, by limiting the
+// throughput of the cockroach process with its cgroup.
+func diskStallRunner(
+	bytesPerSecond int,
+) func(ctx context.Context, o operation.Operation, c cluster.Cluster) registry.OperationCleanup {
+	return func(ctx context.Context, o operation.Operation, c cluster.Cluster) registry.OperationCleanup {
+		nodes := diskStallNodes(o, c)
+		cleanup := &cleanupDiskStall{Nodes: nodes, Staller: diskStallerCgroup}
+		if bytesPerSecond > 0 {
+			cleanup.Staller = diskStallerCgroupThrottle
+			cleanup.BytesPerSecond = bytesPerSecond
+			o.Status(fmt.Sprintf("limiting the disk throughput of nodes %s to %d B/s",
+				nodes.NodeIDsString(), bytesPerSecond))
+		} else {
+			o.Status(fmt.Sprintf("stalling disk on nodes %s", nodes.NodeIDsString()))
+		}
+		cleanup.makeStaller(o, c).Stall(ctx, nodes)
+
+		// Errors past this point must not prevent the cleanup from being
+		// returned, which restores the disks.
+		for _, n := range nodes {
+			node := c.Node(n)
+			if bytesPerSecond > 0 {
+				// A slow disk isn't expected to make the node fatal, but it may
+				// if its writes become slower than storage.max_sync_duration.
+				o.Status(fmt.Sprintf("node n%d is %s after the slowdown of its disk",
+					n, nodeProcessState(ctx, o, c, node)))
+				continue
+			}
+			o.Status(fmt.Sprintf("waiting for node n%d to detect the stall and fatal", n))
+			deadline := timeutil.Now().Add(stallDetectionTimeout)
+			for {
+				state := nodeProcessState(ctx, o, c, node)
+				if state == "stopped" {
+					o.Status(fmt.Sprintf("node n%d detected the stall and exited", n))
+					break
+				}
+				if ctx.Err() != nil || timeutil.Now().After(deadline) {
+					o.Errorf("node n%d is still %s %s after its disk stalled; "+
+						"is storage.max_sync_duration.fatal.enabled false?", n, state, stallDetectionTimeout)
+					break
+				}
+				time.Sleep(time.Second)
+			}
+		}
+		return cleanup
+	}
+}
+
+// nodeProcessState returns whether the cockroach process of the given node is
+// "running" or "stopped", or "unknown" if that can't be determined.
+func nodeProcessState(
+	ctx context.Context, o operation.Operation, c cluster.Cluster, node option.NodeListOption,
+) string {
+	err := c.RunE(ctx, option.WithNodes(node), "pgrep", "-f", "cockroach\\ start")
+	if err == nil {
+		return "running"
 	}
+	if code, ok := rperrors.GetExitCode(err); ok && code == 1 {
+		// pgrep returns error code 1 if no processes are found.
+		return "stopped"
+	}
+	o.L().Printf("failed to find the cockroach process of node n%d: %v", node[0], err)
+	return "unknown"
 }
 
 func registerDiskStall(r registry.Registry) {
@@ -71,5 +189,24 @@ func registerDiskStall(r registry.Registry) {
 		CompatibleClouds: registry.OnlyGCE,
 		Dependencies:     []registry.OperationDependency{registry.OperationRequiresZeroUnderreplicatedRanges},
 		Run:              runDiskStall,
+		NewCleanup:       newCleanupDiskStall,
+	})
+	r.AddOperation(registry.OperationSpec{
+		Name:             "disk-stall/cgroup",
+		Owner:            registry.OwnerStorage,
+		Timeout:          10 * time.Minute,
+		CompatibleClouds: registry.AllExceptLocal,
+		Dependencies:     []registry.OperationDependency{registry.OperationRequiresZeroUnderreplicatedRanges},
+		Run:              diskStallRunner(0 /* bytesPerSecond */),
+		NewCleanup:       newCleanupDiskStall,
+	})
+	r.AddOperation(registry.OperationSpec{
+		Name:             "disk-stall/slow",
+		Owner:            registry.OwnerStorage,
+		Timeout:          10 * time.Minute,
+		CompatibleClouds: registry.AllExceptLocal,
+		Dependencies:     []registry.OperationDependency{registry.OperationRequiresZeroUnderreplicatedRanges},
+		Run:              diskStallRunner(slowDiskBytesPerSecond),
+		NewCleanup:       newCleanupDiskStall,
 	})
 }
@@ -14,99 +14,224 @@ import (
 	"context"
 	"fmt"
 	"math/rand"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/cluster"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/operation"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/option"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/roachtestflags"
+	"github.com/cockroachdb/cockroach/pkg/util/randutil"
+	"github.com/cockroachdb/errors"
 )
 
-type cleanupNetworkPartition struct {
-	nodeID int
-}
+const (
+	// partitionInputChain and partitionOutputChain are the iptables chains,
+	// jumped to from INPUT and OUTPUT respectively, holding the rules of a
+	// partition. Healing the partition removes them, which leaves the other
+	// rules of the node alone.
+	partitionInputChain  = "ROACHTEST_PARTITION_IN"
+	partitionOutputChain = "ROACHTEST_PARTITION_OUT"
+	// partitionHealUnit is the transient systemd unit which heals the
+	// partition of a node after --partition-duration.
+	partitionHealUnit = "roachtest-partition-heal"
+)
 
-// Cleanup removes the network partition created by the operation.
-func (np *cleanupNetworkPartition) Cleanup(
-	ctx context.Context, o operation.Operation, c cluster.Cluster,
-) {
-	o.Status(fmt.Sprintf("remove the partition on node n%d", np.nodeID))
-	c.Run(ctx, option.WithNodes(c.Node(np.nodeID)), `sudo iptables -F`)
+type partitionMode int
+
+const (
+	// partitionFull drops the traffic of the nodes with all other hosts, in
+	// both directions.
+	partitionFull partitionMode = iota
+	// partitionSymmetric drops the traffic between the nodes and their peers,
+	// in both directions.
+	partitionSymmetric
+	// partitionAsymmetric drops the packets of the connections which the peers
+	// open to the nodes, received by the nodes, while the connections opened by
+	// the nodes are left alone. This is representative of the accidental
+	// firewall rules which have caused such outages in the wild.
+	partitionAsymmetric
+)
+
+// setupPartitionCmd creates the chains of a partition. It fails if the node is
+// already partitioned, e.g. by a concurrent operation.
+var setupPartitionCmd = fmt.Sprintf(
+	`sudo iptables -N %[1]s && sudo iptables -N %[2]s && `+
+		`sudo iptables -I INPUT -j %[1]s && sudo iptables -I OUTPUT -j %[2]s`,
+	partitionInputChain, partitionOutputChain)
+
+// healPartitionScript removes the chains of a partition, if any. It always
+// succeeds, so that healing is idempotent.
+var healPartitionScript = fmt.Sprintf(
+	`iptables -D INPUT -j %[1]s; iptables -D OUTPUT -j %[2]s; `+
+		`iptables -F %[1]s; iptables -F %[2]s; iptables -X %[1]s; iptables -X %[2]s; true`,
+	partitionInputChain, partitionOutputChain)
+
+// scheduleHealCmd returns the command which heals the partition of a node
+// after the given duration, should the cleanup of the operation not run.
+func scheduleHealCmd(after time.Duration) string {
+	return fmt.Sprintf(
+		`sudo systemctl stop %[1]s.timer 2>/dev/null; sudo systemctl reset-failed %[1]s 2>/dev/null; `+
+			`sudo systemd-run --unit=%[1]s --on-active=%[2]ds /bin/bash -c '%[3]s'`,
+		partitionHealUnit, int(after.Seconds()), healPartitionScript)
 }
 
-// createNetworkPartition creates a network partition between two random nodes.
-func createNetworkPartialPartition(
-	ctx context.Context, o operation.Operation, c cluster.Cluster,
-) registry.OperationCleanup {
-	nodeCount := c.Spec().NodeCount
-	if nodeCount <= 1 {
-		o.Fatal("not enough nodes to create a partition")
+// healPartitionCmd heals the partition of a node, and cancels its scheduled
+// healing.
+var healPartitionCmd = fmt.Sprintf(`sudo systemctl stop %s.timer 2>/dev/null; sudo bash -c '%s'`,
+	partitionHealUnit, healPartitionScript)
+
+// partitionRules returns the iptables rules, as arguments of `iptables -A`,
+// which partition the node listening on the given port from the peers with the
+// given IPs, or from all other hosts if there are none.
+func partitionRules(mode partitionMode, pgport string, peerIPs []string) []string {
+	if len(peerIPs) == 0 {
+		peerIPs = []string{""}
 	}
-	nodeID := rand.Intn(nodeCount) + 1
-
-	// Create a partial partition between two random nodes.
-	var otherNodeID int
-	// Choose a different nodeID to partition from.
-	for {
-		otherNodeID = rand.Intn(nodeCount) + 1
-		if otherNodeID != nodeID {
-			break
+	var rules []string
+	for _, ip := range peerIPs {
+		var src, dst string
+		if ip != "" {
+			src, dst = " -s "+ip, " -d "+ip
 		}
+		// Inbound TCP connections, received packets.
+		rules = append(rules, fmt.Sprintf("%s -p tcp%s --dport %s -j DROP", partitionInputChain, src, pgport))
+		if mode == partitionAsymmetric {
+			continue
+		}
+		// Dropping a single direction of each connection would still let e.g.
+		// TCP retransmits through, which is not representative of real network
+		// outages, so packets are dropped in both directions.
+		rules = append(rules,
+			// Inbound TCP connections, sent packets.
+			fmt.Sprintf("%s -p tcp%s --sport %s -j DROP", partitionOutputChain, dst, pgport),
+			// Outbound TCP connections, sent and received packets.
+			fmt.Sprintf("%s -p tcp%s --dport %s -j DROP", partitionOutputChain, dst, pgport),
+			fmt.Sprintf("%s -p tcp%s --sport %s -j DROP", partitionInputChain, src, pgport),
+		)
 	}
+	return rules
+}
 
-	// Get the internal IPs of the remote node.
-	ips, err := c.InternalIP(ctx, o.L(), c.Node(otherNodeID))
+// partitionNodeSets returns the nodes to partition, and their peers for
+// partitions which aren't full. They are given by --partition-nodes and
+// --partition-peers, or picked randomly otherwise.
+func partitionNodeSets(
+	rng *rand.Rand, mode partitionMode, nodeCount int, nodesSelector, peersSelector string,
+) (nodes, peers option.NodeListOption, _ error) {
+	if nodeCount <= 1 {
+		return nil, nil, errors.New("not enough nodes to create a partition")
+	}
+	nodes, err := selectNodes(rng, nodeCount, nodesSelector, nil /* exclude */)
 	if err != nil {
-		o.Fatal(err)
+		return nil, nil, err
+	}
+	if mode == partitionFull {
+		return nodes, nil, nil
 	}
-	otherNodeIP := ips[0]
-	o.Status(fmt.Sprintf("creating a partition between nodes n%d and n%d", nodeID, otherNodeID))
-	// Block all input and output traffic between the two nodes.
-	c.Run(ctx, option.WithNodes(c.Node(nodeID)), fmt.Sprintf(`sudo iptables -A INPUT  -p tcp -s %s -j DROP`, otherNodeIP))
-	c.Run(ctx, option.WithNodes(c.Node(nodeID)), fmt.Sprintf(`sudo iptables -A OUTPUT -p tcp -d %s -j DROP`, otherNodeIP))
+	if peers, err = selectNodes(rng, nodeCount, peersSelector, nodes); err != nil {
+		return nil, nil, err
+	}
+	for _, n := range peers {
+		if slices.Contains(nodes, n) {
+			return nil, nil, errors.Newf("node n%d is on both sides of the partition", n)
+		}
+	}
+	return nodes, peers, nil
+}
+
+type cleanupNetworkPartition struct {
+	// Nodes are the partitioned nodes.
+	Nodes []int `json:"nodes"`
+	// NodeID is the partitioned node of the cleanups persisted before the
+	// partitions were held in dedicated chains, which are healed by flushing
+	// all the rules of the node.
+	NodeID int `json:"node_id,omitempty"`
+}
 
-	return &cleanupNetworkPartition{nodeID: nodeID}
+func newCleanupNetworkPartition() registry.OperationCleanup {
+	return &cleanupNetworkPartition{}
 }
 
-// createNetworkFullPartition creates a network partition between a random node
-// and all other nodes.
-func createNetworkFullPartition(
+// Cleanup removes the network partition created by the operation.
+func (np *cleanupNetworkPartition) Cleanup(
 	ctx context.Context, o operation.Operation, c cluster.Cluster,
-) registry.OperationCleanup {
-	nodeCount := c.Spec().NodeCount
-	if nodeCount <= 1 {
-		o.Fatal("not enough nodes to create a partition")
+) {
+	if np.NodeID != 0 {
+		o.Status(fmt.Sprintf("remove the partition on node n%d", np.NodeID))
+		c.Run(ctx, option.WithNodes(c.Node(np.NodeID)), `sudo iptables -F`)
+		return
 	}
-	nodeID := rand.Intn(nodeCount) + 1
+	o.Status(fmt.Sprintf("remove the partition on nodes %v", np.Nodes))
+	c.Run(ctx, option.WithNodes(np.Nodes), healPartitionCmd)
+}
 
-	// Drop bi-directional traffic between the node and all other nodes on the
-	// pgport.
-	o.Status(fmt.Sprintf("partition node n%d from the cluster", nodeID))
-	c.Run(ctx, option.WithNodes(c.Node(nodeID)), fmt.Sprintf(`sudo iptables -A INPUT  -p tcp --sport {pgport:%d} -j DROP`, nodeID))
-	c.Run(ctx, option.WithNodes(c.Node(nodeID)), fmt.Sprintf(`sudo iptables -A OUTPUT -p tcp --sport {pgport:%d} -j DROP`, nodeID))
-	c.Run(ctx, option.WithNodes(c.Node(nodeID)), fmt.Sprintf(`sudo iptables -A INPUT  -p tcp --dport {pgport:%d} -j DROP`, nodeID))
-	c.Run(ctx, option.WithNodes(c.Node(nodeID)), fmt.Sprintf(`sudo iptables -A OUTPUT -p tcp --dport {pgport:%d} -j DROP`, nodeID))
+// createNetworkPartition returns the Run function of the operation which
+// creates a partition of the given mode.
+func createNetworkPartition(
+	mode partitionMode,
+) func(ctx context.Context, o operation.Operation, c cluster.Cluster) registry.OperationCleanup {
+	return func(ctx context.Context, o operation.Operation, c cluster.Cluster) registry.OperationCleanup {
+		rng, _ := randutil.NewPseudoRand()
+		nodes, peers, err := partitionNodeSets(
+			rng, mode, len(c.All()), roachtestflags.PartitionNodes, roachtestflags.PartitionPeers)
+		if err != nil {
+			o.Fatal(err)
+		}
+
+		var peerIPs []string
+		if len(peers) > 0 {
+			if peerIPs, err = c.InternalIP(ctx, o.L(), peers); err != nil {
+				o.Fatal(err)
+			}
+			o.Status(fmt.Sprintf("partitioning nodes %v from nodes %v", []int(nodes), []int(peers)))
+		} else {
+			o.Status(fmt.Sprintf("partitioning nodes %v from the cluster", []int(nodes)))
+		}
 
-	return &cleanupNetworkPartition{nodeID: nodeID}
+		cleanup := &cleanupNetworkPartition{}
+		for _, n := range nodes {
+			c.Run(ctx, option.WithNodes(c.Node(n)), setupPartitionCmd)
+			// From now on, the node heals itself if the cleanup doesn't run.
+			cleanup.Nodes = append(cleanup.Nodes, n)
+			c.Run(ctx, option.WithNodes(c.Node(n)), scheduleHealCmd(roachtestflags.PartitionDuration))
+
+			var cmds []string
+			for _, rule := range partitionRules(mode, fmt.Sprintf("{pgport:%d}", n), peerIPs) {
+				cmds = append(cmds, "sudo iptables -A "+rule)
+			}
+			c.Run(ctx, option.WithNodes(c.Node(n)), strings.Join(cmds, " && "))
+		}
+		return cleanup
+	}
 }
 
-// registerNetworkPartition registers both the full and partial network
+// registerNetworkPartition registers the full, partial and asymmetric network
 // partition operations.
 func registerNetworkPartition(r registry.Registry) {
-	r.AddOperation(registry.OperationSpec{
-		Name:             "network-partition/full",
-		Owner:            registry.OwnerKV,
-		Timeout:          1 * time.Minute,
-		CompatibleClouds: registry.AllClouds,
-		Dependencies:     []registry.OperationDependency{registry.OperationRequiresZeroUnderreplicatedRanges},
-		Run:              createNetworkFullPartition,
-	})
-	r.AddOperation(registry.OperationSpec{
-		Name:             "network-partition/partial",
-		Owner:            registry.OwnerKV,
-		Timeout:          1 * time.Minute,
-		CompatibleClouds: registry.AllClouds,
-		Dependencies:     []registry.OperationDependency{registry.OperationRequiresZeroUnderreplicatedRanges},
-		Run:              createNetworkPartialPartition,
-	})
+	for _, op := range []struct {
+		name string
+		mode partitionMode
+	}{
+		{"full", partitionFull},
+		{"partial", partitionSymmetric},
+		{"asymmetric", partitionAsymmetric},
+	} {
+		r.AddOperation(registry.OperationSpec{
+			Name:    "network-partition/" + op.name,
+			Owner:   registry.OwnerKV,
+			Timeout: 1 * time.Minute,
+			// The partitions are made with iptables, which would partition the
+			// host of local clusters.
+			CompatibleClouds: registry.AllExceptLocal,
+			Dependencies: []registry.OperationDependency{
+				registry.OperationRequiresZeroUnavailableRanges,
+				registry.OperationRequiresZeroUnderreplicatedRanges,
+			},
+			Run:        createNetworkPartition(op.mode),
+			NewCleanup: newCleanupNetworkPartition,
+		})
+	}
 }
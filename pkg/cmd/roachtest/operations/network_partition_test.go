@@ -0,0 +1,74 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package operations
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/option"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPartitionRules(t *testing.T) {
+	require.Equal(t, []string{
+		"ROACHTEST_PARTITION_IN -p tcp --dport 26257 -j DROP",
+		"ROACHTEST_PARTITION_OUT -p tcp --sport 26257 -j DROP",
+		"ROACHTEST_PARTITION_OUT -p tcp --dport 26257 -j DROP",
+		"ROACHTEST_PARTITION_IN -p tcp --sport 26257 -j DROP",
+	}, partitionRules(partitionFull, "26257", nil))
+
+	require.Equal(t, []string{
+		"ROACHTEST_PARTITION_IN -p tcp -s 10.0.0.2 --dport 26257 -j DROP",
+		"ROACHTEST_PARTITION_OUT -p tcp -d 10.0.0.2 --sport 26257 -j DROP",
+		"ROACHTEST_PARTITION_OUT -p tcp -d 10.0.0.2 --dport 26257 -j DROP",
+		"ROACHTEST_PARTITION_IN -p tcp -s 10.0.0.2 --sport 26257 -j DROP",
+	}, partitionRules(partitionSymmetric, "26257", []string{"10.0.0.2"}))
+
+	require.Equal(t, []string{
+		"ROACHTEST_PARTITION_IN -p tcp -s 10.0.0.2 --dport 26257 -j DROP",
+		"ROACHTEST_PARTITION_IN -p tcp -s 10.0.0.3 --dport 26257 -j DROP",
+	}, partitionRules(partitionAsymmetric, "26257", []string{"10.0.0.2", "10.0.0.3"}))
+}
+
+func TestPartitionNodeSets(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	nodes, peers, err := partitionNodeSets(rng, partitionSymmetric, 5, "1-2", "4,5")
+	require.NoError(t, err)
+	require.Equal(t, option.NodeListOption{1, 2}, nodes)
+	require.Equal(t, option.NodeListOption{4, 5}, peers)
+
+	// Full partitions have no peers.
+	nodes, peers, err = partitionNodeSets(rng, partitionFull, 5, "3", "4")
+	require.NoError(t, err)
+	require.Equal(t, option.NodeListOption{3}, nodes)
+	require.Empty(t, peers)
+
+	// Unset node sets are a single random node each, on different sides.
+	for i := 0; i < 20; i++ {
+		nodes, peers, err = partitionNodeSets(rng, partitionAsymmetric, 3, "", "")
+		require.NoError(t, err)
+		require.Len(t, nodes, 1)
+		require.Len(t, peers, 1)
+		require.NotEqual(t, nodes[0], peers[0])
+	}
+	nodes, peers, err = partitionNodeSets(rng, partitionSymmetric, 3, "1-2", "")
+	require.NoError(t, err)
+	require.Equal(t, option.NodeListOption{3}, peers)
+
+	_, _, err = partitionNodeSets(rng, partitionSymmetric, 5, "1-3", "3-5")
+	require.ErrorContains(t, err, "n3 is on both sides")
+	_, _, err = partitionNodeSets(rng, partitionSymmetric, 3, "all", "")
+	require.ErrorContains(t, err, "no node left")
+	_, _, err = partitionNodeSets(rng, partitionFull, 1, "", "")
+	require.ErrorContains(t, err, "not enough nodes")
+}
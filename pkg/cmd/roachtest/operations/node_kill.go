@@ -24,14 +24,18 @@ import (
 )
 
 type cleanupNodeKill struct {
-	nodes option.NodeListOption
+	Nodes option.NodeListOption `json:"nodes"`
+}
+
+func newCleanupNodeKill() registry.OperationCleanup {
+	return &cleanupNodeKill{}
 }
 
 func (cl *cleanupNodeKill) Cleanup(ctx context.Context, o operation.Operation, c cluster.Cluster) {
 	// We might need to restart the node if it isn't live.
-	db, err := c.ConnE(ctx, o.L(), cl.nodes[0])
+	db, err := c.ConnE(ctx, o.L(), cl.Nodes[0])
 	if err != nil {
-		err = c.RunE(ctx, option.WithNodes(cl.nodes), "./cockroach.sh")
+		err = c.RunE(ctx, option.WithNodes(cl.Nodes), "./cockroach.sh")
 		if err != nil {
 			o.Status(fmt.Sprintf("restarted node with error %s", err))
 		} else {
@@ -42,7 +46,7 @@ func (cl *cleanupNodeKill) Cleanup(ctx context.Context, o operation.Operation, c
 	defer db.Close()
 	_, err = db.Query("SELECT 1")
 	if err != nil {
-		err = c.RunE(ctx, option.WithNodes(cl.nodes), "./cockroach.sh")
+		err = c.RunE(ctx, option.WithNodes(cl.Nodes), "./cockroach.sh")
 		if err != nil {
 			o.Status(fmt.Sprintf("restarted node with error %s", err))
 		} else {
@@ -93,7 +97,7 @@ func runNodeKill(
 	}
 
 	return &cleanupNodeKill{
-		nodes: node,
+		Nodes: node,
 	}
 }
 
@@ -105,6 +109,7 @@ func registerNodeKill(r registry.Registry) {
 		CompatibleClouds: registry.AllClouds,
 		Dependencies:     []registry.OperationDependency{registry.OperationRequiresZeroUnderreplicatedRanges},
 		Run:              nodeKillRunner(9 /* signal */, true /* drain */),
+		NewCleanup:       newCleanupNodeKill,
 	})
 	r.AddOperation(registry.OperationSpec{
 		Name:             "node-kill/sigkill/drain=false",
@@ -113,6 +118,7 @@ func registerNodeKill(r registry.Registry) {
 		CompatibleClouds: registry.AllClouds,
 		Dependencies:     []registry.OperationDependency{registry.OperationRequiresZeroUnderreplicatedRanges},
 		Run:              nodeKillRunner(9 /* signal */, false /* drain */),
+		NewCleanup:       newCleanupNodeKill,
 	})
 	r.AddOperation(registry.OperationSpec{
 		Name:             "node-kill/sigterm/drain=true",
@@ -121,6 +127,7 @@ func registerNodeKill(r registry.Registry) {
 		CompatibleClouds: registry.AllClouds,
 		Dependencies:     []registry.OperationDependency{registry.OperationRequiresZeroUnderreplicatedRanges},
 		Run:              nodeKillRunner(15 /* signal */, true /* drain */),
+		NewCleanup:       newCleanupNodeKill,
 	})
 	r.AddOperation(registry.OperationSpec{
 		Name:             "node-kill/sigterm/drain=false",
@@ -129,5 +136,6 @@ func registerNodeKill(r registry.Registry) {
 		CompatibleClouds: registry.AllClouds,
 		Dependencies:     []registry.OperationDependency{registry.OperationRequiresZeroUnderreplicatedRanges},
 		Run:              nodeKillRunner(15 /* signal */, false /* drain */),
+		NewCleanup:       newCleanupNodeKill,
 	})
 }
@@ -0,0 +1,390 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package operations
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/cluster"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/operation"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/option"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/roachtestutil"
+	"github.com/cockroachdb/cockroach/pkg/util/randutil"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+)
+
+const (
+	// nodeStartTimeout is how long a node has to accept SQL connections once
+	// started.
+	nodeStartTimeout = 5 * time.Minute
+	// decommissionProgressTimeout is how long a decommissioning node has to
+	// start shedding replicas.
+	decommissionProgressTimeout = 5 * time.Minute
+	// rangeRecoveryTimeout is how long the ranges have to be fully replicated
+	// again once a node was replaced or recommissioned.
+	rangeRecoveryTimeout = 30 * time.Minute
+)
+
+// The steps below make up the node lifecycle operations. They take roachprod
+// node indexes, which differ from the IDs of the cockroach nodes once nodes
+// have been replaced.
+
+// nodeID returns the ID of the cockroach node running on the given node, which
+// has nodeStartTimeout to accept SQL connections.
+func nodeID(ctx context.Context, o operation.Operation, c cluster.Cluster, node int) int {
+	deadline := timeutil.Now().Add(nodeStartTimeout)
+	for {
+		id, err := func() (int, error) {
+			db, err := c.ConnE(ctx, o.L(), node)
+			if err != nil {
+				return 0, err
+			}
+			defer db.Close()
+			var id int
+			err = db.QueryRowContext(ctx, "SELECT crdb_internal.node_id()").Scan(&id)
+			return id, err
+		}()
+		if err == nil {
+			return id
+		}
+		if ctx.Err() != nil || timeutil.Now().After(deadline) {
+			o.Fatalf("failed to get the node ID of node %d: %v", node, err)
+		}
+		time.Sleep(5 * time.Second)
+	}
+}
+
+// otherNode returns a cockroach node of the cluster other than the given one,
+// to run commands against the cluster while that node is down.
+func otherNode(c cluster.Cluster, node int) int {
+	for _, n := range c.CRDBNodes() {
+		if n != node {
+			return n
+		}
+	}
+	return 0
+}
+
+// joinNode is the node through which the cockroach nodes join the cluster, as
+// set in their cockroach.sh script: roachprod points --join at the init
+// target, which defaults to node 1.
+const joinNode = 1
+
+// replaceableNodes returns the cockroach nodes which can be replaced by a
+// fresh node. A fresh node on the join node would only join itself, and wait
+// for the cluster to be initialized forever.
+func replaceableNodes(c cluster.Cluster) option.NodeListOption {
+	var nodes option.NodeListOption
+	for _, n := range c.CRDBNodes() {
+		if n != joinNode {
+			nodes = append(nodes, n)
+		}
+	}
+	return nodes
+}
+
+// maxRangeReplicas returns the largest number of replicas of a range, i.e.
+// the replication factor of the cluster, as seen from node via.
+func maxRangeReplicas(ctx context.Context, o operation.Operation, c cluster.Cluster, via int) int {
+	db, err := c.ConnE(ctx, o.L(), via)
+	if err != nil {
+		o.Fatal(err)
+	}
+	defer db.Close()
+	var replicas int
+	if err := db.QueryRowContext(ctx,
+		"SELECT COALESCE(max(array_length(replicas, 1)), 0) FROM crdb_internal.ranges_no_leases",
+	).Scan(&replicas); err != nil {
+		o.Fatal(err)
+	}
+	return replicas
+}
+
+// killNode kills the cockroach process of the given node with SIGKILL, and
+// waits for it to exit.
+func killNode(ctx context.Context, o operation.Operation, c cluster.Cluster, node int) {
+	o.Status(fmt.Sprintf("killing node %d", node))
+	c.Run(ctx, option.WithNodes(c.Node(node)), "pkill", "-9", "-f", "cockroach\\ start")
+	for nodeProcessState(ctx, o, c, c.Node(node)) != "stopped" {
+		if err := ctx.Err(); err != nil {
+			o.Fatal(err)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+// clearStore removes the store of the given stopped node, which held the
+// cockroach node with the given ID. The store is wiped along with the logs of
+// the node if wipe is set, and moved aside otherwise so that it can still be
+// inspected; it is then up to the operator to remove it.
+func clearStore(
+	ctx context.Context, o operation.Operation, c cluster.Cluster, node, id int, wipe bool,
+) {
+	if wipe {
+		o.Status(fmt.Sprintf("wiping node %d", node))
+		if err := c.WipeE(ctx, o.L(), c.Node(node)); err != nil {
+			o.Fatal(err)
+		}
+		return
+	}
+	o.Status(fmt.Sprintf("moving the store of node %d aside", node))
+	c.Run(ctx, option.WithNodes(c.Node(node)), fmt.Sprintf("mv {store-dir} {store-dir}.n%d", id))
+}
+
+// decommissionNodeID decommissions the cockroach node with the given ID, which
+// may be dead, by running the command on node via. If wait is false, the node
+// is only marked as decommissioning.
+func decommissionNodeID(
+	ctx context.Context, o operation.Operation, c cluster.Cluster, id, via int, wait bool,
+) {
+	o.Status(fmt.Sprintf("decommissioning n%d from node %d (wait=%t)", id, via, wait))
+	addr, err := c.InternalAddr(ctx, o.L(), c.Node(via))
+	if err != nil {
+		o.Fatal(err)
+	}
+	cmd := roachtestutil.NewCommand("./%s node decommission %d", o.ClusterCockroach(), id).
+		WithEqualsSyntax().
+		Flag("host", addr[0]).
+		Flag("logtostderr", "INFO").
+		MaybeFlag(c.IsSecure(), "certs-dir", "certs").
+		MaybeOption(!c.IsSecure(), "insecure").
+		MaybeFlag(!wait, "wait", "none")
+	c.Run(ctx, option.WithNodes(c.Node(via)), cmd.String())
+}
+
+// recommissionNodeID recommissions the decommissioning cockroach node with the
+// given ID, by running the command on node via.
+func recommissionNodeID(
+	ctx context.Context, o operation.Operation, c cluster.Cluster, id, via int,
+) {
+	o.Status(fmt.Sprintf("recommissioning n%d from node %d", id, via))
+	addr, err := c.InternalAddr(ctx, o.L(), c.Node(via))
+	if err != nil {
+		o.Fatal(err)
+	}
+	cmd := roachtestutil.NewCommand("./%s node recommission %d", o.ClusterCockroach(), id).
+		WithEqualsSyntax().
+		Flag("host", addr[0]).
+		Flag("logtostderr", "INFO").
+		MaybeFlag(c.IsSecure(), "certs-dir", "certs").
+		MaybeOption(!c.IsSecure(), "insecure")
+	c.Run(ctx, option.WithNodes(c.Node(via)), cmd.String())
+}
+
+// startFreshNode starts a cockroach node with an empty store on the given
+// node, which joins the cluster under a new node ID, and returns that ID.
+func startFreshNode(ctx context.Context, o operation.Operation, c cluster.Cluster, node int) int {
+	o.Status(fmt.Sprintf("starting a fresh node on node %d", node))
+	c.Run(ctx, option.WithNodes(c.Node(node)), "./cockroach.sh")
+	id := nodeID(ctx, o, c, node)
+	o.Status(fmt.Sprintf("node %d joined the cluster as n%d", node, id))
+	return id
+}
+
+// nodeReplicas returns the number of replicas held by the stores of the
+// cockroach node with the given ID, as seen from node via.
+func nodeReplicas(ctx context.Context, o operation.Operation, c cluster.Cluster, id, via int) int {
+	db, err := c.ConnE(ctx, o.L(), via)
+	if err != nil {
+		o.Fatal(err)
+	}
+	defer db.Close()
+	var replicas int
+	if err := db.QueryRowContext(ctx,
+		`SELECT COALESCE(sum((metrics->>'replicas')::DECIMAL), 0)::INT
+		   FROM crdb_internal.kv_store_status WHERE node_id = $1`, id,
+	).Scan(&replicas); err != nil {
+		o.Fatal(err)
+	}
+	return replicas
+}
+
+// waitForRangeRecovery waits until no range is unavailable or under-replicated
+// and the cockroach node with the given ID holds replicas, as seen from node
+// via.
+func waitForRangeRecovery(
+	ctx context.Context, o operation.Operation, c cluster.Cluster, id, via int,
+) {
+	db, err := c.ConnE(ctx, o.L(), via)
+	if err != nil {
+		o.Fatal(err)
+	}
+	defer db.Close()
+
+	o.Status("waiting for the ranges to be fully replicated")
+	deadline := timeutil.Now().Add(rangeRecoveryTimeout)
+	for {
+		var unavailable, underreplicated, replicas int
+		if err := db.QueryRowContext(ctx, `
+SELECT sum((metrics->>'ranges.unavailable')::DECIMAL)::INT,
+       sum((metrics->>'ranges.underreplicated')::DECIMAL)::INT,
+       sum(CASE WHEN node_id = $1 THEN (metrics->>'replicas')::DECIMAL ELSE 0 END)::INT
+  FROM crdb_internal.kv_store_status`, id,
+		).Scan(&unavailable, &underreplicated, &replicas); err != nil {
+			o.Fatal(err)
+		}
+		if unavailable == 0 && underreplicated == 0 && replicas > 0 {
+			o.Status(fmt.Sprintf("ranges recovered; n%d holds %d replicas", id, replicas))
+			return
+		}
+		if ctx.Err() != nil || timeutil.Now().After(deadline) {
+			o.Fatalf("ranges did not recover within %s: %d unavailable, %d under-replicated, "+
+				"%d replicas on n%d", rangeRecoveryTimeout, unavailable, underreplicated, replicas, id)
+		}
+		o.L().Printf("%d unavailable and %d under-replicated ranges, %d replicas on n%d",
+			unavailable, underreplicated, replicas, id)
+		time.Sleep(10 * time.Second)
+	}
+}
+
+// nodeReplaceRunner returns the Run function of an operation which replaces a
+// random node, other than the join node, by a fresh one on the same machine, as an operator would replace
+// a node whose store was lost: the node is killed, its store wiped or moved
+// aside, and it is decommissioned while the fresh node takes over its
+// replicas. If the replacement fails once the node was killed, the returned
+// cleanup restarts the node.
+func nodeReplaceRunner(
+	wipe bool,
+) func(ctx context.Context, o operation.Operation, c cluster.Cluster) registry.OperationCleanup {
+	return func(ctx context.Context, o operation.Operation, c cluster.Cluster) registry.OperationCleanup {
+		candidates := replaceableNodes(c)
+		if len(candidates) == 0 {
+			o.Fatal("not enough nodes to replace a node")
+		}
+		rng, _ := randutil.NewPseudoRand()
+		node := candidates.SeededRandNode(rng)[0]
+		via := otherNode(c, node)
+		oldID := nodeID(ctx, o, c, node)
+
+		killNode(ctx, o, c, node)
+		// Failures past this point must not prevent the cleanup from being
+		// returned, which restarts the node.
+		if !runUntilFatal(o, func() {
+			clearStore(ctx, o, c, node, oldID, wipe)
+			// Mark the dead node as decommissioning before the fresh node
+			// joins, and only wait for its replicas to move once there's room
+			// for them on clusters at their replication factor.
+			decommissionNodeID(ctx, o, c, oldID, via, false /* wait */)
+			newID := startFreshNode(ctx, o, c, node)
+			decommissionNodeID(ctx, o, c, oldID, via, true /* wait */)
+			waitForRangeRecovery(ctx, o, c, newID, via)
+		}) {
+			return &cleanupNodeKill{Nodes: c.Node(node)}
+		}
+		return nil
+	}
+}
+
+// runUntilFatal runs fn, and returns false if it failed the operation with
+// o.Fatal(), which is then recovered from so that the caller can return a
+// cleanup. Other panics are left alone.
+func runUntilFatal(o operation.Operation, fn func()) (ok bool) {
+	defer func() {
+		if r := recover(); r != nil {
+			if !o.Failed() {
+				panic(r)
+			}
+			ok = false
+		}
+	}()
+	fn()
+	return true
+}
+
+type cleanupRecommission struct {
+	// Node is the roachprod node running the decommissioning cockroach node.
+	Node int `json:"node"`
+	// NodeID is the ID of the decommissioning cockroach node.
+	NodeID int `json:"node_id"`
+}
+
+func newCleanupRecommission() registry.OperationCleanup {
+	return &cleanupRecommission{}
+}
+
+// Cleanup recommissions the node, and waits for it to hold replicas again.
+func (cl *cleanupRecommission) Cleanup(
+	ctx context.Context, o operation.Operation, c cluster.Cluster,
+) {
+	recommissionNodeID(ctx, o, c, cl.NodeID, cl.Node)
+	waitForRangeRecovery(ctx, o, c, cl.NodeID, cl.Node)
+}
+
+// runDecommissionRecommission starts decommissioning a random node, and waits
+// for it to shed replicas. The node is recommissioned by the cleanup. The
+// cluster must have more nodes than its replication factor, so that the
+// replicas of the node have somewhere to go.
+func runDecommissionRecommission(
+	ctx context.Context, o operation.Operation, c cluster.Cluster,
+) registry.OperationCleanup {
+	rng, _ := randutil.NewPseudoRand()
+	node := c.CRDBNodes().SeededRandNode(rng)[0]
+	if rf := maxRangeReplicas(ctx, o, c, node); len(c.CRDBNodes()) <= rf {
+		o.Fatalf("can't decommission a node of a %d-node cluster with %d replicas per range",
+			len(c.CRDBNodes()), rf)
+	}
+	id := nodeID(ctx, o, c, node)
+	before := nodeReplicas(ctx, o, c, id, node)
+
+	decommissionNodeID(ctx, o, c, id, node, false /* wait */)
+	cleanup := &cleanupRecommission{Node: node, NodeID: id}
+
+	// Errors past this point must not prevent the cleanup from being returned,
+	// which recommissions the node.
+	o.Status(fmt.Sprintf("waiting for n%d to shed some of its %d replicas", id, before))
+	deadline := timeutil.Now().Add(decommissionProgressTimeout)
+	for {
+		replicas := nodeReplicas(ctx, o, c, id, node)
+		if replicas < before {
+			o.Status(fmt.Sprintf("n%d is down to %d replicas", id, replicas))
+			break
+		}
+		if ctx.Err() != nil || timeutil.Now().After(deadline) {
+			o.Errorf("n%d still holds %d replicas %s after it started decommissioning",
+				id, replicas, decommissionProgressTimeout)
+			break
+		}
+		time.Sleep(5 * time.Second)
+	}
+	return cleanup
+}
+
+func registerNodeReplace(r registry.Registry) {
+	for _, wipe := range []bool{true, false} {
+		r.AddOperation(registry.OperationSpec{
+			Name:             fmt.Sprintf("node-replace/wipe=%t", wipe),
+			Owner:            registry.OwnerKV,
+			Timeout:          45 * time.Minute,
+			CompatibleClouds: registry.AllClouds,
+			Dependencies: []registry.OperationDependency{
+				registry.OperationRequiresZeroUnavailableRanges,
+				registry.OperationRequiresZeroUnderreplicatedRanges,
+			},
+			Run:        nodeReplaceRunner(wipe),
+			NewCleanup: newCleanupNodeKill,
+		})
+	}
+	r.AddOperation(registry.OperationSpec{
+		Name:             "node-decommission/recommission",
+		Owner:            registry.OwnerKV,
+		Timeout:          30 * time.Minute,
+		CompatibleClouds: registry.AllClouds,
+		Dependencies: []registry.OperationDependency{
+			registry.OperationRequiresZeroUnavailableRanges,
+			registry.OperationRequiresZeroUnderreplicatedRanges,
+		},
+		Run:        runDecommissionRecommission,
+		NewCleanup: newCleanupRecommission,
+	})
+}
@@ -19,6 +19,8 @@ func RegisterOperations(r registry.Registry) {
 	registerNetworkPartition(r)
 	registerDiskStall(r)
 	registerNodeKill(r)
+	registerNodeReplace(r)
+	registerResourcePressure(r)
 	registerClusterSettings(r)
 	registerBackupRestore(r)
 	registerManualCompaction(r)
@@ -24,24 +24,28 @@ import (
 )
 
 type cleanupResize struct {
-	origClusterSize int
-	growCount       int
+	OrigClusterSize int `json:"orig_cluster_size"`
+	GrowCount       int `json:"grow_count"`
+}
+
+func newCleanupResize() registry.OperationCleanup {
+	return &cleanupResize{}
 }
 
 // Cleanup shrinks the cluster back to its original size.
 func (cl *cleanupResize) Cleanup(ctx context.Context, o operation.Operation, c cluster.Cluster) {
 	dynamicCluster := c.(cluster.DynamicCluster)
 	defer func() {
-		err := dynamicCluster.Shrink(ctx, o.L(), cl.growCount)
+		err := dynamicCluster.Shrink(ctx, o.L(), cl.GrowCount)
 		if err != nil {
 			o.Status(fmt.Sprintf("error shrinking cluster: %s", err))
 		} else {
 			o.Status("shrunk cluster back to original size")
 		}
 	}()
-	for i := 0; i < cl.growCount; i++ {
-		drainNode(ctx, o, c, c.Node(cl.origClusterSize+i+1))
-		decommissionNode(ctx, o, c, c.Node(cl.origClusterSize+i+1))
+	for i := 0; i < cl.GrowCount; i++ {
+		drainNode(ctx, o, c, c.Node(cl.OrigClusterSize+i+1))
+		decommissionNode(ctx, o, c, c.Node(cl.OrigClusterSize+i+1))
 	}
 }
 
@@ -88,7 +92,7 @@ func resizeCluster(
 	startOpts.RoachprodOpts.IsRestart = true
 	c.Start(ctx, o.L(), startOpts, o.ClusterSettings(), newNodes)
 
-	return &cleanupResize{growCount: growCount, origClusterSize: origClusterSize}
+	return &cleanupResize{GrowCount: growCount, OrigClusterSize: origClusterSize}
 }
 
 func registerResize(r registry.Registry) {
@@ -101,5 +105,6 @@ func registerResize(r registry.Registry) {
 		Run: func(ctx context.Context, o operation.Operation, c cluster.Cluster) registry.OperationCleanup {
 			return resizeCluster(ctx, o, c, 3)
 		},
+		NewCleanup: newCleanupResize,
 	})
 }
@@ -0,0 +1,151 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package operations
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/cluster"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/operation"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/option"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/roachtestflags"
+	"github.com/cockroachdb/cockroach/pkg/util/randutil"
+)
+
+// pressureUnit is the transient systemd unit running stress-ng on the nodes
+// put under pressure, so that it can be stopped by the cleanup.
+const pressureUnit = "roachtest-pressure"
+
+type pressureKind string
+
+const (
+	// cpuPressure keeps all the CPUs of the nodes busy for the given
+	// percentage of the time.
+	cpuPressure pressureKind = "cpu"
+	// memoryPressure allocates, and keeps touching, the given percentage of
+	// the memory available on the nodes.
+	memoryPressure pressureKind = "memory"
+)
+
+// stressCmd returns the command which puts a node under the given pressure for
+// the given duration, after which stress-ng exits on its own.
+func stressCmd(kind pressureKind, percent int, duration time.Duration) string {
+	var args string
+	switch kind {
+	case cpuPressure:
+		args = fmt.Sprintf("--cpu 0 --cpu-load %d", percent)
+	case memoryPressure:
+		// The size is computed by the shell, as systemd would expand the
+		// percent sign of a relative --vm-bytes.
+		args = fmt.Sprintf(
+			"--vm 1 --vm-keep --vm-bytes $(( $(awk '/MemAvailable/ {print $2}' /proc/meminfo) * %d / 100 ))k",
+			percent)
+	}
+	return fmt.Sprintf(
+		`sudo systemctl stop %[1]s 2>/dev/null; sudo systemctl reset-failed %[1]s 2>/dev/null; `+
+			`sudo systemd-run --unit=%[1]s stress-ng %[2]s --timeout %[3]ds`,
+		pressureUnit, args, int(duration.Seconds()))
+}
+
+// stopPressureCmd stops the pressure on a node, if any. It always succeeds.
+var stopPressureCmd = fmt.Sprintf(
+	`sudo systemctl stop %[1]s 2>/dev/null; sudo systemctl reset-failed %[1]s 2>/dev/null; true`,
+	pressureUnit)
+
+type cleanupResourcePressure struct {
+	// Nodes are the nodes under pressure.
+	Nodes []int `json:"nodes"`
+}
+
+func newCleanupResourcePressure() registry.OperationCleanup {
+	return &cleanupResourcePressure{}
+}
+
+// Cleanup stops the pressure, and restarts the nodes which didn't survive it,
+// e.g. because they ran out of memory.
+func (cl *cleanupResourcePressure) Cleanup(
+	ctx context.Context, o operation.Operation, c cluster.Cluster,
+) {
+	o.Status(fmt.Sprintf("stopping the pressure on nodes %v", cl.Nodes))
+	c.Run(ctx, option.WithNodes(cl.Nodes), stopPressureCmd)
+	for _, n := range cl.Nodes {
+		if nodeProcessState(ctx, o, c, c.Node(n)) == "stopped" {
+			o.Status(fmt.Sprintf("restarting node n%d", n))
+			c.Run(ctx, option.WithNodes(c.Node(n)), "./cockroach.sh")
+		}
+	}
+}
+
+// resourcePressureRunner returns the Run function of an operation which puts
+// the nodes given by --pressure-nodes under the given pressure, for
+// --pressure-duration at most.
+func resourcePressureRunner(
+	kind pressureKind, percent int,
+) func(ctx context.Context, o operation.Operation, c cluster.Cluster) registry.OperationCleanup {
+	return func(ctx context.Context, o operation.Operation, c cluster.Cluster) registry.OperationCleanup {
+		rng, _ := randutil.NewPseudoRand()
+		nodes, err := selectNodes(rng, len(c.All()), roachtestflags.PressureNodes, nil /* exclude */)
+		if err != nil {
+			o.Fatal(err)
+		}
+		o.Status(fmt.Sprintf("installing stress-ng on nodes %v", []int(nodes)))
+		if err := c.Install(ctx, o.L(), nodes, "stress-ng"); err != nil {
+			o.Fatal(err)
+		}
+
+		tags := []string{
+			fmt.Sprintf("fault:%s-pressure", kind),
+			fmt.Sprintf("fault-percent:%d", percent),
+		}
+		for _, n := range nodes {
+			tags = append(tags, fmt.Sprintf("fault-node:%d", n))
+		}
+		o.AddEventTags(tags...)
+
+		o.Status(fmt.Sprintf("applying %d%% %s pressure to nodes %v for %s",
+			percent, kind, []int(nodes), roachtestflags.PressureDuration))
+		c.Run(ctx, option.WithNodes(nodes), stressCmd(kind, percent, roachtestflags.PressureDuration))
+		cleanup := &cleanupResourcePressure{Nodes: nodes}
+
+		// stress-ng fails right away if it can't apply the pressure.
+		time.Sleep(5 * time.Second)
+		if err := c.RunE(ctx, option.WithNodes(nodes), "systemctl", "is-active", "--quiet", pressureUnit); err != nil {
+			o.Errorf("stress-ng is not running on all of nodes %v: %v", []int(nodes), err)
+		}
+		return cleanup
+	}
+}
+
+func registerResourcePressure(r registry.Registry) {
+	for _, op := range []struct {
+		kind    pressureKind
+		percent int
+	}{
+		{cpuPressure, 50},
+		{cpuPressure, 100},
+		{memoryPressure, 50},
+		{memoryPressure, 90},
+	} {
+		r.AddOperation(registry.OperationSpec{
+			Name:    fmt.Sprintf("resource-pressure/%s=%d", op.kind, op.percent),
+			Owner:   registry.OwnerAdmissionControl,
+			Timeout: 10 * time.Minute,
+			// stress-ng would put the host of local clusters under pressure.
+			CompatibleClouds: registry.AllExceptLocal,
+			Dependencies:     []registry.OperationDependency{registry.OperationRequiresZeroUnderreplicatedRanges},
+			Run:              resourcePressureRunner(op.kind, op.percent),
+			NewCleanup:       newCleanupResourcePressure,
+		})
+	}
+}
@@ -0,0 +1,27 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package operations
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestStressCmd(t *testing.T) {
+	require.Contains(t, stressCmd(cpuPressure, 50, 10*time.Minute),
+		"systemd-run --unit=roachtest-pressure stress-ng --cpu 0 --cpu-load 50 --timeout 600s")
+	memCmd := stressCmd(memoryPressure, 90, time.Minute)
+	require.Contains(t, memCmd, "stress-ng --vm 1 --vm-keep --vm-bytes $((")
+	require.Contains(t, memCmd, "* 90 / 100 ))k --timeout 60s")
+	require.NotContains(t, memCmd, "%")
+}
@@ -14,18 +14,47 @@ import (
 	"context"
 	gosql "database/sql"
 	"fmt"
+	"math/rand"
+	"slices"
 
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/cluster"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/operation"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/option"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/roachtestutil"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/install"
 	"github.com/cockroachdb/cockroach/pkg/util/randutil"
+	"github.com/cockroachdb/errors"
 )
 
 // systemDBs lists dbs created by default on a new cockroachdb cluster. These
 // may not be mutable and should be excluded by most operations.
 var systemDBs = []string{"system", "information_schema", "crdb_internal", "defaultdb", "postgres"}
 
+// selectNodes returns the nodes given by the selector, e.g. '1-3' or '1,4', as
+// taken by the flags of the operations which target specific nodes. If the
+// selector is empty, a random node other than the excluded ones is returned.
+func selectNodes(
+	rng *rand.Rand, nodeCount int, selector string, exclude option.NodeListOption,
+) (option.NodeListOption, error) {
+	if selector != "" {
+		nodes, err := install.ListNodes(selector, nodeCount)
+		if err != nil {
+			return nil, err
+		}
+		return option.FromInstallNodes(nodes), nil
+	}
+	var candidates option.NodeListOption
+	for n := 1; n <= nodeCount; n++ {
+		if !slices.Contains(exclude, n) {
+			candidates = append(candidates, n)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, errors.New("no node left to select")
+	}
+	return option.NodeListOption{candidates[rng.Intn(len(candidates))]}, nil
+}
+
 // pickRandomDB picks a random DB that isn't one of `excludeDBs` on the
 // target cluster connected to by `conn`.
 func pickRandomDB(
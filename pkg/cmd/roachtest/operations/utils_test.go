@@ -0,0 +1,38 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package operations
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/option"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectNodes(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	nodes, err := selectNodes(rng, 5, "2-4", nil /* exclude */)
+	require.NoError(t, err)
+	require.Equal(t, option.NodeListOption{2, 3, 4}, nodes)
+
+	for i := 0; i < 20; i++ {
+		nodes, err = selectNodes(rng, 3, "", option.NodeListOption{2})
+		require.NoError(t, err)
+		require.Len(t, nodes, 1)
+		require.Contains(t, []int{1, 3}, nodes[0])
+	}
+
+	_, err = selectNodes(rng, 3, "5", nil /* exclude */)
+	require.Error(t, err)
+	_, err = selectNodes(rng, 2, "", option.NodeListOption{1, 2})
+	require.ErrorContains(t, err, "no node left to select")
+}
@@ -14,7 +14,21 @@ package option
 type NodeLister struct {
 	NodeCount               int
 	WorkloadNodeProvisioned bool
-	Fatalf                  func(string, ...interface{})
+	// WorkloadNodeCount is the number of workload nodes, if provisioned; zero
+	// means a single one.
+	WorkloadNodeCount int
+	// WorkloadNodeRoles optionally contains the role of each workload node.
+	WorkloadNodeRoles []string
+	Fatalf            func(string, ...interface{})
+}
+
+// numWorkloadNodes returns the number of workload nodes at the end of the
+// cluster.
+func (l NodeLister) numWorkloadNodes() int {
+	if !l.WorkloadNodeProvisioned {
+		return 0
+	}
+	return max(1, l.WorkloadNodeCount)
 }
 
 // All returns a list of all nodes.
@@ -24,10 +38,7 @@ func (l NodeLister) All() NodeListOption {
 
 // CRDBNodes returns a list of all CRDB nodes, i.e, non workload nodes.
 func (l NodeLister) CRDBNodes() NodeListOption {
-	if l.WorkloadNodeProvisioned {
-		return l.Range(1, l.NodeCount-1)
-	}
-	return l.Range(1, l.NodeCount)
+	return l.Range(1, l.NodeCount-l.numWorkloadNodes())
 }
 
 // Range returns only the nodes [begin, ..., end].
@@ -62,10 +73,29 @@ func (l NodeLister) Node(n int) NodeListOption {
 }
 
 // WorkloadNode returns the workload node—it assumes that one has
-// been created through the cluster spec WorkloadNode option.
+// been created through the cluster spec WorkloadNode option. If multiple
+// workload nodes were provisioned, all of them are returned.
 func (l NodeLister) WorkloadNode() NodeListOption {
 	if !l.WorkloadNodeProvisioned {
 		l.Fatalf("workload node specified but no workload nodes were provisioned by the cluster")
+		return nil
 	}
-	return l.Nodes(l.NodeCount)
+	return l.Range(l.NodeCount-l.numWorkloadNodes()+1, l.NodeCount)
+}
+
+// WorkloadNodesWithRole returns the workload nodes with the given role—it
+// assumes that they have been created through the cluster spec
+// WorkloadNodeRoles option.
+func (l NodeLister) WorkloadNodesWithRole(role string) NodeListOption {
+	first := l.NodeCount - l.numWorkloadNodes() + 1
+	var r NodeListOption
+	for i, nodeRole := range l.WorkloadNodeRoles {
+		if nodeRole == role {
+			r = append(r, first+i)
+		}
+	}
+	if len(r) == 0 {
+		l.Fatalf("no workload nodes with role %q were provisioned by the cluster", role)
+	}
+	return r
 }
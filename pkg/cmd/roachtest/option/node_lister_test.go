@@ -0,0 +1,45 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package option
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeListerWorkloadNodes(t *testing.T) {
+	fatalf := func(format string, args ...interface{}) {
+		panic(fmt.Sprintf(format, args...))
+	}
+
+	l := NodeLister{NodeCount: 4, WorkloadNodeProvisioned: true, Fatalf: fatalf}
+	require.Equal(t, NodeListOption{1, 2, 3}, l.CRDBNodes())
+	require.Equal(t, NodeListOption{4}, l.WorkloadNode())
+
+	l = NodeLister{
+		NodeCount:               7,
+		WorkloadNodeProvisioned: true,
+		WorkloadNodeCount:       3,
+		WorkloadNodeRoles:       []string{"loadgen", "backup-driver", "loadgen"},
+		Fatalf:                  fatalf,
+	}
+	require.Equal(t, NodeListOption{1, 2, 3, 4}, l.CRDBNodes())
+	require.Equal(t, NodeListOption{5, 6, 7}, l.WorkloadNode())
+	require.Equal(t, NodeListOption{5, 7}, l.WorkloadNodesWithRole("loadgen"))
+	require.Equal(t, NodeListOption{6}, l.WorkloadNodesWithRole("backup-driver"))
+	require.Panics(t, func() { l.WorkloadNodesWithRole("chaos-driver") })
+
+	l = NodeLister{NodeCount: 3, Fatalf: fatalf}
+	require.Equal(t, NodeListOption{1, 2, 3}, l.CRDBNodes())
+	require.Panics(t, func() { l.WorkloadNode() })
+}
@@ -0,0 +1,131 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/roachtestflags"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+	"github.com/cockroachdb/errors"
+)
+
+// pendingCleanup describes the cleanup of an operation which has yet to
+// complete. See registry.OperationSpec.NewCleanup.
+type pendingCleanup struct {
+	Operation string    `json:"operation"`
+	RunID     uint64    `json:"run_id"`
+	Created   time.Time `json:"created"`
+	// State is the JSON encoding of the registry.OperationCleanup.
+	State json.RawMessage `json:"state"`
+}
+
+// pendingCleanupRegistry persists the pending cleanups of the operations run
+// on a cluster, one file each, in a directory of its own.
+type pendingCleanupRegistry struct {
+	dir string
+}
+
+// newPendingCleanupRegistry returns the registry of the pending cleanups of the
+// given cluster, in --pending-cleanups-dir.
+func newPendingCleanupRegistry(clusterName string) *pendingCleanupRegistry {
+	dir := roachtestflags.PendingCleanupsDir
+	if dir == "" {
+		dir = filepath.Join(roachtestflags.ArtifactsDir, "pending-cleanups")
+	}
+	return &pendingCleanupRegistry{dir: filepath.Join(dir, clusterName)}
+}
+
+// path returns the path of the file of the given pending cleanup.
+func (r *pendingCleanupRegistry) path(opName string, runID uint64) string {
+	return filepath.Join(r.dir, fmt.Sprintf("%s-%d.json", strings.ReplaceAll(opName, "/", "_"), runID))
+}
+
+// add persists the given cleanup of a run of an operation. The file is
+// written atomically, so that a runner killed at any point doesn't leave a
+// partial cleanup behind.
+func (r *pendingCleanupRegistry) add(
+	opName string, runID uint64, cleanup registry.OperationCleanup,
+) error {
+	state, err := json.Marshal(cleanup)
+	if err != nil {
+		return errors.Wrap(err, "encoding cleanup")
+	}
+	data, err := json.MarshalIndent(pendingCleanup{
+		Operation: opName,
+		RunID:     runID,
+		Created:   timeutil.Now(),
+		State:     state,
+	}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(r.dir, 0755); err != nil {
+		return err
+	}
+	path := r.path(opName, runID)
+	if err := os.WriteFile(path+".tmp", append(data, '\n'), 0644); err != nil {
+		return errors.Wrap(err, "writing pending cleanup")
+	}
+	return errors.Wrap(os.Rename(path+".tmp", path), "writing pending cleanup")
+}
+
+// remove removes a pending cleanup once it has completed.
+func (r *pendingCleanupRegistry) remove(opName string, runID uint64) error {
+	if err := os.Remove(r.path(opName, runID)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "removing pending cleanup")
+	}
+	return nil
+}
+
+// list returns the pending cleanups, from oldest to newest.
+func (r *pendingCleanupRegistry) list() ([]pendingCleanup, error) {
+	paths, err := filepath.Glob(filepath.Join(r.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var res []pendingCleanup
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading pending cleanup")
+		}
+		var p pendingCleanup
+		if err := json.Unmarshal(data, &p); err != nil {
+			return nil, errors.Wrapf(err, "decoding pending cleanup %s", path)
+		}
+		res = append(res, p)
+	}
+	sort.SliceStable(res, func(i, j int) bool {
+		return res[i].Created.Before(res[j].Created)
+	})
+	return res, nil
+}
+
+// decodeCleanup returns the cleanup of the given operation described by a
+// pending cleanup.
+func decodeCleanup(opSpec *registry.OperationSpec, p pendingCleanup) (registry.OperationCleanup, error) {
+	if opSpec.NewCleanup == nil {
+		return nil, errors.Newf("operation %s doesn't support resuming cleanups", opSpec.Name)
+	}
+	cleanup := opSpec.NewCleanup()
+	if err := json.Unmarshal(p.State, cleanup); err != nil {
+		return nil, errors.Wrapf(err, "decoding cleanup of operation %s", opSpec.Name)
+	}
+	return cleanup, nil
+}
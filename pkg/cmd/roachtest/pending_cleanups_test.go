@@ -0,0 +1,70 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/cluster"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/operation"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/roachtestflags"
+	"github.com/stretchr/testify/require"
+)
+
+type testCleanup struct {
+	Table string `json:"table"`
+}
+
+func (*testCleanup) Cleanup(context.Context, operation.Operation, cluster.Cluster) {}
+
+func TestPendingCleanupRegistry(t *testing.T) {
+	defer func(dir string) { roachtestflags.PendingCleanupsDir = dir }(roachtestflags.PendingCleanupsDir)
+	roachtestflags.PendingCleanupsDir = t.TempDir()
+
+	r := newPendingCleanupRegistry("foo")
+	pending, err := r.list()
+	require.NoError(t, err)
+	require.Empty(t, pending)
+
+	require.NoError(t, r.add("add-column", 1, &testCleanup{Table: "a"}))
+	require.NoError(t, r.add("node-kill/sigkill", 2, &testCleanup{Table: "b"}))
+	// The pending cleanups of other clusters are kept apart.
+	require.NoError(t, newPendingCleanupRegistry("bar").add("add-column", 3, &testCleanup{}))
+
+	pending, err = r.list()
+	require.NoError(t, err)
+	require.Len(t, pending, 2)
+	require.Equal(t, "add-column", pending[0].Operation)
+	require.Equal(t, uint64(1), pending[0].RunID)
+	require.Equal(t, "node-kill/sigkill", pending[1].Operation)
+
+	spec := &registry.OperationSpec{
+		Name:       "add-column",
+		NewCleanup: func() registry.OperationCleanup { return &testCleanup{} },
+	}
+	cleanup, err := decodeCleanup(spec, pending[0])
+	require.NoError(t, err)
+	require.Equal(t, &testCleanup{Table: "a"}, cleanup)
+
+	spec.NewCleanup = nil
+	_, err = decodeCleanup(spec, pending[0])
+	require.ErrorContains(t, err, "doesn't support resuming cleanups")
+
+	// Completed cleanups are removed.
+	require.NoError(t, r.remove("add-column", 1))
+	require.NoError(t, r.remove("add-column", 1))
+	pending, err = r.list()
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+	require.Equal(t, "node-kill/sigkill", pending[0].Operation)
+}
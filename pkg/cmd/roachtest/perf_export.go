@@ -0,0 +1,281 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/roachtestflags"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/roachtestutil"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/logger"
+	"github.com/cockroachdb/cockroach/pkg/workload/histogram"
+	"github.com/cockroachdb/errors"
+	"github.com/codahale/hdrhistogram"
+)
+
+const (
+	// perfStatsFile is the name of the perf artifacts file holding the
+	// histograms recorded by workloads (see workload's --histograms flag).
+	perfStatsFile = "stats.json"
+	// openMetricsStatsFile is the name of the file holding the histograms of
+	// perfStatsFile in the OpenMetrics format.
+	openMetricsStatsFile = "stats.om"
+	// perfMetricsStatsFile is the name of the file holding the histograms of
+	// perfStatsFile in the schema of roachtestutil.PerfMetrics.
+	perfMetricsStatsFile = "stats.metrics.json"
+)
+
+// openMetricsQuantiles are the quantiles exported for each histogram.
+var openMetricsQuantiles = []float64{0.5, 0.95, 0.99, 1}
+
+// openMetricsInterval is the interval over which the ticks of the histograms,
+// usually recorded every second, are merged into a single point.
+const openMetricsInterval = 30 * time.Second
+
+// exportPerfArtifacts converts the perf artifacts of the given test run, which
+// have been fetched from the cluster, into the OpenMetrics format and the
+// schema of roachtestutil.PerfMetrics. If --roachperf-upload is set, both the
+// original and converted artifacts, as well as the PerfMetrics written by the
+// test itself, are uploaded there, with the same layout as in the artifacts
+// dir.
+func exportPerfArtifacts(
+	ctx context.Context, l *logger.Logger, artifactsRootDir string, t *testImpl,
+) error {
+	dirs, err := filepath.Glob(filepath.Join(t.ArtifactsDir(), "*."+perfArtifactsDir))
+	if err != nil {
+		return err
+	}
+	for _, dir := range dirs {
+		var toUpload []string
+		node := strings.TrimSuffix(filepath.Base(dir), "."+perfArtifactsDir)
+		labels := map[string]string{
+			"test":  t.Name(),
+			"cloud": roachtestflags.Cloud.String(),
+			"node":  node,
+		}
+		if path := filepath.Join(dir, perfStatsFile); fileExists(path) {
+			toUpload = append(toUpload, path)
+			omPath := filepath.Join(dir, openMetricsStatsFile)
+			metricsPath := filepath.Join(dir, perfMetricsStatsFile)
+			if ok, err := convertToOpenMetrics(path, omPath, labels); err != nil {
+				return errors.Wrapf(err, "converting %s", path)
+			} else if ok {
+				if err := convertToPerfMetrics(path, metricsPath, labels); err != nil {
+					return errors.Wrapf(err, "converting %s", path)
+				}
+				toUpload = append(toUpload, omPath, metricsPath)
+			} else {
+				l.Printf("%s holds no histograms, not converting it", path)
+			}
+		}
+		if path := filepath.Join(dir, roachtestutil.PerfMetricsFile); fileExists(path) {
+			toUpload = append(toUpload, path)
+		}
+
+		if roachtestflags.RoachperfUploadURL == "" {
+			continue
+		}
+		for _, file := range toUpload {
+			rel, err := filepath.Rel(artifactsRootDir, file)
+			if err != nil {
+				return err
+			}
+			dest := strings.TrimSuffix(roachtestflags.RoachperfUploadURL, "/") + "/" + filepath.ToSlash(rel)
+			l.Printf("uploading %s to %s", file, dest)
+			if out, err := exec.CommandContext(ctx, "gsutil", "cp", file, dest).CombinedOutput(); err != nil {
+				return errors.Wrapf(err, "uploading %s: %s", file, out)
+			}
+		}
+	}
+	return nil
+}
+
+// convertToOpenMetrics converts the histograms in the given perf artifacts
+// file (see histogram.DecodeSnapshots) into the OpenMetrics format. Returns
+// false, and writes nothing, if the file holds no histograms; some tests write
+// perf artifacts of their own format.
+func convertToOpenMetrics(path, omPath string, labels map[string]string) (bool, error) {
+	snapshots := decodePerfStats(path)
+	if len(snapshots) == 0 {
+		return false, nil
+	}
+
+	f, err := os.Create(omPath)
+	if err != nil {
+		return false, err
+	}
+	w := bufio.NewWriter(f)
+	if err := writeOpenMetrics(w, snapshots, labels); err != nil {
+		_ = f.Close()
+		return false, err
+	}
+	if err := w.Flush(); err != nil {
+		_ = f.Close()
+		return false, err
+	}
+	return true, f.Close()
+}
+
+// convertToPerfMetrics converts the histograms in the given perf artifacts
+// file into roachtestutil.PerfMetrics, written to metricsPath. The file must
+// hold histograms, see convertToOpenMetrics.
+func convertToPerfMetrics(path, metricsPath string, labels map[string]string) error {
+	data, err := roachtestutil.ConvertHistogramSnapshots(decodePerfStats(path), labels).Marshal()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(metricsPath, data, 0644)
+}
+
+// decodePerfStats returns the histograms in the given perf artifacts file, or
+// nil if it wasn't written by a workload.
+func decodePerfStats(path string) map[string][]histogram.SnapshotTick {
+	snapshots, err := histogram.DecodeSnapshots(path)
+	if err != nil {
+		return nil
+	}
+	for name, ticks := range snapshots {
+		if name == "" || len(ticks) == 0 || ticks[0].Hist == nil {
+			delete(snapshots, name)
+		}
+	}
+	return snapshots
+}
+
+// writeOpenMetrics writes the given histograms as a single OpenMetrics summary
+// family, with a metric (labeled with name=<histogram name>) per histogram.
+// The ticks of each histogram are merged over every openMetricsInterval, and a
+// point is written for each interval in which operations were recorded, with
+// the timestamp of its last tick:
+//
+//	# TYPE op_latency_seconds summary
+//	# UNIT op_latency_seconds seconds
+//	op_latency_seconds{name="read",test="kv95",quantile="0.5"} 0.0015 1700000000.000
+//	...
+//	op_latency_seconds_sum{name="read",test="kv95"} 1.23 1700000000.000
+//	op_latency_seconds_count{name="read",test="kv95"} 820 1700000000.000
+//	# EOF
+func writeOpenMetrics(
+	w io.Writer, snapshots map[string][]histogram.SnapshotTick, labels map[string]string,
+) error {
+	const family = "op_latency_seconds"
+	var keys []string
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	names := make([]string, 0, len(snapshots))
+	for name := range snapshots {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if _, err := fmt.Fprintf(w, "# TYPE %[1]s summary\n# UNIT %[1]s seconds\n", family); err != nil {
+		return err
+	}
+	for _, name := range names {
+		var b strings.Builder
+		fmt.Fprintf(&b, `name="%s"`, labelValueEscaper.Replace(name))
+		for _, k := range keys {
+			fmt.Fprintf(&b, `,%s="%s"`, sanitizeLabelName(k), labelValueEscaper.Replace(labels[k]))
+		}
+		metricLabels := b.String()
+
+		for _, p := range mergeTicks(snapshots[name], openMetricsInterval) {
+			h := p.hist
+			ts := float64(p.now.UnixMilli()) / 1000
+			for _, q := range openMetricsQuantiles {
+				if _, err := fmt.Fprintf(w, "%s{%s,quantile=\"%g\"} %g %.3f\n", family, metricLabels, q,
+					nanosToSeconds(h.ValueAtQuantile(q*100)), ts); err != nil {
+					return err
+				}
+			}
+			count := h.TotalCount()
+			if _, err := fmt.Fprintf(w, "%s_sum{%s} %g %.3f\n%s_count{%s} %d %.3f\n",
+				family, metricLabels, h.Mean()*float64(count)/float64(time.Second), ts,
+				family, metricLabels, count, ts); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := fmt.Fprintln(w, "# EOF")
+	return err
+}
+
+// mergedTicks is the merged histogram of the ticks of an interval, and the
+// time of the last one.
+type mergedTicks struct {
+	hist *hdrhistogram.Histogram
+	now  time.Time
+}
+
+// mergeTicks merges the given ticks of a histogram over each interval, starting
+// from the first tick, and returns the intervals in which operations were
+// recorded, in chronological order.
+func mergeTicks(ticks []histogram.SnapshotTick, interval time.Duration) []mergedTicks {
+	sort.Slice(ticks, func(i, j int) bool { return ticks[i].Now.Before(ticks[j].Now) })
+	var res []mergedTicks
+	var cur mergedTicks
+	var start time.Time
+	flush := func() {
+		if cur.hist != nil && cur.hist.TotalCount() > 0 {
+			res = append(res, cur)
+		}
+		cur = mergedTicks{}
+	}
+	for _, tick := range ticks {
+		if tick.Hist == nil {
+			continue
+		}
+		if cur.hist != nil && tick.Now.Sub(start) >= interval {
+			flush()
+		}
+		h := hdrhistogram.Import(tick.Hist)
+		if cur.hist == nil {
+			cur.hist, start = h, tick.Now
+		} else {
+			cur.hist.Merge(h)
+		}
+		cur.now = tick.Now
+	}
+	flush()
+	return res
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+func nanosToSeconds(v int64) float64 {
+	return float64(v) / float64(time.Second)
+}
+
+var invalidLabelNameChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// sanitizeLabelName replaces the characters not allowed in OpenMetrics label
+// names with underscores.
+func sanitizeLabelName(name string) string {
+	return invalidLabelNameChars.ReplaceAllString(name, "_")
+}
+
+// labelValueEscaper escapes OpenMetrics label values.
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
@@ -0,0 +1,123 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/roachtestutil"
+	"github.com/cockroachdb/cockroach/pkg/workload/histogram"
+	"github.com/codahale/hdrhistogram"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertToOpenMetrics(t *testing.T) {
+	dir := t.TempDir()
+	statsPath := filepath.Join(dir, "stats.json")
+	omPath := filepath.Join(dir, "stats.om")
+
+	// Perf artifacts that don't hold histograms aren't converted.
+	require.NoError(t, os.WriteFile(statsPath, []byte(`{"copy_row_rate": 10}`), 0644))
+	ok, err := convertToOpenMetrics(statsPath, omPath, nil /* labels */)
+	require.NoError(t, err)
+	require.False(t, ok)
+	require.NoFileExists(t, omPath)
+
+	f, err := os.Create(statsPath)
+	require.NoError(t, err)
+	enc := json.NewEncoder(f)
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// The ticks at 1s, 2s and 10s are merged into a point, as are the ones at
+	// 40s and 50s. The interval starting at 70s has no operations, and no point.
+	for _, tick := range []struct {
+		at      time.Duration
+		latency time.Duration
+		count   int
+	}{
+		{time.Second, 100 * time.Millisecond, 10},
+		{2 * time.Second, 200 * time.Millisecond, 10},
+		{10 * time.Second, 0, 0},
+		{40 * time.Second, 300 * time.Millisecond, 10},
+		{50 * time.Second, 0, 0},
+		{70 * time.Second, 0, 0},
+	} {
+		h := hdrhistogram.New(time.Millisecond.Nanoseconds(), time.Second.Nanoseconds(), 2)
+		for j := 0; j < tick.count; j++ {
+			require.NoError(t, h.RecordValue(tick.latency.Nanoseconds()))
+		}
+		require.NoError(t, enc.Encode(histogram.SnapshotTick{
+			Name: "q1",
+			Hist: h.Export(),
+			Now:  now.Add(tick.at),
+		}))
+	}
+	require.NoError(t, f.Close())
+
+	ok, err = convertToOpenMetrics(statsPath, omPath, map[string]string{"test": `tpch"bench`, "cpu-arch": "amd64"})
+	require.NoError(t, err)
+	require.True(t, ok)
+	om, err := os.ReadFile(omPath)
+	require.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(om)), "\n")
+	require.Len(t, lines, 15)
+	require.Equal(t, "# TYPE op_latency_seconds summary", lines[0])
+	require.Equal(t, "# UNIT op_latency_seconds seconds", lines[1])
+	require.Equal(t, "# EOF", lines[14])
+
+	// Each point has 4 quantiles, a sum and a count.
+	const labels = `{name="q1",cpu_arch="amd64",test="tpch\"bench"`
+	for i, point := range []struct {
+		quantiles []float64
+		sum       float64
+		count     string
+		ts        string
+	}{
+		{[]float64{0.1, 0.2, 0.2, 0.2}, 3, "20", "1704067210.000"},
+		{[]float64{0.3, 0.3, 0.3, 0.3}, 3, "10", "1704067250.000"},
+	} {
+		lines := lines[2+6*i : 2+6*(i+1)]
+		for j, q := range []string{"0.5", "0.95", "0.99", "1"} {
+			fields := strings.Fields(lines[j])
+			require.Equal(t, "op_latency_seconds"+labels+`,quantile="`+q+`"}`, fields[0])
+			v, err := strconv.ParseFloat(fields[1], 64)
+			require.NoError(t, err)
+			require.InEpsilon(t, point.quantiles[j], v, 0.1)
+			require.Equal(t, point.ts, fields[2])
+		}
+		fields := strings.Fields(lines[4])
+		require.Equal(t, "op_latency_seconds_sum"+labels+"}", fields[0])
+		v, err := strconv.ParseFloat(fields[1], 64)
+		require.NoError(t, err)
+		require.InEpsilon(t, point.sum, v, 0.1)
+		require.Equal(t, []string{"op_latency_seconds_count" + labels + "}", point.count, point.ts},
+			strings.Fields(lines[5]))
+	}
+
+	// The histograms are also converted into PerfMetrics, with all the ticks
+	// merged.
+	metricsPath := filepath.Join(dir, "stats.metrics.json")
+	require.NoError(t, convertToPerfMetrics(statsPath, metricsPath, map[string]string{"test": "tpchbench"}))
+	data, err := os.ReadFile(metricsPath)
+	require.NoError(t, err)
+	var m roachtestutil.PerfMetrics
+	require.NoError(t, json.Unmarshal(data, &m))
+	require.Equal(t, roachtestutil.PerfMetricsSchemaVersion, m.SchemaVersion)
+	require.Len(t, m.Metrics, 1)
+	require.Equal(t, map[string]string{"op": "q1", "test": "tpchbench"}, m.Metrics[0].Labels)
+	require.Equal(t, now.Add(70*time.Second), m.Metrics[0].Timestamp)
+	require.Equal(t, int64(30), m.Metrics[0].Histogram.Count)
+}
@@ -0,0 +1,128 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/option"
+	"github.com/cockroachdb/cockroach/pkg/roachprod"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/install"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/logger"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	// preflightMaxClockOffset is the maximum offset allowed between the clock
+	// of a node and the clock of the test runner; it is well below the
+	// default --max-offset of CockroachDB.
+	preflightMaxClockOffset = 250 * time.Millisecond
+
+	// maxPreflightReplacements is the number of times a cluster failing the
+	// pre-flight checks is replaced before giving up on the test.
+	maxPreflightReplacements = 2
+)
+
+// errPreflightChecksFailed marks the errors returned by runPreflightChecks.
+var errPreflightChecksFailed = errors.New("cluster failed pre-flight checks")
+
+// preflightDmesgRE matches kernel messages that indicate that a VM is not
+// healthy, e.g. because of a faulty disk.
+var preflightDmesgRE = regexp.MustCompile(
+	`(?i)I/O error|EXT4-fs error|XFS .*(corruption|error)|hardware error|machine check|nvme.*timeout|out of memory`,
+)
+
+// runPreflightChecks verifies that a newly created cluster is healthy before
+// it is handed to a test: all the nodes are reachable, their clocks are in
+// sync with the test runner's, their disks are writable and the kernel did not
+// report errors. Failures are due to the infrastructure and are marked with
+// errPreflightChecksFailed.
+func (c *clusterImpl) runPreflightChecks(ctx context.Context, l *logger.Logger) error {
+	run := func(cmd string) ([]install.RunResultDetails, error) {
+		results, err := roachprod.RunWithDetails(
+			ctx, l, c.MakeNodes(c.All()), "" /* SSHOptions */, "", /* processTag */
+			c.IsSecure(), []string{cmd}, option.WithNodes(c.All()),
+		)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range results {
+			if r.Err != nil {
+				return nil, errors.Wrapf(r.Err, "n%d: %s", r.Node, strings.TrimSpace(r.CombinedOut))
+			}
+		}
+		return results, nil
+	}
+
+	start := timeutil.Now()
+	results, err := run("date +%s%N")
+	if err != nil {
+		return errors.Mark(errors.Wrap(err, "nodes unreachable"), errPreflightChecksFailed)
+	}
+	if err := checkClockOffsets(results, start, timeutil.Now(), preflightMaxClockOffset); err != nil {
+		return errors.Mark(err, errPreflightChecksFailed)
+	}
+
+	const probe = "{store-dir}/.roachtest-preflight"
+	if _, err := run("mkdir -p {store-dir} && echo ok > " + probe + " && sync && rm " + probe); err != nil {
+		return errors.Mark(errors.Wrap(err, "disk not writable"), errPreflightChecksFailed)
+	}
+
+	results, err = run("sudo dmesg --level=err,crit,alert,emerg || true")
+	if err != nil {
+		return errors.Mark(errors.Wrap(err, "reading kernel messages"), errPreflightChecksFailed)
+	}
+	for _, r := range results {
+		if msgs := dmesgErrors(r.Stdout); len(msgs) > 0 {
+			return errors.Mark(
+				errors.Newf("n%d: kernel errors: %s", r.Node, strings.Join(msgs, "; ")), errPreflightChecksFailed,
+			)
+		}
+	}
+	return nil
+}
+
+// checkClockOffsets verifies that the clocks of the nodes, as output by `date
+// +%s%N` between start and end on the test runner, are within maxOffset of the
+// test runner's clock.
+func checkClockOffsets(
+	results []install.RunResultDetails, start, end time.Time, maxOffset time.Duration,
+) error {
+	for _, r := range results {
+		nanos, err := strconv.ParseInt(strings.TrimSpace(r.Stdout), 10, 64)
+		if err != nil {
+			return errors.Wrapf(err, "n%d: parsing clock", r.Node)
+		}
+		nodeTime := timeutil.Unix(0, nanos)
+		if nodeTime.Before(start.Add(-maxOffset)) || nodeTime.After(end.Add(maxOffset)) {
+			return errors.Newf("n%d: clock offset exceeds %s (node: %s, runner: %s-%s)",
+				r.Node, maxOffset, nodeTime, start, end)
+		}
+	}
+	return nil
+}
+
+// dmesgErrors returns the kernel messages in the given dmesg output which
+// indicate that a VM is not healthy.
+func dmesgErrors(out string) []string {
+	var msgs []string
+	for _, line := range strings.Split(out, "\n") {
+		if preflightDmesgRE.MatchString(line) {
+			msgs = append(msgs, strings.TrimSpace(line))
+		}
+	}
+	return msgs
+}
@@ -0,0 +1,57 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/roachprod/install"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckClockOffsets(t *testing.T) {
+	start := time.Unix(1000, 0)
+	end := start.Add(time.Second)
+	result := func(node int, offset time.Duration) install.RunResultDetails {
+		return install.RunResultDetails{
+			Node:   install.Node(node),
+			Stdout: strconv.FormatInt(start.Add(offset).UnixNano(), 10) + "\n",
+		}
+	}
+
+	// Node clocks are compared to the time range during which they were read.
+	require.NoError(t, checkClockOffsets([]install.RunResultDetails{
+		result(1, 0), result(2, time.Second), result(3, -100*time.Millisecond),
+	}, start, end, 250*time.Millisecond))
+	require.ErrorContains(t, checkClockOffsets([]install.RunResultDetails{
+		result(1, 0), result(2, -300*time.Millisecond),
+	}, start, end, 250*time.Millisecond), "n2: clock offset exceeds 250ms")
+	require.ErrorContains(t, checkClockOffsets([]install.RunResultDetails{
+		result(1, 1500*time.Millisecond),
+	}, start, end, 250*time.Millisecond), "n1: clock offset")
+	require.Error(t, checkClockOffsets([]install.RunResultDetails{
+		{Node: 1, Stdout: "not a time"},
+	}, start, end, 250*time.Millisecond))
+}
+
+func TestDmesgErrors(t *testing.T) {
+	out := `[    0.521000] ACPI Error: AE_NOT_FOUND, While resolving a named reference package element
+[  312.101010] blk_update_request: I/O error, dev nvme0n1, sector 2048
+[  313.000000] EXT4-fs error (device nvme0n1): ext4_find_entry:1455: inode #2: comm ls: reading directory lblock 0
+`
+	require.Equal(t, []string{
+		"[  312.101010] blk_update_request: I/O error, dev nvme0n1, sector 2048",
+		"[  313.000000] EXT4-fs error (device nvme0n1): ext4_find_entry:1455: inode #2: comm ls: reading directory lblock 0",
+	}, dmesgErrors(out))
+	require.Empty(t, dmesgErrors(""))
+}
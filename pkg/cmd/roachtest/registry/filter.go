@@ -13,6 +13,8 @@ package registry
 import (
 	"fmt"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/spec"
@@ -27,14 +29,19 @@ type TestFilter struct {
 	// Cloud, if set, restricts the set of tests to those compatible with this cloud.
 	Cloud spec.Cloud
 
-	// Suite, if set, restricts the set of tests to those that are part of this suite.
-	Suite string
+	// Suites, if set, restricts the set of tests to those that are part of any
+	// of these suites.
+	Suites []string
 
 	// Owner, if set, restricts the set of tests to those with this owner.
 	Owner Owner
 
 	// OnlyBenchmarks, if set, restricts the set of tests to benchmarks.
 	OnlyBenchmarks bool
+
+	// Expr, if set, restricts the set of tests to those for which the
+	// expression holds.
+	Expr FilterExpr
 }
 
 // TestFilterOption can be passed to NewTestFilter.
@@ -46,8 +53,22 @@ func WithCloud(cloud spec.Cloud) TestFilterOption {
 }
 
 // WithSuite restricts the set of tests to those that are part of this suite.
+// When combined with other suites, the tests that are part of any of them
+// match.
 func WithSuite(suite string) TestFilterOption {
-	return func(tf *TestFilter) { tf.Suite = suite }
+	return WithSuites(suite)
+}
+
+// WithSuites restricts the set of tests to those that are part of any of these
+// suites.
+func WithSuites(suites ...string) TestFilterOption {
+	return func(tf *TestFilter) {
+		for _, s := range suites {
+			if !slices.Contains(tf.Suites, s) {
+				tf.Suites = append(tf.Suites, s)
+			}
+		}
+	}
 }
 
 // WithOwner restricts the set of tests to those with this owner.
@@ -59,6 +80,11 @@ func OnlyBenchmarks() TestFilterOption {
 	return func(tf *TestFilter) { tf.OnlyBenchmarks = true }
 }
 
+// WithExpr restricts the set of tests to those for which the expression holds.
+func WithExpr(expr FilterExpr) TestFilterOption {
+	return func(tf *TestFilter) { tf.Expr = expr }
+}
+
 // NewTestFilter initializes a new filter. The strings are interpreted as
 // regular expressions (which are joined with |).
 func NewTestFilter(regexps []string, options ...TestFilterOption) (*TestFilter, error) {
@@ -84,8 +110,10 @@ func NewTestFilter(regexps []string, options ...TestFilterOption) (*TestFilter,
 	}
 
 	// Validate Cloud, Suite, Owner fields.
-	if tf.Suite != "" && !AllSuites.Contains(tf.Suite) {
-		return nil, errors.Newf("invalid suite %q; valid suites are %s", tf.Suite, AllSuites)
+	for _, s := range tf.Suites {
+		if !AllSuites.Contains(s) {
+			return nil, errors.Newf("invalid suite %q; valid suites are %s", s, AllSuites)
+		}
 	}
 	if tf.Owner != "" && !tf.Owner.IsValid() {
 		return nil, errors.Newf("invalid owner %q", tf.Owner)
@@ -102,10 +130,12 @@ type MatchFailReason struct {
 	NameMismatch bool
 	// If true, the test owner does not match the owner in the filter.
 	OwnerMismatch bool
-	// If true, the test is not part of the suite in the filter.
+	// If true, the test is not part of any of the suites in the filter.
 	NotPartOfSuite bool
 	// If true, the test is not compatible with the cloud in the filter.
 	CloudNotCompatible bool
+	// If true, the filter expression does not hold for the test.
+	ExprMismatch bool
 }
 
 // Matches returns true if the filter matches the test. If the test doesn't
@@ -114,8 +144,9 @@ func (filter *TestFilter) Matches(t *TestSpec) (matches bool, reason MatchFailRe
 	reason.IsNotBenchmark = filter.OnlyBenchmarks && !t.Benchmark
 	reason.NameMismatch = !filter.Name.MatchString(t.Name)
 	reason.OwnerMismatch = filter.Owner != "" && t.Owner != filter.Owner
-	reason.NotPartOfSuite = filter.Suite != "" && !t.Suites.Contains(filter.Suite)
+	reason.NotPartOfSuite = len(filter.Suites) > 0 && !slices.ContainsFunc(filter.Suites, t.Suites.Contains)
 	reason.CloudNotCompatible = filter.Cloud.IsSet() && !t.CompatibleClouds.Contains(filter.Cloud)
+	reason.ExprMismatch = filter.Expr != nil && !filter.Expr.Matches(t)
 
 	// We have a match if all fields are false.
 	return reason == MatchFailReason{}, reason
@@ -140,8 +171,9 @@ func (filter *TestFilter) MatchFailReasonString(r MatchFailReason) string {
 	appendIf(r.IsNotBenchmark, "is not a benchmark")
 	appendIf(r.NameMismatch, "does not match regex %q", filter.Name)
 	appendIf(r.OwnerMismatch, "does not have owner %q", filter.Owner)
-	appendIf(r.NotPartOfSuite, "is not part of the %q suite", filter.Suite)
+	appendIf(r.NotPartOfSuite, "is not part of the %s suite", filter.suitesString())
 	appendIf(r.CloudNotCompatible, "is not compatible with %q", filter.Cloud)
+	appendIf(r.ExprMismatch, "does not match expression %q", filter.Expr)
 
 	if len(reasons) <= 2 {
 		// 0 reasons: ""
@@ -196,6 +228,10 @@ const (
 	// the name regexp and the owner.
 	NoTestsWithNameAndOwner
 
+	// NoTestsMatchExpr indicates that no tests/benchmarks match the filter
+	// expression.
+	NoTestsMatchExpr
+
 	// IncompatibleCloud indicates that some tests match all aspects of the filter
 	// except the cloud. Since cloud compatibility was added more recently, we want
 	// to have a useful message for this case.
@@ -239,11 +275,11 @@ func (filter *TestFilter) FilterWithHint(tests []TestSpec) ([]TestSpec, NoMatche
 	}
 
 	// Check potential problems related to the suite.
-	if filter.Suite != "" {
+	if len(filter.Suites) > 0 {
 		// 3. Is the suite incorrect?
 		// We check if no tests match the suite, in which case the suite is the problem.
 		suiteOnlyFilter := noFilter
-		suiteOnlyFilter.Suite = filter.Suite
+		suiteOnlyFilter.Suites = filter.Suites
 		if len(suiteOnlyFilter.Filter(tests)) == 0 {
 			return nil, NoTestsInSuite
 		}
@@ -275,7 +311,16 @@ func (filter *TestFilter) FilterWithHint(tests []TestSpec) ([]TestSpec, NoMatche
 		}
 	}
 
-	// 7. Are we trying to run some tests on an incompatible cloud?
+	// 7. Is the filter expression incorrect?
+	if filter.Expr != nil {
+		exprOnlyFilter := noFilter
+		exprOnlyFilter.Expr = filter.Expr
+		if len(exprOnlyFilter.Filter(tests)) == 0 {
+			return nil, NoTestsMatchExpr
+		}
+	}
+
+	// 8. Are we trying to run some tests on an incompatible cloud?
 	//
 	// We want to see if the desired tests exist but are not compatible with the
 	// given cloud (which is a recent feature). We use all fields from the
@@ -302,13 +347,15 @@ func (filter *TestFilter) NoMatchesHintString(h NoMatchesHint) string {
 	case NoSuchName:
 		return fmt.Sprintf("no %s match regexp %q", noun, filter.Name)
 	case NoTestsInSuite:
-		return fmt.Sprintf("no %s in suite %q", noun, filter.Suite)
+		return fmt.Sprintf("no %s in suite %s", noun, filter.suitesString())
 	case NoTestsWithNameAndSuite:
-		return fmt.Sprintf("no %s in suite %q match regexp %q", noun, filter.Suite, filter.Name)
+		return fmt.Sprintf("no %s in suite %s match regexp %q", noun, filter.suitesString(), filter.Name)
 	case NoTestsWithOwner:
 		return fmt.Sprintf("no %s with owner %q", noun, filter.Owner)
 	case NoTestsWithNameAndOwner:
 		return fmt.Sprintf("no %s with owner %q match regexp %q", noun, filter.Owner, filter.Name)
+	case NoTestsMatchExpr:
+		return fmt.Sprintf("no %s match expression %q", noun, filter.Expr)
 	case IncompatibleCloud:
 		// Get a description of the filter without the cloud.
 		noCloudFilter := *filter
@@ -340,8 +387,9 @@ func (filter *TestFilter) String() string {
 	}
 	appendIf(filter.Name.String() != ".", "match regex %q", filter.Name)
 	appendIf(filter.Cloud.IsSet(), "are compatible with cloud %q", filter.Cloud)
-	appendIf(filter.Suite != "", "are part of the %q suite", filter.Suite)
+	appendIf(len(filter.Suites) > 0, "are part of the %s suite", filter.suitesString())
 	appendIf(filter.Owner != "", "have owner %q", filter.Owner)
+	appendIf(filter.Expr != nil, "match expression %q", filter.Expr)
 
 	noun := filter.noun()
 	if len(criteria) == 0 {
@@ -350,6 +398,15 @@ func (filter *TestFilter) String() string {
 	return noun + " which " + strings.Join(criteria, " and ")
 }
 
+// suitesString returns the quoted suites of the filter, separated by "or".
+func (filter *TestFilter) suitesString() string {
+	quoted := make([]string, len(filter.Suites))
+	for i, s := range filter.Suites {
+		quoted[i] = strconv.Quote(s)
+	}
+	return strings.Join(quoted, " or ")
+}
+
 // noun returns "tests" or "benchmarks" depending on the OnlyBenchmarks field.
 func (filter *TestFilter) noun() string {
 	if filter.OnlyBenchmarks {
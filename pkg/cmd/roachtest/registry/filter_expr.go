@@ -0,0 +1,247 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package registry
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/spec"
+	"github.com/cockroachdb/errors"
+)
+
+// FilterExpr is a boolean expression over the metadata of a test, e.g.
+//
+//	suite:nightly && owner:kv && !benchmark && cloud:gce
+//
+// The supported terms are:
+//   - suite:<suite>: the test is part of the suite;
+//   - owner:<owner>: the test has the owner;
+//   - cloud:<cloud>: the test is compatible with the cloud;
+//   - name:<regexp>: the name of the test matches the regexp;
+//   - benchmark: the test is a benchmark.
+//
+// Terms can be combined with ! (not), && (and), || (or) and parentheses; &&
+// binds tighter than ||. Values containing spaces or operators can be double
+// quoted, e.g. name:"^kv(0|95)/".
+//
+// See ParseFilterExpr.
+type FilterExpr interface {
+	// Matches returns whether the expression holds for the given test.
+	Matches(t *TestSpec) bool
+	fmt.Stringer
+}
+
+type notExpr struct{ e FilterExpr }
+type andExpr struct{ l, r FilterExpr }
+type orExpr struct{ l, r FilterExpr }
+
+type suiteTerm string
+type ownerTerm Owner
+type cloudTerm spec.Cloud
+type nameTerm struct{ re *regexp.Regexp }
+type benchmarkTerm struct{}
+
+func (e notExpr) Matches(t *TestSpec) bool { return !e.e.Matches(t) }
+func (e andExpr) Matches(t *TestSpec) bool { return e.l.Matches(t) && e.r.Matches(t) }
+func (e orExpr) Matches(t *TestSpec) bool  { return e.l.Matches(t) || e.r.Matches(t) }
+
+func (e suiteTerm) Matches(t *TestSpec) bool   { return t.Suites.Contains(string(e)) }
+func (e ownerTerm) Matches(t *TestSpec) bool   { return t.Owner == Owner(e) }
+func (e cloudTerm) Matches(t *TestSpec) bool   { return t.CompatibleClouds.Contains(spec.Cloud(e)) }
+func (e nameTerm) Matches(t *TestSpec) bool    { return e.re.MatchString(t.Name) }
+func (benchmarkTerm) Matches(t *TestSpec) bool { return t.Benchmark }
+
+func (e notExpr) String() string { return "!" + e.e.String() }
+func (e andExpr) String() string { return fmt.Sprintf("(%s && %s)", e.l, e.r) }
+func (e orExpr) String() string  { return fmt.Sprintf("(%s || %s)", e.l, e.r) }
+
+func (e suiteTerm) String() string   { return "suite:" + string(e) }
+func (e ownerTerm) String() string   { return "owner:" + string(e) }
+func (e cloudTerm) String() string   { return "cloud:" + spec.Cloud(e).String() }
+func (e nameTerm) String() string    { return `name:"` + e.re.String() + `"` }
+func (benchmarkTerm) String() string { return "benchmark" }
+
+// ParseFilterExpr parses a filter expression. See FilterExpr.
+func ParseFilterExpr(s string) (FilterExpr, error) {
+	p := filterExprParser{input: s}
+	if err := p.tokenize(); err != nil {
+		return nil, errors.Wrapf(err, "invalid filter expression %q", s)
+	}
+	e, err := p.parseOr()
+	if err == nil && p.pos < len(p.tokens) {
+		err = errors.Newf("unexpected %q", p.tokens[p.pos])
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid filter expression %q", s)
+	}
+	return e, nil
+}
+
+// AnyFilterExpr returns an expression which holds if any of the given
+// expressions holds. There must be at least one expression.
+func AnyFilterExpr(exprs ...FilterExpr) FilterExpr {
+	e := exprs[0]
+	for _, r := range exprs[1:] {
+		e = orExpr{l: e, r: r}
+	}
+	return e
+}
+
+type filterExprParser struct {
+	input  string
+	tokens []string
+	pos    int
+}
+
+// tokenize splits the input into operators and terms.
+func (p *filterExprParser) tokenize() error {
+	s := p.input
+	for len(s) > 0 {
+		switch {
+		case unicode.IsSpace(rune(s[0])):
+			s = s[1:]
+		case strings.HasPrefix(s, "&&"), strings.HasPrefix(s, "||"):
+			p.tokens = append(p.tokens, s[:2])
+			s = s[2:]
+		case s[0] == '!' || s[0] == '(' || s[0] == ')':
+			p.tokens = append(p.tokens, s[:1])
+			s = s[1:]
+		default:
+			var term strings.Builder
+			for len(s) > 0 && !unicode.IsSpace(rune(s[0])) && !strings.ContainsRune("!()&|", rune(s[0])) {
+				if s[0] != '"' {
+					term.WriteByte(s[0])
+					s = s[1:]
+					continue
+				}
+				end := strings.IndexByte(s[1:], '"')
+				if end < 0 {
+					return errors.New("unterminated quoted string")
+				}
+				term.WriteString(s[1 : end+1])
+				s = s[end+2:]
+			}
+			if term.Len() == 0 {
+				return errors.Newf("unexpected %q", s[:1])
+			}
+			p.tokens = append(p.tokens, term.String())
+		}
+	}
+	return nil
+}
+
+func (p *filterExprParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *filterExprParser) parseOr() (FilterExpr, error) {
+	l, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "||" {
+		p.pos++
+		r, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		l = orExpr{l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *filterExprParser) parseAnd() (FilterExpr, error) {
+	l, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == "&&" {
+		p.pos++
+		r, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		l = andExpr{l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *filterExprParser) parseUnary() (FilterExpr, error) {
+	tok := p.peek()
+	switch tok {
+	case "":
+		return nil, errors.New("unexpected end of expression")
+	case "!":
+		p.pos++
+		e, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notExpr{e: e}, nil
+	case "(":
+		p.pos++
+		e, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ")" {
+			return nil, errors.New("missing )")
+		}
+		p.pos++
+		return e, nil
+	case ")", "&&", "||":
+		return nil, errors.Newf("unexpected %q", tok)
+	}
+	p.pos++
+	return parseFilterTerm(tok)
+}
+
+func parseFilterTerm(term string) (FilterExpr, error) {
+	if term == "benchmark" {
+		return benchmarkTerm{}, nil
+	}
+	key, val, ok := strings.Cut(term, ":")
+	if !ok || val == "" {
+		return nil, errors.Newf("invalid term %q; expected <key>:<value> or benchmark", term)
+	}
+	switch key {
+	case "suite":
+		if !AllSuites.Contains(val) {
+			return nil, errors.Newf("invalid suite %q; valid suites are %s", val, AllSuites)
+		}
+		return suiteTerm(val), nil
+	case "owner":
+		if !Owner(val).IsValid() {
+			return nil, errors.Newf("invalid owner %q", val)
+		}
+		return ownerTerm(val), nil
+	case "cloud":
+		cloud, ok := spec.TryCloudFromString(val)
+		if !ok {
+			return nil, errors.Newf("invalid cloud %q", val)
+		}
+		return cloudTerm(cloud), nil
+	case "name":
+		re, err := regexp.Compile(val)
+		if err != nil {
+			return nil, err
+		}
+		return nameTerm{re: re}, nil
+	default:
+		return nil, errors.Newf("unknown key %q in term %q; expected suite, owner, cloud or name", key, term)
+	}
+}
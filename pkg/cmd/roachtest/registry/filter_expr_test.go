@@ -0,0 +1,89 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package registry
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseFilterExpr(t *testing.T) {
+	for _, tc := range []struct {
+		expr     string
+		expected string
+	}{
+		{"benchmark", "benchmark"},
+		{"suite:nightly && owner:kv && !benchmark && cloud:gce",
+			"(((suite:nightly && owner:kv) && !benchmark) && cloud:gce)"},
+		{"suite:nightly || suite:weekly && owner:kv",
+			"(suite:nightly || (suite:weekly && owner:kv))"},
+		{"(suite:nightly || suite:weekly) && !(owner:kv)",
+			"((suite:nightly || suite:weekly) && !owner:kv)"},
+		{`name:"^kv(0|95)/" && !!benchmark`, `(name:"^kv(0|95)/" && !!benchmark)`},
+	} {
+		e, err := ParseFilterExpr(tc.expr)
+		require.NoError(t, err, tc.expr)
+		require.Equal(t, tc.expected, e.String())
+
+		// The string representation can be parsed back.
+		e2, err := ParseFilterExpr(e.String())
+		require.NoError(t, err, tc.expr)
+		require.Equal(t, tc.expected, e2.String())
+	}
+
+	for _, tc := range []struct {
+		expr string
+		err  string
+	}{
+		{"", "unexpected end of expression"},
+		{"suite:nightly owner:kv", `unexpected "owner:kv"`},
+		{"(suite:nightly", "missing )"},
+		{"suite:nightly)", `unexpected ")"`},
+		{"suite:nightly & owner:kv", `unexpected "&"`},
+		{"|| owner:kv", `unexpected "||"`},
+		{`name:"foo`, "unterminated quoted string"},
+		{"tag:foo", `unknown key "tag"`},
+		{"suite", "expected <key>:<value> or benchmark"},
+		{"suite:badsuite", `invalid suite "badsuite"`},
+		{"cloud:badcloud", `invalid cloud "badcloud"`},
+		{`name:"("`, "error parsing regexp"},
+	} {
+		_, err := ParseFilterExpr(tc.expr)
+		require.ErrorContains(t, err, tc.err, tc.expr)
+	}
+}
+
+func TestAnyFilterExpr(t *testing.T) {
+	var exprs []FilterExpr
+	for _, s := range []string{"suite:nightly && owner:kv", "benchmark && name:tpch", "owner:cdc"} {
+		e, err := ParseFilterExpr(s)
+		require.NoError(t, err)
+		exprs = append(exprs, e)
+	}
+	require.Equal(t, "(suite:nightly && owner:kv)", AnyFilterExpr(exprs[0]).String())
+	e := AnyFilterExpr(exprs...)
+	require.Equal(t,
+		`(((suite:nightly && owner:kv) || (benchmark && name:"tpch")) || owner:cdc)`, e.String())
+
+	for _, tc := range []struct {
+		spec     TestSpec
+		expected bool
+	}{
+		{TestSpec{Owner: OwnerKV, Suites: Suites(Nightly)}, true},
+		{TestSpec{Owner: OwnerKV, Suites: Suites(Weekly)}, false},
+		{TestSpec{Name: "tpchbench/q1", Owner: OwnerKV, Benchmark: true}, true},
+		{TestSpec{Name: "tpchbench/q1", Owner: OwnerKV}, false},
+		{TestSpec{Owner: OwnerCDC}, true},
+	} {
+		require.Equal(t, tc.expected, e.Matches(&tc.spec), "%+v", tc.spec)
+	}
+}
@@ -73,11 +73,17 @@ func TestTestFilter(t *testing.T) {
 				case "cloud":
 					options = append(options, WithCloud(spec.CloudFromString(arg.Vals[0])))
 				case "suite":
-					options = append(options, WithSuite(arg.Vals[0]))
+					options = append(options, WithSuites(arg.Vals...))
 				case "owner":
 					options = append(options, WithOwner(Owner(arg.Vals[0])))
 				case "benchmarks":
 					options = append(options, OnlyBenchmarks())
+				case "expr":
+					expr, err := ParseFilterExpr(arg.Vals[0])
+					if err != nil {
+						return fmt.Sprintf("error: %s", err)
+					}
+					options = append(options, WithExpr(expr))
 				case "test":
 					testName = arg.Vals[0]
 				default:
@@ -0,0 +1,88 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package registry
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/spec"
+	"github.com/cockroachdb/errors"
+)
+
+// MetamorphicTopology lists the parameters of the topology of the cluster of a
+// test which the test does not depend on, and which are thus picked randomly
+// for each invocation of roachtest to widen the coverage of the test. The
+// choices are derived from --global-seed and the name of the test, so passing
+// the same seed reproduces them; all the runs of a test within an invocation
+// use the same topology.
+type MetamorphicTopology struct {
+	// MinNodes and MaxNodes, if MaxNodes is set, are the bounds (inclusive) of
+	// the number of CockroachDB nodes of the cluster. The workload nodes of the
+	// cluster spec are kept as they are.
+	MinNodes, MaxNodes int
+	// Geo, if set, randomly spreads the nodes across the default zones of the
+	// cloud rather than placing them in a single zone.
+	Geo bool
+	// Disks, if set, randomly uses instance-local SSDs or network-attached
+	// disks.
+	Disks bool
+}
+
+// Validate returns an error if the topology can't be applied to the given
+// cluster spec.
+func (mt MetamorphicTopology) Validate(s spec.ClusterSpec) error {
+	if mt.MaxNodes > 0 && (mt.MinNodes < 1 || mt.MinNodes > mt.MaxNodes) {
+		return errors.Newf("invalid node count range [%d, %d]", mt.MinNodes, mt.MaxNodes)
+	}
+	if mt.Disks && s.StoresPerNode > 1 {
+		return errors.New("disks can't be randomized with multiple stores per node, " +
+			"which require local SSDs")
+	}
+	return nil
+}
+
+// Apply randomizes the given cluster spec using the given source of
+// randomness.
+func (mt MetamorphicTopology) Apply(rng *rand.Rand, s *spec.ClusterSpec) {
+	// The choices are always drawn in the same order, so that adding a
+	// parameter to a topology doesn't change the others.
+	if mt.MaxNodes > 0 {
+		nodes := mt.MinNodes + rng.Intn(mt.MaxNodes-mt.MinNodes+1)
+		s.NodeCount = nodes + s.NumWorkloadNodes()
+	}
+	if geo := rng.Float64() < 0.5; mt.Geo {
+		s.Geo = geo
+	}
+	if localSSD := rng.Float64() < 0.5; mt.Disks {
+		s.LocalSSD = spec.LocalSSDDisable
+		if localSSD {
+			s.LocalSSD = spec.LocalSSDPreferOn
+		}
+	}
+}
+
+// Describe returns the choices of the topology made in the given cluster spec,
+// as they are logged, e.g. "nodes=5 geo=true local-ssd=false".
+func (mt MetamorphicTopology) Describe(s spec.ClusterSpec) string {
+	var choices []string
+	if mt.MaxNodes > 0 {
+		choices = append(choices, fmt.Sprintf("nodes=%d", s.NodeCount-s.NumWorkloadNodes()))
+	}
+	if mt.Geo {
+		choices = append(choices, fmt.Sprintf("geo=%t", s.Geo))
+	}
+	if mt.Disks {
+		choices = append(choices, fmt.Sprintf("local-ssd=%t", s.LocalSSD == spec.LocalSSDPreferOn))
+	}
+	return strings.Join(choices, " ")
+}
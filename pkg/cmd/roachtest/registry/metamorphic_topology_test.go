@@ -0,0 +1,57 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package registry
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/spec"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetamorphicTopology(t *testing.T) {
+	mt := MetamorphicTopology{MinNodes: 3, MaxNodes: 6, Geo: true, Disks: true}
+	base := spec.MakeClusterSpec(4, spec.WorkloadNode())
+	require.NoError(t, mt.Validate(base))
+
+	seen := make(map[string]bool)
+	for seed := int64(0); seed < 1000; seed++ {
+		s := base
+		mt.Apply(rand.New(rand.NewSource(seed)), &s)
+		nodes := s.NodeCount - s.NumWorkloadNodes()
+		require.GreaterOrEqual(t, nodes, 3)
+		require.LessOrEqual(t, nodes, 6)
+		require.Equal(t, 1, s.NumWorkloadNodes())
+		seen[mt.Describe(s)] = true
+
+		// The same seed yields the same topology.
+		again := base
+		mt.Apply(rand.New(rand.NewSource(seed)), &again)
+		require.Equal(t, s, again)
+	}
+	// All the combinations of 4 node counts, 2 zone layouts and 2 disk types
+	// are picked.
+	require.Len(t, seen, 16)
+
+	// Only the parameters of the topology are randomized, and described.
+	mt = MetamorphicTopology{Geo: true}
+	s := base
+	mt.Apply(rand.New(rand.NewSource(1)), &s)
+	require.Equal(t, base.NodeCount, s.NodeCount)
+	require.Equal(t, base.LocalSSD, s.LocalSSD)
+	require.Equal(t, fmt.Sprintf("geo=%t", s.Geo), mt.Describe(s))
+
+	require.Error(t, MetamorphicTopology{MinNodes: 5, MaxNodes: 3}.Validate(base))
+	require.Error(t, MetamorphicTopology{MaxNodes: 3}.Validate(base))
+	require.Error(t, MetamorphicTopology{Disks: true}.Validate(spec.MakeClusterSpec(3, spec.MultipleStores(2))))
+}
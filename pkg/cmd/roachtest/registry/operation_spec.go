@@ -71,9 +71,22 @@ type OperationSpec struct {
 	// TODO(bilal): Unused.
 	CanRunConcurrently bool
 
+	// Cooldown is the minimum time between two runs of this operation started
+	// by the operation scheduler (see run-operation --schedule). If zero, the
+	// default given via --operation-cooldown applies.
+	Cooldown time.Duration
+
 	// Run is the operation function. It returns an OperationCleanup if this
 	// operation requires additional cleanup steps afterwards (eg. dropping an
 	// extra column that was created). A nil return value indicates no cleanup
 	// necessary
 	Run func(ctx context.Context, o operation.Operation, c cluster.Cluster) OperationCleanup
+
+	// NewCleanup, if set, returns an empty OperationCleanup of the type
+	// returned by Run. The cleanups of such operations are persisted, using
+	// encoding/json, until they complete, so that the cleanups that didn't run
+	// (e.g. because the runner died) can be run later with
+	// `run-operation --resume-cleanups`. Their state must thus be held in
+	// exported fields.
+	NewCleanup func() OperationCleanup
 }
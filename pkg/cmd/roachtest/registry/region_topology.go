@@ -0,0 +1,133 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package registry
+
+import (
+	"context"
+	"strings"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/cluster"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/spec"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/test"
+	"github.com/cockroachdb/errors"
+)
+
+// RegionTopology describes how the CockroachDB nodes of a cluster are spread
+// across regions.
+type RegionTopology struct {
+	// Name identifies the topology in test names, e.g. "3x3-geo".
+	Name string
+	// GCEZones contains one GCE zone per region.
+	GCEZones []string
+	// NodesPerRegion is the number of nodes in each region; it has the same
+	// length as GCEZones.
+	NodesPerRegion []int
+}
+
+var (
+	// SingleRegion is a 3 node cluster in a single region.
+	SingleRegion = RegionTopology{
+		Name:           "single-region",
+		GCEZones:       []string{"us-east1-b"},
+		NodesPerRegion: []int{3},
+	}
+
+	// TwoRegionsWithWitness is a cluster with 3 nodes in each of 2 regions, and
+	// a single node in a third region which can hold a witness replica.
+	TwoRegionsWithWitness = RegionTopology{
+		Name:           "2-region-witness",
+		GCEZones:       []string{"us-east1-b", "us-west1-b", "us-central1-b"},
+		NodesPerRegion: []int{3, 3, 1},
+	}
+
+	// ThreeByThreeGeo is a cluster with 3 nodes in each of 3 regions.
+	ThreeByThreeGeo = RegionTopology{
+		Name:           "3x3-geo",
+		GCEZones:       []string{"us-east1-b", "us-west1-b", "europe-west2-b"},
+		NodesPerRegion: []int{3, 3, 3},
+	}
+
+	// AllRegionTopologies contains all the predefined region topologies.
+	AllRegionTopologies = []RegionTopology{SingleRegion, TwoRegionsWithWitness, ThreeByThreeGeo}
+)
+
+// NodeCount returns the number of CockroachDB nodes in the topology.
+func (rt RegionTopology) NodeCount() int {
+	var n int
+	for _, nodes := range rt.NodesPerRegion {
+		n += nodes
+	}
+	return n
+}
+
+// MultiRegion returns whether the topology spans more than one region.
+func (rt RegionTopology) MultiRegion() bool {
+	return len(rt.GCEZones) > 1
+}
+
+// nodeZones returns the zone of each node; nodes are assigned to regions in
+// order, e.g. the first NodesPerRegion[0] nodes are in the first region.
+func (rt RegionTopology) nodeZones() []string {
+	var zones []string
+	for i, nodes := range rt.NodesPerRegion {
+		for j := 0; j < nodes; j++ {
+			zones = append(zones, rt.GCEZones[i])
+		}
+	}
+	return zones
+}
+
+// ClusterSpec returns the spec of a cluster with the topology; opts are
+// applied on top of it. Workload nodes, if requested, are placed in the first
+// region.
+func (rt RegionTopology) ClusterSpec(r Registry, opts ...spec.Option) spec.ClusterSpec {
+	if len(rt.GCEZones) != len(rt.NodesPerRegion) {
+		panic(errors.AssertionFailedf("topology %s: zone list doesn't match the number of regions", rt.Name))
+	}
+	zones := rt.nodeZones()
+	s := r.MakeClusterSpec(1, opts...)
+	for i := 0; i < s.NumWorkloadNodes(); i++ {
+		zones = append(zones, rt.GCEZones[0])
+	}
+	topologyOpts := []spec.Option{spec.GCEZones(strings.Join(zones, ","))}
+	if rt.MultiRegion() {
+		topologyOpts = append(topologyOpts, spec.Geo())
+	}
+	return r.MakeClusterSpec(len(zones), append(topologyOpts, opts...)...)
+}
+
+// AddMultiRegionTests registers a variant of the given test for each of the
+// given topologies. The name of each variant is the name of the template
+// followed by the name of the topology, and its cluster is made with
+// RegionTopology.ClusterSpec using clusterOpts; template.Cluster and
+// template.Run are ignored. Since zones can only be specified on GCE,
+// multi-region variants are not compatible with the other clouds.
+func AddMultiRegionTests(
+	r Registry,
+	template TestSpec,
+	topologies []RegionTopology,
+	run func(ctx context.Context, t test.Test, c cluster.Cluster, topology RegionTopology),
+	clusterOpts ...spec.Option,
+) {
+	for _, topology := range topologies {
+		topology := topology // copy for closure
+		s := template
+		s.Name = template.Name + "/" + topology.Name
+		s.Cluster = topology.ClusterSpec(r, clusterOpts...)
+		if topology.MultiRegion() {
+			s.CompatibleClouds = template.CompatibleClouds.Remove(Clouds(spec.AWS, spec.Azure))
+		}
+		s.Run = func(ctx context.Context, t test.Test, c cluster.Cluster) {
+			run(ctx, t, c, topology)
+		}
+		r.Add(s)
+	}
+}
@@ -0,0 +1,64 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package registry
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/cluster"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/spec"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/test"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeRegistry struct {
+	specs []TestSpec
+}
+
+func (r *fakeRegistry) MakeClusterSpec(nodeCount int, opts ...spec.Option) spec.ClusterSpec {
+	return spec.MakeClusterSpec(nodeCount, opts...)
+}
+func (r *fakeRegistry) Add(s TestSpec)             { r.specs = append(r.specs, s) }
+func (r *fakeRegistry) AddOperation(OperationSpec) {}
+func (r *fakeRegistry) PromFactory() promauto.Factory {
+	return promauto.With(nil)
+}
+
+func TestAddMultiRegionTests(t *testing.T) {
+	r := &fakeRegistry{}
+	var ran []string
+	AddMultiRegionTests(r, TestSpec{Name: "foo", CompatibleClouds: AllClouds}, AllRegionTopologies,
+		func(_ context.Context, _ test.Test, _ cluster.Cluster, topology RegionTopology) {
+			ran = append(ran, topology.Name)
+		}, spec.WorkloadNode())
+
+	require.Len(t, r.specs, 3)
+	for _, s := range r.specs {
+		s.Run(context.Background(), nil, nil)
+	}
+	require.Equal(t, []string{"single-region", "2-region-witness", "3x3-geo"}, ran)
+
+	single, witness := r.specs[0], r.specs[1]
+	require.Equal(t, "foo/single-region", single.Name)
+	require.Equal(t, 4, single.Cluster.NodeCount)
+	require.False(t, single.Cluster.Geo)
+	require.Equal(t, AllClouds.String(), single.CompatibleClouds.String())
+
+	require.Equal(t, "foo/2-region-witness", witness.Name)
+	require.Equal(t, 8, witness.Cluster.NodeCount)
+	require.True(t, witness.Cluster.Geo)
+	require.Equal(t,
+		"us-east1-b,us-east1-b,us-east1-b,us-west1-b,us-west1-b,us-west1-b,us-central1-b,us-east1-b",
+		witness.Cluster.GCE.Zones)
+	require.Equal(t, Clouds(spec.Local, spec.GCE).String(), witness.CompatibleClouds.String())
+}
@@ -40,6 +40,11 @@ type testStats struct {
 	// LastFailureIsPreempt indicates that the last run of the test failed
 	// due to VM preemption
 	LastFailureIsPreempt bool
+	// FailureRate is the fraction of the previous runs of the test which
+	// failed.
+	FailureRate float64
+	// RecentlyAdded indicates that the test has little or no history.
+	RecentlyAdded bool
 }
 
 // TestSpec is a spec for a roachtest.
@@ -48,6 +53,18 @@ type TestSpec struct {
 	// When Skip is set, this can contain more text to be printed in the logs
 	// after the "--- SKIP" line.
 	SkipDetails string
+	// SkipIssue is the issue tracking the reason the test is skipped, either
+	// as a #<number> reference to an issue of the cockroach repository or as a
+	// URL. It can only be set along with Skip, as can SkipCategory and
+	// SkipExpiry.
+	SkipIssue string
+	// SkipCategory is the kind of reason the test is skipped for.
+	SkipCategory SkipCategory
+	// SkipExpiry is the date, formatted as SkipExpiryLayout, until which the
+	// test is expected to be skipped. The test remains skipped past that date,
+	// but the runner then reports the skip as stale, so that it is revisited
+	// rather than forgotten.
+	SkipExpiry string
 
 	Name string
 	// Owner is the name of the team responsible for signing off on failures of
@@ -58,7 +75,14 @@ type TestSpec struct {
 	// failed. If not specified, the default timeout is 10m before the test's
 	// associated cluster expires. The timeout is always truncated to 10m before
 	// the test's cluster expires.
+	//
+	// The timeout is scaled depending on the cloud and CPU architecture; see
+	// TimeoutScaling.
 	Timeout time.Duration
+	// TimeoutScaling overrides the factor by which Timeout is scaled on the
+	// given clouds, e.g. for tests that do less work in local runs. The default
+	// factors are 2 for local runs and ARM64 clusters, and 1 otherwise.
+	TimeoutScaling map[spec.Cloud]float64
 	// Denotes whether the test is a roachperf benchmark. If true, the test is expected, but not required, to produce
 	// artifacts in Test.PerfArtifactsDir(), which get exported to the roachperf dashboard
 	// (see getPerfArtifacts() in test_runner.go).
@@ -105,6 +129,18 @@ type TestSpec struct {
 	// in the environment.
 	RequiresLicense bool
 
+	// SQLProbe, if set, continuously runs trivial reads and writes against the
+	// cluster while the test runs, and records their latencies and the periods
+	// during which they failed (i.e. the cluster was unavailable) in the perf
+	// artifacts of the test.
+	SQLProbe bool
+
+	// Bottleneck is the resource of the CockroachDB nodes the test is expected
+	// to saturate, e.g. the CPU for a throughput benchmark. The resource
+	// utilization report of the test flags it if the nodes are saturated on
+	// another resource.
+	Bottleneck Resource
+
 	// EncryptionSupport encodes to what extent tests supports
 	// encryption-at-rest. See the EncryptionSupport type for details.
 	// Encryption support is opt-in -- i.e., if the TestSpec does not
@@ -116,6 +152,12 @@ type TestSpec struct {
 	// to epoch leases.
 	Leases LeaseType
 
+	// MetamorphicTopology, if set, lets the runner randomize the parameters of
+	// the topology of the cluster which the test doesn't depend on, within the
+	// given bounds. The Cluster spec is updated accordingly before the test
+	// runs.
+	MetamorphicTopology *MetamorphicTopology
+
 	// SkipPostValidations is a bit-set of post-validations that should be skipped
 	// after the test completes. This is useful for tests that are known to be
 	// incompatible with some validations. By default, tests will run all
@@ -125,6 +167,15 @@ type TestSpec struct {
 	// Run is the test function.
 	Run func(ctx context.Context, t test.Test, c cluster.Cluster)
 
+	// Stages, if set instead of Run, makes the test a staged test: the stages
+	// run in order on the same cluster, and a checkpoint is recorded on it
+	// after each one. A later run of the test on that cluster (with --cluster
+	// and --wipe=false) resumes at the first stage which didn't complete, which lets
+	// long-running tests span multiple runs, e.g. an import on the first day,
+	// chaos on the second and scaling on the third. See also --resume-stage
+	// and --stop-after-stage.
+	Stages []Stage
+
 	// True iff results from this test should not be published externally,
 	// e.g. to GitHub.
 	RedactResults bool
@@ -160,6 +211,11 @@ type TestSpec struct {
 	// explaining why the test has been chosen for opting out of test selection.
 	TestSelectionOptOutSuites SuiteSet
 
+	// Artifacts controls which of the artifacts of the test are kept once it
+	// finishes. It is combined with the policy set through the --artifacts-*
+	// flags; see ArtifactPolicy.Merge.
+	Artifacts ArtifactPolicy
+
 	// stats are populated by test selector based on previous execution data
 	stats *testStats
 }
@@ -172,11 +228,137 @@ func (ts *TestSpec) SetStats(avgDurationInMillis int64, lastFailureIsPreempt boo
 	}
 }
 
+// SetHistory sets the failure rate of the previous runs of the test, and
+// whether it was recently added; see SchedulingPriority.
+func (ts *TestSpec) SetHistory(failureRate float64, recentlyAdded bool) {
+	if ts.stats == nil {
+		ts.stats = &testStats{}
+	}
+	ts.stats.FailureRate = failureRate
+	ts.stats.RecentlyAdded = recentlyAdded
+}
+
+// SchedulingPriority returns the priority with which the test is scheduled
+// within a run; tests with a higher priority run first, so that the tests most
+// likely to fail provide signal early. Recently added tests come first,
+// followed by tests in decreasing order of failure rate. It is 0 if the history
+// of the test is not known.
+func (ts *TestSpec) SchedulingPriority() float64 {
+	if ts.stats == nil {
+		return 0
+	}
+	priority := ts.stats.FailureRate
+	if ts.stats.RecentlyAdded {
+		priority++
+	}
+	return priority
+}
+
 // IsLastFailurePreempt returns true is the last failure of the test was due to VM preemption.
 func (ts *TestSpec) IsLastFailurePreempt() bool {
 	return ts.stats != nil && ts.stats.LastFailureIsPreempt
 }
 
+// AvgDuration returns the average duration of the previous runs of the test,
+// or 0 if it is not known.
+func (ts *TestSpec) AvgDuration() time.Duration {
+	if ts.stats == nil {
+		return 0
+	}
+	return time.Duration(ts.stats.AvgDurationInMillis) * time.Millisecond
+}
+
+// SkipExpiryLayout is the layout of TestSpec.SkipExpiry.
+const SkipExpiryLayout = "2006-01-02"
+
+// SkipCategory is the kind of reason a test is skipped for.
+type SkipCategory string
+
+const (
+	// SkipFlaky is for tests which fail intermittently, for reasons yet to be
+	// understood.
+	SkipFlaky SkipCategory = "flaky"
+	// SkipBug is for tests which fail because of a known bug in cockroach.
+	SkipBug SkipCategory = "bug"
+	// SkipInfra is for tests which fail because of the test itself or of the
+	// infrastructure it runs on.
+	SkipInfra SkipCategory = "infra"
+	// SkipCost is for tests which are too expensive to run regularly.
+	SkipCost SkipCategory = "cost"
+	// SkipManual is for tests which are only meant to be run manually, e.g.
+	// benchmarks or fixture generation.
+	SkipManual SkipCategory = "manual"
+)
+
+var skipCategories = []SkipCategory{SkipFlaky, SkipBug, SkipInfra, SkipCost, SkipManual}
+
+// IsValid returns whether the category is one of the known categories, or
+// unspecified.
+func (c SkipCategory) IsValid() bool {
+	return c == "" || slices.Contains(skipCategories, c)
+}
+
+// SkipExpired returns whether the test is skipped and the given time is past
+// the day of its SkipExpiry.
+func (ts *TestSpec) SkipExpired(now time.Time) bool {
+	if ts.Skip == "" || ts.SkipExpiry == "" {
+		return false
+	}
+	expiry, err := time.Parse(SkipExpiryLayout, ts.SkipExpiry)
+	if err != nil {
+		// Invalid expiries are rejected when the test is registered.
+		return false
+	}
+	return !now.Before(expiry.AddDate(0, 0, 1))
+}
+
+// SkipReason returns the reason the test is skipped along with its category,
+// issue and expiry, e.g. "[flaky] times out (#123, expires 2024-09-01)".
+func (ts *TestSpec) SkipReason() string {
+	var b strings.Builder
+	if ts.SkipCategory != "" {
+		fmt.Fprintf(&b, "[%s] ", ts.SkipCategory)
+	}
+	b.WriteString(ts.Skip)
+	var details []string
+	if ts.SkipIssue != "" && ts.SkipIssue != ts.Skip {
+		details = append(details, ts.SkipIssue)
+	}
+	if ts.SkipExpiry != "" {
+		details = append(details, "expires "+ts.SkipExpiry)
+	}
+	if len(details) > 0 {
+		fmt.Fprintf(&b, " (%s)", strings.Join(details, ", "))
+	}
+	return b.String()
+}
+
+// ArtifactPolicy controls which artifacts of a test run are kept (and how)
+// before they are published. The zero value keeps all artifacts, as is.
+type ArtifactPolicy struct {
+	// OnlyOnFailure, if set, discards the artifacts of passing runs, except
+	// for the test log and the perf artifacts.
+	OnlyOnFailure bool
+	// MaxLogFileSize, if positive, is the size (in bytes) above which log
+	// files, other than the test log, are discarded.
+	MaxLogFileSize int64
+	// Compress, if set, gzips the artifacts (other than the perf artifacts).
+	Compress bool
+}
+
+// Merge combines two policies; the result is the most restrictive of the two.
+func (p ArtifactPolicy) Merge(o ArtifactPolicy) ArtifactPolicy {
+	res := ArtifactPolicy{
+		OnlyOnFailure:  p.OnlyOnFailure || o.OnlyOnFailure,
+		MaxLogFileSize: p.MaxLogFileSize,
+		Compress:       p.Compress || o.Compress,
+	}
+	if o.MaxLogFileSize > 0 && (res.MaxLogFileSize <= 0 || o.MaxLogFileSize < res.MaxLogFileSize) {
+		res.MaxLogFileSize = o.MaxLogFileSize
+	}
+	return res
+}
+
 // PostValidation is a type of post-validation that runs after a test completes.
 type PostValidation int
 
@@ -230,6 +412,41 @@ const (
 	MetamorphicLeases
 )
 
+// Stage is a stage of a staged test; see TestSpec.Stages.
+type Stage struct {
+	// Name identifies the stage in the checkpoints and on the command line,
+	// and is unique within the test.
+	Name string
+	Run  func(ctx context.Context, t test.Test, c cluster.Cluster)
+}
+
+// Resource is a resource of the nodes of a cluster.
+type Resource int
+
+func (r Resource) String() string {
+	switch r {
+	case UnknownBottleneck:
+		return "unknown"
+	case CPUBottleneck:
+		return "cpu"
+	case MemoryBottleneck:
+		return "memory"
+	case DiskBottleneck:
+		return "disk"
+	default:
+		return fmt.Sprintf("resource-%d", r)
+	}
+}
+
+const (
+	// UnknownBottleneck means that the test doesn't declare the resource it
+	// saturates, and isn't flagged whichever resource it saturates.
+	UnknownBottleneck = Resource(iota)
+	CPUBottleneck
+	MemoryBottleneck
+	DiskBottleneck
+)
+
 // CloudSet represents a set of clouds.
 //
 // Instances of CloudSet are immutable. The uninitialized (zero) value is not
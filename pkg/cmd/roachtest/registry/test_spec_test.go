@@ -12,6 +12,7 @@ package registry
 
 import (
 	"testing"
+	"time"
 
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/spec"
 	"github.com/stretchr/testify/require"
@@ -43,3 +44,24 @@ func TestSuiteSet(t *testing.T) {
 	require.False(t, s.Contains(ReleaseQualification))
 	expect(ManualOnly, "<none>")
 }
+
+func TestSkipExpiry(t *testing.T) {
+	s := TestSpec{
+		Skip:         "times out",
+		SkipIssue:    "#123",
+		SkipCategory: SkipFlaky,
+		SkipExpiry:   "2024-09-01",
+	}
+	require.Equal(t, "[flaky] times out (#123, expires 2024-09-01)", s.SkipReason())
+	require.False(t, s.SkipExpired(time.Date(2024, 9, 1, 23, 59, 0, 0, time.UTC)))
+	require.True(t, s.SkipExpired(time.Date(2024, 9, 2, 0, 0, 0, 0, time.UTC)))
+
+	// Free-form skips never expire.
+	s = TestSpec{Skip: "#456"}
+	require.Equal(t, "#456", s.SkipReason())
+	require.False(t, s.SkipExpired(time.Date(2100, 1, 1, 0, 0, 0, 0, time.UTC)))
+
+	require.True(t, SkipManual.IsValid())
+	require.True(t, SkipCategory("").IsValid())
+	require.False(t, SkipCategory("later").IsValid())
+}
@@ -0,0 +1,269 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/roachtestflags"
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	reportFormatJUnit = "junit"
+	reportFormatJSON  = "json"
+
+	reportStatusSuccess = "success"
+	reportStatusFailure = "failure"
+	reportStatusFlaky   = "flaky"
+	reportStatusSkipped = "skipped"
+	// reportStatusNotRun is the status of the test runs which were not
+	// started because of --max-run-duration, or because they were skipped
+	// through the runner API. They are only reported, and not recorded in
+	// the checkpoint, so that --resume-from runs them.
+	reportStatusNotRun = "not_run"
+	// reportStatusInfraSkipped is the status of the tests which were not
+	// scheduled as their cluster exceeds the cloud quota. See
+	// --check-cloud-quota.
+	reportStatusInfraSkipped = "infra_skipped"
+
+	junitReportFile = "report.xml"
+	jsonReportFile  = "report.json"
+)
+
+// testReportEntry is the machine-readable result of a single test run.
+type testReportEntry struct {
+	Name            string  `json:"name"`
+	Owner           string  `json:"owner"`
+	DurationSeconds float64 `json:"duration_seconds"`
+	Status          string  `json:"status"`
+	Failure         string  `json:"failure,omitempty"`
+	// Reason is the reason why the test was not run, for the not_run status.
+	Reason       string `json:"reason,omitempty"`
+	ArtifactsDir string `json:"artifacts_dir"`
+	// Utilization is the resource utilization of the nodes while the test ran.
+	Utilization *resourceUtilization `json:"utilization,omitempty"`
+}
+
+// parseReportFormats parses the value of the --report-format flag.
+func parseReportFormats(s string) ([]string, error) {
+	var formats []string
+	for _, f := range strings.Split(s, ",") {
+		switch f = strings.TrimSpace(f); f {
+		case "":
+		case reportFormatJUnit, reportFormatJSON:
+			if !slices.Contains(formats, f) {
+				formats = append(formats, f)
+			}
+		default:
+			return nil, errors.Newf("unknown report format %q", f)
+		}
+	}
+	return formats, nil
+}
+
+// collectTestReport returns the results of all the tests run by the runner,
+// sorted by name.
+func collectTestReport(r *testRunner) []testReportEntry {
+	r.status.Lock()
+	defer r.status.Unlock()
+
+	var entries []testReportEntry
+	add := func(tests map[*testImpl]struct{}, status string) {
+		for t := range tests {
+			e := testReportEntry{
+				Name:            t.Name(),
+				Owner:           string(t.spec.Owner),
+				DurationSeconds: t.duration().Seconds(),
+				Status:          status,
+				ArtifactsDir:    t.ArtifactsDir(),
+				Utilization:     t.utilization,
+			}
+			switch status {
+			case reportStatusFailure:
+				e.Failure = t.failureMsg()
+			case reportStatusFlaky:
+				e.Failure = t.firstFailure.failureMsg()
+			}
+			entries = append(entries, e)
+		}
+	}
+	add(r.status.pass, reportStatusSuccess)
+	add(r.status.fail, reportStatusFailure)
+	add(r.status.flaky, reportStatusFlaky)
+	add(r.status.skip, reportStatusSkipped)
+	if r.budget != nil {
+		for _, t := range r.budget.notRunTests() {
+			entries = append(entries, testReportEntry{
+				Name: t.name, Owner: t.owner, Status: reportStatusNotRun, Reason: notRunReason,
+			})
+		}
+	}
+	if r.costs != nil {
+		for _, t := range r.costs.refusedTests() {
+			entries = append(entries, testReportEntry{
+				Name: t.name, Owner: t.owner, Status: reportStatusNotRun, Reason: costRefusedReason,
+			})
+		}
+	}
+	for _, t := range r.skippedTests() {
+		entries = append(entries, testReportEntry{
+			Name: t.name, Owner: t.owner, Status: reportStatusNotRun, Reason: skippedRemainingReason,
+		})
+	}
+	for _, t := range r.cloudQuota.infraSkippedTests() {
+		entries = append(entries, testReportEntry{Name: t.name, Owner: t.owner, Status: reportStatusInfraSkipped})
+	}
+
+	slices.SortFunc(entries, func(a, b testReportEntry) int {
+		return strings.Compare(a.Name, b.Name)
+	})
+	return entries
+}
+
+// testReportStatus returns the report status of a finished test run.
+func testReportStatus(t *testImpl) string {
+	switch {
+	case t.Failed():
+		return reportStatusFailure
+	case t.spec.Skip != "":
+		return reportStatusSkipped
+	case t.firstFailure != nil:
+		return reportStatusFlaky
+	default:
+		return reportStatusSuccess
+	}
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     float64         `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name       string          `xml:"name,attr"`
+	Classname  string          `xml:"classname,attr"`
+	Time       float64         `xml:"time,attr"`
+	Properties []junitProperty `xml:"properties>property,omitempty"`
+	Failure    *junitFailure   `xml:"failure,omitempty"`
+	Skipped    *junitSkipped   `xml:"skipped,omitempty"`
+}
+
+type junitSkipped struct {
+	Message string `xml:"message,attr,omitempty"`
+}
+
+type junitProperty struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// makeJUnitReport converts the test results into a JUnit XML document with a
+// single "roachtest" test suite.
+func makeJUnitReport(entries []testReportEntry) junitTestSuites {
+	suite := junitTestSuite{Name: "roachtest", Tests: len(entries)}
+	for _, e := range entries {
+		tc := junitTestCase{
+			Name:      e.Name,
+			Classname: "roachtest",
+			Time:      e.DurationSeconds,
+			Properties: []junitProperty{
+				{Name: "owner", Value: e.Owner},
+				{Name: "artifacts", Value: e.ArtifactsDir},
+			},
+		}
+		if u := e.Utilization; u != nil {
+			tc.Properties = append(tc.Properties, junitProperty{Name: "utilization", Value: u.String()})
+			if u.WrongBottleneck {
+				tc.Properties = append(tc.Properties, junitProperty{Name: "wrong_bottleneck", Value: "true"})
+			}
+		}
+		switch e.Status {
+		case reportStatusFailure:
+			suite.Failures++
+			msg, _, _ := strings.Cut(e.Failure, "\n")
+			tc.Failure = &junitFailure{Message: msg, Text: e.Failure}
+		case reportStatusFlaky:
+			tc.Properties = append(tc.Properties, junitProperty{Name: "flaky", Value: e.Failure})
+		case reportStatusSkipped:
+			suite.Skipped++
+			tc.Skipped = &junitSkipped{}
+		case reportStatusNotRun:
+			suite.Skipped++
+			tc.Skipped = &junitSkipped{Message: e.Reason}
+		case reportStatusInfraSkipped:
+			suite.Skipped++
+			tc.Skipped = &junitSkipped{Message: infraSkippedReason}
+		}
+		suite.Time += e.DurationSeconds
+		suite.Cases = append(suite.Cases, tc)
+	}
+	return junitTestSuites{Suites: []junitTestSuite{suite}}
+}
+
+// writeTestReport writes the test results to the given directory in each of
+// the given formats.
+func writeTestReport(dir string, formats []string, entries []testReportEntry) error {
+	for _, format := range formats {
+		var path string
+		var data []byte
+		var err error
+		switch format {
+		case reportFormatJUnit:
+			path = filepath.Join(dir, junitReportFile)
+			data, err = xml.MarshalIndent(makeJUnitReport(entries), "", "  ")
+			data = append([]byte(xml.Header), data...)
+		case reportFormatJSON:
+			path = filepath.Join(dir, jsonReportFile)
+			data, err = json.MarshalIndent(entries, "", "  ")
+		default:
+			return errors.AssertionFailedf("unknown report format %q", format)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "marshaling %s report", format)
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+		if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+			return errors.Wrapf(err, "writing %s report", format)
+		}
+	}
+	return nil
+}
+
+// maybeWriteTestReport writes the machine-readable reports requested via
+// --report-format to the artifacts directory.
+func maybeWriteTestReport(r *testRunner, formats []string) error {
+	if len(formats) == 0 {
+		return nil
+	}
+	return writeTestReport(roachtestflags.ArtifactsDir, formats, collectTestReport(r))
+}
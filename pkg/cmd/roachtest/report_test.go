@@ -0,0 +1,75 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseReportFormats(t *testing.T) {
+	formats, err := parseReportFormats("")
+	require.NoError(t, err)
+	require.Empty(t, formats)
+
+	formats, err = parseReportFormats("junit, json,junit")
+	require.NoError(t, err)
+	require.Equal(t, []string{reportFormatJUnit, reportFormatJSON}, formats)
+
+	_, err = parseReportFormats("junit,html")
+	require.ErrorContains(t, err, `unknown report format "html"`)
+}
+
+func TestWriteTestReport(t *testing.T) {
+	entries := []testReportEntry{
+		{Name: "a", Owner: "kv", DurationSeconds: 2, Status: reportStatusSuccess, ArtifactsDir: "artifacts/a"},
+		{Name: "b", Owner: "sql", DurationSeconds: 3, Status: reportStatusFailure,
+			Failure: "boom\nstack trace", ArtifactsDir: "artifacts/b"},
+		{Name: "c", Owner: "kv", Status: reportStatusSkipped, ArtifactsDir: "artifacts/c"},
+		{Name: "d", Owner: "kv", Status: reportStatusNotRun, Reason: notRunReason},
+		{Name: "e", Owner: "sql", Status: reportStatusInfraSkipped},
+	}
+	dir := t.TempDir()
+	require.NoError(t, writeTestReport(dir, []string{reportFormatJUnit, reportFormatJSON}, entries))
+
+	data, err := os.ReadFile(filepath.Join(dir, jsonReportFile))
+	require.NoError(t, err)
+	var decoded []testReportEntry
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, entries, decoded)
+
+	data, err = os.ReadFile(filepath.Join(dir, junitReportFile))
+	require.NoError(t, err)
+	var suites junitTestSuites
+	require.NoError(t, xml.Unmarshal(data, &suites))
+	require.Len(t, suites.Suites, 1)
+	suite := suites.Suites[0]
+	require.Equal(t, 5, suite.Tests)
+	require.Equal(t, 1, suite.Failures)
+	require.Equal(t, 3, suite.Skipped)
+	require.Equal(t, 5.0, suite.Time)
+	require.Len(t, suite.Cases, 5)
+	require.Nil(t, suite.Cases[0].Failure)
+	require.Equal(t, "boom", suite.Cases[1].Failure.Message)
+	require.Equal(t, "boom\nstack trace", suite.Cases[1].Failure.Text)
+	require.NotNil(t, suite.Cases[2].Skipped)
+	require.Equal(t, notRunReason, suite.Cases[3].Skipped.Message)
+	require.Equal(t, infraSkippedReason, suite.Cases[4].Skipped.Message)
+	require.Equal(t, []junitProperty{
+		{Name: "owner", Value: "sql"},
+		{Name: "artifacts", Value: "artifacts/b"},
+	}, suite.Cases[1].Properties)
+}
@@ -0,0 +1,147 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/roachtestflags"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/spec"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/test"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/vm"
+)
+
+// testSeed returns the random seed of a run of a test. It is derived from the
+// global seed and the name of the test, so that it doesn't depend on which
+// other tests are run or in which order; runs after the first one (see
+// --count) get a different seed each.
+func testSeed(globalSeed int64, testName string, runNum int) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(testName))
+	if runNum > 1 {
+		_, _ = fmt.Fprintf(h, "#%d", runNum)
+	}
+	return globalSeed ^ int64(h.Sum64())
+}
+
+// applyMetamorphicTopologies randomizes the cluster specs of the given tests
+// which have a MetamorphicTopology. Like testSeed, the choices only depend on
+// the global seed and the name of the test.
+func applyMetamorphicTopologies(specs []registry.TestSpec, globalSeed int64) {
+	for i := range specs {
+		s := &specs[i]
+		if s.MetamorphicTopology == nil {
+			continue
+		}
+		rng := rand.New(rand.NewSource(testSeed(globalSeed, s.Name+"/topology", 1 /* runNum */)))
+		s.MetamorphicTopology.Apply(rng, &s.Cluster)
+		fmt.Printf("%s: metamorphic topology: %s\n", s.Name, s.MetamorphicTopology.Describe(s.Cluster))
+	}
+}
+
+// reproInfo contains the choices made when running a test that are needed to
+// run it again the same way.
+type reproInfo struct {
+	testName   string
+	runNum     int
+	cloud      spec.Cloud
+	globalSeed int64
+	seed       int64
+	// arch is the CPU architecture of the cluster if it was picked randomly,
+	// and empty otherwise.
+	arch vm.CPUArch
+	// encrypted is set if encryption-at-rest was enabled metamorphically.
+	encrypted *bool
+	// assertionsSeed is set if the cockroach binary with runtime assertions
+	// was used; it is the seed of its metamorphic constants.
+	assertionsSeed *int64
+	// buildVersion is the version of the cockroach binary.
+	buildVersion string
+	// clusterSettings are the cluster settings passed with --cluster-settings.
+	clusterSettings map[string]string
+}
+
+// command returns a shell command that runs the test again with the same
+// seed, flags, and binaries.
+func (ri reproInfo) command() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# test seed: %d, cockroach version: %s\n", ri.seed, ri.buildVersion)
+	if ri.assertionsSeed != nil {
+		fmt.Fprintf(&b, "%s=%d ", test.EnvAssertionsEnabledSeed, *ri.assertionsSeed)
+	}
+	fmt.Fprintf(&b, "roachtest run '^%s$' --cloud=%s --global-seed=%d",
+		regexp.QuoteMeta(ri.testName), ri.cloud, ri.globalSeed)
+	if ri.runNum > 1 {
+		// The seed of a run depends on its number, so all the runs up to the
+		// failed one are needed.
+		fmt.Fprintf(&b, " --count=%d", ri.runNum)
+	}
+	if ri.encrypted != nil {
+		encryptionProbability := 0
+		if *ri.encrypted {
+			encryptionProbability = 1
+		}
+		fmt.Fprintf(&b, " --metamorphic-encryption-probability=%d", encryptionProbability)
+	}
+	switch ri.arch {
+	case vm.ArchARM64:
+		b.WriteString(" --metamorphic-arm64-probability=1")
+	case vm.ArchFIPS:
+		b.WriteString(" --metamorphic-arm64-probability=0 --metamorphic-fips-probability=1")
+	case vm.ArchAMD64:
+		b.WriteString(" --metamorphic-arm64-probability=0 --metamorphic-fips-probability=0")
+	}
+	if len(ri.clusterSettings) > 0 {
+		settings := make([]string, 0, len(ri.clusterSettings))
+		for name, value := range ri.clusterSettings {
+			settings = append(settings, name+"="+value)
+		}
+		sort.Strings(settings)
+		fmt.Fprintf(&b, " --cluster-settings='%s'", strings.Join(settings, ","))
+	}
+	return b.String()
+}
+
+// reproCommand returns the command that reproduces this run of the test on
+// the given cluster, which may be nil if it couldn't be created.
+func (t *testImpl) reproCommand(c *clusterImpl) string {
+	ri := reproInfo{
+		testName:        t.Name(),
+		runNum:          t.runNum,
+		cloud:           roachtestflags.Cloud,
+		globalSeed:      roachtestflags.GlobalSeed,
+		seed:            t.seed,
+		clusterSettings: roachtestflags.ClusterSettings,
+	}
+	if t.buildVersion != nil {
+		ri.buildVersion = t.buildVersion.String()
+	}
+	if c != nil {
+		if t.spec.Cluster.Arch == "" {
+			ri.arch = c.arch
+		}
+		if t.spec.EncryptionSupport == registry.EncryptionMetamorphic {
+			encrypted := c.encAtRest
+			ri.encrypted = &encrypted
+		}
+		if t.usingRuntimeAssertions() {
+			assertionsSeed := c.cockroachRandomSeed()
+			ri.assertionsSeed = &assertionsSeed
+		}
+	}
+	return ri.command()
+}
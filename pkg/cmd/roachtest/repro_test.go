@@ -0,0 +1,74 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/spec"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/vm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTestSeed(t *testing.T) {
+	// The seed of a test only depends on the global seed, its name, and the
+	// run number.
+	require.Equal(t, testSeed(1, "foo", 1), testSeed(1, "foo", 1))
+	require.NotEqual(t, testSeed(1, "foo", 1), testSeed(2, "foo", 1))
+	require.NotEqual(t, testSeed(1, "foo", 1), testSeed(1, "bar", 1))
+	require.NotEqual(t, testSeed(1, "foo", 1), testSeed(1, "foo", 2))
+}
+
+func TestReproCommand(t *testing.T) {
+	encrypted := true
+	assertionsSeed := int64(42)
+	testCases := []struct {
+		name     string
+		ri       reproInfo
+		expected string
+	}{
+		{
+			name: "defaults",
+			ri: reproInfo{
+				testName: "kv0/nodes=3", runNum: 1, cloud: spec.GCE,
+				globalSeed: 7, seed: 123, buildVersion: "v24.2.0",
+			},
+			expected: `# test seed: 123, cockroach version: v24.2.0
+roachtest run '^kv0/nodes=3$' --cloud=gce --global-seed=7`,
+		},
+		{
+			name: "metamorphic choices",
+			ri: reproInfo{
+				testName: "tpcc/w=100", runNum: 3, cloud: spec.AWS,
+				globalSeed: -5, seed: 9, arch: vm.ArchFIPS, encrypted: &encrypted,
+				assertionsSeed: &assertionsSeed, buildVersion: "v24.2.0",
+			},
+			expected: `# test seed: 9, cockroach version: v24.2.0
+ROACHTEST_ASSERTIONS_ENABLED_SEED=42 roachtest run '^tpcc/w=100$' --cloud=aws --global-seed=-5 --count=3 ` +
+				`--metamorphic-encryption-probability=1 --metamorphic-arm64-probability=0 --metamorphic-fips-probability=1`,
+		},
+		{
+			name: "cluster settings",
+			ri: reproInfo{
+				testName: "kv0/nodes=3", runNum: 1, cloud: spec.GCE,
+				globalSeed: 7, seed: 123, buildVersion: "v24.2.0",
+				clusterSettings: map[string]string{"b.enabled": "true", "a.interval": "1s"},
+			},
+			expected: `# test seed: 123, cockroach version: v24.2.0
+roachtest run '^kv0/nodes=3$' --cloud=gce --global-seed=7 --cluster-settings='a.interval=1s,b.enabled=true'`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, tc.ri.command())
+		})
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/roachtestflags"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+	"github.com/cockroachdb/errors"
+)
+
+// runInfoFile is the name of the file, in the artifacts directory, which
+// records the flags a run was started with. See rerun-failed.
+const runInfoFile = "run_info.json"
+
+// runInfo describes how a run was started.
+type runInfo struct {
+	// Flags contains the values of the run flags passed on the command line,
+	// and of --global-seed, by name. Secret flags are omitted.
+	Flags map[string]string `json:"flags"`
+}
+
+// rerunExcludedFlags are the flags of a previous run which rerun-failed
+// doesn't carry over: they select the tests to run, or where to write the
+// artifacts, which rerun-failed determines on its own.
+var rerunExcludedFlags = map[string]struct{}{
+	"artifacts":          {},
+	"artifacts-literal":  {},
+	"resume-from":        {},
+	"select-probability": {},
+	"selective-tests":    {},
+	"shard":              {},
+	"shard-strategy":     {},
+	"shard-manifest":     {},
+	"test-history":       {},
+}
+
+// writeRunInfo records the flags of the current run in the given artifacts
+// directory.
+func writeRunInfo(dir string) error {
+	info := runInfo{Flags: roachtestflags.ChangedRunFlags()}
+	info.Flags["global-seed"] = strconv.FormatInt(roachtestflags.GlobalSeed, 10)
+	data, err := json.MarshalIndent(info, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	return errors.Wrap(os.WriteFile(filepath.Join(dir, runInfoFile), append(data, '\n'), 0644),
+		"writing run info")
+}
+
+// loadRunInfo loads the run info recorded in the given artifacts directory.
+func loadRunInfo(dir string) (runInfo, error) {
+	var info runInfo
+	data, err := os.ReadFile(filepath.Join(dir, runInfoFile))
+	if err != nil {
+		return info, errors.Wrap(err, "reading run info")
+	}
+	if err := json.Unmarshal(data, &info); err != nil {
+		return info, errors.Wrap(err, "decoding run info")
+	}
+	return info, nil
+}
+
+// failedTests returns the sorted names of the tests with at least one failed
+// run in the given checkpoint entries.
+func failedTests(entries []checkpointEntry) []string {
+	failed := make(map[string]struct{})
+	for _, e := range entries {
+		if !e.passed() {
+			failed[e.Name] = struct{}{}
+		}
+	}
+	names := make([]string, 0, len(failed))
+	for name := range failed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// exactNamesPattern returns a regular expression which matches exactly the
+// given test names.
+func exactNamesPattern(names []string) string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = regexp.QuoteMeta(name)
+	}
+	return fmt.Sprintf("^(%s)$", strings.Join(quoted, "|"))
+}
+
+// prepareRerunFailed configures the run flags to run again the failed tests of
+// the run whose artifacts are in prevDir: the flags of that run are applied,
+// unless they were passed on the command line, and the artifacts are written
+// to a new subdirectory of prevDir. It returns the names of the failed tests.
+func prepareRerunFailed(prevDir string) ([]string, error) {
+	entries, err := loadCheckpointEntries(prevDir)
+	if err != nil {
+		return nil, err
+	}
+	failed := failedTests(entries)
+	if len(failed) == 0 {
+		return nil, nil
+	}
+
+	info, err := loadRunInfo(prevDir)
+	if err != nil {
+		return nil, err
+	}
+	flags := make(map[string]string, len(info.Flags))
+	for name, val := range info.Flags {
+		if _, ok := rerunExcludedFlags[name]; !ok {
+			flags[name] = val
+		}
+	}
+	if err := roachtestflags.SetUnchangedRunFlags(flags); err != nil {
+		return nil, err
+	}
+	roachtestflags.ArtifactsDir = filepath.Join(
+		prevDir, "rerun_"+timeutil.Now().Format("20060102_150405"))
+	return failed, nil
+}
@@ -0,0 +1,68 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/roachtestflags"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFailedTests(t *testing.T) {
+	entries := []checkpointEntry{
+		{Name: "b", Run: 1, Status: reportStatusFailure},
+		{Name: "a", Run: 1, Status: reportStatusSuccess},
+		{Name: "c", Run: 1, Status: reportStatusSkipped},
+		{Name: "b", Run: 2, Status: reportStatusFailure},
+		{Name: "a", Run: 2, Status: reportStatusFailure},
+	}
+	failed := failedTests(entries)
+	require.Equal(t, []string{"a", "b"}, failed)
+
+	re := regexp.MustCompile(exactNamesPattern([]string{"kv0/nodes=3", "tpcc/w=1+"}))
+	require.True(t, re.MatchString("kv0/nodes=3"))
+	require.True(t, re.MatchString("tpcc/w=1+"))
+	require.False(t, re.MatchString("kv0/nodes=3/cpu=8"))
+	require.False(t, re.MatchString("tpcc/w=11"))
+}
+
+func TestPrepareRerunFailed(t *testing.T) {
+	defer func(dir string) { roachtestflags.ArtifactsDir = dir }(roachtestflags.ArtifactsDir)
+	dir := t.TempDir()
+
+	// A run with no failures has nothing to rerun.
+	cp := newRunCheckpoint(dir)
+	require.NoError(t, cp.record(checkpointEntry{Name: "a", Run: 1, Status: reportStatusSuccess}))
+	failed, err := prepareRerunFailed(dir)
+	require.NoError(t, err)
+	require.Empty(t, failed)
+
+	// The run info is needed to rerun the failed tests with the same flags.
+	require.NoError(t, cp.record(checkpointEntry{Name: "b", Run: 1, Status: reportStatusFailure}))
+	_, err = prepareRerunFailed(dir)
+	require.ErrorContains(t, err, "reading run info")
+
+	require.NoError(t, writeRunInfo(dir))
+	info, err := loadRunInfo(dir)
+	require.NoError(t, err)
+	require.Equal(t, strconv.FormatInt(roachtestflags.GlobalSeed, 10), info.Flags["global-seed"])
+
+	failed, err = prepareRerunFailed(dir)
+	require.NoError(t, err)
+	require.Equal(t, []string{"b"}, failed)
+	require.Equal(t, dir, filepath.Dir(roachtestflags.ArtifactsDir))
+	require.True(t, strings.HasPrefix(filepath.Base(roachtestflags.ArtifactsDir), "rerun_"))
+}
@@ -0,0 +1,55 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package roachtestflags
+
+import (
+	"strings"
+
+	"github.com/spf13/pflag"
+)
+
+// FilterExprList holds the filter expressions passed with --filter, which
+// can be repeated. A test matches if any of them holds.
+type FilterExprList []string
+
+// String returns the disjunction of the expressions, which is itself a filter
+// expression, so that it can be passed back to --filter.
+func (f FilterExprList) String() string {
+	if len(f) == 1 {
+		return f[0]
+	}
+	parts := make([]string, len(f))
+	for i, e := range f {
+		parts[i] = "(" + e + ")"
+	}
+	return strings.Join(parts, " || ")
+}
+
+type filterExprsValue struct {
+	val *FilterExprList
+}
+
+var _ pflag.Value = (*filterExprsValue)(nil)
+
+func (v *filterExprsValue) String() string {
+	return v.val.String()
+}
+
+func (v *filterExprsValue) Type() string {
+	return "string"
+}
+
+// Set adds an expression. Unlike for slice flags, commas are not separators,
+// as they can appear in the regexps of the expressions.
+func (v *filterExprsValue) Set(str string) error {
+	*v.val = append(*v.val, str)
+	return nil
+}
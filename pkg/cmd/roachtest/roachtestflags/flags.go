@@ -38,14 +38,18 @@ var (
 		        to tailor behaviour to that cloud.`,
 	})
 
-	Suite string
-	_     = registerListFlag(&Suite, FlagInfo{
-		Name:  "suite",
-		Usage: `List only tests from the given suite (e.g. "nightly")`,
+	Suites []string
+	_      = registerListFlag(&Suites, FlagInfo{
+		Name: "suite",
+		Usage: `
+			List only tests from the given suite (e.g. "nightly"). Can be repeated,
+			or be a comma-separated list, to list the tests of any of the suites`,
 	})
-	_ = registerRunFlag(&Suite, FlagInfo{
-		Name:  "suite",
-		Usage: `Run only tests from the given suite (e.g. "nightly")`,
+	_ = registerRunFlag(&Suites, FlagInfo{
+		Name: "suite",
+		Usage: `
+			Run only tests from the given suite (e.g. "nightly"). Can be repeated,
+			or be a comma-separated list, to run the tests of any of the suites`,
 	})
 
 	Owner string
@@ -58,6 +62,26 @@ var (
 		Usage: `Run only tests with the given owner (e.g. "kv")`,
 	})
 
+	FilterExprs FilterExprList
+	_           = registerListFlag(&FilterExprs, FlagInfo{
+		Name: "filter",
+		Usage: `List only tests matching the given boolean expression over suites,
+		        owners, clouds and names, e.g.
+		        "suite:nightly && owner:kv && !benchmark && cloud:gce". Can be
+		        repeated to list the tests matching any of the expressions, e.g.
+		        --filter "suite:nightly && owner:kv" --filter "benchmark && name:tpch".
+		        The tests must also match --suite and the regexps, if any`,
+	})
+	_ = registerRunFlag(&FilterExprs, FlagInfo{
+		Name: "filter",
+		Usage: `Run only tests matching the given boolean expression over suites,
+		        owners, clouds and names, e.g.
+		        "suite:nightly && owner:kv && !benchmark && cloud:gce". Can be
+		        repeated to run the tests matching any of the expressions, e.g.
+		        --filter "suite:nightly && owner:kv" --filter "benchmark && name:tpch".
+		        The tests must also match --suite and the regexps, if any`,
+	})
+
 	OnlyBenchmarks bool
 	_              = registerListFlag(&OnlyBenchmarks, FlagInfo{
 		Name:  "bench",
@@ -144,6 +168,121 @@ var (
 		lead to cluster unavailability or operation failures.`,
 	})
 
+	OperationDryRun bool
+	_               = registerRunOpsFlag(&OperationDryRun, FlagInfo{
+		Name: "dry-run",
+		Usage: `
+			Run all the operations matched by the regex without mutating the
+			cluster: dependencies are checked and read-only SQL statements are
+			executed, but roachprod commands, file uploads, node starts and stops,
+			and all other SQL statements are printed instead of executed.`,
+	})
+
+	OperationSchedule string
+	_                 = registerRunOpsFlag(&OperationSchedule, FlagInfo{
+		Name: "schedule",
+		Usage: `
+			Run as a long-running scheduler: on each tick of the given cron
+			schedule (e.g. '*/15 * * * *' or '@every 30m'), one of the operations
+			matched by the regex is picked at random, according to their weights,
+			among those not in their cooldown period, and run against the cluster.
+			Runs until interrupted.`,
+	})
+
+	OperationWeights map[string]string
+	_                = registerRunOpsFlag(&OperationWeights, FlagInfo{
+		Name: "operation-weight",
+		Usage: `
+			Relative weight of an operation for --schedule, as <operation>=<weight>.
+			Operations default to a weight of 1; a weight of 0 disables an
+			operation. Can be specified multiple times.`,
+	})
+
+	OperationCooldown time.Duration
+	_                 = registerRunOpsFlag(&OperationCooldown, FlagInfo{
+		Name: "operation-cooldown",
+		Usage: `
+			Minimum time between two runs of the same operation for --schedule,
+			for the operations which don't specify their own cooldown.`,
+	})
+
+	SchedulerStatePath string
+	_                  = registerRunOpsFlag(&SchedulerStatePath, FlagInfo{
+		Name: "scheduler-state",
+		Usage: `
+			Path of the file in which --schedule persists when each operation last
+			ran, so that cooldowns are honored across restarts of the scheduler.`,
+	})
+
+	PendingCleanupsDir string
+	_                  = registerRunOpsFlag(&PendingCleanupsDir, FlagInfo{
+		Name: "pending-cleanups-dir",
+		Usage: `
+			Directory in which the cleanups of operations are persisted until they
+			complete, so that --resume-cleanups can run the ones that didn't.
+			Defaults to the pending-cleanups directory in the artifacts directory.`,
+	})
+
+	ResumeCleanups bool
+	_              = registerRunOpsFlag(&ResumeCleanups, FlagInfo{
+		Name: "resume-cleanups",
+		Usage: `
+			Instead of running an operation, run the pending cleanups of the
+			operations matched by the regex on the cluster, e.g. the ones left
+			behind by a runner that died before running them.`,
+	})
+
+	DiskStallNode string
+	_             = registerRunOpsFlag(&DiskStallNode, FlagInfo{
+		Name: "disk-stall-node",
+		Usage: `
+			Nodes whose disks are stalled or slowed down by the disk-stall
+			operations, e.g. '2' or '1,3'. A random node if unset.`,
+	})
+
+	PartitionNodes string
+	_              = registerRunOpsFlag(&PartitionNodes, FlagInfo{
+		Name: "partition-nodes",
+		Usage: `
+			Nodes on one side of the partitions created by the network-partition
+			operations, e.g. '1-3' or '1,4'. A random node if unset.`,
+	})
+
+	PartitionPeers string
+	_              = registerRunOpsFlag(&PartitionPeers, FlagInfo{
+		Name: "partition-peers",
+		Usage: `
+			Nodes on the other side of the partitions created by the partial and
+			asymmetric network-partition operations, in the format of
+			--partition-nodes. A random node not in --partition-nodes if unset.`,
+	})
+
+	PartitionDuration time.Duration = 10 * time.Minute
+	_                               = registerRunOpsFlag(&PartitionDuration, FlagInfo{
+		Name: "partition-duration",
+		Usage: `
+			Maximum duration of the partitions created by the network-partition
+			operations: the partitioned nodes heal themselves after it, even if the
+			cleanup of the operation doesn't run. Should exceed --wait-before-cleanup.`,
+	})
+
+	PressureNodes string
+	_             = registerRunOpsFlag(&PressureNodes, FlagInfo{
+		Name: "pressure-nodes",
+		Usage: `
+			Nodes put under CPU or memory pressure by the resource-pressure
+			operations, e.g. '1-3' or '1,4'. A random node if unset.`,
+	})
+
+	PressureDuration time.Duration = 10 * time.Minute
+	_                              = registerRunOpsFlag(&PressureDuration, FlagInfo{
+		Name: "pressure-duration",
+		Usage: `
+			Maximum duration of the pressure applied by the resource-pressure
+			operations: it stops after it, even if the cleanup of the operation
+			doesn't run. Should exceed --wait-before-cleanup.`,
+	})
+
 	CockroachEAPath string
 	_               = registerRunFlag(&CockroachEAPath, FlagInfo{
 		Name: "cockroach-ea",
@@ -207,6 +346,80 @@ var (
 			--artifacts; defaults to the value of --artifacts if not provided`,
 	})
 
+	ArtifactsOnlyOnFailure bool
+	_                      = registerRunFlag(&ArtifactsOnlyOnFailure, FlagInfo{
+		Name: "artifacts-only-on-failure",
+		Usage: `
+			Discard the artifacts of passing tests, except for the test log and
+			the perf artifacts`,
+	})
+
+	ArtifactsMaxLogSizeMB int
+	_                     = registerRunFlag(&ArtifactsMaxLogSizeMB, FlagInfo{
+		Name: "artifacts-max-log-size",
+		Usage: `
+			Discard log files larger than this size (in MB) from the artifacts of
+			tests, except for the test log; 0 keeps all logs`,
+	})
+
+	ArtifactsCompress bool
+	_                 = registerRunFlag(&ArtifactsCompress, FlagInfo{
+		Name:  "artifacts-compress",
+		Usage: `Gzip the artifacts of tests (other than the perf artifacts)`,
+	})
+
+	DebugZipTimeout time.Duration = 5 * time.Minute
+	_                             = registerRunFlag(&DebugZipTimeout, FlagInfo{
+		Name:  "debug-zip-timeout",
+		Usage: `Timeout for collecting a debug zip from the cluster of a failed test`,
+	})
+
+	DebugZipMaxSizeMB int = 1024
+	_                     = registerRunFlag(&DebugZipMaxSizeMB, FlagInfo{
+		Name: "debug-zip-max-size",
+		Usage: `
+			Size (in MB) above which the debug zip of a failed test is not
+			downloaded from the cluster; 0 downloads debug zips of any size`,
+	})
+
+	ExportOpenMetrics bool
+	_                 = registerRunFlag(&ExportOpenMetrics, FlagInfo{
+		Name: "openmetrics",
+		Usage: `
+			Convert the histograms in the perf artifacts of benchmarks into the
+			OpenMetrics format (stats.om, next to stats.json); defaults to true
+			for the bench command`,
+	})
+
+	RoachperfUploadURL string
+	_                  = registerRunFlag(&RoachperfUploadURL, FlagInfo{
+		Name: "roachperf-upload",
+		Usage: `
+			GCS location (e.g. gs://cockroach-nightly/artifacts/20240101-1234) to
+			upload the perf artifacts of benchmarks to, with the same layout as
+			in the artifacts dir; requires gsutil`,
+	})
+
+	ArtifactsUploadURL string
+	_                  = registerRunFlag(&ArtifactsUploadURL, FlagInfo{
+		Name: "artifacts-upload",
+		Usage: `
+			GCS or S3 location (e.g. gs://bucket/artifacts/20240101-1234 or
+			s3://bucket/artifacts/20240101-1234) to upload the artifacts of each
+			test run to as soon as it completes, with the same layout as in the
+			artifacts dir; requires gsutil or the aws CLI respectively`,
+	})
+
+	ArtifactsRetention time.Duration = 30 * 24 * time.Hour
+	_                                = registerRunFlag(&ArtifactsRetention, FlagInfo{
+		Name: "artifacts-retention",
+		Usage: `
+			How long the artifacts uploaded with --artifacts-upload are to be
+			retained. The date until which they are retained is set in the
+			retain-until metadata of each uploaded file, for lifecycle rules of the
+			bucket to act on`,
+	})
+
 	ClusterID string
 	_         = registerRunFlag(&ClusterID, FlagInfo{
 		Name:  "cluster-id",
@@ -232,6 +445,47 @@ var (
 		Usage: `Never wipe and destroy the cluster`,
 	})
 
+	LeakedClusterCheckInterval time.Duration = 15 * time.Minute
+	_                                        = registerRunFlag(&LeakedClusterCheckInterval, FlagInfo{
+		Name: "leaked-cluster-check-interval",
+		Usage: `
+			Interval at which the clusters labeled with the ID of the run are
+			checked against the clusters in use by the run, to report those
+			which were leaked, i.e. should have been destroyed. The check also
+			runs once the run is done. 0 only runs that final check`,
+	})
+
+	UtilizationSampleInterval time.Duration = 30 * time.Second
+	_                                       = registerRunFlag(&UtilizationSampleInterval, FlagInfo{
+		Name: "utilization-sample-interval",
+		Usage: `
+			Interval at which the CPU, memory, disk and network utilization of
+			the CockroachDB nodes is sampled while a test runs, to report it
+			with the results of the test; 0 disables the sampling`,
+	})
+
+	DebugWithDelve bool
+	_              = registerRunFlag(&DebugWithDelve, FlagInfo{
+		Name: "debug-with-delve",
+		Usage: `
+			When the cluster of a failed test is kept by --debug or
+			--debug-always, install delve on its CockroachDB nodes, attach it
+			to the cockroach processes in headless mode and print the
+			instructions to connect to it`,
+	})
+
+	DebugMaxLifetime time.Duration
+	_                = registerRunFlag(&DebugMaxLifetime, FlagInfo{
+		Name: "debug-max-lifetime",
+		Usage: `
+			If set, the clusters kept by --debug or --debug-always are destroyed
+			once this long has passed since they were kept: their expiration is
+			set to that deadline when they are kept, and reminders are sent
+			about them until then. The runner doesn't wait for them: the
+			clusters still kept once the run is over are left to expire at their
+			deadline. 0 leaves them to expire`,
+	})
+
 	RunSkipped bool
 	_          = registerRunFlag(&RunSkipped, FlagInfo{
 		Name:  "run-skipped",
@@ -256,8 +510,10 @@ var (
 
 	Parallelism int = 10
 	_               = registerRunFlag(&Parallelism, FlagInfo{
-		Name:  "parallelism",
-		Usage: `Number of tests to run in parallel`,
+		Name: "parallelism",
+		Usage: `
+			Number of tests to run in parallel. If 0, tests are run in parallel
+			as long as there is enough CPU quota for them (see --cpu-quota)`,
 	})
 
 	deprecatedRoachprodBinary string
@@ -273,6 +529,38 @@ var (
 		Usage: `Wipe existing cluster before starting test (for use with --cluster)`,
 	})
 
+	ResumeStage string
+	_           = registerRunFlag(&ResumeStage, FlagInfo{
+		Name: "resume-stage",
+		Usage: `
+			The stage at which staged tests resume, instead of the first stage
+			which didn't complete according to the checkpoint of their cluster.
+			The stages before it must have completed, so this is only useful
+			along with --cluster and --wipe=false`,
+	})
+
+	StopAfterStage string
+	_              = registerRunFlag(&StopAfterStage, FlagInfo{
+		Name: "stop-after-stage",
+		Usage: `
+			The stage after which staged tests stop, leaving their cluster and
+			its checkpoint for a later run to resume at the next stage. Requires
+			--wipe=false, since the checkpoint is wiped along with the cluster;
+			resume with --cluster and --wipe=false`,
+	})
+
+	TeardownConcurrency int = 10
+	_                       = registerRunFlag(&TeardownConcurrency, FlagInfo{
+		Name: "teardown-concurrency",
+		Usage: `
+			Maximum number of clusters destroyed concurrently at the end of the
+			run, or when it is interrupted, including the clusters of
+			--cluster-pool. The nodes of each cluster are wiped or destroyed in
+			parallel, up to roachprod's maximum concurrency, so destroying many
+			clusters at once runs into the rate limits of the cloud APIs. 0 means
+			no limit`,
+	})
+
 	Zones string
 	_     = registerRunFlag(&Zones, FlagInfo{
 		Name: "zones",
@@ -299,32 +587,56 @@ var (
 
 	HTTPPort int = 0
 	_            = registerRunFlag(&HTTPPort, FlagInfo{
-		Name:  "port",
-		Usage: `The port on which to serve the HTTP interface`,
+		Name: "port",
+		Usage: `
+			The port on which to serve the HTTP interface. It also serves a JSON API
+			under /api/v1/, available from localhost only, to inspect the state of the
+			run and to cancel tests, extend their timeouts, or skip the remaining ones`,
 	})
 
-	DatadogSite string = "us5.datadoghq.com"
-	_                  = registerRunOpsFlag(&DatadogSite, FlagInfo{
+	// The Datadog flags are used by run-operation to emit events, and by both
+	// run-operation and run to submit metrics.
+	DatadogSite     string = "us5.datadoghq.com"
+	datadogSiteFlag        = FlagInfo{
 		Name:  "datadog-site",
 		Usage: `Datadog site to communicate with (e.g., us5.datadoghq.com).`,
-	})
-
-	DatadogAPIKey string = ""
-	_                    = registerRunOpsFlag(&DatadogAPIKey, FlagInfo{
-		Name:  "datadog-api-key",
-		Usage: `Datadog API key to emit telemetry data to Datadog.`,
-	})
-
-	DatadogApplicationKey string = ""
-	_                            = registerRunOpsFlag(&DatadogApplicationKey, FlagInfo{
-		Name:  "datadog-app-key",
-		Usage: `Datadog application key to read telemetry data from Datadog.`,
-	})
+	}
+	_ = registerRunOpsFlag(&DatadogSite, datadogSiteFlag)
+	_ = registerRunFlag(&DatadogSite, datadogSiteFlag)
+
+	DatadogAPIKey     string = ""
+	datadogAPIKeyFlag        = FlagInfo{
+		Name:   "datadog-api-key",
+		Usage:  `Datadog API key to emit telemetry data to Datadog.`,
+		Secret: true,
+	}
+	_ = registerRunOpsFlag(&DatadogAPIKey, datadogAPIKeyFlag)
+	_ = registerRunFlag(&DatadogAPIKey, datadogAPIKeyFlag)
+
+	DatadogApplicationKey     string = ""
+	datadogApplicationKeyFlag        = FlagInfo{
+		Name:   "datadog-app-key",
+		Usage:  `Datadog application key to read telemetry data from Datadog.`,
+		Secret: true,
+	}
+	_ = registerRunOpsFlag(&DatadogApplicationKey, datadogApplicationKeyFlag)
+	_ = registerRunFlag(&DatadogApplicationKey, datadogApplicationKeyFlag)
 
-	DatadogTags string = ""
-	_                  = registerRunOpsFlag(&DatadogTags, FlagInfo{
+	DatadogTags     string = ""
+	datadogTagsFlag        = FlagInfo{
 		Name:  "datadog-tags",
 		Usage: `A comma-separated list of tags to attach to telemetry data (e.g., key1:val1,key2:val2).`,
+	}
+	_ = registerRunOpsFlag(&DatadogTags, datadogTagsFlag)
+	_ = registerRunFlag(&DatadogTags, datadogTagsFlag)
+
+	OperationLogsURL string
+	_                = registerRunOpsFlag(&OperationLogsURL, FlagInfo{
+		Name: "operation-logs-url",
+		Usage: `
+			URL of the logs of a run of an operation, linked from its Datadog
+			events. {cluster}, {operation} and {run-id} are replaced by the
+			cluster name, the operation name and the run id of the operation.`,
 	})
 
 	SideEyeApiToken string = ""
@@ -334,6 +646,7 @@ var (
 						created clusters. If empty, the Side-Eye agents will not be started.
 						When set, app.side-eye.io can be used to monitor running clusters and also
 						timing out tests will get a snapshot before their clusters are destroyed.`,
+		Secret: true,
 	})
 
 	PreferLocalSSD bool = true
@@ -352,10 +665,51 @@ var (
 			stage <ver>. Example: 20.1.4=cockroach-20.1,20.2.0=cockroach-20.2.`,
 	})
 
+	ClusterSettings map[string]string
+	_               = registerRunFlag(&ClusterSettings, FlagInfo{
+		Name: "cluster-settings",
+		Usage: `
+			List of <cluster setting>=<value> applied to every cluster started
+			during the run, overriding the values set by the tests. Example:
+			kv.rangefeed.enabled=true,sql.stats.automatic_collection.enabled=false`,
+	})
+
+	NodeBinaryOverride map[string]string
+	_                  = registerRunFlag(&NodeBinaryOverride, FlagInfo{
+		Name: "node-binary-override",
+		Usage: `
+			List of <node>=<path to cockroach binary>. The binary is uploaded to the
+			given nodes instead of the cockroach binary of the test, including when
+			mixed-version tests upgrade to the current version. Example:
+			1=cockroach-patched,3=cockroach-patched.`,
+	})
+
+	FetchReleaseData bool
+	_                = registerRunFlag(&FetchReleaseData, FlagInfo{
+		Name: "fetch-release-data",
+		Usage: `
+			Fetch the latest release data at the start of the run, instead of using
+			the data embedded in the roachtest binary, so that mixed-version tests
+			upgrade from the latest releases and follow the current upgrade rules.`,
+	})
+
+	NotifyWebhooks map[string]string
+	_              = registerRunFlag(&NotifyWebhooks, FlagInfo{
+		Name: "notify-webhook",
+		Usage: `
+			List of <team>=<Slack-compatible webhook URL> to notify of the start of
+			the run, test failures, and the final summary. Failures are posted to
+			the webhook of the owning team, or to the 'default' one, which also
+			receives the messages about the run. Example:
+			default=https://hooks.slack.com/...,kv=https://hooks.slack.com/...`,
+		Secret: true,
+	})
+
 	SlackToken string
 	_          = registerRunFlag(&SlackToken, FlagInfo{
-		Name:  "slack-token",
-		Usage: `Slack bot token`,
+		Name:   "slack-token",
+		Usage:  `Slack bot token`,
+		Secret: true,
 	})
 
 	TeamCity bool
@@ -370,6 +724,25 @@ var (
 		Usage: `Add GitHub-specific markers to the output where possible, and optionally populate GITHUB_STEP_SUMMARY with a summary of all tests`,
 	})
 
+	CIReporter string
+	_          = registerRunFlag(&CIReporter, FlagInfo{
+		Name: "ci-reporter",
+		Usage: `
+			Format in which to report the progress of the run to CI: teamcity for
+			TeamCity service messages, github for GitHub Actions annotations and step
+			summary, or jsonl for one JSON object per event. Defaults to teamcity
+			with --teamcity, and to github with --github.`,
+	})
+
+	ReportFormat string
+	_            = registerRunFlag(&ReportFormat, FlagInfo{
+		Name: "report-format",
+		Usage: `
+			Comma-separated list of machine-readable report formats to write to the
+			artifacts directory once all tests have run; supported formats are
+			'junit' (report.xml) and 'json' (report.json)`,
+	})
+
 	DisableIssue bool
 	_            = registerRunFlag(&DisableIssue, FlagInfo{
 		Name:  "disable-issue",
@@ -384,12 +757,15 @@ var (
 			process`,
 	})
 
-	SelectProbability float64 = 1.0
-	_                         = registerRunFlag(&SelectProbability, FlagInfo{
+	SelectProbability = SelectProbabilities{Default: 1}
+	_                 = registerRunFlag(&SelectProbability, FlagInfo{
 		Name: "select-probability",
 		Usage: `
-			The probability of a matched test being selected to run. Note: this will
-			run at least one test per prefix.`,
+			The probability of a matched test being selected to run, optionally
+			overridden for the tests of given suites and owners, e.g.
+			"0.3,weekly=1,owner:kv=0.5". The override of the owner of a test
+			applies over those of its suites, of which the highest applies.
+			Note: this will run at least one test per prefix.`,
 	})
 
 	UseSpotVM = NeverUseSpot
@@ -400,12 +776,172 @@ var (
 			Note, this is merely a _hint_. The framework decides if a SpotVM should be used.`,
 	})
 
+	PreemptionRequeues int = 1
+	_                      = registerRunFlag(&PreemptionRequeues, FlagInfo{
+		Name: "preemption-requeues",
+		Usage: `
+			Number of times a test run whose spot VMs were preempted is requeued,
+			on on-demand VMs, instead of being reported as failed. Preemptions
+			are only detected on spot VMs, so a requeued run is never requeued
+			again and the default of 1 suffices; 0 disables requeuing.`,
+	})
+
+	TimeoutScale float64
+	_            = registerRunFlag(&TimeoutScale, FlagInfo{
+		Name: "timeout-scale",
+		Usage: `
+			Factor by which test timeouts are scaled; if not set, timeouts are
+			scaled depending on the cloud and CPU architecture (e.g. 2x for local
+			runs)`,
+	})
+
 	AutoKillThreshold float64 = 1.0
 	_                         = registerRunFlag(&AutoKillThreshold, FlagInfo{
 		Name:  "auto-kill-threshold",
 		Usage: `Percentage of failed tests before all remaining tests are automatically terminated.`,
 	})
 
+	Shard string
+	_     = registerRunFlag(&Shard, FlagInfo{
+		Name: "shard",
+		Usage: `
+			Run only a shard of the selected tests, in the form <index>/<count>
+			(1-based, e.g. 2/4), to split a run across several runner hosts`,
+	})
+
+	ShardStrategy string = "hash"
+	_                    = registerRunFlag(&ShardStrategy, FlagInfo{
+		Name: "shard-strategy",
+		Usage: `
+			How tests are assigned to shards: 'hash' (by hash of the test name) or
+			'range' (contiguous ranges of the sorted test names)`,
+	})
+
+	ShardManifest string
+	_             = registerRunFlag(&ShardManifest, FlagInfo{
+		Name: "shard-manifest",
+		Usage: `
+			Directory shared by all the runners of a sharded run (e.g. a network
+			file system mount), in which runners claim test runs before starting
+			them and record their results. Claimed test runs are not started by
+			other runners, so shards don't overlap and restarted runners skip
+			completed tests`,
+	})
+
+	ShardManifestTTL time.Duration = 15 * time.Minute
+	_                              = registerRunFlag(&ShardManifestTTL, FlagInfo{
+		Name: "shard-manifest-ttl",
+		Usage: `
+			Time after which a test run claimed in the --shard-manifest, and
+			neither completed nor heartbeated by its runner since, can be claimed
+			by another runner`,
+	})
+
+	ResumeFrom string
+	_          = registerRunFlag(&ResumeFrom, FlagInfo{
+		Name: "resume-from",
+		Usage: `
+			Artifacts directory of an interrupted run to resume. Tests whose runs
+			all passed in that run, as recorded in its checkpoint.json, are not run
+			again`,
+	})
+
+	TestHistory string
+	_           = registerRunFlag(&TestHistory, FlagInfo{
+		Name: "test-history",
+		Usage: `
+			Comma-separated list of artifacts directories (or glob patterns) of
+			previous runs. The failure rate of each test is computed from their
+			checkpoint.json files, and tests which failed more often, or which
+			were not part of these runs, are scheduled first`,
+	})
+
+	PreflightChecks bool = true
+	_                    = registerRunFlag(&PreflightChecks, FlagInfo{
+		Name: "preflight-checks",
+		Usage: `
+			Check that newly created clusters are healthy (nodes reachable,
+			clocks in sync, disks writable, no kernel errors) before running tests
+			on them. Unhealthy clusters are replaced, and the test is reported as
+			an infra flake if no healthy cluster could be created`,
+	})
+
+	ClusterPool bool
+	_           = registerRunFlag(&ClusterPool, FlagInfo{
+		Name: "cluster-pool",
+		Usage: `
+			Wipe the clusters of failed tests and pool them for reuse by other
+			compatible tests, instead of destroying them. Clusters tainted by the
+			failure (e.g. by a timeout or an infrastructure flake) are destroyed`,
+	})
+
+	RetriesPerFailure int = 0
+	_                     = registerRunFlag(&RetriesPerFailure, FlagInfo{
+		Name: "retries-per-failure",
+		Usage: `
+			Number of times a failed test is retried on a fresh cluster. A test
+			that passes on a retry is reported as flaky instead of failed`,
+	})
+
+	MaxTotalCost float64 = 0
+	_                    = registerRunFlag(&MaxTotalCost, FlagInfo{
+		Name: "max-total-cost",
+		Usage: `
+			Budget, in USD, for the estimated cloud cost of the run. Test runs
+			whose estimated cost would exceed the remaining budget are not
+			scheduled. The cost of a test run is estimated from its cluster spec
+			and its average duration (or timeout, if unknown). 0 means no limit`,
+	})
+
+	MaxRunDuration time.Duration
+	_              = registerRunFlag(&MaxRunDuration, FlagInfo{
+		Name: "max-run-duration",
+		Usage: `
+			Budget for the duration of the run. Test runs which are not expected to
+			finish within the remaining budget, based on their average duration (or
+			timeout, if unknown), are not started and are reported as not run;
+			running tests are left to finish. 0 means no limit`,
+	})
+
+	FailFast bool
+	_        = registerRunFlag(&FailFast, FlagInfo{
+		Name: "fail-fast",
+		Usage: `
+			Stop scheduling the remaining runs of a test, with --count, once one of
+			its runs failed. Runs which are retried or requeued, and infrastructure
+			flakes, don't count as failures. The skipped runs are reported as not
+			run`,
+	})
+
+	FailFastRun bool
+	_           = registerRunFlag(&FailFastRun, FlagInfo{
+		Name: "fail-fast-run",
+		Usage: `
+			Like --fail-fast, but stop scheduling the runs of all the tests once a
+			test failed. Running tests are left to finish`,
+	})
+
+	CheckCloudQuota bool
+	_               = registerRunFlag(&CheckCloudQuota, FlagInfo{
+		Name: "check-cloud-quota",
+		Usage: `
+			Before scheduling the tests, check the remaining CPU quota of the GCE
+			regions their clusters would be created in. Tests whose cluster needs
+			more CPUs than remain are moved to one of --cloud-quota-fallback-zones
+			with enough quota if their zones aren't pinned, and are reported as
+			infra-skipped otherwise. --cpu-quota is lowered to the remaining
+			quota if it's higher. Only applies to GCE clusters created by the
+			run`,
+	})
+
+	CloudQuotaFallbackZones string = "us-central1-b,us-west1-b"
+	_                              = registerRunFlag(&CloudQuotaFallbackZones, FlagInfo{
+		Name: "cloud-quota-fallback-zones",
+		Usage: `
+			Zones, in order of preference, to which --check-cloud-quota moves the
+			tests which exceed the quota of their default region`,
+	})
+
 	GlobalSeed int64 = randutil.NewPseudoSeed()
 	_                = registerRunFlag(&GlobalSeed, FlagInfo{
 		Name:  "global-seed",
@@ -499,6 +1035,10 @@ type FlagInfo struct {
 	// Deprecated is used only for deprecated flags; it is the message shown when
 	// the flag is used.
 	Deprecated string
+
+	// Secret is set for flags whose values (e.g. credentials) must not be
+	// recorded in the artifacts of a run.
+	Secret bool
 }
 
 // AddListFlags adds all flags registered for the list command to the given
@@ -513,6 +1053,18 @@ func AddRunFlags(cmdFlags *pflag.FlagSet) {
 	globalMan.AddFlagsToCommand(runCmdID, cmdFlags)
 }
 
+// ChangedRunFlags returns the values of the run flags which were passed on the
+// command line, by name; secret flags are omitted.
+func ChangedRunFlags() map[string]string {
+	return globalMan.ChangedValues(runCmdID)
+}
+
+// SetUnchangedRunFlags sets the run flags which were not passed on the command
+// line to the given values, by name.
+func SetUnchangedRunFlags(values map[string]string) error {
+	return globalMan.SetUnchanged(runCmdID, values)
+}
+
 // AddRunOpsFlags adds all flags registered for the run-operations command to
 // the given command flag set.
 func AddRunOpsFlags(cmdFlags *pflag.FlagSet) {
@@ -75,10 +75,16 @@ func (m *manager) AddFlagsToCommand(cmd cmdID, cmdFlags *pflag.FlagSet) {
 			cmdFlags.DurationVarP(p, f.Name, f.Shorthand, *p, usage)
 		case *string:
 			cmdFlags.StringVarP(p, f.Name, f.Shorthand, *p, usage)
+		case *[]string:
+			cmdFlags.StringSliceVarP(p, f.Name, f.Shorthand, *p, usage)
 		case *map[string]string:
 			cmdFlags.StringToStringVarP(p, f.Name, f.Shorthand, *p, usage)
 		case *spec.Cloud:
 			cmdFlags.VarP(&cloudValue{val: p}, f.Name, f.Shorthand, usage)
+		case *SelectProbabilities:
+			cmdFlags.VarP(&selectProbabilitiesValue{val: p}, f.Name, f.Shorthand, usage)
+		case *FilterExprList:
+			cmdFlags.VarP(&filterExprsValue{val: p}, f.Name, f.Shorthand, usage)
 		default:
 			panic(fmt.Sprintf("unsupported pointer type %T", p))
 		}
@@ -108,6 +114,59 @@ func (m *manager) Changed(valPtr interface{}) *FlagInfo {
 	return nil
 }
 
+// ChangedValues returns the values of the flags of the given command which were
+// passed on the command line, by name. Secret flags are omitted.
+func (m *manager) ChangedValues(cmd cmdID) map[string]string {
+	values := make(map[string]string)
+	for _, f := range m.flags[cmd] {
+		if f.Secret {
+			continue
+		}
+		for _, flagSet := range f.flagSets {
+			if flagSet.Changed(f.Name) {
+				values[f.Name] = flagValueString(flagSet.Lookup(f.Name).Value)
+				break
+			}
+		}
+	}
+	return values
+}
+
+// SetUnchanged sets the flags of the given command which were not passed on
+// the command line to the given values, by name. Values of flags that don't
+// exist are ignored.
+func (m *manager) SetUnchanged(cmd cmdID, values map[string]string) error {
+	for _, f := range m.flags[cmd] {
+		val, ok := values[f.Name]
+		if !ok || len(f.flagSets) == 0 {
+			continue
+		}
+		changed := false
+		for _, flagSet := range f.flagSets {
+			changed = changed || flagSet.Changed(f.Name)
+		}
+		if changed {
+			continue
+		}
+		if err := f.flagSets[0].Set(f.Name, val); err != nil {
+			return errors.Wrapf(err, "setting --%s", f.Name)
+		}
+	}
+	return nil
+}
+
+// flagValueString returns the string representation of a flag's value which
+// can be passed back to Set.
+func flagValueString(v pflag.Value) string {
+	s := v.String()
+	if t := v.Type(); t == "stringToString" || t == "stringSlice" {
+		// Maps and slices are printed as "[k1=v1,k2=v2]" and "[a,b]", but parsed
+		// without the brackets.
+		s = strings.TrimSuffix(strings.TrimPrefix(s, "["), "]")
+	}
+	return s
+}
+
 // cleanupString converts a multi-line string into a single-line string,
 // removing all extra whitespace at the beginning and end of lines.
 func cleanupString(s string) string {
@@ -104,6 +104,51 @@ func TestManager(t *testing.T) {
 	})
 }
 
+func TestChangedValues(t *testing.T) {
+	m, tv := initTest()
+	var secret string
+	var mapVal map[string]string
+	var sliceVal []string
+	var exprs FilterExprList
+	m.RegisterFlag(runCmdID, &secret, FlagInfo{Name: "some-secret", Secret: true})
+	m.RegisterFlag(runCmdID, &mapVal, FlagInfo{Name: "some-map"})
+	m.RegisterFlag(runCmdID, &sliceVal, FlagInfo{Name: "some-slice"})
+	m.RegisterFlag(runCmdID, &exprs, FlagInfo{Name: "some-filter"})
+	runCmd := &cobra.Command{}
+	m.AddFlagsToCommand(runCmdID, runCmd.Flags())
+	require.NoError(t, runCmd.ParseFlags([]string{
+		"-s", "foo", "--some-secret", "hunter2", "--some-map", "a=1",
+		"--some-slice", "a,b", "--some-slice", "c",
+		"--some-filter", "suite:nightly && owner:kv", "--some-filter", `name:"a,b"`,
+	}))
+	require.Equal(t, []string{"a", "b", "c"}, sliceVal)
+	require.Equal(t, FilterExprList{"suite:nightly && owner:kv", `name:"a,b"`}, exprs)
+	values := m.ChangedValues(runCmdID)
+	require.Equal(t, map[string]string{
+		"some-string": "foo",
+		"some-map":    "a=1",
+		"some-slice":  "a,b,c",
+		"some-filter": `(suite:nightly && owner:kv) || (name:"a,b")`,
+	}, values)
+
+	// The recorded values are applied to another invocation, except for the
+	// flags passed on its command line.
+	m, tv = initTest()
+	m.RegisterFlag(runCmdID, &mapVal, FlagInfo{Name: "some-map"})
+	m.RegisterFlag(runCmdID, &sliceVal, FlagInfo{Name: "some-slice"})
+	m.RegisterFlag(runCmdID, &exprs, FlagInfo{Name: "some-filter"})
+	mapVal, sliceVal, exprs = nil, nil, nil
+	rerunCmd := &cobra.Command{}
+	m.AddFlagsToCommand(runCmdID, rerunCmd.Flags())
+	require.NoError(t, rerunCmd.ParseFlags([]string{"-s", "bar"}))
+	require.NoError(t, m.SetUnchanged(runCmdID, values))
+	require.Equal(t, "bar", tv.stringVal)
+	require.Equal(t, map[string]string{"a": "1"}, mapVal)
+	require.Equal(t, []string{"a", "b", "c"}, sliceVal)
+	require.Equal(t, FilterExprList{`(suite:nightly && owner:kv) || (name:"a,b")`}, exprs)
+	require.NotNil(t, m.Changed(&mapVal))
+}
+
 func TestCleanupString(t *testing.T) {
 	in := `
   this is
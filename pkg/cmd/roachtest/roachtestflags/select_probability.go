@@ -0,0 +1,144 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package roachtestflags
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/spf13/pflag"
+)
+
+// selectProbabilityOwnerPrefix is the prefix of the keys of the
+// --select-probability entries which apply to the tests of an owner, rather
+// than of a suite.
+const selectProbabilityOwnerPrefix = "owner:"
+
+// SelectProbabilities are the probabilities with which the tests matched by a
+// run are selected to run. See --select-probability.
+type SelectProbabilities struct {
+	// Default is the probability of the tests which none of the overrides
+	// below apply to.
+	Default float64
+	// Suites and Owners override the probability of the tests of the given
+	// suites and owners.
+	Suites map[string]float64
+	Owners map[string]float64
+}
+
+// ParseSelectProbabilities parses the value of --select-probability: a
+// comma-separated list of a default probability, and of <suite>=<probability>
+// and owner:<owner>=<probability> overrides, e.g. "0.5,weekly=1,owner:kv=0.8".
+// The default probability is 1 if it's not given.
+func ParseSelectProbabilities(s string) (SelectProbabilities, error) {
+	p := SelectProbabilities{Default: 1}
+	parse := func(s string) (float64, error) {
+		v, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, errors.Wrapf(err, "invalid select probability %q", s)
+		}
+		if v < 0 || v > 1 {
+			return 0, errors.Newf("select probability %s must be in [0,1]", s)
+		}
+		return v, nil
+	}
+	var hasDefault bool
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, val, isOverride := strings.Cut(entry, "=")
+		if !isOverride {
+			if hasDefault {
+				return SelectProbabilities{}, errors.Newf("multiple default select probabilities in %q", s)
+			}
+			v, err := parse(entry)
+			if err != nil {
+				return SelectProbabilities{}, err
+			}
+			p.Default, hasDefault = v, true
+			continue
+		}
+		v, err := parse(val)
+		if err != nil {
+			return SelectProbabilities{}, err
+		}
+		overrides := &p.Suites
+		if owner, ok := strings.CutPrefix(key, selectProbabilityOwnerPrefix); ok {
+			overrides, key = &p.Owners, owner
+		}
+		if key == "" {
+			return SelectProbabilities{}, errors.Newf("invalid select probability entry %q", entry)
+		}
+		if *overrides == nil {
+			*overrides = make(map[string]float64)
+		}
+		(*overrides)[key] = v
+	}
+	return p, nil
+}
+
+// IsOne returns whether all the tests are selected.
+func (p SelectProbabilities) IsOne() bool {
+	if p.Default != 1 {
+		return false
+	}
+	for _, v := range p.Suites {
+		if v != 1 {
+			return false
+		}
+	}
+	for _, v := range p.Owners {
+		if v != 1 {
+			return false
+		}
+	}
+	return true
+}
+
+func (p SelectProbabilities) String() string {
+	entries := []string{strconv.FormatFloat(p.Default, 'g', -1, 64)}
+	var overrides []string
+	for k, v := range p.Suites {
+		overrides = append(overrides, k+"="+strconv.FormatFloat(v, 'g', -1, 64))
+	}
+	for k, v := range p.Owners {
+		overrides = append(overrides, selectProbabilityOwnerPrefix+k+"="+strconv.FormatFloat(v, 'g', -1, 64))
+	}
+	sort.Strings(overrides)
+	return strings.Join(append(entries, overrides...), ",")
+}
+
+type selectProbabilitiesValue struct {
+	val *SelectProbabilities
+}
+
+var _ pflag.Value = (*selectProbabilitiesValue)(nil)
+
+func (v *selectProbabilitiesValue) String() string {
+	return v.val.String()
+}
+
+func (v *selectProbabilitiesValue) Type() string {
+	return "string"
+}
+
+func (v *selectProbabilitiesValue) Set(str string) error {
+	p, err := ParseSelectProbabilities(str)
+	if err != nil {
+		return err
+	}
+	*v.val = p
+	return nil
+}
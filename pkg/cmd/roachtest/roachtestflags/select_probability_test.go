@@ -0,0 +1,42 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package roachtestflags
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSelectProbabilities(t *testing.T) {
+	p, err := ParseSelectProbabilities("0.5")
+	require.NoError(t, err)
+	require.Equal(t, SelectProbabilities{Default: 0.5}, p)
+	require.False(t, p.IsOne())
+
+	p, err = ParseSelectProbabilities("nightly=0.3, weekly=1,owner:kv=0.8")
+	require.NoError(t, err)
+	require.Equal(t, SelectProbabilities{
+		Default: 1,
+		Suites:  map[string]float64{"nightly": 0.3, "weekly": 1},
+		Owners:  map[string]float64{"kv": 0.8},
+	}, p)
+	require.Equal(t, "1,nightly=0.3,owner:kv=0.8,weekly=1", p.String())
+
+	p, err = ParseSelectProbabilities("1,weekly=1")
+	require.NoError(t, err)
+	require.True(t, p.IsOne())
+
+	for _, s := range []string{"2", "0.5,0.6", "nightly=x", "=0.5", "owner:=0.5", "weekly=-1"} {
+		_, err := ParseSelectProbabilities(s)
+		require.Error(t, err, s)
+	}
+}
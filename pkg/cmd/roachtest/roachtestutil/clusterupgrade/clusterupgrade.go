@@ -24,6 +24,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/build"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/cluster"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/option"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/roachtestutil"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/test"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/roachprod/install"
@@ -237,6 +238,13 @@ func uploadBinaryVersion(
 		if err := c.PutE(ctx, l, defaultBinary, dstBinary, nodes); err != nil {
 			return "", err
 		}
+		if binary == "cockroach" && v.IsCurrent() {
+			if err := roachtestutil.PutNodeBinaryOverrides(
+				ctx, l, c, t.NodeBinaryOverride(), dstBinary, nodes,
+			); err != nil {
+				return "", err
+			}
+		}
 	} else {
 		dir := filepath.Dir(dstBinary)
 		// Avoid staging the binary if it already exists.
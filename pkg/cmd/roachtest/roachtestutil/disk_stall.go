@@ -43,16 +43,37 @@ type cgroupDiskStaller struct {
 	c           cluster.Cluster
 	readOrWrite []bandwidthReadWrite
 	logsToo     bool
+	// bytesPerSecond is the throughput the disk is limited to when stalled.
+	bytesPerSecond int
 }
 
 var _ DiskStaller = (*cgroupDiskStaller)(nil)
 
 func MakeCgroupDiskStaller(f Fataler, c cluster.Cluster, readsToo bool, logsToo bool) DiskStaller {
+	// NB: I don't understand why, but attempting to set a
+	// bytesPerSecond={0,1} results in Invalid argument from the io.max
+	// cgroupv2 API.
+	return makeCgroupDiskStaller(f, c, readsToo, logsToo, 4 /* bytesPerSecond */)
+}
+
+// MakeCgroupDiskThrottler returns a DiskStaller which, rather than stalling
+// the disk, slows it down by limiting its throughput to the given rate.
+func MakeCgroupDiskThrottler(
+	f Fataler, c cluster.Cluster, readsToo bool, bytesPerSecond int,
+) DiskStaller {
+	return makeCgroupDiskStaller(f, c, readsToo, false /* logsToo */, bytesPerSecond)
+}
+
+func makeCgroupDiskStaller(
+	f Fataler, c cluster.Cluster, readsToo bool, logsToo bool, bytesPerSecond int,
+) *cgroupDiskStaller {
 	bwRW := []bandwidthReadWrite{writeBandwidth}
 	if readsToo {
 		bwRW = append(bwRW, readBandwidth)
 	}
-	return &cgroupDiskStaller{f: f, c: c, readOrWrite: bwRW, logsToo: logsToo}
+	return &cgroupDiskStaller{
+		f: f, c: c, readOrWrite: bwRW, logsToo: logsToo, bytesPerSecond: bytesPerSecond,
+	}
 }
 
 func (s *cgroupDiskStaller) DataDir() string { return "{store-dir}" }
@@ -73,10 +94,7 @@ func (s *cgroupDiskStaller) Stall(ctx context.Context, nodes option.NodeListOpti
 		s.readOrWrite[i], s.readOrWrite[j] = s.readOrWrite[j], s.readOrWrite[i]
 	})
 	for _, rw := range s.readOrWrite {
-		// NB: I don't understand why, but attempting to set a
-		// bytesPerSecond={0,1} results in Invalid argument from the io.max
-		// cgroupv2 API.
-		if err := s.setThroughput(ctx, nodes, rw, throughput{limited: true, bytesPerSecond: 4}); err != nil {
+		if err := s.setThroughput(ctx, nodes, rw, throughput{limited: true, bytesPerSecond: s.bytesPerSecond}); err != nil {
 			s.f.Fatal(err)
 		}
 	}
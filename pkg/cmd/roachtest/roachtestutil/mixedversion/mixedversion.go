@@ -66,9 +66,11 @@
 // output.
 //
 // The random seed used to generate a test plan is logged; reusing the
-// same seed will lead to the exact same test plan being generated. To
-// set a specific seed when running a mixed-version test, one can set
-// the `COCKROACH_RANDOM_SEED` environment variable.
+// same seed will lead to the exact same test plan being generated. By
+// default, the seed is the test's seed (see `test.Test.Seed`), so
+// re-running a test with the same `--global-seed` generates the same
+// plan. To set a specific seed when running a mixed-version test, one
+// can set the `COCKROACH_RANDOM_SEED` environment variable.
 package mixedversion
 
 import (
@@ -90,6 +92,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/roachprod/logger"
 	"github.com/cockroachdb/cockroach/pkg/roachprod/vm"
 	"github.com/cockroachdb/cockroach/pkg/testutils/release"
+	"github.com/cockroachdb/cockroach/pkg/util/envutil"
 	"github.com/cockroachdb/cockroach/pkg/util/randutil"
 	"github.com/cockroachdb/errors"
 )
@@ -468,11 +471,6 @@ func DisableMutators(names ...string) CustomOption {
 	}
 }
 
-// minSupportedSkipVersionUpgrade is the minimum version after which
-// "skip version" upgrades are supported (i.e., upgrading two major
-// releases in a single upgrade).
-var minSupportedSkipVersionUpgrade = clusterupgrade.MustParseVersion("v24.1.0")
-
 func defaultTestOptions() testOptions {
 	return testOptions{
 		// We use fixtures more often than not as they are more likely to
@@ -526,7 +524,8 @@ func NewTest(
 		fn(&opts)
 	}
 
-	prng, seed := randutil.NewLockedPseudoRand()
+	seed := envutil.EnvOrDefaultInt64("COCKROACH_RANDOM_SEED", t.Seed())
+	prng := rand.New(randutil.NewLockedSource(seed))
 	testCtx, cancel := context.WithCancel(ctx)
 
 	test := &Test{
@@ -733,6 +732,13 @@ func (t *Test) clusterArch() vm.CPUArch {
 		return *t._arch // test-only
 	}
 
+	// Releases must be available for every node of a mixed-architecture
+	// cluster, so it is treated as an ARM64 cluster if any node uses ARM64.
+	for _, n := range t.crdbNodes {
+		if t.cluster.NodeArchitecture(n) == vm.ArchARM64 {
+			return vm.ArchARM64
+		}
+	}
 	return t.cluster.Architecture()
 }
 
@@ -772,9 +778,9 @@ func (t *Test) choosePreviousReleases() ([]*clusterupgrade.Version, error) {
 	// possiblePredecessorsFor returns a list of possible predecessors
 	// for the given release `v`. If skip-version is enabled and
 	// supported, this function will return both the immediate
-	// predecessor along with the predecessor's predecessor. This is the
-	// function to change in case the rules around what upgrades are
-	// possible in CRDB change.
+	// predecessor along with the predecessor's predecessor. The rules
+	// around what upgrades are possible in CRDB are encoded in the
+	// `release` package, along with the release data.
 	possiblePredecessorsFor := func(v *clusterupgrade.Version) ([]*clusterupgrade.Version, error) {
 		pred, err := t.predecessorFunc(t.prng, v, t.options.minimumSupportedVersion)
 		if err != nil {
@@ -789,7 +795,7 @@ func (t *Test) choosePreviousReleases() ([]*clusterupgrade.Version, error) {
 		// predecessor is the immediate predecessor release. If the
 		// predecessor doesn't support skip versions, then its predecessor
 		// won't either. Don't attempt to find it.
-		if !skipVersions || !pred.AtLeast(minSupportedSkipVersionUpgrade) {
+		if !skipVersions || !release.SupportsSkipVersionUpgrade(&pred.Version) {
 			return []*clusterupgrade.Version{pred}, nil
 		}
 
@@ -798,7 +804,12 @@ func (t *Test) choosePreviousReleases() ([]*clusterupgrade.Version, error) {
 			return nil, err
 		}
 
-		if predPred.AtLeast(minSupportedSkipVersionUpgrade) {
+		sources, err := release.UpgradeSources(&v.Version)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(sources) > 1 {
 			// If the predecessor's predecessor supports skip-version
 			// upgrades and we haven't performed a skip-version upgrade yet,
 			// do it. This logic makes sure that, when skip-version upgrades
@@ -0,0 +1,227 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package roachtestutil
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/cluster"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/option"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/test"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+	"github.com/cockroachdb/cockroach/pkg/workload/histogram"
+	"github.com/cockroachdb/errors"
+	"github.com/codahale/hdrhistogram"
+)
+
+// PerfMetricsSchemaVersion is the version of the schema of PerfMetrics. It is
+// incremented on changes which aren't backwards compatible, so that the tools
+// reading the perf artifacts of past runs can tell the schemas apart.
+const PerfMetricsSchemaVersion = 1
+
+// PerfMetricsFile is the name of the perf artifacts file in which tests write
+// their PerfMetrics.
+const PerfMetricsFile = "metrics.json"
+
+// PerfUnit is the unit of the values of a PerfMetric.
+type PerfUnit string
+
+const (
+	PerfUnitSeconds       PerfUnit = "seconds"
+	PerfUnitBytes         PerfUnit = "bytes"
+	PerfUnitBytesPerSec   PerfUnit = "bytes/s"
+	PerfUnitOpsPerSec     PerfUnit = "ops/s"
+	PerfUnitCount         PerfUnit = "count"
+	PerfUnitRatio         PerfUnit = "ratio"
+	PerfUnitDimensionless PerfUnit = ""
+)
+
+// PerfMetrics are the performance metrics of a test run, in a format shared
+// by all tests, which is what tools should consume rather than the formats of
+// the individual tests.
+type PerfMetrics struct {
+	SchemaVersion int          `json:"schema_version"`
+	Metrics       []PerfMetric `json:"metrics"`
+}
+
+// PerfMetric is a measurement made by a test. Exactly one of Value and
+// Histogram is set.
+type PerfMetric struct {
+	// Name is the name of the metric, in snake_case, e.g. "op_latency".
+	Name string   `json:"name"`
+	Unit PerfUnit `json:"unit"`
+	// Labels tell apart the metrics of the same name, e.g. by operation.
+	Labels    map[string]string `json:"labels,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
+	// Value is the value of a scalar metric.
+	Value *float64 `json:"value,omitempty"`
+	// Histogram is the distribution of the values of the metric.
+	Histogram *PerfHistogram `json:"histogram,omitempty"`
+}
+
+// PerfHistogram is a distribution of values, with fixed buckets so that the
+// histograms of different runs can be compared.
+type PerfHistogram struct {
+	Count int64   `json:"count"`
+	Sum   float64 `json:"sum"`
+	// Buckets are the cumulative counts of the values at most their upper
+	// bound, in increasing order of upper bound. The counts of the values
+	// greater than the last bound are only included in Count.
+	Buckets []PerfBucket `json:"buckets"`
+}
+
+// PerfBucket is a bucket of a PerfHistogram.
+type PerfBucket struct {
+	UpperBound float64 `json:"le"`
+	Count      int64   `json:"count"`
+}
+
+// PerfLatencyBuckets are the upper bounds, in seconds, of the buckets of the
+// latency histograms: powers of two from 0.5ms to ~4.4min.
+var PerfLatencyBuckets = func() []float64 {
+	bounds := make([]float64, 20)
+	for i := range bounds {
+		bounds[i] = 0.0005 * float64(int64(1)<<i)
+	}
+	return bounds
+}()
+
+// NewPerfMetrics returns an empty set of metrics of the current schema.
+func NewPerfMetrics() *PerfMetrics {
+	return &PerfMetrics{SchemaVersion: PerfMetricsSchemaVersion}
+}
+
+// AddValue adds a scalar metric.
+func (m *PerfMetrics) AddValue(
+	name string, unit PerfUnit, value float64, labels map[string]string,
+) {
+	m.Metrics = append(m.Metrics, PerfMetric{
+		Name:      name,
+		Unit:      unit,
+		Labels:    labels,
+		Timestamp: timeutil.Now().UTC(),
+		Value:     &value,
+	})
+}
+
+// AddLatencies adds the distribution of the latencies recorded in the given
+// histogram, of values in nanoseconds, as a metric in seconds with
+// PerfLatencyBuckets.
+func (m *PerfMetrics) AddLatencies(
+	name string, h *hdrhistogram.Histogram, labels map[string]string, timestamp time.Time,
+) {
+	m.Metrics = append(m.Metrics, PerfMetric{
+		Name:      name,
+		Unit:      PerfUnitSeconds,
+		Labels:    labels,
+		Timestamp: timestamp.UTC(),
+		Histogram: latencyHistogram(h),
+	})
+}
+
+// latencyHistogram returns the PerfHistogram, in seconds, of the given
+// histogram of values in nanoseconds.
+func latencyHistogram(h *hdrhistogram.Histogram) *PerfHistogram {
+	count := h.TotalCount()
+	ph := &PerfHistogram{
+		Count:   count,
+		Sum:     h.Mean() * float64(count) / float64(time.Second),
+		Buckets: make([]PerfBucket, len(PerfLatencyBuckets)),
+	}
+	for i, bound := range PerfLatencyBuckets {
+		ph.Buckets[i].UpperBound = bound
+	}
+	for _, bar := range h.Distribution() {
+		if bar.Count == 0 {
+			continue
+		}
+		// All the values of a bar are in the first bucket whose bound is at
+		// least the upper end of the bar.
+		to := float64(bar.To) / float64(time.Second)
+		i := sort.SearchFloat64s(PerfLatencyBuckets, to)
+		for ; i < len(ph.Buckets); i++ {
+			ph.Buckets[i].Count += bar.Count
+		}
+	}
+	return ph
+}
+
+// Marshal returns the JSON encoding of the metrics.
+func (m *PerfMetrics) Marshal() ([]byte, error) {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
+
+// Write writes the metrics to PerfMetricsFile in the perf artifacts directory
+// of the given node, from which the test runner collects them.
+func (m *PerfMetrics) Write(
+	ctx context.Context, t test.Test, c cluster.Cluster, node option.NodeListOption,
+) error {
+	data, err := m.Marshal()
+	if err != nil {
+		return err
+	}
+	dest := filepath.Join(t.PerfArtifactsDir(), PerfMetricsFile)
+	if err := c.RunE(ctx, option.WithNodes(node), "mkdir -p "+t.PerfArtifactsDir()); err != nil {
+		return errors.Wrap(err, "creating the perf artifacts directory")
+	}
+	return errors.Wrap(c.PutString(ctx, string(data), dest, 0644, node), "writing the perf metrics")
+}
+
+// ConvertHistogramSnapshots converts the histograms written by workloads (see
+// histogram.DecodeSnapshots) into PerfMetrics: the ticks of each histogram are
+// merged into an "op_latency" metric labeled with op=<histogram name> and the
+// given labels, timestamped with the last tick.
+func ConvertHistogramSnapshots(
+	snapshots map[string][]histogram.SnapshotTick, labels map[string]string,
+) *PerfMetrics {
+	names := make([]string, 0, len(snapshots))
+	for name := range snapshots {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	m := NewPerfMetrics()
+	for _, name := range names {
+		var merged *hdrhistogram.Histogram
+		var last time.Time
+		for _, tick := range snapshots[name] {
+			if tick.Hist == nil {
+				continue
+			}
+			h := hdrhistogram.Import(tick.Hist)
+			if merged == nil {
+				merged = h
+			} else {
+				merged.Merge(h)
+			}
+			if tick.Now.After(last) {
+				last = tick.Now
+			}
+		}
+		if merged == nil {
+			continue
+		}
+		metricLabels := map[string]string{"op": name}
+		for k, v := range labels {
+			metricLabels[k] = v
+		}
+		m.AddLatencies("op_latency", merged, metricLabels, last)
+	}
+	return m
+}
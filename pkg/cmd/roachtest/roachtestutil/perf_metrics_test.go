@@ -0,0 +1,81 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package roachtestutil
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/workload/histogram"
+	"github.com/codahale/hdrhistogram"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvertHistogramSnapshots(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tick := func(name string, i int, latencies ...time.Duration) histogram.SnapshotTick {
+		h := hdrhistogram.New(time.Microsecond.Nanoseconds(), time.Minute.Nanoseconds(), 2)
+		for _, l := range latencies {
+			require.NoError(t, h.RecordValue(l.Nanoseconds()))
+		}
+		return histogram.SnapshotTick{Name: name, Hist: h.Export(), Now: now.Add(time.Duration(i) * time.Second)}
+	}
+	snapshots := map[string][]histogram.SnapshotTick{
+		"write": {tick("write", 1, 10*time.Millisecond)},
+		"read": {
+			tick("read", 2, time.Millisecond, 100*time.Millisecond),
+			tick("read", 1, 3*time.Millisecond),
+		},
+		"empty": {{Name: "empty", Now: now}},
+	}
+
+	m := ConvertHistogramSnapshots(snapshots, map[string]string{"test": "kv95"})
+	require.Equal(t, PerfMetricsSchemaVersion, m.SchemaVersion)
+	require.Len(t, m.Metrics, 2)
+
+	read := m.Metrics[0]
+	require.Equal(t, "op_latency", read.Name)
+	require.Equal(t, PerfUnitSeconds, read.Unit)
+	require.Equal(t, map[string]string{"op": "read", "test": "kv95"}, read.Labels)
+	require.Equal(t, now.Add(2*time.Second), read.Timestamp)
+	require.Nil(t, read.Value)
+	require.Equal(t, int64(3), read.Histogram.Count)
+	require.InEpsilon(t, 0.104, read.Histogram.Sum, 0.01)
+	require.Len(t, read.Histogram.Buckets, len(PerfLatencyBuckets))
+	countAt := func(h *PerfHistogram, bound float64) int64 {
+		for _, b := range h.Buckets {
+			if b.UpperBound >= bound {
+				return b.Count
+			}
+		}
+		return h.Count
+	}
+	require.Equal(t, int64(0), countAt(read.Histogram, 0.0005))
+	require.Equal(t, int64(1), countAt(read.Histogram, 0.002))
+	require.Equal(t, int64(2), countAt(read.Histogram, 0.004))
+	require.Equal(t, int64(2), countAt(read.Histogram, 0.064))
+	require.Equal(t, int64(3), countAt(read.Histogram, 0.128))
+
+	write := m.Metrics[1]
+	require.Equal(t, map[string]string{"op": "write", "test": "kv95"}, write.Labels)
+	require.Equal(t, int64(1), write.Histogram.Count)
+
+	// The metrics round-trip through their JSON encoding.
+	m.AddValue("throughput", PerfUnitOpsPerSec, 1200, nil)
+	data, err := m.Marshal()
+	require.NoError(t, err)
+	var decoded PerfMetrics
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	require.Equal(t, m.Metrics[:2], decoded.Metrics[:2])
+	require.Equal(t, 1200.0, *decoded.Metrics[2].Value)
+	require.Nil(t, decoded.Metrics[2].Histogram)
+}
@@ -0,0 +1,131 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package roachtestutil
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/cluster"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/option"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/test"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/vm"
+	"github.com/cockroachdb/cockroach/pkg/util/version"
+)
+
+// SnapshotFixture is a dataset which is expensive to load into a cluster. The
+// first time it is loaded, the volumes of the cluster are captured in
+// snapshots, which later runs restore instead of loading the dataset again.
+//
+// The snapshots are named by roachprod as
+// <Prefix>-<cockroach version>-n<node count>-<node>, and are only restored on
+// clusters with the same number of nodes, by a cockroach binary of the same
+// release series: they are taken again for each release.
+type SnapshotFixture struct {
+	// Prefix identifies the snapshots of the dataset. It must be short enough
+	// for the snapshot names to fit in 63 characters, and must not be a prefix
+	// of the prefix of another fixture followed by a version.
+	Prefix string
+	// Load starts cockroach on the cluster and loads the dataset.
+	Load func(ctx context.Context, t test.Test, c cluster.Cluster)
+}
+
+// snapshotSuffixRE matches what follows the prefix in the name of a snapshot
+// of a fixture: the version of cockroach, in which dots are replaced by
+// dashes, the number of nodes of the cluster and the node of the snapshot.
+var snapshotSuffixRE = regexp.MustCompile(`^(v[0-9][a-z0-9-]*)-n([0-9]+)-([0-9]{4})$`)
+
+// Setup makes the dataset of the fixture available on the cluster: it restores
+// the snapshots of the fixture if there are any for the cluster, and loads the
+// dataset and snapshots the cluster otherwise. Snapshots are only used on
+// remote clusters; the dataset is always loaded on local ones. Cockroach is
+// stopped on all nodes when Setup returns. Returns whether the dataset was
+// restored from snapshots.
+func (f SnapshotFixture) Setup(ctx context.Context, t test.Test, c cluster.Cluster) bool {
+	if c.IsLocal() {
+		f.load(ctx, t, c)
+		return false
+	}
+
+	snapshots, err := c.ListSnapshots(ctx, vm.VolumeSnapshotListOpts{NamePrefix: f.Prefix + "-"})
+	if err != nil {
+		// Snapshots only make the setup faster, so we can do without them.
+		t.L().Printf("failed to list the snapshots of fixture %s, loading it: %v", f.Prefix, err)
+	}
+	if found := findFixtureSnapshots(snapshots, f.Prefix, c.Spec().NodeCount, t.BuildVersion()); found != nil {
+		t.L().Printf("restoring fixture %s from %d snapshots", f.Prefix, len(found))
+		c.Stop(ctx, t.L(), option.DefaultStopOpts())
+		if err := c.ApplySnapshots(ctx, found); err != nil {
+			t.Fatal(err)
+		}
+		return true
+	}
+
+	t.L().Printf("no snapshots found for fixture %s, loading it", f.Prefix)
+	f.load(ctx, t, c)
+	created, err := c.CreateSnapshot(ctx, f.Prefix)
+	if err != nil {
+		t.L().Printf("failed to snapshot fixture %s: %v", f.Prefix, err)
+		return false
+	}
+	t.L().Printf("created %d snapshots of fixture %s", len(created), f.Prefix)
+	return false
+}
+
+// load loads the dataset, and stops cockroach so that the volumes can be
+// snapshotted.
+func (f SnapshotFixture) load(ctx context.Context, t test.Test, c cluster.Cluster) {
+	f.Load(ctx, t, c)
+	c.Stop(ctx, t.L(), option.DefaultStopOpts())
+}
+
+// findFixtureSnapshots returns, sorted by node, the snapshots of the fixture
+// with the given prefix taken by a binary of the release series of v on a
+// cluster with the given number of nodes, or nil if there aren't any. If there
+// are complete sets of snapshots from several versions in that series, the
+// set whose version sorts last is returned.
+func findFixtureSnapshots(
+	snapshots []vm.VolumeSnapshot, prefix string, nodeCount int, v *version.Version,
+) []vm.VolumeSnapshot {
+	series := fmt.Sprintf("v%d-%d-", v.Major(), v.Minor())
+	byVersion := make(map[string][]vm.VolumeSnapshot)
+	for _, s := range snapshots {
+		suffix, ok := strings.CutPrefix(s.Name, prefix+"-")
+		if !ok {
+			continue
+		}
+		m := snapshotSuffixRE.FindStringSubmatch(suffix)
+		if m == nil || !strings.HasPrefix(m[1], series) || m[2] != strconv.Itoa(nodeCount) {
+			continue
+		}
+		byVersion[m[1]] = append(byVersion[m[1]], s)
+	}
+
+	var versions []string
+	for snapshotVersion, s := range byVersion {
+		// A partial set of snapshots, e.g. because the creation of some of them
+		// failed, can't be restored.
+		if len(s) == nodeCount {
+			versions = append(versions, snapshotVersion)
+		}
+	}
+	if len(versions) == 0 {
+		return nil
+	}
+	sort.Strings(versions)
+	found := byVersion[versions[len(versions)-1]]
+	sort.Sort(vm.VolumeSnapshots(found))
+	return found
+}
@@ -0,0 +1,52 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package roachtestutil
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/roachprod/vm"
+	"github.com/cockroachdb/cockroach/pkg/util/version"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindFixtureSnapshots(t *testing.T) {
+	snapshots := func(names ...string) []vm.VolumeSnapshot {
+		var res []vm.VolumeSnapshot
+		for _, name := range names {
+			res = append(res, vm.VolumeSnapshot{ID: name, Name: name})
+		}
+		return res
+	}
+	v := version.MustParse("v24.2.0-alpha.1")
+
+	all := snapshots(
+		// A complete set from a previous release series.
+		"tpch-sf1-v24-1-3-n2-0001", "tpch-sf1-v24-1-3-n2-0002",
+		// A complete set, listed out of order.
+		"tpch-sf1-v24-2-0-alpha-1-n2-0002", "tpch-sf1-v24-2-0-alpha-1-n2-0001",
+		// A partial set of a later version.
+		"tpch-sf1-v24-2-0-n2-0001",
+		// A set for a cluster with a different number of nodes.
+		"tpch-sf1-v24-2-1-n3-0001", "tpch-sf1-v24-2-1-n3-0002", "tpch-sf1-v24-2-1-n3-0003",
+		// A set of another fixture whose prefix starts with this one's.
+		"tpch-sf10-v24-2-1-n2-0001", "tpch-sf10-v24-2-1-n2-0002",
+	)
+	require.Equal(t,
+		snapshots("tpch-sf1-v24-2-0-alpha-1-n2-0001", "tpch-sf1-v24-2-0-alpha-1-n2-0002"),
+		findFixtureSnapshots(all, "tpch-sf1", 2, v))
+	require.Equal(t,
+		snapshots("tpch-sf1-v24-2-1-n3-0001", "tpch-sf1-v24-2-1-n3-0002", "tpch-sf1-v24-2-1-n3-0003"),
+		findFixtureSnapshots(all, "tpch-sf1", 3, v))
+	require.Nil(t, findFixtureSnapshots(all, "tpch-sf1", 4, v))
+	require.Nil(t, findFixtureSnapshots(all, "tpch-sf1", 2, version.MustParse("v24.3.0")))
+	require.Nil(t, findFixtureSnapshots(nil, "tpch-sf1", 2, v))
+}
@@ -11,9 +11,15 @@
 package roachtestutil
 
 import (
+	"context"
+	"sort"
+
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/cluster"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/option"
 	"github.com/cockroachdb/cockroach/pkg/roachprod/config"
 	"github.com/cockroachdb/cockroach/pkg/roachprod/install"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/logger"
+	"github.com/cockroachdb/errors"
 )
 
 // SystemInterfaceSystemdUnitName is a convenience function that
@@ -37,3 +43,31 @@ func SetDefaultAdminUIPort(c cluster.Cluster, opts *install.StartOpts) {
 		opts.AdminUIPort = config.DefaultAdminUIPort
 	}
 }
+
+// PutNodeBinaryOverrides uploads, to dest, the cockroach binaries overridden
+// for the given nodes (see --node-binary-override). It is meant to be called
+// after uploading the cockroach binary to all the nodes, so that the overridden
+// nodes run their own binary instead.
+func PutNodeBinaryOverrides(
+	ctx context.Context,
+	l *logger.Logger,
+	c cluster.Cluster,
+	overrides map[int]string,
+	dest string,
+	nodes option.NodeListOption,
+) error {
+	var overridden []int
+	for _, n := range nodes {
+		if _, ok := overrides[n]; ok {
+			overridden = append(overridden, n)
+		}
+	}
+	sort.Ints(overridden)
+	for _, n := range overridden {
+		l.Printf("using cockroach binary override for n%d: %s", n, overrides[n])
+		if err := c.PutE(ctx, l, overrides[n], dest, c.Node(n)); err != nil {
+			return errors.Wrapf(err, "uploading cockroach binary override to n%d", n)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package roachtestutil
+
+import (
+	"context"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/cluster"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/option"
+	"github.com/cockroachdb/cockroach/pkg/roachprod"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/logger"
+)
+
+// DefaultWorkloadPrometheusPort is the port `workload run` exposes the
+// snapshots of its histograms on unless --prometheus-port is passed.
+const DefaultWorkloadPrometheusPort = 2112
+
+// ScrapeWorkloadHistograms registers the workloads running on the given nodes,
+// which expose their histograms on the given ports (see the --prometheus-port
+// flag of `workload run`), with the managed Prometheus instance of the
+// cluster. Their latencies are then visible in Grafana while they run, rather
+// than only once stats.json is collected.
+//
+// The registration is best-effort: it is a no-op on local clusters and on
+// clusters which aren't scraped by the managed Prometheus, and failures are
+// only logged, as the test doesn't depend on them.
+func ScrapeWorkloadHistograms(
+	ctx context.Context,
+	l *logger.Logger,
+	c cluster.Cluster,
+	nodes option.NodeListOption,
+	ports ...int,
+) {
+	if c.IsLocal() {
+		return
+	}
+	if len(ports) == 0 {
+		ports = []int{DefaultWorkloadPrometheusPort}
+	}
+	if err := roachprod.UpdateWorkloadPrometheusTargets(ctx, l, c.MakeNodes(nodes), ports); err != nil {
+		l.Printf("failed to register the workload histograms with Prometheus: %v", err)
+	}
+}
@@ -13,6 +13,7 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"math/rand"
 	"net/http"
 	"os"
@@ -21,19 +22,24 @@ import (
 	"path/filepath"
 	"runtime"
 	"slices"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/DataDog/datadog-api-client-go/v2/api/datadog"
 	"github.com/DataDog/datadog-api-client-go/v2/api/datadogV1"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/cluster"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/option"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/roachtestflags"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/roachtestutil/operations"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/spec"
 	"github.com/cockroachdb/cockroach/pkg/roachprod"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/config"
 	"github.com/cockroachdb/cockroach/pkg/roachprod/install"
 	"github.com/cockroachdb/cockroach/pkg/roachprod/logger"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/vm/local"
+	"github.com/cockroachdb/cockroach/pkg/testutils/release"
 	"github.com/cockroachdb/cockroach/pkg/util/allstacks"
 	"github.com/cockroachdb/cockroach/pkg/util/leaktest"
 	"github.com/cockroachdb/cockroach/pkg/util/log"
@@ -53,6 +59,7 @@ const (
 	// NB: These are in a particular order corresponding to the order we
 	// want these tests to appear in the generated Markdown report.
 	testResultFailure testResult = iota
+	testResultFlaky
 	testResultSuccess
 	testResultSkip
 )
@@ -70,6 +77,12 @@ type testReportForGitHub struct {
 	name     string
 	duration time.Duration
 	status   testResult
+	// The fields below are only set for failed tests. errMsg is the first line
+	// of the first failure of the test, and source is the file:line where it
+	// was reported. artifactsDir is relative to the root artifacts directory.
+	errMsg       string
+	source       string
+	artifactsDir string
 }
 
 // runTests is the main function for the run and bench commands.
@@ -86,6 +99,8 @@ func runTests(register func(registry.Registry), filter *registry.TestFilter) err
 	stopper := stop.NewStopper()
 	defer stopper.Stop(context.Background())
 	runner := newTestRunner(cr, stopper)
+	runner.metrics = newRunnerMetrics(r.PromFactory(), roachtestflags.Cloud.String())
+	cr.metrics = runner.metrics
 
 	clusterType := roachprodCluster
 	bindTo := ""
@@ -98,10 +113,68 @@ func runTests(register func(registry.Registry), filter *registry.TestFilter) err
 		}
 	}
 
+	reportFormats, err := parseReportFormats(roachtestflags.ReportFormat)
+	if err != nil {
+		return err
+	}
+	if runner.ci, err = ciReporterFromFlags(); err != nil {
+		return err
+	}
+	nodeBinaryOverride, err := parseNodeBinaryOverride(roachtestflags.NodeBinaryOverride)
+	if err != nil {
+		return err
+	}
 	specs, err := testsToRun(r, filter, roachtestflags.RunSkipped, roachtestflags.SelectProbability, true)
 	if err != nil {
 		return err
 	}
+	runner.staleSkips = staleSkips(filter.Filter(r.AllTests()), timeutil.Now())
+	applyMetamorphicTopologies(specs, roachtestflags.GlobalSeed)
+	shard, err := parseShardSpec(roachtestflags.Shard, roachtestflags.ShardStrategy)
+	if err != nil {
+		return err
+	}
+	if shard.count > 1 {
+		sharded := shard.filter(specs)
+		fmt.Printf("running shard %s: %d of %d selected tests\n", shard, len(sharded), len(specs))
+		specs = sharded
+	}
+	runner.checkpoint = newRunCheckpoint(roachtestflags.ArtifactsDir)
+	if err := writeRunInfo(roachtestflags.ArtifactsDir); err != nil {
+		return err
+	}
+	if roachtestflags.ResumeFrom != "" {
+		prev, err := loadCheckpointEntries(roachtestflags.ResumeFrom)
+		if err != nil {
+			return err
+		}
+		remaining, skipped := remainingTests(specs, roachtestflags.Count, prev)
+		fmt.Printf("resuming from %s: skipping %d tests which passed\n",
+			roachtestflags.ResumeFrom, len(specs)-len(remaining))
+		if len(remaining) == 0 {
+			fmt.Printf("all tests passed; nothing to resume\n")
+			return nil
+		}
+		specs = remaining
+		// Carry over the skipped test runs, so that this run can be resumed as
+		// well.
+		if err := runner.checkpoint.record(skipped...); err != nil {
+			return err
+		}
+	}
+	if roachtestflags.TestHistory != "" {
+		history, err := loadTestHistory(strings.Split(roachtestflags.TestHistory, ","))
+		if err != nil {
+			return err
+		}
+		prioritized := applyTestHistory(specs, history)
+		fmt.Printf("scheduling first %d tests which failed in or were missing from previous runs\n", prioritized)
+	}
+	if roachtestflags.ShardManifest != "" {
+		if runner.config.manifest, err = newTestManifest(roachtestflags.ShardManifest, roachtestflags.ShardManifestTTL); err != nil {
+			return err
+		}
+	}
 
 	n := len(specs)
 	if n*roachtestflags.Count < parallelism {
@@ -157,6 +230,14 @@ func runTests(register func(registry.Registry), filter *registry.TestFilter) err
 	if opt.debugMode == DebugKeepAlways && n > 1 {
 		return errors.Newf("--debug-always is only allowed when running a single test")
 	}
+	if roachtestflags.StopAfterStage != "" && roachtestflags.ClusterWipe {
+		// The stage checkpoint lives in the store dir, which is wiped when the
+		// cluster is released or reused, so the next run couldn't resume.
+		return errors.Newf("--stop-after-stage requires --wipe=false")
+	}
+	if roachtestflags.DebugWithDelve && !opt.debugMode.IsDebug() {
+		return errors.Newf("--debug-with-delve requires --debug or --debug-always")
+	}
 
 	lopt := loggingOpt{
 		l:                   l,
@@ -168,6 +249,14 @@ func runTests(register func(registry.Registry), filter *registry.TestFilter) err
 		runnerLogPath:       runnerLogPath,
 	}
 	l.Printf("global random seed: %d", roachtestflags.GlobalSeed)
+	if roachtestflags.FetchReleaseData {
+		if err := release.LoadReleaseData(context.Background(), release.ReleaseDataURL); err != nil {
+			// The embedded release data is stale at worst, which doesn't
+			// warrant failing the run.
+			shout(context.Background(), l, os.Stdout,
+				"WARNING: using the release data embedded in the binary: %v", err)
+		}
+	}
 	go func() {
 		if err := http.ListenAndServe(
 			fmt.Sprintf(":%d", roachtestflags.PromPort),
@@ -178,8 +267,9 @@ func runTests(register func(registry.Registry), filter *registry.TestFilter) err
 	}()
 	// We're going to run all the workers (and thus all the tests) in a context
 	// that gets canceled when the Interrupt signal is received.
-	ctx, cancel := context.WithCancel(context.Background())
+	ctx, cancel := context.WithCancel(newDatadogContext(context.Background()))
 	defer cancel()
+	runner.datadogMetrics = newDatadogMetrics(ctx, getDatadogTags())
 	CtrlC(ctx, l, cancel, cr)
 	// Install goroutine leak checker and run it at the end of the entire test
 	// run. If a test is leaking a goroutine, then it will likely be still around.
@@ -187,6 +277,9 @@ func runTests(register func(registry.Registry), filter *registry.TestFilter) err
 	// could yield false positives; e.g., user-specified test teardown goroutines
 	// may still be running long after the test has completed.
 	defer leaktest.AfterTest(l)()
+	if m := runner.config.manifest; m != nil {
+		defer m.startHeartbeats(l)()
+	}
 
 	// We allow roachprod users to set a default auth-mode through the
 	// ROACHPROD_DEFAULT_AUTH_MODE env var. However, roachtests shouldn't
@@ -200,6 +293,7 @@ func runTests(register func(registry.Registry), filter *registry.TestFilter) err
 		ctx, specs, roachtestflags.Count, parallelism, opt,
 		testOpts{
 			versionsBinaryOverride: roachtestflags.VersionsBinaryOverride,
+			nodeBinaryOverride:     nodeBinaryOverride,
 			skipInit:               roachtestflags.SkipInit,
 			goCoverEnabled:         roachtestflags.GoCoverEnabled,
 		},
@@ -212,18 +306,34 @@ func runTests(register func(registry.Registry), filter *registry.TestFilter) err
 	l.PrintfCtx(ctx, "runTests destroying all clusters")
 	cr.destroyAllClusters(context.Background(), l)
 
-	if roachtestflags.TeamCity {
-		// Collect the runner logs.
-		fmt.Printf("##teamcity[publishArtifacts '%s']\n", filepath.Join(literalArtifactsDir, runnerLogsDir))
+	if ciErr := runner.ci.runFinished(printCIOutput, runner, literalArtifactsDir); ciErr != nil {
+		shout(ctx, l, os.Stdout, "failed to report the end of the run to CI (%+v)", ciErr)
 	}
 
-	if summaryErr := maybeDumpSummaryMarkdown(runner); summaryErr != nil {
-		shout(ctx, l, os.Stdout, "failed to write to GITHUB_STEP_SUMMARY file (%+v)", summaryErr)
+	if reportErr := maybeWriteTestReport(runner, reportFormats); reportErr != nil {
+		shout(ctx, l, os.Stdout, "failed to write test report (%+v)", reportErr)
 	}
 
 	return err
 }
 
+// parseNodeBinaryOverride parses the value of --node-binary-override, and
+// checks that the binaries exist.
+func parseNodeBinaryOverride(flag map[string]string) (map[int]string, error) {
+	overrides := make(map[int]string, len(flag))
+	for node, path := range flag {
+		n, err := strconv.Atoi(node)
+		if err != nil || n < 1 {
+			return nil, errors.Newf("invalid node %q in --node-binary-override: nodes are numbered from 1", node)
+		}
+		if _, err := os.Stat(path); err != nil {
+			return nil, errors.Wrapf(err, "binary override for n%d", n)
+		}
+		overrides[n] = path
+	}
+	return overrides, nil
+}
+
 // This diverts all the default non-fatal logging to a file in `baseDir`. This is particularly
 // useful in CI, where without this, stderr/stdout are cluttered with logs from various
 // packages used in roachtest like sarama and testutils.
@@ -265,6 +375,9 @@ func initRunFlagsBinariesAndLibraries(cmd *cobra.Command) error {
 	if roachtestflags.Count <= 0 {
 		return fmt.Errorf("--count (%d) must by greater than 0", roachtestflags.Count)
 	}
+	if roachtestflags.Parallelism < 0 {
+		return fmt.Errorf("--parallelism (%d) must not be negative", roachtestflags.Parallelism)
+	}
 
 	if !(0 <= roachtestflags.ARM64Probability && roachtestflags.ARM64Probability <= 1) {
 		return fmt.Errorf("'metamorphic-arm64-probability' must be in [0,1]")
@@ -278,8 +391,15 @@ func initRunFlagsBinariesAndLibraries(cmd *cobra.Command) error {
 	if roachtestflags.FIPSProbability == 1 && roachtestflags.ARM64Probability != 0 {
 		return fmt.Errorf("'metamorphic-arm64-probability' must be 0 when 'metamorphic-fips-probability' is 1")
 	}
-	if !(0 <= roachtestflags.SelectProbability && roachtestflags.SelectProbability <= 1) {
-		return fmt.Errorf("'select-probability' must be in [0,1]")
+	for suite := range roachtestflags.SelectProbability.Suites {
+		if !registry.AllSuites.Contains(suite) {
+			return fmt.Errorf("'select-probability': invalid suite %q; valid suites are %s", suite, registry.AllSuites)
+		}
+	}
+	for owner := range roachtestflags.SelectProbability.Owners {
+		if !registry.Owner(owner).IsValid() {
+			return fmt.Errorf("'select-probability': invalid owner %q", owner)
+		}
 	}
 	arm64Opt := cmd.Flags().Lookup("metamorphic-arm64-probability")
 	if !arm64Opt.Changed && runtime.GOARCH == "arm64" && roachtestflags.Cloud == spec.Local {
@@ -302,8 +422,8 @@ func initRunFlagsBinariesAndLibraries(cmd *cobra.Command) error {
 		fmt.Printf("FIPS clusters will be provisioned with probability %.2f\n", roachtestflags.FIPSProbability*amd64Probability)
 	}
 
-	if roachtestflags.SelectProbability > 0 && roachtestflags.SelectProbability < 1 {
-		fmt.Printf("Matching tests will be selected with probability %.2f\n", roachtestflags.SelectProbability)
+	if !roachtestflags.SelectProbability.IsOne() {
+		fmt.Printf("Matching tests will be selected with probability %s\n", roachtestflags.SelectProbability)
 	}
 	return nil
 }
@@ -385,10 +505,9 @@ func testRunnerLogger(
 	return l, teeOpt
 }
 
+// maybeDumpSummaryMarkdown writes a summary of the run to the
+// GITHUB_STEP_SUMMARY file, if set.
 func maybeDumpSummaryMarkdown(r *testRunner) error {
-	if !roachtestflags.GitHubActions {
-		return nil
-	}
 	summaryPath := os.Getenv("GITHUB_STEP_SUMMARY")
 	if summaryPath == "" {
 		return nil
@@ -397,6 +516,7 @@ func maybeDumpSummaryMarkdown(r *testRunner) error {
 	if err != nil {
 		return err
 	}
+	defer summaryFile.Close()
 
 	_, err = summaryFile.WriteString(`| TestName | Status | Duration |
 | --- | --- | --- |
@@ -415,10 +535,26 @@ func maybeDumpSummaryMarkdown(r *testRunner) error {
 	}
 
 	for test := range r.status.fail {
+		report := testReportForGitHub{
+			name:         test.Name(),
+			duration:     test.duration(),
+			status:       testResultFailure,
+			artifactsDir: test.ArtifactsDir(),
+		}
+		if rel, err := filepath.Rel(roachtestflags.ArtifactsDir, test.ArtifactsDir()); err == nil {
+			report.artifactsDir = rel
+		}
+		if failures := test.failures(); len(failures) > 0 {
+			report.errMsg, report.source = summarizeFailure(failures[0])
+		}
+		allTests = append(allTests, report)
+	}
+
+	for test := range r.status.flaky {
 		allTests = append(allTests, testReportForGitHub{
 			name:     test.Name(),
 			duration: test.duration(),
-			status:   testResultFailure,
+			status:   testResultFlaky,
 		})
 	}
 
@@ -430,8 +566,21 @@ func maybeDumpSummaryMarkdown(r *testRunner) error {
 		})
 	}
 
-	// Sort the test results: first fails, then successes, then skips, and
-	// within each category sort by test duration in descending order.
+	// The test runs which were not started because of the run duration or
+	// cost budgets are reported as skipped.
+	var notRun []notRunTest
+	if r.budget != nil {
+		notRun = append(notRun, r.budget.notRunTests()...)
+	}
+	if r.costs != nil {
+		notRun = append(notRun, r.costs.refusedTests()...)
+	}
+	for _, t := range notRun {
+		allTests = append(allTests, testReportForGitHub{name: t.name, status: testResultSkip})
+	}
+
+	// Sort the test results: first fails, then flakes, then successes, then
+	// skips, and within each category sort by test duration in descending order.
 	// Ties are very unlikely to happen but we break them by test name.
 	slices.SortFunc(allTests, func(a, b testReportForGitHub) int {
 		if a.status < b.status {
@@ -450,6 +599,8 @@ func maybeDumpSummaryMarkdown(r *testRunner) error {
 		var statusString string
 		if test.status == testResultFailure {
 			statusString = "❌ FAILED"
+		} else if test.status == testResultFlaky {
+			statusString = "🟧 FLAKY"
 		} else if test.status == testResultSuccess {
 			statusString = "✅ SUCCESS"
 		} else {
@@ -461,7 +612,86 @@ func maybeDumpSummaryMarkdown(r *testRunner) error {
 		}
 	}
 
-	return nil
+	return writeFailureDetailsMarkdown(summaryFile, allTests, githubRunURL(), r.artifacts.browseURL)
+}
+
+// summarizeFailure returns the first line of the given failure's message, and
+// the file:line where it was reported.
+func summarizeFailure(f failure) (errMsg string, source string) {
+	errMsg, _, _ = strings.Cut(strings.TrimSpace(f.squashedErr.Error()), "\n")
+	if file, line, _, ok := errors.GetOneLineSource(f.squashedErr); ok {
+		source = fmt.Sprintf("%s:%d", file, line)
+	}
+	return errMsg, source
+}
+
+// githubRunURL returns the URL of the GitHub Actions workflow run roachtest is
+// running in, if any.
+func githubRunURL() string {
+	server, repo, runID := os.Getenv("GITHUB_SERVER_URL"), os.Getenv("GITHUB_REPOSITORY"), os.Getenv("GITHUB_RUN_ID")
+	if server == "" || repo == "" || runID == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s/actions/runs/%s", server, repo, runID)
+}
+
+// writeFailureDetailsMarkdown writes a section for each failed test in tests,
+// with its first error and where to find its artifacts and logs. The artifacts
+// and test log link to the URLs returned by artifactURL, if any, and the
+// runner log links to the workflow run if runURL is set.
+func writeFailureDetailsMarkdown(
+	w io.Writer,
+	tests []testReportForGitHub,
+	runURL string,
+	artifactURL func(rel string, isDir bool) string,
+) error {
+	link := func(text, target string) string {
+		if target == "" {
+			return fmt.Sprintf("`%s`", text)
+		}
+		return fmt.Sprintf("[`%s`](%s)", text, target)
+	}
+	var b strings.Builder
+	for _, test := range tests {
+		if test.status != testResultFailure {
+			continue
+		}
+		if b.Len() == 0 {
+			b.WriteString("\n## Failures\n")
+		}
+		fmt.Fprintf(&b, "\n### `%s`\n\n", test.name)
+		if test.errMsg != "" {
+			errMsg := test.errMsg
+			if test.source != "" {
+				errMsg = fmt.Sprintf("(%s) %s", test.source, errMsg)
+			}
+			fence := codeFence(errMsg)
+			fmt.Fprintf(&b, "%s\n%s\n%s\n\n", fence, errMsg, fence)
+		}
+		testLog := filepath.Join(test.artifactsDir, testLogName)
+		fmt.Fprintf(&b, "- Artifacts: %s\n", link(test.artifactsDir, artifactURL(test.artifactsDir, true /* isDir */)))
+		fmt.Fprintf(&b, "- Test log: %s\n", link(testLog, artifactURL(testLog, false /* isDir */)))
+		if runURL != "" {
+			fmt.Fprintf(&b, "- Runner log: [`%s` in the job log](%s)\n", test.name, runURL)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// codeFence returns a Markdown code fence for the given text, which is longer
+// than any run of backticks in it, so that the text can't close the block.
+func codeFence(text string) string {
+	longest, run := 0, 0
+	for _, c := range text {
+		if c == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	return strings.Repeat("`", max(3, longest+1))
 }
 
 // maybeEmitDatadogEvent sends an event to Datadog if the passed in ctx has the
@@ -470,15 +700,12 @@ func maybeEmitDatadogEvent(
 	ctx context.Context,
 	datadogEventsAPI *datadogV1.EventsApi,
 	opSpec *registry.OperationSpec,
-	clusterName string,
+	links operationEventLinks,
 	eventType ddEventType,
-	operationID uint64,
 	datadogTags []string,
 ) {
 	// The passed in context is not configured to communicate with Datadog.
-	_, hasAPIKeys := ctx.Value(datadog.ContextAPIKeys).(map[string]datadog.APIKey)
-	_, hasServerVariables := ctx.Value(datadog.ContextServerVariables).(map[string]string)
-	if !hasAPIKeys || !hasServerVariables {
+	if !hasDatadogContext(ctx) {
 		return
 	}
 
@@ -505,16 +732,16 @@ func maybeEmitDatadogEvent(
 
 	// We're within a best effort function so we ignore return values.
 	_, _, _ = datadogEventsAPI.CreateEvent(ctx, datadogV1.EventCreateRequest{
-		AggregationKey: datadog.PtrString(fmt.Sprintf("operation-%d", operationID)),
+		AggregationKey: datadog.PtrString(fmt.Sprintf("operation-%d", links.runID)),
 		AlertType:      &alertType,
 		DateHappened:   datadog.PtrInt64(timeutil.Now().UnixNano()),
 		Host:           &hostname,
 		SourceTypeName: datadog.PtrString("roachtest"),
-		Tags: append(datadogTags,
+		Tags: append(append(datadogTags,
 			fmt.Sprintf("operation-name:%s", opSpec.Name),
 			fmt.Sprintf("operation-status:%s", status),
-		),
-		Text:  fmt.Sprintf("cluster: %s\n", clusterName),
+		), links.tags()...),
+		Text:  links.text(),
 		Title: title,
 	})
 }
@@ -586,7 +813,19 @@ func getDatadogTags() []string {
 	return strings.Split(rawTags, ",")
 }
 
+// localClusterCertsDir returns the certs directory of the first node of the
+// given local cluster, or an empty string if the cluster is insecure.
+func localClusterCertsDir(clusterName string) string {
+	dir := filepath.Join(local.VMDir(clusterName, 1), install.CockroachNodeCertsDir)
+	if _, err := os.Stat(dir); err != nil {
+		return ""
+	}
+	return dir
+}
+
 // runOperation sequentially runs one operation matched by the passed-in filter.
+// If --schedule is set, it instead runs the operations matched by the filter on
+// that schedule until interrupted; see operationScheduler.
 func runOperation(register func(registry.Registry), filter string, clusterName string) error {
 	//lint:ignore SA1019 deprecated
 	rand.Seed(roachtestflags.GlobalSeed)
@@ -605,12 +844,21 @@ func runOperation(register func(registry.Registry), filter string, clusterName s
 	ctx := context.Background()
 	ctx = newDatadogContext(ctx)
 
-	datadogEventsClient := datadogV1.NewEventsApi(datadog.NewAPIClient(datadog.NewConfiguration()))
 	datadogTags := getDatadogTags()
 
+	certsDir := roachtestflags.CertsDir
+	if config.IsLocalClusterName(clusterName) {
+		// Local clusters don't need cloud access, and their certs, if any, are
+		// on the local filesystem.
+		roachtestflags.Cloud = spec.Local
+		if certsDir == "" {
+			certsDir = localClusterCertsDir(clusterName)
+		}
+	}
+
 	// TODO(bilal): This is excessive for just getting the number of nodes in the
 	// cluster. We should expose a roachprod.Nodes method or so.
-	nodes, err := roachprod.PgURL(ctx, l, clusterName, roachtestflags.CertsDir, roachprod.PGURLOptions{})
+	nodes, err := roachprod.PgURL(ctx, l, clusterName, certsDir, roachprod.PGURLOptions{})
 	if err != nil {
 		return errors.Wrap(err, "roachtest: run-operation: error when getting number of nodes")
 	}
@@ -635,30 +883,82 @@ func runOperation(register func(registry.Registry), filter string, clusterName s
 	}
 
 	cSpec := spec.ClusterSpec{NodeCount: len(nodes)}
-	op := &operationImpl{
+	expiration := cSpec.Expiration()
+	if roachtestflags.Cloud == spec.Local {
+		expiration = timeutil.Now().Add(100000 * time.Hour)
+	}
+	opRunner := &operationRunner{
+		l:               l,
+		clusterName:     clusterName,
 		clusterSettings: config.ClusterSettings,
 		startOpts:       config.StartOpts,
-		l:               l,
-	}
-	c := &dynamicClusterImpl{
-		&clusterImpl{
-			name:       clusterName,
-			cloud:      roachtestflags.Cloud,
-			spec:       cSpec,
-			f:          op,
-			l:          l,
-			expiration: cSpec.Expiration(),
-			destroyState: destroyState{
-				owned: false,
+		c: &dynamicClusterImpl{
+			&clusterImpl{
+				name:       clusterName,
+				cloud:      roachtestflags.Cloud,
+				spec:       cSpec,
+				l:          l,
+				expiration: expiration,
+				destroyState: destroyState{
+					owned: false,
+				},
+				localCertsDir: certsDir,
 			},
-			localCertsDir: roachtestflags.CertsDir,
 		},
+		cleanups:       newPendingCleanupRegistry(clusterName),
+		datadogEvents:  datadogV1.NewEventsApi(datadog.NewAPIClient(datadog.NewConfiguration())),
+		datadogTags:    datadogTags,
+		datadogMetrics: newDatadogMetrics(ctx, datadogTags),
 	}
 
-	specs, err := opsToRun(r, filter)
+	specs, err := opsToRun(r, filter, roachtestflags.Cloud)
 	if err != nil {
 		return err
 	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	// Cancel this context if we get an interrupt.
+	CtrlC(ctx, l, cancel, nil /* registry */)
+
+	if roachtestflags.ResumeCleanups {
+		return opRunner.resumeCleanups(ctx, specs)
+	}
+
+	if roachtestflags.OperationDryRun {
+		// Audit all the matched operations, rather than one of them.
+		opRunner.dryRun = true
+		var failed []string
+		for i := range specs {
+			l.Printf("dry-run: operation %s", specs[i].Name)
+			if err := opRunner.run(ctx, &specs[i]); err != nil {
+				// Operations may fail in a dry-run if they depend on the effects
+				// of the steps which were not executed.
+				l.Printf("dry-run: operation %s failed: %s", specs[i].Name, err)
+				failed = append(failed, specs[i].Name)
+			}
+		}
+		if len(failed) > 0 {
+			return errors.Newf("dry-run of operations %s failed", strings.Join(failed, ", "))
+		}
+		return nil
+	}
+
+	if roachtestflags.OperationSchedule != "" {
+		weights, err := parseOperationWeights(roachtestflags.OperationWeights)
+		if err != nil {
+			return err
+		}
+		s, err := newOperationScheduler(
+			roachtestflags.OperationSchedule, specs, weights, roachtestflags.OperationCooldown,
+			roachtestflags.SchedulerStatePath, rand.New(rand.NewSource(roachtestflags.GlobalSeed)),
+		)
+		if err != nil {
+			return err
+		}
+		return s.run(ctx, l, opRunner.run)
+	}
+
 	var opSpec *registry.OperationSpec
 	if len(specs) > 1 {
 		opSpec = &specs[rand.Intn(len(specs))]
@@ -668,21 +968,55 @@ func runOperation(register func(registry.Registry), filter string, clusterName s
 	} else {
 		return errors.Errorf("no operations found for filter %s", filter)
 	}
-	op.spec = opSpec
+	return opRunner.run(ctx, opSpec)
+}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-	// Cancel this context if we get an interrupt.
-	CtrlC(ctx, l, cancel, nil /* registry */)
+// operationRunner runs operations against an existing cluster.
+type operationRunner struct {
+	l               *logger.Logger
+	clusterName     string
+	clusterSettings install.ClusterSettings
+	startOpts       option.StartOpts
+	c               *dynamicClusterImpl
+
+	// dryRun, if set, runs the operations against a dryRunCluster, which
+	// records the steps they would execute. See --dry-run.
+	dryRun bool
+
+	// cleanups, if set, persists the cleanups of the operations until they
+	// complete. See --resume-cleanups.
+	cleanups *pendingCleanupRegistry
+
+	datadogEvents  *datadogV1.EventsApi
+	datadogTags    []string
+	datadogMetrics *datadogMetrics
+}
 
-	op.mu.cancel = cancel
+// run runs the given operation, and its cleanup if any, emitting Datadog
+// events as it progresses. Returns the first failure of the operation.
+func (r *operationRunner) run(ctx context.Context, opSpec *registry.OperationSpec) error {
+	l := r.l
+	var c cluster.Cluster = r.c
+	var dryRunC *dryRunCluster
+	if r.dryRun {
+		dryRunC = newDryRunCluster(r.c, l)
+		c = dryRunC
+		defer func() {
+			l.Printf("dry-run: operation %s would execute %d steps:", opSpec.Name, len(dryRunC.steps()))
+			for i, step := range dryRunC.steps() {
+				l.Printf("  %d. %s", i+1, step)
+			}
+		}()
+	}
+	ctx, op, cancel := r.newOperation(ctx, opSpec)
+	defer cancel()
 	op.Status(fmt.Sprintf("checking if operation %s dependencies are met", opSpec.Name))
 
 	if roachtestflags.SkipDependencyCheck {
 		op.Status("skipping dependency check")
-	} else if ok, err := operations.CheckDependencies(ctx, c, l, opSpec); !ok || err != nil {
+	} else if ok, err := operations.CheckDependencies(ctx, r.c, l, opSpec); !ok || err != nil {
 		if err != nil {
-			op.Fatalf("error checking dependencies: %s", err)
+			return errors.Wrap(err, "error checking dependencies")
 		}
 		op.Status("operation dependencies not met. Use --skip-dependency-check to skip this check.")
 		return nil
@@ -690,48 +1024,173 @@ func runOperation(register func(registry.Registry), filter string, clusterName s
 
 	// operationRunID is used for datadog event aggregation and logging.
 	operationRunID := rand.Uint64()
-	maybeEmitDatadogEvent(ctx, datadogEventsClient, opSpec, clusterName, eventOpStarted, operationRunID, datadogTags)
+	links := makeOperationEventLinks(r.clusterName, opSpec.Name, operationRunID, timeutil.Now())
+	r.emitEvent(ctx, op, links, eventOpStarted)
 	op.Status(fmt.Sprintf("running operation %s with run id %d", opSpec.Name, operationRunID))
 	var cleanup registry.OperationCleanup
-	func() {
-		ctx, cancel := context.WithTimeout(ctx, opSpec.Timeout)
-		defer cancel()
-
+	opStart := timeutil.Now()
+	r.runStep(ctx, op, func(ctx context.Context) {
 		cleanup = opSpec.Run(ctx, op, c)
-	}()
+	})
+	if !r.dryRun {
+		r.datadogMetrics.recordOperation(ctx, l, opSpec.Name, r.clusterName, timeutil.Since(opStart), op.Failed())
+	}
 	if op.Failed() {
 		op.Status("operation failed")
-		maybeEmitDatadogEvent(ctx, datadogEventsClient, opSpec, clusterName, eventOpError, operationRunID, datadogTags)
-		return op.mu.failures[0]
+		r.emitEvent(ctx, op, links, eventOpError)
+		err := op.mu.failures[0]
+		if cleanup != nil {
+			// Operations which fail after disrupting the cluster return the
+			// cleanup which undoes the disruption, e.g. restarts a node.
+			r.persistCleanup(l, op.spec, operationRunID, cleanup)
+			_ = r.runCleanup(op, c, cleanup, links)
+		}
+		return err
 	}
 
-	maybeEmitDatadogEvent(ctx, datadogEventsClient, opSpec, clusterName, eventOpRan, operationRunID, datadogTags)
+	r.emitEvent(ctx, op, links, eventOpRan)
 	if cleanup == nil {
 		op.Status("operation ran successfully")
 		return nil
 	}
 
-	op.Status(fmt.Sprintf("operation ran successfully; waiting %s before cleanup", roachtestflags.WaitBeforeCleanup))
-	select {
-	// Don't exit if the context is done due to a Ctrl-C, instead still run the
-	// cleanup code.
-	case <-ctx.Done():
-	case <-time.After(roachtestflags.WaitBeforeCleanup):
+	r.persistCleanup(l, opSpec, operationRunID, cleanup)
+
+	if r.dryRun {
+		op.Status("operation ran successfully; running cleanup")
+	} else {
+		op.Status(fmt.Sprintf("operation ran successfully; waiting %s before cleanup", roachtestflags.WaitBeforeCleanup))
+		select {
+		// Don't exit if the context is done due to a Ctrl-C, instead still run the
+		// cleanup code.
+		case <-ctx.Done():
+		case <-time.After(roachtestflags.WaitBeforeCleanup):
+		}
 	}
-	op.Status("running cleanup")
-	func() {
-		ctx, cancel := context.WithTimeout(context.Background(), opSpec.Timeout)
-		defer cancel()
+	return r.runCleanup(op, c, cleanup, links)
+}
 
-		cleanup.Cleanup(ctx, op, c)
+// newOperation returns the operationImpl of a run of the given operation, and
+// the context to run it with, which is canceled by o.Fatal().
+func (r *operationRunner) newOperation(
+	ctx context.Context, opSpec *registry.OperationSpec,
+) (context.Context, *operationImpl, context.CancelFunc) {
+	op := &operationImpl{
+		spec:            opSpec,
+		clusterSettings: r.clusterSettings,
+		startOpts:       r.startOpts,
+		l:               r.l,
+	}
+	r.c.f = op
+
+	ctx, cancel := context.WithCancel(ctx)
+	op.mu.cancel = cancel
+	return ctx, op, cancel
+}
+
+// runStep runs one step of an operation, turning the panics of o.Fatal() into
+// failures of the operation.
+func (r *operationRunner) runStep(
+	ctx context.Context, op *operationImpl, step func(ctx context.Context),
+) {
+	ctx, cancel := context.WithTimeout(ctx, op.spec.Timeout)
+	defer cancel()
+	defer func() {
+		if err := recover(); err != nil && err != errOperationFatal {
+			op.Errorf("operation panicked: %v", err)
+		}
 	}()
+	step(ctx)
+}
 
-	if op.Failed() {
+// emitEvent emits a Datadog event about the run of an operation with the given
+// links, tagged with the tags the operation added so far.
+func (r *operationRunner) emitEvent(
+	ctx context.Context, op *operationImpl, links operationEventLinks, eventType ddEventType,
+) {
+	if r.dryRun {
+		return
+	}
+	tags := append(append([]string(nil), r.datadogTags...), op.eventTags()...)
+	maybeEmitDatadogEvent(ctx, r.datadogEvents, op.spec, links, eventType, tags)
+}
+
+// persistCleanup persists the cleanup of a run of an operation until it
+// completes, so that it can be resumed if the runner dies before then.
+func (r *operationRunner) persistCleanup(
+	l *logger.Logger, opSpec *registry.OperationSpec, runID uint64, cleanup registry.OperationCleanup,
+) {
+	if r.cleanups == nil || opSpec.NewCleanup == nil || r.dryRun {
+		return
+	}
+	if err := r.cleanups.add(opSpec.Name, runID, cleanup); err != nil {
+		l.Printf("failed to persist the cleanup of operation %s: %s", opSpec.Name, err)
+	}
+}
+
+// runCleanup runs the cleanup of the run of an operation with the given links,
+// and removes it from the pending cleanups once it completes. Returns the
+// first failure of the cleanup.
+func (r *operationRunner) runCleanup(
+	op *operationImpl, c cluster.Cluster, cleanup registry.OperationCleanup, links operationEventLinks,
+) error {
+	opSpec := op.spec
+	op.Status("running cleanup")
+	// The cleanup runs even if the operation was interrupted, or failed.
+	ctx := context.Background()
+	prevFailures := len(op.failuresSince(0))
+	r.runStep(ctx, op, func(ctx context.Context) {
+		cleanup.Cleanup(ctx, op, c)
+	})
+	if failures := op.failuresSince(prevFailures); len(failures) > 0 {
 		op.Status("operation cleanup failed")
-		maybeEmitDatadogEvent(ctx, datadogEventsClient, opSpec, clusterName, eventOpError, operationRunID, datadogTags)
-		return op.mu.failures[0]
+		r.emitEvent(ctx, op, links, eventOpError)
+		return failures[0]
 	}
-	maybeEmitDatadogEvent(ctx, datadogEventsClient, opSpec, clusterName, eventOpFinishedCleanup, operationRunID, datadogTags)
+	r.emitEvent(ctx, op, links, eventOpFinishedCleanup)
 
+	if r.cleanups != nil && opSpec.NewCleanup != nil && !r.dryRun {
+		if err := r.cleanups.remove(opSpec.Name, links.runID); err != nil {
+			r.l.Printf("failed to remove the pending cleanup of operation %s: %s", opSpec.Name, err)
+		}
+	}
 	return nil
 }
+
+// resumeCleanups runs the pending cleanups of the given operations on the
+// cluster, from oldest to newest. The cleanups which fail are left pending.
+// Returns the first failure.
+func (r *operationRunner) resumeCleanups(ctx context.Context, specs []registry.OperationSpec) error {
+	pending, err := r.cleanups.list()
+	if err != nil {
+		return err
+	}
+	byName := make(map[string]*registry.OperationSpec, len(specs))
+	for i := range specs {
+		byName[specs[i].Name] = &specs[i]
+	}
+
+	var resumed int
+	var firstErr error
+	for _, p := range pending {
+		opSpec, ok := byName[p.Operation]
+		if !ok {
+			continue
+		}
+		cleanup, err := decodeCleanup(opSpec, p)
+		if err != nil {
+			return err
+		}
+		resumed++
+		_, op, cancel := r.newOperation(ctx, opSpec)
+		op.Status(fmt.Sprintf("resuming the cleanup of operation %s with run id %d, from %s",
+			opSpec.Name, p.RunID, p.Created.Format(time.RFC3339)))
+		links := makeOperationEventLinks(r.clusterName, opSpec.Name, p.RunID, p.Created)
+		if err := r.runCleanup(op, r.c, cleanup, links); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		cancel()
+	}
+	r.l.Printf("resumed %d of %d pending cleanups", resumed, len(pending))
+	return firstErr
+}
@@ -0,0 +1,122 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/spec"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+)
+
+// windDownMargin is the time set aside at the end of the run duration budget
+// to set up clusters before the test runs, and to collect artifacts and
+// destroy clusters after them.
+const windDownMargin = 10 * time.Minute
+
+// notRunReason is the reason reported for the test runs which were not
+// started because of the run duration budget.
+const notRunReason = "not run: the run duration budget is exhausted"
+
+// costRefusedReason is the reason reported for the test runs which were not
+// scheduled because of the cost budget.
+const costRefusedReason = "not run: the estimated cost exceeds --max-total-cost"
+
+// notRunTest is a test run which was not started because the run duration
+// budget was nearly exhausted.
+type notRunTest struct {
+	name   string
+	owner  string
+	runNum int
+}
+
+// durationBudget enforces the budget set by --max-run-duration.
+//
+// A test run is only started if it is expected to finish, along with its
+// setup and teardown, before the budget is exhausted. Test runs which are
+// already running are left to finish, and the ones which are not started are
+// reported as not run, so that the run ends cleanly before being killed by
+// the timeout of the CI job running roachtest.
+type durationBudget struct {
+	// deadline is the time at which the budget is exhausted; the zero value
+	// means no limit.
+	deadline time.Time
+	// cloud is the cloud the tests run on, which their expected durations
+	// depend on.
+	cloud spec.Cloud
+	mu    struct {
+		syncutil.Mutex
+		notRun []notRunTest
+	}
+}
+
+func newDurationBudget(start time.Time, budget time.Duration, cloud spec.Cloud) *durationBudget {
+	b := &durationBudget{cloud: cloud}
+	if budget > 0 {
+		b.deadline = start.Add(budget)
+	}
+	return b
+}
+
+// admit returns whether a run of the given test, started at the given time,
+// is expected to finish before the deadline. The run is recorded as not run
+// otherwise, unless force is set.
+func (b *durationBudget) admit(t *registry.TestSpec, runNum int, now time.Time, force bool) bool {
+	if force || b.deadline.IsZero() {
+		return true
+	}
+	if now.Add(expectedTestDuration(t, b.cloud) + windDownMargin).Before(b.deadline) {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.mu.notRun = append(b.mu.notRun, notRunTest{name: t.Name, owner: string(t.Owner), runNum: runNum})
+	return false
+}
+
+// notRunTests returns the test runs which were not started because of the
+// budget, sorted by name and run.
+func (b *durationBudget) notRunTests() []notRunTest {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	res := append([]notRunTest(nil), b.mu.notRun...)
+	sortNotRunTests(res)
+	return res
+}
+
+// sortNotRunTests sorts the given test runs by name and run.
+func sortNotRunTests(tests []notRunTest) {
+	sort.Slice(tests, func(i, j int) bool {
+		if tests[i].name != tests[j].name {
+			return tests[i].name < tests[j].name
+		}
+		return tests[i].runNum < tests[j].runNum
+	})
+}
+
+// summary returns a description of the test runs which were not started
+// because of the budget, or an empty string if there are none.
+func (b *durationBudget) summary() string {
+	notRun := b.notRunTests()
+	if len(notRun) == 0 {
+		return ""
+	}
+	runs := make([]string, len(notRun))
+	for i, t := range notRun {
+		runs[i] = fmt.Sprintf("%s (run %d)", t.name, t.runNum)
+	}
+	return fmt.Sprintf("%d test runs not run, as they would not finish within --max-run-duration:\n%s",
+		len(runs), strings.Join(runs, "\n"))
+}
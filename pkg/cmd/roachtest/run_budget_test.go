@@ -0,0 +1,57 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/spec"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDurationBudget(t *testing.T) {
+	test := registry.TestSpec{
+		Name:    "foo",
+		Owner:   registry.OwnerKV,
+		Cluster: spec.MakeClusterSpec(4),
+		Timeout: time.Hour,
+	}
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// No limit.
+	b := newDurationBudget(start, 0, spec.GCE)
+	require.True(t, b.admit(&test, 1, start.Add(100*time.Hour), false /* force */))
+	require.Empty(t, b.summary())
+
+	b = newDurationBudget(start, 3*time.Hour, spec.GCE)
+	require.True(t, b.admit(&test, 1, start, false /* force */))
+	require.True(t, b.admit(&test, 2, start.Add(time.Hour), false /* force */))
+	// The run would finish in time, but not its teardown.
+	require.False(t, b.admit(&test, 4, start.Add(2*time.Hour-windDownMargin/2), false /* force */))
+	require.False(t, b.admit(&test, 3, start.Add(3*time.Hour), false /* force */))
+	// Retries are always run.
+	require.True(t, b.admit(&test, 2, start.Add(3*time.Hour), true /* force */))
+
+	require.Equal(t, []notRunTest{
+		{name: "foo", owner: string(registry.OwnerKV), runNum: 3},
+		{name: "foo", owner: string(registry.OwnerKV), runNum: 4},
+	}, b.notRunTests())
+	summary := b.summary()
+	require.Contains(t, summary, "2 test runs not run")
+	require.Contains(t, summary, "foo (run 3)\nfoo (run 4)")
+
+	// Timeouts are scaled by 2x on local clusters.
+	b = newDurationBudget(start, 3*time.Hour, spec.Local)
+	require.True(t, b.admit(&test, 1, start, false /* force */))
+	require.False(t, b.admit(&test, 2, start.Add(time.Hour), false /* force */))
+}
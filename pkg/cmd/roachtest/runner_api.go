@@ -0,0 +1,297 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// The runner API exposes the state of the test runner, and operations to
+// control it, as JSON over the runner's HTTP server (see --port), so that CI
+// wrappers and TUIs can supervise runs programmatically. Since the operations
+// interfere with the run, the API only serves requests from localhost, made to
+// a localhost address (which defeats DNS rebinding). Requests from browsers
+// which change the state of the runner must also come from the runner's own
+// origin, so that other web pages can't issue them.
+//
+//	GET  /api/v1/state                                 queued, running and completed tests, and workers
+//	POST /api/v1/cancel?test=<name>[&run=<n>]          fail and cancel a running test
+//	POST /api/v1/extend-timeout?test=<name>[&run=<n>]&by=<duration>
+//	                                                   extend the timeout of a running test
+//	POST /api/v1/skip-remaining                        don't start any more tests
+const runnerAPIPrefix = "/api/v1/"
+
+// apiQueuedTest is a test that has runs left to start.
+type apiQueuedTest struct {
+	Test string `json:"test"`
+	Runs int    `json:"runs"`
+}
+
+// apiRunningTest is a run of a test in progress.
+type apiRunningTest struct {
+	Test    string    `json:"test"`
+	Run     int       `json:"run"`
+	Attempt int       `json:"attempt"`
+	Start   time.Time `json:"start"`
+	Status  string    `json:"status"`
+}
+
+// apiCompletedTest is a finished run of a test.
+type apiCompletedTest struct {
+	Test    string    `json:"test"`
+	Run     int       `json:"run"`
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Pass    bool      `json:"pass"`
+	Failure string    `json:"failure,omitempty"`
+}
+
+// apiWorker is the status of a worker, and of the test it is running if any.
+type apiWorker struct {
+	Name    string `json:"name"`
+	Status  string `json:"status"`
+	Test    string `json:"test,omitempty"`
+	Run     int    `json:"run,omitempty"`
+	Cluster string `json:"cluster,omitempty"`
+}
+
+// apiRunnerState is the response to GET /api/v1/state.
+type apiRunnerState struct {
+	Queued    []apiQueuedTest    `json:"queued"`
+	Running   []apiRunningTest   `json:"running"`
+	Completed []apiCompletedTest `json:"completed"`
+	Workers   []apiWorker        `json:"workers"`
+}
+
+// serveAPI is the handler for the runner API.
+func (r *testRunner) serveAPI(wr http.ResponseWriter, req *http.Request) {
+	if host, _, err := net.SplitHostPort(req.RemoteAddr); err != nil || !net.ParseIP(host).IsLoopback() {
+		http.Error(wr, "the runner API is only available from localhost", http.StatusForbidden)
+		return
+	}
+	if !isLoopbackHost(req.Host) {
+		http.Error(wr, "the runner API is only available at a localhost address", http.StatusForbidden)
+		return
+	}
+
+	endpoint := req.URL.Path[len(runnerAPIPrefix):]
+	method := http.MethodPost
+	if endpoint == "state" {
+		method = http.MethodGet
+	}
+	if req.Method != method {
+		http.Error(wr, fmt.Sprintf("%s %s not allowed", req.Method, req.URL.Path), http.StatusMethodNotAllowed)
+		return
+	}
+	if origin := req.Header.Get("Origin"); method == http.MethodPost && origin != "" {
+		if u, err := url.Parse(origin); err != nil || u.Host != req.Host {
+			http.Error(wr, fmt.Sprintf("cross-origin requests from %s are not allowed", origin), http.StatusForbidden)
+			return
+		}
+	}
+
+	var res interface{}
+	var err error
+	switch endpoint {
+	case "state":
+		res = r.apiState()
+	case "cancel":
+		res, err = r.apiCancel(req)
+	case "extend-timeout":
+		res, err = r.apiExtendTimeout(req)
+	case "skip-remaining":
+		res = map[string]int{"skipped": r.skipRemaining()}
+	default:
+		http.NotFound(wr, req)
+		return
+	}
+	if err != nil {
+		http.Error(wr, err.Error(), http.StatusBadRequest)
+		return
+	}
+	wr.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(wr).Encode(res); err != nil {
+		http.Error(wr, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// isLoopbackHost returns whether the given host, with an optional port, is
+// localhost or a loopback IP.
+func isLoopbackHost(hostPort string) bool {
+	host, _, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		host = hostPort
+	}
+	host = strings.Trim(host, "[]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// apiState returns the current state of the runner.
+func (r *testRunner) apiState() apiRunnerState {
+	state := apiRunnerState{
+		Queued:    []apiQueuedTest{},
+		Running:   []apiRunningTest{},
+		Completed: []apiCompletedTest{},
+		Workers:   []apiWorker{},
+	}
+	for _, t := range r.work.workRemaining() {
+		state.Queued = append(state.Queued, apiQueuedTest{Test: t.spec.Name, Runs: t.count})
+	}
+	for _, t := range r.runningTests() {
+		state.Running = append(state.Running, apiRunningTest{
+			Test:    t.Name(),
+			Run:     t.runNum,
+			Attempt: t.attempt,
+			Start:   t.start,
+			Status:  t.GetStatus(),
+		})
+	}
+	for _, t := range r.getCompletedTests() {
+		state.Completed = append(state.Completed, apiCompletedTest{
+			Test:    t.test,
+			Run:     t.run,
+			Start:   t.start,
+			End:     t.end,
+			Pass:    t.pass,
+			Failure: t.failure,
+		})
+	}
+
+	r.workersMu.Lock()
+	for _, w := range r.workersMu.workers {
+		worker := apiWorker{Name: w.name, Status: w.Status()}
+		if t := w.Test(); t != nil {
+			worker.Test, worker.Run = t.Name(), t.runNum
+		}
+		if c := w.Cluster(); c != nil {
+			worker.Cluster = c.name
+		}
+		state.Workers = append(state.Workers, worker)
+	}
+	r.workersMu.Unlock()
+	sort.Slice(state.Workers, func(i, j int) bool {
+		return state.Workers[i].Name < state.Workers[j].Name
+	})
+	return state
+}
+
+// runningTests returns the tests currently running, ordered by name and run.
+func (r *testRunner) runningTests() []*testImpl {
+	r.status.Lock()
+	tests := make([]*testImpl, 0, len(r.status.running))
+	for t := range r.status.running {
+		tests = append(tests, t)
+	}
+	r.status.Unlock()
+	sort.Slice(tests, func(i, j int) bool {
+		if tests[i].Name() != tests[j].Name() {
+			return tests[i].Name() < tests[j].Name()
+		}
+		return tests[i].runNum < tests[j].runNum
+	})
+	return tests
+}
+
+// findRunningTest returns the running test identified by the test and
+// (optional) run query parameters of the request.
+func (r *testRunner) findRunningTest(req *http.Request) (*testImpl, error) {
+	name := req.URL.Query().Get("test")
+	if name == "" {
+		return nil, errors.New("missing test parameter")
+	}
+	run := 0
+	if s := req.URL.Query().Get("run"); s != "" {
+		var err error
+		if run, err = strconv.Atoi(s); err != nil {
+			return nil, errors.Wrap(err, "invalid run parameter")
+		}
+	}
+	var found *testImpl
+	for _, t := range r.runningTests() {
+		if t.Name() != name || (run != 0 && t.runNum != run) {
+			continue
+		}
+		if found != nil {
+			return nil, errors.Newf("several runs of %s are running; specify the run parameter", name)
+		}
+		found = t
+	}
+	if found == nil {
+		return nil, errors.Newf("%s is not running", name)
+	}
+	return found, nil
+}
+
+// apiCancel fails the requested running test and cancels its context.
+func (r *testRunner) apiCancel(req *http.Request) (interface{}, error) {
+	t, err := r.findRunningTest(req)
+	if err != nil {
+		return nil, err
+	}
+	t.Errorf("test canceled through the runner API")
+	return apiRunningTest{Test: t.Name(), Run: t.runNum, Attempt: t.attempt, Start: t.start}, nil
+}
+
+// skippedRemainingReason is the reason reported for the test runs which were
+// not started because of the skip-remaining operation of the runner API.
+const skippedRemainingReason = "not run: remaining tests skipped through the runner API"
+
+// skipRemaining removes the remaining tests from the work pool, and records
+// their runs so that they are reported as not run. Returns the number of runs
+// that were skipped.
+func (r *testRunner) skipRemaining() int {
+	skipped := r.work.skipRemaining()
+	r.apiSkipped.Lock()
+	defer r.apiSkipped.Unlock()
+	r.apiSkipped.tests = append(r.apiSkipped.tests, skipped...)
+	return len(skipped)
+}
+
+// skippedTests returns the test runs skipped through the runner API.
+func (r *testRunner) skippedTests() []notRunTest {
+	r.apiSkipped.Lock()
+	defer r.apiSkipped.Unlock()
+	return append([]notRunTest(nil), r.apiSkipped.tests...)
+}
+
+// apiExtendTimeout extends the timeout of the requested running test.
+func (r *testRunner) apiExtendTimeout(req *http.Request) (interface{}, error) {
+	t, err := r.findRunningTest(req)
+	if err != nil {
+		return nil, err
+	}
+	by, err := time.ParseDuration(req.URL.Query().Get("by"))
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid by parameter")
+	}
+	if by <= 0 {
+		return nil, errors.Newf("timeout extension must be positive, got %s", by)
+	}
+	if err := t.extendTimeout(by); err != nil {
+		return nil, err
+	}
+	t.L().Printf("timeout extended by %s through the runner API", by)
+	return map[string]string{"test": t.Name(), "extendedBy": by.String()}, nil
+}
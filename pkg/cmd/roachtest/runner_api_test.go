@@ -0,0 +1,134 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunnerAPI(t *testing.T) {
+	r := newTestRunner(nil /* cr */, nil /* stopper */)
+	r.work = newWorkPool([]registry.TestSpec{{Name: "queued"}}, 2 /* count */)
+	r.status.running = map[*testImpl]struct{}{}
+	running := &testImpl{
+		spec:              &registry.TestSpec{Name: "running"},
+		runNum:            1,
+		l:                 nilLogger(),
+		timeoutExtensions: make(chan time.Duration, 1),
+	}
+	r.status.running[running] = struct{}{}
+
+	const runnerHost = "localhost:8080"
+	requestWithHeaders := func(
+		method, url, remoteAddr, host string, headers map[string]string,
+	) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, nil)
+		req.RemoteAddr = remoteAddr
+		req.Host = host
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		rec := httptest.NewRecorder()
+		r.serveAPI(rec, req)
+		return rec
+	}
+	request := func(method, url, remoteAddr string) *httptest.ResponseRecorder {
+		return requestWithHeaders(method, url, remoteAddr, runnerHost, nil)
+	}
+	const localhost = "127.0.0.1:12345"
+
+	t.Run("localhost only", func(t *testing.T) {
+		rec := request(http.MethodGet, "/api/v1/state", "10.1.2.3:12345")
+		require.Equal(t, http.StatusForbidden, rec.Code)
+		// A localhost request to another host name, e.g. through DNS rebinding.
+		rec = requestWithHeaders(http.MethodGet, "/api/v1/state", localhost, "evil.example.com:8080", nil)
+		require.Equal(t, http.StatusForbidden, rec.Code)
+		rec = requestWithHeaders(http.MethodGet, "/api/v1/state", localhost, "[::1]:8080", nil)
+		require.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("same origin only", func(t *testing.T) {
+		rec := requestWithHeaders(http.MethodPost, "/api/v1/skip-remaining", localhost, runnerHost,
+			map[string]string{"Origin": "https://evil.example.com"})
+		require.Equal(t, http.StatusForbidden, rec.Code)
+		require.Len(t, r.work.workRemaining(), 1)
+		// Reading the state doesn't change the run.
+		rec = requestWithHeaders(http.MethodGet, "/api/v1/state", localhost, runnerHost,
+			map[string]string{"Origin": "https://evil.example.com"})
+		require.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("state", func(t *testing.T) {
+		rec := request(http.MethodGet, "/api/v1/state", localhost)
+		require.Equal(t, http.StatusOK, rec.Code)
+		var state apiRunnerState
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &state))
+		require.Equal(t, []apiQueuedTest{{Test: "queued", Runs: 2}}, state.Queued)
+		require.Len(t, state.Running, 1)
+		require.Equal(t, "running", state.Running[0].Test)
+		require.Empty(t, state.Completed)
+
+		rec = request(http.MethodPost, "/api/v1/state", localhost)
+		require.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	})
+
+	t.Run("extend timeout", func(t *testing.T) {
+		rec := request(http.MethodPost, "/api/v1/extend-timeout?test=running&by=30m", localhost)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		require.Equal(t, 30*time.Minute, <-running.timeoutExtensions)
+
+		rec = request(http.MethodPost, "/api/v1/extend-timeout?test=running&by=-1m", localhost)
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+		rec = request(http.MethodPost, "/api/v1/extend-timeout?test=queued&by=1m", localhost)
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+
+		// Once the runner stopped waiting for the test, its timeout is final.
+		running.finalizeTimeout()
+		rec = request(http.MethodPost, "/api/v1/extend-timeout?test=running&by=1m", localhost)
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+		require.Empty(t, running.timeoutExtensions)
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		rec := request(http.MethodPost, "/api/v1/cancel?test=running&run=2", localhost)
+		require.Equal(t, http.StatusBadRequest, rec.Code)
+		require.False(t, running.Failed())
+
+		rec = request(http.MethodPost, "/api/v1/cancel?test=running", localhost)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		require.True(t, running.Failed())
+	})
+
+	t.Run("skip remaining", func(t *testing.T) {
+		rec := request(http.MethodPost, "/api/v1/skip-remaining", localhost)
+		require.Equal(t, http.StatusOK, rec.Code)
+		require.JSONEq(t, `{"skipped": 2}`, rec.Body.String())
+		require.Empty(t, r.work.workRemaining())
+		// The skipped runs are reported as not run.
+		var notRun []testReportEntry
+		for _, e := range collectTestReport(r) {
+			if e.Status == reportStatusNotRun {
+				notRun = append(notRun, e)
+			}
+		}
+		require.Equal(t, []testReportEntry{
+			{Name: "queued", Status: reportStatusNotRun, Reason: skippedRemainingReason},
+			{Name: "queued", Status: reportStatusNotRun, Reason: skippedRemainingReason},
+		}, notRun)
+	})
+}
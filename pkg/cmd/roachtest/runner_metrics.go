@@ -0,0 +1,167 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/roachprod/install"
+	"github.com/cockroachdb/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// runnerMetricsNamespace is the namespace of the metrics of the test runner,
+// which tell apart its metrics from those registered by the tests.
+const runnerMetricsNamespace = "roachtest_runner"
+
+// runnerMetrics are Prometheus metrics about the infrastructure used by the
+// test runner, exposed on --prom-port along with the metrics registered by
+// tests, so that its health can be monitored over time. All methods are no-ops
+// on a nil runnerMetrics.
+type runnerMetrics struct {
+	// cloud is the value of the cloud label, i.e. the cloud of the clusters
+	// created by the runner. Clusters are only ever created in the cloud
+	// given by --cloud, so all the metrics of a runner share the label.
+	cloud string
+
+	queueDepth             prometheus.Gauge
+	clusterCreateDuration  *prometheus.HistogramVec
+	clusterDestroyDuration *prometheus.HistogramVec
+	clusterWipeDuration    *prometheus.HistogramVec
+	artifactsDuration      prometheus.Histogram
+	cloudAPIErrors         *prometheus.CounterVec
+}
+
+// clusterOpBuckets are the buckets of the cluster creation and destruction
+// latencies, which range from a few seconds to tens of minutes.
+var clusterOpBuckets = prometheus.ExponentialBuckets(5, 2, 10) // 5s to ~43m
+
+// errCloudAPI marks the errors returned by the cloud provider, e.g. when it
+// fails to create VMs, as opposed to errors running commands on the VMs.
+var errCloudAPI = errors.New("cloud provider error")
+
+// markCloudAPIError marks the given error as returned by the cloud provider.
+func markCloudAPIError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return errors.Mark(err, errCloudAPI)
+}
+
+// newRunnerMetrics registers the runner metrics with the given factory, with
+// the given cloud label.
+func newRunnerMetrics(factory promauto.Factory, cloud string) *runnerMetrics {
+	factory.NewCounterFunc(prometheus.CounterOpts{
+		Namespace: runnerMetricsNamespace,
+		Name:      "ssh_retries_total",
+		Help:      "Number of remote commands and file transfers retried after transient errors.",
+	}, func() float64 { return float64(install.Retries()) })
+
+	return &runnerMetrics{
+		cloud: cloud,
+		queueDepth: factory.NewGauge(prometheus.GaugeOpts{
+			Namespace: runnerMetricsNamespace,
+			Name:      "test_queue_depth",
+			Help:      "Number of test runs that have yet to start.",
+		}),
+		clusterCreateDuration: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: runnerMetricsNamespace,
+			Name:      "cluster_create_duration_seconds",
+			Help:      "Time to create (or reuse) a cluster for a test, by cloud and result.",
+			Buckets:   clusterOpBuckets,
+		}, []string{"cloud", "result"}),
+		clusterDestroyDuration: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: runnerMetricsNamespace,
+			Name:      "cluster_destroy_duration_seconds",
+			Help:      "Time to destroy a cluster, by cloud and result.",
+			Buckets:   clusterOpBuckets,
+		}, []string{"cloud", "result"}),
+		clusterWipeDuration: factory.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: runnerMetricsNamespace,
+			Name:      "cluster_wipe_duration_seconds",
+			Help:      "Time to wipe a cluster, to reuse it or release it, by cloud and result.",
+			Buckets:   clusterOpBuckets,
+		}, []string{"cloud", "result"}),
+		artifactsDuration: factory.NewHistogram(prometheus.HistogramOpts{
+			Namespace: runnerMetricsNamespace,
+			Name:      "artifact_collection_duration_seconds",
+			Help:      "Time to collect the artifacts of a failed test.",
+			Buckets:   prometheus.ExponentialBuckets(1, 2, 13), // 1s to ~68m
+		}),
+		cloudAPIErrors: factory.NewCounterVec(prometheus.CounterOpts{
+			Namespace: runnerMetricsNamespace,
+			Name:      "cloud_api_errors_total",
+			Help:      "Number of failed cloud provider operations, by cloud and operation.",
+		}, []string{"cloud", "operation"}),
+	}
+}
+
+// resultLabel returns the value of the result label for an operation which
+// returned the given error.
+func resultLabel(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
+
+// setQueueDepth records the number of test runs that have yet to start.
+func (m *runnerMetrics) setQueueDepth(runs int) {
+	if m == nil {
+		return
+	}
+	m.queueDepth.Set(float64(runs))
+}
+
+// recordClusterCreation records the duration of the creation of a cluster,
+// and whether it failed. Only the failures of the cloud provider, see
+// markCloudAPIError, are counted as cloud API errors.
+func (m *runnerMetrics) recordClusterCreation(duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.clusterCreateDuration.WithLabelValues(m.cloud, resultLabel(err)).Observe(duration.Seconds())
+	if errors.Is(err, errCloudAPI) {
+		m.cloudAPIErrors.WithLabelValues(m.cloud, "create").Inc()
+	}
+}
+
+// recordClusterDestruction records the duration of the destruction of a
+// cluster, and whether it failed.
+func (m *runnerMetrics) recordClusterDestruction(duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.clusterDestroyDuration.WithLabelValues(m.cloud, resultLabel(err)).Observe(duration.Seconds())
+	if err != nil {
+		m.cloudAPIErrors.WithLabelValues(m.cloud, "destroy").Inc()
+	}
+}
+
+// recordClusterWipe records the duration of the wipe of a cluster, and whether
+// it failed. Wipes run commands on the nodes over SSH, so their failures are
+// only counted by the result label, not as cloud API errors.
+func (m *runnerMetrics) recordClusterWipe(duration time.Duration, err error) {
+	if m == nil {
+		return
+	}
+	m.clusterWipeDuration.WithLabelValues(m.cloud, resultLabel(err)).Observe(duration.Seconds())
+}
+
+// recordArtifactCollection records the duration of the collection of the
+// artifacts of a test.
+func (m *runnerMetrics) recordArtifactCollection(duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.artifactsDuration.Observe(duration.Seconds())
+}
@@ -0,0 +1,65 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunnerMetrics(t *testing.T) {
+	// All methods are no-ops on nil metrics.
+	var m *runnerMetrics
+	m.setQueueDepth(1)
+	m.recordClusterCreation(time.Second, nil)
+	m.recordClusterDestruction(time.Second, nil)
+	m.recordArtifactCollection(time.Second)
+
+	reg := prometheus.NewRegistry()
+	m = newRunnerMetrics(promauto.With(reg), "gce")
+	m.setQueueDepth(7)
+	m.recordClusterCreation(time.Minute, nil)
+	m.recordClusterCreation(time.Minute, markCloudAPIError(errors.New("quota exceeded")))
+	// Errors which were not returned by the cloud provider, e.g. failures to
+	// set up the VMs, are not cloud API errors.
+	m.recordClusterCreation(time.Minute, errors.New("ssh: connection refused"))
+	m.recordClusterDestruction(30*time.Second, errors.New("boom"))
+	m.recordArtifactCollection(time.Minute)
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	samples := make(map[string]uint64)
+	for _, f := range families {
+		for _, metric := range f.GetMetric() {
+			switch {
+			case metric.Gauge != nil:
+				samples[f.GetName()] += uint64(metric.Gauge.GetValue())
+			case metric.Counter != nil:
+				samples[f.GetName()] += uint64(metric.Counter.GetValue())
+			case metric.Histogram != nil:
+				samples[f.GetName()] += metric.Histogram.GetSampleCount()
+			}
+		}
+	}
+	require.Equal(t, map[string]uint64{
+		"roachtest_runner_test_queue_depth":                     7,
+		"roachtest_runner_ssh_retries_total":                    0,
+		"roachtest_runner_cluster_create_duration_seconds":      3,
+		"roachtest_runner_cluster_destroy_duration_seconds":     1,
+		"roachtest_runner_artifact_collection_duration_seconds": 1,
+		"roachtest_runner_cloud_api_errors_total":               2,
+	}, samples)
+}
@@ -0,0 +1,338 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/logger"
+	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	shardByHash  = "hash"
+	shardByRange = "range"
+)
+
+// shardSpec describes the subset of the selected tests that a runner is
+// responsible for, when a run is split across multiple runner hosts.
+type shardSpec struct {
+	// index is the 0-based index of the shard, out of count shards.
+	index, count int
+	// strategy is how the tests are assigned to shards; either shardByHash or
+	// shardByRange.
+	strategy string
+}
+
+// parseShardSpec parses the --shard and --shard-strategy flags. The shard is
+// specified as <index>/<count>, with a 1-based index. An empty shard means
+// that all the tests are run.
+func parseShardSpec(shard, strategy string) (shardSpec, error) {
+	if strategy != shardByHash && strategy != shardByRange {
+		return shardSpec{}, errors.Newf("unknown shard strategy %q", strategy)
+	}
+	if shard == "" {
+		return shardSpec{index: 0, count: 1, strategy: strategy}, nil
+	}
+	indexStr, countStr, ok := strings.Cut(shard, "/")
+	if !ok {
+		return shardSpec{}, errors.Newf("invalid shard %q; expected <index>/<count>", shard)
+	}
+	index, err := strconv.Atoi(indexStr)
+	if err != nil {
+		return shardSpec{}, errors.Wrapf(err, "invalid shard index in %q", shard)
+	}
+	count, err := strconv.Atoi(countStr)
+	if err != nil {
+		return shardSpec{}, errors.Wrapf(err, "invalid shard count in %q", shard)
+	}
+	if count < 1 || index < 1 || index > count {
+		return shardSpec{}, errors.Newf("invalid shard %q; index must be in [1, count]", shard)
+	}
+	return shardSpec{index: index - 1, count: count, strategy: strategy}, nil
+}
+
+func (s shardSpec) String() string {
+	return fmt.Sprintf("%d/%d (by %s)", s.index+1, s.count, s.strategy)
+}
+
+// filter returns the tests which belong to the shard. The tests are assigned
+// to shards deterministically, so that runners given the same tests and
+// different shard indexes run disjoint subsets of them.
+func (s shardSpec) filter(tests []registry.TestSpec) []registry.TestSpec {
+	if s.count <= 1 {
+		return tests
+	}
+	var res []registry.TestSpec
+	switch s.strategy {
+	case shardByHash:
+		for _, t := range tests {
+			h := fnv.New32a()
+			_, _ = h.Write([]byte(t.Name))
+			if int(h.Sum32()%uint32(s.count)) == s.index {
+				res = append(res, t)
+			}
+		}
+	case shardByRange:
+		names := make([]string, 0, len(tests))
+		for _, t := range tests {
+			names = append(names, t.Name)
+		}
+		slices.Sort(names)
+		n := len(names)
+		names = names[s.index*n/s.count : (s.index+1)*n/s.count]
+		for _, t := range tests {
+			if _, found := slices.BinarySearch(names, t.Name); found {
+				res = append(res, t)
+			}
+		}
+	}
+	return res
+}
+
+// testManifest is a directory shared by all the runners of a sharded run,
+// e.g. on a network file system. A runner claims each test run in the
+// manifest before starting it, and records its result once it is done. Runners
+// don't start test runs claimed by another runner, so shards never overlap even
+// if their test assignments do, and a restarted runner skips the test runs
+// that have already completed.
+//
+// A runner heartbeats the claims it holds, see startHeartbeats. A claim which
+// wasn't completed nor heartbeated for longer than the TTL of the manifest is
+// stale, e.g. because its runner crashed, and can be claimed by another runner.
+//
+// Entries are never rewritten in place: they are written to a temporary file
+// which is then linked or renamed into place, so that other runners never read
+// a partially written entry, and heartbeats only touch the modification time
+// of the entries, so that they can't overwrite a claim taken over by another
+// runner.
+type testManifest struct {
+	dir    string
+	runner string
+	ttl    time.Duration
+
+	mu struct {
+		syncutil.Mutex
+		// held contains the paths of the claims held by this runner, which are
+		// not completed yet.
+		held map[string]struct{}
+	}
+}
+
+// manifestEntry is the content of the file of a claimed test run.
+type manifestEntry struct {
+	Runner  string    `json:"runner"`
+	Claimed time.Time `json:"claimed"`
+	// Heartbeat is the last heartbeat of the claim, i.e. the modification
+	// time of its file.
+	Heartbeat time.Time `json:"-"`
+	Status    string    `json:"status,omitempty"`
+	Completed time.Time `json:"completed,omitempty"`
+}
+
+// stale returns whether the claim is neither completed nor heartbeated within
+// the given TTL.
+func (e manifestEntry) stale(ttl time.Duration) bool {
+	return e.Status == "" && timeutil.Since(e.Heartbeat) > ttl
+}
+
+func newTestManifest(dir string, ttl time.Duration) (*testManifest, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, errors.Wrap(err, "creating test manifest")
+	}
+	hostname, _ := os.Hostname()
+	m := &testManifest{
+		dir:    dir,
+		runner: fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+		ttl:    ttl,
+	}
+	m.mu.held = make(map[string]struct{})
+	return m, nil
+}
+
+func (m *testManifest) path(testName string, runNum int) string {
+	return filepath.Join(m.dir, fmt.Sprintf("%s.run_%d.json", url.PathEscape(testName), runNum))
+}
+
+// claim claims the given test run for this runner. Returns false if the run
+// was already claimed, either by this runner or by another one, unless the
+// claim is stale, in which case it is taken over. A claim which can't be read,
+// e.g. because it's being replaced, is considered held by another runner.
+func (m *testManifest) claim(testName string, runNum int) (bool, error) {
+	path := m.path(testName, runNum)
+	claimed, err := m.create(path)
+	if err == nil && !claimed {
+		claimed, err = m.reclaim(path)
+	}
+	if err != nil {
+		return false, errors.Wrapf(err, "claiming %s run %d", testName, runNum)
+	}
+	if claimed {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.mu.held[path] = struct{}{}
+	}
+	return claimed, nil
+}
+
+// create creates the claim at the given path. Returns false if it exists. The
+// claim is written to a temporary file which is then linked into place, which
+// fails if the claim exists.
+func (m *testManifest) create(path string) (bool, error) {
+	tmp, err := m.writeTemp(path, manifestEntry{Runner: m.runner, Claimed: timeutil.Now()})
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmp)
+	if err := os.Link(tmp, path); err != nil {
+		if os.IsExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// reclaim takes over the claim at the given path if it is stale. The claim is
+// first moved aside, which only one of the runners racing to take it over
+// succeeds at, and then created anew.
+func (m *testManifest) reclaim(path string) (bool, error) {
+	if e, err := readManifestEntry(path); err != nil || !e.stale(m.ttl) {
+		return false, nil
+	}
+	tombstone := fmt.Sprintf("%s.stale.%s", path, m.runner)
+	if err := os.Rename(path, tombstone); err != nil {
+		if os.IsNotExist(err) {
+			// Another runner took over the claim.
+			return false, nil
+		}
+		return false, err
+	}
+	defer os.Remove(tombstone)
+	// Renaming preserves the modification time of the claim, i.e. its
+	// heartbeat.
+	if e, err := readManifestEntry(tombstone); err != nil || !e.stale(m.ttl) {
+		// Another runner took over the claim in the meantime, and we moved its
+		// fresh claim aside. Put it back, unless yet another runner claimed it.
+		if err := os.Link(tombstone, path); err != nil && !os.IsExist(err) {
+			return false, err
+		}
+		return false, nil
+	}
+	return m.create(path)
+}
+
+// complete records the final status of a test run claimed by this runner.
+func (m *testManifest) complete(testName string, runNum int, status string) error {
+	path := m.path(testName, runNum)
+	m.mu.Lock()
+	delete(m.mu.held, path)
+	m.mu.Unlock()
+	e, err := readManifestEntry(path)
+	if err != nil {
+		return errors.Wrapf(err, "reading manifest entry of %s run %d", testName, runNum)
+	}
+	if e.Runner != m.runner {
+		return errors.Newf("%s run %d is claimed by %s", testName, runNum, e.Runner)
+	}
+	e.Status, e.Completed = status, timeutil.Now()
+	tmp, err := m.writeTemp(path, e)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// heartbeat refreshes the heartbeat of the claims held by this runner, by
+// touching their files. Claims which were taken over by another runner, after
+// this runner failed to heartbeat them in time, are no longer held. If a claim
+// is taken over right before it's touched, the heartbeat only refreshes the
+// new claim, and the next heartbeat finds out that it's no longer held.
+func (m *testManifest) heartbeat() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var errs error
+	for path := range m.mu.held {
+		e, err := readManifestEntry(path)
+		if os.IsNotExist(err) || (err == nil && e.Runner != m.runner) {
+			// The claim is being, or was, taken over.
+			delete(m.mu.held, path)
+			continue
+		}
+		if err == nil {
+			now := timeutil.Now()
+			err = os.Chtimes(path, now, now)
+		}
+		errs = errors.CombineErrors(errs, err)
+	}
+	return errs
+}
+
+// startHeartbeats heartbeats the claims held by this runner every third of the
+// TTL of the manifest, until the returned function is called.
+func (m *testManifest) startHeartbeats(l *logger.Logger) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(m.ttl / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if err := m.heartbeat(); err != nil {
+					l.Printf("failed to heartbeat test manifest claims: %s", err)
+				}
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+func readManifestEntry(path string) (manifestEntry, error) {
+	var e manifestEntry
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return e, err
+	}
+	if err := json.Unmarshal(data, &e); err != nil {
+		return e, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return e, err
+	}
+	e.Heartbeat = info.ModTime()
+	return e, nil
+}
+
+// writeTemp writes the given entry to a temporary file of this runner next to
+// the given path, to be linked or renamed into place, and returns its path.
+func (m *testManifest) writeTemp(path string, e manifestEntry) (string, error) {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return "", err
+	}
+	tmp := fmt.Sprintf("%s.%s.tmp", path, m.runner)
+	return tmp, os.WriteFile(tmp, append(data, '\n'), 0644)
+}
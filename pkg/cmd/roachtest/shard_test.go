@@ -0,0 +1,130 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseShardSpec(t *testing.T) {
+	s, err := parseShardSpec("", shardByHash)
+	require.NoError(t, err)
+	require.Equal(t, shardSpec{index: 0, count: 1, strategy: shardByHash}, s)
+
+	s, err = parseShardSpec("2/4", shardByRange)
+	require.NoError(t, err)
+	require.Equal(t, shardSpec{index: 1, count: 4, strategy: shardByRange}, s)
+
+	for _, tc := range []struct{ shard, strategy, expErr string }{
+		{"1/2", "random", `unknown shard strategy "random"`},
+		{"2", shardByHash, "expected <index>/<count>"},
+		{"a/2", shardByHash, "invalid shard index"},
+		{"1/b", shardByHash, "invalid shard count"},
+		{"0/2", shardByHash, "index must be in [1, count]"},
+		{"3/2", shardByHash, "index must be in [1, count]"},
+	} {
+		_, err := parseShardSpec(tc.shard, tc.strategy)
+		require.ErrorContains(t, err, tc.expErr, "shard %q", tc.shard)
+	}
+}
+
+func TestShardSpecFilter(t *testing.T) {
+	var tests []registry.TestSpec
+	for i := 0; i < 50; i++ {
+		tests = append(tests, registry.TestSpec{Name: fmt.Sprintf("test-%d", i)})
+	}
+	for _, strategy := range []string{shardByHash, shardByRange} {
+		t.Run(strategy, func(t *testing.T) {
+			const count = 4
+			seen := map[string]int{}
+			for i := 0; i < count; i++ {
+				shard := shardSpec{index: i, count: count, strategy: strategy}
+				for _, test := range shard.filter(tests) {
+					seen[test.Name]++
+				}
+			}
+			require.Len(t, seen, len(tests))
+			for name, n := range seen {
+				require.Equal(t, 1, n, "test %s in %d shards", name, n)
+			}
+		})
+	}
+}
+
+func TestTestManifest(t *testing.T) {
+	dir := t.TempDir()
+	m1, err := newTestManifest(dir, time.Hour)
+	require.NoError(t, err)
+	m2, err := newTestManifest(dir, time.Hour)
+	require.NoError(t, err)
+	m2.runner = "other"
+
+	claimed, err := m1.claim("kv/splits", 1)
+	require.NoError(t, err)
+	require.True(t, claimed)
+	claimed, err = m2.claim("kv/splits", 1)
+	require.NoError(t, err)
+	require.False(t, claimed)
+	claimed, err = m2.claim("kv/splits", 2)
+	require.NoError(t, err)
+	require.True(t, claimed)
+
+	require.ErrorContains(t, m2.complete("kv/splits", 1, reportStatusSuccess), "is claimed by")
+	require.NoError(t, m1.complete("kv/splits", 1, reportStatusFailure))
+
+	data, err := os.ReadFile(m1.path("kv/splits", 1))
+	require.NoError(t, err)
+	var e manifestEntry
+	require.NoError(t, json.Unmarshal(data, &e))
+	require.Equal(t, m1.runner, e.Runner)
+	require.Equal(t, reportStatusFailure, e.Status)
+
+	// A claim which is not heartbeated past the TTL is taken over, unless it
+	// was completed.
+	require.NoError(t, m2.heartbeat())
+	m3, err := newTestManifest(dir, time.Minute)
+	require.NoError(t, err)
+	m3.runner = "third"
+	claimed, err = m3.claim("kv/splits", 2)
+	require.NoError(t, err)
+	require.False(t, claimed)
+	path := m2.path("kv/splits", 2)
+	old := timeutil.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(path, old, old))
+	claimed, err = m3.claim("kv/splits", 2)
+	require.NoError(t, err)
+	require.True(t, claimed)
+	claimed, err = m3.claim("kv/splits", 1)
+	require.NoError(t, err)
+	require.False(t, claimed)
+
+	// The runner which lost its claim stops heartbeating it, and can't
+	// complete it.
+	require.NoError(t, m2.heartbeat())
+	require.Empty(t, m2.mu.held)
+	require.ErrorContains(t, m2.complete("kv/splits", 2, reportStatusSuccess), "is claimed by third")
+	require.NoError(t, m3.complete("kv/splits", 2, reportStatusSuccess))
+
+	// A claim which can't be read, e.g. a partially written one, is held by
+	// another runner.
+	require.NoError(t, os.WriteFile(m1.path("kv/splits", 3), []byte(`{"runner":`), 0644))
+	claimed, err = m1.claim("kv/splits", 3)
+	require.NoError(t, err)
+	require.False(t, claimed)
+}
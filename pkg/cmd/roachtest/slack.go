@@ -56,6 +56,17 @@ func sortTests(tests []*testImpl) {
 	})
 }
 
+// teamCityBuildLink returns a link to the TeamCity build running roachtest, if
+// any.
+func teamCityBuildLink() string {
+	if buildID := os.Getenv("TC_BUILD_ID"); buildID != "" {
+		return fmt.Sprintf("https://teamcity.cockroachdb.com/viewLog.html?"+
+			"buildId=%s&buildTypeId=Cockroach_Nightlies_WorkloadNightly",
+			buildID)
+	}
+	return ""
+}
+
 func postSlackReport(pass, fail, skip map[*testImpl]struct{}) {
 	client := makeSlackClient()
 	if client == nil {
@@ -88,17 +99,11 @@ func postSlackReport(pass, fail, skip map[*testImpl]struct{}) {
 		if len(fail) > 0 {
 			status = "warning"
 		}
-		var link string
-		if buildID := os.Getenv("TC_BUILD_ID"); buildID != "" {
-			link = fmt.Sprintf("https://teamcity.cockroachdb.com/viewLog.html?"+
-				"buildId=%s&buildTypeId=Cockroach_Nightlies_WorkloadNightly",
-				buildID)
-		}
 		attachments = append(attachments,
 			slack.Attachment{
 				Color:     status,
 				Title:     message,
-				TitleLink: link,
+				TitleLink: teamCityBuildLink(),
 				Fallback:  message,
 			})
 	}
@@ -104,6 +104,17 @@ type ClusterSpec struct {
 	// WorkloadNodeCPUs.
 	WorkloadNode     bool
 	WorkloadNodeCPUs int
+	// WorkloadNodeCount is the number of workload nodes at the end of the
+	// cluster if WorkloadNode is set; zero means a single one.
+	WorkloadNodeCount int
+	// WorkloadNodeRoles optionally contains the role of each workload node, as
+	// a comma-separated list (so that the spec remains comparable). See
+	// WorkloadRoles.
+	WorkloadNodeRoles string
+	// ARM64Nodes is the number of CockroachDB nodes that use arm64 in a
+	// mixed-architecture cluster; they are the last nodes before any workload
+	// nodes, and the other nodes use amd64. See NodeArch.
+	ARM64Nodes int
 	// CPUs is the number of CPUs per node.
 	CPUs                 int
 	Mem                  MemPerCPU
@@ -117,6 +128,8 @@ type ClusterSpec struct {
 	TerminateOnMigration bool
 	// Use a spot instance or equivalent of a cloud provider.
 	UseSpotVMs bool
+	// IPv6 creates the VMs with IPv6 addresses only. Only supported on GCE.
+	IPv6 bool
 	// FileSystem determines the underlying FileSystem
 	// to be used. The default is ext4.
 	FileSystem fileSystemType
@@ -125,6 +138,11 @@ type ClusterSpec struct {
 
 	GatherCores bool
 
+	// StoresPerNode is the number of stores that nodes are started with, each
+	// on its own local SSD (i.e. the disks are not striped). Zero means a
+	// single store. See MultipleStores.
+	StoresPerNode int
+
 	// GCE-specific arguments. These values apply only on clusters instantiated on GCE.
 	GCE struct {
 		MachineType    string
@@ -212,7 +230,12 @@ func awsMachineSupportsSSD(machineType string) bool {
 }
 
 func getAWSOpts(
-	machineType string, zones []string, volumeSize, ebsThroughput int, localSSD bool, useSpotVMs bool,
+	machineType string,
+	zones []string,
+	volumeSize, ebsThroughput int,
+	localSSD bool,
+	multipleDisks bool,
+	useSpotVMs bool,
 ) vm.ProviderOpts {
 	opts := aws.DefaultProviderOpts()
 	if volumeSize != 0 {
@@ -226,6 +249,9 @@ func getAWSOpts(
 	}
 	if localSSD {
 		opts.SSDMachineType = machineType
+		// NB: the instance stores of the machine type are mounted as a single
+		// RAID 0 array unless multiple disks are requested.
+		opts.UseMultipleDisks = multipleDisks
 	} else {
 		opts.MachineType = machineType
 	}
@@ -247,6 +273,7 @@ func getGCEOpts(
 	arch vm.CPUArch,
 	volumeType string,
 	useSpot bool,
+	ipv6Only bool,
 ) vm.ProviderOpts {
 	opts := gce.DefaultProviderOpts()
 	opts.MachineType = machineType
@@ -272,6 +299,7 @@ func getGCEOpts(
 	}
 	opts.TerminateOnMigration = terminateOnMigration
 	opts.UseSpot = useSpot
+	opts.IPv6Only = ipv6Only
 	if volumeType != "" {
 		opts.PDVolumeType = volumeType
 	}
@@ -350,6 +378,20 @@ func (s *ClusterSpec) RoachprodOpts(
 		createVMOpts.Lifetime = s.Lifetime
 	}
 	cloud := params.Cloud
+	if s.ARM64Nodes > 0 {
+		if cloud == Local {
+			return vm.CreateOpts{}, nil, nil, "", errors.Errorf("mixed-architecture clusters are not supported on %s", cloud)
+		}
+		if s.ARM64Nodes >= s.NodeCount-s.NumWorkloadNodes() {
+			return vm.CreateOpts{}, nil, nil, "", errors.Errorf(
+				"mixed-architecture cluster with %d arm64 nodes needs more than %d CockroachDB nodes",
+				s.ARM64Nodes, s.NodeCount-s.NumWorkloadNodes(),
+			)
+		}
+	}
+	if s.IPv6 && cloud != GCE {
+		return vm.CreateOpts{}, nil, nil, "", errors.Errorf("IPv6-only clusters are not supported on %s", cloud)
+	}
 	switch cloud {
 	case Local:
 		createVMOpts.VMProviders = []string{cloud.String()}
@@ -367,10 +409,18 @@ func (s *ClusterSpec) RoachprodOpts(
 			return vm.CreateOpts{}, nil, nil, "", errors.Errorf("specifying SSD count is not yet supported on %s", cloud)
 		}
 	}
+	if s.StoresPerNode > 1 {
+		if s.RAID0 {
+			return vm.CreateOpts{}, nil, nil, "", errors.New("multiple stores per node are incompatible with RAID0")
+		}
+		if cloud == Azure {
+			return vm.CreateOpts{}, nil, nil, "", errors.Errorf("multiple stores per node are not yet supported on %s", cloud)
+		}
+	}
 
 	createVMOpts.GeoDistributed = s.Geo
 	createVMOpts.Arch = string(requestedArch)
-	ssdCount := s.SSDs
+	ssdCount := max(s.SSDs, s.StoresPerNode)
 
 	machineType := params.Defaults.MachineType
 	switch cloud {
@@ -432,6 +482,26 @@ func (s *ClusterSpec) RoachprodOpts(
 		}
 	}
 
+	if s.StoresPerNode > 1 && !createVMOpts.SSDOpts.UseLocalSSD {
+		return vm.CreateOpts{}, nil, nil, "", errors.Errorf(
+			"multiple stores per node require local SSDs, which are not available for machine type %q", machineType,
+		)
+	}
+	if s.StoresPerNode > 1 && cloud == AWS {
+		// Unlike on GCE, where as many local SSDs as stores are requested, the
+		// number of local SSDs on AWS is determined by the machine type. Each
+		// of them is mounted as a store.
+		if n, ok := awsLocalSSDCount(machineType); !ok {
+			return vm.CreateOpts{}, nil, nil, "", errors.Errorf(
+				"unknown number of local SSDs for machine type %q; cannot use multiple stores per node", machineType,
+			)
+		} else if n != s.StoresPerNode {
+			return vm.CreateOpts{}, nil, nil, "", errors.Errorf(
+				"%d stores per node requested, but machine type %q has %d local SSDs", s.StoresPerNode, machineType, n,
+			)
+		}
+	}
+
 	if s.FileSystem == Zfs {
 		if cloud != GCE {
 			return vm.CreateOpts{}, nil, nil, "", errors.Errorf(
@@ -493,17 +563,17 @@ func (s *ClusterSpec) RoachprodOpts(
 	switch cloud {
 	case AWS:
 		providerOpts = getAWSOpts(machineType, zones, s.VolumeSize, s.AWS.VolumeThroughput,
-			createVMOpts.SSDOpts.UseLocalSSD, s.UseSpotVMs)
+			createVMOpts.SSDOpts.UseLocalSSD, s.StoresPerNode > 1, s.UseSpotVMs)
 		workloadProviderOpts = getAWSOpts(workloadMachineType, zones, s.VolumeSize, s.AWS.VolumeThroughput,
-			createVMOpts.SSDOpts.UseLocalSSD, s.UseSpotVMs)
+			createVMOpts.SSDOpts.UseLocalSSD, s.StoresPerNode > 1, s.UseSpotVMs)
 	case GCE:
 		providerOpts = getGCEOpts(machineType, zones, s.VolumeSize, ssdCount,
 			createVMOpts.SSDOpts.UseLocalSSD, s.RAID0, s.TerminateOnMigration,
-			s.GCE.MinCPUPlatform, vm.ParseArch(createVMOpts.Arch), s.GCE.VolumeType, s.UseSpotVMs,
+			s.GCE.MinCPUPlatform, vm.ParseArch(createVMOpts.Arch), s.GCE.VolumeType, s.UseSpotVMs, s.IPv6,
 		)
 		workloadProviderOpts = getGCEOpts(workloadMachineType, zones, s.VolumeSize, ssdCount,
 			createVMOpts.SSDOpts.UseLocalSSD, s.RAID0, s.TerminateOnMigration,
-			s.GCE.MinCPUPlatform, vm.ParseArch(createVMOpts.Arch), s.GCE.VolumeType, s.UseSpotVMs,
+			s.GCE.MinCPUPlatform, vm.ParseArch(createVMOpts.Arch), s.GCE.VolumeType, s.UseSpotVMs, s.IPv6,
 		)
 	case Azure:
 		providerOpts = getAzureOpts(machineType, zones, s.VolumeSize)
@@ -525,8 +595,50 @@ func (s *ClusterSpec) Expiration() time.Time {
 
 // TotalCPUs is the total amount of CPUs allocated for a cluster.
 func (s *ClusterSpec) TotalCPUs() int {
+	n := s.NumWorkloadNodes()
+	return (s.NodeCount-n)*s.CPUs + n*s.WorkloadNodeCPUs
+}
+
+// NumWorkloadNodes returns the number of workload nodes in the cluster.
+func (s *ClusterSpec) NumWorkloadNodes() int {
 	if !s.WorkloadNode {
-		return s.NodeCount * s.CPUs
+		return 0
+	}
+	return max(1, s.WorkloadNodeCount)
+}
+
+// WorkloadRoles returns the role of each workload node, or nil if the roles
+// were not specified.
+func (s *ClusterSpec) WorkloadRoles() []WorkloadRole {
+	if s.WorkloadNodeRoles == "" {
+		return nil
+	}
+	var roles []WorkloadRole
+	for _, r := range strings.Split(s.WorkloadNodeRoles, ",") {
+		roles = append(roles, WorkloadRole(r))
+	}
+	return roles
+}
+
+// NodeArch returns the CPU architecture of the given node in a cluster with the
+// given architecture. Only the arm64 nodes of mixed-architecture clusters
+// differ from the cluster's architecture.
+func (s *ClusterSpec) NodeArch(node int, clusterArch vm.CPUArch) vm.CPUArch {
+	lastCRDBNode := s.NodeCount - s.NumWorkloadNodes()
+	if node > lastCRDBNode-s.ARM64Nodes && node <= lastCRDBNode {
+		return vm.ArchARM64
 	}
-	return (s.NodeCount-1)*s.CPUs + s.WorkloadNodeCPUs
+	return clusterArch
 }
+
+// WorkloadRole is the purpose of a workload node; see WorkloadNodeRoles.
+type WorkloadRole string
+
+const (
+	// LoadGenRole is the role of workload nodes that generate load.
+	LoadGenRole WorkloadRole = "loadgen"
+	// BackupDriverRole is the role of workload nodes that drive backups.
+	BackupDriverRole WorkloadRole = "backup-driver"
+	// ChaosDriverRole is the role of workload nodes that inject failures.
+	ChaosDriverRole WorkloadRole = "chaos-driver"
+)
@@ -13,6 +13,9 @@ package spec
 import (
 	"testing"
 
+	"github.com/cockroachdb/cockroach/pkg/roachprod/vm"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/vm/aws"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/vm/gce"
 	"github.com/stretchr/testify/require"
 )
 
@@ -42,3 +45,76 @@ func TestClustersCompatible(t *testing.T) {
 		require.True(t, ClustersCompatible(s1, s2, AWS))
 	})
 }
+
+func TestMultipleStores(t *testing.T) {
+	params := func(cloud Cloud) RoachprodClusterConfig {
+		return RoachprodClusterConfig{Cloud: cloud}
+	}
+	t.Run("gce", func(t *testing.T) {
+		s := MakeClusterSpec(3, MultipleStores(4))
+		createOpts, providerOpts, _, _, err := s.RoachprodOpts(params(GCE))
+		require.NoError(t, err)
+		require.True(t, createOpts.SSDOpts.UseLocalSSD)
+		gceOpts := providerOpts.(*gce.ProviderOpts)
+		require.Equal(t, 4, gceOpts.SSDCount)
+		require.True(t, gceOpts.UseMultipleDisks)
+	})
+	t.Run("aws", func(t *testing.T) {
+		s := MakeClusterSpec(3, MultipleStores(2), AWSMachineType("m6id.12xlarge"))
+		_, providerOpts, _, _, err := s.RoachprodOpts(params(AWS))
+		require.NoError(t, err)
+		require.True(t, providerOpts.(*aws.ProviderOpts).UseMultipleDisks)
+
+		s = MakeClusterSpec(3, MultipleStores(2), AWSMachineType("m6id.4xlarge"))
+		_, _, _, _, err = s.RoachprodOpts(params(AWS))
+		require.ErrorContains(t, err, `machine type "m6id.4xlarge" has 1 local SSDs`)
+
+		s = MakeClusterSpec(3, MultipleStores(2), AWSMachineType("m7gd.metal"))
+		_, _, _, _, err = s.RoachprodOpts(params(AWS))
+		require.NoError(t, err)
+
+		s = MakeClusterSpec(3, MultipleStores(4), AWSMachineType("m7gd.16xlarge"))
+		_, _, _, _, err = s.RoachprodOpts(params(AWS))
+		require.ErrorContains(t, err, `machine type "m7gd.16xlarge" has 2 local SSDs`)
+	})
+	t.Run("raid0", func(t *testing.T) {
+		s := MakeClusterSpec(3, MultipleStores(2), RAID0(true))
+		_, _, _, _, err := s.RoachprodOpts(params(GCE))
+		require.ErrorContains(t, err, "incompatible with RAID0")
+	})
+	t.Run("no local SSD", func(t *testing.T) {
+		s := MakeClusterSpec(3, MultipleStores(2), VolumeSize(100))
+		_, _, _, _, err := s.RoachprodOpts(params(GCE))
+		require.ErrorContains(t, err, "require local SSDs")
+	})
+}
+
+func TestMixedArch(t *testing.T) {
+	s := MakeClusterSpec(5, MixedArch(2), WorkloadNode())
+	require.Equal(t, vm.ArchAMD64, s.Arch)
+	var archs []vm.CPUArch
+	for n := 1; n <= s.NodeCount; n++ {
+		archs = append(archs, s.NodeArch(n, s.Arch))
+	}
+	require.Equal(t, []vm.CPUArch{
+		vm.ArchAMD64, vm.ArchAMD64, vm.ArchARM64, vm.ArchARM64, vm.ArchAMD64,
+	}, archs)
+
+	_, _, _, _, err := s.RoachprodOpts(RoachprodClusterConfig{Cloud: Local})
+	require.ErrorContains(t, err, "not supported on local")
+	s = MakeClusterSpec(3, MixedArch(3))
+	_, _, _, _, err = s.RoachprodOpts(RoachprodClusterConfig{Cloud: GCE})
+	require.ErrorContains(t, err, "needs more than 3 CockroachDB nodes")
+}
+
+func TestIPv6(t *testing.T) {
+	s := MakeClusterSpec(3, IPv6())
+	for _, cloud := range []Cloud{Local, AWS, Azure} {
+		_, _, _, _, err := s.RoachprodOpts(RoachprodClusterConfig{Cloud: cloud})
+		require.ErrorContains(t, err, "IPv6-only clusters are not supported")
+	}
+	_, providerOpts, workloadProviderOpts, _, err := s.RoachprodOpts(RoachprodClusterConfig{Cloud: GCE})
+	require.NoError(t, err)
+	require.True(t, providerOpts.(*gce.ProviderOpts).IPv6Only)
+	require.True(t, workloadProviderOpts.(*gce.ProviderOpts).IPv6Only)
+}
@@ -12,6 +12,7 @@ package spec
 
 import (
 	"fmt"
+	"strings"
 
 	"github.com/cockroachdb/cockroach/pkg/roachprod/vm"
 	"github.com/cockroachdb/errors"
@@ -117,6 +118,60 @@ func SelectAWSMachineType(
 	return fmt.Sprintf("%s.%s", family, size), selectedArch, nil
 }
 
+// awsLocalSSDCount returns the number of local SSDs (instance store volumes)
+// of the given AWS machine type, or false if it is not known. Only the
+// families with local SSDs which are used by roachtests are known, i.e. the
+// ones selected by SelectAWSMachineType, and i3 and i3en.
+func awsLocalSSDCount(machineType string) (int, bool) {
+	family, size, ok := strings.Cut(machineType, ".")
+	if !ok {
+		return 0, false
+	}
+	switch family {
+	case "m6id", "c6id", "r6id":
+		switch size {
+		case "large", "xlarge", "2xlarge", "4xlarge", "8xlarge":
+			return 1, true
+		case "12xlarge", "16xlarge":
+			return 2, true
+		case "24xlarge", "32xlarge", "metal":
+			return 4, true
+		}
+	case "m7gd", "c7gd", "r7gd":
+		// The Graviton3 families top out at 16xlarge, and their metal
+		// instances have the disks of the 16xlarge ones.
+		switch size {
+		case "medium", "large", "xlarge", "2xlarge", "4xlarge", "8xlarge":
+			return 1, true
+		case "12xlarge", "16xlarge", "metal":
+			return 2, true
+		}
+	case "i3":
+		switch size {
+		case "large", "xlarge", "2xlarge":
+			return 1, true
+		case "4xlarge":
+			return 2, true
+		case "8xlarge":
+			return 4, true
+		case "16xlarge", "metal":
+			return 8, true
+		}
+	case "i3en":
+		switch size {
+		case "large", "xlarge", "3xlarge":
+			return 1, true
+		case "2xlarge", "6xlarge":
+			return 2, true
+		case "12xlarge":
+			return 4, true
+		case "24xlarge", "metal":
+			return 8, true
+		}
+	}
+	return 0, false
+}
+
 // SelectGCEMachineType selects a machine type given the desired number of CPUs,
 // memory per CPU, and CPU architecture.  It returns a compatible machine type
 // and its architecture.
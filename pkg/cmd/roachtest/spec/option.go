@@ -11,6 +11,7 @@
 package spec
 
 import (
+	"strings"
 	"time"
 
 	"github.com/cockroachdb/cockroach/pkg/roachprod/vm"
@@ -31,6 +32,16 @@ func Arch(arch vm.CPUArch) Option {
 	}
 }
 
+// MixedArch requests a mixed-architecture cluster in which the last
+// arm64Nodes CockroachDB nodes use arm64 and the others use amd64. Binaries
+// are uploaded to each node for its own architecture.
+func MixedArch(arm64Nodes int) Option {
+	return func(spec *ClusterSpec) {
+		spec.Arch = vm.ArchAMD64
+		spec.ARM64Nodes = arm64Nodes
+	}
+}
+
 // CPU sets the number of CPUs for each node.
 func CPU(n int) Option {
 	return func(spec *ClusterSpec) {
@@ -46,6 +57,30 @@ func WorkloadNode() Option {
 	}
 }
 
+// WorkloadNodes indicates that the last n nodes are workload nodes.
+func WorkloadNodes(n int) Option {
+	return func(spec *ClusterSpec) {
+		spec.WorkloadNode = true
+		spec.WorkloadNodeCount = n
+	}
+}
+
+// WorkloadNodeRoles indicates that the last nodes are workload nodes with the
+// given roles, one per node. Roles can be repeated, e.g. to split load
+// generation across several nodes; the nodes with a given role are returned by
+// Cluster.WorkloadNodesWithRole.
+func WorkloadNodeRoles(roles ...WorkloadRole) Option {
+	return func(spec *ClusterSpec) {
+		spec.WorkloadNode = true
+		spec.WorkloadNodeCount = len(roles)
+		names := make([]string, len(roles))
+		for i, r := range roles {
+			names[i] = string(r)
+		}
+		spec.WorkloadNodeRoles = strings.Join(names, ",")
+	}
+}
+
 func WorkloadNodeCPU(n int) Option {
 	return func(spec *ClusterSpec) {
 		spec.WorkloadNodeCPUs = n
@@ -80,6 +115,17 @@ func RAID0(enabled bool) Option {
 	}
 }
 
+// MultipleStores requests nodes with n local SSDs which are not striped (i.e.
+// a JBOD layout), and starts cockroach with one store per disk. On AWS, the
+// number of disks is determined by the machine type. It is incompatible with
+// RAID0 and implies PreferLocalSSD.
+func MultipleStores(n int) Option {
+	return func(spec *ClusterSpec) {
+		spec.StoresPerNode = n
+		spec.LocalSSD = LocalSSDPreferOn
+	}
+}
+
 // Geo requests Geo-distributed nodes.
 func Geo() Option {
 	return func(spec *ClusterSpec) {
@@ -205,6 +251,15 @@ func UseSpotVMs() Option {
 	}
 }
 
+// IPv6 creates the VMs of the cluster with IPv6 addresses only, i.e. the nodes
+// listen, advertise and connect to each other on IPv6. This option is only
+// supported by GCE, where the VMs are created in the --gce-ipv6-subnet subnet.
+func IPv6() Option {
+	return func(spec *ClusterSpec) {
+		spec.IPv6 = true
+	}
+}
+
 // SetFileSystem is an Option which can be used to set
 // the underlying file system to be used.
 func SetFileSystem(fs fileSystemType) Option {
@@ -0,0 +1,273 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"context"
+	gosql "database/sql"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/cluster"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/option"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/logger"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+	"github.com/cockroachdb/cockroach/pkg/workload/histogram"
+)
+
+const (
+	// sqlProbeInterval is the interval between two probes.
+	sqlProbeInterval = time.Second
+	// sqlProbeTimeout is how long a probe waits for a node before trying the
+	// next one.
+	sqlProbeTimeout = 5 * time.Second
+	// sqlProbeTickInterval is the interval at which the latencies of the probes
+	// are written to the perf artifacts.
+	sqlProbeTickInterval = 10 * time.Second
+	// sqlProbeDir is the directory of the artifacts of the prober, laid out
+	// like the perf artifacts fetched from the nodes.
+	sqlProbeDir = "sql-probe." + perfArtifactsDir
+	// sqlProbeGapsFile is the name of the file in sqlProbeDir listing the
+	// availability gaps observed by the prober.
+	sqlProbeGapsFile = "availability_gaps.json"
+
+	sqlProbeReadOp  = "sql-probe-read"
+	sqlProbeWriteOp = "sql-probe-write"
+)
+
+// sqlProbeSetupStmt creates the table written and read by the probes.
+const sqlProbeSetupStmt = `CREATE DATABASE IF NOT EXISTS roachtest_probe;
+CREATE TABLE IF NOT EXISTS roachtest_probe.probe (k INT PRIMARY KEY, ts TIMESTAMPTZ)`
+
+// availabilityGap is a period during which all the probes failed.
+type availabilityGap struct {
+	Start   time.Time `json:"start"`
+	End     time.Time `json:"end"`
+	Seconds float64   `json:"seconds"`
+	// Ongoing is set if the probes were still failing when the test ended.
+	Ongoing bool `json:"ongoing,omitempty"`
+	// Error is the error of the first failed probe of the gap.
+	Error string `json:"error"`
+}
+
+// sqlProber continuously runs a trivial write and read against the cluster of
+// a test, and records their latencies and availability gaps. See
+// registry.TestSpec.SQLProbe.
+type sqlProber struct {
+	c     cluster.Cluster
+	l     *logger.Logger
+	nodes option.NodeListOption
+	// conns are the connections to the nodes, by node. next is the index in
+	// nodes of the node to probe next.
+	conns map[int]*gosql.DB
+	next  int
+	setUp bool
+
+	reg   *histogram.Registry
+	hists *histogram.Histograms
+
+	// available is set once a probe succeeded. The probes fail until the test
+	// starts cockroach, which doesn't make the cluster unavailable.
+	available bool
+	// gapStart and gapErr are the time and error of the first failed probe of
+	// the ongoing availability gap, if any.
+	gapStart time.Time
+	gapErr   error
+	gaps     []availabilityGap
+}
+
+func newSQLProber(c cluster.Cluster, l *logger.Logger) *sqlProber {
+	reg := histogram.NewRegistry(sqlProbeTimeout, "sql_probe")
+	return &sqlProber{
+		c:     c,
+		l:     l,
+		nodes: c.CRDBNodes(),
+		conns: make(map[int]*gosql.DB),
+		reg:   reg,
+		hists: reg.GetHandle(),
+	}
+}
+
+// startSQLProber starts probing the cluster of the given test until the
+// returned function is called, which writes the artifacts of the prober.
+func startSQLProber(ctx context.Context, t *testImpl, c cluster.Cluster) (stop func()) {
+	dir := filepath.Join(t.ArtifactsDir(), sqlProbeDir)
+	l, err := t.L().ChildLogger("sql-probe", logger.QuietStdout, logger.QuietStderr)
+	if err == nil {
+		err = os.MkdirAll(dir, 0755)
+	}
+	var stats *os.File
+	if err == nil {
+		stats, err = os.Create(filepath.Join(dir, perfStatsFile))
+	}
+	if err != nil {
+		t.L().Printf("failed to start the SQL prober: %v", err)
+		return func() {}
+	}
+
+	p := newSQLProber(c, l)
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		p.run(ctx, json.NewEncoder(stats))
+	}()
+	return func() {
+		cancel()
+		<-done
+		_ = stats.Close()
+		p.close()
+		gaps := p.finish(timeutil.Now())
+		t.L().Printf("SQL prober observed %d availability gaps (see %s)", len(gaps), sqlProbeDir)
+		if err := writeAvailabilityGaps(filepath.Join(dir, sqlProbeGapsFile), gaps); err != nil {
+			t.L().Printf("failed to write the availability gaps: %v", err)
+		}
+	}
+}
+
+// run probes the cluster until the context is canceled, and writes the
+// latencies of the probes with the given encoder.
+func (p *sqlProber) run(ctx context.Context, enc *json.Encoder) {
+	probeTicker := time.NewTicker(sqlProbeInterval)
+	defer probeTicker.Stop()
+	histTicker := time.NewTicker(sqlProbeTickInterval)
+	defer histTicker.Stop()
+	writeHists := func() {
+		p.reg.Tick(func(tick histogram.Tick) {
+			if err := enc.Encode(tick.Snapshot()); err != nil {
+				p.l.Printf("failed to write the probe latencies: %v", err)
+			}
+		})
+	}
+	defer writeHists()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-histTicker.C:
+			writeHists()
+		case <-probeTicker.C:
+			err := p.probe(ctx)
+			if ctx.Err() != nil {
+				// The probe was interrupted by the end of the test.
+				return
+			}
+			p.record(timeutil.Now(), err)
+		}
+	}
+}
+
+// probe writes and reads a row through the first node which responds, trying
+// the nodes in turn. Returns the error of the last node tried if none
+// responded.
+func (p *sqlProber) probe(ctx context.Context) error {
+	var err error
+	for i := 0; i < len(p.nodes); i++ {
+		idx := (p.next + i) % len(p.nodes)
+		if err = p.probeNode(ctx, p.nodes[idx]); err == nil {
+			p.next = idx + 1
+			return nil
+		}
+	}
+	p.next++
+	return err
+}
+
+func (p *sqlProber) probeNode(ctx context.Context, node int) error {
+	ctx, cancel := context.WithTimeout(ctx, sqlProbeTimeout)
+	defer cancel()
+	db, ok := p.conns[node]
+	if !ok {
+		var err error
+		if db, err = p.c.ConnE(ctx, p.l, node); err != nil {
+			return err
+		}
+		p.conns[node] = db
+	}
+	if !p.setUp {
+		if _, err := db.ExecContext(ctx, sqlProbeSetupStmt); err != nil {
+			return err
+		}
+		p.setUp = true
+	}
+
+	start := timeutil.Now()
+	if _, err := db.ExecContext(ctx, `UPSERT INTO roachtest_probe.probe VALUES (1, now())`); err != nil {
+		return err
+	}
+	p.hists.Get(sqlProbeWriteOp).Record(timeutil.Since(start))
+
+	start = timeutil.Now()
+	var ts time.Time
+	if err := db.QueryRowContext(ctx, `SELECT ts FROM roachtest_probe.probe WHERE k = 1`).Scan(&ts); err != nil {
+		return err
+	}
+	p.hists.Get(sqlProbeReadOp).Record(timeutil.Since(start))
+	return nil
+}
+
+// record records the outcome of the probe which ended at the given time.
+func (p *sqlProber) record(now time.Time, err error) {
+	if err == nil {
+		if !p.gapStart.IsZero() {
+			gap := p.endGap(now)
+			p.l.Printf("cluster available again after %.1fs", gap.Seconds)
+		}
+		p.available = true
+		return
+	}
+	if p.available && p.gapStart.IsZero() {
+		p.l.Printf("cluster unavailable: %v", err)
+		p.gapStart, p.gapErr = now, err
+	}
+}
+
+func (p *sqlProber) endGap(now time.Time) availabilityGap {
+	gap := availabilityGap{
+		Start:   p.gapStart,
+		End:     now,
+		Seconds: now.Sub(p.gapStart).Seconds(),
+		Error:   p.gapErr.Error(),
+	}
+	p.gaps = append(p.gaps, gap)
+	p.gapStart, p.gapErr = time.Time{}, nil
+	return gap
+}
+
+// finish ends the ongoing availability gap, if any, at the given time, and
+// returns all the gaps.
+func (p *sqlProber) finish(now time.Time) []availabilityGap {
+	if !p.gapStart.IsZero() {
+		p.endGap(now)
+		p.gaps[len(p.gaps)-1].Ongoing = true
+	}
+	return p.gaps
+}
+
+func (p *sqlProber) close() {
+	for _, db := range p.conns {
+		_ = db.Close()
+	}
+}
+
+func writeAvailabilityGaps(path string, gaps []availabilityGap) error {
+	if gaps == nil {
+		gaps = []availabilityGap{}
+	}
+	data, err := json.MarshalIndent(gaps, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
@@ -0,0 +1,60 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLProberAvailabilityGaps(t *testing.T) {
+	p := &sqlProber{l: nilLogger()}
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	at := func(seconds int) time.Time { return start.Add(time.Duration(seconds) * time.Second) }
+	errStalled := errors.New("stalled")
+
+	// The probes fail until cockroach is started, which is not a gap.
+	p.record(at(0), errors.New("connection refused"))
+	p.record(at(1), nil)
+	// The gap lasts from its first failed probe to the next successful one.
+	p.record(at(2), errStalled)
+	p.record(at(3), errors.New("timeout"))
+	p.record(at(5), nil)
+	p.record(at(6), nil)
+	// A gap which is still open at the end of the test.
+	p.record(at(7), errStalled)
+
+	gaps := p.finish(at(9))
+	require.Equal(t, []availabilityGap{
+		{Start: at(2), End: at(5), Seconds: 3, Error: "stalled"},
+		{Start: at(7), End: at(9), Seconds: 2, Ongoing: true, Error: "stalled"},
+	}, gaps)
+
+	path := filepath.Join(t.TempDir(), sqlProbeGapsFile)
+	require.NoError(t, writeAvailabilityGaps(path, gaps))
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var read []availabilityGap
+	require.NoError(t, json.Unmarshal(data, &read))
+	require.Equal(t, gaps, read)
+
+	// A run without gaps writes an empty list rather than null.
+	require.NoError(t, writeAvailabilityGaps(path, (&sqlProber{}).finish(at(10))))
+	data, err = os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, "[]\n", string(data))
+}
@@ -0,0 +1,203 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/cluster"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/option"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/roachtestflags"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/test"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	// stageCheckpointFile is the file on the first node of the cluster of a
+	// staged test which records the stages which completed on it. It lives in
+	// the store dir so that it's wiped along with the data it describes, i.e.
+	// when the cluster is released or reused with --wipe (the default). Runs
+	// which span multiple invocations must thus use --wipe=false.
+	stageCheckpointFile = "{store-dir}/roachtest-stages.json"
+	// stageArtifactsFile is the name of the copy of the checkpoint in the
+	// artifacts dir of the test.
+	stageArtifactsFile = "stages.json"
+)
+
+// stageNameRE is the regexp stage names must match. The names are written to
+// the checkpoint on the cluster by a shell command, so quotes are not allowed.
+var stageNameRE = regexp.MustCompile(`^[a-zA-Z0-9-_]+$`)
+
+// stageCheckpoint is the checkpoint of a staged test on its cluster.
+type stageCheckpoint struct {
+	// Completed are the stages which completed, in order.
+	Completed []completedStage `json:"completed"`
+}
+
+type completedStage struct {
+	Name     string        `json:"name"`
+	Finished time.Time     `json:"finished"`
+	Duration time.Duration `json:"duration"`
+}
+
+func (cp *stageCheckpoint) names() []string {
+	names := make([]string, len(cp.Completed))
+	for i, s := range cp.Completed {
+		names[i] = s.Name
+	}
+	return names
+}
+
+func validateStages(stages []registry.Stage) error {
+	seen := make(map[string]struct{})
+	for _, s := range stages {
+		if !stageNameRE.MatchString(s.Name) {
+			return errors.Newf("stage name %q must match this regexp: %s", s.Name, stageNameRE)
+		}
+		if _, ok := seen[s.Name]; ok {
+			return errors.Newf("duplicate stage %q", s.Name)
+		}
+		seen[s.Name] = struct{}{}
+		if s.Run == nil {
+			return errors.Newf("stage %q must specify Run", s.Name)
+		}
+	}
+	return nil
+}
+
+// stagesToRun returns the range [start, end) of the stages to run, given the
+// names of the stages which already completed on the cluster and the stages to
+// resume at and to stop after, if any.
+func stagesToRun(
+	stages []registry.Stage, completed []string, resume, stopAfter string,
+) (start, end int, _ error) {
+	index := func(name string) (int, error) {
+		for i, s := range stages {
+			if s.Name == name {
+				return i, nil
+			}
+		}
+		return 0, errors.Newf("unknown stage %q", name)
+	}
+	for i, name := range completed {
+		if i >= len(stages) || stages[i].Name != name {
+			return 0, 0, errors.Newf("the stages completed on the cluster (%s) don't match the stages of the test",
+				strings.Join(completed, ", "))
+		}
+	}
+
+	start, end = len(completed), len(stages)
+	if resume != "" {
+		i, err := index(resume)
+		if err != nil {
+			return 0, 0, err
+		}
+		if i > start {
+			return 0, 0, errors.Newf("can't resume at stage %q before stage %q completes",
+				resume, stages[start].Name)
+		}
+		start = i
+	}
+	if stopAfter != "" {
+		i, err := index(stopAfter)
+		if err != nil {
+			return 0, 0, err
+		}
+		if i < start {
+			return 0, 0, errors.Newf("stage %q already completed", stopAfter)
+		}
+		end = i + 1
+	}
+	return start, end, nil
+}
+
+// runStages runs the stages of a staged test which are left to run on its
+// cluster, recording a checkpoint after each one.
+func runStages(ctx context.Context, t test.Test, c cluster.Cluster, stages []registry.Stage) {
+	cp, err := readStageCheckpoint(ctx, t, c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	start, end, err := stagesToRun(stages, cp.names(), roachtestflags.ResumeStage, roachtestflags.StopAfterStage)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if start == len(stages) {
+		t.Skip("all the stages of the test completed on the cluster")
+	}
+	cp.Completed = cp.Completed[:start]
+	if start > 0 {
+		t.L().Printf("resuming at stage %s after stages %s", stages[start].Name,
+			strings.Join(cp.names(), ", "))
+	}
+
+	for i := start; i < end; i++ {
+		stage := stages[i]
+		t.Status(fmt.Sprintf("stage %d/%d: %s", i+1, len(stages), stage.Name))
+		t.L().Printf("running stage %s", stage.Name)
+		stageStart := timeutil.Now()
+		stage.Run(ctx, t, c)
+		if t.Failed() {
+			return
+		}
+		cp.Completed = append(cp.Completed, completedStage{
+			Name:     stage.Name,
+			Finished: timeutil.Now(),
+			Duration: timeutil.Since(stageStart),
+		})
+		if err := writeStageCheckpoint(ctx, t, c, cp); err != nil {
+			t.Fatal(err)
+		}
+		t.L().Printf("completed stage %s in %s", stage.Name, cp.Completed[len(cp.Completed)-1].Duration)
+	}
+	if end < len(stages) {
+		t.L().Printf("stopping after stage %s; the next run on the cluster resumes at stage %s",
+			stages[end-1].Name, stages[end].Name)
+	}
+}
+
+func readStageCheckpoint(ctx context.Context, t test.Test, c cluster.Cluster) (stageCheckpoint, error) {
+	var cp stageCheckpoint
+	res, err := c.RunWithDetailsSingleNode(ctx, t.L(), option.WithNodes(c.Node(1)),
+		fmt.Sprintf("cat %s 2>/dev/null || true", stageCheckpointFile))
+	if err != nil {
+		return cp, errors.Wrap(err, "reading the stage checkpoint")
+	}
+	if out := strings.TrimSpace(res.Stdout); out != "" {
+		if err := json.Unmarshal([]byte(out), &cp); err != nil {
+			return cp, errors.Wrapf(err, "parsing the stage checkpoint %q", out)
+		}
+	}
+	return cp, nil
+}
+
+func writeStageCheckpoint(
+	ctx context.Context, t test.Test, c cluster.Cluster, cp stageCheckpoint,
+) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	if err := c.RunE(ctx, option.WithNodes(c.Node(1)),
+		fmt.Sprintf("mkdir -p {store-dir} && echo '%s' > %s", data, stageCheckpointFile)); err != nil {
+		return errors.Wrap(err, "writing the stage checkpoint")
+	}
+	return os.WriteFile(filepath.Join(t.ArtifactsDir(), stageArtifactsFile), append(data, '\n'), 0644)
+}
@@ -0,0 +1,66 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/cluster"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/test"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStagesToRun(t *testing.T) {
+	run := func(context.Context, test.Test, cluster.Cluster) {}
+	stages := []registry.Stage{
+		{Name: "import", Run: run},
+		{Name: "chaos", Run: run},
+		{Name: "scale", Run: run},
+	}
+	require.NoError(t, validateStages(stages))
+	require.ErrorContains(t, validateStages(append(stages, registry.Stage{Name: "chaos", Run: run})),
+		"duplicate stage")
+	require.ErrorContains(t, validateStages([]registry.Stage{{Name: "it's", Run: run}}),
+		"must match")
+
+	for _, tc := range []struct {
+		name              string
+		completed         []string
+		resume, stopAfter string
+		start, end        int
+		expectedErr       string
+	}{
+		{name: "fresh cluster", start: 0, end: 3},
+		{name: "resume after checkpoint", completed: []string{"import"}, start: 1, end: 3},
+		{name: "stop after stage", stopAfter: "import", start: 0, end: 1},
+		{name: "next stage only", completed: []string{"import"}, stopAfter: "chaos", start: 1, end: 2},
+		{name: "rerun stage", completed: []string{"import", "chaos"}, resume: "chaos", start: 1, end: 3},
+		{name: "all completed", completed: []string{"import", "chaos", "scale"}, start: 3, end: 3},
+		{name: "skip stage", resume: "chaos", expectedErr: `before stage "import" completes`},
+		{name: "stop after completed", completed: []string{"import"}, stopAfter: "import",
+			expectedErr: "already completed"},
+		{name: "unknown stage", resume: "load", expectedErr: `unknown stage "load"`},
+		{name: "mismatched checkpoint", completed: []string{"chaos"}, expectedErr: "don't match"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			start, end, err := stagesToRun(stages, tc.completed, tc.resume, tc.stopAfter)
+			if tc.expectedErr != "" {
+				require.ErrorContains(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.start, start)
+			require.Equal(t, tc.end, end)
+		})
+	}
+}
@@ -40,6 +40,10 @@ type Test interface {
 	// picked randomly.
 	Cockroach() string
 	Name() string
+	// Seed returns the random seed of this run of the test, derived from the
+	// --global-seed flag and the name of the test. Tests should use it to seed
+	// their random choices so that a failed run can be reproduced.
+	Seed() int64
 	BuildVersion() *version.Version
 	IsBuildVersion(string) bool // "vXX.YY"
 	SnapshotPrefix() string
@@ -53,6 +57,10 @@ type Test interface {
 	// through all registered roachtests to change how they register the test.
 	Spec() interface{}
 	VersionsBinaryOverride() map[string]string
+	// NodeBinaryOverride returns the cockroach binaries to use on specific
+	// nodes instead of the one of the test, by node. See
+	// --node-binary-override.
+	NodeBinaryOverride() map[int]string
 	SkipInit() bool
 	Skip(args ...interface{})
 	Skipf(format string, args ...interface{})
@@ -76,6 +84,12 @@ type Test interface {
 	// node in the cluster.
 	GoCoverArtifactsDir() string
 
+	// CollectDebugZipOnFailure registers a debug zip to be collected from the
+	// given nodes, and saved to dest (relative to the artifacts dir), if the
+	// test fails. If the test registers no debug zips, a debug.zip is collected
+	// from the first responsive node of the cluster.
+	CollectDebugZipOnFailure(dest string, nodes ...int)
+
 	L() *logger.Logger
 	Progress(float64)
 	Status(args ...interface{})
@@ -19,7 +19,18 @@ import (
 )
 
 // makeTestFilter creates a registry.TestFilter based on the current flags and
-// the given regexps.
+// the given regexps. Each kind of criteria is OR-ed within itself: a test
+// matches the regexps if it matches any of them, the suites if it is part of
+// any of them, and the --filter expressions if it matches any of them. The
+// kinds are AND-ed together, like the other flags, so that e.g. --suite=nightly
+// kv selects the nightly kv tests. Composite selections which can't be written
+// that way, e.g. "the nightly KV tests plus the TPC-H benchmarks", are written
+// as several --filter expressions instead:
+//
+//	--filter 'suite:nightly && owner:kv' --filter 'benchmark && name:tpch'
+//
+// Since the filter is applied to each test once, tests matching several of the
+// expressions are only selected once.
 func makeTestFilter(regexps []string) (*registry.TestFilter, error) {
 	var options []registry.TestFilterOption
 	if !roachtestflags.ForceCloudCompat {
@@ -33,27 +44,35 @@ func makeTestFilter(regexps []string) (*registry.TestFilter, error) {
 	if roachtestflags.OnlyBenchmarks {
 		options = append(options, registry.OnlyBenchmarks())
 	}
-	if roachtestflags.Suite != "" {
-		options = append(options, registry.WithSuite(roachtestflags.Suite))
+	var exprs []registry.FilterExpr
+	for _, s := range roachtestflags.FilterExprs {
+		expr, err := registry.ParseFilterExpr(s)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, expr)
+	}
+	if len(exprs) > 0 {
+		options = append(options, registry.WithExpr(registry.AnyFilterExpr(exprs...)))
 	}
 
 	// Tags no longer exist, but we provide some basic backward compatibility: if
 	// we see a single tag which matches a known suite, we convert it to a suite.
-	suite := roachtestflags.Suite
+	suites := roachtestflags.Suites
 	var args []string
 	for _, v := range regexps {
 		if tagPrefix := "tag:"; strings.HasPrefix(v, tagPrefix) {
 			tag := strings.TrimPrefix(v, tagPrefix)
-			if suite == "" && registry.AllSuites.Contains(tag) {
-				suite = tag
+			if len(suites) == 0 && registry.AllSuites.Contains(tag) {
+				suites = []string{tag}
 				continue
 			}
 			return nil, fmt.Errorf("tags are no longer supported; use --suite, --owner instead")
 		}
 		args = append(args, v)
 	}
-	if suite != "" {
-		options = append(options, registry.WithSuite(suite))
+	if len(suites) > 0 {
+		options = append(options, registry.WithSuites(suites...))
 	}
 	return registry.NewTestFilter(args, options...)
 }
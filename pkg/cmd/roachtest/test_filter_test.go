@@ -29,6 +29,6 @@ func TestMakeTestFilterTagCompatibility(t *testing.T) {
 		if err != nil {
 			return fmt.Sprintf("error: %v", err)
 		}
-		return fmt.Sprintf("regexp: %s\nsuite: %s", f.Name, f.Suite)
+		return fmt.Sprintf("regexp: %s\nsuite: %s", f.Name, strings.Join(f.Suites, ","))
 	})
 }
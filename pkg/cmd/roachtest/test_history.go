@@ -0,0 +1,83 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"path/filepath"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
+	"github.com/cockroachdb/errors"
+)
+
+// testHistory is the outcome of the previous runs of a test.
+type testHistory struct {
+	runs     int
+	failures int
+}
+
+// loadTestHistory loads the history of tests from the checkpoints of previous
+// runs in the given artifacts directories, which can be glob patterns. See
+// --test-history.
+func loadTestHistory(dirs []string) (map[string]testHistory, error) {
+	history := make(map[string]testHistory)
+	for _, pattern := range dirs {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid test history pattern %q", pattern)
+		}
+		if len(matches) == 0 {
+			return nil, errors.Newf("no artifacts directories match %q", pattern)
+		}
+		for _, dir := range matches {
+			entries, err := loadCheckpointEntries(dir)
+			if err != nil {
+				return nil, errors.Wrapf(err, "loading test history from %s", dir)
+			}
+			for _, e := range entries {
+				if e.Status == reportStatusSkipped {
+					continue
+				}
+				h := history[e.Name]
+				h.runs++
+				// Flaky runs passed only after being retried, which is what
+				// scheduling them early is meant to catch.
+				if e.Status != reportStatusSuccess {
+					h.failures++
+				}
+				history[e.Name] = h
+			}
+		}
+	}
+	return history, nil
+}
+
+// applyTestHistory sets the failure rate of the given tests from their history,
+// so that they are scheduled accordingly (see TestSpec.SchedulingPriority).
+// Tests without history are considered recently added. It returns the number of
+// tests whose priority is raised.
+func applyTestHistory(specs []registry.TestSpec, history map[string]testHistory) int {
+	if len(history) == 0 {
+		return 0
+	}
+	var prioritized int
+	for i := range specs {
+		h, ok := history[specs[i].Name]
+		var failureRate float64
+		if ok {
+			failureRate = float64(h.failures) / float64(h.runs)
+		}
+		specs[i].SetHistory(failureRate, !ok /* recentlyAdded */)
+		if specs[i].SchedulingPriority() > 0 {
+			prioritized++
+		}
+	}
+	return prioritized
+}
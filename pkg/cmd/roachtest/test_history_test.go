@@ -0,0 +1,59 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTestHistory(t *testing.T) {
+	root := t.TempDir()
+	for dir, entries := range map[string][]checkpointEntry{
+		"run1": {
+			{Name: "a", Run: 1, Status: reportStatusSuccess},
+			{Name: "b", Run: 1, Status: reportStatusFailure},
+			{Name: "c", Run: 1, Status: reportStatusSuccess},
+		},
+		"run2": {
+			{Name: "a", Run: 1, Status: reportStatusSuccess},
+			{Name: "b", Run: 1, Status: reportStatusSuccess},
+			{Name: "c", Run: 1, Status: reportStatusFlaky},
+			{Name: "c", Run: 2, Status: reportStatusFlaky},
+		},
+	} {
+		require.NoError(t, newRunCheckpoint(filepath.Join(root, dir)).record(entries...))
+	}
+
+	_, err := loadTestHistory([]string{filepath.Join(root, "missing*")})
+	require.Error(t, err)
+	history, err := loadTestHistory([]string{filepath.Join(root, "run*")})
+	require.NoError(t, err)
+	require.Equal(t, map[string]testHistory{
+		"a": {runs: 2, failures: 0},
+		"b": {runs: 2, failures: 1},
+		"c": {runs: 3, failures: 2},
+	}, history)
+
+	specs := []registry.TestSpec{{Name: "a"}, {Name: "b"}, {Name: "c"}, {Name: "d"}}
+	require.Equal(t, 3, applyTestHistory(specs, history))
+	require.Zero(t, specs[0].SchedulingPriority())
+
+	// The new test goes first, followed by the tests which failed more often.
+	var order []string
+	for _, tc := range newWorkPool(specs, 1 /* count */).workRemaining() {
+		order = append(order, tc.spec.Name)
+	}
+	require.Equal(t, []string{"d", "c", "b", "a"}, order)
+}
@@ -20,8 +20,10 @@ import (
 	"sync"
 	"time"
 
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/option"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/test"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/tests"
 	"github.com/cockroachdb/cockroach/pkg/roachprod/logger"
 	"github.com/cockroachdb/cockroach/pkg/testutils/skip"
 	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
@@ -73,6 +75,10 @@ type testImpl struct {
 	// buildVersion is the version of the Cockroach binary that the test will run
 	// against.
 	buildVersion *version.Version
+	// runNum is the number of this run of the test (see --count), and seed is
+	// its random seed; see testSeed.
+	runNum int
+	seed   int64
 
 	// l is the logger that the test will use for its output.
 	l *logger.Logger
@@ -91,9 +97,34 @@ type testImpl struct {
 	// https://www.jetbrains.com/help/teamcity/2019.1/configuring-general-settings.html#Artifact-Paths
 	artifactsSpec string
 
+	// attempt is the number of times this run of the test has been retried
+	// after failing, and firstFailure is its first failed attempt if attempt > 0.
+	// See --retries-per-failure.
+	attempt      int
+	firstFailure *testImpl
+	// requeues is the number of times this run of the test has been requeued
+	// after its spot VMs were preempted. See --preemption-requeues.
+	requeues int
+
+	// utilization is the resource utilization report of the test, set once
+	// it ran, if the utilization of the nodes was sampled. See
+	// --utilization-sample-interval.
+	utilization *resourceUtilization
+
+	// commands records the roachprod operations run on the cluster of the
+	// test. See commandAuditLog.
+	commands *commandAuditLog
+
+	// timeoutExtensions receives the extensions of the test's timeout requested
+	// through the runner API while the test runs. See runnerAPIPrefix.
+	timeoutExtensions chan time.Duration
+
 	mu struct {
 		syncutil.RWMutex
 		done bool
+		// timeoutFinal is set once the runner stopped waiting for the test to
+		// finish, after which its timeout can't be extended anymore.
+		timeoutFinal bool
 
 		// cancel, if set, is called from the t.Fatal() family of functions when the
 		// test is being marked as failed (i.e. when the failed field above is also
@@ -121,6 +152,9 @@ type testImpl struct {
 		// TODO(test-eng): this should just be an in-mem (ring) buffer attached to
 		// `t.L()`.
 		output []byte
+
+		// debugZips are the debug zips registered via CollectDebugZipOnFailure.
+		debugZips []debugZipTarget
 	}
 	// Map from version to path to the cockroach binary to be used when
 	// mixed-version test wants a binary for that binary. If a particular version
@@ -129,7 +163,11 @@ type testImpl struct {
 	//
 	// Version strings look like "20.1.4".
 	versionsBinaryOverride map[string]string
-	skipInit               bool
+	// nodeBinaryOverride maps the indexes of nodes to the cockroach binary to
+	// upload to them instead of the one of the test. See the
+	// --node-binary-override flag.
+	nodeBinaryOverride map[int]string
+	skipInit           bool
 	// If true, go coverage is enabled and the BAZEL_COVER_DIR env var will be set
 	// when starting nodes.
 	goCoverEnabled bool
@@ -202,6 +240,10 @@ func (t *testImpl) VersionsBinaryOverride() map[string]string {
 	return t.versionsBinaryOverride
 }
 
+func (t *testImpl) NodeBinaryOverride() map[int]string {
+	return t.nodeBinaryOverride
+}
+
 func (t *testImpl) SkipInit() bool {
 	return t.skipInit
 }
@@ -211,12 +253,30 @@ func (t *testImpl) Spec() interface{} {
 	return t.spec
 }
 
+// usingRuntimeAssertions returns whether the test uses the cockroach binary
+// with runtime assertions.
+func (t *testImpl) usingRuntimeAssertions() bool {
+	switch t.spec.CockroachBinary {
+	case registry.StandardCockroach:
+		return false
+	case registry.RuntimeAssertionsCockroach:
+		return true
+	default:
+		return tests.UsingRuntimeAssertions(t)
+	}
+}
+
 func (t *testImpl) Helper() {}
 
 func (t *testImpl) Name() string {
 	return t.spec.Name
 }
 
+// Seed is part of the test.Test interface.
+func (t *testImpl) Seed() int64 {
+	return t.seed
+}
+
 func (t *testImpl) SnapshotPrefix() string {
 	return t.spec.SnapshotPrefix
 }
@@ -435,6 +495,31 @@ func (t *testImpl) addFailure(depth int, format string, args ...interface{}) {
 	t.mu.output = append(t.mu.output, '\n')
 }
 
+// extendTimeout extends the timeout of the running test by the given
+// duration.
+func (t *testImpl) extendTimeout(d time.Duration) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.mu.done || t.mu.timeoutFinal {
+		return errors.Newf("cannot extend the timeout of %s: the test is no longer running", t.Name())
+	}
+	select {
+	case t.timeoutExtensions <- d:
+		return nil
+	default:
+		return errors.Newf("cannot extend the timeout of %s: the test is not running, "+
+			"or an extension is already pending", t.Name())
+	}
+}
+
+// finalizeTimeout stops accepting extensions of the test's timeout. It is
+// called by the runner once it stopped waiting for the test to finish.
+func (t *testImpl) finalizeTimeout() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.mu.timeoutFinal = true
+}
+
 // suppressFailures will stop future failures from being surfaced to github posting
 // or the test logger. It will not stop those failures from being logged in their
 // own failure.log files. Used if we are confident on the root cause of a failure and
@@ -568,6 +653,32 @@ func (t *testImpl) GoCoverArtifactsDir() string {
 	return ""
 }
 
+// debugZipTarget is a debug zip collected when a test fails.
+type debugZipTarget struct {
+	// dest is the path of the debug zip, relative to the artifacts dir.
+	dest string
+	// nodes are the nodes the debug zip may be collected from; all nodes if
+	// empty.
+	nodes option.NodeListOption
+}
+
+// CollectDebugZipOnFailure is part of the test.Test interface.
+func (t *testImpl) CollectDebugZipOnFailure(dest string, nodes ...int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.mu.debugZips = append(t.mu.debugZips, debugZipTarget{dest: dest, nodes: nodes})
+}
+
+// debugZipTargets returns the debug zips to collect if the test fails.
+func (t *testImpl) debugZipTargets() []debugZipTarget {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if len(t.mu.debugZips) == 0 {
+		return []debugZipTarget{{dest: "debug.zip"}}
+	}
+	return append([]debugZipTarget(nil), t.mu.debugZips...)
+}
+
 // IsBuildVersion returns true if the build version is greater than or equal to
 // minVersion. This allows a test to optionally perform additional checks
 // depending on the cockroach version it is running against. Note that the
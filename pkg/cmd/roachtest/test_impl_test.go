@@ -13,6 +13,7 @@ package main
 import (
 	"testing"
 
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/option"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
 	"github.com/cockroachdb/errors"
 	"github.com/stretchr/testify/assert"
@@ -177,3 +178,15 @@ func Test_failureSpecifyOwnerAndAddFailureCombination(t *testing.T) {
 	require.NotNil(t, errWithOwnership)
 	require.Equal(t, registry.OwnerTestEng, errWithOwnership.Owner)
 }
+
+func TestDebugZipTargets(t *testing.T) {
+	var ti testImpl
+	require.Equal(t, []debugZipTarget{{dest: "debug.zip"}}, ti.debugZipTargets())
+
+	ti.CollectDebugZipOnFailure("source_debug.zip", 1, 2, 3)
+	ti.CollectDebugZipOnFailure("dest_debug.zip", 4, 5, 6)
+	require.Equal(t, []debugZipTarget{
+		{dest: "source_debug.zip", nodes: option.NodeListOption{1, 2, 3}},
+		{dest: "dest_debug.zip", nodes: option.NodeListOption{4, 5, 6}},
+	}, ti.debugZipTargets())
+}
@@ -12,6 +12,7 @@ package main
 
 import (
 	"cmp"
+	"context"
 	"fmt"
 	"os"
 	"regexp"
@@ -20,8 +21,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/cluster"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/spec"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/test"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
@@ -125,6 +128,19 @@ func (r *testRegistryImpl) prepareSpec(spec *registry.TestSpec) error {
 	spec.CompatibleClouds.AssertInitialized()
 	spec.Suites.AssertInitialized()
 
+	if len(spec.Stages) > 0 {
+		if spec.Run != nil {
+			return fmt.Errorf("%s: must specify either Run or Stages", spec.Name)
+		}
+		if err := validateStages(spec.Stages); err != nil {
+			return fmt.Errorf("%s: %v", spec.Name, err)
+		}
+		stages := spec.Stages
+		spec.Run = func(ctx context.Context, t test.Test, c cluster.Cluster) {
+			runStages(ctx, t, c, stages)
+		}
+	}
+
 	if spec.Run == nil {
 		return fmt.Errorf("%s: must specify Run", spec.Name)
 	}
@@ -156,6 +172,25 @@ func (r *testRegistryImpl) prepareSpec(spec *registry.TestSpec) error {
 		}
 	}
 
+	if spec.MetamorphicTopology != nil {
+		if err := spec.MetamorphicTopology.Validate(spec.Cluster); err != nil {
+			return fmt.Errorf("%s: invalid MetamorphicTopology: %v", spec.Name, err)
+		}
+	}
+
+	if spec.Skip == "" && (spec.SkipIssue != "" || spec.SkipCategory != "" || spec.SkipExpiry != "") {
+		return fmt.Errorf("%s: SkipIssue, SkipCategory and SkipExpiry require Skip", spec.Name)
+	}
+	if !spec.SkipCategory.IsValid() {
+		return fmt.Errorf("%s: unknown skip category %q", spec.Name, spec.SkipCategory)
+	}
+	if spec.SkipExpiry != "" {
+		if _, err := time.Parse(registry.SkipExpiryLayout, spec.SkipExpiry); err != nil {
+			return fmt.Errorf("%s: SkipExpiry %q must be a date formatted as %s",
+				spec.Name, spec.SkipExpiry, registry.SkipExpiryLayout)
+		}
+	}
+
 	return nil
 }
 func (r *testRegistryImpl) PromFactory() promauto.Factory {
@@ -69,9 +69,13 @@ var (
 	// errClusterProvisioningFailed wraps the error given in an error
 	// that is properly sent to Test Eng and marked as an infra flake.
 	errClusterProvisioningFailed = func(err error) error {
+		title := "cluster_creation"
+		if errors.Is(err, errPreflightChecksFailed) {
+			title = "cluster_preflight"
+		}
 		return registry.ErrorWithOwner(
 			registry.OwnerTestEng, err,
-			registry.WithTitleOverride("cluster_creation"),
+			registry.WithTitleOverride(title),
 			registry.InfraFlake,
 		)
 	}
@@ -109,6 +113,28 @@ const VmLabelTestName string = "test_name"
 // VmLabelTestRunID is the label used to identify the test run id in the VM metadata
 const VmLabelTestRunID string = "test_run_id"
 
+// VmLabelTestOwner is the label used to identify the team owning the test in
+// the VM metadata.
+const VmLabelTestOwner string = "test_owner"
+
+// VmLabelTestSuite is the label used to identify the suite of the run in the VM
+// metadata.
+const VmLabelTestSuite string = "test_suite"
+
+// clusterResourceLabels returns the labels of the VMs and disks of a cluster
+// created for the given test, so that the cost of clusters can be attributed
+// to teams and tests in billing exports. The run-wide labels are given.
+func clusterResourceLabels(runLabels map[string]string, t registry.TestSpec) map[string]string {
+	labels := map[string]string{
+		VmLabelTestName:  vm.SanitizeLabel(t.Name),
+		VmLabelTestOwner: vm.SanitizeLabel(string(t.Owner)),
+	}
+	for k, v := range runLabels {
+		labels[k] = v
+	}
+	return labels
+}
+
 // testRunner runs tests.
 type testRunner struct {
 	stopper *stop.Stopper
@@ -127,6 +153,9 @@ type testRunner struct {
 		// shut down, normally used to ensure a remote prometheus server has scraped the roachtest
 		// endpoint.
 		overrideShutdownPromScrapeInterval time.Duration
+		// manifest, if set, is where test runs are claimed and their results
+		// recorded, when the run is split across multiple runners.
+		manifest *testManifest
 	}
 
 	status struct {
@@ -135,6 +164,9 @@ type testRunner struct {
 		pass    map[*testImpl]struct{}
 		fail    map[*testImpl]struct{}
 		skip    map[*testImpl]struct{}
+		// flaky contains the tests which passed on a retry after failing. See
+		// --retries-per-failure.
+		flaky map[*testImpl]struct{}
 	}
 
 	// cr keeps track of all live clusters.
@@ -149,6 +181,45 @@ type testRunner struct {
 	// work maintains the remaining tests to run.
 	work *workPool
 
+	// checkpoint, if set, records the results of the completed test runs. See
+	// --resume-from.
+	checkpoint *runCheckpoint
+
+	// pool, if set, holds the clusters of failed tests available for reuse by
+	// other tests. See --cluster-pool.
+	pool *clusterPool
+
+	// costs keeps track of the cost of the test runs. See --max-total-cost.
+	costs *costTracker
+
+	// budget keeps track of the test runs which were not started as they
+	// would not finish in time. See --max-run-duration.
+	budget *durationBudget
+
+	// apiSkipped holds the test runs which were not started as they were
+	// skipped through the runner API. See skipRemaining.
+	apiSkipped struct {
+		syncutil.Mutex
+		tests []notRunTest
+	}
+
+	// cloudQuota, if set, holds the tests which were moved to another zone or
+	// not scheduled as their cluster exceeds the cloud quota. See
+	// --check-cloud-quota.
+	cloudQuota *cloudQuotaCheck
+
+	// failFast skips the remaining runs of the tests which failed. See
+	// --fail-fast.
+	failFast *failFast
+
+	// artifacts, if set, uploads the artifacts of the test runs as they
+	// complete. See --artifacts-upload.
+	artifacts *artifactUploader
+
+	// staleSkips are the tests selected by the filter of the run which are
+	// skipped past their SkipExpiry, and are reported at the end of the run.
+	staleSkips []registry.TestSpec
+
 	completedTestsMu struct {
 		syncutil.Mutex
 		// completed maintains information on all completed test runs.
@@ -158,6 +229,21 @@ type testRunner struct {
 	// Counts cluster creation errors across all workers.
 	numClusterErrs int32
 
+	// datadogMetrics, if set, submits test and cluster creation metrics to
+	// Datadog.
+	datadogMetrics *datadogMetrics
+
+	// notifier, if set, posts the progress of the run to webhooks. See
+	// --notify-webhook.
+	notifier *webhookNotifier
+
+	// metrics, if set, are the Prometheus metrics of the runner's
+	// infrastructure.
+	metrics *runnerMetrics
+
+	// ci reports the progress of the run to the CI system. See --ci-reporter.
+	ci ciReporter
+
 	// sideEyeClient, if set, is the client used to communicate with the Side-Eye
 	// debugging service.
 	sideEyeClient *sideeyeclient.SideEyeClient
@@ -176,6 +262,8 @@ func newTestRunner(cr *clusterRegistry, stopper *stop.Stopper) *testRunner {
 	}
 	r.config.skipClusterWipeOnAttach = !roachtestflags.ClusterWipe
 	r.config.disableIssue = roachtestflags.DisableIssue
+	r.notifier = newWebhookNotifier(roachtestflags.NotifyWebhooks)
+	r.ci = noopCIReporter{}
 	r.workersMu.workers = make(map[string]*workerStatus)
 	return r
 }
@@ -214,6 +302,11 @@ type clustersOpt struct {
 	// Side-Eye. If set, each node in the cluster will run the Side-Eye agent.
 	sideEyeToken string
 
+	// resourceLabels are added to the VMs and disks of all the clusters, along
+	// with the owner and name of the test they are created for, for cost
+	// attribution. See clusterResourceLabels.
+	resourceLabels map[string]string
+
 	// preAllocateClusterFn is a function called right before allocating a
 	// cluster. It allows the caller to e.g. inject errors for testing.
 	preAllocateClusterFn func(
@@ -256,6 +349,7 @@ func (c clustersOpt) validate() error {
 
 type testOpts struct {
 	versionsBinaryOverride map[string]string
+	nodeBinaryOverride     map[int]string
 	skipInit               bool
 	goCoverEnabled         bool
 }
@@ -269,7 +363,8 @@ type testOpts struct {
 //
 //	locally (although generally they run against remote roachprod clusters).
 //	parallelism bounds the maximum number of tests that run concurrently. Note
-//	that the concurrency is also affected by cpuQuota.
+//	that the concurrency is also affected by cpuQuota. If 0, workers are
+//	added as long as there is quota for more tests; see admitWorkers.
 //
 // clusterOpt: Options for the clusters to use by tests.
 // lopt: Options for logging.
@@ -330,6 +425,31 @@ func (r *testRunner) Run(
 		r.cr, numConcurrentClusterCreations(),
 	)
 
+	r.status.running = make(map[*testImpl]struct{})
+	r.status.pass = make(map[*testImpl]struct{})
+	r.status.fail = make(map[*testImpl]struct{})
+	r.status.skip = make(map[*testImpl]struct{})
+	r.status.flaky = make(map[*testImpl]struct{})
+
+	r.cloudQuota = nil
+	if roachtestflags.CheckCloudQuota && clustersOpt.typ == roachprodCluster &&
+		clustersOpt.clusterName == "" && roachtestflags.Cloud == spec.GCE {
+		r.cloudQuota = newGCEQuotaCheck(roachtestflags.CloudQuotaFallbackZones, roachtestflags.ARM64Probability > 0)
+		tests = r.cloudQuota.filter(ctx, lopt.l, tests, roachtestflags.Zones)
+		if len(tests) == 0 {
+			// The run ends here, but the infra-skipped tests are still reported,
+			// see collectTestReport.
+			shout(ctx, lopt.l, lopt.stdout, "%s", r.cloudQuota.summary())
+			r.notifier.runFinished(ctx, lopt.l, r)
+			return errors.Newf("all the tests matching the filters exceed the remaining cloud quota")
+		}
+		if limit, ok := r.cloudQuota.cpuLimit(); ok && limit < clustersOpt.cpuQuota {
+			shout(ctx, lopt.l, lopt.stdout, "limiting --cpu-quota from %d to %d, the remaining cloud quota",
+				clustersOpt.cpuQuota, limit)
+			clustersOpt.cpuQuota = limit
+		}
+	}
+
 	n := len(tests)
 	if n*count < parallelism {
 		// Don't spin up more workers than necessary.
@@ -353,22 +473,64 @@ func (r *testRunner) Run(
 			}
 		}
 	}
-	r.status.running = make(map[*testImpl]struct{})
-	r.status.pass = make(map[*testImpl]struct{})
-	r.status.fail = make(map[*testImpl]struct{})
-	r.status.skip = make(map[*testImpl]struct{})
-
 	r.work = newWorkPool(tests, count)
+	r.pool = nil
+	if roachtestflags.ClusterPool {
+		r.pool = &clusterPool{}
+	}
+	r.costs = newCostTracker(roachtestflags.Cloud, roachtestflags.MaxTotalCost)
+	r.budget = newDurationBudget(timeutil.Now(), roachtestflags.MaxRunDuration, roachtestflags.Cloud)
+	r.failFast = newFailFast(roachtestflags.FailFast, roachtestflags.FailFastRun)
+	artifacts, err := newArtifactUploader(roachtestflags.ArtifactsUploadURL, roachtestflags.ArtifactsRetention)
+	if err != nil {
+		return err
+	}
+	r.artifacts = artifacts
 	errs := &workerErrors{}
 
 	qp := quotapool.NewIntPool("cloud cpu", uint64(clustersOpt.cpuQuota))
 	l := lopt.l
 	runID = generateRunID(clustersOpt)
+	clustersOpt.resourceLabels = map[string]string{VmLabelTestRunID: vm.SanitizeLabel(runID)}
+	if suites := roachtestflags.Suites; len(suites) > 0 {
+		clustersOpt.resourceLabels[VmLabelTestSuite] = vm.SanitizeLabel(strings.Join(suites, "-"))
+	}
 	shout(ctx, l, lopt.stdout, "%s: %s", VmLabelTestRunID, runID)
+	r.notifier.runStarted(ctx, l, n*count)
+
+	// runDone is closed once all the tests have run, after which the clusters
+	// kept for debugging, if managed, are checked one last time.
+	runDone := make(chan struct{})
+	lifecycleDone := make(chan struct{})
+	if clustersOpt.debugMode.IsDebug() && roachtestflags.DebugMaxLifetime > 0 {
+		lc := newDebugClusterLifecycle(r.cr, r.notifier, lopt.stdout, roachtestflags.DebugMaxLifetime)
+		go func() {
+			defer close(lifecycleDone)
+			lc.run(ctx, l, runDone)
+		}()
+	} else {
+		close(lifecycleDone)
+	}
+
+	// The clusters of the run are checked for leaks until all the tests have
+	// run, and once more after they're destroyed.
+	var leaks *leakedClusterDetector
+	leaksDone := make(chan struct{})
+	if clustersOpt.typ != localCluster {
+		leaks = newLeakedClusterDetector(ctx, r.cr, lopt.stdout)
+	}
+	if interval := roachtestflags.LeakedClusterCheckInterval; leaks != nil && interval > 0 {
+		go func() {
+			defer close(leaksDone)
+			leaks.run(ctx, l, interval, runDone)
+		}()
+	} else {
+		close(leaksDone)
+	}
+
 	var wg sync.WaitGroup
 
-	for i := 0; i < parallelism; i++ {
-		i := i // Copy for closure.
+	startWorker := func(i int) {
 		wg.Add(1)
 		if err := r.stopper.RunAsyncTask(ctx, "worker", func(ctx context.Context) {
 			defer wg.Done()
@@ -414,11 +576,42 @@ func (r *testRunner) Run(
 			wg.Done()
 		}
 	}
+	if parallelism == 0 {
+		r.admitWorkers(ctx, qp, l, n*count, startWorker)
+	}
+	for i := 0; i < parallelism; i++ {
+		startWorker(i)
+	}
 
 	// Wait for all the workers to finish.
 	wg.Wait()
 	shutdownStart := timeutil.Now()
+	if r.pool != nil {
+		pooled := r.pool.drain()
+		clusters := make([]*clusterImpl, len(pooled))
+		for i, pc := range pooled {
+			clusters[i] = pc.c
+		}
+		// The run's ctx may have been canceled, e.g. by a SIGINT, but the pooled
+		// clusters must be destroyed regardless. Don't wait more than 5 min for
+		// that though.
+		destroyCtx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+		destroyClusters(destroyCtx, l, clusters, closeLogger, roachtestflags.TeardownConcurrency)
+		cancel()
+		for _, pc := range pooled {
+			qp.Release(pc.alloc)
+		}
+	}
 	r.cr.destroyAllClusters(ctx, l)
+	close(runDone)
+	<-lifecycleDone
+	<-leaksDone
+	if leaks != nil && ctx.Err() == nil {
+		leaks.check(ctx, l, true /* final */)
+	}
+	if summary := r.artifacts.wait(); summary != "" {
+		shout(ctx, l, lopt.stdout, "%s", summary)
+	}
 
 	if errs.Err() != nil {
 		shout(ctx, l, lopt.stdout, "FAIL (err: %s)", errs.Err())
@@ -426,6 +619,22 @@ func (r *testRunner) Run(
 	}
 	passFailLine := r.generateReport()
 	shout(ctx, l, lopt.stdout, passFailLine)
+	if clustersOpt.typ != localCluster {
+		shout(ctx, l, lopt.stdout, "%s", r.costs.summary())
+	}
+	if summary := r.budget.summary(); summary != "" {
+		shout(ctx, l, lopt.stdout, "%s", summary)
+	}
+	if summary := r.failFast.summary(); summary != "" {
+		shout(ctx, l, lopt.stdout, "%s", summary)
+	}
+	if summary := r.cloudQuota.summary(); summary != "" {
+		shout(ctx, l, lopt.stdout, "%s", summary)
+	}
+	if summary := staleSkipsSummary(r.staleSkips); summary != "" {
+		shout(ctx, l, lopt.stdout, "%s", summary)
+	}
+	r.notifier.runFinished(ctx, l, r)
 
 	if r.numClusterErrs > 0 {
 		shout(ctx, l, lopt.stdout, "%d clusters could not be created", r.numClusterErrs)
@@ -450,6 +659,48 @@ func (r *testRunner) Run(
 	return nil
 }
 
+// autoParallelismInterval is how often admitWorkers checks whether a new worker
+// can be started.
+var autoParallelismInterval = 5 * time.Second
+
+// admitWorkers implements --parallelism=0. Rather than starting a fixed number
+// of workers, it starts a new worker whenever none of the existing ones is
+// waiting for CPU quota and the free quota is enough for the smallest of the
+// remaining tests, up to maxWorkers workers. The first worker is started
+// unconditionally. It returns once all the tests have been picked up by workers
+// or the runner is stopped.
+func (r *testRunner) admitWorkers(
+	ctx context.Context,
+	qp *quotapool.IntPool,
+	l *logger.Logger,
+	maxWorkers int,
+	startWorker func(i int),
+) {
+	ticker := time.NewTicker(autoParallelismInterval)
+	defer ticker.Stop()
+	for started := 0; started < maxWorkers; {
+		cpus, ok := r.work.smallestTestCPUs()
+		if !ok {
+			return
+		}
+		if started == 0 || (qp.Len() == 0 && qp.ApproximateQuota() >= uint64(cpus)) {
+			l.PrintfCtx(ctx, "Starting worker %d (%d of %d CPUs available)",
+				started, qp.ApproximateQuota(), qp.Capacity())
+			startWorker(started)
+			started++
+		}
+		// Wait before checking again, so that the new worker gets to acquire
+		// quota for its test first.
+		select {
+		case <-ticker.C:
+		case <-r.stopper.ShouldQuiesce():
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
 // N.B. currently this value is hardcoded per cloud provider.
 func numConcurrentClusterCreations() int {
 	var res int
@@ -536,8 +787,25 @@ func (r *testRunner) allocateCluster(
 		localCluster: clustersOpt.typ == localCluster,
 		arch:         arch,
 		sideEyeToken: clustersOpt.sideEyeToken,
+		labels:       clusterResourceLabels(clustersOpt.resourceLabels, t),
+	}
+	for replacements := 0; ; replacements++ {
+		c, vmCreateOpts, err := clusterFactory.newCluster(ctx, cfg, wStatus.SetStatus, lopt.tee)
+		if err != nil || !roachtestflags.PreflightChecks || cfg.localCluster || c.spec.NodeCount == 0 {
+			return c, vmCreateOpts, err
+		}
+		wStatus.SetStatus("running pre-flight checks")
+		err = c.runPreflightChecks(ctx, lopt.l)
+		if err == nil {
+			return c, vmCreateOpts, nil
+		}
+		lopt.l.PrintfCtx(ctx, "Cluster %s failed pre-flight checks: %s", c.Name(), err)
+		c.Destroy(context.Background(), closeLogger, lopt.l)
+		if replacements == maxPreflightReplacements || ctx.Err() != nil {
+			return nil, nil, err
+		}
+		lopt.l.PrintfCtx(ctx, "Replacing cluster %s for test %s", c.Name(), t.Name)
 	}
-	return clusterFactory.newCluster(ctx, cfg, wStatus.SetStatus, lopt.tee)
 }
 
 // runWorker runs tests in a loop until work is exhausted.
@@ -618,6 +886,18 @@ func (r *testRunner) runWorker(
 	clusterDestroyWg := &sync.WaitGroup{}
 	// cluster destroy can be done concurrently. The WaitGroup just ensures that all pending Destroy calls have completed.
 	defer clusterDestroyWg.Wait() // wait for the clusters to be destroyed
+	// retry, if set, is the next attempt of a test that failed on this worker
+	// and is to be retried on a fresh cluster.
+	var retry *testToRunRes
+	defer func() {
+		// The worker stopped before the retry could run, so its outcome won't be
+		// known. Report the failure it was meant to retry.
+		if retry != nil && retry.firstFailure != nil {
+			r.reportFirstFailure(ctx, l, stdout,
+				newGithubIssues(r.config.disableIssue, nil /* cluster */, nil /* vmCreateOpts */),
+				retry.firstFailure, "the retry was abandoned")
+		}
+	}()
 	// Loop until there's no more work in the pool, we get interrupted, or an
 	// error occurs.
 	for {
@@ -643,12 +923,19 @@ func (r *testRunner) runWorker(
 		wStatus.SetTest(nil /* test */, testToRunRes{})
 
 		testToRun := testToRunRes{noWork: true}
-		if c != nil {
+		if retry != nil {
+			// Retry the failed test. Its cluster has been released, and we hold on
+			// to its quota allocation, which the fresh cluster needs as well.
+			testToRun, retry = *retry, nil
+		} else if c != nil {
 			// Try to reuse cluster.
 			testToRun = work.selectTestForCluster(ctx, c.spec, r.cr, roachtestflags.Cloud)
 			if !testToRun.noWork {
 				// We found a test to run on this cluster. Wipe the cluster.
-				if err := c.WipeForReuse(ctx, l, testToRun.spec.Cluster); err != nil {
+				wipeStart := timeutil.Now()
+				err := c.WipeForReuse(ctx, l, testToRun.spec.Cluster)
+				r.metrics.recordClusterWipe(timeutil.Since(wipeStart), err)
+				if err != nil {
 					// We do not count reuse attempt error toward clusterCreateErr. If
 					// either the Wipe or Extend failed, then destroy the cluster and attempt
 					// to create a fresh cluster for the selected test.
@@ -688,6 +975,23 @@ func (r *testRunner) runWorker(
 				alloc = nil
 			}
 
+			// Prefer a pooled cluster over acquiring quota for a new one. Pooled
+			// clusters that no remaining test can use are destroyed.
+			if r.pool != nil {
+				taken, unusable := r.pool.take(work, roachtestflags.Cloud)
+				for _, pc := range unusable {
+					l.PrintfCtx(ctx, "No tests can reuse pooled cluster %s. Destroying.", pc.c)
+					r.destroyClusterAsync(clusterDestroyWg, pc.c, l)
+					qp.Release(pc.alloc)
+				}
+				if taken != nil {
+					l.PrintfCtx(ctx, "Taking cluster %s from the pool", taken.c)
+					c, alloc = taken.c, taken.alloc
+					wStatus.SetCluster(c)
+					continue
+				}
+			}
+
 			var err error
 			testToRun, alloc, err = work.selectTest(ctx, qp, l)
 			if err != nil {
@@ -698,6 +1002,42 @@ func (r *testRunner) runWorker(
 				return nil
 			}
 		}
+		r.metrics.setQueueDepth(work.runsRemaining())
+
+		// Like for the cost budget, retries are always run.
+		if !r.budget.admit(&testToRun.spec, testToRun.runNum, timeutil.Now(), testToRun.isRerun()) {
+			shout(ctx, l, stdout, "Not running %s (run %d): it would not finish within --max-run-duration",
+				testToRun.spec.Name, testToRun.runNum)
+			r.ci.testIgnored(func(format string, args ...interface{}) {
+				shout(ctx, l, stdout, format, args...)
+			}, testToRun.spec.Name, notRunReason, 0)
+			continue
+		}
+
+		// Retries of a failed test run are not subject to the budget, as its
+		// failure is only reported once it is no longer retried.
+		estimatedCost, withinBudget := r.costs.reserve(&testToRun.spec, testToRun.runNum, testToRun.isRerun())
+		if !withinBudget {
+			shout(ctx, l, stdout, "Not scheduling %s (run %d): its estimated cost of $%.2f exceeds the budget",
+				testToRun.spec.Name, testToRun.runNum, estimatedCost)
+			r.ci.testIgnored(func(format string, args ...interface{}) {
+				shout(ctx, l, stdout, format, args...)
+			}, testToRun.spec.Name, costRefusedReason, 0)
+			continue
+		}
+
+		if m := r.config.manifest; m != nil && !testToRun.isRerun() {
+			claimed, err := m.claim(testToRun.spec.Name, testToRun.runNum)
+			if err != nil {
+				return err
+			}
+			if !claimed {
+				l.PrintfCtx(ctx, "Skipping %s (run %d): claimed by another runner",
+					testToRun.spec.Name, testToRun.runNum)
+				r.costs.release(estimatedCost)
+				continue
+			}
+		}
 
 		// From this point onward, c != nil iff we are reusing the cluster.
 
@@ -737,9 +1077,13 @@ func (r *testRunner) runWorker(
 			// Create a new cluster if can't reuse or reuse attempt failed.
 			// N.B. non-reusable cluster would have been destroyed above.
 			wStatus.SetTest(nil /* test */, testToRun)
+			createStart := timeutil.Now()
 			c, vmCreateOpts, clusterCreateErr = r.allocateCluster(
 				ctx, clusterFactory, clustersOpt, lopt,
 				testToRun.spec, arch, wStatus)
+			r.datadogMetrics.recordClusterCreation(
+				ctx, l, testToRun.spec.Name, timeutil.Since(createStart), clusterCreateErr)
+			r.metrics.recordClusterCreation(timeutil.Since(createStart), clusterCreateErr)
 
 			if clusterCreateErr != nil {
 				atomic.AddInt32(&r.numClusterErrs, 1)
@@ -795,6 +1139,12 @@ func (r *testRunner) runWorker(
 		}
 		escapedTestName := teamCityNameEscape(testName)
 		runSuffix := "run_" + strconv.Itoa(testToRun.runNum)
+		if testToRun.attempt > 0 {
+			runSuffix += "_retry_" + strconv.Itoa(testToRun.attempt)
+		}
+		if testToRun.requeues > 0 {
+			runSuffix += "_requeue_" + strconv.Itoa(testToRun.requeues)
+		}
 
 		testArtifactsDir := filepath.Join(filepath.Join(artifactsRootDir, escapedTestName), runSuffix)
 		logPath := filepath.Join(testArtifactsDir, "test.log")
@@ -818,13 +1168,21 @@ func (r *testRunner) runWorker(
 			cockroachEA:            cockroachEA[arch],
 			deprecatedWorkload:     workload[arch],
 			buildVersion:           binaryVersion,
+			runNum:                 testToRun.runNum,
+			seed:                   testSeed(roachtestflags.GlobalSeed, testToRun.spec.Name, testToRun.runNum),
 			artifactsDir:           testArtifactsDir,
 			artifactsSpec:          artifactsSpec,
 			l:                      testL,
 			versionsBinaryOverride: topt.versionsBinaryOverride,
+			nodeBinaryOverride:     topt.nodeBinaryOverride,
 			skipInit:               topt.skipInit,
 			debug:                  clustersOpt.debugMode.IsDebug(),
 			goCoverEnabled:         topt.goCoverEnabled,
+			attempt:                testToRun.attempt,
+			firstFailure:           testToRun.firstFailure,
+			requeues:               testToRun.requeues,
+			timeoutExtensions:      make(chan time.Duration, 1),
+			commands:               newCommandAuditLog(testArtifactsDir),
 		}
 		github := newGithubIssues(r.config.disableIssue, c, vmCreateOpts)
 
@@ -848,6 +1206,11 @@ func (r *testRunner) runWorker(
 			l.PrintfCtx(ctx, "Starting test: %s:%d on cluster=%s (arch=%q)", testToRun.spec.Name, testToRun.runNum, c.Name(), arch)
 
 			c.setTest(t)
+			t.L().Printf("test seed: %d (derived from --global-seed=%d)", t.seed, roachtestflags.GlobalSeed)
+			if mt := t.spec.MetamorphicTopology; mt != nil {
+				t.L().Printf("metamorphic topology: %s (derived from --global-seed=%d)",
+					mt.Describe(t.spec.Cluster), roachtestflags.GlobalSeed)
+			}
 
 			var setupErr error
 			if c.spec.NodeCount > 0 { // skip during tests
@@ -868,6 +1231,9 @@ func (r *testRunner) runWorker(
 				// test".
 				c.status("running test")
 
+				// Metamorphic choices are made with the test's own seed so that
+				// they can be reproduced; see reproCommand.
+				testRNG := rand.New(rand.NewSource(t.seed))
 				testSpec := t.Spec().(*registry.TestSpec)
 				switch testSpec.EncryptionSupport {
 				case registry.EncryptionAlwaysEnabled:
@@ -878,7 +1244,7 @@ func (r *testRunner) runWorker(
 					// when tests opted-in to metamorphic testing, encryption will
 					// be enabled according to the probability passed to
 					// --metamorphic-encryption-probability
-					c.encAtRest = prng.Float64() < roachtestflags.EncryptionProbability
+					c.encAtRest = testRNG.Float64() < roachtestflags.EncryptionProbability
 				}
 
 				// Set initial cluster settings for this test.
@@ -892,7 +1258,7 @@ func (r *testRunner) runWorker(
 				case registry.ExpirationLeases:
 					c.clusterSettings["kv.expiration_leases_only.enabled"] = "true"
 				case registry.MetamorphicLeases:
-					enabled := prng.Float64() < 0.5
+					enabled := testRNG.Float64() < 0.5
 					c.status(fmt.Sprintf("metamorphically setting kv.expiration_leases_only.enabled = %t",
 						enabled))
 					c.clusterSettings["kv.expiration_leases_only.enabled"] = fmt.Sprintf("%t", enabled)
@@ -916,6 +1282,22 @@ func (r *testRunner) runWorker(
 		l.PrintfCtx(ctx, msg)
 
 		testL.Close()
+		r.costs.complete(estimatedCost, testToRun.spec.Cluster, t.duration())
+		if !shouldRetry(t) && !shouldRequeue(t) {
+			status := testReportStatus(t)
+			if m := r.config.manifest; m != nil {
+				if err := m.complete(testToRun.spec.Name, testToRun.runNum, status); err != nil {
+					shout(ctx, l, stdout, "failed to record test result in manifest: %s", err)
+				}
+			}
+			if cp := r.checkpoint; cp != nil {
+				if err := cp.record(checkpointEntry{
+					Name: testToRun.spec.Name, Run: testToRun.runNum, Status: status,
+				}); err != nil {
+					shout(ctx, l, stdout, "failed to record test result in checkpoint: %s", err)
+				}
+			}
+		}
 		if t.Failed() {
 			failureMsg := fmt.Sprintf("%s (%d) - %s", testToRun.spec.Name, testToRun.runNum, t.failureMsg())
 			if c != nil {
@@ -925,21 +1307,70 @@ func (r *testRunner) runWorker(
 					// We already marked the cluster as a saved cluster above in the case
 					// of DebugKeepAlways, but update it with the failureMsg.
 					c.Save(ctx, failureMsg, l)
+					if roachtestflags.DebugWithDelve {
+						if instructions, err := c.attachDelve(ctx, l); err != nil {
+							shout(ctx, l, stdout, "failed to attach delve to cluster %s: %s", c.Name(), err)
+						} else {
+							shout(ctx, l, stdout, "%s", instructions)
+						}
+					}
 
 					// Continue with a fresh cluster.
 					c = nil
 				case NoDebug:
-					// On any test failure or error, we destroy the cluster. We could be
-					// more selective, but this sounds safer.
-					l.PrintfCtx(ctx, "destroying cluster %s because: %s", c, failureMsg)
-					c.Destroy(context.Background(), closeLogger, l)
+					// On any test failure or error, we destroy the cluster, unless it
+					// can be pooled for reuse by other tests. A failed test is retried
+					// on a fresh cluster, for which it keeps its quota allocation.
+					errWithOwner := failuresAsErrorWithOwnership(t.failures())
+					// The preempted VMs of the cluster may have been recreated by the
+					// cloud provider without our data, or not at all.
+					if wasPreempted(t) {
+						c.taint("spot VMs preempted")
+					}
+					if errWithOwner != nil && errWithOwner.InfraFlake {
+						c.taint("infrastructure flake")
+					}
+					if r.pool != nil && !shouldRetry(t) && r.pool.put(ctx, l, c, alloc) {
+						// The pool now owns the cluster and its quota allocation.
+						alloc = nil
+					} else {
+						l.PrintfCtx(ctx, "destroying cluster %s because: %s", c, failureMsg)
+						c.Destroy(context.Background(), closeLogger, l)
+					}
 					c = nil
 				}
 			}
+			if shouldRequeue(t) {
+				// The test's spot VMs were preempted; run it again on on-demand VMs,
+				// which can't be preempted.
+				next := testToRun
+				next.requeues++
+				next.canReuseCluster = false
+				next.spec.Cluster.UseSpotVMs = false
+				retry = &next
+			} else if shouldRetry(t) {
+				next := testToRun
+				next.attempt++
+				next.canReuseCluster = false
+				if next.firstFailure == nil {
+					next.firstFailure = t
+				}
+				retry = &next
+			} else if errWithOwner := failuresAsErrorWithOwnership(t.failures()); errWithOwner == nil || !errWithOwner.InfraFlake {
+				if n := r.failFast.failed(r.work, testToRun.spec.Name, testToRun.runNum); n > 0 {
+					shout(ctx, l, stdout, "Not scheduling %d remaining test runs, as %s (run %d) failed",
+						n, testToRun.spec.Name, testToRun.runNum)
+				}
+			}
 		} else {
 			// Upon success fetch the perf artifacts from the remote hosts.
 			if t.spec.Benchmark {
 				getPerfArtifacts(ctx, c, t)
+				if roachtestflags.ExportOpenMetrics || roachtestflags.RoachperfUploadURL != "" {
+					if err := exportPerfArtifacts(ctx, t.L(), artifactsRootDir, t); err != nil {
+						t.L().PrintfCtx(ctx, "failed to export perf artifacts: %v", err)
+					}
+				}
 			}
 			if clustersOpt.debugMode == DebugKeepAlways {
 				// We already marked the cluster as a saved cluster above.
@@ -948,6 +1379,11 @@ func (r *testRunner) runWorker(
 				c = nil
 			}
 		}
+		r.artifacts.upload(l, artifactsRootDir, testArtifactsDir, map[string]string{
+			"test":   testToRun.spec.Name,
+			"run":    strconv.Itoa(testToRun.runNum),
+			"status": testReportStatus(t),
+		})
 	}
 }
 
@@ -969,6 +1405,30 @@ func (r *testRunner) destroyClusterAsync(
 	}(c)
 }
 
+// reportFirstFailure reports the first failed attempt of a test, whose retry
+// did not pass for the given reason other than a failure of the test, e.g.
+// because it was an infrastructure flake. Without it, the failure of the test
+// would go unreported.
+func (r *testRunner) reportFirstFailure(
+	ctx context.Context, l *logger.Logger, stdout io.Writer, github *githubIssues, f *testImpl, reason string,
+) {
+	output := fmt.Sprintf("%s\ntest artifacts and logs in: %s\n\n%s",
+		f.failureMsg(), f.ArtifactsDir(), reason)
+	issue, err := github.MaybePost(f, l, output)
+	if err != nil {
+		shout(ctx, l, stdout, "failed to post issue: %s", err)
+	}
+	if issue != nil {
+		output += "\n" + issue.String()
+	}
+	r.notifier.testFailed(ctx, l, f, output)
+	shout(ctx, l, stdout, "--- FAIL: %s (run %d)\n%s", f.Name(), f.runNum, output)
+
+	r.status.Lock()
+	defer r.status.Unlock()
+	r.status.fail[f] = struct{}{}
+}
+
 // getArtifacts retrieves artifacts (like perf or go cover) produced by a
 // successful test.
 //
@@ -1052,6 +1512,9 @@ func (r *testRunner) runTest(
 	if runCount > 1 {
 		testRunID += fmt.Sprintf("#%d", runNum)
 	}
+	ciOut := func(format string, args ...interface{}) {
+		shout(ctx, l, stdout, format, args...)
+	}
 
 	r.status.Lock()
 	r.status.running[t] = struct{}{}
@@ -1063,7 +1526,13 @@ func (r *testRunner) runTest(
 	s := t.Spec().(*registry.TestSpec)
 
 	grafanaAvailable := roachtestflags.Cloud == spec.GCE
-	if err := c.addLabels(map[string]string{VmLabelTestName: testRunID}); err != nil {
+	// A reused cluster is labeled with the test that created it, so the labels
+	// are updated for the duration of the test, and reverted after it.
+	testLabels := map[string]string{
+		VmLabelTestName:  testRunID,
+		VmLabelTestOwner: vm.SanitizeLabel(string(s.Owner)),
+	}
+	if err := c.addLabels(testLabels); err != nil {
 		shout(ctx, l, stdout, "failed to add label to cluster [%s] - %s", c.Name(), err)
 		grafanaAvailable = false
 	}
@@ -1076,7 +1545,7 @@ func (r *testRunner) runTest(
 
 	defer func() {
 		t.end = timeutil.Now()
-		if err := c.removeLabels([]string{VmLabelTestName}); err != nil {
+		if err := c.revertLabels(testLabels); err != nil {
 			shout(ctx, l, stdout, "failed to remove label from cluster [%s] - %s", c.Name(), err)
 		}
 
@@ -1099,20 +1568,15 @@ func (r *testRunner) runTest(
 		t.mu.Unlock()
 
 		if s.Skip != "" {
-			// When skipping a test, we should not report ##teamcity[testStarted...] or ##teamcity[testFinished...]
-			// service messages else the test will be reported as having run twice.
-			if roachtestflags.TeamCity {
-				shout(ctx, l, stdout, "##teamcity[testIgnored name='%s' message='%s' duration='%d']\n",
-					s.Name, TeamCityEscape(s.Skip), t.duration().Milliseconds())
-			}
-			shout(ctx, l, stdout, "--- SKIP: %s (%s)\n\t%s\n", s.Name, "N/A", s.Skip)
+			// When skipping a test, we should not report it as started or finished,
+			// else the test will be reported as having run twice.
+			r.ci.testIgnored(ciOut, s.Name, s.SkipReason(), t.duration())
+			shout(ctx, l, stdout, "--- SKIP: %s (%s)\n\t%s\n", s.Name, "N/A", s.SkipReason())
 		} else {
-			// Delaying the ##teamcity[testStarted...] service message until the test is finished allows us to branch
-			// separately for skipped tests. The duration of the test is passed to ##teamcity[testFinished...] for
-			// accurate reporting in the TC UI.
-			if roachtestflags.TeamCity {
-				shout(ctx, l, stdout, "##teamcity[testStarted name='%s' flowId='%s']", t.Name(), testRunID)
-			}
+			// Delaying the report of the test start until the test is finished
+			// allows us to branch separately for skipped tests. The duration of the
+			// test is passed to testFinished for accurate reporting in the CI UI.
+			r.ci.testStarted(ciOut, t.Name(), testRunID)
 
 			durationStr := fmt.Sprintf("%.2fs", t.duration().Seconds())
 			if t.Failed() {
@@ -1140,66 +1604,70 @@ func (r *testRunner) runTest(
 				}
 
 				output := fmt.Sprintf("%s\ntest artifacts and logs in: %s", failureMsg, t.ArtifactsDir())
+				output += fmt.Sprintf("\nto reproduce, run:\n%s", t.reproCommand(c))
+
+				if shouldRequeue(t) {
+					// Preemptions are not failures of the test; it is run again, and
+					// its outcome is that of the requeued run.
+					output += fmt.Sprintf("\nrequeuing on on-demand VMs (requeue %d of %d)",
+						t.requeues+1, roachtestflags.PreemptionRequeues)
+				} else if shouldRetry(t) {
+					// The issue, if any, is posted once the outcome of the retries is
+					// known.
+					output += fmt.Sprintf("\nretrying on a fresh cluster (retry %d of %d)",
+						t.attempt+1, roachtestflags.RetriesPerFailure)
+				} else {
+					if errWithOwner := failuresAsErrorWithOwnership(t.failures()); t.firstFailure != nil &&
+						errWithOwner != nil && errWithOwner.InfraFlake {
+						// The retry didn't get to tell whether the test is flaky, so the
+						// failure it retried is reported as is.
+						r.reportFirstFailure(ctx, l, stdout, github, t.firstFailure,
+							fmt.Sprintf("retry %d of %d was an infrastructure flake", t.attempt, roachtestflags.RetriesPerFailure))
+					}
+					if t.attempt > 0 {
+						output = fmt.Sprintf("test failed on all %d attempts; last failure:\n%s",
+							t.attempt+1, output)
+					}
+					issue, err := github.MaybePost(t, l, output)
+					if err != nil {
+						shout(ctx, l, stdout, "failed to post issue: %s", err)
+					}
 
-				issue, err := github.MaybePost(t, l, output)
-				if err != nil {
-					shout(ctx, l, stdout, "failed to post issue: %s", err)
-				}
-
-				// If an issue was created (or comment added) on GitHub,
-				// include that information in the output so that it can be
-				// easily inspected on the TeamCity overview page.
-				if issue != nil {
-					output += "\n" + issue.String()
-				}
-				if roachtestflags.TeamCity {
-					// If `##teamcity[testFailed ...]` is not present before `##teamCity[testFinished ...]`,
-					// TeamCity regards the test as successful.
-					shout(ctx, l, stdout, "##teamcity[testFailed name='%s' details='%s' flowId='%s']",
-						s.Name, TeamCityEscape(output), testRunID)
-				}
-
-				shout(ctx, l, stdout, "--- FAIL: %s (%s)\n%s", testRunID, durationStr, output)
-
-				if roachtestflags.GitHubActions {
-					outputLines := strings.Split(strings.TrimSpace(output), "\n")
-					for _, line := range outputLines {
-						shout(ctx, l, stdout, "::error title=%s failed::%s", s.Name, line)
+					// If an issue was created (or comment added) on GitHub,
+					// include that information in the output so that it can be
+					// easily inspected on the TeamCity overview page.
+					if issue != nil {
+						output += "\n" + issue.String()
 					}
+					r.notifier.testFailed(ctx, l, t, output)
 				}
+				r.ci.testFailed(ciOut, s.Name, testRunID, output)
+				shout(ctx, l, stdout, "--- FAIL: %s (%s)\n%s", testRunID, durationStr, output)
 			} else {
 				shout(ctx, l, stdout, "--- PASS: %s (%s)", testRunID, durationStr)
+				if f := t.firstFailure; f != nil {
+					// The test passed on a retry, so it is flaky. File an issue for the
+					// first failed attempt, which MaybePost labels as flaky.
+					output := fmt.Sprintf("%s\ntest artifacts and logs in: %s\n\npassed on retry %d of %d",
+						f.failureMsg(), f.ArtifactsDir(), t.attempt, roachtestflags.RetriesPerFailure)
+					shout(ctx, l, stdout, "--- FLAKY: %s\n%s", testRunID, output)
+					if _, err := github.MaybePost(t, l, output); err != nil {
+						shout(ctx, l, stdout, "failed to post issue: %s", err)
+					}
+				}
 			}
 
-			if roachtestflags.TeamCity {
-				shout(ctx, l, stdout, "##teamcity[testFinished name='%s' flowId='%s' duration='%d']",
-					t.Name(), testRunID, t.duration().Milliseconds())
-			}
+			r.ci.testFinished(ciOut, t.Name(), testRunID, t.duration())
 		}
 
-		if roachtestflags.TeamCity {
-			// Zip the artifacts. This improves the TeamCity UX where we can navigate
-			// through zip files just fine, but we can't download subtrees of the
-			// artifacts storage. By zipping we get this capability as we can just
-			// download the zip file for the failing test instead.
-			if err := zipArtifacts(t); err != nil {
-				l.Printf("unable to zip artifacts: %s", err)
-			}
-
-			if t.artifactsSpec != "" {
-				// Tell TeamCity to collect this test's artifacts now. The TC job
-				// also collects the artifacts directory wholesale at the end, but
-				// here we make sure that the artifacts for any test that has already
-				// finished are available in the UI even before the job as a whole
-				// has completed. We're using the exact same destination to avoid
-				// duplication of any of the artifacts.
-				shout(ctx, l, stdout, "##teamcity[publishArtifacts '%s']", t.artifactsSpec)
-			}
+		if err := applyArtifactPolicy(l, t.ArtifactsDir(), t.Failed(), artifactPolicy(s)); err != nil {
+			l.Printf("unable to apply artifact policy: %s", err)
 		}
 
-		if roachtestflags.GitHubActions && roachtestflags.Parallelism == 1 {
-			shout(ctx, l, stdout, "::endgroup::")
+		if err := r.ci.testArtifacts(ciOut, t); err != nil {
+			l.Printf("%s", err)
 		}
+		r.ci.endGroup(ciOut)
 
 		r.recordTestFinish(completedTestInfo{
 			test:    t.Name(),
@@ -1215,24 +1683,22 @@ func (r *testRunner) runTest(
 		if s.Run != nil {
 			if t.Failed() {
 				errWithOwner := failuresAsErrorWithOwnership(t.failures())
-				if errWithOwner == nil || !errWithOwner.InfraFlake {
+				if (errWithOwner == nil || !errWithOwner.InfraFlake) && !shouldRetry(t) {
 					r.status.fail[t] = struct{}{}
 				}
 			} else if s.Skip != "" {
 				r.status.skip[t] = struct{}{}
+			} else if t.firstFailure != nil {
+				r.status.flaky[t] = struct{}{}
 			} else {
 				r.status.pass[t] = struct{}{}
 			}
 		}
 		r.status.Unlock()
+		r.datadogMetrics.recordTest(ctx, l, t)
 	}()
 
-	// NB: Nesting won't work properly if we're running multiple tests
-	// concurrently. Therefore, we only group log lines if parallelism is 1
-	// (which is true for local roachtests that we run in GitHub Actions).
-	if roachtestflags.GitHubActions && roachtestflags.Parallelism == 1 {
-		shout(ctx, l, stdout, "::group::%s", s.Name)
-	}
+	r.ci.beginGroup(ciOut, s.Name)
 
 	t.start = timeutil.Now()
 
@@ -1273,7 +1739,22 @@ func (r *testRunner) runTest(
 	}()
 
 	var timedOut bool
-	timeout := testTimeout(t.spec)
+	timeout := scaledTestTimeout(t.spec, c.Cloud(), c.arch)
+	if timeout != testTimeout(t.spec) {
+		t.L().Printf("scaled test timeout from %s to %s", testTimeout(t.spec), timeout)
+	}
+
+	// Watch for preemptions while the test runs, so that a test whose spot VMs
+	// are gone doesn't keep running until it times out.
+	watchCtx, stopWatching := context.WithCancel(ctx)
+	defer stopWatching()
+	preemptedCh := watchForPreemption(watchCtx, c, l)
+
+	stopProbe := func() {}
+	if t.spec.SQLProbe {
+		stopProbe = startSQLProber(runCtx, t, c)
+	}
+	stopSampling := startUtilizationSampler(runCtx, t, c)
 
 	if grafanaAvailable {
 		// Shout this to the log and stdout to make it available to anyone watching the test via CI or locally.
@@ -1283,27 +1764,67 @@ func (r *testRunner) runTest(
 	} else {
 		shout(ctx, l, stdout, "=== RUN   %s", testRunID)
 	}
-	select {
-	case <-testReturnedCh:
-		s := "successfully"
-		if t.Failed() {
-			s = "with failure(s)"
-		}
-		t.L().Printf("test completed %s", s)
-		annotationText := fmt.Sprintf("%s completed %s", t.Name(), s)
-		// Attempt to annotate the test completion on Grafana.
-		if err := c.AddGrafanaAnnotation(ctx, t.L(), grafana.AddAnnotationRequest{Text: annotationText}); err != nil {
-			t.L().Printf(errors.Wrap(err, "error adding annotation for test end").Error())
-		}
-	case <-time.After(timeout):
-		// NB: We're adding the timeout failure intentionally without cancelling the context
-		// to capture as much state as possible during artifact collection.
-		t.addFailure(0, "test timed out (%s)", timeout)
-		// We suppress other failures from being surfaced to the top as the timeout is always going
-		// to be the main error and subsequent errors (i.e. context cancelled) add noise.
-		t.suppressFailures()
-		timedOut = true
-	}
+	deadline := timeutil.Now().Add(timeout)
+	timeoutTimer := time.NewTimer(timeout)
+	defer timeoutTimer.Stop()
+	for testDone := false; !testDone; {
+		select {
+		case <-testReturnedCh:
+			testDone = true
+			s := "successfully"
+			if t.Failed() {
+				s = "with failure(s)"
+			}
+			t.L().Printf("test completed %s", s)
+			annotationText := fmt.Sprintf("%s completed %s", t.Name(), s)
+			// Attempt to annotate the test completion on Grafana.
+			if err := c.AddGrafanaAnnotation(ctx, t.L(), grafana.AddAnnotationRequest{Text: annotationText}); err != nil {
+				t.L().Printf(errors.Wrap(err, "error adding annotation for test end").Error())
+			}
+		case extension := <-t.timeoutExtensions:
+			// The timeout was extended through the runner API. The cluster
+			// needs to survive until the new deadline too.
+			timeout += extension
+			deadline = deadline.Add(extension)
+			timeoutTimer.Reset(timeutil.Until(deadline))
+			if err := c.maybeExtendClusterUntil(ctx, t.L(), deadline); err != nil {
+				t.L().Printf("failed to extend the cluster for the extended timeout: %s", err)
+			}
+		case <-timeoutTimer.C:
+			testDone = true
+			// NB: We're adding the timeout failure intentionally without cancelling the context
+			// to capture as much state as possible during artifact collection.
+			timeoutMsg := fmt.Sprintf("test timed out (%s)", timeout)
+			// If the Side-Eye integration is active, capture a snapshot of the
+			// cluster while the test is still stuck, and link it in the failure. The
+			// link goes on its own line since the first one is used to fingerprint
+			// the failure.
+			if r.sideEyeClient != nil {
+				if snapURL := c.CaptureSideEyeSnapshot(ctx, t.L(), r.sideEyeClient); snapURL != "" {
+					timeoutMsg += fmt.Sprintf("\nSide-Eye snapshot: %s", snapURL)
+				}
+			}
+			t.addFailure(0, "%s", timeoutMsg)
+			// We suppress other failures from being surfaced to the top as the timeout is always going
+			// to be the main error and subsequent errors (i.e. context cancelled) add noise.
+			t.suppressFailures()
+			timedOut = true
+			// The test goroutine may still be using the cluster.
+			c.taint("test timed out")
+		case preemptedVMs := <-preemptedCh:
+			testDone = true
+			t.Error(vmPreemptionError(preemptedVMs))
+			// The test can't make progress on a cluster that lost VMs, so we
+			// interrupt it instead of waiting for it to notice.
+			cancel()
+			t.L().Printf("VMs preempted during the test run: %s", preemptedVMs)
+		}
+	}
+	// Extensions requested from now on would be ignored, so refuse them.
+	t.finalizeTimeout()
+	stopWatching()
+	stopProbe()
+	stopSampling()
 
 	// Replacing the logger is best effort.
 	replaceLogger := func(name string) {
@@ -1363,6 +1884,36 @@ func getVMNames(fullVMNames []string) string {
 	return strings.Join(vmNames, ", ")
 }
 
+// preemptionPollInterval is how often the cloud provider is polled for
+// preempted VMs while a test runs on spot VMs.
+var preemptionPollInterval = time.Minute
+
+// watchForPreemption polls the cloud provider for preempted VMs of the given
+// cluster, if it uses spot VMs, until the context is canceled. The names of
+// the preempted VMs are sent on the returned channel once some are found.
+func watchForPreemption(ctx context.Context, c *clusterImpl, l *logger.Logger) <-chan string {
+	ch := make(chan string, 1)
+	if c.IsLocal() || !c.spec.UseSpotVMs {
+		return ch
+	}
+	go func() {
+		ticker := time.NewTicker(preemptionPollInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			if names := getPreemptedVMNames(ctx, c, l); names != "" {
+				ch <- names
+				return
+			}
+		}
+	}()
+	return ch
+}
+
 // getPreemptedVMNames returns a comma separated list of preempted VM
 // names, or an empty string if no VM was preempted or an error was found.
 func getPreemptedVMNames(ctx context.Context, c *clusterImpl, l *logger.Logger) string {
@@ -1497,15 +2048,9 @@ func (r *testRunner) teardownTest(
 	ctx context.Context, t *testImpl, c *clusterImpl, timedOut bool,
 ) error {
 	if timedOut || t.Failed() {
-		if timedOut {
-			// If the Side-Eye integration was configured, capture a snapshot of the
-			// cluster to help with debugging.
-			if r.sideEyeClient != nil {
-				c.CaptureSideEyeSnapshot(ctx, t.L(), r.sideEyeClient)
-			}
-		}
-
+		collectStart := timeutil.Now()
 		err := r.collectArtifacts(ctx, t, c, timedOut, time.Hour)
+		r.metrics.recordArtifactCollection(timeutil.Since(collectStart))
 		if err != nil {
 			t.L().Printf("error collecting artifacts: %v", err)
 		}
@@ -1550,6 +2095,11 @@ func (r *testRunner) collectArtifacts(
 		// crashes here in cases where the goroutine leaks but later gets unstuck
 		// and tries to log something.
 		defer close(artifactsCollectedCh)
+		// Fetch the profiles first, while the nodes are in the state that led
+		// to the failure; dumping stacks below may kill them.
+		if err := c.FetchProfiles(ctx, t.L()); err != nil {
+			t.L().Printf("failed to fetch profiles: %s", err)
+		}
 		if timedOut {
 			// Timeouts are often opaque. Improve our changes by dumping the stack
 			// so that at least we can piece together what the test is trying to
@@ -1625,8 +2175,14 @@ func (r *testRunner) collectArtifacts(
 		if err := c.FetchTimeseriesData(ctx, t.L()); err != nil {
 			t.L().Printf("failed to fetch timeseries data: %s", err)
 		}
-		if err := c.FetchDebugZip(ctx, t.L(), "debug.zip"); err != nil {
-			t.L().Printf("failed to collect zip: %s", err)
+		for _, target := range t.debugZipTargets() {
+			var opts []option.Option
+			if len(target.nodes) > 0 {
+				opts = append(opts, target.nodes)
+			}
+			if err := c.FetchDebugZip(ctx, t.L(), target.dest, opts...); err != nil {
+				t.L().Printf("failed to collect %s: %s", target.dest, err)
+			}
 		}
 		if err := c.FetchVMSpecs(ctx, t.L()); err != nil {
 			t.L().Errorf("failed to collect VM specs: %s", err)
@@ -1667,10 +2223,16 @@ func (r *testRunner) generateReport() string {
 	fails := len(r.status.fail)
 	var msg string
 	if fails > 0 {
-		msg = fmt.Sprintf("FAIL (%d fails)\n", fails)
+		msg = fmt.Sprintf("FAIL (%d fails)", fails)
 	} else {
 		msg = "PASS"
 	}
+	if flakes := len(r.status.flaky); flakes > 0 {
+		msg += fmt.Sprintf(" (%d flaky)", flakes)
+	}
+	if fails > 0 {
+		msg += "\n"
+	}
 	return msg
 }
 
@@ -1701,6 +2263,7 @@ func (r *testRunner) removeWorker(ctx context.Context, name string) {
 //	a port automatically (which will be printed to stdout).
 func (r *testRunner) runHTTPServer(httpPort int, stdout io.Writer, bindTo string) error {
 	http.HandleFunc("/", r.serveHTTP)
+	http.HandleFunc(runnerAPIPrefix, r.serveAPI)
 	// Run an http server in the background.
 	// We handle the case where httpPort is 0, which means we automatically
 	// allocate a port.
@@ -1959,6 +2522,31 @@ func zipArtifacts(t *testImpl) error {
 	return moveToZipArchive("artifacts.zip", t.ArtifactsDir(), list...)
 }
 
+// shouldRetry returns whether the given finished test run should be retried on
+// a fresh cluster, as per --retries-per-failure. Skipped tests and
+// infrastructure flakes are not retried.
+func shouldRetry(t *testImpl) bool {
+	if !t.Failed() || t.spec.Skip != "" || t.attempt >= roachtestflags.RetriesPerFailure {
+		return false
+	}
+	errWithOwner := failuresAsErrorWithOwnership(t.failures())
+	return errWithOwner == nil || !errWithOwner.InfraFlake
+}
+
+// wasPreempted returns whether the given test failed because VMs of its
+// cluster were preempted. See vmPreemptionError.
+func wasPreempted(t *testImpl) bool {
+	errWithOwner := failuresAsErrorWithOwnership(t.failures())
+	return errWithOwner != nil && errWithOwner.TitleOverride == "vm_preemption"
+}
+
+// shouldRequeue returns whether the given test run, which ran on spot VMs that
+// were preempted, is to be requeued instead of reported as failed.
+func shouldRequeue(t *testImpl) bool {
+	return t.Failed() && t.spec.Skip == "" && wasPreempted(t) &&
+		t.requeues < roachtestflags.PreemptionRequeues
+}
+
 // testTimeout returns the timeout of a test. The default is set
 // to 3 hours but tests may specify their own timeouts.
 func testTimeout(spec *registry.TestSpec) time.Duration {
@@ -1969,6 +2557,40 @@ func testTimeout(spec *registry.TestSpec) time.Duration {
 	return timeout
 }
 
+var (
+	// cloudTimeoutScaling and archTimeoutScaling are the default factors by
+	// which test timeouts are scaled; local clusters share the resources of a
+	// single machine, and ARM64 machines are often slower or emulated.
+	cloudTimeoutScaling = map[spec.Cloud]float64{spec.Local: 2}
+	archTimeoutScaling  = map[vm.CPUArch]float64{vm.ArchARM64: 2}
+)
+
+// timeoutScale returns the factor by which the timeout of the test is scaled
+// when running on the given cloud and CPU architecture: the --timeout-scale
+// flag if set, else the test's TimeoutScaling for the cloud if any, else the
+// largest of the default factors for the cloud and architecture.
+func timeoutScale(s *registry.TestSpec, cloud spec.Cloud, arch vm.CPUArch) float64 {
+	if roachtestflags.TimeoutScale > 0 {
+		return roachtestflags.TimeoutScale
+	}
+	if f, ok := s.TimeoutScaling[cloud]; ok {
+		return f
+	}
+	scale := 1.0
+	if f, ok := cloudTimeoutScaling[cloud]; ok {
+		scale = max(scale, f)
+	}
+	if f, ok := archTimeoutScaling[arch]; ok {
+		scale = max(scale, f)
+	}
+	return scale
+}
+
+// scaledTestTimeout returns the timeout of the test scaled by timeoutScale.
+func scaledTestTimeout(s *registry.TestSpec, cloud spec.Cloud, arch vm.CPUArch) time.Duration {
+	return time.Duration(float64(testTimeout(s)) * timeoutScale(s, cloud, arch))
+}
+
 // Annotate the start of the test in Grafana and the branch if applicable.
 func grafanaAnnotateTestStart(ctx context.Context, t test.Test, c cluster.Cluster) {
 	const BuildBranch = "TC_BUILD_BRANCH"
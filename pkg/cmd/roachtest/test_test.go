@@ -15,6 +15,7 @@ import (
 	"context"
 	"io"
 	"math/rand"
+	"os"
 	"path/filepath"
 	"regexp"
 	"sort"
@@ -33,6 +34,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/roachprod/logger"
 	"github.com/cockroachdb/cockroach/pkg/roachprod/vm"
 	"github.com/cockroachdb/cockroach/pkg/testutils"
+	"github.com/cockroachdb/cockroach/pkg/util/quotapool"
 	"github.com/cockroachdb/cockroach/pkg/util/stop"
 	"github.com/cockroachdb/cockroach/pkg/util/syncutil"
 	"github.com/cockroachdb/errors"
@@ -215,7 +217,7 @@ func setupRunnerTest(t *testing.T, r testRegistryImpl, testFilters []string) *ru
 	tf, err := registry.NewTestFilter(testFilters)
 	require.NoError(t, err)
 
-	tests, _ := testsToRun(r, tf, false, 1.0, true)
+	tests, _ := testsToRun(r, tf, false, roachtestflags.SelectProbabilities{Default: 1}, true)
 	cr := newClusterRegistry()
 
 	stopper := stop.NewStopper()
@@ -341,6 +343,132 @@ func TestRunnerTestTimeout(t *testing.T) {
 	}
 }
 
+func TestScaledTestTimeout(t *testing.T) {
+	s := &registry.TestSpec{Timeout: time.Hour}
+	require.Equal(t, time.Hour, scaledTestTimeout(s, spec.GCE, vm.ArchAMD64))
+	require.Equal(t, 2*time.Hour, scaledTestTimeout(s, spec.Local, vm.ArchAMD64))
+	require.Equal(t, 2*time.Hour, scaledTestTimeout(s, spec.AWS, vm.ArchARM64))
+	require.Equal(t, 2*time.Hour, scaledTestTimeout(s, spec.Local, vm.ArchARM64))
+
+	s.TimeoutScaling = map[spec.Cloud]float64{spec.Local: 0.5}
+	require.Equal(t, 30*time.Minute, scaledTestTimeout(s, spec.Local, vm.ArchARM64))
+	require.Equal(t, 2*time.Hour, scaledTestTimeout(s, spec.GCE, vm.ArchARM64))
+
+	defer func(scale float64) { roachtestflags.TimeoutScale = scale }(roachtestflags.TimeoutScale)
+	roachtestflags.TimeoutScale = 3
+	require.Equal(t, 3*time.Hour, scaledTestTimeout(s, spec.Local, vm.ArchAMD64))
+}
+
+func TestRunnerRetriesPerFailure(t *testing.T) {
+	ctx := context.Background()
+	defer func(prev int) {
+		roachtestflags.RetriesPerFailure = prev
+	}(roachtestflags.RetriesPerFailure)
+	roachtestflags.RetriesPerFailure = 2
+
+	r := mkReg(t)
+	var flakyRuns, failRuns int32 // atomic
+	r.Add(registry.TestSpec{
+		Name:  "flaky",
+		Owner: OwnerUnitTest,
+		Run: func(ctx context.Context, t test.Test, c cluster.Cluster) {
+			if atomic.AddInt32(&flakyRuns, 1) == 1 {
+				t.Fatal("flaked")
+			}
+		},
+		Cluster:          r.MakeClusterSpec(0),
+		CompatibleClouds: registry.AllExceptAWS,
+		Suites:           registry.Suites(registry.Nightly),
+	})
+	r.Add(registry.TestSpec{
+		Name:  "fail",
+		Owner: OwnerUnitTest,
+		Run: func(ctx context.Context, t test.Test, c cluster.Cluster) {
+			atomic.AddInt32(&failRuns, 1)
+			t.Fatal("failed")
+		},
+		Cluster:          r.MakeClusterSpec(0),
+		CompatibleClouds: registry.AllExceptAWS,
+		Suites:           registry.Suites(registry.Nightly),
+	})
+
+	var infraRuns int32 // atomic
+	r.Add(registry.TestSpec{
+		Name:  "infra",
+		Owner: OwnerUnitTest,
+		Run: func(ctx context.Context, t test.Test, c cluster.Cluster) {
+			if atomic.AddInt32(&infraRuns, 1) == 1 {
+				t.Fatal("failed before the infra flake")
+			}
+			t.Fatal(registry.ErrorWithOwner(registry.OwnerTestEng, errors.New("infra flake"), registry.InfraFlake))
+		},
+		Cluster:          r.MakeClusterSpec(0),
+		CompatibleClouds: registry.AllExceptAWS,
+		Suites:           registry.Suites(registry.Nightly),
+	})
+
+	rt := setupRunnerTest(t, r, nil)
+	err := rt.runner.Run(ctx, rt.tests, 1 /* count */, defaultParallelism, rt.copt, testOpts{}, rt.lopt)
+	require.True(t, testutils.IsError(err, "some tests failed"), "unexpected error: %v", err)
+
+	require.Equal(t, int32(2), atomic.LoadInt32(&flakyRuns))
+	require.Equal(t, int32(3), atomic.LoadInt32(&failRuns))
+	// Infra flakes are not retried, and the failure which the retry was meant
+	// to check is reported.
+	require.Equal(t, int32(2), atomic.LoadInt32(&infraRuns))
+	require.Len(t, rt.runner.status.flaky, 1)
+	require.Len(t, rt.runner.status.fail, 2)
+	require.Empty(t, rt.runner.status.pass)
+	for test := range rt.runner.status.flaky {
+		require.Equal(t, "flaky", test.Name())
+		require.Equal(t, 1, test.attempt)
+		require.Contains(t, test.firstFailure.failureMsg(), "flaked")
+	}
+	for test := range rt.runner.status.fail {
+		switch test.Name() {
+		case "fail":
+			require.Equal(t, 2, test.attempt)
+		case "infra":
+			require.Equal(t, 0, test.attempt)
+			require.Contains(t, test.failureMsg(), "failed before the infra flake")
+		default:
+			t.Fatalf("unexpected failed test %s", test.Name())
+		}
+	}
+	require.Contains(t, rt.stdout.String(), "--- FLAKY: flaky")
+}
+
+func TestRunnerRequeuesPreemptedTests(t *testing.T) {
+	ctx := context.Background()
+	r := mkReg(t)
+	var runs int32 // atomic
+	r.Add(registry.TestSpec{
+		Name:  "preempted",
+		Owner: OwnerUnitTest,
+		Run: func(ctx context.Context, t test.Test, c cluster.Cluster) {
+			if atomic.AddInt32(&runs, 1) == 1 {
+				t.Fatal(vmPreemptionError("my_VM"))
+			}
+		},
+		Cluster:          r.MakeClusterSpec(0, spec.UseSpotVMs()),
+		CompatibleClouds: registry.AllExceptAWS,
+		Suites:           registry.Suites(registry.Nightly),
+	})
+
+	rt := setupRunnerTest(t, r, nil)
+	err := rt.runner.Run(ctx, rt.tests, 1 /* count */, defaultParallelism, rt.copt, testOpts{}, rt.lopt)
+	require.NoError(t, err)
+
+	require.Equal(t, int32(2), atomic.LoadInt32(&runs))
+	require.Len(t, rt.runner.status.pass, 1)
+	require.Empty(t, rt.runner.status.flaky)
+	for test := range rt.runner.status.pass {
+		require.Equal(t, 1, test.requeues)
+		require.False(t, test.spec.Cluster.UseSpotVMs)
+	}
+	require.Contains(t, rt.stdout.String(), "requeuing on on-demand VMs (requeue 1 of 2)")
+}
+
 func TestRegistryPrepareSpec(t *testing.T) {
 	dummyRun := func(context.Context, test.Test, cluster.Cluster) {}
 
@@ -419,7 +547,7 @@ func runExitCodeTest(t *testing.T, injectedError error) error {
 	tf, err := registry.NewTestFilter(nil)
 	require.NoError(t, err)
 
-	tests, _ := testsToRun(r, tf, false, 1.0, true)
+	tests, _ := testsToRun(r, tf, false, roachtestflags.SelectProbabilities{Default: 1}, true)
 	lopt := loggingOpt{
 		l:            nilLogger(),
 		tee:          logger.NoTee,
@@ -481,3 +609,84 @@ func TestNewCluster(t *testing.T) {
 		})
 	}
 }
+
+func TestAdmitWorkers(t *testing.T) {
+	defer func(d time.Duration) { autoParallelismInterval = d }(autoParallelismInterval)
+	autoParallelismInterval = time.Millisecond
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stopper := stop.NewStopper()
+	defer stopper.Stop(ctx)
+	r := &testRunner{stopper: stopper}
+	var specs []registry.TestSpec
+	for _, name := range []string{"a", "b", "c", "d"} {
+		specs = append(specs, registry.TestSpec{Name: name, Cluster: spec.ClusterSpec{NodeCount: 1, CPUs: 4}})
+	}
+	r.work = newWorkPool(specs, 1 /* count */)
+
+	// The quota fits two of the tests at a time.
+	qp := quotapool.NewIntPool("cloud cpu", 10)
+	var started int
+	r.admitWorkers(ctx, qp, nilLogger(), len(specs), func(i int) {
+		require.Equal(t, started, i)
+		started++
+		// The worker selects a test and holds onto its quota.
+		ttr, _, err := r.work.selectTest(ctx, qp, nilLogger())
+		require.NoError(t, err)
+		require.False(t, ttr.noWork)
+		if started == 2 {
+			time.AfterFunc(50*time.Millisecond, cancel)
+		}
+	})
+	require.Equal(t, 2, started)
+}
+
+func TestClusterResourceLabels(t *testing.T) {
+	runLabels := map[string]string{VmLabelTestRunID: "teamcity-123", VmLabelTestSuite: "nightly"}
+	labels := clusterResourceLabels(runLabels, registry.TestSpec{Name: "kv0/nodes=3", Owner: registry.OwnerKV})
+	require.Equal(t, map[string]string{
+		VmLabelTestName:  "kv0-nodes-3",
+		VmLabelTestOwner: "kv",
+		VmLabelTestRunID: "teamcity-123",
+		VmLabelTestSuite: "nightly",
+	}, labels)
+
+	// The labels are added to the default ones, which are left untouched.
+	defaults := map[string]string{"usage": "roachtest"}
+	withRunLabels := withLabels(defaults, runLabels)
+	require.Equal(t, map[string]string{"usage": "roachtest"}, defaults)
+	require.Equal(t, "roachtest", withRunLabels["usage"])
+	require.Equal(t, "nightly", withRunLabels[VmLabelTestSuite])
+
+	// Once a test is done with a reused cluster, the labels it overwrote are
+	// restored, and only the ones it added are removed.
+	restore, remove := labelsToRevert(labels, map[string]string{
+		VmLabelTestName:  "kv50_run_1",
+		VmLabelTestOwner: "kv",
+		"extra":          "label",
+	})
+	require.Equal(t, map[string]string{VmLabelTestName: "kv0-nodes-3", VmLabelTestOwner: "kv"}, restore)
+	require.Equal(t, []string{"extra"}, remove)
+
+	// Clusters not created by roachtest have no creation labels.
+	restore, remove = labelsToRevert(nil, map[string]string{VmLabelTestName: "kv50_run_1"})
+	require.Empty(t, restore)
+	require.Equal(t, []string{VmLabelTestName}, remove)
+}
+
+func TestParseNodeBinaryOverride(t *testing.T) {
+	binary := filepath.Join(t.TempDir(), "cockroach-patched")
+	require.NoError(t, os.WriteFile(binary, nil, 0755))
+
+	overrides, err := parseNodeBinaryOverride(map[string]string{"1": binary, "3": binary})
+	require.NoError(t, err)
+	require.Equal(t, map[int]string{1: binary, 3: binary}, overrides)
+
+	_, err = parseNodeBinaryOverride(map[string]string{"0": binary})
+	require.ErrorContains(t, err, "nodes are numbered from 1")
+	_, err = parseNodeBinaryOverride(map[string]string{"n1": binary})
+	require.ErrorContains(t, err, "nodes are numbered from 1")
+	_, err = parseNodeBinaryOverride(map[string]string{"2": binary + ".missing"})
+	require.ErrorContains(t, err, "binary override for n2")
+}
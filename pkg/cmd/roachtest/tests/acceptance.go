@@ -12,7 +12,6 @@ package tests
 
 import (
 	"context"
-	"strings"
 	"time"
 
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/cluster"
@@ -26,12 +25,15 @@ func registerAcceptance(r registry.Registry) {
 	cloudsWithoutServiceRegistration := registry.AllClouds.Remove(registry.CloudsWithServiceRegistration)
 
 	testCases := map[registry.Owner][]struct {
-		name               string
-		fn                 func(ctx context.Context, t test.Test, c cluster.Cluster)
-		skip               string
-		numNodes           int
-		nodeRegions        []string
+		name     string
+		fn       func(ctx context.Context, t test.Test, c cluster.Cluster)
+		skip     string
+		numNodes int
+		// topologies, if set, registers a variant of the test for each region
+		// topology instead of a single test with numNodes nodes.
+		topologies         []registry.RegionTopology
 		timeout            time.Duration
+		timeoutScaling     map[spec.Cloud]float64
 		encryptionSupport  registry.EncryptionSupport
 		defaultLeases      bool
 		requiresLicense    bool
@@ -76,11 +78,13 @@ func registerAcceptance(r registry.Registry) {
 		},
 		registry.OwnerTestEng: {
 			{
-				name:          "version-upgrade",
-				fn:            runVersionUpgrade,
-				timeout:       2 * time.Hour, // actually lower in local runs; see `runVersionUpgrade`
-				defaultLeases: true,
-				nativeLibs:    registry.LibGEOS,
+				name:    "version-upgrade",
+				fn:      runVersionUpgrade,
+				timeout: 2 * time.Hour,
+				// Local runs perform a single upgrade; see `runVersionUpgrade`.
+				timeoutScaling: map[spec.Cloud]float64{spec.Local: 0.25},
+				defaultLeases:  true,
+				nativeLibs:     registry.LibGEOS,
 			},
 		},
 		registry.OwnerDisasterRecovery: {
@@ -111,6 +115,11 @@ func registerAcceptance(r registry.Registry) {
 				fn:       runMismatchedLocalityTest,
 				numNodes: 3,
 			},
+			{
+				name:     "declarative-schema-changer",
+				fn:       runDeclarativeSchemaChangerSmoke,
+				numNodes: 3,
+			},
 		},
 	}
 	for owner, tests := range testCases {
@@ -122,15 +131,6 @@ func registerAcceptance(r registry.Registry) {
 			}
 
 			var extraOptions []spec.Option
-			if tc.nodeRegions != nil {
-				// Sanity: Ensure the region counts are sane.
-				if len(tc.nodeRegions) != numNodes {
-					panic(errors.AssertionFailedf("region list doesn't match number of nodes"))
-				}
-				extraOptions = append(extraOptions, spec.Geo())
-				extraOptions = append(extraOptions, spec.GCEZones(strings.Join(tc.nodeRegions, ",")))
-			}
-
 			if tc.workloadNode {
 				extraOptions = append(extraOptions, spec.WorkloadNode())
 			}
@@ -157,12 +157,20 @@ func registerAcceptance(r registry.Registry) {
 			if tc.timeout != 0 {
 				testSpec.Timeout = tc.timeout
 			}
+			testSpec.TimeoutScaling = tc.timeoutScaling
 			if !tc.defaultLeases {
 				testSpec.Leases = registry.MetamorphicLeases
 			}
 			if len(tc.nativeLibs) > 0 {
 				testSpec.NativeLibs = tc.nativeLibs
 			}
+			if len(tc.topologies) > 0 {
+				registry.AddMultiRegionTests(r, testSpec, tc.topologies,
+					func(ctx context.Context, t test.Test, c cluster.Cluster, _ registry.RegionTopology) {
+						tc.fn(ctx, t, c)
+					}, extraOptions...)
+				continue
+			}
 			testSpec.Run = func(ctx context.Context, t test.Test, c cluster.Cluster) {
 				tc.fn(ctx, t, c)
 			}
@@ -55,6 +55,7 @@ func registerCancel(r registry.Registry) {
 			t.Status("restoring TPCH dataset for Scale Factor 1")
 			if err := loadTPCHDataset(
 				ctx, t, c, conn, 1 /* sf */, c.NewMonitor(ctx), c.All(), false, /* disableMergeQueue */
+				tpchLegacyBackup,
 			); err != nil {
 				t.Fatal(err)
 			}
@@ -12,12 +12,15 @@ package tests
 
 import (
 	"context"
+	"fmt"
 	"time"
 
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachprod/grafana"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/cluster"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/option"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/test"
 	"github.com/cockroachdb/cockroach/pkg/roachprod/install"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/logger"
 	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
 	"github.com/cockroachdb/errors"
 )
@@ -97,6 +100,21 @@ func (ch *Chaos) sendEvent(t ChaosEventType, target option.NodeListOption) {
 	}
 }
 
+// annotate adds a grafana annotation for a chaos event, over the time range
+// starting at start if it is set. Errors are logged.
+func (ch *Chaos) annotate(
+	ctx context.Context, c cluster.Cluster, l *logger.Logger, text string, start time.Time,
+) {
+	req := grafana.AddAnnotationRequest{Text: "chaos: " + text, Tags: []string{"chaos"}}
+	if !start.IsZero() {
+		req.StartTime = start.UnixMilli()
+		req.EndTime = timeutil.Now().UnixMilli()
+	}
+	if err := c.AddGrafanaAnnotation(ctx, l, req); err != nil {
+		l.Printf("error adding grafana annotation: %v", err)
+	}
+}
+
 // Runner returns a closure that runs chaos against the given cluster without
 // setting off the monitor. The process returns without an error after the chaos
 // duration.
@@ -154,6 +172,8 @@ func (ch *Chaos) Runner(
 				}
 			}
 			ch.sendEvent(ChaosEventTypeShutdownComplete, target)
+			stoppedAt := timeutil.Now()
+			ch.annotate(ctx, c, l, fmt.Sprintf("stopped %s", target), time.Time{})
 
 			select {
 			case <-ch.Stopper:
@@ -197,6 +217,7 @@ func (ch *Chaos) Runner(
 				return errors.Wrapf(err, "could not restart node %s", target)
 			}
 			ch.sendEvent(ChaosEventTypeStartupComplete, target)
+			ch.annotate(ctx, c, l, fmt.Sprintf("%s down", target), stoppedAt)
 		}
 	}
 }
@@ -619,16 +619,11 @@ func (rd *replicationDriver) setupC2C(
 		require.NoError(rd.t, rd.c.StartGrafana(ctx, promLog, rd.setup.promCfg))
 		rd.t.L().Printf("Prom has started")
 	}
+	// The source and destination are separate clusters, so each needs its own
+	// debug zip.
+	t.CollectDebugZipOnFailure("source_debug.zip", rd.setup.src.nodes...)
+	t.CollectDebugZipOnFailure("dest_debug.zip", rd.setup.dst.nodes...)
 	return func() {
-		backgroundCtx := context.Background()
-		if t.Failed() {
-			// Use a new context to grab the debug zips, as the parent context may
-			// have already been cancelled by the roachtest test infra.
-			debugCtx, cancel := context.WithTimeout(backgroundCtx, time.Minute*5)
-			defer cancel()
-			rd.fetchDebugZip(debugCtx, rd.setup.src.nodes, "source_debug.zip")
-			rd.fetchDebugZip(debugCtx, rd.setup.dst.nodes, "dest_debug.zip")
-		}
 		srcDB.Close()
 		destDB.Close()
 	}
@@ -21,6 +21,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/cluster"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/option"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/roachtestutil"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/test"
 	"github.com/cockroachdb/cockroach/pkg/roachprod"
 	"github.com/cockroachdb/cockroach/pkg/roachprod/install"
@@ -128,6 +129,11 @@ func runTest(ctx context.Context, t test.Test, c cluster.Cluster, pg string) {
 		rate, dataRate, t.PerfArtifactsDir(),
 	)
 	c.Run(ctx, option.WithNodes(c.Node(1)), cmd)
+
+	metrics := roachtestutil.NewPerfMetrics()
+	metrics.AddValue("copy_row_rate", roachtestutil.PerfUnitOpsPerSec, float64(rate), nil)
+	metrics.AddValue("copy_data_rate", roachtestutil.PerfUnitBytesPerSec, bytes/dur.Seconds(), nil)
+	require.NoError(t, metrics.Write(ctx, t, c, c.Node(1)))
 }
 
 func runCopyFromPG(ctx context.Context, t test.Test, c cluster.Cluster, sf int) {
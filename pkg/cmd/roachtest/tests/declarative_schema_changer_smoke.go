@@ -0,0 +1,158 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package tests
+
+import (
+	"context"
+	gosql "database/sql"
+	"fmt"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/cluster"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/option"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/test"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/install"
+	"github.com/cockroachdb/cockroach/pkg/testutils"
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/require"
+)
+
+// dscSmokeAppName is the application name of the session issuing the schema
+// changes, which scopes the pausepoints of their jobs to them.
+const dscSmokeAppName = "dsc_smoke"
+
+// runDeclarativeSchemaChangerSmoke runs a representative set of schema changes
+// with the declarative schema changer on a 3-node cluster. The jobs of the
+// schema changes which backfill are paused after their first post-commit
+// stage, and resumed once the node which issued them restarts, so that they
+// complete on a different node than the one they started on.
+func runDeclarativeSchemaChangerSmoke(ctx context.Context, t test.Test, c cluster.Cluster) {
+	c.Start(ctx, t.L(), option.DefaultStartOpts(), install.MakeClusterSettings())
+	db := c.Conn(ctx, t.L(), 2)
+	defer db.Close()
+	require.NoError(t, WaitFor3XReplication(ctx, t, t.L(), db))
+	require.NoError(t, setShortJobIntervalsCommon(func(query string, args ...interface{}) error {
+		_, err := db.ExecContext(ctx, query, args...)
+		return err
+	}))
+	for _, stmt := range []string{
+		`CREATE TABLE t (k INT PRIMARY KEY, v INT, s VARCHAR(10), d INT)`,
+		`INSERT INTO t SELECT i, i % 100, 'row' || i::STRING, i FROM generate_series(1, 10000) AS g(i)`,
+	} {
+		_, err := db.ExecContext(ctx, stmt)
+		require.NoError(t, err)
+	}
+	var user string
+	require.NoError(t, db.QueryRowContext(ctx, `SELECT current_user()`).Scan(&user))
+
+	// issue runs the given schema change from n1, in a session which always
+	// uses the declarative schema changer so that it can't silently fall back
+	// to the legacy one.
+	issue := func(ctx context.Context, stmt string) error {
+		n1 := c.Conn(ctx, t.L(), 1)
+		defer n1.Close()
+		conn, err := n1.Conn(ctx)
+		if err != nil {
+			return err
+		}
+		defer conn.Close()
+		for _, setup := range []string{
+			fmt.Sprintf(`SET application_name = '%s'`, dscSmokeAppName),
+			`SET use_declarative_schema_changer = unsafe_always`,
+		} {
+			if _, err := conn.ExecContext(ctx, setup); err != nil {
+				return err
+			}
+		}
+		_, err = conn.ExecContext(ctx, stmt)
+		return err
+	}
+
+	for _, change := range []struct {
+		stmt string
+		// restart, if set, restarts n1 while the job of the schema change is
+		// paused.
+		restart bool
+	}{
+		{stmt: `CREATE INDEX t_v_idx ON t (v)`, restart: true},
+		{stmt: `ALTER TABLE t ADD COLUMN c INT NOT NULL DEFAULT 42`, restart: true},
+		{stmt: `ALTER TABLE t ALTER COLUMN s TYPE VARCHAR(20)`},
+		{stmt: `ALTER TABLE t DROP COLUMN d`},
+	} {
+		t.Status(change.stmt)
+		if !change.restart {
+			require.NoError(t, issue(ctx, change.stmt))
+			continue
+		}
+
+		_, err := db.ExecContext(ctx, `SET CLUSTER SETTING jobs.debug.pausepoints = $1`,
+			fmt.Sprintf("schemachanger.%s.%s.1", user, dscSmokeAppName))
+		require.NoError(t, err)
+		// The statement doesn't return successfully: it either fails once its
+		// job pauses, or when n1 restarts.
+		issueErr := make(chan error, 1)
+		go func() { issueErr <- issue(ctx, change.stmt) }()
+
+		var jobID int64
+		testutils.SucceedsWithin(t, func() error {
+			return db.QueryRowContext(ctx, `SELECT job_id FROM [SHOW JOBS]
+WHERE job_type = 'NEW SCHEMA CHANGE' AND status = 'paused'`).Scan(&jobID)
+		}, 5*time.Minute)
+		t.L().Printf("job %d of %q paused", jobID, change.stmt)
+
+		c.Stop(ctx, t.L(), option.DefaultStopOpts(), c.Node(1))
+		t.L().Printf("statement returned: %v", <-issueErr)
+		c.Start(ctx, t.L(), option.DefaultStartOpts(), install.MakeClusterSettings(), c.Node(1))
+
+		_, err = db.ExecContext(ctx, `SET CLUSTER SETTING jobs.debug.pausepoints = ''`)
+		require.NoError(t, err)
+		_, err = db.ExecContext(ctx, `RESUME JOB $1`, jobID)
+		require.NoError(t, err)
+		waitForDSCJob(ctx, t, db, jobID)
+	}
+
+	t.Status("validating the table")
+	for _, check := range []struct {
+		query    string
+		expected string
+	}{
+		{`SELECT count(*) FROM crdb_internal.invalid_objects`, "0"},
+		{`SELECT count(*) FROM t@t_v_idx`, "10000"},
+		{`SELECT count(*) FROM t WHERE c = 42`, "10000"},
+		{`SELECT data_type FROM [SHOW COLUMNS FROM t] WHERE column_name = 's'`, "VARCHAR(20)"},
+		{`SELECT count(*) FROM [SHOW COLUMNS FROM t] WHERE column_name = 'd'`, "0"},
+		{`SELECT count(*) FROM [SHOW JOBS]
+WHERE job_type = 'NEW SCHEMA CHANGE' AND status != 'succeeded'`, "0"},
+	} {
+		var actual string
+		require.NoError(t, db.QueryRowContext(ctx, check.query).Scan(&actual), check.query)
+		require.Equal(t, check.expected, actual, check.query)
+	}
+}
+
+// waitForDSCJob waits for the given declarative schema changer job to
+// succeed, and fails the test if it fails.
+func waitForDSCJob(ctx context.Context, t test.Test, db *gosql.DB, jobID int64) {
+	testutils.SucceedsWithin(t, func() error {
+		var status, jobErr string
+		if err := db.QueryRowContext(ctx,
+			`SELECT status, error FROM [SHOW JOB $1]`, jobID).Scan(&status, &jobErr); err != nil {
+			return err
+		}
+		switch status {
+		case "succeeded":
+			return nil
+		case "failed", "canceled":
+			t.Fatalf("job %d %s: %s", jobID, status, jobErr)
+		}
+		return errors.Newf("job %d is %s", jobID, status)
+	}, 5*time.Minute)
+}
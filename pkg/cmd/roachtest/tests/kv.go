@@ -356,6 +356,13 @@ func registerKV(r registry.Registry) {
 			tags["weekly"] = struct{}{}
 		}
 
+		// The read-heavy tests with the default small blocks serve their reads
+		// from memory and are expected to be CPU-bound.
+		var bottleneck registry.Resource
+		if opts.readPercent >= 95 && opts.blockSize == 0 && !opts.spanReads {
+			bottleneck = registry.CPUBottleneck
+		}
+
 		r.Add(registry.TestSpec{
 			Name:      strings.Join(nameParts, "/"),
 			Owner:     owner,
@@ -367,6 +374,7 @@ func registerKV(r registry.Registry) {
 			CompatibleClouds:  clouds,
 			Suites:            suites,
 			EncryptionSupport: encryption,
+			Bottleneck:        bottleneck,
 		})
 	}
 }
@@ -954,6 +962,97 @@ func measureQPS(
 	}
 }
 
+// registerKVStaged registers a staged kv test, which splits a long-running
+// workload into stages so that it can span multiple runs on the same cluster,
+// e.g. with --stop-after-stage=init and, later, --cluster and --wipe=false.
+// Each stage starts the cluster and stops it when done, since a resumed run
+// attaches to a stopped cluster.
+func registerKVStaged(r registry.Registry) {
+	runWorkload := func(ctx context.Context, t test.Test, c cluster.Cluster, duration time.Duration) {
+		m := c.NewMonitor(ctx, c.CRDBNodes())
+		m.Go(func(ctx context.Context) error {
+			return c.RunE(ctx, option.WithNodes(c.WorkloadNode()), fmt.Sprintf(
+				"./cockroach workload run kv --read-percent=50 --concurrency=64 --duration=%s {pgurl%s}",
+				duration, c.CRDBNodes()))
+		})
+		m.Wait()
+	}
+
+	r.Add(registry.TestSpec{
+		Name:             "kv50/staged",
+		Owner:            registry.OwnerTestEng,
+		Cluster:          r.MakeClusterSpec(4, spec.WorkloadNode()),
+		CompatibleClouds: registry.AllExceptAWS,
+		Suites:           registry.Suites(registry.Weekly),
+		Timeout:          3 * time.Hour,
+		Stages: []registry.Stage{
+			{
+				Name: "init",
+				Run: func(ctx context.Context, t test.Test, c cluster.Cluster) {
+					c.Start(ctx, t.L(), option.DefaultStartOpts(), install.MakeClusterSettings(), c.CRDBNodes())
+					c.Run(ctx, option.WithNodes(c.WorkloadNode()), fmt.Sprintf(
+						"./cockroach workload init kv --splits=1000 {pgurl:1-%d}", len(c.CRDBNodes())))
+					c.Stop(ctx, t.L(), option.DefaultStopOpts(), c.CRDBNodes())
+				},
+			},
+			{
+				Name: "run",
+				Run: func(ctx context.Context, t test.Test, c cluster.Cluster) {
+					c.Start(ctx, t.L(), option.DefaultStartOpts(), install.MakeClusterSettings(), c.CRDBNodes())
+					runWorkload(ctx, t, c, time.Hour)
+					c.Stop(ctx, t.L(), option.DefaultStopOpts(), c.CRDBNodes())
+				},
+			},
+			{
+				Name: "restart",
+				Run: func(ctx context.Context, t test.Test, c cluster.Cluster) {
+					c.Start(ctx, t.L(), option.DefaultStartOpts(), install.MakeClusterSettings(), c.CRDBNodes())
+					// Restart each node in turn, with the workload tolerating the
+					// errors of the connections to the stopped node, then check that
+					// the workload recovers on all of them.
+					for _, n := range c.CRDBNodes() {
+						t.Status(fmt.Sprintf("restarting n%d", n))
+						c.Stop(ctx, t.L(), option.DefaultStopOpts(), c.Node(n))
+						c.Run(ctx, option.WithNodes(c.WorkloadNode()), fmt.Sprintf(
+							"./cockroach workload run kv --read-percent=50 --concurrency=64 --duration=5m --tolerate-errors {pgurl%s}",
+							c.CRDBNodes()))
+						c.Start(ctx, t.L(), option.DefaultStartOpts(), install.MakeClusterSettings(), c.Node(n))
+					}
+					runWorkload(ctx, t, c, 10*time.Minute)
+					c.Stop(ctx, t.L(), option.DefaultStopOpts(), c.CRDBNodes())
+				},
+			},
+		},
+	})
+}
+
+// registerKVIPv6 runs a short kv workload against a secure cluster of
+// IPv6-only VMs, which exercises the IPv6 listen and advertise addresses, the
+// node certificates' IPv6 SANs and the workload's connections over IPv6.
+func registerKVIPv6(r registry.Registry) {
+	r.Add(registry.TestSpec{
+		Name:             "kv50/ipv6",
+		Owner:            registry.OwnerTestEng,
+		Cluster:          r.MakeClusterSpec(4, spec.WorkloadNode(), spec.IPv6()),
+		CompatibleClouds: registry.OnlyGCE,
+		Suites:           registry.Suites(registry.Nightly),
+		Timeout:          time.Hour,
+		Run: func(ctx context.Context, t test.Test, c cluster.Cluster) {
+			c.Start(ctx, t.L(), option.DefaultStartOpts(),
+				install.MakeClusterSettings(install.SecureOption(true)), c.CRDBNodes())
+			c.Run(ctx, option.WithNodes(c.WorkloadNode()), fmt.Sprintf(
+				"./cockroach workload init kv {pgurl:1-%d}", len(c.CRDBNodes())))
+			m := c.NewMonitor(ctx, c.CRDBNodes())
+			m.Go(func(ctx context.Context) error {
+				return c.RunE(ctx, option.WithNodes(c.WorkloadNode()), fmt.Sprintf(
+					"./cockroach workload run kv --read-percent=50 --concurrency=32 --duration=10m {pgurl%s}",
+					c.CRDBNodes()))
+			})
+			m.Wait()
+		},
+	})
+}
+
 // registerKVRestartImpact measures the impact of stopping and then restarting
 // a node during a write-heavy workload. Specifically the Raft log on the node
 // falls behind when the node is down and when it comes back up it goes into IO
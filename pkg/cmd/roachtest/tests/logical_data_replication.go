@@ -267,15 +267,8 @@ func (mc *multiCluster) StartCluster(
 	pgURL, err := copyPGCertsAndMakeURL(ctx, t, mc.c, node, clusterSettings.PGUrlCertsDir, addr[0])
 	require.NoError(t, err)
 
+	t.CollectDebugZipOnFailure(fmt.Sprintf("%s_debug.zip", desc), nodes...)
 	cleanup := func() {
-		if t.Failed() {
-			debugCtx, cancel := context.WithTimeout(context.Background(), time.Minute*5)
-			defer cancel()
-			filename := fmt.Sprintf("%s_debug.zip", desc)
-			if err := mc.c.FetchDebugZip(debugCtx, t.L(), filename, nodes); err != nil {
-				t.L().Printf("failed to download debug zip to %s from node %s", filename, nodes)
-			}
-		}
 		db.Close()
 	}
 
@@ -54,6 +54,7 @@ func runMultiTenantTPCH(
 		t.Status("restoring TPCH dataset for Scale Factor 1 in ", setupNames[setupIdx])
 		if err := loadTPCHDataset(
 			ctx, t, c, conn, 1 /* sf */, c.NewMonitor(ctx), c.All(), false, /* disableMergeQueue */
+			tpchLegacyBackup,
 		); err != nil {
 			t.Fatal(err)
 		}
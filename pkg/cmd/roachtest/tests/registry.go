@@ -85,6 +85,8 @@ func RegisterTests(r registry.Registry) {
 	registerKVScalability(r)
 	registerKVSplits(r)
 	registerKVRestartImpact(r)
+	registerKVStaged(r)
+	registerKVIPv6(r)
 	registerKnex(r)
 	registerLOQRecovery(r)
 	registerLargeRange(r)
@@ -156,6 +158,7 @@ func RegisterTests(r registry.Registry) {
 	registerTPCHVec(r)
 	registerTypeORM(r)
 	registerUnoptimizedQueryOracle(r)
+	registerVersionUpgradeMixedArch(r)
 	registerYCSB(r)
 	registerDeclarativeSchemaChangerJobCompatibilityInMixedVersion(r)
 	registerMultiRegionMixedVersion(r)
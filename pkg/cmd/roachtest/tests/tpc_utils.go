@@ -20,15 +20,42 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/test"
 	"github.com/cockroachdb/cockroach/pkg/roachprod/install"
 	"github.com/cockroachdb/cockroach/pkg/sql/pgwire/pgcode"
+	"github.com/cockroachdb/cockroach/pkg/util/version"
 	"github.com/cockroachdb/errors"
 	"github.com/lib/pq"
 )
 
+// tpchDatasetSource is where loadTPCHDataset loads a TPC-H dataset from.
+type tpchDatasetSource int
+
+const (
+	// tpchLegacyBackup restores the dataset from the backup of its scale factor
+	// which was taken by an old release, ignoring the version incompatibility.
+	tpchLegacyBackup tpchDatasetSource = iota
+	// tpchVersionedBackup restores the dataset from the backup of its scale
+	// factor taken by the release series under test (see tpchBackupCollection).
+	// If there isn't one, it imports the dataset with `workload fixtures
+	// import` and backs it up into the collection for the next runs.
+	tpchVersionedBackup
+)
+
+// tpchBackupCollection returns the collection of the backups of the TPC-H
+// dataset of the given scale factor taken by binaries of the given release
+// series. The backups are created by the first test which loads the dataset
+// with tpchVersionedBackup on each release series, which imports it and backs
+// it up into the collection.
+func tpchBackupCollection(sf int, v *version.Version) string {
+	return fmt.Sprintf(
+		"gs://cockroach-fixtures-us-east1/workload/tpch/scalefactor=%d/v%d.%d?AUTH=implicit",
+		sf, v.Major(), v.Minor(),
+	)
+}
+
 // loadTPCHDataset loads a TPC-H dataset for the specific benchmark spec on the
 // provided roachNodes. The function is idempotent and first checks whether a
 // compatible dataset exists (compatible is defined as a tpch dataset with a
 // scale factor at least as large as the provided scale factor), performing an
-// expensive dataset restore only if it doesn't.
+// expensive dataset load from the given source only if it doesn't.
 //
 // The function disables auto stats collection and ensures that table statistics
 // are present for all TPCH tables.
@@ -41,6 +68,7 @@ func loadTPCHDataset(
 	m cluster.Monitor,
 	roachNodes option.NodeListOption,
 	disableMergeQueue bool,
+	source tpchDatasetSource,
 ) (retErr error) {
 	_, err := db.Exec("SET CLUSTER SETTING sql.stats.automatic_collection.enabled = false;")
 	if retErr != nil {
@@ -96,6 +124,37 @@ func loadTPCHDataset(
 		return err
 	}
 
+	var query string
+	switch source {
+	case tpchLegacyBackup:
+		tpchURL := fmt.Sprintf("gs://cockroach-fixtures-us-east1/workload/tpch/scalefactor=%d/backup?AUTH=implicit", sf)
+		query = fmt.Sprintf(`RESTORE tpch.* FROM '%s' WITH into_db = 'tpch', unsafe_restore_incompatible_version;`, tpchURL)
+	case tpchVersionedBackup:
+		collection := tpchBackupCollection(sf, t.BuildVersion())
+		if !hasBackups(ctx, t, db, collection) {
+			t.L().Printf("no backup of tpch scale factor %d for this release in %s, importing it\n", sf, collection)
+			if err := c.RunE(ctx, option.WithNodes(roachNodes[:1]), fmt.Sprintf(
+				"./cockroach workload fixtures import tpch --scale-factor=%d {pgurl%s}", sf, roachNodes[:1],
+			)); err != nil {
+				return err
+			}
+			// Back up the imported dataset so that the next runs on this release
+			// series restore it instead. A failure to do so, e.g. because the
+			// credentials of the cluster can't write to the bucket, only means
+			// that the next runs import it too.
+			t.L().Printf("backing up tpch scale factor %d into %s\n", sf, collection)
+			if _, err := db.ExecContext(ctx, fmt.Sprintf(
+				`BACKUP DATABASE tpch INTO '%s'`, collection,
+			)); err != nil {
+				t.L().Printf("failed to back up tpch scale factor %d: %v", sf, err)
+			}
+			return nil
+		}
+		query = fmt.Sprintf(`RESTORE tpch.* FROM LATEST IN '%s' WITH into_db = 'tpch';`, collection)
+	default:
+		return errors.AssertionFailedf("unknown tpch dataset source %d", source)
+	}
+
 	t.L().Printf("restoring tpch scale factor %d\n", sf)
 	// Lower the target size for the restore spans so that we get more ranges.
 	// This is useful to exercise the parallelism across ranges within a single
@@ -103,15 +162,64 @@ func loadTPCHDataset(
 	if _, err := db.ExecContext(ctx, "SET CLUSTER SETTING backup.restore_span.target_size = '64MiB';"); err != nil {
 		return err
 	}
-	tpchURL := fmt.Sprintf("gs://cockroach-fixtures-us-east1/workload/tpch/scalefactor=%d/backup?AUTH=implicit", sf)
 	if _, err := db.ExecContext(ctx, `CREATE DATABASE IF NOT EXISTS tpch;`); err != nil {
 		return err
 	}
-	query := fmt.Sprintf(`RESTORE tpch.* FROM '%s' WITH into_db = 'tpch', unsafe_restore_incompatible_version;`, tpchURL)
 	_, err = db.ExecContext(ctx, query)
 	return err
 }
 
+// loadTPCDSDataset restores the TPC-DS dataset of the given scale factor,
+// unless a tpcds database already exists (e.g. because the cluster is reused
+// with --wipe=false), then scatters its tables and waits for them to be fully
+// replicated. Only scale factor 1 is backed up at the moment.
+//
+// The function disables auto stats collection and leaves db using the tpcds
+// database. Unlike loadTPCHDataset, it doesn't collect statistics, which is
+// left to the caller.
+func loadTPCDSDataset(ctx context.Context, t test.Test, db *gosql.DB, sf int) error {
+	if _, err := db.ExecContext(
+		ctx, "SET CLUSTER SETTING sql.stats.automatic_collection.enabled = false;",
+	); err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, `USE tpcds`); err == nil {
+		t.L().Printf("found existing tpcds dataset, continuing\n")
+		return nil
+	} else if pqErr := (*pq.Error)(nil); !(errors.As(err, &pqErr) &&
+		pgcode.MakeCode(string(pqErr.Code)) == pgcode.InvalidCatalogName) {
+		return err
+	}
+
+	t.Status(fmt.Sprintf("restoring tpcds dataset for scale factor %d", sf))
+	tpcdsURL := fmt.Sprintf("gs://cockroach-fixtures-us-east1/workload/tpcds/scalefactor=%d/backup?AUTH=implicit", sf)
+	if _, err := db.ExecContext(ctx, fmt.Sprintf(
+		`RESTORE DATABASE tpcds FROM '%s' WITH unsafe_restore_incompatible_version;`, tpcdsURL,
+	)); err != nil {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, `USE tpcds`); err != nil {
+		return err
+	}
+	scatterTables(t, db, tpcdsTables)
+	t.Status("waiting for full replication")
+	return WaitFor3XReplication(ctx, t, t.L(), db)
+}
+
+// hasBackups returns whether there are backups in the given collection. Errors
+// listing the collection, e.g. because it doesn't exist, are logged and treated
+// as an empty collection.
+func hasBackups(ctx context.Context, t test.Test, db *gosql.DB, collection string) bool {
+	var count int
+	if err := db.QueryRowContext(
+		ctx, fmt.Sprintf(`SELECT count(*) FROM [SHOW BACKUPS IN '%s']`, collection),
+	).Scan(&count); err != nil {
+		t.L().Printf("failed to list the backups in %s: %v", collection, err)
+		return false
+	}
+	return count > 0
+}
+
 // scatterTables runs "ALTER TABLE ... SCATTER" statement for every table in
 // tableNames. It assumes that conn is already using the target database. If an
 // error is encountered, the test is failed.
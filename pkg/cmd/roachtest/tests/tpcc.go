@@ -286,6 +286,11 @@ func runTPCC(
 	m := c.NewMonitor(ctx, c.CRDBNodes())
 	m.ExpectDeaths(int32(opts.ExpectedDeaths))
 	rampDur := rampDuration(c.IsLocal())
+	promPorts := make([]int, len(workloadInstances))
+	for i := range workloadInstances {
+		promPorts[i] = workloadInstances[i].prometheusPort
+	}
+	roachtestutil.ScrapeWorkloadHistograms(ctx, l, c, c.WorkloadNode(), promPorts...)
 	for i := range workloadInstances {
 		// Make a copy of i for the goroutine.
 		i := i
@@ -29,62 +29,42 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
+var tpcdsTables = []string{
+	`call_center`, `catalog_page`, `catalog_returns`, `catalog_sales`,
+	`customer`, `customer_address`, `customer_demographics`, `date_dim`,
+	`dbgen_version`, `household_demographics`, `income_band`, `inventory`,
+	`item`, `promotion`, `reason`, `ship_mode`, `store`, `store_returns`,
+	`store_sales`, `time_dim`, `warehouse`, `web_page`, `web_returns`,
+	`web_sales`, `web_site`,
+}
+
+// tpcdsQueriesToSkip are the TPC-DS queries which aren't run by the tests.
+var tpcdsQueriesToSkip = map[int]bool{
+	// These queries don't complete within 5 minutes.
+	1:  true,
+	64: true,
+
+	// These queries contain unsupported function 'rollup' (#46280).
+	5:  true,
+	14: true,
+	18: true,
+	22: true,
+	67: true,
+	77: true,
+	80: true,
+}
+
 func registerTPCDSVec(r registry.Registry) {
 	const (
 		timeout                         = 5 * time.Minute
 		withStatsSlowerWarningThreshold = 1.25
 	)
 
-	queriesToSkip := map[int]bool{
-		// These queries don't complete within 5 minutes.
-		1:  true,
-		64: true,
-
-		// These queries contain unsupported function 'rollup' (#46280).
-		5:  true,
-		14: true,
-		18: true,
-		22: true,
-		67: true,
-		77: true,
-		80: true,
-	}
-
-	tpcdsTables := []string{
-		`call_center`, `catalog_page`, `catalog_returns`, `catalog_sales`,
-		`customer`, `customer_address`, `customer_demographics`, `date_dim`,
-		`dbgen_version`, `household_demographics`, `income_band`, `inventory`,
-		`item`, `promotion`, `reason`, `ship_mode`, `store`, `store_returns`,
-		`store_sales`, `time_dim`, `warehouse`, `web_page`, `web_returns`,
-		`web_sales`, `web_site`,
-	}
-
 	runTPCDSVec := func(ctx context.Context, t test.Test, c cluster.Cluster) {
 		c.Start(ctx, t.L(), option.DefaultStartOpts(), install.MakeClusterSettings())
 
 		clusterConn := c.Conn(ctx, t.L(), 1)
-		t.Status("disabling automatic collection of stats")
-		if _, err := clusterConn.Exec(
-			`SET CLUSTER SETTING sql.stats.automatic_collection.enabled=false;`,
-		); err != nil {
-			t.Fatal(err)
-		}
-		t.Status("restoring TPCDS dataset for Scale Factor 1")
-		if _, err := clusterConn.Exec(
-			`
-RESTORE DATABASE tpcds FROM 'gs://cockroach-fixtures-us-east1/workload/tpcds/scalefactor=1/backup?AUTH=implicit'
-WITH unsafe_restore_incompatible_version;
-`,
-		); err != nil {
-			t.Fatal(err)
-		}
-
-		if _, err := clusterConn.Exec("USE tpcds;"); err != nil {
-			t.Fatal(err)
-		}
-		scatterTables(t, clusterConn, tpcdsTables)
-		t.Status("waiting for full replication")
-		err := WaitFor3XReplication(ctx, t, t.L(), clusterConn)
+		err := loadTPCDSDataset(ctx, t, clusterConn, 1 /* sf */)
 		require.NoError(t, err)
 
 		// TODO(yuzefovich): it seems like if cmpconn.CompareConns hits a
@@ -140,7 +120,7 @@ WITH unsafe_restore_incompatible_version;
 		// plans.
 		for _, haveStats := range []bool{false, true} {
 			for queryNum := 1; queryNum <= tpcds.NumQueries; queryNum++ {
-				if _, toSkip := queriesToSkip[queryNum]; toSkip {
+				if _, toSkip := tpcdsQueriesToSkip[queryNum]; toSkip {
 					continue
 				}
 				query, ok := tpcds.QueriesByNumber[queryNum]
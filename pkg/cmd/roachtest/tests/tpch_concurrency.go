@@ -45,7 +45,7 @@ func registerTPCHConcurrency(r registry.Registry) {
 
 		if err := loadTPCHDataset(
 			ctx, t, c, conn, 1 /* sf */, c.NewMonitor(ctx, c.CRDBNodes()),
-			c.CRDBNodes(), true, /* disableMergeQueue */
+			c.CRDBNodes(), true /* disableMergeQueue */, tpchVersionedBackup,
 		); err != nil {
 			t.Fatal(err)
 		}
@@ -11,21 +11,32 @@
 package tests
 
 import (
+	"bytes"
 	"context"
+	gosql "database/sql"
 	"fmt"
 	"io"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
 	"strings"
 	"time"
 
+	"github.com/cockroachdb/cockroach/pkg/cli/clisqlclient"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/cluster"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/option"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/roachtestutil"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/spec"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/test"
+	"github.com/cockroachdb/cockroach/pkg/roachprod"
 	"github.com/cockroachdb/cockroach/pkg/roachprod/install"
-	"github.com/cockroachdb/cockroach/pkg/util/httputil"
+	"github.com/cockroachdb/cockroach/pkg/workload/histogram"
 	"github.com/cockroachdb/cockroach/pkg/workload/querybench"
+	"github.com/cockroachdb/cockroach/pkg/workload/tpcds"
+	"github.com/cockroachdb/errors"
+	"github.com/codahale/hdrhistogram"
 )
 
 type tpchBenchSpec struct {
@@ -33,30 +44,115 @@ type tpchBenchSpec struct {
 	CPUs            int
 	ScaleFactor     int
 	benchType       string
-	url             string
+	queryFile       string
 	numRunsPerQuery int
+	// captureStmtBundles, if set, runs EXPLAIN ANALYZE (DEBUG) once for each
+	// query after the benchmark, and stores the statement bundles in the
+	// artifacts.
+	captureStmtBundles bool
 	// maxLatency is the expected maximum time that a query will take to execute
-	// needed to correctly initialize histograms.
+	// needed to correctly initialize histograms. Executions which take longer
+	// time out, and are recorded as failed; see tpchBenchFailedQueries.
 	maxLatency time.Duration
+	// regressionThreshold is the relative increase of the mean latency of a
+	// query, over its latency in previous runs of the benchmark, above which
+	// the query is considered to have regressed. Zero disables regression
+	// detection. See checkTPCHBenchRegressions.
+	regressionThreshold float64
+	// failOnRegression, if set, fails the test if a query regressed. Otherwise,
+	// regressions are only logged.
+	failOnRegression bool
+	// snapshotFixture, if set, restores the dataset from volume snapshots taken
+	// by a previous run instead of loading it, when there are any. See
+	// roachtestutil.SnapshotFixture.
+	snapshotFixture bool
+	// weekly, if set, runs the benchmark in the weekly suite instead of the
+	// nightly one.
+	weekly bool
+	// timeout, if set, overrides the default timeout of the test.
+	timeout time.Duration
 }
 
+const (
+	// tpcdsBenchType is the benchType of the benchmarks which run TPC-DS
+	// queries against a TPC-DS dataset, rather than against a TPC-H dataset.
+	tpcdsBenchType = "tpcds"
+	// tpcdsQueryFile is the query file of the TPC-DS benchmarks. It isn't one
+	// of the files in pkg/workload/querybench, but is generated from the
+	// queries of the tpcds workload; see tpcdsBenchQueries.
+	tpcdsQueryFile = "tpcds-queries"
+)
+
+// dataset returns the name of the dataset, and of the database, the
+// benchmark runs against.
+func (b tpchBenchSpec) dataset() string {
+	if b.benchType == tpcdsBenchType {
+		return "tpcds"
+	}
+	return "tpch"
+}
+
+// loadDataset loads the dataset of the benchmark.
+func (b tpchBenchSpec) loadDataset(
+	ctx context.Context, t test.Test, c cluster.Cluster, conn *gosql.DB, m cluster.Monitor,
+) error {
+	if b.benchType == tpcdsBenchType {
+		if err := loadTPCDSDataset(ctx, t, conn, b.ScaleFactor); err != nil {
+			return err
+		}
+		createStatsFromTables(t, conn, tpcdsTables)
+		return nil
+	}
+	return loadTPCHDataset(
+		ctx, t, c, conn, b.ScaleFactor, m, c.CRDBNodes(), true /* disableMergeQueue */, tpchVersionedBackup,
+	)
+}
+
+// tpchBenchBaselineRuns is the number of previous runs of a benchmark whose
+// latencies make up the baseline to detect regressions against.
+const tpchBenchBaselineRuns = 5
+
 // runTPCHBench runs sets of queries against CockroachDB clusters in different
 // configurations.
 //
-// In order to run a benchmark, a TPC-H dataset must first be loaded. To reuse
+// In order to run a benchmark, a TPC-H (or, for tpcdsBenchType, TPC-DS) dataset
+// must first be loaded. To reuse
 // this data across runs, it is recommended to use a combination of
 // `--cluster=<cluster>` and `--wipe=false` flags to limit the loading phase to
-// the first run.
+// the first run. Benchmarks with snapshotFixture set instead reuse the data
+// across clusters through volume snapshots.
 //
 // This benchmark runs with a single load generator node running a single
 // worker.
 func runTPCHBench(ctx context.Context, t test.Test, c cluster.Cluster, b tpchBenchSpec) {
 	filename := b.benchType
-	t.Status(fmt.Sprintf("downloading %s query file from %s", filename, b.url))
-	if err := c.RunE(ctx, option.WithNodes(c.WorkloadNode()), fmt.Sprintf("curl %s > %s", b.url, filename)); err != nil {
+	queries, source, err := readTPCHBenchQueryFile(b.queryFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Status(fmt.Sprintf("uploading %s query file from %s", filename, source))
+	if err := c.PutString(ctx, string(queries), filename, 0644, c.WorkloadNode()); err != nil {
 		t.Fatal(err)
 	}
 
+	if b.snapshotFixture {
+		t.Status("setting up dataset fixture")
+		fixture := roachtestutil.SnapshotFixture{
+			Prefix: fmt.Sprintf("%s-sf%d", b.dataset(), b.ScaleFactor),
+			Load: func(ctx context.Context, t test.Test, c cluster.Cluster) {
+				c.Start(ctx, t.L(), option.NewStartOpts(option.NoBackupSchedule), install.MakeClusterSettings(), c.CRDBNodes())
+				m := c.NewMonitor(ctx, c.CRDBNodes())
+				m.Go(func(ctx context.Context) error {
+					conn := c.Conn(ctx, t.L(), 1)
+					defer conn.Close()
+					return b.loadDataset(ctx, t, c, conn, m)
+				})
+				m.Wait()
+			},
+		}
+		fixture.Setup(ctx, t, c)
+	}
+
 	t.Status("starting nodes")
 	c.Start(ctx, t.L(), option.NewStartOpts(option.NoBackupSchedule), install.MakeClusterSettings(), c.CRDBNodes())
 
@@ -66,84 +162,364 @@ func runTPCHBench(ctx context.Context, t test.Test, c cluster.Cluster, b tpchBen
 		defer conn.Close()
 
 		t.Status("setting up dataset")
-		err := loadTPCHDataset(
-			ctx, t, c, conn, b.ScaleFactor, m, c.CRDBNodes(), true, /* disableMergeQueue */
-		)
-		if err != nil {
+		if err := b.loadDataset(ctx, t, c, conn, m); err != nil {
 			return err
 		}
 
-		t.L().Printf("running %s benchmark on tpch scale-factor=%d", filename, b.ScaleFactor)
+		t.L().Printf("running %s benchmark on %s scale-factor=%d", filename, b.dataset(), b.ScaleFactor)
 
-		numQueries, err := getNumQueriesInFile(filename, b.url)
+		stmts, err := querybench.ParseQueries(bytes.NewReader(queries), "" /* separator */)
 		if err != nil {
 			t.Fatal(err)
 		}
+		numQueries := len(stmts)
 		// maxOps flag will allow us to exit the workload once all the queries were
 		// run b.numRunsPerQuery number of times.
 		maxOps := b.numRunsPerQuery * numQueries
 
-		// Run with only one worker to get best-case single-query performance.
+		// Run with only one worker to get best-case single-query performance. A
+		// query which fails or times out is recorded as such, and doesn't stop
+		// the other queries from running. Its failed executions are recorded in
+		// stats.json, in a histogram named after the query with a .failed
+		// suffix, which the latency checks below ignore; the test fails after
+		// the checks if there are any.
 		cmd := fmt.Sprintf(
-			"./cockroach workload run querybench --db=tpch --concurrency=1 --query-file=%s "+
-				"--num-runs=%d --max-ops=%d {pgurl%s} "+
+			"./cockroach workload run querybench --db=%s --concurrency=1 --query-file=%s "+
+				"--num-runs=%d --max-ops=%d {pgurl%s} --query-timeout=%s --continue-on-error "+
 				"--histograms="+t.PerfArtifactsDir()+"/stats.json --histograms-max-latency=%s",
+			b.dataset(),
 			filename,
 			b.numRunsPerQuery,
 			maxOps,
 			c.CRDBNodes(),
 			b.maxLatency.String(),
+			b.maxLatency.String(),
 		)
 		if err := c.RunE(ctx, option.WithNodes(c.WorkloadNode()), cmd); err != nil {
 			t.Fatal(err)
 		}
-		return nil
+		if b.captureStmtBundles {
+			if err := captureTPCHBenchStmtBundles(ctx, t, c, b.dataset(), stmts); err != nil {
+				return err
+			}
+		}
+
+		dir, err := os.MkdirTemp("", "tpchbench")
+		if err != nil {
+			return err
+		}
+		defer func() {
+			_ = os.RemoveAll(dir)
+		}()
+		snapshots, err := fetchTPCHBenchSnapshots(ctx, t, c, dir)
+		if err != nil {
+			return err
+		}
+		var failedErr error
+		failed := tpchBenchFailedQueries(snapshots)
+		for _, f := range failed {
+			t.L().Printf("query %s failed or timed out %d out of %d times", f.query, f.failures, f.failures+f.successes)
+		}
+		if len(failed) > 0 {
+			failedErr = errors.Newf("%d queries failed or timed out; see the workload logs", len(failed))
+		}
+		current := meanQueryLatencies(snapshots)
+		if b.regressionThreshold > 0 {
+			if err := checkTPCHBenchRegressions(ctx, t, b, current, dir); err != nil {
+				return errors.CombineErrors(err, failedErr)
+			}
+		}
+		return failedErr
 	})
 	m.Wait()
 }
 
-// getNumQueriesInFile downloads a file that url points to, stores it at a
-// temporary location, parses it using querybench, and deletes the file. It
-// returns the number of queries in the file.
-func getNumQueriesInFile(filename, url string) (int, error) {
-	tempFile, err := downloadFile(filename, url)
+// captureTPCHBenchStmtBundles runs EXPLAIN ANALYZE (DEBUG) for each of the
+// given queries and downloads the statement bundles to the stmtbundle
+// sub-directory of the artifacts, as <index>.zip. The queries run against the
+// given database. The mapping from queries to bundles is written to index.txt
+// in the same directory. Queries that fail are logged and skipped.
+func captureTPCHBenchStmtBundles(
+	ctx context.Context, t test.Test, c cluster.Cluster, db string, stmts []querybench.NamedStmt,
+) error {
+	t.Status("capturing statement bundles")
+	bundleDir := filepath.Join(t.ArtifactsDir(), "stmtbundle")
+	if err := os.MkdirAll(bundleDir, 0755); err != nil {
+		return err
+	}
+	conn, err := c.ConnE(ctx, t.L(), 1, option.DBName(db))
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	pgURL, err := c.ExternalPGUrl(ctx, t.L(), c.Node(1), roachprod.PGURLOptions{})
 	if err != nil {
-		return 0, err
+		return err
 	}
-	// Use closure to make linter happy about unchecked error.
+	sqlConnCtx := clisqlclient.Context{}
+	connForBundle := sqlConnCtx.MakeSQLConn(io.Discard, io.Discard, pgURL[0])
 	defer func() {
-		_ = os.Remove(tempFile.Name())
+		_ = connForBundle.Close()
 	}()
 
-	queries, err := querybench.GetQueries(tempFile.Name(), "")
-	if err != nil {
-		return 0, err
+	var index strings.Builder
+	for i, stmt := range stmts {
+		bundleID, err := explainAnalyzeDebug(conn, stmt.Query())
+		if err != nil {
+			t.L().Printf("unable to capture statement bundle for query %s: %v", stmt.Name(), err)
+			continue
+		}
+		filename := fmt.Sprintf("%d.zip", i+1)
+		if err := clisqlclient.StmtDiagDownloadBundle(
+			ctx, connForBundle, bundleID, filepath.Join(bundleDir, filename),
+		); err != nil {
+			t.L().Printf("unable to download statement bundle for query %s: %v", stmt.Name(), err)
+			continue
+		}
+		fmt.Fprintf(&index, "%s: %s\n", filename, stmt.Name())
 	}
-	return len(queries), nil
+	return os.WriteFile(filepath.Join(bundleDir, "index.txt"), []byte(index.String()), 0644)
 }
 
-// downloadFile will download a url as a local temporary file.
-func downloadFile(filename string, url string) (*os.File, error) {
-	// These files may be a bit large, so give ourselves
-	// some room before the timeout expires.
-	httpClient := httputil.NewClientWithTimeout(30 * time.Second)
-	// Get the data.
-	resp, err := httpClient.Get(context.TODO(), url)
+// perfBaselineURL returns the location of the perf artifacts of previous
+// runs, as uploaded by CI, or the empty string if it is not known. It can be
+// set with the ROACHTEST_PERF_BASELINE_URL env var.
+func perfBaselineURL() string {
+	if u := os.Getenv("ROACHTEST_PERF_BASELINE_URL"); u != "" {
+		return u
+	}
+	if os.Getenv("TC_BUILD_ID") != "" {
+		return "gs://cockroach-nightly/artifacts"
+	}
+	return ""
+}
+
+// checkTPCHBenchRegressions compares the given mean latency of each query of
+// the benchmark with its median latency over the last tpchBenchBaselineRuns
+// runs, which are downloaded to dir, and reports the queries that regressed
+// beyond b.regressionThreshold. Not being able to fetch the baselines is not
+// an error.
+func checkTPCHBenchRegressions(
+	ctx context.Context, t test.Test, b tpchBenchSpec, current map[string]time.Duration, dir string,
+) error {
+	baseURL := perfBaselineURL()
+	if baseURL == "" {
+		t.L().Printf("no perf baselines location; skipping regression detection")
+		return nil
+	}
+	t.Status("checking for regressions against previous runs")
+
+	baselinePaths, err := fetchPerfBaselines(
+		ctx, baseURL, t.Name(), t.PerfArtifactsDir(), tpchBenchBaselineRuns, dir,
+	)
 	if err != nil {
+		t.L().Printf("unable to fetch perf baselines from %s; skipping regression detection: %v", baseURL, err)
+		return nil
+	}
+	var baselines []map[string]time.Duration
+	for _, path := range baselinePaths {
+		snapshots, err := histogram.DecodeSnapshots(path)
+		if err != nil {
+			t.L().Printf("unable to decode perf baseline: %v", err)
+			continue
+		}
+		baselines = append(baselines, meanQueryLatencies(snapshots))
+	}
+	if len(baselines) == 0 {
+		t.L().Printf("no perf baselines found in %s; skipping regression detection", baseURL)
+		return nil
+	}
+
+	regressions := findQueryRegressions(current, baselines, b.regressionThreshold)
+	t.L().Printf("compared %d queries against %d previous runs; %d regressed by more than %.0f%%",
+		len(current), len(baselines), len(regressions), b.regressionThreshold*100)
+	for _, r := range regressions {
+		t.L().Printf("query %s regressed: mean latency %s, baseline %s", r.query, r.current, r.baseline)
+	}
+	if len(regressions) > 0 && b.failOnRegression {
+		return errors.Newf("%d queries regressed by more than %.0f%% compared to previous runs",
+			len(regressions), b.regressionThreshold*100)
+	}
+	return nil
+}
+
+// fetchTPCHBenchSnapshots downloads the histograms recorded by the benchmark
+// into dir, and decodes them.
+func fetchTPCHBenchSnapshots(
+	ctx context.Context, t test.Test, c cluster.Cluster, dir string,
+) (map[string][]histogram.SnapshotTick, error) {
+	path := filepath.Join(dir, "stats.json")
+	if err := c.Get(
+		ctx, t.L(), t.PerfArtifactsDir()+"/stats.json", path, c.WorkloadNode(),
+	); err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	snapshots, err := histogram.DecodeSnapshots(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode histogram snapshots")
+	}
+	return snapshots, nil
+}
 
-	// Create the file.
-	out, err := os.CreateTemp(`` /* dir */, filename)
+// fetchPerfBaselines downloads the histograms recorded in the last n runs of
+// the given test from the given location (see perfBaselineURL) into dir, and
+// returns their paths.
+func fetchPerfBaselines(
+	ctx context.Context, baseURL, testName, perfDir string, n int, dir string,
+) ([]string, error) {
+	// Runs are stored in dirs prefixed with the date of the run, so they are
+	// listed in chronological order. A test can be run several times in a
+	// night, e.g. with --count or when it is retried, and each run counts.
+	pattern := fmt.Sprintf("%s/*/%s/run_*/*.%s/stats.json", strings.TrimSuffix(baseURL, "/"), testName, perfDir)
+	out, err := exec.CommandContext(ctx, "gsutil", "ls", pattern).Output()
 	if err != nil {
-		return nil, err
+		return nil, errors.Wrapf(err, "listing %s", pattern)
+	}
+	objects := strings.Fields(string(out))
+	sort.Strings(objects)
+	if len(objects) > n {
+		objects = objects[len(objects)-n:]
+	}
+	var paths []string
+	for i, object := range objects {
+		path := filepath.Join(dir, fmt.Sprintf("baseline_%d.json", i))
+		if out, err := exec.CommandContext(ctx, "gsutil", "cp", object, path).CombinedOutput(); err != nil {
+			return nil, errors.Wrapf(err, "downloading %s: %s", object, out)
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// meanQueryLatencies returns the mean latency of each query in the given
+// histograms, as recorded by querybench. Failed executions are not included.
+func meanQueryLatencies(snapshots map[string][]histogram.SnapshotTick) map[string]time.Duration {
+	res := make(map[string]time.Duration)
+	for name, ticks := range snapshots {
+		if _, failed := querybench.IsFailedHistogramName(name); failed {
+			continue
+		}
+		count, mean := histogramCountAndMean(ticks)
+		if name != "" && count > 0 {
+			res[name] = mean
+		}
+	}
+	return res
+}
+
+// histogramCountAndMean returns the number of values recorded in the given
+// ticks of a histogram, and their mean.
+func histogramCountAndMean(ticks []histogram.SnapshotTick) (int64, time.Duration) {
+	var count int64
+	var total float64
+	for _, tick := range ticks {
+		if tick.Hist == nil {
+			continue
+		}
+		h := hdrhistogram.Import(tick.Hist)
+		count += h.TotalCount()
+		total += h.Mean() * float64(h.TotalCount())
+	}
+	if count == 0 {
+		return 0, 0
 	}
-	defer out.Close()
+	return count, time.Duration(total / float64(count))
+}
 
-	// Write the body to file.
-	_, err = io.Copy(out, resp.Body)
-	return out, err
+type failedQuery struct {
+	query               string
+	failures, successes int64
+}
+
+// tpchBenchFailedQueries returns the queries which failed or timed out at
+// least once in the given histograms, as recorded by querybench with
+// --continue-on-error, sorted by query name.
+func tpchBenchFailedQueries(snapshots map[string][]histogram.SnapshotTick) []failedQuery {
+	var res []failedQuery
+	for name, ticks := range snapshots {
+		query, failed := querybench.IsFailedHistogramName(name)
+		if !failed {
+			continue
+		}
+		failures, _ := histogramCountAndMean(ticks)
+		if failures == 0 {
+			continue
+		}
+		successes, _ := histogramCountAndMean(snapshots[query])
+		res = append(res, failedQuery{query: query, failures: failures, successes: successes})
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].query < res[j].query })
+	return res
+}
+
+type queryRegression struct {
+	query             string
+	current, baseline time.Duration
+}
+
+// findQueryRegressions returns the queries whose current latency exceeds the
+// median of their baseline latencies by more than the given threshold, sorted
+// by query name. Queries without baselines are ignored.
+func findQueryRegressions(
+	current map[string]time.Duration, baselines []map[string]time.Duration, threshold float64,
+) []queryRegression {
+	var res []queryRegression
+	for query, latency := range current {
+		var previous []time.Duration
+		for _, b := range baselines {
+			if l, ok := b[query]; ok {
+				previous = append(previous, l)
+			}
+		}
+		if len(previous) == 0 {
+			continue
+		}
+		sort.Slice(previous, func(i, j int) bool { return previous[i] < previous[j] })
+		median := previous[len(previous)/2]
+		if len(previous)%2 == 0 {
+			median = (previous[len(previous)/2-1] + median) / 2
+		}
+		if float64(latency) > float64(median)*(1+threshold) {
+			res = append(res, queryRegression{query: query, current: latency, baseline: median})
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].query < res[j].query })
+	return res
+}
+
+// readTPCHBenchQueryFile returns the contents of the given query file (one of
+// the files in pkg/workload/querybench, or tpcdsQueryFile), and where it was
+// read from. The file is read from the directory set with the
+// ROACHTEST_QUERYBENCH_DIR env var, if any, and otherwise from the query files
+// embedded in the binary (see querybench.QueryFiles), so that no network
+// access is needed.
+func readTPCHBenchQueryFile(queryFile string) (_ []byte, source string, _ error) {
+	if dir := os.Getenv("ROACHTEST_QUERYBENCH_DIR"); dir != "" {
+		path := filepath.Join(dir, queryFile)
+		content, err := os.ReadFile(path)
+		return content, path, err
+	}
+	if queryFile == tpcdsQueryFile {
+		return tpcdsBenchQueries(tpcdsQueriesToSkip), "tpcds workload queries", nil
+	}
+	content, err := querybench.QueryFiles.ReadFile(queryFile)
+	return content, "embedded " + queryFile, err
+}
+
+// tpcdsBenchQueries returns a query file with the TPC-DS queries of the tpcds
+// workload, except for the given ones, in the format querybench understands:
+// one query per line, named q<number>.
+func tpcdsBenchQueries(skip map[int]bool) []byte {
+	var buf bytes.Buffer
+	for queryNum := 1; queryNum <= tpcds.NumQueries; queryNum++ {
+		query, ok := tpcds.QueriesByNumber[queryNum]
+		if !ok || skip[queryNum] {
+			continue
+		}
+		query = strings.TrimSpace(strings.ReplaceAll(query, "\n", " "))
+		fmt.Fprintf(&buf, "-- query %d\nq%d: %s\n\n", queryNum, queryNum, query)
+	}
+	return buf.Bytes()
 }
 
 func registerTPCHBenchSpec(r registry.Registry, b tpchBenchSpec) {
@@ -158,15 +534,26 @@ func registerTPCHBenchSpec(r registry.Registry, b tpchBenchSpec) {
 	// Add a load generator node.
 	numNodes := b.Nodes + 1
 
+	suites := registry.Suites(registry.Nightly)
+	if b.weekly {
+		suites = registry.Suites(registry.Weekly)
+	}
+	clusterOpts := []spec.Option{spec.WorkloadNode()}
+	if b.snapshotFixture {
+		// Only persistent disks can be snapshotted.
+		clusterOpts = append(clusterOpts, spec.VolumeSize(500), spec.GCEVolumeType("pd-ssd"))
+	}
+
 	r.Add(registry.TestSpec{
 		Name:      strings.Join(nameParts, "/"),
 		Owner:     registry.OwnerSQLQueries,
 		Benchmark: true,
-		Cluster:   r.MakeClusterSpec(numNodes, spec.WorkloadNode()),
+		Timeout:   b.timeout,
+		Cluster:   r.MakeClusterSpec(numNodes, clusterOpts...),
 		// Uses gs://cockroach-fixtures-us-east1. See:
 		// https://github.com/cockroachdb/cockroach/issues/105968
 		CompatibleClouds: registry.Clouds(spec.GCE, spec.Local),
-		Suites:           registry.Suites(registry.Nightly),
+		Suites:           suites,
 		Run: func(ctx context.Context, t test.Test, c cluster.Cluster) {
 			runTPCHBench(ctx, t, c, b)
 		},
@@ -176,22 +563,57 @@ func registerTPCHBenchSpec(r registry.Registry, b tpchBenchSpec) {
 func registerTPCHBench(r registry.Registry) {
 	specs := []tpchBenchSpec{
 		{
+			Nodes:              3,
+			CPUs:               4,
+			ScaleFactor:        1,
+			benchType:          `sql20`,
+			queryFile:          `2.1-sql-20`,
+			numRunsPerQuery:    3,
+			captureStmtBundles: true,
+			maxLatency:         100 * time.Second,
+			// Query latencies vary a fair bit from run to run, so regressions
+			// are only reported for now.
+			regressionThreshold: 0.25,
+		},
+		{
+			Nodes:               3,
+			CPUs:                4,
+			ScaleFactor:         1,
+			benchType:           `tpch`,
+			queryFile:           `tpch-queries`,
+			numRunsPerQuery:     3,
+			captureStmtBundles:  true,
+			maxLatency:          500 * time.Second,
+			regressionThreshold: 0.25,
+			failOnRegression:    true,
+		},
+		{
+			// Loading the dataset at this scale factor takes hours, so it is
+			// restored from snapshots.
 			Nodes:           3,
 			CPUs:            4,
-			ScaleFactor:     1,
-			benchType:       `sql20`,
-			url:             `https://raw.githubusercontent.com/cockroachdb/cockroach/master/pkg/workload/querybench/2.1-sql-20`,
-			numRunsPerQuery: 3,
-			maxLatency:      100 * time.Second,
+			ScaleFactor:     100,
+			benchType:       `tpch`,
+			queryFile:       `tpch-queries`,
+			numRunsPerQuery: 1,
+			maxLatency:      time.Hour,
+			snapshotFixture: true,
+			weekly:          true,
+			timeout:         12 * time.Hour,
 		},
 		{
 			Nodes:           3,
 			CPUs:            4,
 			ScaleFactor:     1,
-			benchType:       `tpch`,
-			url:             `https://raw.githubusercontent.com/cockroachdb/cockroach/master/pkg/workload/querybench/tpch-queries`,
+			benchType:       tpcdsBenchType,
+			queryFile:       tpcdsQueryFile,
 			numRunsPerQuery: 3,
-			maxLatency:      500 * time.Second,
+			maxLatency:      10 * time.Minute,
+			// The queries which don't complete within 5 minutes are skipped (see
+			// tpcdsQueriesToSkip). Each of the others is held to its latency in
+			// the previous runs.
+			regressionThreshold: 0.25,
+			failOnRegression:    true,
 		},
 	}
 
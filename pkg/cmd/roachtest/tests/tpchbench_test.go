@@ -0,0 +1,84 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package tests
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/workload/histogram"
+	"github.com/cockroachdb/cockroach/pkg/workload/querybench"
+	"github.com/cockroachdb/cockroach/pkg/workload/tpcds"
+	"github.com/codahale/hdrhistogram"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFindQueryRegressions(t *testing.T) {
+	current := map[string]time.Duration{
+		"q1": 100 * time.Millisecond,
+		"q2": 150 * time.Millisecond,
+		"q3": 300 * time.Millisecond,
+		"q4": time.Second,
+	}
+	baselines := []map[string]time.Duration{
+		{"q1": 100 * time.Millisecond, "q2": 100 * time.Millisecond, "q3": 100 * time.Millisecond},
+		{"q1": 90 * time.Millisecond, "q2": 110 * time.Millisecond, "q3": 500 * time.Millisecond},
+		{"q1": 110 * time.Millisecond, "q2": 120 * time.Millisecond, "q3": 250 * time.Millisecond},
+	}
+	// q3's median baseline (250ms) ignores the outliers; q4 has no baseline.
+	require.Equal(t, []queryRegression{
+		{query: "q2", current: 150 * time.Millisecond, baseline: 110 * time.Millisecond},
+	}, findQueryRegressions(current, baselines, 0.25))
+
+	require.Equal(t, []queryRegression{
+		{query: "q2", current: 150 * time.Millisecond, baseline: 110 * time.Millisecond},
+		{query: "q3", current: 300 * time.Millisecond, baseline: 250 * time.Millisecond},
+	}, findQueryRegressions(current, baselines, 0.1))
+
+	// With an even number of baselines, the median is the mean of the middle two.
+	require.Equal(t, []queryRegression{
+		{query: "q2", current: 150 * time.Millisecond, baseline: 105 * time.Millisecond},
+	}, findQueryRegressions(current, baselines[:2], 0.25))
+}
+
+func TestTPCDSBenchQueries(t *testing.T) {
+	stmts, err := querybench.ParseQueries(bytes.NewReader(tpcdsBenchQueries(map[int]bool{1: true})), "")
+	require.NoError(t, err)
+	require.Len(t, stmts, len(tpcds.QueriesByNumber)-1)
+	require.Equal(t, "q2", stmts[0].Name())
+	require.NotContains(t, stmts[0].Query(), "\n")
+}
+
+func TestTPCHBenchFailedQueries(t *testing.T) {
+	ticks := func(latencies ...time.Duration) []histogram.SnapshotTick {
+		h := hdrhistogram.New(time.Millisecond.Nanoseconds(), time.Minute.Nanoseconds(), 1)
+		for _, l := range latencies {
+			require.NoError(t, h.RecordValue(l.Nanoseconds()))
+		}
+		return []histogram.SnapshotTick{{Hist: h.Export()}}
+	}
+	snapshots := map[string][]histogram.SnapshotTick{
+		"q1":                                 ticks(time.Second, time.Second),
+		querybench.FailedHistogramName("q1"): ticks(time.Minute),
+		"q2":                                 ticks(time.Second),
+		querybench.FailedHistogramName("q3"): ticks(time.Minute, time.Minute),
+	}
+	require.Equal(t, []failedQuery{
+		{query: "q1", failures: 1, successes: 2},
+		{query: "q3", failures: 2, successes: 0},
+	}, tpchBenchFailedQueries(snapshots))
+
+	// Failed executions don't count towards the latencies of the queries.
+	latencies := meanQueryLatencies(snapshots)
+	require.Len(t, latencies, 2)
+	require.InDelta(t, time.Second, latencies["q1"], float64(100*time.Millisecond))
+}
@@ -33,6 +33,7 @@ import (
 	"github.com/cockroachdb/cockroach/pkg/roachprod/install"
 	"github.com/cockroachdb/cockroach/pkg/util/randutil"
 	"github.com/cockroachdb/cockroach/pkg/workload/tpch"
+	"github.com/cockroachdb/errors"
 	"github.com/stretchr/testify/require"
 )
 
@@ -81,6 +82,43 @@ type tpchVecTestCase interface {
 	postTestRunHook(ctx context.Context, t test.Test, c cluster.Cluster, conn *gosql.DB)
 }
 
+// explainAnalyzeDebug runs EXPLAIN ANALYZE (DEBUG) for the given query, and
+// returns the ID of the statement bundle, which can be downloaded with
+// clisqlclient.StmtDiagDownloadBundle.
+func explainAnalyzeDebug(conn *gosql.DB, query string) (int64, error) {
+	rows, err := conn.Query(fmt.Sprintf("EXPLAIN ANALYZE (DEBUG) %s;", query))
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+	// The output of the command in both single-tenant and multi-tenant
+	// configs contains a line like
+	//
+	//   SQL shell: \statement-diag download 951198764631457793
+	//
+	// We'll use that command to figure out the bundle ID.
+	const sqlShellPrefix = `SQL shell: \statement-diag download `
+	var line, debugOutput string
+	for rows.Next() {
+		if err := rows.Scan(&line); err != nil {
+			return 0, err
+		}
+		debugOutput += line + "\n"
+		if strings.HasPrefix(line, sqlShellPrefix) {
+			id, err := strconv.ParseInt(line[len(sqlShellPrefix):], 10, 64)
+			if err != nil {
+				return 0, errors.Wrapf(err, "couldn't parse bundle ID in\n%s", debugOutput)
+			}
+			return id, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+	return 0, errors.Newf("unexpectedly didn't find a line with %q prefix in "+
+		"EXPLAIN ANALYZE (DEBUG) output\n%s", sqlShellPrefix, debugOutput)
+}
+
 // tpchVecTestCaseBase is a default tpchVecTestCase implementation that can be
 // embedded and extended.
 type tpchVecTestCaseBase struct{}
@@ -332,44 +370,10 @@ func (p *tpchVecPerfTest) postTestRunHook(
 				}
 				for i := 0; i < runConfig.numRunsPerQuery; i++ {
 					t.Status(fmt.Sprintf("\nRunning EXPLAIN ANALYZE (DEBUG) for setup=%s\n", runConfig.setupNames[setupIdx]))
-					rows, err := tempConn.Query(fmt.Sprintf(
-						"EXPLAIN ANALYZE (DEBUG) %s;", tpch.QueriesByNumber[queryNum],
-					))
+					bundleID, err := explainAnalyzeDebug(tempConn, tpch.QueriesByNumber[queryNum])
 					if err != nil {
 						t.Fatal(err)
 					}
-					// The output of the command in both single-tenant and
-					// multi-tenant configs contains a line like
-					//
-					//   SQL shell: \statement-diag download 951198764631457793
-					//
-					// We'll use that command to figure out the bundle ID and
-					// then download the bundle into the artifacts.
-					sqlShellPrefix := `SQL shell: \statement-diag download `
-					var line, debugOutput string
-					var bundleID int64
-					for rows.Next() {
-						if err = rows.Scan(&line); err != nil {
-							t.Fatal(err)
-						}
-						debugOutput += line + "\n"
-						if strings.HasPrefix(line, sqlShellPrefix) {
-							id, err := strconv.Atoi(line[len(sqlShellPrefix):])
-							if err != nil {
-								t.Fatalf("couldn't parse bundle ID in %d\n%v", id, debugOutput)
-							}
-							bundleID = int64(id)
-							break
-						}
-					}
-					if err = rows.Close(); err != nil {
-						t.Fatal(err)
-					}
-					if bundleID == 0 {
-						t.Fatal(fmt.Sprintf("unexpectedly didn't find a line "+
-							"with %q prefix in EXPLAIN ANALYZE (DEBUG) output\n%s",
-							sqlShellPrefix, debugOutput))
-					}
 					dest := fmt.Sprintf("%s/bundle_%d_%d.zip", t.ArtifactsDir(), setupIdx, i)
 					err = clisqlclient.StmtDiagDownloadBundle(ctx, connForBundle, bundleID, dest)
 					if err != nil {
@@ -545,7 +549,7 @@ func runTPCHVec(ctx context.Context, t test.Test, c cluster.Cluster, testCase tp
 
 	t.Status("restoring TPCH dataset for Scale Factor 1")
 	if err := loadTPCHDataset(
-		ctx, t, c, conn, 1 /* sf */, c.NewMonitor(ctx), c.All(), disableMergeQueue,
+		ctx, t, c, conn, 1 /* sf */, c.NewMonitor(ctx), c.All(), disableMergeQueue, tpchLegacyBackup,
 	); err != nil {
 		t.Fatal(err)
 	}
@@ -683,6 +687,7 @@ func registerTPCHVec(r registry.Registry) {
 		Suites:           registry.Suites(registry.Nightly),
 		Skip: "This config can be used to perform some benchmarking and is not " +
 			"meant to be run on a nightly basis",
+		SkipCategory: registry.SkipManual,
 		Run: func(ctx context.Context, t test.Test, c cluster.Cluster) {
 			// In order to use this test for benchmarking, include the queries
 			// that modify the cluster settings for all configs to benchmark
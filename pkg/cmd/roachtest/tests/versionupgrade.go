@@ -20,9 +20,11 @@ import (
 
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/cluster"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/option"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/roachtestutil"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/roachtestutil/clusterupgrade"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/roachtestutil/mixedversion"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/spec"
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/test"
 	"github.com/cockroachdb/cockroach/pkg/roachpb"
 	"github.com/cockroachdb/cockroach/pkg/roachprod/install"
@@ -99,21 +101,34 @@ DROP TABLE splitmerge.t;
 	},
 }
 
+// registerVersionUpgradeMixedArch registers a variant of version-upgrade
+// running on a cluster with both amd64 and arm64 nodes, which verifies that
+// nodes of different architectures are compatible with each other throughout
+// upgrades.
+func registerVersionUpgradeMixedArch(r registry.Registry) {
+	r.Add(registry.TestSpec{
+		Name:             "version-upgrade/mixed-arch",
+		Owner:            registry.OwnerTestEng,
+		Cluster:          r.MakeClusterSpec(4, spec.MixedArch(2)),
+		CompatibleClouds: registry.AllExceptLocal,
+		Suites:           registry.Suites(registry.Nightly),
+		Timeout:          2 * time.Hour,
+		NativeLibs:       registry.LibGEOS,
+		Run:              runVersionUpgrade,
+	})
+}
+
 func runVersionUpgrade(ctx context.Context, t test.Test, c cluster.Cluster) {
-	testCtx := ctx
 	opts := []mixedversion.CustomOption{
 		mixedversion.AlwaysUseFixtures,
 		mixedversion.AlwaysUseLatestPredecessors,
 	}
 	if c.IsLocal() {
-		localTimeout := 30 * time.Minute
-		var cancel context.CancelFunc
-		testCtx, cancel = context.WithTimeout(ctx, localTimeout)
-		defer cancel()
+		// The timeout of the test is scaled down accordingly in local runs.
 		opts = append(opts, mixedversion.NumUpgrades(1))
 	}
 
-	mvt := mixedversion.NewTest(testCtx, t, t.L(), c, c.All(), opts...)
+	mvt := mixedversion.NewTest(ctx, t, t.L(), c, c.All(), opts...)
 	mvt.OnStartup(
 		"setup schema changer workload",
 		func(ctx context.Context, l *logger.Logger, rng *rand.Rand, h *mixedversion.Helper) error {
@@ -47,11 +47,13 @@ var suites = map[string]string{
 
 // TestDetails has the details of the test as fetched from snowflake
 type TestDetails struct {
-	Name                 string // test name
-	Selected             bool   // whether a test is Selected or not
-	AvgDurationInMillis  int64  // average duration of the test
-	TotalRuns            int    // total number of times the test has run successfully
-	LastFailureIsPreempt bool   // last failure is due to a VM preemption
+	Name                 string  // test name
+	Selected             bool    // whether a test is Selected or not
+	AvgDurationInMillis  int64   // average duration of the test
+	TotalRuns            int     // total number of times the test has run successfully
+	LastFailureIsPreempt bool    // last failure is due to a VM preemption
+	FailureRate          float64 // fraction of the runs of the test that failed
+	RecentlyAdded        bool    // the test was first run recently
 }
 
 // SelectTestsReq is the request for CategoriseTests
@@ -104,7 +106,7 @@ func CategoriseTests(ctx context.Context, req *SelectTestsReq) ([]*TestDetails,
 	// add the parameters in sequence
 	rows, err := statement.QueryContext(ctx, req.ForPastDays*-1, currentBranch,
 		fmt.Sprintf("%%%s - %s%%", suites[req.Suite], req.Cloud),
-		req.FirstRunOn*-1, req.LastRunOn*-1)
+		req.FirstRunOn*-1, req.LastRunOn*-1, req.FirstRunOn*-1)
 	if err != nil {
 		return nil, err
 	}
@@ -137,12 +139,16 @@ func CategoriseTests(ctx context.Context, req *SelectTestsReq) ([]*TestDetails,
 		// 2. average duration of the test
 		// 3. total number of times the test has run successfully
 		// 4. last failure is due to an infra flake
+		// 5. failure rate of the test
+		// 6. whether the test was recently added
 		testDetails := &TestDetails{
 			Name:                 testInfos[0],
 			Selected:             testInfos[1] != "no",
 			AvgDurationInMillis:  getDuration(testInfos[2]),
 			TotalRuns:            getTotalRuns(testInfos[3]),
 			LastFailureIsPreempt: testInfos[4] == "yes",
+			FailureRate:          getFailureRate(testInfos[5]),
+			RecentlyAdded:        testInfos[6] == "yes",
 		}
 		if testDetails.Selected {
 			// selected for running
@@ -177,6 +183,12 @@ func getTotalRuns(totalRunsStr string) int {
 	return int(totalRuns)
 }
 
+// getFailureRate extracts the failure rate from the snowflake query failure_rate field
+func getFailureRate(failureRateStr string) float64 {
+	failureRate, _ := strconv.ParseFloat(failureRateStr, 64)
+	return failureRate
+}
+
 // getConnect makes connection to snowflake and returns the connection.
 func getConnect(ctx context.Context) (*gosql.DB, error) {
 	username, password, err := getSFCreds()
@@ -45,5 +45,10 @@ select
   total_successful_runs,
   -- indicates the last failure was due to infra flake
   case when recent_details like '%VMs preempted during the test run%' then 'yes' else 'no' end as last_failure_is_preeempt,
+  -- the fraction of the runs that failed, ignoring runs that failed due to preempted VMs
+  case when total_successful_runs + failure_count > 0
+         then failure_count / (total_successful_runs + failure_count) else 0 end as failure_rate,
+  -- indicates the test was first run in the last "firstRunOn" days, or never
+  case when first_run is null or first_run > dateadd(DAY, ?, current_date()) then 'yes' else 'no' end as recently_added,
 from test_stats
 order by selected desc, total_successful_runs
@@ -0,0 +1,324 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/option"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/roachtestflags"
+	"github.com/cockroachdb/cockroach/pkg/roachprod"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/logger"
+	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	// utilizationFile is the name of the file in the artifacts dir of a test
+	// holding its resource utilization report.
+	utilizationFile = "utilization.json"
+
+	// cpuSaturation and diskSaturation are the mean utilizations, in percent,
+	// of the busiest node above which the CPU and the disks are considered
+	// saturated. memorySaturation is the peak memory utilization above which
+	// the memory is.
+	cpuSaturation    = 80
+	diskSaturation   = 80
+	memorySaturation = 90
+)
+
+// utilizationSampleCmd prints the cumulative CPU, disk and network counters
+// of a node, and its memory usage, on one line: the busy and total CPU time in
+// jiffies, the total and available memory in KiB, the time spent doing IOs by
+// the disks in ms and the number of disks, and the bytes received and sent
+// over the network. Only the disks backing the stores, mounted on /mnt/data*,
+// are sampled, and not e.g. the boot disk: if the stores are on a RAID array
+// or a partition, the underlying disks are.
+const utilizationSampleCmd = `echo $(
+awk '/^cpu /{busy=$2+$3+$4+$7+$8+$9; print busy, busy+$5+$6}' /proc/stat
+awk '/^MemTotal:/{t=$2} /^MemAvailable:/{a=$2} END{print t, a}' /proc/meminfo
+awk -v devs="$(awk '$2 ~ /^\/mnt\/data/{print $1}' /proc/mounts | xargs -r lsblk -nsro NAME,TYPE | awk '$2 == "disk"{print $1}' | tr '\n' ' ')" 'BEGIN{split(devs, d, " "); for (i in d) want[d[i]]} ($3 in want){ticks+=$13; n++} END{print ticks+0, n+0}' /proc/diskstats
+awk -F'[: ]+' 'NR>2{sub(/^ +/, ""); if ($1 != "lo") b+=$2+$10} END{print b+0}' /proc/net/dev
+)`
+
+// utilizationSample is the output of utilizationSampleCmd on a node.
+type utilizationSample struct {
+	at                         time.Time
+	cpuBusy, cpuTotal          float64
+	memTotalKB, memAvailableKB float64
+	diskIOMillis, disks        float64
+	netBytes                   float64
+}
+
+func parseUtilizationSample(at time.Time, out string) (utilizationSample, error) {
+	fields := strings.Fields(out)
+	if len(fields) != 7 {
+		return utilizationSample{}, errors.Newf("unexpected utilization sample %q", out)
+	}
+	var vals [7]float64
+	for i, f := range fields {
+		v, err := strconv.ParseFloat(f, 64)
+		if err != nil {
+			return utilizationSample{}, errors.Wrapf(err, "unexpected utilization sample %q", out)
+		}
+		vals[i] = v
+	}
+	return utilizationSample{
+		at:      at,
+		cpuBusy: vals[0], cpuTotal: vals[1],
+		memTotalKB: vals[2], memAvailableKB: vals[3],
+		diskIOMillis: vals[4], disks: vals[5],
+		netBytes: vals[6],
+	}, nil
+}
+
+// utilizationStats summarizes the utilization of a resource.
+type utilizationStats struct {
+	// Mean is the mean utilization of the node on which it's the highest.
+	Mean float64 `json:"mean"`
+	// Max is the highest utilization of any node.
+	Max float64 `json:"max"`
+}
+
+// resourceUtilization is the resource utilization report of a test run, which
+// summarizes the utilization of the CockroachDB nodes while it ran.
+type resourceUtilization struct {
+	CPUPercent    utilizationStats `json:"cpu_percent"`
+	MemoryPercent utilizationStats `json:"memory_percent"`
+	DiskPercent   utilizationStats `json:"disk_percent"`
+	NetworkMBps   utilizationStats `json:"network_mbps"`
+	// Saturated lists the resources the nodes were saturated on.
+	Saturated []string `json:"saturated,omitempty"`
+	// WrongBottleneck is set if the nodes were saturated on a resource other
+	// than the one the test is expected to saturate. See
+	// registry.TestSpec.Bottleneck.
+	WrongBottleneck bool `json:"wrong_bottleneck,omitempty"`
+}
+
+func (u *resourceUtilization) String() string {
+	s := fmt.Sprintf("cpu %.0f%% (max %.0f%%), memory %.0f%% (max %.0f%%), "+
+		"disk %.0f%% (max %.0f%%), network %.1f MB/s (max %.1f MB/s)",
+		u.CPUPercent.Mean, u.CPUPercent.Max, u.MemoryPercent.Mean, u.MemoryPercent.Max,
+		u.DiskPercent.Mean, u.DiskPercent.Max, u.NetworkMBps.Mean, u.NetworkMBps.Max)
+	if len(u.Saturated) > 0 {
+		s += fmt.Sprintf("; saturated: %s", strings.Join(u.Saturated, ", "))
+	}
+	return s
+}
+
+// utilizationAccumulator accumulates the utilization of a resource of a node.
+type utilizationAccumulator struct {
+	sum, max float64
+	n        int
+}
+
+func (a *utilizationAccumulator) add(v float64) {
+	a.sum += v
+	a.n++
+	if v > a.max {
+		a.max = v
+	}
+}
+
+func (a *utilizationAccumulator) mean() float64 {
+	if a.n == 0 {
+		return 0
+	}
+	return a.sum / float64(a.n)
+}
+
+// nodeUtilization accumulates the utilization of the resources of a node.
+type nodeUtilization struct {
+	cpu, memory, disk, network utilizationAccumulator
+}
+
+// utilizationSampler periodically samples the resource utilization of the
+// CockroachDB nodes of the cluster of a test. See --utilization-sample-interval.
+type utilizationSampler struct {
+	c     *clusterImpl
+	l     *logger.Logger
+	nodes option.NodeListOption
+	// prev is the previous sample of each node.
+	prev  map[int]utilizationSample
+	usage map[int]*nodeUtilization
+}
+
+func newUtilizationSampler(c *clusterImpl, l *logger.Logger) *utilizationSampler {
+	return &utilizationSampler{
+		c:     c,
+		l:     l,
+		nodes: c.CRDBNodes(),
+		prev:  make(map[int]utilizationSample),
+		usage: make(map[int]*nodeUtilization),
+	}
+}
+
+// startUtilizationSampler starts sampling the resource utilization of the
+// cluster of the given test until the returned function is called, which
+// records the utilization report of the test and writes it to its artifacts.
+func startUtilizationSampler(ctx context.Context, t *testImpl, c *clusterImpl) (stop func()) {
+	interval := roachtestflags.UtilizationSampleInterval
+	if interval <= 0 || c.IsLocal() {
+		return func() {}
+	}
+	l, err := t.L().ChildLogger("utilization", logger.QuietStdout, logger.QuietStderr)
+	if err != nil {
+		t.L().Printf("failed to start the utilization sampler: %v", err)
+		return func() {}
+	}
+
+	s := newUtilizationSampler(c, l)
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.run(ctx, interval)
+	}()
+	return func() {
+		cancel()
+		<-done
+		u := s.report(t.spec.Bottleneck)
+		if u == nil {
+			return
+		}
+		t.utilization = u
+		t.L().Printf("resource utilization: %s", u)
+		if u.WrongBottleneck {
+			t.L().Printf("WARNING: the nodes were saturated on %s, but the test is expected to "+
+				"be bottlenecked on %s", strings.Join(u.Saturated, ", "), t.spec.Bottleneck)
+		}
+		data, err := json.MarshalIndent(u, "", "  ")
+		if err == nil {
+			err = os.WriteFile(filepath.Join(t.ArtifactsDir(), utilizationFile), append(data, '\n'), 0644)
+		}
+		if err != nil {
+			t.L().Printf("failed to write the utilization report: %v", err)
+		}
+	}
+}
+
+// run samples the utilization of the nodes at the given interval until the
+// context is canceled.
+func (s *utilizationSampler) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	s.sample(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.sample(ctx)
+		}
+	}
+}
+
+func (s *utilizationSampler) sample(ctx context.Context) {
+	// The samples are taken through roachprod directly, rather than through the
+	// cluster, so that they don't create a log file per sample in the artifacts
+	// of the test nor fill its audit log.
+	results, err := roachprod.RunWithDetails(
+		ctx, s.l, s.c.MakeNodes(s.nodes), "" /* SSHOptions */, "", /* processTag */
+		s.c.IsSecure(), []string{utilizationSampleCmd}, option.WithNodes(s.nodes),
+	)
+	if ctx.Err() != nil {
+		return
+	}
+	if err != nil {
+		s.l.Printf("failed to sample the utilization: %v", err)
+	}
+	now := timeutil.Now()
+	for _, res := range results {
+		if res.Err != nil {
+			continue
+		}
+		sample, err := parseUtilizationSample(now, res.Stdout)
+		if err != nil {
+			s.l.Printf("n%d: %v", res.Node, err)
+			continue
+		}
+		s.record(int(res.Node), sample)
+	}
+}
+
+// record records the utilization of the given node since its previous sample.
+func (s *utilizationSampler) record(node int, sample utilizationSample) {
+	prev, ok := s.prev[node]
+	s.prev[node] = sample
+	if !ok {
+		return
+	}
+	elapsed := sample.at.Sub(prev.at)
+	if elapsed <= 0 || sample.cpuTotal <= prev.cpuTotal {
+		return
+	}
+	usage, ok := s.usage[node]
+	if !ok {
+		usage = &nodeUtilization{}
+		s.usage[node] = usage
+	}
+	usage.cpu.add(100 * (sample.cpuBusy - prev.cpuBusy) / (sample.cpuTotal - prev.cpuTotal))
+	if sample.memTotalKB > 0 {
+		usage.memory.add(100 * (sample.memTotalKB - sample.memAvailableKB) / sample.memTotalKB)
+	}
+	if sample.disks > 0 {
+		diskPercent := 100 * (sample.diskIOMillis - prev.diskIOMillis) /
+			(float64(elapsed.Milliseconds()) * sample.disks)
+		usage.disk.add(min(diskPercent, 100))
+	}
+	usage.network.add((sample.netBytes - prev.netBytes) / elapsed.Seconds() / 1e6)
+}
+
+// report returns the utilization report of the nodes, given the resource the
+// test is expected to saturate, or nil if no utilization was recorded.
+func (s *utilizationSampler) report(bottleneck registry.Resource) *resourceUtilization {
+	if len(s.usage) == 0 {
+		return nil
+	}
+	var u resourceUtilization
+	summarize := func(stats *utilizationStats, acc func(*nodeUtilization) *utilizationAccumulator) {
+		for _, usage := range s.usage {
+			a := acc(usage)
+			stats.Mean = max(stats.Mean, a.mean())
+			stats.Max = max(stats.Max, a.max)
+		}
+	}
+	summarize(&u.CPUPercent, func(n *nodeUtilization) *utilizationAccumulator { return &n.cpu })
+	summarize(&u.MemoryPercent, func(n *nodeUtilization) *utilizationAccumulator { return &n.memory })
+	summarize(&u.DiskPercent, func(n *nodeUtilization) *utilizationAccumulator { return &n.disk })
+	summarize(&u.NetworkMBps, func(n *nodeUtilization) *utilizationAccumulator { return &n.network })
+
+	for _, r := range []struct {
+		resource  registry.Resource
+		saturated bool
+	}{
+		{registry.CPUBottleneck, u.CPUPercent.Mean >= cpuSaturation},
+		{registry.MemoryBottleneck, u.MemoryPercent.Max >= memorySaturation},
+		{registry.DiskBottleneck, u.DiskPercent.Mean >= diskSaturation},
+	} {
+		if !r.saturated {
+			continue
+		}
+		u.Saturated = append(u.Saturated, r.resource.String())
+		if bottleneck != registry.UnknownBottleneck && r.resource != bottleneck {
+			u.WrongBottleneck = true
+		}
+	}
+	return &u
+}
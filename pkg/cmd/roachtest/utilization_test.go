@@ -0,0 +1,57 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUtilizationSampler(t *testing.T) {
+	start := time.Date(2024, 8, 1, 0, 0, 0, 0, time.UTC)
+	sample := func(at time.Duration, out string) utilizationSample {
+		s, err := parseUtilizationSample(start.Add(at), out)
+		require.NoError(t, err)
+		return s
+	}
+	_, err := parseUtilizationSample(start, "1 2 3")
+	require.Error(t, err)
+
+	s := &utilizationSampler{
+		prev:  make(map[int]utilizationSample),
+		usage: make(map[int]*nodeUtilization),
+	}
+	require.Nil(t, s.report(registry.UnknownBottleneck))
+
+	// Node 1 is CPU bound: 90% busy, with a disk busy 10% of the time and
+	// 10 MB/s of network traffic.
+	s.record(1, sample(0, "1000 2000 1000000 800000 0 1 0\n"))
+	s.record(1, sample(10*time.Second, "1900 3000 1000000 600000 1000 1 100000000\n"))
+	// Node 2 is idle, but its memory is nearly full.
+	s.record(2, sample(0, "0 1000 1000000 50000 0 2 0\n"))
+	s.record(2, sample(10*time.Second, "100 2000 1000000 50000 0 2 0\n"))
+
+	u := s.report(registry.CPUBottleneck)
+	require.Equal(t, utilizationStats{Mean: 90, Max: 90}, u.CPUPercent)
+	require.Equal(t, utilizationStats{Mean: 95, Max: 95}, u.MemoryPercent)
+	require.Equal(t, utilizationStats{Mean: 10, Max: 10}, u.DiskPercent)
+	require.Equal(t, utilizationStats{Mean: 10, Max: 10}, u.NetworkMBps)
+	require.Equal(t, []string{"cpu", "memory"}, u.Saturated)
+	require.True(t, u.WrongBottleneck)
+	require.Equal(t, "cpu 90% (max 90%), memory 95% (max 95%), disk 10% (max 10%), "+
+		"network 10.0 MB/s (max 10.0 MB/s); saturated: cpu, memory", u.String())
+
+	// Without an expected bottleneck, the test isn't flagged.
+	require.False(t, s.report(registry.UnknownBottleneck).WrongBottleneck)
+}
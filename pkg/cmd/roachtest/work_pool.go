@@ -14,6 +14,7 @@ import (
 	"context"
 	"fmt"
 	"math"
+	"sort"
 	"time"
 
 	"github.com/cockroachdb/cockroach/pkg/cmd/roachtest/registry"
@@ -43,6 +44,11 @@ func newWorkPool(tests []registry.TestSpec, count int) *workPool {
 	for _, spec := range tests {
 		p.mu.tests = append(p.mu.tests, testWithCount{spec: spec, count: count})
 	}
+	// Tests are selected in order when they are otherwise equally suitable, so
+	// the ones with the highest scheduling priority go first.
+	sort.SliceStable(p.mu.tests, func(i, j int) bool {
+		return p.mu.tests[i].spec.SchedulingPriority() > p.mu.tests[j].spec.SchedulingPriority()
+	})
 	return p
 }
 
@@ -62,6 +68,21 @@ type testToRunRes struct {
 	// canReuseCluster is true if the selected test can reuse the cluster passed
 	// to testToRun(). Will be false if noWork is set.
 	canReuseCluster bool
+
+	// attempt is the number of times this run has been retried after failing.
+	// 0 unless --retries-per-failure was used.
+	attempt int
+	// firstFailure is the first failed attempt of this run, if attempt > 0.
+	firstFailure *testImpl
+	// requeues is the number of times this run has been requeued after its spot
+	// VMs were preempted, at most --preemption-requeues (1 by default).
+	requeues int
+}
+
+// isRerun returns whether the run was retried or requeued, i.e. it already
+// ran and failed.
+func (t testToRunRes) isRerun() bool {
+	return t.attempt > 0 || t.requeues > 0
 }
 
 func (p *workPool) workRemaining() []testWithCount {
@@ -72,6 +93,61 @@ func (p *workPool) workRemaining() []testWithCount {
 	return res
 }
 
+// runsRemaining returns the number of runs of the remaining tests.
+func (p *workPool) runsRemaining() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var runs int
+	for _, t := range p.mu.tests {
+		runs += t.count
+	}
+	return runs
+}
+
+// skipRemaining removes all the remaining tests from the pool, so that no more
+// tests are started, and returns the runs that were removed.
+func (p *workPool) skipRemaining() []notRunTest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var runs []notRunTest
+	for _, t := range p.mu.tests {
+		for runNum := p.count - t.count + 1; runNum <= p.count; runNum++ {
+			runs = append(runs, notRunTest{name: t.spec.Name, owner: string(t.spec.Owner), runNum: runNum})
+		}
+	}
+	p.mu.tests = nil
+	return runs
+}
+
+// skipTest removes the remaining runs of the given test from the pool, and
+// returns their number.
+func (p *workPool) skipTest(name string) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i, t := range p.mu.tests {
+		if t.spec.Name == name {
+			p.mu.tests = append(p.mu.tests[:i], p.mu.tests[i+1:]...)
+			return t.count
+		}
+	}
+	return 0
+}
+
+// smallestTestCPUs returns the number of CPUs needed by the smallest of the
+// remaining tests, or false if there are no remaining tests.
+func (p *workPool) smallestTestCPUs() (int, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.mu.tests) == 0 {
+		return 0, false
+	}
+	smallest := math.MaxInt64
+	for _, t := range p.mu.tests {
+		smallest = min(smallest, t.spec.Cluster.TotalCPUs())
+	}
+	return smallest, true
+}
+
 // selectTestForCluster selects a test to run on a cluster with a given spec.
 //
 // Among tests that match the spec, we do the following:
@@ -239,6 +315,14 @@ func scoreTestAgainstCluster(tc testWithCount, tag string, cr *clusterRegistry)
 	return score
 }
 
+// hasCompatibleTest returns whether any of the remaining tests can run on a
+// cluster with the given spec.
+func (p *workPool) hasCompatibleTest(s spec.ClusterSpec, cloud spec.Cloud) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.findCompatibleTestsLocked(s, cloud)) > 0
+}
+
 // findCompatibleTestsLocked returns a list of tests compatible with a cluster spec.
 func (p *workPool) findCompatibleTestsLocked(
 	clusterSpec spec.ClusterSpec, cloud spec.Cloud,
@@ -0,0 +1,672 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package raft
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	pb "github.com/cockroachdb/cockroach/pkg/raft/raftpb"
+)
+
+// ErrCorruptStorage is returned by OpenDiskStorage when the on-disk state is
+// corrupt or inconsistent.
+var ErrCorruptStorage = errors.New("raft: disk storage is corrupt")
+
+const (
+	diskStateFile     = "raft-state"
+	diskHardStateFile = "raft-hardstate"
+	diskSegmentSuffix = ".seg"
+	// diskRecordHeaderSize is the size of the header preceding each log entry in
+	// a segment: the length of the encoded entry, and its CRC.
+	diskRecordHeaderSize = 8
+	// defaultSegmentSize is the default DiskStorageOptions.SegmentSize.
+	defaultSegmentSize = 64 << 20
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// DiskStorageOptions configures a DiskStorage.
+type DiskStorageOptions struct {
+	// SegmentSize is the size after which the log segment being appended to is
+	// sealed and a new one is started. Defaults to 64 MiB if zero.
+	SegmentSize int64
+	// NoSync disables fsyncs. Appends and state updates are then not durable
+	// across machine crashes, which can be useful for benchmarks.
+	NoSync bool
+}
+
+// diskEntryPos is the location of a log entry in the segment files.
+type diskEntryPos struct {
+	seg  uint64
+	off  int64
+	size uint32
+	term uint64
+}
+
+// DiskStorage implements the Storage interface backed by files in a directory.
+// It is a simple reference implementation, intended for embedders and for
+// benchmarking with realistic write latencies, and is not optimized.
+//
+// The log is stored in append-only segment files. Each record in a segment is
+// an encoded log entry, prefixed with its length and CRC. Overwriting a suffix
+// of the log appends the new entries, and the in-memory index of entry
+// positions is rebuilt by replaying the segments on startup. The snapshot and
+// the log truncation point are stored in a separate state file, and the
+// HardState, which is updated much more often, in a small file of its own.
+// Both files are replaced atomically on every update.
+//
+// Like MemoryStorage, DiskStorage holds a single snapshot which is written by
+// CreateSnapshot or ApplySnapshot, and the log is truncated by Compact.
+type DiskStorage struct {
+	// Protects access to all fields. Most methods of DiskStorage are run on the
+	// raft goroutine, but Append() is run on an application goroutine.
+	sync.Mutex
+
+	dir  string
+	opts DiskStorageOptions
+
+	hardState pb.HardState
+	snapshot  pb.Snapshot
+	// offIndex and offTerm identify the entry preceding the first entry in the
+	// log, similarly to the dummy entry in MemoryStorage.
+	offIndex, offTerm uint64
+	// pos[i] is the position of the entry at index offIndex+1+i.
+	pos []diskEntryPos
+
+	// firstSeg is the first segment which may contain live entries. Segments
+	// before it are obsolete, and removed.
+	firstSeg uint64
+	// segs contains all the open segment files, keyed by sequence number.
+	segs map[uint64]*os.File
+	// curSeg is the segment being appended to, and curSize is its size.
+	curSeg  uint64
+	curSize int64
+}
+
+// OpenDiskStorage opens the DiskStorage in the given directory, creating it if
+// it does not exist. The caller must Close the storage when done with it.
+func OpenDiskStorage(dir string, opts DiskStorageOptions) (*DiskStorage, error) {
+	if opts.SegmentSize == 0 {
+		opts.SegmentSize = defaultSegmentSize
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	ds := &DiskStorage{dir: dir, opts: opts, segs: map[uint64]*os.File{}}
+	if err := ds.load(); err != nil {
+		_ = ds.Close()
+		return nil, err
+	}
+	return ds, nil
+}
+
+// Close closes all the files held by the storage.
+func (ds *DiskStorage) Close() error {
+	ds.Lock()
+	defer ds.Unlock()
+	var err error
+	for seq, f := range ds.segs {
+		err = errors.Join(err, f.Close())
+		delete(ds.segs, seq)
+	}
+	return err
+}
+
+// InitialState implements the Storage interface.
+func (ds *DiskStorage) InitialState() (pb.HardState, pb.ConfState, error) {
+	ds.Lock()
+	defer ds.Unlock()
+	return ds.hardState, ds.snapshot.Metadata.ConfState, nil
+}
+
+// SetHardState saves the current HardState.
+func (ds *DiskStorage) SetHardState(st pb.HardState) error {
+	ds.Lock()
+	defer ds.Unlock()
+	ds.hardState = st
+	return ds.writeHardState()
+}
+
+// Entries implements the Storage interface.
+func (ds *DiskStorage) Entries(lo, hi, maxSize uint64) ([]pb.Entry, error) {
+	ds.Lock()
+	defer ds.Unlock()
+	if lo <= ds.offIndex {
+		return nil, ErrCompacted
+	}
+	if hi > ds.lastIndex()+1 {
+		getLogger().Panicf("entries' hi(%d) is out of bound lastindex(%d)", hi, ds.lastIndex())
+	}
+	if len(ds.pos) == 0 {
+		return nil, ErrUnavailable
+	}
+
+	// The record size is the entry encoding size, so the limit can be applied
+	// before reading the entries.
+	positions := ds.pos[lo-ds.offIndex-1 : hi-ds.offIndex-1]
+	var size uint64
+	for limit := 0; limit < len(positions); limit++ {
+		if size += uint64(positions[limit].size); limit > 0 && size > maxSize {
+			positions = positions[:limit]
+			break
+		}
+	}
+	ents := make([]pb.Entry, 0, len(positions))
+	for _, p := range positions {
+		ent, err := ds.readEntry(p)
+		if err != nil {
+			return nil, err
+		}
+		ents = append(ents, ent)
+	}
+	return ents, nil
+}
+
+// Term implements the Storage interface.
+func (ds *DiskStorage) Term(i uint64) (uint64, error) {
+	ds.Lock()
+	defer ds.Unlock()
+	return ds.term(i)
+}
+
+func (ds *DiskStorage) term(i uint64) (uint64, error) {
+	switch {
+	case i < ds.offIndex:
+		return 0, ErrCompacted
+	case i == ds.offIndex:
+		return ds.offTerm, nil
+	case i > ds.lastIndex():
+		return 0, ErrUnavailable
+	}
+	return ds.pos[i-ds.offIndex-1].term, nil
+}
+
+// LastIndex implements the Storage interface.
+func (ds *DiskStorage) LastIndex() (uint64, error) {
+	ds.Lock()
+	defer ds.Unlock()
+	return ds.lastIndex(), nil
+}
+
+func (ds *DiskStorage) lastIndex() uint64 {
+	return ds.offIndex + uint64(len(ds.pos))
+}
+
+// FirstIndex implements the Storage interface.
+func (ds *DiskStorage) FirstIndex() (uint64, error) {
+	ds.Lock()
+	defer ds.Unlock()
+	return ds.offIndex + 1, nil
+}
+
+// Stats implements the StorageStats interface.
+func (ds *DiskStorage) Stats() (LogStats, error) {
+	ds.Lock()
+	defer ds.Unlock()
+	st := LogStats{
+		Entries:    uint64(len(ds.pos)),
+		FirstIndex: ds.offIndex + 1,
+		LastIndex:  ds.lastIndex(),
+	}
+	for _, p := range ds.pos {
+		st.Bytes += uint64(p.size)
+	}
+	return st, nil
+}
+
+// Snapshot implements the Storage interface.
+func (ds *DiskStorage) Snapshot() (pb.Snapshot, error) {
+	ds.Lock()
+	defer ds.Unlock()
+	return ds.snapshot, nil
+}
+
+// ApplySnapshot overwrites the contents of this Storage object with those of
+// the given snapshot.
+func (ds *DiskStorage) ApplySnapshot(snap pb.Snapshot) error {
+	ds.Lock()
+	defer ds.Unlock()
+	if ds.snapshot.Metadata.Index >= snap.Metadata.Index {
+		return ErrSnapOutOfDate
+	}
+	if err := snap.VerifyIntegrity(); err != nil {
+		return err
+	}
+	// Start a new segment, so that all the existing ones become obsolete.
+	if err := ds.rollSegment(); err != nil {
+		return err
+	}
+	ds.snapshot = snap
+	ds.offIndex, ds.offTerm = snap.Metadata.Index, snap.Metadata.Term
+	ds.pos = nil
+	ds.firstSeg = ds.curSeg
+	if err := ds.writeState(); err != nil {
+		return err
+	}
+	return ds.removeObsoleteSegments()
+}
+
+// CreateSnapshot makes a snapshot which can be retrieved with Snapshot() and
+// can be used to reconstruct the state at that point.
+// If any configuration changes have been made since the last compaction,
+// the result of the last ApplyConfChange must be passed in.
+func (ds *DiskStorage) CreateSnapshot(
+	i uint64, cs *pb.ConfState, data []byte,
+) (pb.Snapshot, error) {
+	ds.Lock()
+	defer ds.Unlock()
+	if i <= ds.snapshot.Metadata.Index {
+		return pb.Snapshot{}, ErrSnapOutOfDate
+	}
+	if i > ds.lastIndex() {
+		getLogger().Panicf("snapshot %d is out of bound lastindex(%d)", i, ds.lastIndex())
+	}
+	term, err := ds.term(i)
+	if err != nil {
+		return pb.Snapshot{}, err
+	}
+	snap := ds.snapshot
+	snap.Metadata.Index = i
+	snap.Metadata.Term = term
+	if cs != nil {
+		snap.Metadata.ConfState = *cs
+	}
+	snap.Data = data
+
+	prev := ds.snapshot
+	ds.snapshot = snap
+	if err := ds.writeState(); err != nil {
+		ds.snapshot = prev
+		return pb.Snapshot{}, err
+	}
+	return snap, nil
+}
+
+// Compact discards all log entries prior to compactIndex, and removes the
+// segment files which no longer contain live entries.
+// It is the application's responsibility to not attempt to compact an index
+// greater than raftLog.applied.
+func (ds *DiskStorage) Compact(compactIndex uint64) error {
+	ds.Lock()
+	defer ds.Unlock()
+	if compactIndex <= ds.offIndex {
+		return ErrCompacted
+	}
+	if compactIndex > ds.lastIndex() {
+		getLogger().Panicf("compact %d is out of bound lastindex(%d)", compactIndex, ds.lastIndex())
+	}
+	i := compactIndex - ds.offIndex - 1
+	ds.offIndex, ds.offTerm = compactIndex, ds.pos[i].term
+	ds.pos = slices.Clone(ds.pos[i+1:])
+	// Entry positions are ordered by segment, so all the segments preceding the
+	// one containing the first live entry are obsolete.
+	ds.firstSeg = ds.curSeg
+	if len(ds.pos) != 0 {
+		ds.firstSeg = ds.pos[0].seg
+	}
+	if err := ds.writeState(); err != nil {
+		return err
+	}
+	return ds.removeObsoleteSegments()
+}
+
+// Append the new entries to storage. The entries must be contiguous, and must
+// not leave a gap after the last entry in the log.
+func (ds *DiskStorage) Append(entries []pb.Entry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	ds.Lock()
+	defer ds.Unlock()
+
+	first := ds.offIndex + 1
+	last := entries[0].Index + uint64(len(entries)) - 1
+	// shortcut if there is no new entry.
+	if last < first {
+		return nil
+	}
+	// truncate compacted entries
+	if first > entries[0].Index {
+		entries = entries[first-entries[0].Index:]
+	}
+	if entries[0].Index > ds.lastIndex()+1 {
+		getLogger().Panicf("missing log entry [last: %d, append at: %d]",
+			ds.lastIndex(), entries[0].Index)
+	}
+
+	if ds.curSize >= ds.opts.SegmentSize {
+		if err := ds.rollSegment(); err != nil {
+			return err
+		}
+	}
+	var buf []byte
+	positions := make([]diskEntryPos, 0, len(entries))
+	for i := range entries {
+		data, err := entries[i].Marshal()
+		if err != nil {
+			return err
+		}
+		positions = append(positions, diskEntryPos{
+			seg:  ds.curSeg,
+			off:  ds.curSize + int64(len(buf)),
+			size: uint32(len(data)),
+			term: entries[i].Term,
+		})
+		buf = binary.LittleEndian.AppendUint32(buf, uint32(len(data)))
+		buf = binary.LittleEndian.AppendUint32(buf, crc32.Checksum(data, crcTable))
+		buf = append(buf, data...)
+	}
+	f := ds.segs[ds.curSeg]
+	if _, err := f.WriteAt(buf, ds.curSize); err != nil {
+		return err
+	}
+	if err := ds.sync(f); err != nil {
+		return err
+	}
+	ds.curSize += int64(len(buf))
+	ds.pos = append(ds.pos[:entries[0].Index-ds.offIndex-1], positions...)
+	return nil
+}
+
+// readEntry reads the log entry at the given position.
+func (ds *DiskStorage) readEntry(p diskEntryPos) (pb.Entry, error) {
+	buf := make([]byte, diskRecordHeaderSize+int(p.size))
+	if _, err := ds.segs[p.seg].ReadAt(buf, p.off); err != nil {
+		return pb.Entry{}, err
+	}
+	var ent pb.Entry
+	if _, err := decodeDiskRecord(buf, &ent); err != nil {
+		return pb.Entry{}, err
+	}
+	return ent, nil
+}
+
+// decodeDiskRecord decodes the record at the start of buf into ent, and returns
+// the size of the record. Returns io.ErrUnexpectedEOF if buf does not contain
+// the whole record, or ErrCorruptStorage if the checksum does not match.
+func decodeDiskRecord(buf []byte, ent *pb.Entry) (int, error) {
+	if len(buf) < diskRecordHeaderSize {
+		return 0, io.ErrUnexpectedEOF
+	}
+	size := int(binary.LittleEndian.Uint32(buf))
+	crc := binary.LittleEndian.Uint32(buf[4:])
+	if len(buf) < diskRecordHeaderSize+size {
+		return 0, io.ErrUnexpectedEOF
+	}
+	data := buf[diskRecordHeaderSize : diskRecordHeaderSize+size]
+	if crc32.Checksum(data, crcTable) != crc {
+		return 0, ErrCorruptStorage
+	}
+	if err := ent.Unmarshal(data); err != nil {
+		return 0, err
+	}
+	return diskRecordHeaderSize + size, nil
+}
+
+// load reads the state file, and replays the segments to rebuild the index of
+// entry positions.
+func (ds *DiskStorage) load() error {
+	if err := ds.readState(); err != nil {
+		return err
+	}
+	seqs, err := ds.listSegments()
+	if err != nil {
+		return err
+	}
+	ds.curSeg = ds.firstSeg
+	for i, seq := range seqs {
+		if seq < ds.firstSeg {
+			// The segment became obsolete, but was not removed before a restart.
+			if err := os.Remove(ds.segmentPath(seq)); err != nil {
+				return err
+			}
+			continue
+		}
+		f, err := os.OpenFile(ds.segmentPath(seq), os.O_RDWR, 0)
+		if err != nil {
+			return err
+		}
+		ds.segs[seq] = f
+		size, err := ds.replaySegment(seq, f, i == len(seqs)-1)
+		if err != nil {
+			return err
+		}
+		ds.curSeg, ds.curSize = seq, size
+	}
+	if _, ok := ds.segs[ds.curSeg]; !ok {
+		return ds.openSegment(ds.curSeg)
+	}
+	return nil
+}
+
+// replaySegment adds the positions of all entries in the given segment to the
+// index, and returns the size of the segment. A torn write at the end of the
+// last segment is truncated.
+func (ds *DiskStorage) replaySegment(seq uint64, f *os.File, last bool) (int64, error) {
+	buf, err := io.ReadAll(f)
+	if err != nil {
+		return 0, err
+	}
+	var off int
+	for off < len(buf) {
+		var ent pb.Entry
+		n, err := decodeDiskRecord(buf[off:], &ent)
+		if err != nil {
+			if !last {
+				return 0, fmt.Errorf("%w: segment %d at offset %d: %v", ErrCorruptStorage, seq, off, err)
+			}
+			// The tail of the last segment may contain a partially written batch of
+			// entries. It was never acknowledged, so it is safe to discard.
+			getLogger().Warningf("truncating segment %d at offset %d: %v", seq, off, err)
+			if err := f.Truncate(int64(off)); err != nil {
+				return 0, err
+			}
+			break
+		}
+		if ent.Index > ds.offIndex {
+			if ent.Index > ds.lastIndex()+1 {
+				return 0, fmt.Errorf("%w: segment %d: missing log entry [last: %d, append at: %d]",
+					ErrCorruptStorage, seq, ds.lastIndex(), ent.Index)
+			}
+			ds.pos = append(ds.pos[:ent.Index-ds.offIndex-1], diskEntryPos{
+				seg: seq, off: int64(off), size: uint32(n - diskRecordHeaderSize), term: ent.Term,
+			})
+		}
+		off += n
+	}
+	return int64(off), nil
+}
+
+// listSegments returns the sorted sequence numbers of the segment files.
+func (ds *DiskStorage) listSegments() ([]uint64, error) {
+	dirEnts, err := os.ReadDir(ds.dir)
+	if err != nil {
+		return nil, err
+	}
+	var seqs []uint64
+	for _, e := range dirEnts {
+		name, ok := strings.CutSuffix(e.Name(), diskSegmentSuffix)
+		if !ok {
+			continue
+		}
+		seq, err := strconv.ParseUint(name, 16, 64)
+		if err != nil {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	slices.Sort(seqs)
+	return seqs, nil
+}
+
+func (ds *DiskStorage) segmentPath(seq uint64) string {
+	return filepath.Join(ds.dir, fmt.Sprintf("%016x%s", seq, diskSegmentSuffix))
+}
+
+// openSegment creates the segment with the given sequence number, and makes it
+// the one being appended to.
+func (ds *DiskStorage) openSegment(seq uint64) error {
+	f, err := os.OpenFile(ds.segmentPath(seq), os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if err := ds.syncDir(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	ds.segs[seq] = f
+	ds.curSeg, ds.curSize = seq, 0
+	return nil
+}
+
+// rollSegment seals the current segment and starts a new one.
+func (ds *DiskStorage) rollSegment() error {
+	return ds.openSegment(ds.curSeg + 1)
+}
+
+// removeObsoleteSegments removes the segments preceding firstSeg.
+func (ds *DiskStorage) removeObsoleteSegments() error {
+	for seq, f := range ds.segs {
+		if seq >= ds.firstSeg {
+			continue
+		}
+		delete(ds.segs, seq)
+		if err := errors.Join(f.Close(), os.Remove(ds.segmentPath(seq))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeState atomically replaces the state file with the current snapshot and
+// log truncation point.
+//
+// The state file contains the firstSeg, offIndex and offTerm fields, followed
+// by the snapshot, and a trailing CRC.
+func (ds *DiskStorage) writeState() error {
+	snap, err := ds.snapshot.Marshal()
+	if err != nil {
+		return err
+	}
+	var buf []byte
+	buf = binary.LittleEndian.AppendUint64(buf, ds.firstSeg)
+	buf = binary.LittleEndian.AppendUint64(buf, ds.offIndex)
+	buf = binary.LittleEndian.AppendUint64(buf, ds.offTerm)
+	buf = append(buf, snap...)
+	return ds.writeFile(diskStateFile, buf)
+}
+
+// writeHardState atomically replaces the HardState file with the current
+// HardState, followed by a trailing CRC. It is small, and doesn't depend on the
+// size of the snapshot.
+func (ds *DiskStorage) writeHardState() error {
+	hs, err := ds.hardState.Marshal()
+	if err != nil {
+		return err
+	}
+	return ds.writeFile(diskHardStateFile, hs)
+}
+
+// readState reads the state and HardState files, if they exist.
+func (ds *DiskStorage) readState() error {
+	if data, err := ds.readFile(diskStateFile); err != nil {
+		return err
+	} else if data != nil {
+		if len(data) < 24 {
+			return fmt.Errorf("%w: invalid %s file", ErrCorruptStorage, diskStateFile)
+		}
+		ds.firstSeg = binary.LittleEndian.Uint64(data)
+		ds.offIndex = binary.LittleEndian.Uint64(data[8:])
+		ds.offTerm = binary.LittleEndian.Uint64(data[16:])
+		if err := ds.snapshot.Unmarshal(data[24:]); err != nil {
+			return err
+		}
+	}
+	if data, err := ds.readFile(diskHardStateFile); err != nil {
+		return err
+	} else if data != nil {
+		if err := ds.hardState.Unmarshal(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFile atomically replaces the given file in the storage directory with
+// the given data, followed by its CRC.
+func (ds *DiskStorage) writeFile(name string, data []byte) error {
+	buf := binary.LittleEndian.AppendUint32(data, crc32.Checksum(data, crcTable))
+	path := filepath.Join(ds.dir, name)
+	tmp := path + ".tmp"
+	f, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := errors.Join(ds.sync(f), f.Close()); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	return ds.syncDir()
+}
+
+// readFile reads a file written by writeFile, and returns its data after
+// verifying the CRC. Returns nil if the file does not exist.
+func (ds *DiskStorage) readFile(name string) ([]byte, error) {
+	buf, err := os.ReadFile(filepath.Join(ds.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if len(buf) < 4 {
+		return nil, fmt.Errorf("%w: invalid %s file", ErrCorruptStorage, name)
+	}
+	data, crc := buf[:len(buf)-4], binary.LittleEndian.Uint32(buf[len(buf)-4:])
+	if crc32.Checksum(data, crcTable) != crc {
+		return nil, fmt.Errorf("%w: invalid %s file", ErrCorruptStorage, name)
+	}
+	return data, nil
+}
+
+func (ds *DiskStorage) sync(f *os.File) error {
+	if ds.opts.NoSync {
+		return nil
+	}
+	return f.Sync()
+}
+
+func (ds *DiskStorage) syncDir() error {
+	if ds.opts.NoSync {
+		return nil
+	}
+	d, err := os.Open(ds.dir)
+	if err != nil {
+		return err
+	}
+	return errors.Join(d.Sync(), d.Close())
+}
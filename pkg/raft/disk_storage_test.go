@@ -0,0 +1,156 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package raft
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	pb "github.com/cockroachdb/cockroach/pkg/raft/raftpb"
+	"github.com/stretchr/testify/require"
+)
+
+func openTestDiskStorage(t *testing.T, dir string) *DiskStorage {
+	// Use small segments to exercise rolling over and removing them.
+	ds, err := OpenDiskStorage(dir, DiskStorageOptions{SegmentSize: 64, NoSync: true})
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = ds.Close() })
+	return ds
+}
+
+func requireDiskStorageLog(t *testing.T, ds *DiskStorage, want []pb.Entry) {
+	t.Helper()
+	first, err := ds.FirstIndex()
+	require.NoError(t, err)
+	last, err := ds.LastIndex()
+	require.NoError(t, err)
+	require.Equal(t, want[0].Index+1, first)
+	require.Equal(t, want[len(want)-1].Index, last)
+	for _, e := range want {
+		term, err := ds.Term(e.Index)
+		require.NoError(t, err)
+		require.Equal(t, e.Term, term)
+	}
+	if first > last {
+		return
+	}
+	ents, err := ds.Entries(first, last+1, math.MaxUint64)
+	require.NoError(t, err)
+	require.Equal(t, want[1:], ents)
+}
+
+func TestDiskStorage(t *testing.T) {
+	dir := t.TempDir()
+	ds := openTestDiskStorage(t, dir)
+	requireDiskStorageLog(t, ds, index(0).terms(0))
+
+	hs := pb.HardState{Term: 3, Vote: 1, Commit: 2}
+	require.NoError(t, ds.SetHardState(hs))
+	require.NoError(t, ds.Append(index(1).terms(1, 1, 2, 2, 2)))
+	// Overwrite a suffix of the log.
+	require.NoError(t, ds.Append(index(4).terms(3, 3, 3)))
+	want := index(0).terms(0, 1, 1, 2, 3, 3, 3)
+	requireDiskStorageLog(t, ds, want)
+	st, err := ds.Stats()
+	require.NoError(t, err)
+	require.Equal(t, LogStats{
+		Bytes: uint64(entsSize(want[1:])), Entries: 6, FirstIndex: 1, LastIndex: 6,
+	}, st)
+
+	// Entries respects the size limit, and returns at least one entry.
+	ents, err := ds.Entries(2, 7, 0)
+	require.NoError(t, err)
+	require.Equal(t, want[2:3], ents)
+	ents, err = ds.Entries(2, 7, uint64(want[2].Size()+want[3].Size()))
+	require.NoError(t, err)
+	require.Equal(t, want[2:4], ents)
+
+	// The state survives a restart.
+	require.NoError(t, ds.Close())
+	ds = openTestDiskStorage(t, dir)
+	requireDiskStorageLog(t, ds, want)
+	gotHS, _, err := ds.InitialState()
+	require.NoError(t, err)
+	require.Equal(t, hs, gotHS)
+
+	// Compaction removes the obsolete segments, and survives a restart.
+	cs := &pb.ConfState{Voters: []pb.PeerID{1, 2, 3}}
+	snap, err := ds.CreateSnapshot(5, cs, []byte("data"))
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), snap.Metadata.Term)
+	segs, err := ds.listSegments()
+	require.NoError(t, err)
+	require.NoError(t, ds.Compact(5))
+	_, err = ds.Term(4)
+	require.Equal(t, ErrCompacted, err)
+	segsAfter, err := ds.listSegments()
+	require.NoError(t, err)
+	require.Less(t, len(segsAfter), len(segs))
+
+	require.NoError(t, ds.Close())
+	ds = openTestDiskStorage(t, dir)
+	requireDiskStorageLog(t, ds, want[5:])
+	got, err := ds.Snapshot()
+	require.NoError(t, err)
+	require.Equal(t, snap, got)
+	_, gotCS, err := ds.InitialState()
+	require.NoError(t, err)
+	require.Equal(t, *cs, gotCS)
+
+	// Updating the HardState doesn't rewrite the snapshot.
+	state, err := os.ReadFile(filepath.Join(dir, diskStateFile))
+	require.NoError(t, err)
+	hs.Commit = 5
+	require.NoError(t, ds.SetHardState(hs))
+	stateAfter, err := os.ReadFile(filepath.Join(dir, diskStateFile))
+	require.NoError(t, err)
+	require.Equal(t, state, stateAfter)
+	require.NoError(t, ds.Close())
+	ds = openTestDiskStorage(t, dir)
+	gotHS, _, err = ds.InitialState()
+	require.NoError(t, err)
+	require.Equal(t, hs, gotHS)
+
+	// Applying a snapshot discards the whole log.
+	snap = pb.Snapshot{Metadata: pb.SnapshotMetadata{Index: 10, Term: 4, ConfState: *cs}}
+	require.Equal(t, ErrSnapOutOfDate, ds.ApplySnapshot(pb.Snapshot{}))
+	require.NoError(t, ds.ApplySnapshot(snap))
+	requireDiskStorageLog(t, ds, index(10).terms(4))
+	require.NoError(t, ds.Append(index(11).terms(4, 5)))
+
+	require.NoError(t, ds.Close())
+	ds = openTestDiskStorage(t, dir)
+	requireDiskStorageLog(t, ds, index(10).terms(4, 4, 5))
+}
+
+// TestDiskStorageTornWrite tests that a partially written record at the end of
+// the log is discarded on restart.
+func TestDiskStorageTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	ds := openTestDiskStorage(t, dir)
+	require.NoError(t, ds.Append(index(1).terms(1, 1)))
+	last := ds.pos[len(ds.pos)-1]
+	path := ds.segmentPath(last.seg)
+	require.NoError(t, ds.Close())
+
+	// Chop off the tail of the last record.
+	require.NoError(t, os.Truncate(path, last.off+diskRecordHeaderSize+1))
+	ds = openTestDiskStorage(t, dir)
+	requireDiskStorageLog(t, ds, index(0).terms(0, 1))
+
+	// The log can be appended to after the truncation.
+	require.NoError(t, ds.Append(index(2).terms(2, 2)))
+	require.NoError(t, ds.Close())
+	ds = openTestDiskStorage(t, dir)
+	requireDiskStorageLog(t, ds, index(0).terms(0, 1, 2, 2))
+}
@@ -62,6 +62,12 @@ type raftLog struct {
 	// applyingEntsPaused is true when entry application has been paused until
 	// enough progress is acknowledged.
 	applyingEntsPaused bool
+
+	// onTruncate is Config.OnDivergentLogTruncation, see there for details.
+	onTruncate func(lo, hi uint64)
+	// watermarks contains the waiters for the committed and applied indices. It
+	// is nil until the first RawNode.WaitFor call.
+	watermarks *watermarks
 }
 
 // newLog returns log using the given storage and default options. It
@@ -153,10 +159,21 @@ func (l *raftLog) maybeAppend(a logSlice) bool {
 		// can carry a newer a.term, which should update our accTerm.
 		return true
 	}
-	if first := a.entries[0].Index; first <= l.committed {
+	first := a.entries[0].Index
+	if first <= l.committed {
 		l.logger.Panicf("entry %d is already committed [committed(%d)]", first, l.committed)
 	}
-	return l.unstable.truncateAndAppend(a)
+	last := l.lastIndex()
+	if !l.unstable.truncateAndAppend(a) {
+		return false
+	}
+	// The entries at indices >= first diverged from the appended log slice, and
+	// were overwritten in memory. Let the application know before the new
+	// entries are handed out for writing to storage.
+	if first <= last && l.onTruncate != nil {
+		l.onTruncate(first, last)
+	}
+	return true
 }
 
 // append adds the given log slice to the end of the log.
@@ -325,6 +342,21 @@ func (l *raftLog) hasNextOrInProgressSnapshot() bool {
 	return l.unstable.snapshot != nil
 }
 
+// stableStats returns the statistics of the stable log, if the storage
+// implements StorageStats. Returns false otherwise.
+func (l *raftLog) stableStats() (LogStats, bool) {
+	ss, ok := l.storage.(StorageStats)
+	if !ok {
+		return LogStats{}, false
+	}
+	st, err := ss.Stats()
+	if err != nil {
+		l.logger.Warningf("failed to get log stats: %v", err)
+		return LogStats{}, false
+	}
+	return st, true
+}
+
 func (l *raftLog) snapshot() (pb.Snapshot, error) {
 	if l.unstable.snapshot != nil {
 		return *l.unstable.snapshot, nil
@@ -347,22 +379,62 @@ func (l *raftLog) lastIndex() uint64 {
 	return l.unstable.lastIndex()
 }
 
+// lastWrittenIndex returns the last index of the log which is durable in
+// storage, or is being written to it.
+//
+// The leader can only learn that our log matches its own up to some index from
+// our MsgAppResp acks, which are released once the acked entries are durable.
+// With asynchronous storage writes, the leader can receive such an ack before
+// the raftLog learns about the durability (see stableTo), so the entries that
+// are in progress are counted too.
+func (l *raftLog) lastWrittenIndex() uint64 {
+	if u := &l.unstable; u.snapshot == nil || u.snapshotInProgress {
+		return u.entryInProgress
+	}
+	// The pending snapshot, and thus the entries following it, have not been
+	// sent to storage yet. The durable log is the one in storage.
+	index, err := l.storage.LastIndex()
+	if err != nil {
+		panic(err) // TODO(bdarnell)
+	}
+	return index
+}
+
 // commitTo bumps the commit index to the given value if it is higher than the
 // current commit index.
+//
+// The caller must make sure that the log is consistent with the mark.term
+// leader's log up to mark.index. See maybeCommit for a variant that checks it.
 func (l *raftLog) commitTo(mark logMark) {
-	// TODO(pav-kv): it is only safe to update the commit index if our log is
-	// consistent with the mark.term leader. If the mark.term leader sees the
-	// mark.index entry as committed, all future leaders have it in the log. It is
-	// thus safe to bump the commit index to min(mark.index, lastIndex) if our
-	// accTerm >= mark.term. Do this once raftLog/unstable tracks the accTerm.
-
 	// never decrease commit
 	if l.committed < mark.index {
 		if l.lastIndex() < mark.index {
 			l.logger.Panicf("tocommit(%d) is out of range [lastIndex(%d)]. Was the raft log corrupted, truncated, or lost?", mark.index, l.lastIndex())
 		}
 		l.committed = mark.index
+		l.watermarks.advance(WatermarkCommitted, l.committed)
+	}
+}
+
+// maybeCommit bumps the commit index to min(mark.index, lastIndex), given that
+// the mark.term leader considers the log up to mark.index committed. Returns
+// true if the commit index was bumped.
+//
+// If the mark.term leader sees the mark.index entry as committed, all future
+// leaders have it in the log. Our log is a prefix of the accTerm leader's log,
+// so it is safe to bump the commit index if accTerm >= mark.term. Otherwise, we
+// don't know whether our log is consistent with the mark.term leader's log, and
+// the commit index is not changed.
+func (l *raftLog) maybeCommit(mark logMark) bool {
+	if l.accTerm() < mark.term {
+		return false
 	}
+	index := min(mark.index, l.lastIndex())
+	if index <= l.committed {
+		return false
+	}
+	l.commitTo(logMark{term: mark.term, index: index})
+	return true
 }
 
 func (l *raftLog) appliedTo(i uint64, size entryEncodingSize) {
@@ -370,6 +442,7 @@ func (l *raftLog) appliedTo(i uint64, size entryEncodingSize) {
 		l.logger.Panicf("applied(%d) is out of range [prevApplied(%d), committed(%d)]", i, l.applied, l.committed)
 	}
 	l.applied = i
+	l.watermarks.advance(WatermarkApplied, i)
 	l.applying = max(l.applying, i)
 	if l.applyingEntsSize > size {
 		l.applyingEntsSize -= size
@@ -378,6 +451,9 @@ func (l *raftLog) appliedTo(i uint64, size entryEncodingSize) {
 		l.applyingEntsSize = 0
 	}
 	l.applyingEntsPaused = l.applyingEntsSize >= l.maxApplyingEntsSize
+	if o, ok := l.storage.(appliedObserver); ok {
+		o.appliedTo(i)
+	}
 }
 
 func (l *raftLog) acceptApplying(i uint64, size entryEncodingSize, allowUnstable bool) {
@@ -495,6 +571,7 @@ func (l *raftLog) restore(s snapshot) bool {
 		return false
 	}
 	l.committed = id.index
+	l.watermarks.advance(WatermarkCommitted, l.committed)
 	return true
 }
 
@@ -257,6 +257,54 @@ func TestLogMaybeAppend(t *testing.T) {
 	}
 }
 
+// TestLogMaybeAppendTruncationCallback tests that maybeAppend notifies the
+// application about the divergent log suffix it truncates, and only if the
+// append is accepted.
+func TestLogMaybeAppendTruncationCallback(t *testing.T) {
+	init := entryID{}.append(1, 2, 3, 3)
+	for _, tt := range []struct {
+		app  logSlice
+		term uint64 // the term of the append, if not the default 100
+		want [][2]uint64
+	}{
+		// No truncation.
+		{app: init.lastEntryID().append(4)},
+		{app: entryID{term: 2, index: 2}.append(3, 3)},
+		{app: entryID{term: 3, index: 4}.append()},
+		// Rejected append.
+		{app: entryID{term: 4, index: 4}.append(4)},
+		// Rejected truncation from an outdated leader.
+		{app: entryID{term: 1, index: 1}.append(2, 2), term: 2},
+		// Truncation of a divergent suffix.
+		{app: entryID{term: 2, index: 2}.append(4), want: [][2]uint64{{3, 4}}},
+		{app: entryID{term: 3, index: 3}.append(4, 4, 4), want: [][2]uint64{{4, 4}}},
+		{app: entryID{term: 2, index: 2}.append(3, 4), want: [][2]uint64{{4, 4}}},
+	} {
+		t.Run("", func(t *testing.T) {
+			app := tt.app
+			app.term = 100
+			if tt.term != 0 {
+				app.term = tt.term
+			}
+			require.NoError(t, app.valid())
+
+			raftLog := newLog(NewMemoryStorage(), discardLogger)
+			require.True(t, raftLog.append(init))
+			raftLog.committed = 1
+			var got [][2]uint64
+			raftLog.onTruncate = func(lo, hi uint64) {
+				got = append(got, [2]uint64{lo, hi})
+			}
+			ok := raftLog.maybeAppend(app)
+			require.Equal(t, tt.want, got)
+			if tt.term != 0 {
+				require.False(t, ok)
+				require.Equal(t, init.lastIndex(), raftLog.lastIndex())
+			}
+		})
+	}
+}
+
 // TestCompactionSideEffects ensures that all the log related functionality
 // works correctly after a compaction.
 func TestCompactionSideEffects(t *testing.T) {
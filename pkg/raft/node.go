@@ -20,6 +20,8 @@ package raft
 import (
 	"context"
 	"errors"
+	"fmt"
+	"time"
 
 	pb "github.com/cockroachdb/cockroach/pkg/raft/raftpb"
 )
@@ -38,6 +40,33 @@ var (
 	ErrStopped = errors.New("raft: stopped")
 )
 
+// ProposalQueueFullError is returned by the proposal methods of a Node when its
+// bounded proposal queue is full, see Config.MaxProposalQueueLen. The proposal
+// was not made, and can be retried later.
+type ProposalQueueFullError struct {
+	// Len is the capacity of the proposal queue.
+	Len int
+}
+
+func (e *ProposalQueueFullError) Error() string {
+	return fmt.Sprintf("raft: proposal queue is full (%d proposals)", e.Len)
+}
+
+// ProposalQueueMetrics is notified of the activity of the bounded proposal
+// queue of a Node, see Config.MaxProposalQueueLen. Its methods are called
+// concurrently, from the goroutines making proposals and from the raft
+// goroutine, and must not block.
+type ProposalQueueMetrics interface {
+	// Enqueued is called when a proposal is added to the queue, with the length
+	// of the queue after adding it.
+	Enqueued(queueLen int)
+	// Dequeued is called when the raft goroutine takes a proposal off the queue,
+	// with the time the proposal spent in the queue.
+	Dequeued(wait time.Duration)
+	// Rejected is called when a proposal is rejected because the queue is full.
+	Rejected()
+}
+
 // SoftState provides state that is useful for logging and debugging.
 // The state is volatile and does not need to be persisted to the WAL.
 type SoftState struct {
@@ -246,6 +275,7 @@ func setupNode(c *Config, peers []Peer) *node {
 	}
 
 	n := newNode(rn)
+	n.setProposalQueue(c.MaxProposalQueueLen, c.ProposalQueueMetrics)
 	return &n
 }
 
@@ -269,6 +299,7 @@ func RestartNode(c *Config) Node {
 		panic(err)
 	}
 	n := newNode(rn)
+	n.setProposalQueue(c.MaxProposalQueueLen, c.ProposalQueueMetrics)
 	go n.run()
 	return &n
 }
@@ -276,6 +307,9 @@ func RestartNode(c *Config) Node {
 type msgWithResult struct {
 	m      pb.Message
 	result chan error
+	// enqueued is the time at which the message was added to a bounded proposal
+	// queue. Zero if the queue is unbounded.
+	enqueued time.Time
 }
 
 // node is the canonical implementation of the Node interface
@@ -291,6 +325,11 @@ type node struct {
 	stop       chan struct{}
 	status     chan chan Status
 
+	// propQueueLen is the capacity of propc if the proposal queue is bounded,
+	// and zero otherwise. propMetrics is notified of the queue's activity.
+	propQueueLen int
+	propMetrics  ProposalQueueMetrics
+
 	rn *RawNode
 }
 
@@ -313,6 +352,17 @@ func newNode(rn *RawNode) node {
 	}
 }
 
+// setProposalQueue makes the proposal queue bounded, if queueLen is positive.
+// Must be called before the node is started.
+func (n *node) setProposalQueue(queueLen int, metrics ProposalQueueMetrics) {
+	if queueLen <= 0 {
+		return
+	}
+	n.propc = make(chan msgWithResult, queueLen)
+	n.propQueueLen = queueLen
+	n.propMetrics = metrics
+}
+
 func (n *node) Stop() {
 	select {
 	case n.stop <- struct{}{}:
@@ -369,6 +419,9 @@ func (n *node) run() {
 		// described in raft dissertation)
 		// Currently it is dropped in Step silently.
 		case pm := <-propc:
+			if n.propMetrics != nil && !pm.enqueued.IsZero() {
+				n.propMetrics.Dequeued(time.Since(pm.enqueued))
+			}
 			m := pm.m
 			m.From = r.id
 			err := r.Step(m)
@@ -430,7 +483,7 @@ func (n *node) run() {
 			rd = Ready{}
 			advancec = nil
 		case c := <-n.status:
-			c <- getStatus(r)
+			c <- n.rn.Status()
 		case <-n.stop:
 			close(n.done)
 			return
@@ -506,27 +559,55 @@ func (n *node) stepWithWaitOption(ctx context.Context, m pb.Message, wait bool)
 	if wait {
 		pm.result = make(chan error, 1)
 	}
+	if n.propQueueLen > 0 {
+		if err := n.enqueueProposal(ctx, pm); err != nil || !wait {
+			return err
+		}
+	} else {
+		select {
+		case ch <- pm:
+			if !wait {
+				return nil
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-n.done:
+			return ErrStopped
+		}
+	}
 	select {
-	case ch <- pm:
-		if !wait {
-			return nil
+	case err := <-pm.result:
+		if err != nil {
+			return err
 		}
 	case <-ctx.Done():
 		return ctx.Err()
 	case <-n.done:
 		return ErrStopped
 	}
+	return nil
+}
+
+// enqueueProposal adds the proposal to the bounded proposal queue, or returns a
+// *ProposalQueueFullError if the queue is full.
+func (n *node) enqueueProposal(ctx context.Context, pm msgWithResult) error {
+	pm.enqueued = time.Now()
 	select {
-	case err := <-pm.result:
-		if err != nil {
-			return err
+	case n.propc <- pm:
+		if n.propMetrics != nil {
+			n.propMetrics.Enqueued(len(n.propc))
 		}
+		return nil
 	case <-ctx.Done():
 		return ctx.Err()
 	case <-n.done:
 		return ErrStopped
+	default:
+		if n.propMetrics != nil {
+			n.propMetrics.Rejected()
+		}
+		return &ProposalQueueFullError{Len: n.propQueueLen}
 	}
-	return nil
 }
 
 func (n *node) Ready() <-chan Ready { return n.readyc }
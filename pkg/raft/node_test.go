@@ -22,6 +22,7 @@ import (
 	"fmt"
 	"math"
 	"strings"
+	"sync"
 	"testing"
 	"time"
 
@@ -165,6 +166,74 @@ func TestNodePropose(t *testing.T) {
 	assert.Equal(t, []byte("somedata"), msgs[0].Entries[0].Data)
 }
 
+type testProposalQueueMetrics struct {
+	mu       sync.Mutex
+	enqueued []int
+	dequeued int
+	rejected int
+}
+
+func (m *testProposalQueueMetrics) Enqueued(queueLen int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.enqueued = append(m.enqueued, queueLen)
+}
+
+func (m *testProposalQueueMetrics) Dequeued(time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dequeued++
+}
+
+func (m *testProposalQueueMetrics) Rejected() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.rejected++
+}
+
+func (m *testProposalQueueMetrics) numDequeued() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.dequeued
+}
+
+// TestNodeProposalQueueFull ensures that proposals are rejected with a
+// ProposalQueueFullError when the bounded proposal queue is full, and that the
+// queued proposals are handled once the node has a leader.
+func TestNodeProposalQueueFull(t *testing.T) {
+	s := newTestMemoryStorage(withPeers(1))
+	rn := newTestRawNode(1, 10, 1, s)
+	n := newNode(rn)
+	var metrics testProposalQueueMetrics
+	n.setProposalQueue(2, &metrics)
+
+	// The node is not running yet, so the proposals stay in the queue.
+	prop := raftpb.Message{Type: raftpb.MsgProp, Entries: []raftpb.Entry{{Data: []byte("foo")}}}
+	require.NoError(t, n.Step(context.Background(), prop))
+	require.NoError(t, n.Step(context.Background(), prop))
+	err := n.Step(context.Background(), prop)
+	var qErr *ProposalQueueFullError
+	require.ErrorAs(t, err, &qErr)
+	require.Equal(t, 2, qErr.Len)
+	require.Equal(t, []int{1, 2}, metrics.enqueued)
+	require.Equal(t, 1, metrics.rejected)
+
+	go n.run()
+	defer n.Stop()
+	require.NoError(t, n.Campaign(context.Background()))
+	deadline := time.After(10 * time.Second)
+	for metrics.numDequeued() < 2 {
+		select {
+		case rd := <-n.Ready():
+			require.NoError(t, s.Append(rd.Entries))
+			n.Advance()
+		case <-time.After(time.Millisecond):
+		case <-deadline:
+			t.Fatal("queued proposals were not handled")
+		}
+	}
+}
+
 // TestDisableProposalForwarding ensures that proposals are not forwarded to
 // the leader when DisableProposalForwarding is true.
 func TestDisableProposalForwarding(t *testing.T) {
@@ -70,6 +70,10 @@ type Config struct {
 	// right away when entering the joint configuration, so that it is caught up
 	// as soon as possible.
 	LearnersNext map[pb.PeerID]struct{}
+	// FailureDomains is an optional commit policy. If non-empty, an index is
+	// committed only once it has been acknowledged by at least one voter in each
+	// failure domain, in addition to the majority quorum. See FailureDomains.
+	FailureDomains FailureDomains
 }
 
 // MakeEmptyConfig constructs and returns an empty Config.
@@ -96,6 +100,9 @@ func (c Config) String() string {
 	if c.AutoLeave {
 		fmt.Fprint(&buf, " autoleave")
 	}
+	if len(c.FailureDomains) != 0 {
+		fmt.Fprintf(&buf, " domains=%s", c.FailureDomains)
+	}
 	return buf.String()
 }
 
@@ -112,9 +119,10 @@ func (c *Config) Clone() Config {
 		return mm
 	}
 	return Config{
-		Voters:       JointConfig{clone(c.Voters[0]), clone(c.Voters[1])},
-		Learners:     clone(c.Learners),
-		LearnersNext: clone(c.LearnersNext),
+		Voters:         JointConfig{clone(c.Voters[0]), clone(c.Voters[1])},
+		Learners:       clone(c.Learners),
+		LearnersNext:   clone(c.LearnersNext),
+		FailureDomains: c.FailureDomains.Clone(),
 	}
 }
 
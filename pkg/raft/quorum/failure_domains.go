@@ -0,0 +1,90 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package quorum
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	pb "github.com/cockroachdb/cockroach/pkg/raft/raftpb"
+)
+
+// FailureDomains maps voters to the failure domains (e.g. regions) in which
+// they reside. When used as a commit policy, an index is only committed once it
+// has been acknowledged by at least one voter in each of the failure domains,
+// in addition to being committed by the majority quorum.
+//
+// Voters which are not mapped to a failure domain do not constrain the commit
+// index beyond their participation in the majority quorum. Failure domains with
+// no voters in the configuration are ignored.
+type FailureDomains map[pb.PeerID]string
+
+// CommittedIndex returns the largest index acknowledged by at least one of the
+// given voters in each failure domain. Returns math.MaxUint64 if none of the
+// voters is mapped to a failure domain.
+func (d FailureDomains) CommittedIndex(voters JointConfig, l AckedIndexer) Index {
+	if len(d) == 0 {
+		return math.MaxUint64
+	}
+	acked := make(map[string]Index, len(d))
+	for _, cfg := range voters {
+		for id := range cfg {
+			domain, ok := d[id]
+			if !ok {
+				continue
+			}
+			// A voter that has not acknowledged anything yet still constitutes a
+			// failure domain, and holds back the commit index.
+			idx, _ := l.AckedIndex(id)
+			if cur, ok := acked[domain]; !ok || idx > cur {
+				acked[domain] = idx
+			}
+		}
+	}
+	committed := Index(math.MaxUint64)
+	for _, idx := range acked {
+		committed = min(committed, idx)
+	}
+	return committed
+}
+
+// Clone returns a copy of the FailureDomains that shares no memory with the
+// original.
+func (d FailureDomains) Clone() FailureDomains {
+	if d == nil {
+		return nil
+	}
+	dd := make(FailureDomains, len(d))
+	for id, domain := range d {
+		dd[id] = domain
+	}
+	return dd
+}
+
+func (d FailureDomains) String() string {
+	ids := make([]pb.PeerID, 0, len(d))
+	for id := range d {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	var buf strings.Builder
+	buf.WriteByte('(')
+	for i, id := range ids {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		fmt.Fprintf(&buf, "%d:%s", id, d[id])
+	}
+	buf.WriteByte(')')
+	return buf.String()
+}
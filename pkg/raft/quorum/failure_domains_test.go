@@ -0,0 +1,63 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package quorum
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFailureDomainsCommittedIndex(t *testing.T) {
+	voters := JointConfig{MajorityConfig{1: {}, 2: {}, 3: {}, 4: {}, 5: {}}}
+	acked := mapAckIndexer{1: 10, 2: 8, 3: 6, 4: 4}
+
+	for _, tt := range []struct {
+		domains FailureDomains
+		voters  JointConfig
+		want    Index
+	}{
+		{domains: nil, voters: voters, want: math.MaxUint64},
+		{domains: FailureDomains{1: "a", 2: "a", 3: "a"}, voters: voters, want: 10},
+		{domains: FailureDomains{1: "a", 2: "a", 3: "b"}, voters: voters, want: 6},
+		{domains: FailureDomains{1: "a", 3: "b", 4: "b"}, voters: voters, want: 6},
+		{domains: FailureDomains{1: "a", 2: "b", 4: "c"}, voters: voters, want: 4},
+		// Voter 5 has not acknowledged anything, and is the only voter in "c".
+		{domains: FailureDomains{1: "a", 2: "b", 5: "c"}, voters: voters, want: 0},
+		// Domains with no voters in the configuration are ignored.
+		{domains: FailureDomains{1: "a", 6: "b"}, voters: voters, want: 10},
+		// Both halves of a joint configuration are taken into account.
+		{
+			domains: FailureDomains{1: "a", 4: "b"},
+			voters:  JointConfig{MajorityConfig{1: {}, 2: {}}, MajorityConfig{3: {}, 4: {}}},
+			want:    4,
+		},
+	} {
+		t.Run(tt.domains.String(), func(t *testing.T) {
+			require.Equal(t, tt.want, tt.domains.CommittedIndex(tt.voters, acked))
+		})
+	}
+}
+
+func TestConfigFailureDomains(t *testing.T) {
+	cfg := MakeEmptyConfig()
+	cfg.Voters[0] = MajorityConfig{1: {}, 2: {}, 3: {}}
+	require.Equal(t, "voters=(1 2 3)", cfg.String())
+
+	cfg.FailureDomains = FailureDomains{1: "a", 2: "b", 3: "b"}
+	require.Equal(t, "voters=(1 2 3) domains=(1:a 2:b 3:b)", cfg.String())
+
+	clone := cfg.Clone()
+	require.Equal(t, cfg.FailureDomains, clone.FailureDomains)
+	clone.FailureDomains[1] = "c"
+	require.Equal(t, "a", cfg.FailureDomains[1])
+}
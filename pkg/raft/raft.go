@@ -77,6 +77,14 @@ const noLimit = math.MaxUint64
 // so that the proposer can be notified and fail fast.
 var ErrProposalDropped = errors.New("raft proposal dropped")
 
+// ErrNotJoint is returned when attempting to leave a joint configuration while
+// the active configuration is not joint.
+var ErrNotJoint = errors.New("raft: configuration is not joint")
+
+// ErrPendingConfChange is returned when attempting to propose a configuration
+// change while a previously proposed one may still be unapplied.
+var ErrPendingConfChange = errors.New("raft: configuration change pending")
+
 // lockedRand is a small wrapper around rand.Rand to provide
 // synchronization among multiple raft groups. Only the methods needed
 // by the code are exposed (e.g. Intn).
@@ -112,6 +120,22 @@ func (st StateType) String() string {
 	return stmap[st]
 }
 
+// ScanBudget controls the memory and bandwidth that raft spends on internal
+// scans of its log, such as scanning the unapplied committed entries for
+// configuration changes before campaigning.
+type ScanBudget interface {
+	// PageSize returns the maximum byte size of the entries read from the log
+	// at a time. A page can exceed it if it consists of a single large entry.
+	PageSize() uint64
+	// MaxBytes returns the total byte size of the entries that a single scan is
+	// allowed to read. The scan can exceed it by up to one page. Zero means no
+	// limit.
+	MaxBytes() uint64
+	// ScanTruncated is called when the scan of the [lo, hi) log interval has
+	// been stopped at index next < hi, because its budget has been exhausted.
+	ScanTruncated(lo, next, hi uint64)
+}
+
 // Config contains the parameters to start a raft.
 type Config struct {
 	// ID is the identity of the local raft. ID cannot be 0.
@@ -210,6 +234,15 @@ type Config struct {
 	// throughput limit of 10 MB/s for this group. With RTT of 400ms, this drops
 	// to 2.5 MB/s. See Little's law to understand the maths behind.
 	MaxInflightBytes uint64
+	// EntrySizeEstimator, if set, returns the size of the given entry as sent
+	// over the wire. It replaces the entry sizes in the MaxSizePerMsg and
+	// MaxInflightBytes accounting of append messages, which otherwise are the
+	// size of the protobuf encoding and the size of the payload respectively.
+	// This allows applications which wrap the entries before sending them, e.g.
+	// with encryption or an envelope, to keep these limits accurate.
+	//
+	// The estimator must be cheap, since it is called for every sent entry.
+	EntrySizeEstimator func(e *pb.Entry) uint64
 
 	// CheckQuorum specifies if the leader should check quorum activity. Leader
 	// steps down when quorum is not active for an electionTimeout.
@@ -263,8 +296,81 @@ type Config struct {
 	// https://github.com/etcd-io/raft/issues/83
 	StepDownOnRemoval bool
 
+	// DisableHeartbeatFallback turns off the heartbeats that a newly elected
+	// leader sends, as part of the probe it broadcasts to all followers, to the
+	// followers to which it can't send a MsgApp. By default, the leader
+	// immediately sends each follower a MsgApp carrying the leader's commit
+	// index, or a heartbeat if the MsgApp flow to the follower is throttled, so
+	// that all the followers learn about the new leader and the commit state
+	// within one round trip. With the fallback disabled, the leader only sends
+	// the MsgApps which the flow control allows, and the other followers learn
+	// about the leader on the first heartbeat. Only intended for tests.
+	DisableHeartbeatFallback bool
+
 	// StoreLiveness is a reference to the store liveness fabric.
 	StoreLiveness raftstoreliveness.StoreLiveness
+
+	// OnDivergentLogTruncation, if set, is invoked when the log accepts an
+	// append from a newer leader which truncates a diverging suffix, before the
+	// new entries are handed out in a Ready. The discarded entries are in the
+	// [lo, hi] interval of log indices. It is not invoked for rejected appends.
+	// This lets the application release side effects tied to these entries,
+	// e.g. proposal quotas or intents.
+	//
+	// The callback is invoked synchronously while stepping a message, and must
+	// not call back into raft.
+	OnDivergentLogTruncation func(lo, hi uint64)
+
+	// ScanBudget limits the internal scans of the raft log. If nil, the scans
+	// are paginated by MaxCommittedSizePerReady, and are not limited in total.
+	ScanBudget ScanBudget
+
+	// ReuseReady makes RawNode reuse the memory backing the Ready structs it
+	// returns across iterations, instead of allocating it anew every time. When
+	// set, a Ready (including its SoftState and Messages) must not be retained
+	// or accessed after the following call to Advance, or to Ready when using
+	// AsyncStorageWrites. See Status.ReadyAllocs for the resulting allocations.
+	ReuseReady bool
+
+	// MaxProposalQueueLen, if positive, bounds the number of proposals queued
+	// for the raft goroutine of a Node (see StartNode). Proposals made while the
+	// queue is full are rejected with a *ProposalQueueFullError, instead of
+	// blocking the caller until the raft goroutine picks them up. This allows
+	// callers to implement backpressure. Not used by RawNode.
+	MaxProposalQueueLen int
+	// ProposalQueueMetrics, if set, is notified of the activity of the bounded
+	// proposal queue. Only used if MaxProposalQueueLen is positive.
+	ProposalQueueMetrics ProposalQueueMetrics
+
+	// MaxElectionBackoffTicks enables election storm dampening if positive. When
+	// the term keeps advancing without a leader being elected, e.g. due to a
+	// flapping network, the election timeout is extended by a backoff which
+	// doubles with every such term, up to MaxElectionBackoffTicks. This limits
+	// the term inflation during election storms.
+	MaxElectionBackoffTicks int
+	// OnElectionStorm, if set, is invoked when this node campaigns with a
+	// non-zero election backoff (see MaxElectionBackoffTicks). It is passed the
+	// number of terms since the last known leader, and the current backoff.
+	//
+	// The callback is invoked synchronously, and must not call back into raft.
+	OnElectionStorm func(termsWithoutLeader uint64, backoffTicks int)
+
+	// SampleReplicationStats makes the leader sample the sizes of the MsgApp
+	// messages it sends, and the latencies of the followers' acknowledgements.
+	// The statistics are exposed via Status.Replication, and help to identify
+	// the follower which is the replication bottleneck. Acknowledgements which
+	// took longer than an election timeout are also logged.
+	SampleReplicationStats bool
+
+	// DecodeFailureDomains, if set, extracts the FailureDomains commit policy
+	// from the Context of an applied ConfChangeV2. If it returns false, the
+	// policy of the current configuration carries over to the new one. See
+	// quorum.FailureDomains for details on the commit policy.
+	DecodeFailureDomains func(ctx []byte) (quorum.FailureDomains, bool)
+	// FailureDomains is the initial FailureDomains commit policy. The policy is
+	// not persisted by raft, so the application must provide the policy it last
+	// configured via DecodeFailureDomains when restarting.
+	FailureDomains quorum.FailureDomains
 }
 
 func (c *Config) validate() error {
@@ -300,6 +406,9 @@ func (c *Config) validate() error {
 	if c.MaxInflightMsgs <= 0 {
 		return errors.New("max inflight messages must be greater than 0")
 	}
+	if c.MaxProposalQueueLen < 0 {
+		return errors.New("max proposal queue length must not be negative")
+	}
 	if c.MaxInflightBytes == 0 {
 		c.MaxInflightBytes = noLimit
 	} else if c.MaxInflightBytes < c.MaxSizePerMsg {
@@ -328,6 +437,9 @@ type raft struct {
 	config          quorum.Config
 	trk             tracker.ProgressTracker
 	electionTracker tracker.ElectionTracker
+	// supportTracker is used by the leader to skip sending messages to the peers
+	// whose stores stopped supporting the leader's store.
+	supportTracker tracker.SupportTracker
 
 	state StateType
 
@@ -383,25 +495,63 @@ type raft struct {
 	// only leader keeps heartbeatElapsed.
 	heartbeatElapsed int
 
+	// stepBatching is true while a batch of messages is being stepped, see
+	// RawNode.StepBatch. While batching, the leader defers the commit index
+	// checks until the end of the batch.
+	stepBatching bool
+	// commitCheckPending is true if a commit index check has been deferred while
+	// stepping a batch of messages.
+	commitCheckPending bool
+
+	// ticks is the number of ticks since this raft instance was created. It is
+	// used to timestamp the FollowerStatus transitions.
+	ticks uint64
+	// followers tracks the FollowerStatus of each peer other than the leader.
+	// Only maintained by the leader. Reset on term changes.
+	followers map[pb.PeerID]*followerTracker
+
 	maxInflight      int
 	maxInflightBytes uint64
 	checkQuorum      bool
 	preVote          bool
+	// entrySizeEstimator is Config.EntrySizeEstimator.
+	entrySizeEstimator func(e *pb.Entry) uint64
 
 	heartbeatTimeout int
 	electionTimeout  int
 	// randomizedElectionTimeout is a random number between
-	// [electiontimeout, 2 * electiontimeout - 1]. It gets reset
-	// when raft changes its state to follower or candidate.
+	// [electiontimeout, 2 * electiontimeout - 1], plus the election backoff. It
+	// gets reset when raft changes its state to follower or candidate.
 	randomizedElectionTimeout int
+	// maxElectionBackoff is Config.MaxElectionBackoffTicks.
+	maxElectionBackoff int
+	// leaderTerm is the last term in which this node knew of a leader. Used to
+	// compute the election backoff.
+	leaderTerm uint64
+	// onElectionStorm is Config.OnElectionStorm.
+	onElectionStorm func(termsWithoutLeader uint64, backoffTicks int)
+
 	disableProposalForwarding bool
 	stepDownOnRemoval         bool
+	// disableHeartbeatFallback is Config.DisableHeartbeatFallback.
+	disableHeartbeatFallback bool
+	// sampleReplicationStats is Config.SampleReplicationStats.
+	sampleReplicationStats bool
+	// replStats samples the replication statistics if sampleReplicationStats is
+	// set. Only maintained by the leader. Reset on term changes.
+	replStats *replicationSampler
+	// frozen is set while the group is frozen, see RawNode.Freeze. Proposals are
+	// rejected with a *GroupFrozenError.
+	frozen *GroupFrozenError
 
 	tick func()
 	step stepFunc
 
 	logger        Logger
 	storeLiveness raftstoreliveness.StoreLiveness
+	scanBudget    ScanBudget
+
+	decodeFailureDomains func(ctx []byte) (quorum.FailureDomains, bool)
 }
 
 func newRaft(c *Config) *raft {
@@ -409,6 +559,7 @@ func newRaft(c *Config) *raft {
 		panic(err.Error())
 	}
 	raftlog := newLogWithSize(c.Storage, c.Logger, entryEncodingSize(c.MaxCommittedSizePerReady))
+	raftlog.onTruncate = c.OnDivergentLogTruncation
 	hs, cs, err := c.Storage.InitialState()
 	if err != nil {
 		panic(err) // TODO(bdarnell)
@@ -426,16 +577,24 @@ func newRaft(c *Config) *raft {
 		logger:                      c.Logger,
 		maxInflight:                 c.MaxInflightMsgs,
 		maxInflightBytes:            c.MaxInflightBytes,
+		entrySizeEstimator:          c.EntrySizeEstimator,
 		checkQuorum:                 c.CheckQuorum,
 		preVote:                     c.PreVote,
 		disableProposalForwarding:   c.DisableProposalForwarding,
 		disableConfChangeValidation: c.DisableConfChangeValidation,
 		stepDownOnRemoval:           c.StepDownOnRemoval,
+		disableHeartbeatFallback:    c.DisableHeartbeatFallback,
+		sampleReplicationStats:      c.SampleReplicationStats,
 		storeLiveness:               c.StoreLiveness,
+		scanBudget:                  c.ScanBudget,
+		decodeFailureDomains:        c.DecodeFailureDomains,
+		maxElectionBackoff:          c.MaxElectionBackoffTicks,
+		onElectionStorm:             c.OnElectionStorm,
 	}
 	lastID := r.raftLog.lastEntryID()
 
 	r.electionTracker = tracker.MakeVoteTracker(&r.config)
+	r.supportTracker = tracker.MakeSupportTracker(&r.config, r.storeLiveness)
 
 	cfg, progressMap, err := confchange.Restore(confchange.Changer{
 		Config:           quorum.MakeEmptyConfig(),
@@ -447,6 +606,7 @@ func newRaft(c *Config) *raft {
 	if err != nil {
 		panic(err)
 	}
+	cfg.FailureDomains = c.FailureDomains.Clone()
 	assertConfStatesEquivalent(r.logger, cs, r.switchToConfig(cfg, progressMap))
 
 	if !IsEmptyHardState(hs) {
@@ -455,6 +615,8 @@ func newRaft(c *Config) *raft {
 	if c.Applied > 0 {
 		raftlog.appliedTo(c.Applied, 0 /* size */)
 	}
+	// Don't penalize the terms preceding a restart by an election backoff.
+	r.leaderTerm = r.Term
 	r.becomeFollower(r.Term, r.lead)
 
 	var nodesStrs []string
@@ -468,6 +630,13 @@ func newRaft(c *Config) *raft {
 	return r
 }
 
+// setLogger replaces the logger used by this raft instance and its log.
+func (r *raft) setLogger(l Logger) {
+	r.logger = l
+	r.raftLog.logger = l
+	r.raftLog.unstable.logger = l
+}
+
 func (r *raft) hasLeader() bool { return r.lead != None }
 
 func (r *raft) softState() SoftState { return SoftState{RaftState: r.state} }
@@ -581,8 +750,14 @@ func (r *raft) send(m pb.Message) {
 //
 // Returns true if a message was sent, or false otherwise. A message is not sent
 // if the follower log and commit index are up-to-date, the flow is paused (for
-// reasons like in-flight limits), or the message could not be constructed.
+// reasons like in-flight limits), the follower's store no longer supports the
+// leader's store, or the message could not be constructed.
 func (r *raft) maybeSendAppend(to pb.PeerID) bool {
+	if r.supportTracker.LostSupport(to) {
+		// The follower's store is likely down, don't bother constructing the
+		// message. The follower is caught up once its store supports us again.
+		return false
+	}
 	pr := r.trk.Progress(to)
 
 	last, commit := r.raftLog.lastIndex(), r.raftLog.committed
@@ -604,6 +779,11 @@ func (r *raft) maybeSendAppend(to pb.PeerID) bool {
 			// Send a snapshot if we failed to get the entries.
 			return r.maybeSendSnapshot(to, pr)
 		}
+		if r.entrySizeEstimator != nil {
+			// The entries are limited by their encoding size above. Limit them by
+			// the estimated wire size too.
+			entries = limitSizeFunc(entries, r.maxMsgSize, r.entrySizeEstimator)
+		}
 	}
 
 	// Send the MsgApp, and update the progress accordingly.
@@ -616,11 +796,26 @@ func (r *raft) maybeSendAppend(to pb.PeerID) bool {
 		Commit:  commit,
 		Match:   pr.Match,
 	})
-	pr.SentEntries(len(entries), uint64(payloadsSize(entries)))
+	size := r.sentEntriesSize(entries)
+	pr.SentEntries(len(entries), size)
 	pr.SentCommit(commit)
+	r.sampleMsgApp(to, entries, size)
 	return true
 }
 
+// sentEntriesSize returns the size of the given entries for the purpose of
+// the MaxInflightBytes accounting.
+func (r *raft) sentEntriesSize(entries []pb.Entry) uint64 {
+	if r.entrySizeEstimator == nil {
+		return uint64(payloadsSize(entries))
+	}
+	var size uint64
+	for i := range entries {
+		size += r.entrySizeEstimator(&entries[i])
+	}
+	return size
+}
+
 // maybeSendSnapshot fetches a snapshot from Storage, and sends it to the given
 // node. Returns true iff the snapshot message has been emitted successfully.
 func (r *raft) maybeSendSnapshot(to pb.PeerID, pr *tracker.Progress) bool {
@@ -680,12 +875,33 @@ func (r *raft) bcastAppend() {
 	})
 }
 
-// bcastHeartbeat sends RPC, without entries to all the peers.
-func (r *raft) bcastHeartbeat() {
+// bcastProbe is called by a newly elected leader. It sends a MsgApp to all the
+// peers, which carries the empty entry appended by becomeLeader and the
+// leader's commit index. This probes the followers' logs, and lets them learn
+// about the new leader and the commit index without waiting for a heartbeat.
+//
+// Unless Config.DisableHeartbeatFallback is set, the peers to which a MsgApp
+// can't be sent, e.g. because the MsgApp flow is throttled or their store
+// stopped supporting ours, are sent a heartbeat instead, so that no follower
+// waits for the first heartbeat tick to learn about the new leader.
+func (r *raft) bcastProbe() {
 	r.trk.Visit(func(id pb.PeerID, _ *tracker.Progress) {
 		if id == r.id {
 			return
 		}
+		if !r.maybeSendAppend(id) && !r.disableHeartbeatFallback {
+			r.sendHeartbeat(id)
+		}
+	})
+}
+
+// bcastHeartbeat sends RPC, without entries to all the peers, except those
+// whose stores no longer support the leader's store.
+func (r *raft) bcastHeartbeat() {
+	r.trk.Visit(func(id pb.PeerID, _ *tracker.Progress) {
+		if id == r.id || r.supportTracker.LostSupport(id) {
+			return
+		}
 		r.sendHeartbeat(id)
 	})
 }
@@ -719,12 +935,49 @@ func (r *raft) appliedTo(index uint64, size entryEncodingSize) {
 	}
 }
 
+// proposeLeaveJoint proposes to transition out of the active joint
+// configuration. Unlike the automatic transition in appliedTo, it does not
+// require the configuration to be marked AutoLeave.
+func (r *raft) proposeLeaveJoint() error {
+	if r.state != StateLeader {
+		r.logger.Debugf("%x not leader at term %d; not proposing to leave joint configuration", r.id, r.Term)
+		return ErrProposalDropped
+	}
+	if len(r.config.Voters[1]) == 0 {
+		return ErrNotJoint
+	}
+	// Per the "Apply" invariant in the config change safety argument, the leader
+	// must not append a config change if it hasn't applied all config changes in
+	// its log. See stepLeader.
+	if r.pendingConfIndex > r.raftLog.applied {
+		return ErrPendingConfChange
+	}
+	m, err := confChangeToMsg(nil)
+	if err != nil {
+		return err
+	}
+	r.logger.Infof("%x proposing transition out of joint configuration %s", r.id, r.config)
+	return r.Step(m)
+}
+
 func (r *raft) appliedSnap(snap *pb.Snapshot) {
 	index := snap.Metadata.Index
 	r.raftLog.stableSnapTo(index)
 	r.appliedTo(index, 0 /* size */)
 }
 
+// finishStepBatch carries out the actions deferred while stepping a batch of
+// messages.
+func (r *raft) finishStepBatch() {
+	if !r.commitCheckPending {
+		return
+	}
+	r.commitCheckPending = false
+	if r.state == StateLeader && r.maybeCommit() {
+		r.bcastAppend()
+	}
+}
+
 // maybeCommit attempts to advance the commit index. Returns true if the commit
 // index changed (in which case the caller should call r.bcastAppend). This can
 // only be called in StateLeader.
@@ -752,6 +1005,8 @@ func (r *raft) reset(term uint64) {
 	if r.Term != term {
 		r.Term = term
 		r.Vote = None
+		// The lead epoch belongs to the leader of the previous term.
+		r.leadEpoch = 0
 	}
 
 	// TODO(arul): we should only reset this if the term has changed.
@@ -770,6 +1025,7 @@ func (r *raft) reset(term uint64) {
 			Next:      r.raftLog.lastIndex() + 1,
 			Inflights: tracker.NewInflights(r.maxInflight, r.maxInflightBytes),
 			IsLearner: pr.IsLearner,
+			Metadata:  pr.Metadata,
 		}
 		if id == r.id {
 			pr.Match = r.raftLog.lastIndex()
@@ -778,6 +1034,11 @@ func (r *raft) reset(term uint64) {
 
 	r.pendingConfIndex = 0
 	r.uncommittedSize = 0
+	r.followers = nil
+	r.replStats = nil
+	if r.sampleReplicationStats {
+		r.replStats = &replicationSampler{}
+	}
 }
 
 func (r *raft) appendEntry(es ...pb.Entry) (accepted bool) {
@@ -822,7 +1083,11 @@ func (r *raft) appendEntry(es ...pb.Entry) (accepted bool) {
 
 // tickElection is run by followers and candidates after r.electionTimeout.
 func (r *raft) tickElection() {
+	r.ticks++
 	r.electionElapsed++
+	if r.lead != None {
+		r.leaderTerm = r.Term
+	}
 
 	if r.promotable() && r.pastElectionTimeout() {
 		r.electionElapsed = 0
@@ -834,6 +1099,7 @@ func (r *raft) tickElection() {
 
 // tickHeartbeat is run by leaders to send a MsgBeat after r.heartbeatTimeout.
 func (r *raft) tickHeartbeat() {
+	r.ticks++
 	r.heartbeatElapsed++
 	r.electionElapsed++
 
@@ -853,6 +1119,8 @@ func (r *raft) tickHeartbeat() {
 	if r.state != StateLeader {
 		return
 	}
+	r.updateFollowers()
+	r.supportTracker.Refresh()
 
 	if r.heartbeatElapsed >= r.heartbeatTimeout {
 		r.heartbeatElapsed = 0
@@ -863,12 +1131,13 @@ func (r *raft) tickHeartbeat() {
 }
 
 // TODO(arul): Consider removing the lead argument from this function. Instead,
-// for all the methods that want to set the leader explicitly (the ones that are
-// passing in m.From for this field), we can instead have them use an assignLead
-// function instead; in there, we can add safety checks to ensure we're not
-// overwriting the leader.
+// the methods that want to set the leader explicitly (the ones that are passing
+// in m.From for this field) can use assignLead.
 func (r *raft) becomeFollower(term uint64, lead pb.PeerID) {
 	r.step = stepFollower
+	if lead != None {
+		r.leaderTerm = term
+	}
 	r.reset(term)
 	r.tick = r.tickElection
 	r.lead = lead
@@ -876,6 +1145,29 @@ func (r *raft) becomeFollower(term uint64, lead pb.PeerID) {
 	r.logger.Infof("%x became follower at term %d", r.id, r.Term)
 }
 
+// assignLead records that lead is the leader of the current term, upon
+// receiving a message from it. There is at most one leader per term, so once
+// the leader is known, it must not change for the rest of the term; a message
+// from a different leader indicates a bug, and triggers a panic.
+//
+// NB: the leader can be forgotten within a term (see MsgForgetLeader and
+// becomePreCandidate), after which it can be assigned again. It must be the
+// same leader though, which is not checked here.
+func (r *raft) assignLead(lead pb.PeerID) {
+	if lead == None {
+		r.logger.Panicf("%x assigning no leader at term %d", r.id, r.Term)
+	}
+	if r.lead == lead {
+		return
+	}
+	if r.lead != None {
+		r.logger.Panicf("%x assigning leader %x at term %d, but the leader is already %x",
+			r.id, lead, r.Term, r.lead)
+	}
+	r.lead = lead
+	r.leaderTerm = r.Term
+}
+
 func (r *raft) becomeCandidate() {
 	// TODO(xiangli) remove the panic when the raft implementation is stable
 	if r.state == StateLeader {
@@ -915,6 +1207,7 @@ func (r *raft) becomeLeader() {
 		panic("invalid transition [follower -> leader]")
 	}
 	r.step = stepLeader
+	r.leaderTerm = r.Term
 	r.reset(r.Term)
 	r.tick = r.tickHeartbeat
 	r.lead = r.id
@@ -928,6 +1221,9 @@ func (r *raft) becomeLeader() {
 	// The leader always has RecentActive == true; MsgCheckQuorum makes sure to
 	// preserve this.
 	pr.RecentActive = true
+	r.updateFollowers()
+	r.supportTracker.Reset()
+	r.supportTracker.Refresh()
 
 	// Conservatively set the pendingConfIndex to the last index in the
 	// log. There may or may not be a pending config change, but it's
@@ -962,14 +1258,68 @@ func (r *raft) hup(t CampaignType) {
 		r.logger.Warningf("%x cannot campaign at term %d since there are still pending configuration changes to apply", r.id, r.Term)
 		return
 	}
+	// A leadership transfer is initiated by the leader, so it is allowed even if
+	// we are still supporting the leader.
+	if t != campaignTransfer && r.supportingFortifiedLeader() {
+		r.logger.Debugf("%x cannot campaign at term %d since it is supporting the fortified leader %x at epoch %d",
+			r.id, r.Term, r.lead, r.leadEpoch)
+		return
+	}
 
 	r.logger.Infof("%x is starting a new election at term %d", r.id, r.Term)
 	r.campaign(t)
 }
 
+// supportingFortifiedLeader returns true if this peer's store still supports
+// the store of the leader which fortified it, at the epoch it was fortified at.
+// The leader may rely on this support to serve requests under a lease, so the
+// peer must neither campaign nor grant pre-votes until the support expires.
+func (r *raft) supportingFortifiedLeader() bool {
+	if r.lead == None || r.lead == r.id || r.leadEpoch == 0 || r.storeLiveness == nil {
+		return false
+	}
+	epoch, ok := r.storeLiveness.SupportFor(uint64(r.lead))
+	return ok && epoch == r.leadEpoch
+}
+
 // errBreak is a sentinel error used to break a callback-based loop.
 var errBreak = errors.New("break")
 
+// errScanBudgetExhausted is returned by scanBudgeted when the scan has been
+// stopped early due to the ScanBudget.
+var errScanBudgetExhausted = errors.New("scan budget exhausted")
+
+// scanBudgeted is like raftLog.scan, but paginates and limits the scan in
+// accordance with the configured ScanBudget. Returns errScanBudgetExhausted if
+// the scan has been stopped before visiting all entries in [lo, hi).
+func (r *raft) scanBudgeted(lo, hi uint64, v func([]pb.Entry) error) error {
+	// Reuse the maxApplyingEntsSize limit by default because it is used for
+	// similar purposes (limiting the read of unapplied committed entries) when
+	// raft sends entries via the Ready struct for application.
+	pageSize, maxBytes := r.raftLog.maxApplyingEntsSize, entryEncodingSize(noLimit)
+	if b := r.scanBudget; b != nil {
+		pageSize = entryEncodingSize(b.PageSize())
+		if m := b.MaxBytes(); m != 0 {
+			maxBytes = entryEncodingSize(m)
+		}
+	}
+	next, read := lo, entryEncodingSize(0)
+	err := r.raftLog.scan(lo, hi, pageSize, func(ents []pb.Entry) error {
+		if err := v(ents); err != nil {
+			return err
+		}
+		next += uint64(len(ents))
+		if read += entsSize(ents); read >= maxBytes && next < hi {
+			return errScanBudgetExhausted
+		}
+		return nil
+	})
+	if err == errScanBudgetExhausted && r.scanBudget != nil {
+		r.scanBudget.ScanTruncated(lo, next, hi)
+	}
+	return err
+}
+
 func (r *raft) hasUnappliedConfChanges() bool {
 	if r.raftLog.applied >= r.raftLog.committed { // in fact applied == committed
 		return false
@@ -978,13 +1328,7 @@ func (r *raft) hasUnappliedConfChanges() bool {
 	// Scan all unapplied committed entries to find a config change. Paginate the
 	// scan, to avoid a potentially unlimited memory spike.
 	lo, hi := r.raftLog.applied+1, r.raftLog.committed+1
-	// Reuse the maxApplyingEntsSize limit because it is used for similar purposes
-	// (limiting the read of unapplied committed entries) when raft sends entries
-	// via the Ready struct for application.
-	// TODO(pavelkalinnikov): find a way to budget memory/bandwidth for this scan
-	// outside the raft package.
-	pageSize := r.raftLog.maxApplyingEntsSize
-	if err := r.raftLog.scan(lo, hi, pageSize, func(ents []pb.Entry) error {
+	if err := r.scanBudgeted(lo, hi, func(ents []pb.Entry) error {
 		for i := range ents {
 			if ents[i].Type == pb.EntryConfChange || ents[i].Type == pb.EntryConfChangeV2 {
 				found = true
@@ -992,7 +1336,12 @@ func (r *raft) hasUnappliedConfChanges() bool {
 			}
 		}
 		return nil
-	}); err != nil && err != errBreak {
+	}); err == errScanBudgetExhausted {
+		// We don't know whether the rest of the entries contain a config change,
+		// so conservatively assume they do.
+		r.logger.Warningf("%x stopped scanning unapplied entries [%d, %d): %v", r.id, lo, hi, err)
+		return true
+	} else if err != nil && err != errBreak {
 		r.logger.Panicf("error scanning unapplied entries [%d, %d): %v", lo, hi, err)
 	}
 	return found
@@ -1018,6 +1367,13 @@ func (r *raft) campaign(t CampaignType) {
 		voteMsg = pb.MsgVote
 		term = r.Term
 	}
+	if backoff := r.electionBackoff(); backoff > 0 {
+		r.logger.Warningf("%x campaigning at term %d after %d terms without a leader; backing off elections by %d ticks",
+			r.id, r.Term, r.termsWithoutLeader(), backoff)
+		if r.onElectionStorm != nil {
+			r.onElectionStorm(r.termsWithoutLeader(), backoff)
+		}
+	}
 	var ids []pb.PeerID
 	{
 		idMap := r.config.Voters.IDs()
@@ -1063,6 +1419,10 @@ func (r *raft) poll(
 }
 
 func (r *raft) Step(m pb.Message) error {
+	if r.frozen != nil && m.Type == pb.MsgProp {
+		r.logger.Debugf("%x dropping proposal since the group is frozen at index %d", r.id, r.frozen.Index)
+		return r.frozen
+	}
 	// Handle the message term, which may result in our stepping down to a follower.
 	switch {
 	case m.Term == 0:
@@ -1080,6 +1440,15 @@ func (r *raft) Step(m pb.Message) error {
 					r.id, last.term, last.index, r.Vote, m.Type, m.From, m.LogTerm, m.Index, r.Term, r.electionTimeout-r.electionElapsed)
 				return nil
 			}
+			if !force && m.Type == pb.MsgPreVote && r.supportingFortifiedLeader() {
+				// We still support the fortified leader, so the pre-candidate can't win
+				// the election without disrupting it. Reject at our term, so that the
+				// pre-candidate counts the rejection without bumping its term.
+				r.logger.Infof("%x [term: %d] rejected %s from %x [term: %d]: supporting fortified leader %x at epoch %d",
+					r.id, r.Term, m.Type, m.From, m.Term, r.lead, r.leadEpoch)
+				r.send(pb.Message{To: m.From, Term: r.Term, Type: pb.MsgPreVoteResp, Reject: true})
+				return nil
+			}
 		}
 		switch {
 		case m.Type == pb.MsgPreVote:
@@ -1247,9 +1616,13 @@ func stepLeader(r *raft, m pb.Message) error {
 			r.becomeFollower(r.Term, r.id)
 		}
 		// Mark everyone (but ourselves) as inactive in preparation for the next
-		// CheckQuorum.
+		// CheckQuorum. The followers that have not been active since the previous
+		// CheckQuorum are considered unreachable.
 		r.trk.Visit(func(id pb.PeerID, pr *tracker.Progress) {
 			if id != r.id {
+				if !pr.RecentActive && r.state == StateLeader {
+					r.follower(id).unreachable = true
+				}
 				pr.RecentActive = false
 			}
 		})
@@ -1340,6 +1713,9 @@ func stepLeader(r *raft, m pb.Message) error {
 		// an MsgAppResp to acknowledge the appended entries in the last Ready.
 
 		pr.RecentActive = true
+		if m.From != r.id {
+			r.follower(m.From).unreachable = false
+		}
 
 		if m.Reject {
 			// RejectHint is the suggested next base entry for appending (i.e.
@@ -1478,6 +1854,7 @@ func stepLeader(r *raft, m pb.Message) error {
 			// equals pr.Match we know we don't m.Index+1 in our log, so moving
 			// back to replicating state is not useful; besides pr.PendingSnapshot
 			// would prevent it.
+			r.sampleAck(m.From, m.Index)
 			if pr.MaybeUpdate(m.Index) || (pr.Match == m.Index && pr.State == tracker.StateProbe) {
 				switch {
 				case pr.State == tracker.StateProbe:
@@ -1501,7 +1878,9 @@ func stepLeader(r *raft, m pb.Message) error {
 					pr.Inflights.FreeLE(m.Index)
 				}
 
-				if r.maybeCommit() {
+				if r.stepBatching {
+					r.commitCheckPending = true
+				} else if r.maybeCommit() {
 					r.bcastAppend()
 				}
 				// We've updated flow control information above, which may allow us to
@@ -1521,6 +1900,7 @@ func stepLeader(r *raft, m pb.Message) error {
 		}
 	case pb.MsgHeartbeatResp:
 		pr.RecentActive = true
+		r.follower(m.From).unreachable = false
 		pr.MsgAppProbesPaused = false
 		r.maybeSendAppend(m.From)
 
@@ -1548,6 +1928,7 @@ func stepLeader(r *raft, m pb.Message) error {
 		if pr.State == tracker.StateReplicate {
 			pr.BecomeProbe()
 		}
+		r.follower(m.From).unreachable = true
 		r.logger.Debugf("%x failed to send message to %x because it is unreachable [%s]", r.id, m.From, pr)
 	case pb.MsgTransferLeader:
 		if pr.IsLearner {
@@ -1619,7 +2000,7 @@ func stepCandidate(r *raft, m pb.Message) error {
 				r.campaign(campaignElection)
 			} else {
 				r.becomeLeader()
-				r.bcastAppend()
+				r.bcastProbe()
 			}
 		case quorum.VoteLost:
 			// pb.MsgPreVoteResp contains future term of pre-candidate
@@ -1649,20 +2030,15 @@ func stepFollower(r *raft, m pb.Message) error {
 		r.send(m)
 	case pb.MsgApp:
 		r.electionElapsed = 0
-		// TODO(arul): Once r.lead != None, we shouldn't need to update r.lead
-		// anymore within the course of a single term (in the context of which this
-		// function is always called). Instead, if r.lead != None, we should be able
-		// to assert that the leader hasn't changed within a given term. Maybe at
-		// the caller itself.
-		r.lead = m.From
+		r.assignLead(m.From)
 		r.handleAppendEntries(m)
 	case pb.MsgHeartbeat:
 		r.electionElapsed = 0
-		r.lead = m.From
+		r.assignLead(m.From)
 		r.handleHeartbeat(m)
 	case pb.MsgSnap:
 		r.electionElapsed = 0
-		r.lead = m.From
+		r.assignLead(m.From)
 		r.handleSnapshot(m)
 	case pb.MsgTransferLeader:
 		if r.lead == None {
@@ -1700,7 +2076,7 @@ func logSliceFromMsgApp(m *pb.Message) logSlice {
 }
 
 func (r *raft) handleAppendEntries(m pb.Message) {
-	r.checkMatch(m.Match)
+	r.checkMatch(m)
 
 	// TODO(pav-kv): construct logSlice up the stack next to receiving the
 	// message, and validate it before taking any action (e.g. bumping term).
@@ -1712,20 +2088,25 @@ func (r *raft) handleAppendEntries(m pb.Message) {
 		return
 	}
 
+	// The MsgApp can be stale, e.g. if it was reordered with a later MsgApp from
+	// the same leader, or it can fail to append. Regardless, if accTerm >= m.Term
+	// then our log contains all committed entries at m.Term (by raft invariants),
+	// so it is safe to bump the commit index. See raftLog.maybeCommit.
+	commit := logMark{term: m.Term, index: m.Commit}
 	if a.prev.index < r.raftLog.committed {
+		r.raftLog.maybeCommit(commit)
 		r.send(pb.Message{To: m.From, Type: pb.MsgAppResp, Index: r.raftLog.committed})
 		return
 	}
 	if r.raftLog.maybeAppend(a) {
-		// TODO(pav-kv): make it possible to commit even if the append did not
-		// succeed or is stale. If accTerm >= m.Term, then our log contains all
-		// committed entries at m.Term (by raft invariants), so it is safe to bump
-		// the commit index even if the MsgApp is stale.
 		lastIndex := a.lastIndex()
 		r.raftLog.commitTo(logMark{term: m.Term, index: min(m.Commit, lastIndex)})
+		// Our log can extend past the appended slice, if the MsgApp is stale.
+		r.raftLog.maybeCommit(commit)
 		r.send(pb.Message{To: m.From, Type: pb.MsgAppResp, Index: lastIndex})
 		return
 	}
+	r.raftLog.maybeCommit(commit)
 	r.logger.Debugf("%x [logterm: %d, index: %d] rejected MsgApp [logterm: %d, index: %d] from %x",
 		r.id, r.raftLog.zeroTermOnOutOfBounds(r.raftLog.term(m.Index)), m.Index, m.LogTerm, m.Index, m.From)
 
@@ -1757,33 +2138,42 @@ func (r *raft) handleAppendEntries(m pb.Message) {
 	})
 }
 
-// checkMatch ensures that the follower's log size does not contradict to the
-// leader's idea where it matches.
-func (r *raft) checkMatch(match uint64) {
-	// TODO(pav-kv): lastIndex() might be not yet durable. Make this check
-	// stronger by comparing `match` with the last durable index.
-	//
-	// TODO(pav-kv): make this check stronger when the raftLog stores the last
-	// accepted term. If `match` is non-zero, this follower's log last accepted
-	// term must equal the leader term, and have entries up to `match` durable.
-	if last := r.raftLog.lastIndex(); last < match {
-		r.logger.Panicf("match(%d) is out of range [lastIndex(%d)]. Was the raft log corrupted, truncated, or lost?", match, last)
+// checkMatch ensures that the follower's log does not contradict to the m.Term
+// leader's idea where it matches, i.e. the m.Match index.
+//
+// The leader learns the match index from our acks, which we only send once the
+// acked entries are durable. So, if the leader claims a match index beyond our
+// written log, or our log at this index contains an entry from a term after the
+// leader's, then the log has been corrupted, truncated, or lost.
+func (r *raft) checkMatch(m pb.Message) {
+	if m.Match == 0 {
+		return
+	}
+	if written := r.raftLog.lastWrittenIndex(); written < m.Match {
+		r.logger.Panicf("match(%d) is out of range [lastWrittenIndex(%d)]. Was the raft log corrupted, truncated, or lost?",
+			m.Match, written)
+	}
+	// Our log up to m.Match is a prefix of the leader's log, so it can't contain
+	// entries from terms after the leader's.
+	if term, err := r.raftLog.term(m.Match); err == nil && term > m.Term {
+		r.logger.Panicf("entry %d at term %d is above the leader term %d. Was the raft log corrupted?",
+			m.Match, term, m.Term)
 	}
 }
 
 func (r *raft) handleHeartbeat(m pb.Message) {
-	r.checkMatch(m.Match)
+	r.checkMatch(m)
 
 	// The m.Term leader is indicating to us through this heartbeat message
 	// that indices <= m.Commit in its log are committed. If our log matches
 	// the leader's up to index M, then we can update our commit index to
 	// min(m.Commit, M).
 	//
-	// If accTerm == m.Term, i.e. the last accepted log append came from this
-	// leader, then we know that our log is a prefix of the leader's log. We can
-	// thus put M = r.raftLog.lastIndex() in the formula above.
+	// If accTerm >= m.Term, then our log is a prefix of the accTerm leader's
+	// log, which contains all the entries committed at m.Term. We can thus put
+	// M = r.raftLog.lastIndex() in the formula above.
 	//
-	// Otherwise (accTerm != m.Term), we haven't accepted a single log append from
+	// Otherwise (accTerm < m.Term), we haven't accepted a single log append from
 	// the m.Term leader, so we don't know M, and it is unsafe to update the
 	// commit index.
 	//
@@ -1791,15 +2181,7 @@ func (r *raft) handleHeartbeat(m pb.Message) {
 	// stable, we will eventually accept a MsgApp which sets accTerm == m.Term and
 	// enables advancing the commit index. By this, we have the guarantee that our
 	// commit index converges to the leader's.
-	//
-	// TODO(pav-kv): the condition can be relaxed, it is actually safe to bump the
-	// commit index if accTerm >= m.Term.
-	// TODO(pav-kv): move this logic to raftLog.commitTo, once the accTerm has
-	// migrated to raftLog/unstable.
-	mark := logMark{term: m.Term, index: min(m.Commit, r.raftLog.lastIndex())}
-	if mark.term == r.raftLog.accTerm() {
-		r.raftLog.commitTo(mark)
-	}
+	r.raftLog.maybeCommit(logMark{term: m.Term, index: m.Commit})
 	r.send(pb.Message{To: m.From, Type: pb.MsgHeartbeatResp})
 }
 
@@ -1890,6 +2272,13 @@ func (r *raft) restore(s snapshot) bool {
 		return false
 	}
 
+	// Don't switch to a snapshot whose data was truncated or corrupted. The
+	// leader will retry sending it.
+	if err := s.snap.VerifyIntegrity(); err != nil {
+		r.logger.Errorf("%x rejected snapshot [index: %d, term: %d]: %v", r.id, id.index, id.term, err)
+		return false
+	}
+
 	if !r.raftLog.restore(s) {
 		r.logger.Errorf("%x unable to restore snapshot [index: %d, term: %d]", r.id, id.index, id.term)
 		return false
@@ -1910,6 +2299,14 @@ func (r *raft) restore(s snapshot) bool {
 		panic(fmt.Sprintf("unable to restore config %+v: %s", cs, err))
 	}
 
+	// Carry over the commit policy, and the application metadata of the peers
+	// that remain in the configuration.
+	cfg.FailureDomains = r.config.FailureDomains
+	for id, pr := range progressMap {
+		if old := r.trk.Progress(id); old != nil {
+			pr.Metadata = old.Metadata
+		}
+	}
 	assertConfStatesEquivalent(r.logger, cs, r.switchToConfig(cfg, progressMap))
 
 	last := r.raftLog.lastEntryID()
@@ -1946,6 +2343,11 @@ func (r *raft) applyConfChange(cc pb.ConfChangeV2) pb.ConfState {
 		// TODO(tbg): return the error to the caller.
 		panic(err)
 	}
+	if r.decodeFailureDomains != nil {
+		if domains, ok := r.decodeFailureDomains(cc.Context); ok {
+			cfg.FailureDomains = domains
+		}
+	}
 
 	return r.switchToConfig(cfg, progressMap)
 }
@@ -2026,7 +2428,30 @@ func (r *raft) pastElectionTimeout() bool {
 }
 
 func (r *raft) resetRandomizedElectionTimeout() {
-	r.randomizedElectionTimeout = r.electionTimeout + globalRand.Intn(r.electionTimeout)
+	r.randomizedElectionTimeout = r.electionTimeout + globalRand.Intn(r.electionTimeout) + r.electionBackoff()
+}
+
+// termsWithoutLeader returns the number of terms that have passed since this
+// node last knew of a leader.
+func (r *raft) termsWithoutLeader() uint64 {
+	if r.Term <= r.leaderTerm {
+		return 0
+	}
+	return r.Term - r.leaderTerm
+}
+
+// electionBackoff returns the number of ticks by which the election timeout is
+// extended to dampen an election storm. The first term without a leader is not
+// penalized, and the backoff doubles with every subsequent one.
+func (r *raft) electionBackoff() int {
+	n := r.termsWithoutLeader()
+	if r.maxElectionBackoff <= 0 || n < 2 {
+		return 0
+	}
+	if n-2 >= 30 { // avoid overflows
+		return r.maxElectionBackoff
+	}
+	return min(r.electionTimeout<<(n-2), r.maxElectionBackoff)
 }
 
 func (r *raft) sendTimeoutNow(to pb.PeerID) {
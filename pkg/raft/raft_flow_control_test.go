@@ -59,6 +59,45 @@ func TestMsgAppFlowControlFull(t *testing.T) {
 	}
 }
 
+// TestMsgAppFlowControlSizeEstimator ensures that the MsgApp size and the
+// in-flight bytes are limited using Config.EntrySizeEstimator, if it is set.
+func TestMsgAppFlowControlSizeEstimator(t *testing.T) {
+	cfg := newTestConfig(1, 5, 1, newTestMemoryStorage(withPeers(1, 2)))
+	cfg.MaxSizePerMsg = 100
+	cfg.MaxInflightBytes = 150
+	cfg.EntrySizeEstimator = func(*pb.Entry) uint64 { return 60 }
+	r := newRaft(cfg)
+	r.becomeCandidate()
+	r.becomeLeader()
+	pr2 := r.trk.Progress(2)
+	pr2.BecomeReplicate()
+
+	ents := []pb.Entry{{Data: []byte("a")}, {Data: []byte("b")}, {Data: []byte("c")}}
+	if err := r.Step(pb.Message{From: 1, To: 1, Type: pb.MsgProp, Entries: ents}); err != nil {
+		t.Fatal(err)
+	}
+	for r.maybeSendAppend(2) {
+	}
+	var sent int
+	for _, m := range r.readMessages() {
+		if m.Type != pb.MsgApp || m.To != 2 {
+			continue
+		}
+		sent++
+		// Two entries exceed the MaxSizePerMsg according to the estimator.
+		if len(m.Entries) != 1 {
+			t.Fatalf("len(entries) = %d, want 1", len(m.Entries))
+		}
+	}
+	// The third MsgApp fills the in-flight bytes budget.
+	if sent != 3 {
+		t.Fatalf("sent %d MsgApp, want 3", sent)
+	}
+	if !pr2.IsPaused() {
+		t.Fatal("paused = false, want true")
+	}
+}
+
 // TestMsgAppFlowControlMoveForward ensures msgAppResp can move
 // forward the sending window correctly:
 // 1. valid msgAppResp.index moves the windows to pass all smaller or equal index.
@@ -66,7 +66,7 @@ func testUpdateTermFromMessage(t *testing.T, state StateType) {
 		r.becomeLeader()
 	}
 
-	r.Step(pb.Message{Type: pb.MsgApp, Term: 2})
+	r.Step(pb.Message{From: 2, Type: pb.MsgApp, Term: 2})
 
 	assert.Equal(t, uint64(2), r.Term)
 	assert.Equal(t, StateFollower, r.state)
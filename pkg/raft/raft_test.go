@@ -24,8 +24,11 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/cockroachdb/cockroach/pkg/raft/quorum"
 	pb "github.com/cockroachdb/cockroach/pkg/raft/raftpb"
+	"github.com/cockroachdb/cockroach/pkg/raft/raftstoreliveness"
 	"github.com/cockroachdb/cockroach/pkg/raft/tracker"
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -66,7 +69,7 @@ func (r *raft) readMessages() []pb.Message {
 
 func (r *raft) advanceMessagesAfterAppend() {
 	for {
-		msgs := r.takeMessagesAfterAppend()
+		msgs := r.takeMessagesAfterWrite()
 		if len(msgs) == 0 {
 			break
 		}
@@ -80,6 +83,33 @@ func (r *raft) takeMessagesAfterAppend() []pb.Message {
 	return msgs
 }
 
+// takeMessagesAfterWrite is like takeMessagesAfterAppend, but writes the
+// unstable entries to storage first if any of the messages is sent to another
+// peer. See writeBeforeResponding.
+func (r *raft) takeMessagesAfterWrite() []pb.Message {
+	msgs := r.takeMessagesAfterAppend()
+	r.writeBeforeResponding(msgs)
+	return msgs
+}
+
+// writeBeforeResponding writes the unstable entries to storage, if any of the
+// given messages is sent to another peer. Like RawNode, which hands out these
+// messages in a Ready only once its entries are being written, this makes sure
+// that a leader can't learn about entries which are not in our written log.
+// See checkMatch.
+func (r *raft) writeBeforeResponding(msgs []pb.Message) {
+	for _, m := range msgs {
+		if m.To == r.id {
+			continue
+		}
+		if s, ok := r.raftLog.storage.(*MemoryStorage); ok {
+			_ = s.Append(r.raftLog.nextUnstableEnts())
+		}
+		r.raftLog.acceptUnstable()
+		return
+	}
+}
+
 func (r *raft) stepOrSend(msgs []pb.Message) error {
 	for _, m := range msgs {
 		if m.To == r.id {
@@ -653,6 +683,49 @@ func TestLearnerLogReplication(t *testing.T) {
 	assert.Equal(t, n2.raftLog.committed, match)
 }
 
+// TestCommitFailureDomains tests that the leader does not commit an entry
+// until it is acknowledged in all failure domains, and that the failure domains
+// can be reconfigured via the ConfChangeV2 context.
+func TestCommitFailureDomains(t *testing.T) {
+	cfg := newTestConfig(1, 10, 1, newTestMemoryStorage(withPeers(1, 2, 3)))
+	cfg.FailureDomains = quorum.FailureDomains{1: "a", 2: "a", 3: "b"}
+	cfg.DecodeFailureDomains = func(ctx []byte) (quorum.FailureDomains, bool) {
+		if len(ctx) == 0 {
+			return nil, false
+		}
+		domains := quorum.FailureDomains{}
+		for i, domain := range strings.Split(string(ctx), ",") {
+			domains[pb.PeerID(i+1)] = domain
+		}
+		return domains, true
+	}
+	r := newRaft(cfg)
+	r.becomeCandidate()
+	r.becomeLeader()
+	r.readMessages()
+	require.Equal(t, uint64(0), r.raftLog.committed)
+
+	// The majority {1, 2} acks the entry, but it can not be committed until it
+	// is acknowledged in failure domain "b".
+	require.NoError(t, r.Step(pb.Message{From: 2, To: 1, Type: pb.MsgAppResp, Index: 1}))
+	require.Equal(t, uint64(0), r.raftLog.committed)
+	require.NoError(t, r.Step(pb.Message{From: 3, To: 1, Type: pb.MsgAppResp, Index: 1}))
+	require.Equal(t, uint64(1), r.raftLog.committed)
+
+	// A config change without a context retains the failure domains.
+	noop := []pb.ConfChangeSingle{{Type: pb.ConfChangeAddNode, NodeID: 3}}
+	r.applyConfChange(pb.ConfChangeV2{Changes: noop})
+	require.Equal(t, quorum.FailureDomains{1: "a", 2: "a", 3: "b"}, r.config.FailureDomains)
+	// Put all the voters into the same failure domain.
+	r.applyConfChange(pb.ConfChangeV2{Changes: noop, Context: []byte("a,a,a")})
+	require.Equal(t, quorum.FailureDomains{1: "a", 2: "a", 3: "a"}, r.config.FailureDomains)
+
+	mustAppendEntry(r, pb.Entry{Data: []byte("foo")})
+	r.readMessages()
+	require.NoError(t, r.Step(pb.Message{From: 2, To: 1, Type: pb.MsgAppResp, Index: 2}))
+	require.Equal(t, uint64(2), r.raftLog.committed)
+}
+
 func TestSingleNodeCommit(t *testing.T) {
 	s := newTestMemoryStorage(withPeers(1))
 	cfg := newTestConfig(1, 10, 1, s)
@@ -1039,6 +1112,43 @@ func TestCommit(t *testing.T) {
 	}
 }
 
+// TestElectionStormBackoff tests that the election timeout is backed off when
+// the term keeps advancing without a leader, and that the backoff is reset once
+// a leader is known.
+func TestElectionStormBackoff(t *testing.T) {
+	cfg := newTestConfig(1, 10, 1, newTestMemoryStorage(withPeers(1, 2, 3)))
+	cfg.MaxElectionBackoffTicks = 35
+	var storms [][2]uint64
+	cfg.OnElectionStorm = func(termsWithoutLeader uint64, backoffTicks int) {
+		storms = append(storms, [2]uint64{termsWithoutLeader, uint64(backoffTicks)})
+	}
+	r := newRaft(cfg)
+
+	for _, tt := range []struct {
+		term    uint64
+		backoff int
+	}{
+		{term: 1, backoff: 0},
+		{term: 2, backoff: 10},
+		{term: 3, backoff: 20},
+		{term: 4, backoff: 35},
+		{term: 5, backoff: 35},
+	} {
+		require.NoError(t, r.Step(pb.Message{From: 1, To: 1, Type: pb.MsgHup}))
+		require.Equal(t, StateCandidate, r.state)
+		require.Equal(t, tt.term, r.Term)
+		require.Equal(t, tt.backoff, r.electionBackoff())
+		require.GreaterOrEqual(t, r.randomizedElectionTimeout, r.electionTimeout+tt.backoff)
+		require.Less(t, r.randomizedElectionTimeout, 2*r.electionTimeout+tt.backoff)
+	}
+	require.Equal(t, [][2]uint64{{2, 10}, {3, 20}, {4, 35}, {5, 35}}, storms)
+
+	// Once a leader is known, the backoff is reset.
+	r.becomeFollower(6, 2)
+	require.Zero(t, r.electionBackoff())
+	require.Less(t, r.randomizedElectionTimeout, 2*r.electionTimeout)
+}
+
 func TestPastElectionTimeout(t *testing.T) {
 	tests := []struct {
 		elapse       int
@@ -1110,7 +1220,7 @@ func TestHandleMsgApp(t *testing.T) {
 		{pb.Message{Type: pb.MsgApp, Term: 2, LogTerm: 1, Index: 1, Commit: 4, Entries: []pb.Entry{{Index: 2, Term: 2}}}, 2, 2, false},
 
 		// Ensure 3
-		{pb.Message{Type: pb.MsgApp, Term: 1, LogTerm: 1, Index: 1, Commit: 3}, 2, 1, false},                                           // match entry 1, commit up to last new entry 1
+		{pb.Message{Type: pb.MsgApp, Term: 1, LogTerm: 1, Index: 1, Commit: 3}, 2, 2, false},                                           // match entry 1, accTerm >= 1 allows commit up to log.last()
 		{pb.Message{Type: pb.MsgApp, Term: 2, LogTerm: 1, Index: 1, Commit: 3, Entries: []pb.Entry{{Index: 2, Term: 2}}}, 2, 2, false}, // match entry 1, commit up to last new entry 2
 		{pb.Message{Type: pb.MsgApp, Term: 2, LogTerm: 2, Index: 2, Commit: 3}, 2, 2, false},                                           // match entry 2, commit up to last new entry 2
 		{pb.Message{Type: pb.MsgApp, Term: 2, LogTerm: 2, Index: 2, Commit: 4}, 2, 2, false},                                           // commit up to log.last()
@@ -1131,6 +1241,44 @@ func TestHandleMsgApp(t *testing.T) {
 	}
 }
 
+// TestHandleMsgAppStaleCommit ensures that the follower advances its commit
+// index on stale MsgApp messages, if its log is guaranteed to be a prefix of
+// the leader's log.
+func TestHandleMsgAppStaleCommit(t *testing.T) {
+	for _, tt := range []struct {
+		m       pb.Message
+		accTerm uint64
+		commit  uint64
+		wIndex  uint64 // the index in MsgAppResp
+		wCommit uint64
+	}{
+		// The MsgApp is a prefix of the log. Commit up to log.last().
+		{pb.Message{Type: pb.MsgApp, Term: 2, LogTerm: 1, Index: 1, Commit: 3}, 2, 1, 1, 3},
+		{pb.Message{Type: pb.MsgApp, Term: 2, LogTerm: 1, Index: 1, Commit: 10}, 2, 1, 1, 4},
+		// The MsgApp is below the commit index.
+		{pb.Message{Type: pb.MsgApp, Term: 2, LogTerm: 1, Index: 1, Commit: 3}, 2, 2, 3, 3},
+		// The log is not guaranteed to be a prefix of the leader's log.
+		{pb.Message{Type: pb.MsgApp, Term: 3, LogTerm: 1, Index: 1, Commit: 3}, 2, 1, 1, 1},
+		{pb.Message{Type: pb.MsgApp, Term: 3, LogTerm: 1, Index: 1, Commit: 3}, 2, 2, 2, 2},
+	} {
+		t.Run("", func(t *testing.T) {
+			storage := newTestMemoryStorage(withPeers(1, 2))
+			init := entryID{}.append(1, tt.accTerm, tt.accTerm, tt.accTerm)
+			require.NoError(t, storage.Append(init.entries))
+			sm := newTestRaft(1, 10, 1, storage)
+			sm.becomeFollower(tt.m.Term, 2)
+			sm.raftLog.commitTo(logMark{term: init.term, index: tt.commit})
+
+			sm.handleAppendEntries(tt.m)
+			assert.Equal(t, tt.wCommit, sm.raftLog.committed)
+			m := sm.readMessages()
+			require.Len(t, m, 1)
+			assert.False(t, m[0].Reject)
+			assert.Equal(t, tt.wIndex, m[0].Index)
+		})
+	}
+}
+
 // TestHandleHeartbeat ensures that the follower commits to the commit in the message.
 func TestHandleHeartbeat(t *testing.T) {
 	commit := uint64(2)
@@ -1165,6 +1313,57 @@ func TestHandleHeartbeat(t *testing.T) {
 	}
 }
 
+// TestAssignLead ensures that the follower learns the leader from its messages,
+// and that the leader can not change within a term.
+func TestAssignLead(t *testing.T) {
+	sm := newTestRaft(1, 10, 1, newTestMemoryStorage(withPeers(1, 2, 3)))
+	sm.becomeFollower(2, None)
+	require.Equal(t, None, sm.lead)
+
+	require.NoError(t, sm.Step(pb.Message{From: 2, To: 1, Type: pb.MsgHeartbeat, Term: 2}))
+	require.Equal(t, pb.PeerID(2), sm.lead)
+	require.NoError(t, sm.Step(pb.Message{From: 2, To: 1, Type: pb.MsgApp, Term: 2}))
+	require.Equal(t, pb.PeerID(2), sm.lead)
+
+	// The leader can be forgotten, and learned again.
+	require.NoError(t, sm.Step(pb.Message{Type: pb.MsgForgetLeader}))
+	require.Equal(t, None, sm.lead)
+	require.NoError(t, sm.Step(pb.Message{From: 2, To: 1, Type: pb.MsgHeartbeat, Term: 2}))
+	require.Equal(t, pb.PeerID(2), sm.lead)
+
+	// Another leader at the same term violates raft invariants.
+	for _, typ := range []pb.MessageType{pb.MsgApp, pb.MsgHeartbeat, pb.MsgSnap} {
+		require.Panics(t, func() {
+			_ = sm.Step(pb.Message{From: 3, To: 1, Type: typ, Term: 2})
+		})
+	}
+	// A new leader at a higher term is fine.
+	require.NoError(t, sm.Step(pb.Message{From: 3, To: 1, Type: pb.MsgHeartbeat, Term: 3}))
+	require.Equal(t, pb.PeerID(3), sm.lead)
+}
+
+// TestCheckMatch ensures that the follower detects a leader's match index which
+// contradicts to its log.
+func TestCheckMatch(t *testing.T) {
+	storage := newTestMemoryStorage(withPeers(1, 2))
+	require.NoError(t, storage.Append(index(1).terms(1, 2)))
+	sm := newTestRaft(1, 10, 1, storage)
+	sm.becomeFollower(2, 2)
+	require.True(t, sm.raftLog.maybeAppend(entryID{term: 2, index: 2}.append(2, 2)))
+
+	hb := func(term, match uint64) pb.Message {
+		return pb.Message{From: 2, To: 1, Type: pb.MsgHeartbeat, Term: term, Match: match}
+	}
+	sm.handleHeartbeat(hb(2, 2))
+	// The entries 3-4 are not written yet, so they can't be acked.
+	require.Panics(t, func() { sm.handleHeartbeat(hb(2, 3)) })
+	sm.raftLog.acceptUnstable()
+	sm.handleHeartbeat(hb(2, 4))
+	require.Panics(t, func() { sm.handleHeartbeat(hb(2, 5)) })
+	// The leader's log can't contain entries from a later term.
+	require.Panics(t, func() { sm.handleHeartbeat(hb(1, 2)) })
+}
+
 // TestHandleHeartbeatResp ensures that we re-send log entries when we get a heartbeat response.
 func TestHandleHeartbeatResp(t *testing.T) {
 	storage := newTestMemoryStorage(withPeers(1, 2))
@@ -1993,6 +2192,124 @@ func TestBcastBeat(t *testing.T) {
 	}
 }
 
+// TestLeaderSkipsPeersWithoutSupport ensures that the leader does not send
+// messages to the peers whose stores stopped supporting the leader's store.
+func TestLeaderSkipsPeersWithoutSupport(t *testing.T) {
+	fabric := raftstoreliveness.NewMockFabric()
+	cfg := newTestConfig(1, 10, 1, newTestMemoryStorage(withPeers(1, 2, 3)))
+	cfg.StoreLiveness = fabric.StoreLiveness(1)
+	r := newRaft(cfg)
+	// Peer 2 never supported the leader's store, so it is not skipped.
+	fabric.GrantSupport(3, 1, hlc.MaxTimestamp)
+	r.becomeCandidate()
+	r.becomeLeader()
+	r.readMessages()
+
+	recipients := func(typ pb.MessageType) []pb.PeerID {
+		var to []pb.PeerID
+		for _, m := range r.readMessages() {
+			if m.Type == typ {
+				to = append(to, m.To)
+			}
+		}
+		return to
+	}
+	r.tick()
+	require.Equal(t, []pb.PeerID{2, 3}, recipients(pb.MsgHeartbeat))
+
+	fabric.WithdrawSupport(3, 1)
+	r.tick()
+	require.Equal(t, []pb.PeerID{2}, recipients(pb.MsgHeartbeat))
+	r.bcastAppend()
+	require.Equal(t, []pb.PeerID{2}, recipients(pb.MsgApp))
+
+	// The messages resume once the support is restored.
+	fabric.GrantSupport(3, 1, hlc.MaxTimestamp)
+	r.tick()
+	require.Equal(t, []pb.PeerID{2, 3}, recipients(pb.MsgHeartbeat))
+}
+
+// TestSupportingFortifiedLeaderPreventsElection ensures that a follower which
+// supports the store of its fortified leader does not campaign, and does not
+// grant pre-votes, until the support is withdrawn.
+func TestSupportingFortifiedLeaderPreventsElection(t *testing.T) {
+	fabric := raftstoreliveness.NewMockFabric()
+	cfg := newTestConfig(2, 10, 1, newTestMemoryStorage(withPeers(1, 2, 3)))
+	cfg.StoreLiveness = fabric.StoreLiveness(2)
+	cfg.PreVote = true
+	r := newRaft(cfg)
+	r.becomeFollower(1, 1)
+	fabric.GrantSupport(2, 1, hlc.MaxTimestamp)
+	r.leadEpoch = 1
+
+	for i := 0; i < 2*r.electionTimeout; i++ {
+		r.tick()
+	}
+	require.Equal(t, StateFollower, r.state)
+	require.Equal(t, pb.PeerID(1), r.lead)
+
+	require.NoError(t, r.Step(pb.Message{From: 3, To: 2, Type: pb.MsgPreVote, Term: 2}))
+	msgs := r.readMessages()
+	require.Len(t, msgs, 1)
+	require.Equal(t, pb.MsgPreVoteResp, msgs[0].Type)
+	require.True(t, msgs[0].Reject)
+	require.Equal(t, uint64(1), msgs[0].Term)
+
+	// The support for a previous epoch does not prevent the election.
+	fabric.BumpEpoch(2, 1)
+	require.False(t, r.supportingFortifiedLeader())
+	fabric.WithdrawSupport(2, 1)
+	for i := 0; i < 2*r.electionTimeout && r.state == StateFollower; i++ {
+		r.tick()
+	}
+	require.Equal(t, StatePreCandidate, r.state)
+}
+
+// TestElectionProbe ensures that a newly elected leader immediately sends a
+// MsgApp with its commit index to all followers, and a heartbeat to those to
+// which it can't send a MsgApp, unless the heartbeat fallback is disabled.
+func TestElectionProbe(t *testing.T) {
+	for _, disable := range []bool{false, true} {
+		t.Run(fmt.Sprintf("disable=%t", disable), func(t *testing.T) {
+			storage := newTestMemoryStorage(withPeers(1, 2, 3))
+			require.NoError(t, storage.Append(index(1).terms(1, 1)))
+			require.NoError(t, storage.SetHardState(pb.HardState{Term: 1, Commit: 2}))
+			cfg := newTestConfig(1, 10, 1, storage)
+			cfg.DisableHeartbeatFallback = disable
+			r := newRaft(cfg)
+
+			require.NoError(t, r.Step(pb.Message{From: 1, To: 1, Type: pb.MsgHup}))
+			r.readMessages()
+			require.NoError(t, r.Step(pb.Message{From: 2, To: 1, Term: r.Term, Type: pb.MsgVoteResp}))
+			require.Equal(t, StateLeader, r.state)
+
+			msgs := r.readMessages()
+			require.Len(t, msgs, 2)
+			for i, m := range msgs {
+				require.Equal(t, pb.PeerID(i+2), m.To)
+				require.Equal(t, pb.MsgApp, m.Type)
+				require.Equal(t, uint64(2), m.Commit)
+				require.Len(t, m.Entries, 1)
+			}
+
+			// The MsgApp flow to the followers is paused until they respond to the
+			// probe. A probe to a follower whose flow is paused is a heartbeat.
+			r.trk.Progress(2).MsgAppProbesPaused = false
+			r.bcastProbe()
+			msgs = r.readMessages()
+			if disable {
+				require.Len(t, msgs, 1)
+			} else {
+				require.Len(t, msgs, 2)
+				require.Equal(t, pb.PeerID(3), msgs[1].To)
+				require.Equal(t, pb.MsgHeartbeat, msgs[1].Type)
+			}
+			require.Equal(t, pb.PeerID(2), msgs[0].To)
+			require.Equal(t, pb.MsgApp, msgs[0].Type)
+		})
+	}
+}
+
 // TestRecvMsgBeat tests the output of the state machine when receiving MsgBeat
 func TestRecvMsgBeat(t *testing.T) {
 	tests := []struct {
@@ -2157,6 +2474,55 @@ func TestRecvMsgUnreachable(t *testing.T) {
 	assert.Equal(t, wnext, r.trk.Progress(2).Next)
 }
 
+// TestFollowerStatus tests that the leader classifies its followers, and
+// records the ticks at which their classification changes.
+func TestFollowerStatus(t *testing.T) {
+	r := newTestRaft(1, 10, 1, newTestMemoryStorage(withPeers(1, 2, 3)))
+	r.becomeCandidate()
+	r.becomeLeader()
+	r.readMessages()
+
+	followers := func() map[pb.PeerID]FollowerStatus {
+		return getStatus(r).Followers
+	}
+	assert.Equal(t, map[pb.PeerID]FollowerStatus{
+		2: {State: FollowerProbing, Since: 0},
+		3: {State: FollowerProbing, Since: 0},
+	}, followers())
+
+	// Both followers ack the leader's empty entry and move to StateReplicate.
+	// Only follower 2 acks the next entry.
+	require.NoError(t, r.Step(pb.Message{From: 2, To: 1, Type: pb.MsgAppResp, Index: 1}))
+	require.NoError(t, r.Step(pb.Message{From: 3, To: 1, Type: pb.MsgAppResp, Index: 1}))
+	require.NoError(t, r.Step(pb.Message{From: 1, To: 1, Type: pb.MsgProp, Entries: []pb.Entry{{Data: []byte("foo")}}}))
+	r.readMessages()
+	require.NoError(t, r.Step(pb.Message{From: 2, To: 1, Type: pb.MsgAppResp, Index: 2}))
+
+	r.tick()
+	assert.Equal(t, FollowerStatus{State: FollowerHealthy, Since: 1}, followers()[2])
+	assert.Equal(t, FollowerStatus{State: FollowerHealthy, Since: 1}, followers()[3])
+
+	// Follower 3 does not make progress for an election timeout.
+	for i := 0; i < r.electionTimeout; i++ {
+		r.tick()
+	}
+	assert.Equal(t, FollowerStatus{State: FollowerHealthy, Since: 1}, followers()[2])
+	assert.Equal(t, FollowerStatus{State: FollowerIdle, Since: 11}, followers()[3])
+
+	// Follower 2 is reported unreachable, and is considered as such until it
+	// responds.
+	require.NoError(t, r.Step(pb.Message{From: 2, To: 1, Type: pb.MsgUnreachable}))
+	r.tick()
+	assert.Equal(t, FollowerStatus{State: FollowerUnreachable, Since: 12}, followers()[2])
+	require.NoError(t, r.Step(pb.Message{From: 2, To: 1, Type: pb.MsgHeartbeatResp}))
+	r.tick()
+	assert.Equal(t, FollowerStatus{State: FollowerProbing, Since: 13}, followers()[2])
+
+	// The classification is only maintained by the leader.
+	r.becomeFollower(r.Term+1, None)
+	assert.Nil(t, followers())
+}
+
 func TestRestore(t *testing.T) {
 	s := snapshot{
 		term: 11,
@@ -2181,6 +2547,36 @@ func TestRestore(t *testing.T) {
 	assert.Equal(t, StateFollower, sm.state)
 }
 
+// TestRestoreCorruptedSnapshot tests that a snapshot whose data does not match
+// its integrity metadata is rejected.
+func TestRestoreCorruptedSnapshot(t *testing.T) {
+	s := snapshot{
+		term: 11,
+		snap: pb.Snapshot{Data: []byte("data"), Metadata: pb.SnapshotMetadata{
+			Index:     11,
+			Term:      11,
+			ConfState: pb.ConfState{Voters: []pb.PeerID{1, 2, 3}},
+		}},
+	}
+	s.snap.SetIntegrity()
+	corrupted := s
+	corrupted.snap.Data = []byte("dat")
+
+	storage := newTestMemoryStorage(withPeers(1, 2))
+	sm := newTestRaft(1, 10, 1, storage)
+	require.False(t, sm.restore(corrupted))
+	assert.Equal(t, uint64(0), sm.raftLog.lastIndex())
+	assert.Equal(t, []pb.PeerID{1, 2}, sm.trk.VoterNodes())
+
+	var ierr *pb.SnapshotIntegrityError
+	require.ErrorAs(t, storage.ApplySnapshot(corrupted.snap), &ierr)
+	require.Equal(t, uint64(11), ierr.Index)
+
+	require.True(t, sm.restore(s))
+	assert.Equal(t, s.lastEntryID(), sm.raftLog.lastEntryID())
+	assert.Equal(t, s.snap.Metadata.ConfState.Voters, sm.trk.VoterNodes())
+}
+
 // TestRestoreWithLearner restores a snapshot which contains learners.
 func TestRestoreWithLearner(t *testing.T) {
 	s := snapshot{
@@ -3442,6 +3838,49 @@ func testConfChangeCheckBeforeCampaign(t *testing.T, v2 bool) {
 	assert.Equal(t, StateCandidate, n1.state)
 }
 
+type testScanBudget struct {
+	pageSize, maxBytes uint64
+	truncated          [][3]uint64
+}
+
+func (b *testScanBudget) PageSize() uint64 { return b.pageSize }
+func (b *testScanBudget) MaxBytes() uint64 { return b.maxBytes }
+func (b *testScanBudget) ScanTruncated(lo, next, hi uint64) {
+	b.truncated = append(b.truncated, [3]uint64{lo, next, hi})
+}
+
+// TestHasUnappliedConfChangesScanBudget tests that the scan for unapplied
+// config changes respects the ScanBudget, and conservatively assumes there is
+// a config change if the scan is truncated.
+func TestHasUnappliedConfChangesScanBudget(t *testing.T) {
+	ents := index(1).terms(1, 1, 1, 1, 1)
+	ents[3].Type = pb.EntryConfChange
+	size := uint64(ents[0].Size())
+
+	for _, tt := range []struct {
+		maxBytes  uint64
+		want      bool
+		truncated [][3]uint64
+	}{
+		{maxBytes: 0, want: true},
+		{maxBytes: 4 * size, want: true},
+		{maxBytes: 2 * size, want: true, truncated: [][3]uint64{{1, 3, 6}}},
+	} {
+		t.Run("", func(t *testing.T) {
+			s := newTestMemoryStorage(withPeers(1, 2))
+			require.NoError(t, s.Append(ents))
+			budget := &testScanBudget{pageSize: size, maxBytes: tt.maxBytes}
+			cfg := newTestConfig(1, 10, 1, s)
+			cfg.ScanBudget = budget
+			r := newRaft(cfg)
+			r.raftLog.committed = 5
+
+			require.Equal(t, tt.want, r.hasUnappliedConfChanges())
+			require.Equal(t, tt.truncated, budget.truncated)
+		})
+	}
+}
+
 // TestConfChangeCheckBeforeCampaign tests if unapplied ConfChange is checked before campaign.
 func TestConfChangeCheckBeforeCampaign(t *testing.T) {
 	testConfChangeCheckBeforeCampaign(t, false)
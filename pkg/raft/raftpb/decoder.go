@@ -0,0 +1,264 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package raftpb
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/cockroachdb/cockroach/pkg/raft/raftstoreliveness"
+)
+
+// errInvalidVarint is returned when decoding a malformed varint.
+var errInvalidVarint = errors.New("raftpb: invalid varint")
+
+// Decoder is an alternative to the generated Unmarshal methods of Message and
+// Entry, which avoids most of their allocations. It is intended for servers
+// which decode large volumes of raft messages, where these allocations
+// dominate CPU profiles.
+//
+// The values decoded by a Decoder are not self-contained:
+//   - the byte slice fields (Entry.Data, Message.Context) alias the decoded
+//     buffer instead of copying it,
+//   - the Message.Entries slice is carved from memory owned by the Decoder,
+//     which is reused after Reset.
+//
+// Therefore, the decoded values must not be used after the decoded buffer is
+// modified or reused, or after the Decoder is Reset or released. Values which
+// need to outlive them must be copied first. The rare Message.Snapshot field is
+// decoded with the generated code, and does not alias the buffer.
+//
+// A Decoder is not safe for concurrent use.
+type Decoder struct {
+	ents []Entry
+}
+
+var decoderPool = sync.Pool{
+	New: func() interface{} { return &Decoder{} },
+}
+
+// GetDecoder returns a Decoder from a pool. It must be returned to the pool
+// with Release once the values it decoded are no longer used.
+func GetDecoder() *Decoder {
+	return decoderPool.Get().(*Decoder)
+}
+
+// Release resets the Decoder and returns it to the pool.
+func (d *Decoder) Release() {
+	d.Reset()
+	decoderPool.Put(d)
+}
+
+// Reset makes the Decoder reuse its memory for subsequently decoded values.
+// The values decoded before the Reset must no longer be used.
+func (d *Decoder) Reset() {
+	// Clear the references into the decoded buffers, so that they can be GCed.
+	clear(d.ents)
+	d.ents = d.ents[:0]
+}
+
+// UnmarshalMessage decodes the protobuf encoding of a Message into m. See the
+// Decoder comment for the lifetime of the decoded value.
+func (d *Decoder) UnmarshalMessage(data []byte, m *Message) error {
+	*m = Message{}
+	// Entries are accumulated at the end of the arena, and carved out once the
+	// message is fully decoded. Responses are decoded last, so that their own
+	// entries don't interleave with those of this message.
+	entsStart := len(d.ents)
+	var responses [][]byte
+	for len(data) > 0 {
+		num, typ, n, err := decodeTag(data)
+		if err != nil {
+			return err
+		}
+		data = data[n:]
+		var v uint64
+		var b []byte
+		switch typ {
+		case 0: // varint
+			if v, n, err = decodeVarint(data); err != nil {
+				return err
+			}
+		case 2: // length-delimited
+			if b, n, err = decodeBytes(data); err != nil {
+				return err
+			}
+		default:
+			if n, err = skipField(data, typ); err != nil {
+				return err
+			}
+		}
+		data = data[n:]
+
+		switch {
+		case typ == 0 && num == 1:
+			m.Type = MessageType(v)
+		case typ == 0 && num == 2:
+			m.To = PeerID(v)
+		case typ == 0 && num == 3:
+			m.From = PeerID(v)
+		case typ == 0 && num == 4:
+			m.Term = v
+		case typ == 0 && num == 5:
+			m.LogTerm = v
+		case typ == 0 && num == 6:
+			m.Index = v
+		case typ == 2 && num == 7:
+			d.ents = append(d.ents, Entry{})
+			if err := UnmarshalEntry(b, &d.ents[len(d.ents)-1]); err != nil {
+				return err
+			}
+		case typ == 0 && num == 8:
+			m.Commit = v
+		case typ == 2 && num == 9:
+			m.Snapshot = &Snapshot{}
+			if err := m.Snapshot.Unmarshal(b); err != nil {
+				return err
+			}
+		case typ == 0 && num == 10:
+			m.Reject = v != 0
+		case typ == 0 && num == 11:
+			m.RejectHint = v
+		case typ == 2 && num == 12:
+			m.Context = b
+		case typ == 0 && num == 13:
+			m.Vote = PeerID(v)
+		case typ == 2 && num == 14:
+			responses = append(responses, b)
+		case typ == 0 && num == 15:
+			m.Match = v
+		case typ == 0 && num == 16:
+			m.Lead = PeerID(v)
+		case typ == 0 && num == 17:
+			m.LeadEpoch = raftstoreliveness.Epoch(v)
+		}
+	}
+	if entsEnd := len(d.ents); entsEnd > entsStart {
+		// NB: the full slice expression prevents appends to m.Entries from
+		// corrupting the entries of subsequently decoded messages.
+		m.Entries = d.ents[entsStart:entsEnd:entsEnd]
+	}
+	if len(responses) > 0 {
+		// Responses are only used by local messages, so this is rare and does not
+		// need to avoid allocations.
+		resps := make([]Message, len(responses))
+		for i, b := range responses {
+			if err := d.UnmarshalMessage(b, &resps[i]); err != nil {
+				return err
+			}
+		}
+		m.Responses = resps
+	}
+	return nil
+}
+
+// UnmarshalEntry decodes the protobuf encoding of an Entry into ent, without
+// allocating. Unlike Entry.Unmarshal, ent.Data aliases data instead of being a
+// copy of it.
+func UnmarshalEntry(data []byte, ent *Entry) error {
+	*ent = Entry{}
+	for len(data) > 0 {
+		num, typ, n, err := decodeTag(data)
+		if err != nil {
+			return err
+		}
+		data = data[n:]
+		switch {
+		case typ == 0 && num <= 3:
+			var v uint64
+			if v, n, err = decodeVarint(data); err != nil {
+				return err
+			}
+			switch num {
+			case 1:
+				ent.Type = EntryType(v)
+			case 2:
+				ent.Term = v
+			case 3:
+				ent.Index = v
+			}
+		case typ == 2 && num == 4:
+			if ent.Data, n, err = decodeBytes(data); err != nil {
+				return err
+			}
+		default:
+			if n, err = skipField(data, typ); err != nil {
+				return err
+			}
+		}
+		data = data[n:]
+	}
+	return nil
+}
+
+// decodeTag decodes a field tag, and returns the field number, the wire type,
+// and the size of the tag.
+func decodeTag(data []byte) (num int32, typ int, n int, _ error) {
+	v, n, err := decodeVarint(data)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	num, typ = int32(v>>3), int(v&0x7)
+	if num <= 0 {
+		return 0, 0, 0, fmt.Errorf("raftpb: illegal field number %d", num)
+	}
+	return num, typ, n, nil
+}
+
+func decodeVarint(data []byte) (uint64, int, error) {
+	v, n := binary.Uvarint(data)
+	if n <= 0 {
+		return 0, 0, errInvalidVarint
+	}
+	return v, n, nil
+}
+
+// decodeBytes decodes a length-delimited field, and returns its contents (as a
+// subslice of data) and its total size.
+func decodeBytes(data []byte) ([]byte, int, error) {
+	l, n, err := decodeVarint(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	if l > uint64(len(data)-n) {
+		return nil, 0, io.ErrUnexpectedEOF
+	}
+	end := n + int(l)
+	return data[n:end:end], end, nil
+}
+
+// skipField returns the size of a field of the given wire type which is not
+// needed by the decoder.
+func skipField(data []byte, typ int) (int, error) {
+	switch typ {
+	case 0:
+		_, n, err := decodeVarint(data)
+		return n, err
+	case 1:
+		if len(data) < 8 {
+			return 0, io.ErrUnexpectedEOF
+		}
+		return 8, nil
+	case 2:
+		_, n, err := decodeBytes(data)
+		return n, err
+	case 5:
+		if len(data) < 4 {
+			return 0, io.ErrUnexpectedEOF
+		}
+		return 4, nil
+	default:
+		return 0, fmt.Errorf("raftpb: unsupported wire type %d", typ)
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package raftpb
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestDecoder(t *testing.T) {
+	msgs := []Message{
+		{},
+		{Type: MsgHeartbeat, To: 2, From: 1, Term: 5, Commit: 10, Lead: 1, LeadEpoch: 3},
+		{Type: MsgAppResp, To: 1, From: 2, Term: 5, Index: 7, Reject: true, RejectHint: 6, LogTerm: 4},
+		{
+			Type: MsgApp, To: 2, From: 1, Term: 5, LogTerm: 4, Index: 10, Commit: 9, Match: 8,
+			Entries: []Entry{
+				{Term: 5, Index: 11, Data: []byte("foo")},
+				{Term: 5, Index: 12, Type: EntryConfChangeV2, Data: []byte{}},
+				{Term: 5, Index: 13},
+			},
+			Context: []byte("ctx"),
+		},
+		{
+			Type: MsgSnap, To: 2, From: 1, Term: 5,
+			Snapshot: &Snapshot{Data: []byte("snap"), Metadata: SnapshotMetadata{
+				Index: 20, Term: 5, ConfState: ConfState{Voters: []PeerID{1, 2, 3}},
+			}},
+		},
+		{
+			Type: MsgStorageAppend, To: 100, From: 1,
+			Entries: []Entry{{Term: 1, Index: 1, Data: []byte("a")}},
+			Responses: []Message{
+				{Type: MsgAppResp, To: 2, From: 1, Index: 1},
+				{Type: MsgStorageAppendResp, To: 1, From: 100,
+					Entries: []Entry{{Term: 1, Index: 1, Data: []byte("b")}}},
+			},
+		},
+	}
+
+	d := GetDecoder()
+	defer d.Release()
+	var decoded []Message
+	for _, m := range msgs {
+		data, err := m.Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want, got Message
+		if err := want.Unmarshal(data); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.UnmarshalMessage(data, &got); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(want, got) {
+			t.Errorf("decoded message mismatch:\nwant: %+v\ngot:  %+v", want, got)
+		}
+		decoded = append(decoded, got)
+	}
+	// The messages decoded with the same Decoder don't corrupt each other.
+	for i := range msgs {
+		data, err := msgs[i].Marshal()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want Message
+		if err := want.Unmarshal(data); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(want, decoded[i]) {
+			t.Errorf("decoded message %d was corrupted:\nwant: %+v\ngot:  %+v", i, want, decoded[i])
+		}
+	}
+
+	// Truncated input is rejected.
+	data, err := msgs[3].Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m Message
+	if err := d.UnmarshalMessage(data[:len(data)-1], &m); err == nil {
+		t.Error("expected an error decoding a truncated message")
+	}
+}
+
+func TestDecoderAllocs(t *testing.T) {
+	m := Message{
+		Type: MsgApp, To: 2, From: 1, Term: 5, LogTerm: 4, Index: 10, Commit: 9,
+		Entries: []Entry{
+			{Term: 5, Index: 11, Data: []byte("foo")},
+			{Term: 5, Index: 12, Data: []byte("bar")},
+		},
+	}
+	data, err := m.Marshal()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var d Decoder
+	allocs := testing.AllocsPerRun(100, func() {
+		d.Reset()
+		var got Message
+		if err := d.UnmarshalMessage(data, &got); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("expected no allocations, got %.1f", allocs)
+	}
+}
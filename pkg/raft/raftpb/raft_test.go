@@ -42,10 +42,10 @@ func TestProtoMemorySizes(t *testing.T) {
 	assert(unsafe.Sizeof(e), if64Bit(48, 32), "Entry")
 
 	var sm SnapshotMetadata
-	assert(unsafe.Sizeof(sm), if64Bit(120, 68), "SnapshotMetadata")
+	assert(unsafe.Sizeof(sm), if64Bit(136, 80), "SnapshotMetadata")
 
 	var s Snapshot
-	assert(unsafe.Sizeof(s), if64Bit(144, 80), "Snapshot")
+	assert(unsafe.Sizeof(s), if64Bit(160, 92), "Snapshot")
 
 	var m Message
 	assert(unsafe.Sizeof(m), if64Bit(184, 112), "Message")
@@ -0,0 +1,74 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package raftpb
+
+import (
+	"fmt"
+	"hash/crc32"
+)
+
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
+// SnapshotChecksum returns the checksum of the snapshot data, as stored in
+// SnapshotMetadata.DataChecksum.
+func SnapshotChecksum(data []byte) uint32 {
+	return crc32.Checksum(data, castagnoliTable)
+}
+
+// SnapshotIntegrityError is returned when the snapshot data does not match the
+// size or the checksum in the snapshot metadata, i.e. it was truncated or
+// corrupted.
+type SnapshotIntegrityError struct {
+	// Index is the index of the snapshot.
+	Index uint64
+	// WantSize and WantChecksum are the size and checksum in the metadata.
+	WantSize     uint64
+	WantChecksum uint32
+	// GotSize and GotChecksum are the size and checksum of the data.
+	GotSize     uint64
+	GotChecksum uint32
+}
+
+// Error implements the error interface.
+func (e *SnapshotIntegrityError) Error() string {
+	return fmt.Sprintf("raftpb: snapshot at index %d has corrupted data: "+
+		"size %d, checksum %08x; expected size %d, checksum %08x",
+		e.Index, e.GotSize, e.GotChecksum, e.WantSize, e.WantChecksum)
+}
+
+// SetIntegrity sets the size and the checksum of the snapshot data in its
+// metadata, so that the recipients of the snapshot can verify it.
+func (s *Snapshot) SetIntegrity() {
+	s.Metadata.DataSize = uint64(len(s.Data))
+	s.Metadata.DataChecksum = SnapshotChecksum(s.Data)
+}
+
+// VerifyIntegrity checks that the snapshot data matches the size and the
+// checksum in its metadata, and returns a *SnapshotIntegrityError otherwise.
+// Snapshots without the integrity information (see SetIntegrity) are not
+// verified.
+func (s *Snapshot) VerifyIntegrity() error {
+	md := &s.Metadata
+	if md.DataSize == 0 && md.DataChecksum == 0 {
+		return nil
+	}
+	size, sum := uint64(len(s.Data)), SnapshotChecksum(s.Data)
+	if size == md.DataSize && sum == md.DataChecksum {
+		return nil
+	}
+	return &SnapshotIntegrityError{
+		Index:        md.Index,
+		WantSize:     md.DataSize,
+		WantChecksum: md.DataChecksum,
+		GotSize:      size,
+		GotChecksum:  sum,
+	}
+}
@@ -0,0 +1,49 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package raftpb
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSnapshotIntegrity(t *testing.T) {
+	snap := Snapshot{Data: []byte("snapshot data"), Metadata: SnapshotMetadata{Index: 10, Term: 2}}
+	// Snapshots without the integrity information are not verified.
+	if err := snap.VerifyIntegrity(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	snap.SetIntegrity()
+	if err := snap.VerifyIntegrity(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		data []byte
+	}{
+		{name: "truncated", data: snap.Data[:5]},
+		{name: "corrupted", data: []byte("snapshot dada")},
+		{name: "empty", data: nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			corrupted := snap
+			corrupted.Data = tc.data
+			var ierr *SnapshotIntegrityError
+			if err := corrupted.VerifyIntegrity(); !errors.As(err, &ierr) {
+				t.Fatalf("expected SnapshotIntegrityError, got %v", err)
+			}
+			if ierr.Index != 10 || ierr.WantSize != uint64(len(snap.Data)) || ierr.GotSize != uint64(len(tc.data)) {
+				t.Fatalf("unexpected error: %+v", ierr)
+			}
+		})
+	}
+}
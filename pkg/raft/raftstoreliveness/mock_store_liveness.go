@@ -0,0 +1,231 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package raftstoreliveness
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
+)
+
+// MockFabric is an in-memory Store Liveness fabric for tests. Support between
+// stores is scripted by the test via GrantSupport, WithdrawSupport and
+// BumpEpoch, and each store can be given a clock skew relative to the fabric's
+// clock. Stores are identified by the ID of the (single) replica on them.
+//
+// This allows exercising leader fortification in unit and datadriven tests
+// without the full Store Liveness implementation.
+type MockFabric struct {
+	mu sync.Mutex
+	// now is the fabric's clock, see SetTime.
+	now hlc.Timestamp
+	// skew is the clock skew of each store, see SetClockSkew.
+	skew map[uint64]time.Duration
+	// supportFromDisabled is the negation of SupportFromEnabled.
+	supportFromDisabled bool
+	// support contains the support provided by one store to another.
+	support map[supportKey]supportState
+}
+
+// supportKey identifies the support provided by the supporter store to the
+// supported store.
+type supportKey struct {
+	supporter, supported uint64
+}
+
+// supportState is the state of support provided by one store to another.
+type supportState struct {
+	// epoch is the epoch of the current or last period of support.
+	epoch Epoch
+	// expiration is the time until which support is provided.
+	expiration hlc.Timestamp
+	// supported is true if support is currently provided.
+	supported bool
+}
+
+// NewMockFabric creates a MockFabric in which no store supports any other.
+func NewMockFabric() *MockFabric {
+	return &MockFabric{
+		skew:    map[uint64]time.Duration{},
+		support: map[supportKey]supportState{},
+	}
+}
+
+// StoreLiveness returns the view of the fabric from the store with the given
+// replica ID.
+func (f *MockFabric) StoreLiveness(id uint64) StoreLiveness {
+	return mockStoreLiveness{fabric: f, id: id}
+}
+
+// GrantSupport makes the supporter store support the supported store until the
+// given expiration. If support was not already provided, this starts a new
+// period of support with the next epoch. Otherwise, the expiration of the
+// current period is updated.
+func (f *MockFabric) GrantSupport(supporter, supported uint64, expiration hlc.Timestamp) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := supportKey{supporter: supporter, supported: supported}
+	st := f.support[key]
+	if !st.supported {
+		st.epoch++
+		st.supported = true
+	}
+	st.expiration = expiration
+	f.support[key] = st
+}
+
+// WithdrawSupport ends the current period of support provided by the supporter
+// store to the supported store, if any.
+func (f *MockFabric) WithdrawSupport(supporter, supported uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := supportKey{supporter: supporter, supported: supported}
+	st := f.support[key]
+	st.supported = false
+	st.expiration = hlc.Timestamp{}
+	f.support[key] = st
+}
+
+// BumpEpoch increments the support epoch between the supporter and supported
+// stores. If support is currently provided, this amounts to the support being
+// withdrawn and immediately granted again, with the same expiration.
+func (f *MockFabric) BumpEpoch(supporter, supported uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := supportKey{supporter: supporter, supported: supported}
+	st := f.support[key]
+	st.epoch++
+	f.support[key] = st
+}
+
+// SetTime sets the fabric's clock.
+func (f *MockFabric) SetTime(now hlc.Timestamp) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.now = now
+}
+
+// SetClockSkew sets the skew of the given store's clock relative to the
+// fabric's clock. The skew affects the store's SupportExpired calls.
+func (f *MockFabric) SetClockSkew(id uint64, skew time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.skew[id] = skew
+}
+
+// SetSupportFromEnabled sets the value returned by SupportFromEnabled for all
+// stores. It is enabled by default.
+func (f *MockFabric) SetSupportFromEnabled(enabled bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.supportFromDisabled = !enabled
+}
+
+// String returns a description of the state of the fabric, with one line per
+// pair of stores between which support was ever provided.
+func (f *MockFabric) String() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var b strings.Builder
+	fmt.Fprintf(&b, "now: %s\n", formatWallTime(f.now))
+	ids := make([]uint64, 0, len(f.skew))
+	for id := range f.skew {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	for _, id := range ids {
+		if f.skew[id] != 0 {
+			fmt.Fprintf(&b, "skew %d: %d\n", id, f.skew[id].Nanoseconds())
+		}
+	}
+	if f.supportFromDisabled {
+		b.WriteString("support-from disabled\n")
+	}
+	keys := make([]supportKey, 0, len(f.support))
+	for key := range f.support {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b supportKey) int {
+		if c := cmp.Compare(a.supporter, b.supporter); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.supported, b.supported)
+	})
+	for _, key := range keys {
+		st := f.support[key]
+		fmt.Fprintf(&b, "%d->%d: epoch=%d", key.supporter, key.supported, st.epoch)
+		if st.supported {
+			fmt.Fprintf(&b, " expiration=%s", formatWallTime(st.expiration))
+		} else {
+			b.WriteString(" withdrawn")
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+func formatWallTime(ts hlc.Timestamp) string {
+	if ts == hlc.MaxTimestamp {
+		return "max"
+	}
+	return fmt.Sprint(ts.WallTime)
+}
+
+// mockStoreLiveness is the view of a MockFabric from a given store.
+type mockStoreLiveness struct {
+	fabric *MockFabric
+	id     uint64
+}
+
+var _ StoreLiveness = mockStoreLiveness{}
+
+// SupportFor implements the StoreLiveness interface.
+func (m mockStoreLiveness) SupportFor(id uint64) (Epoch, bool) {
+	m.fabric.mu.Lock()
+	defer m.fabric.mu.Unlock()
+	st := m.fabric.support[supportKey{supporter: m.id, supported: id}]
+	if !st.supported {
+		return 0, false
+	}
+	return st.epoch, true
+}
+
+// SupportFrom implements the StoreLiveness interface.
+func (m mockStoreLiveness) SupportFrom(id uint64) (Epoch, hlc.Timestamp, bool) {
+	m.fabric.mu.Lock()
+	defer m.fabric.mu.Unlock()
+	st := m.fabric.support[supportKey{supporter: id, supported: m.id}]
+	if !st.supported {
+		return 0, hlc.Timestamp{}, false
+	}
+	return st.epoch, st.expiration, true
+}
+
+// SupportFromEnabled implements the StoreLiveness interface.
+func (m mockStoreLiveness) SupportFromEnabled() bool {
+	m.fabric.mu.Lock()
+	defer m.fabric.mu.Unlock()
+	return !m.fabric.supportFromDisabled
+}
+
+// SupportExpired implements the StoreLiveness interface. The expiration is
+// compared against the fabric's clock, adjusted by the store's clock skew.
+func (m mockStoreLiveness) SupportExpired(ts hlc.Timestamp) bool {
+	m.fabric.mu.Lock()
+	defer m.fabric.mu.Unlock()
+	now := m.fabric.now.Add(m.fabric.skew[m.id].Nanoseconds(), 0)
+	return ts.Less(now)
+}
@@ -0,0 +1,83 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package raftstoreliveness
+
+import (
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMockFabric(t *testing.T) {
+	f := NewMockFabric()
+	sl1, sl2 := f.StoreLiveness(1), f.StoreLiveness(2)
+	ts := func(wall int64) hlc.Timestamp { return hlc.Timestamp{WallTime: wall} }
+
+	// No support is provided initially.
+	epoch, ok := sl2.SupportFor(1)
+	require.False(t, ok)
+	require.Zero(t, epoch)
+	_, exp, ok := sl1.SupportFrom(2)
+	require.False(t, ok)
+	require.True(t, exp.IsEmpty())
+
+	// Store 2 supports store 1, which is visible from both sides.
+	f.GrantSupport(2, 1, ts(10))
+	epoch, ok = sl2.SupportFor(1)
+	require.True(t, ok)
+	require.Equal(t, Epoch(1), epoch)
+	epoch, exp, ok = sl1.SupportFrom(2)
+	require.True(t, ok)
+	require.Equal(t, Epoch(1), epoch)
+	require.Equal(t, ts(10), exp)
+	// Support is directional.
+	_, ok = sl1.SupportFor(2)
+	require.False(t, ok)
+
+	// Extending support keeps the epoch.
+	f.GrantSupport(2, 1, ts(20))
+	epoch, exp, _ = sl1.SupportFrom(2)
+	require.Equal(t, Epoch(1), epoch)
+	require.Equal(t, ts(20), exp)
+
+	// Withdrawing and re-granting support starts a new epoch, as does an
+	// explicit epoch bump.
+	f.WithdrawSupport(2, 1)
+	_, ok = sl2.SupportFor(1)
+	require.False(t, ok)
+	f.GrantSupport(2, 1, ts(30))
+	epoch, _ = sl2.SupportFor(1)
+	require.Equal(t, Epoch(2), epoch)
+	f.BumpEpoch(2, 1)
+	epoch, exp, ok = sl1.SupportFrom(2)
+	require.True(t, ok)
+	require.Equal(t, Epoch(3), epoch)
+	require.Equal(t, ts(30), exp)
+
+	// Expiration depends on the store's clock skew.
+	f.SetTime(ts(25))
+	require.False(t, sl1.SupportExpired(ts(30)))
+	f.SetClockSkew(1, 10*time.Nanosecond)
+	require.True(t, sl1.SupportExpired(ts(30)))
+	require.False(t, sl2.SupportExpired(ts(30)))
+
+	require.True(t, sl1.SupportFromEnabled())
+	f.SetSupportFromEnabled(false)
+	require.False(t, sl2.SupportFromEnabled())
+
+	require.Equal(t, `now: 25
+skew 1: 10
+support-from disabled
+2->1: epoch=3 expiration=30
+`, f.String())
+}
@@ -25,6 +25,7 @@ import (
 
 	"github.com/cockroachdb/cockroach/pkg/raft"
 	pb "github.com/cockroachdb/cockroach/pkg/raft/raftpb"
+	"github.com/cockroachdb/cockroach/pkg/raft/raftstoreliveness"
 )
 
 // InteractionOpts groups the options for an InteractionEnv.
@@ -45,6 +46,9 @@ type Node struct {
 	AppendWork []pb.Message // []MsgStorageAppend
 	ApplyWork  []pb.Message // []MsgStorageApply
 	History    []pb.Snapshot
+	// DelayedResponses are the responses to the processed AppendWork and
+	// ApplyWork, which are withheld until ReleaseDelayedResponses is called.
+	DelayedResponses []pb.Message
 }
 
 // InteractionEnv facilitates testing of complex interactions between the
@@ -53,6 +57,10 @@ type InteractionEnv struct {
 	Options  *InteractionOpts
 	Nodes    []Node
 	Messages []pb.Message // in-flight messages
+	// Fabric is the mock store liveness fabric used by the nodes added with
+	// mock-store-liveness=true, unless their StoreLiveness is set by
+	// InteractionOpts.OnConfig.
+	Fabric *raftstoreliveness.MockFabric
 
 	Output *RedirectLogger
 }
@@ -64,6 +72,7 @@ func NewInteractionEnv(opts *InteractionOpts) *InteractionEnv {
 	}
 	return &InteractionEnv{
 		Options: opts,
+		Fabric:  raftstoreliveness.NewMockFabric(),
 		Output: &RedirectLogger{
 			Builder: &strings.Builder{},
 		},
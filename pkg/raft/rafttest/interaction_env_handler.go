@@ -44,6 +44,9 @@ func (env *InteractionEnv) Handle(t *testing.T, d datadriven.TestData) string {
 		// Example:
 		//
 		// add-nodes <number-of-nodes-to-add> voters=(1 2 3) learners=(4 5) index=2 content=foo async-storage-writes=true
+		//
+		// With mock-store-liveness=true, the nodes use the mock store liveness
+		// fabric scripted by the store liveness commands below.
 		err = env.handleAddNodes(t, d)
 	case "campaign":
 		// Example:
@@ -77,6 +80,23 @@ func (env *InteractionEnv) Handle(t *testing.T, d datadriven.TestData) string {
 		//
 		// process-apply-thread 3
 		err = env.handleProcessApplyThread(t, d)
+	case "fail-append-thread", "fail-apply-thread":
+		// Drop the next message on the "append" or "apply" thread without
+		// processing it, along with its responses, as if the write failed.
+		//
+		// Example:
+		//
+		// fail-append-thread 3
+		err = env.handleStorageFault(t, d)
+	case "delay-append-thread", "delay-apply-thread", "release-responses":
+		// Process the next message on the "append" or "apply" thread, but
+		// withhold its responses until they are released.
+		//
+		// Example:
+		//
+		// delay-append-thread 3
+		// release-responses 3
+		err = env.handleStorageFault(t, d)
 	case "log-level":
 		// Set the log level. NONE disables all output, including from the test
 		// harness (except errors).
@@ -192,6 +212,21 @@ func (env *InteractionEnv) Handle(t *testing.T, d datadriven.TestData) string {
 		// Example:
 		// report-unreachable 1 2
 		err = env.handleReportUnreachable(t, d)
+	case "store-liveness", "grant-support", "withdraw-support", "bump-epoch",
+		"set-time", "set-clock-skew":
+		// Script and print the mock store liveness fabric shared by the nodes
+		// added with mock-store-liveness=true.
+		// Stores are identified by node IDs, and times are wall times.
+		//
+		// Example:
+		//
+		// grant-support <supporter> <supported> expiration=10
+		// withdraw-support <supporter> <supported>
+		// bump-epoch <supporter> <supported>
+		// set-time 5
+		// set-clock-skew <id> skew=2
+		// store-liveness
+		err = env.handleStoreLiveness(t, d)
 	default:
 		err = fmt.Errorf("unknown command")
 	}
@@ -32,6 +32,7 @@ func (env *InteractionEnv) handleAddNodes(t *testing.T, d datadriven.TestData) e
 	n := firstAsInt(t, d)
 	var snap pb.Snapshot
 	cfg := raftConfigStub()
+	var mockStoreLiveness bool
 	for _, arg := range d.CmdArgs[1:] {
 		for i := range arg.Vals {
 			switch arg.Key {
@@ -64,10 +65,12 @@ func (env *InteractionEnv) handleAddNodes(t *testing.T, d datadriven.TestData) e
 				arg.Scan(t, i, &cfg.DisableConfChangeValidation)
 			case "step-down-on-removal":
 				arg.Scan(t, i, &cfg.StepDownOnRemoval)
+			case "mock-store-liveness":
+				arg.Scan(t, i, &mockStoreLiveness)
 			}
 		}
 	}
-	return env.AddNodes(n, cfg, snap)
+	return env.addNodes(n, cfg, snap, mockStoreLiveness)
 }
 
 type snapOverrideStorage struct {
@@ -87,6 +90,15 @@ var _ raft.Storage = snapOverrideStorage{}
 // AddNodes adds n new nodes initialized from the given snapshot (which may be
 // empty), and using the cfg as template. They will be assigned consecutive IDs.
 func (env *InteractionEnv) AddNodes(n int, cfg raft.Config, snap pb.Snapshot) error {
+	return env.addNodes(n, cfg, snap, false /* mockStoreLiveness */)
+}
+
+// addNodes is like AddNodes. If mockStoreLiveness is set, the nodes whose
+// StoreLiveness is not set by InteractionOpts.OnConfig use the mock store
+// liveness fabric of the env.
+func (env *InteractionEnv) addNodes(
+	n int, cfg raft.Config, snap pb.Snapshot, mockStoreLiveness bool,
+) error {
 	bootstrap := !reflect.DeepEqual(snap, pb.Snapshot{})
 	for i := 0; i < n; i++ {
 		id := pb.PeerID(1 + len(env.Nodes))
@@ -137,6 +149,9 @@ func (env *InteractionEnv) AddNodes(n int, cfg raft.Config, snap pb.Snapshot) er
 		if cfg.Logger != nil {
 			return errors.New("OnConfig must not set Logger")
 		}
+		if mockStoreLiveness && cfg.StoreLiveness == nil {
+			cfg.StoreLiveness = env.Fabric.StoreLiveness(uint64(id))
+		}
 		cfg.Logger = env.Output
 
 		rn, err := raft.NewRawNode(&cfg)
@@ -47,6 +47,12 @@ func (env *InteractionEnv) handleProcessAppendThread(t *testing.T, d datadriven.
 // ProcessAppendThread runs processes a single message on the "append" thread of
 // the node with the given index.
 func (env *InteractionEnv) ProcessAppendThread(idx int) error {
+	return env.processAppendThread(idx, noStorageFault)
+}
+
+// processAppendThread is like ProcessAppendThread, but injects the given fault
+// into the processing.
+func (env *InteractionEnv) processAppendThread(idx int, fault storageFault) error {
 	n := &env.Nodes[idx]
 	if len(n.AppendWork) == 0 {
 		env.Output.WriteString("no append work to perform")
@@ -57,6 +63,10 @@ func (env *InteractionEnv) ProcessAppendThread(idx int) error {
 
 	resps := m.Responses
 	m.Responses = nil
+	if fault == failStorageWork {
+		env.failStorageWork(m, resps)
+		return nil
+	}
 	env.Output.WriteString("Processing:\n")
 	env.Output.WriteString(raft.DescribeMessage(m, defaultEntryFormatter) + "\n")
 	st := raftpb.HardState{
@@ -73,12 +83,7 @@ func (env *InteractionEnv) ProcessAppendThread(idx int) error {
 	if err := processAppend(n, st, m.Entries, snap); err != nil {
 		return err
 	}
-
-	env.Output.WriteString("Responses:\n")
-	for _, m := range resps {
-		env.Output.WriteString(raft.DescribeMessage(m, defaultEntryFormatter) + "\n")
-	}
-	env.Messages = append(env.Messages, resps...)
+	env.sendStorageResponses(n, resps, fault)
 	return nil
 }
 
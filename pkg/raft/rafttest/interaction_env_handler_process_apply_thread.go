@@ -46,6 +46,12 @@ func (env *InteractionEnv) handleProcessApplyThread(t *testing.T, d datadriven.T
 // ProcessApplyThread runs processes a single message on the "apply" thread of
 // the node with the given index.
 func (env *InteractionEnv) ProcessApplyThread(idx int) error {
+	return env.processApplyThread(idx, noStorageFault)
+}
+
+// processApplyThread is like ProcessApplyThread, but injects the given fault
+// into the processing.
+func (env *InteractionEnv) processApplyThread(idx int, fault storageFault) error {
 	n := &env.Nodes[idx]
 	if len(n.ApplyWork) == 0 {
 		env.Output.WriteString("no apply work to perform")
@@ -56,17 +62,16 @@ func (env *InteractionEnv) ProcessApplyThread(idx int) error {
 
 	resps := m.Responses
 	m.Responses = nil
+	if fault == failStorageWork {
+		env.failStorageWork(m, resps)
+		return nil
+	}
 	env.Output.WriteString("Processing:\n")
 	env.Output.WriteString(raft.DescribeMessage(m, defaultEntryFormatter) + "\n")
 	if err := processApply(n, m.Entries); err != nil {
 		return err
 	}
-
-	env.Output.WriteString("Responses:\n")
-	for _, m := range resps {
-		env.Output.WriteString(raft.DescribeMessage(m, defaultEntryFormatter) + "\n")
-	}
-	env.Messages = append(env.Messages, resps...)
+	env.sendStorageResponses(n, resps, fault)
 	return nil
 }
 
@@ -0,0 +1,112 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package rafttest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/raft"
+	pb "github.com/cockroachdb/cockroach/pkg/raft/raftpb"
+	"github.com/cockroachdb/datadriven"
+)
+
+// storageFault is a fault injected into the processing of a MsgStorageAppend
+// or MsgStorageApply message on the append or apply thread.
+type storageFault int
+
+const (
+	// noStorageFault processes the message normally.
+	noStorageFault storageFault = iota
+	// failStorageWork drops the message without processing it, and drops its
+	// responses. This simulates a failed write which is never acknowledged.
+	failStorageWork
+	// delayStorageResponses processes the message, but withholds its responses
+	// until they are released with the release-responses command.
+	delayStorageResponses
+)
+
+func (env *InteractionEnv) handleStorageFault(t *testing.T, d datadriven.TestData) error {
+	idxs := nodeIdxs(t, d)
+	for _, idx := range idxs {
+		var err error
+		run := func() {
+			switch d.Cmd {
+			case "fail-append-thread":
+				err = env.processAppendThread(idx, failStorageWork)
+			case "delay-append-thread":
+				err = env.processAppendThread(idx, delayStorageResponses)
+			case "fail-apply-thread":
+				err = env.processApplyThread(idx, failStorageWork)
+			case "delay-apply-thread":
+				err = env.processApplyThread(idx, delayStorageResponses)
+			case "release-responses":
+				env.ReleaseDelayedResponses(idx)
+			default:
+				err = fmt.Errorf("unknown command %s", d.Cmd)
+			}
+		}
+		if len(idxs) > 1 {
+			fmt.Fprintf(env.Output, "> %d %s\n", idx+1, d.Cmd)
+			env.withIndent(run)
+		} else {
+			run()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// failStorageWork reports the storage work message which is dropped instead of
+// being processed, along with its responses.
+func (env *InteractionEnv) failStorageWork(m pb.Message, resps []pb.Message) {
+	env.Output.WriteString("Failed:\n")
+	env.Output.WriteString(raft.DescribeMessage(m, defaultEntryFormatter) + "\n")
+	env.Output.WriteString("Dropped responses:\n")
+	for _, m := range resps {
+		env.Output.WriteString(raft.DescribeMessage(m, defaultEntryFormatter) + "\n")
+	}
+}
+
+// sendStorageResponses sends the responses of a processed storage work message,
+// or withholds them if the delayStorageResponses fault is injected.
+func (env *InteractionEnv) sendStorageResponses(
+	n *Node, resps []pb.Message, fault storageFault,
+) {
+	if fault == delayStorageResponses {
+		env.Output.WriteString("Delayed responses:\n")
+		n.DelayedResponses = append(n.DelayedResponses, resps...)
+	} else {
+		env.Output.WriteString("Responses:\n")
+		env.Messages = append(env.Messages, resps...)
+	}
+	for _, m := range resps {
+		env.Output.WriteString(raft.DescribeMessage(m, defaultEntryFormatter) + "\n")
+	}
+}
+
+// ReleaseDelayedResponses sends the responses withheld by the delay-append-thread
+// and delay-apply-thread commands on the node with the given index.
+func (env *InteractionEnv) ReleaseDelayedResponses(idx int) {
+	n := &env.Nodes[idx]
+	if len(n.DelayedResponses) == 0 {
+		env.Output.WriteString("no delayed responses")
+		return
+	}
+	env.Output.WriteString("Released responses:\n")
+	for _, m := range n.DelayedResponses {
+		env.Output.WriteString(raft.DescribeMessage(m, defaultEntryFormatter) + "\n")
+	}
+	env.Messages = append(env.Messages, n.DelayedResponses...)
+	n.DelayedResponses = nil
+}
@@ -0,0 +1,82 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package rafttest
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/cockroach/pkg/util/hlc"
+	"github.com/cockroachdb/datadriven"
+)
+
+func (env *InteractionEnv) handleStoreLiveness(t *testing.T, d datadriven.TestData) error {
+	switch d.Cmd {
+	case "grant-support":
+		supporter, supported, err := supportPair(d)
+		if err != nil {
+			return err
+		}
+		expiration := hlc.MaxTimestamp
+		if d.HasArg("expiration") {
+			expiration = hlc.Timestamp{}
+			d.ScanArgs(t, "expiration", &expiration.WallTime)
+		}
+		env.Fabric.GrantSupport(supporter, supported, expiration)
+	case "withdraw-support":
+		supporter, supported, err := supportPair(d)
+		if err != nil {
+			return err
+		}
+		env.Fabric.WithdrawSupport(supporter, supported)
+	case "bump-epoch":
+		supporter, supported, err := supportPair(d)
+		if err != nil {
+			return err
+		}
+		env.Fabric.BumpEpoch(supporter, supported)
+	case "set-time":
+		wall, err := strconv.ParseInt(d.CmdArgs[0].Key, 10, 64)
+		if err != nil {
+			return err
+		}
+		env.Fabric.SetTime(hlc.Timestamp{WallTime: wall})
+	case "set-clock-skew":
+		id, err := strconv.ParseUint(d.CmdArgs[0].Key, 10, 64)
+		if err != nil {
+			return err
+		}
+		var skew int64
+		d.ScanArgs(t, "skew", &skew)
+		env.Fabric.SetClockSkew(id, time.Duration(skew))
+	}
+	env.Output.WriteString(env.Fabric.String())
+	return nil
+}
+
+// supportPair parses the IDs of the supporter and supported stores from the
+// first two arguments.
+func supportPair(d datadriven.TestData) (supporter, supported uint64, _ error) {
+	if len(d.CmdArgs) < 2 {
+		return 0, 0, errors.New("must specify the supporter and supported IDs")
+	}
+	supporter, err := strconv.ParseUint(d.CmdArgs[0].Key, 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	supported, err = strconv.ParseUint(d.CmdArgs[1].Key, 10, 64)
+	if err != nil {
+		return 0, 0, err
+	}
+	return supporter, supported, nil
+}
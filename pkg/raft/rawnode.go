@@ -19,6 +19,7 @@ package raft
 
 import (
 	"errors"
+	"fmt"
 
 	pb "github.com/cockroachdb/cockroach/pkg/raft/raftpb"
 	"github.com/cockroachdb/cockroach/pkg/raft/tracker"
@@ -31,17 +32,58 @@ var ErrStepLocalMsg = errors.New("raft: cannot step raft local message")
 // but there is no peer found in raft.trk for that node.
 var ErrStepPeerNotFound = errors.New("raft: cannot step as peer not found")
 
+// ErrPeerNotFound is returned when the given peer is not found in raft.trk.
+var ErrPeerNotFound = errors.New("raft: peer not found")
+
+// ErrNotDrained is returned by RawNode.ExportState when the group is not frozen,
+// or has Ready work which is not yet fully handled.
+var ErrNotDrained = errors.New("raft: group is not frozen and drained")
+
+// GroupFrozenError is returned when proposing to a frozen group, see
+// RawNode.Freeze. The proposal was not made.
+type GroupFrozenError struct {
+	// Index is the last index of the log when the group was frozen.
+	Index uint64
+}
+
+func (e *GroupFrozenError) Error() string {
+	return fmt.Sprintf("raft: group is frozen at index %d", e.Index)
+}
+
+// FrozenState is the replicated state of a frozen and drained group, see
+// RawNode.ExportState.
+type FrozenState struct {
+	// HardState is the durable HardState of the group.
+	HardState pb.HardState
+	// ConfState is the configuration of the group, as of the applied index.
+	ConfState pb.ConfState
+	// LastIndex and LastTerm identify the last entry of the log. All the entries
+	// up to the HardState.Commit index are durable and applied.
+	LastIndex, LastTerm uint64
+}
+
 // RawNode is a thread-unsafe Node.
 // The methods of this struct correspond to the methods of Node and are described
 // more fully there.
 type RawNode struct {
 	raft               *raft
 	asyncStorageWrites bool
+	reuseReady         bool
 
 	// Mutable fields.
 	prevSoftSt     *SoftState
 	prevHardSt     pb.HardState
 	stepsOnAdvance []pb.Message
+
+	// softStBufs and readyMsgs back the Ready.SoftState and Ready.Messages
+	// fields when reuseReady is set. The SoftState buffers are used in turns, so
+	// that prevSoftSt is never overwritten.
+	softStBufs [2]SoftState
+	readyMsgs  []pb.Message
+	// msgsCap and readyMsgsCap are the capacities of the raft.msgs and readyMsgs
+	// buffers as of the last accepted Ready, used to count their allocations.
+	msgsCap, readyMsgsCap int
+	allocs                ReadyAllocStats
 }
 
 // NewRawNode instantiates a RawNode from the given configuration.
@@ -57,12 +99,26 @@ func NewRawNode(config *Config) (*RawNode, error) {
 		raft: r,
 	}
 	rn.asyncStorageWrites = config.AsyncStorageWrites
+	rn.reuseReady = config.ReuseReady
 	ss := r.softState()
 	rn.prevSoftSt = &ss
 	rn.prevHardSt = r.hardState()
 	return rn, nil
 }
 
+// SetLogger replaces the Logger used by this RawNode, e.g. to attach updated
+// logging tags to a raft group whose identity changed after creation. A nil
+// Logger reverts to the global logger (see SetLogger).
+//
+// Like all RawNode methods, SetLogger must not be called concurrently with
+// other methods of this RawNode.
+func (rn *RawNode) SetLogger(l Logger) {
+	if l == nil {
+		l = getLogger()
+	}
+	rn.raft.setLogger(l)
+}
+
 // Tick advances the internal logical clock by a single tick.
 func (rn *RawNode) Tick() {
 	rn.raft.tick()
@@ -95,6 +151,19 @@ func (rn *RawNode) ProposeConfChange(cc pb.ConfChangeI) error {
 	return rn.raft.Step(m)
 }
 
+// ProposeLeaveJoint proposes to transition out of the active joint
+// configuration. A leader can use it to recover a configuration that is stuck
+// in the joint state, e.g. because the automatic transition out of it was
+// missed across leadership changes, or because the configuration was not
+// marked AutoLeave.
+//
+// Returns ErrProposalDropped if this node is not the leader, ErrNotJoint if the
+// active configuration is not joint, and ErrPendingConfChange if a previously
+// proposed configuration change may still be unapplied.
+func (rn *RawNode) ProposeLeaveJoint() error {
+	return rn.raft.proposeLeaveJoint()
+}
+
 // ApplyConfChange applies a config change to the local node. The app must call
 // this when it applies a configuration change, except when it decides to reject
 // the configuration change, in which case no call must take place.
@@ -115,6 +184,26 @@ func (rn *RawNode) Step(m pb.Message) error {
 	return rn.raft.Step(m)
 }
 
+// StepBatch advances the state machine using the given messages. It is
+// equivalent to calling Step for each message, but amortizes the per-message
+// overhead. In particular, the leader checks whether the commit index can
+// advance, and broadcasts it, once per batch rather than once per message.
+//
+// All messages are stepped even if some of them fail. The first error, if any,
+// is returned.
+func (rn *RawNode) StepBatch(msgs []pb.Message) error {
+	var err error
+	rn.raft.stepBatching = true
+	for i := range msgs {
+		if stepErr := rn.Step(msgs[i]); stepErr != nil && err == nil {
+			err = stepErr
+		}
+	}
+	rn.raft.stepBatching = false
+	rn.raft.finishStepBatch()
+	return err
+}
+
 // Ready returns the outstanding work that the application needs to handle. This
 // includes appending and applying entries or a snapshot, updating the HardState,
 // and sending messages. The returned Ready() *must* be handled and subsequently
@@ -135,10 +224,24 @@ func (rn *RawNode) readyWithoutAccept() Ready {
 		CommittedEntries: r.raftLog.nextCommittedEnts(rn.applyUnstableEntries()),
 		Messages:         r.msgs,
 	}
+	if rn.reuseReady {
+		// Copy the messages, so that raft can reuse its buffer for the messages
+		// sent while this Ready is being handled.
+		rd.Messages = append(rn.readyMsgs[:0], r.msgs...)
+	}
 	if softSt := r.softState(); !softSt.equal(rn.prevSoftSt) {
-		// Allocate only when SoftState changes.
-		escapingSoftSt := softSt
-		rd.SoftState = &escapingSoftSt
+		if rn.reuseReady {
+			buf := &rn.softStBufs[0]
+			if buf == rn.prevSoftSt {
+				buf = &rn.softStBufs[1]
+			}
+			*buf = softSt
+			rd.SoftState = buf
+		} else {
+			// Allocate only when SoftState changes.
+			escapingSoftSt := softSt
+			rd.SoftState = &escapingSoftSt
+		}
 	}
 	if hardSt := r.hardState(); !isHardStateEqual(hardSt, rn.prevHardSt) {
 		rd.HardState = hardSt
@@ -365,6 +468,7 @@ func newStorageApplyRespMsg(r *raft, ents []pb.Entry) pb.Message {
 // ahead and handle a Ready. Nothing must alter the state of the RawNode between
 // this call and the prior call to Ready().
 func (rn *RawNode) acceptReady(rd Ready) {
+	rn.countReadyAllocs(rd)
 	if rd.SoftState != nil {
 		rn.prevSoftSt = rd.SoftState
 	}
@@ -389,7 +493,15 @@ func (rn *RawNode) acceptReady(rd Ready) {
 			rn.stepsOnAdvance = append(rn.stepsOnAdvance, m)
 		}
 	}
-	rn.raft.msgs = nil
+	if rn.reuseReady {
+		// The messages have been copied to rd.Messages, see readyWithoutAccept.
+		clear(rn.raft.msgs)
+		rn.raft.msgs = rn.raft.msgs[:0]
+		rn.readyMsgs = rd.Messages[:0]
+	} else {
+		rn.raft.msgs = nil
+	}
+	rn.msgsCap, rn.readyMsgsCap = cap(rn.raft.msgs), cap(rn.readyMsgs)
 	rn.raft.msgsAfterAppend = nil
 	rn.raft.raftLog.acceptUnstable()
 	if len(rd.CommittedEntries) > 0 {
@@ -399,6 +511,26 @@ func (rn *RawNode) acceptReady(rd Ready) {
 	}
 }
 
+// countReadyAllocs updates the allocation counters with the allocations made
+// for the given Ready, which is being accepted.
+func (rn *RawNode) countReadyAllocs(rd Ready) {
+	rn.allocs.Readies++
+	if rd.SoftState != nil && !rn.reuseReady {
+		rn.allocs.SoftStates++
+	}
+	if cap(rn.raft.msgs) != rn.msgsCap {
+		rn.allocs.MessageBufs++
+	}
+	if rn.reuseReady {
+		if cap(rd.Messages) != rn.readyMsgsCap {
+			rn.allocs.MessageBufs++
+		}
+	} else if cap(rd.Messages) != cap(rn.raft.msgs) {
+		// Ready.Messages outgrew the raft.msgs buffer.
+		rn.allocs.MessageBufs++
+	}
+}
+
 // applyUnstableEntries returns whether entries are allowed to be applied once
 // they are known to be committed but before they have been written locally to
 // stable storage.
@@ -447,10 +579,36 @@ func (rn *RawNode) Advance(_ Ready) {
 	rn.stepsOnAdvance = rn.stepsOnAdvance[:0]
 }
 
+// WaitFor returns a channel which is closed once the watermark of the given
+// kind (the committed or applied index) reaches the given index. If it already
+// has, the returned channel is closed. This allows other goroutines to wait for
+// an index to commit or apply, without polling the Status.
+//
+// The channel is closed by the RawNode method which advances the watermark. A
+// channel whose index is never reached is retained until the RawNode is
+// garbage collected.
+func (rn *RawNode) WaitFor(kind WatermarkKind, index uint64) <-chan struct{} {
+	l := rn.raft.raftLog
+	if l.watermarks == nil {
+		l.watermarks = &watermarks{}
+	}
+	var cur uint64
+	switch kind {
+	case WatermarkCommitted:
+		cur = l.committed
+	case WatermarkApplied:
+		cur = l.applied
+	default:
+		rn.raft.logger.Panicf("unknown watermark kind %d", kind)
+	}
+	return l.watermarks.wait(kind, index, cur)
+}
+
 // Status returns the current status of the given group. This allocates, see
 // SparseStatus, BasicStatus and WithProgress for allocation-friendlier choices.
 func (rn *RawNode) Status() Status {
 	status := getStatus(rn.raft)
+	status.ReadyAllocs = rn.allocs
 	return status
 }
 
@@ -491,6 +649,59 @@ func (rn *RawNode) WithProgress(visitor func(id pb.PeerID, typ ProgressType, pr
 	withProgress(rn.raft, visitor)
 }
 
+// SetPeerMetadata attaches the given application-defined metadata to the
+// Progress of the given peer, replacing the previously attached metadata. The
+// metadata can be inspected via WithProgress and Status, and is retained for as
+// long as the peer is part of the configuration. Returns ErrPeerNotFound if the
+// peer is not in the configuration.
+func (rn *RawNode) SetPeerMetadata(id pb.PeerID, md tracker.PeerMetadata) error {
+	pr := rn.raft.trk.Progress(id)
+	if pr == nil {
+		return ErrPeerNotFound
+	}
+	pr.Metadata = md
+	return nil
+}
+
+// Freeze freezes the group in preparation for an operation which replaces it,
+// such as a range split or merge performed by the layers above raft. A frozen
+// group rejects all proposals with a *GroupFrozenError, including the ones
+// forwarded by followers, but otherwise keeps operating, so that the in-flight
+// Ready work can be drained. Once it is, ExportState can be used to obtain the
+// replicated state of the group. Freezing a frozen group is a no-op.
+func (rn *RawNode) Freeze() {
+	if rn.raft.frozen == nil {
+		rn.raft.frozen = &GroupFrozenError{Index: rn.raft.raftLog.lastIndex()}
+	}
+}
+
+// Unfreeze undoes Freeze, e.g. if the operation which required freezing the
+// group was aborted.
+func (rn *RawNode) Unfreeze() {
+	rn.raft.frozen = nil
+}
+
+// ExportState returns the replicated state of a frozen group. Returns
+// ErrNotDrained if the group is not frozen, or if it still has Ready work to
+// be handled, i.e. unstable entries or snapshot to write, committed entries to
+// apply, or messages to send. The application drains the group by handling
+// the Ready structs until ExportState succeeds.
+func (rn *RawNode) ExportState() (FrozenState, error) {
+	r := rn.raft
+	l := r.raftLog
+	if r.frozen == nil || rn.HasReady() || len(rn.stepsOnAdvance) != 0 ||
+		len(l.unstable.entries) != 0 || l.hasNextOrInProgressSnapshot() || l.applied < l.committed {
+		return FrozenState{}, ErrNotDrained
+	}
+	last := l.lastEntryID()
+	return FrozenState{
+		HardState: r.hardState(),
+		ConfState: r.config.ConfState(),
+		LastIndex: last.index,
+		LastTerm:  last.term,
+	}, nil
+}
+
 // ReportUnreachable reports the given node is not reachable for the last send.
 func (rn *RawNode) ReportUnreachable(id pb.PeerID) {
 	_ = rn.raft.Step(pb.Message{Type: pb.MsgUnreachable, From: id})
@@ -20,7 +20,9 @@ package raft
 import (
 	"context"
 	"fmt"
+	"log"
 	"math"
+	"strings"
 	"testing"
 
 	"github.com/cockroachdb/cockroach/pkg/raft/quorum"
@@ -701,6 +703,267 @@ func TestRawNodeStatus(t *testing.T) {
 	require.Equal(t, expCfg, status.Config)
 }
 
+// TestRawNodePeerMetadata tests that the application metadata attached to the
+// peers' Progress is visible via WithProgress, and survives term changes.
+func TestRawNodePeerMetadata(t *testing.T) {
+	type storeMeta struct{ storeID int }
+
+	s := newTestMemoryStorage(withPeers(1, 2))
+	rn, err := NewRawNode(newTestConfig(1, 10, 1, s))
+	require.NoError(t, err)
+	require.NoError(t, rn.SetPeerMetadata(1, storeMeta{storeID: 10}))
+	require.NoError(t, rn.SetPeerMetadata(2, storeMeta{storeID: 20}))
+	require.Equal(t, ErrPeerNotFound, rn.SetPeerMetadata(3, storeMeta{storeID: 30}))
+
+	check := func() {
+		t.Helper()
+		got := map[pb.PeerID]tracker.PeerMetadata{}
+		rn.WithProgress(func(id pb.PeerID, _ ProgressType, pr tracker.Progress) {
+			got[id] = pr.Metadata
+		})
+		require.Equal(t, map[pb.PeerID]tracker.PeerMetadata{
+			1: storeMeta{storeID: 10},
+			2: storeMeta{storeID: 20},
+		}, got)
+	}
+	check()
+
+	// The Progress is reset when the term changes, but the metadata is retained.
+	rn.raft.becomeCandidate()
+	rn.raft.becomeLeader()
+	check()
+}
+
+// TestRawNodeSetLogger tests that the logger of a RawNode can be replaced at
+// runtime.
+func TestRawNodeSetLogger(t *testing.T) {
+	s := newTestMemoryStorage(withPeers(1))
+	cfg := newTestConfig(1, 10, 1, s)
+	cfg.Logger = discardLogger
+	rn, err := NewRawNode(cfg)
+	require.NoError(t, err)
+
+	var buf strings.Builder
+	rn.SetLogger(&DefaultLogger{Logger: log.New(&buf, "", 0)})
+	require.NoError(t, rn.Campaign())
+	require.Contains(t, buf.String(), "became candidate at term 1")
+
+	rn.SetLogger(nil)
+	require.Equal(t, getLogger(), rn.raft.logger)
+	require.Equal(t, getLogger(), rn.raft.raftLog.logger)
+	require.Equal(t, getLogger(), rn.raft.raftLog.unstable.logger)
+}
+
+// TestRawNodeStepBatch tests that StepBatch steps all the messages, and that
+// the leader advances the commit index once at the end of the batch.
+func TestRawNodeStepBatch(t *testing.T) {
+	s := newTestMemoryStorage(withPeers(1, 2, 3))
+	rn := newTestRawNode(1, 10, 1, s)
+	r := rn.raft
+	r.becomeCandidate()
+	r.becomeLeader()
+	for i := 0; i < 3; i++ {
+		mustAppendEntry(r, pb.Entry{Data: []byte("foo")})
+	}
+	r.readMessages()
+	last := r.raftLog.lastIndex()
+	require.Zero(t, r.raftLog.committed)
+
+	err := rn.StepBatch([]pb.Message{
+		{From: 2, To: 1, Type: pb.MsgAppResp, Index: last - 1},
+		{From: 4, To: 1, Type: pb.MsgAppResp, Index: last},
+		{From: 3, To: 1, Type: pb.MsgAppResp, Index: last},
+	})
+	// The message from the unknown peer fails, but the others are stepped.
+	require.Equal(t, ErrStepPeerNotFound, err)
+	require.Equal(t, last-1, r.trk.Progress(2).Match)
+	require.Equal(t, last, r.trk.Progress(3).Match)
+	require.Equal(t, last, r.raftLog.committed)
+	require.False(t, r.stepBatching)
+	require.False(t, r.commitCheckPending)
+
+	// The new commit index has been broadcast to both followers.
+	for _, id := range []pb.PeerID{2, 3} {
+		var commit uint64
+		for _, m := range r.msgs {
+			if m.To == id && m.Type == pb.MsgApp {
+				commit = max(commit, m.Commit)
+			}
+		}
+		require.Equal(t, last, commit, "peer %d", id)
+	}
+}
+
+// TestRawNodeProposeLeaveJoint tests that the leader can propose to leave a
+// joint configuration which is not left automatically.
+func TestRawNodeProposeLeaveJoint(t *testing.T) {
+	s := newTestMemoryStorage(withPeers(1))
+	rn := newTestRawNode(1, 10, 1, s)
+	require.Equal(t, ErrProposalDropped, rn.ProposeLeaveJoint())
+
+	require.NoError(t, rn.Campaign())
+	for rn.HasReady() {
+		rd := rn.Ready()
+		require.NoError(t, s.Append(rd.Entries))
+		rn.Advance(rd)
+	}
+	require.Equal(t, StateLeader, rn.raft.state)
+	require.Equal(t, ErrNotJoint, rn.ProposeLeaveJoint())
+
+	// Enter a joint configuration which is not left automatically.
+	rn.ApplyConfChange(pb.ConfChangeV2{
+		Transition: pb.ConfChangeTransitionJointExplicit,
+		Changes:    []pb.ConfChangeSingle{{Type: pb.ConfChangeAddNode, NodeID: 2}},
+	})
+	require.NotEmpty(t, rn.raft.config.Voters[1])
+	require.False(t, rn.raft.config.AutoLeave)
+
+	require.NoError(t, rn.ProposeLeaveJoint())
+	last, err := rn.raft.raftLog.entries(rn.raft.raftLog.lastIndex(), noLimit)
+	require.NoError(t, err)
+	require.Len(t, last, 1)
+	require.Equal(t, pb.EntryConfChangeV2, last[0].Type)
+	var cc pb.ConfChangeV2
+	require.NoError(t, cc.Unmarshal(last[0].Data))
+	require.True(t, cc.LeaveJoint())
+
+	// The proposed config change has not been applied yet.
+	require.Equal(t, ErrPendingConfChange, rn.ProposeLeaveJoint())
+}
+
+// TestRawNodeReuseReady tests that Config.ReuseReady does not change the
+// contents of Ready structs, and reduces the allocations made to assemble them.
+func TestRawNodeReuseReady(t *testing.T) {
+	run := func(reuse bool) (msgs [][]pb.Message, allocs ReadyAllocStats) {
+		s := newTestMemoryStorage(withPeers(1, 2, 3))
+		cfg := newTestConfig(1, 10, 1, s)
+		cfg.ReuseReady = reuse
+		rn, err := NewRawNode(cfg)
+		require.NoError(t, err)
+		handle := func() {
+			rd := rn.Ready()
+			msgs = append(msgs, append([]pb.Message(nil), rd.Messages...))
+			require.NoError(t, s.Append(rd.Entries))
+			if !IsEmptyHardState(rd.HardState) {
+				require.NoError(t, s.SetHardState(rd.HardState))
+			}
+			rn.Advance(rd)
+		}
+
+		require.NoError(t, rn.Campaign())
+		handle()
+		require.NoError(t, rn.Step(pb.Message{
+			From: 2, To: 1, Type: pb.MsgVoteResp, Term: rn.raft.Term,
+		}))
+		handle()
+		require.Equal(t, StateLeader, rn.raft.state)
+		for i := 0; i < 10; i++ {
+			require.NoError(t, rn.Propose([]byte("foo")))
+			handle()
+		}
+		return msgs, rn.Status().ReadyAllocs
+	}
+
+	msgs, allocs := run(false /* reuse */)
+	reuseMsgs, reuseAllocs := run(true /* reuse */)
+	require.Equal(t, msgs, reuseMsgs)
+	require.Equal(t, allocs.Readies, reuseAllocs.Readies)
+	require.NotZero(t, allocs.SoftStates)
+	require.Zero(t, reuseAllocs.SoftStates)
+	require.Less(t, reuseAllocs.MessageBufs, allocs.MessageBufs)
+}
+
+// TestRawNodeWaitFor tests that the RawNode notifies the waiters of the commit
+// and applied indices once they are reached.
+func TestRawNodeWaitFor(t *testing.T) {
+	s := newTestMemoryStorage(withPeers(1))
+	rn, err := NewRawNode(newTestConfig(1, 10, 1, s))
+	require.NoError(t, err)
+	closed := func(ch <-chan struct{}) bool {
+		select {
+		case <-ch:
+			return true
+		default:
+			return false
+		}
+	}
+	handle := func() {
+		rd := rn.Ready()
+		require.NoError(t, s.Append(rd.Entries))
+		rn.Advance(rd)
+	}
+
+	require.True(t, closed(rn.WaitFor(WatermarkCommitted, 0)))
+	commit2 := rn.WaitFor(WatermarkCommitted, 2)
+	apply2 := rn.WaitFor(WatermarkApplied, 2)
+	apply3 := rn.WaitFor(WatermarkApplied, 3)
+	require.Equal(t, apply2, rn.WaitFor(WatermarkApplied, 2))
+	require.Equal(t, 1, rn.raft.raftLog.watermarks.count(WatermarkCommitted))
+	require.Equal(t, 2, rn.raft.raftLog.watermarks.count(WatermarkApplied))
+
+	// Become the leader and commit the empty entry at index 1.
+	require.NoError(t, rn.Campaign())
+	for rn.HasReady() {
+		handle()
+	}
+	require.Equal(t, uint64(1), rn.raft.raftLog.applied)
+	require.False(t, closed(commit2))
+	require.False(t, closed(apply2))
+
+	// Commit and apply the entry at index 2.
+	require.NoError(t, rn.Propose([]byte("foo")))
+	handle()
+	require.True(t, closed(commit2))
+	require.False(t, closed(apply2))
+	handle()
+	require.True(t, closed(apply2))
+	require.False(t, closed(apply3))
+	require.Zero(t, rn.raft.raftLog.watermarks.count(WatermarkCommitted))
+	require.Equal(t, 1, rn.raft.raftLog.watermarks.count(WatermarkApplied))
+}
+
+// TestRawNodeFreeze tests that a frozen RawNode rejects proposals, and exports
+// its state once the in-flight Ready work is drained.
+func TestRawNodeFreeze(t *testing.T) {
+	s := newTestMemoryStorage(withPeers(1))
+	rn, err := NewRawNode(newTestConfig(1, 10, 1, s))
+	require.NoError(t, err)
+	handle := func() {
+		rd := rn.Ready()
+		require.NoError(t, s.Append(rd.Entries))
+		rn.Advance(rd)
+	}
+
+	require.NoError(t, rn.Campaign())
+	for rn.HasReady() {
+		handle()
+	}
+	_, err = rn.ExportState()
+	require.Equal(t, ErrNotDrained, err)
+
+	// Freeze the group with a proposal in flight.
+	require.NoError(t, rn.Propose([]byte("foo")))
+	rn.Freeze()
+	var frozenErr *GroupFrozenError
+	require.ErrorAs(t, rn.Propose([]byte("bar")), &frozenErr)
+	require.Equal(t, uint64(2), frozenErr.Index)
+	_, err = rn.ExportState()
+	require.Equal(t, ErrNotDrained, err)
+
+	for rn.HasReady() {
+		handle()
+	}
+	st, err := rn.ExportState()
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), st.HardState.Commit)
+	require.Equal(t, uint64(2), st.LastIndex)
+	require.Equal(t, uint64(1), st.LastTerm)
+	require.Equal(t, []pb.PeerID{1}, st.ConfState.Voters)
+
+	rn.Unfreeze()
+	require.NoError(t, rn.Propose([]byte("bar")))
+}
+
 // TestRawNodeCommitPaginationAfterRestart is the RawNode version of
 // TestNodeCommitPaginationAfterRestart. The anomaly here was even worse as the
 // Raft group would forget to apply entries:
@@ -0,0 +1,185 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package raft
+
+import (
+	"math"
+	"math/bits"
+
+	pb "github.com/cockroachdb/cockroach/pkg/raft/raftpb"
+)
+
+// histogramBuckets is the number of buckets in a Histogram.
+const histogramBuckets = 32
+
+// Histogram is a compact histogram of non-negative integer samples. The bucket
+// bounds grow exponentially: bucket 0 counts the zero samples, and bucket i > 0
+// counts the samples in [2^(i-1), 2^i). The last bucket also counts all the
+// larger samples.
+type Histogram struct {
+	Buckets [histogramBuckets]uint64
+	// Count is the number of samples, and Sum is their sum.
+	Count uint64
+	Sum   uint64
+	// Max is the largest sample.
+	Max uint64
+}
+
+// Record adds a sample to the histogram.
+func (h *Histogram) Record(v uint64) {
+	h.Buckets[min(bits.Len64(v), histogramBuckets-1)]++
+	h.Count++
+	h.Sum += v
+	h.Max = max(h.Max, v)
+}
+
+// Quantile returns an upper bound on the q-quantile of the samples, for q in
+// [0, 1]. The bound is the upper bound of the bucket containing the quantile,
+// capped at the largest sample. Returns 0 if the histogram is empty.
+func (h *Histogram) Quantile(q float64) uint64 {
+	if h.Count == 0 {
+		return 0
+	}
+	rank := max(uint64(math.Ceil(q*float64(h.Count))), 1)
+	var seen uint64
+	for i, n := range h.Buckets[:histogramBuckets-1] {
+		if seen += n; seen >= rank {
+			return min(uint64(1)<<i-1, h.Max)
+		}
+	}
+	return h.Max
+}
+
+// ReplicationStats contains the replication statistics sampled by the leader
+// since it was elected. See Config.SampleReplicationStats.
+type ReplicationStats struct {
+	// MsgAppSizes is the histogram of the sizes of the entries carried by the
+	// MsgApp messages sent to the followers. The sizes are computed in the same
+	// way as for Config.MaxInflightBytes.
+	MsgAppSizes Histogram
+	// AckLatencies contains, for each follower, the histogram of the number of
+	// ticks between sending a sampled MsgApp, and receiving its acknowledgement.
+	AckLatencies map[pb.PeerID]Histogram
+}
+
+// SlowestFollower returns the follower with the highest median acknowledgement
+// latency, which is likely to be the replication bottleneck. Returns None if no
+// latencies have been sampled.
+func (s *ReplicationStats) SlowestFollower() pb.PeerID {
+	slowest, latency := None, uint64(0)
+	for id, h := range s.AckLatencies {
+		if h.Count == 0 {
+			continue
+		}
+		l := h.Quantile(0.5)
+		if slowest == None || l > latency || (l == latency && id < slowest) {
+			slowest, latency = id, l
+		}
+	}
+	return slowest
+}
+
+// replicationSampler samples the replication statistics on the leader. At most
+// one MsgApp per follower is in flight for the purpose of the latency sampling,
+// so that the memory footprint does not depend on the replication pipeline.
+type replicationSampler struct {
+	msgAppSizes Histogram
+	peers       map[pb.PeerID]*peerSample
+}
+
+// peerSample tracks the sampled latencies of a follower.
+type peerSample struct {
+	latencies Histogram
+	// index is the last entry index of the sampled in-flight MsgApp, and tick is
+	// the tick at which it was sent. The index is zero if there is no sample in
+	// flight.
+	index uint64
+	tick  uint64
+	// loggedTick is the tick at which a slow acknowledgement from this follower
+	// was last logged.
+	loggedTick uint64
+}
+
+func (s *replicationSampler) peer(id pb.PeerID) *peerSample {
+	ps, ok := s.peers[id]
+	if !ok {
+		if s.peers == nil {
+			s.peers = map[pb.PeerID]*peerSample{}
+		}
+		ps = &peerSample{}
+		s.peers[id] = ps
+	}
+	return ps
+}
+
+// sentMsgApp records a MsgApp sent to the given follower, carrying the entries
+// up to the given index of the given size.
+func (s *replicationSampler) sentMsgApp(to pb.PeerID, index, size, tick uint64) {
+	s.msgAppSizes.Record(size)
+	if ps := s.peer(to); ps.index == 0 {
+		ps.index, ps.tick = index, tick
+	}
+}
+
+// acked records an acknowledgement of the entries up to the given index by the
+// given follower. Returns the latency of the sampled MsgApp if the
+// acknowledgement covers it, or -1 otherwise.
+func (s *replicationSampler) acked(from pb.PeerID, index, tick uint64) int64 {
+	ps, ok := s.peers[from]
+	if !ok || ps.index == 0 || index < ps.index {
+		return -1
+	}
+	latency := tick - ps.tick
+	ps.latencies.Record(latency)
+	ps.index = 0
+	return int64(latency)
+}
+
+func (s *replicationSampler) stats() ReplicationStats {
+	st := ReplicationStats{
+		MsgAppSizes:  s.msgAppSizes,
+		AckLatencies: make(map[pb.PeerID]Histogram, len(s.peers)),
+	}
+	for id, ps := range s.peers {
+		st.AckLatencies[id] = ps.latencies
+	}
+	return st
+}
+
+// sampleMsgApp records a MsgApp with the given entries sent to the given
+// follower, if sampling is enabled.
+func (r *raft) sampleMsgApp(to pb.PeerID, entries []pb.Entry, size uint64) {
+	if r.replStats == nil || len(entries) == 0 {
+		return
+	}
+	r.replStats.sentMsgApp(to, entries[len(entries)-1].Index, size, r.ticks)
+}
+
+// sampleAck records an acknowledgement of the log up to the given index by the
+// given follower, if sampling is enabled. Acknowledgements which took at least
+// an election timeout are logged, at most once per election timeout for each
+// follower.
+func (r *raft) sampleAck(from pb.PeerID, index uint64) {
+	if r.replStats == nil || from == r.id {
+		return
+	}
+	latency := r.replStats.acked(from, index, r.ticks)
+	if latency < int64(r.electionTimeout) {
+		return
+	}
+	ps := r.replStats.peer(from)
+	if ps.loggedTick != 0 && r.ticks-ps.loggedTick < uint64(r.electionTimeout) {
+		return
+	}
+	ps.loggedTick = r.ticks
+	r.logger.Warningf("%x: %x acknowledged the log up to index %d after %d ticks",
+		r.id, from, index, latency)
+}
@@ -0,0 +1,88 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package raft
+
+import (
+	"math"
+	"testing"
+
+	pb "github.com/cockroachdb/cockroach/pkg/raft/raftpb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistogram(t *testing.T) {
+	var h Histogram
+	require.Zero(t, h.Quantile(0.5))
+
+	for _, v := range []uint64{0, 1, 2, 3, 5, 100, math.MaxUint64} {
+		h.Record(v)
+	}
+	require.Equal(t, uint64(7), h.Count)
+	require.Equal(t, uint64(math.MaxUint64), h.Max)
+	require.Equal(t, uint64(1), h.Buckets[0])
+	require.Equal(t, uint64(1), h.Buckets[1])
+	require.Equal(t, uint64(2), h.Buckets[2])
+	require.Equal(t, uint64(1), h.Buckets[3])
+	require.Equal(t, uint64(1), h.Buckets[7])
+	require.Equal(t, uint64(1), h.Buckets[histogramBuckets-1])
+
+	require.Equal(t, uint64(0), h.Quantile(0))
+	require.Equal(t, uint64(3), h.Quantile(0.5))
+	require.Equal(t, uint64(127), h.Quantile(0.8))
+	require.Equal(t, uint64(math.MaxUint64), h.Quantile(1))
+
+	h = Histogram{}
+	h.Record(100)
+	require.Equal(t, uint64(100), h.Quantile(1))
+}
+
+func TestSampleReplicationStats(t *testing.T) {
+	cfg := newTestConfig(1, 10, 1, newTestMemoryStorage(withPeers(1, 2, 3)))
+	cfg.SampleReplicationStats = true
+	r := newRaft(cfg)
+	require.NoError(t, r.Step(pb.Message{From: 1, To: 1, Type: pb.MsgHup}))
+	r.readMessages()
+	require.NoError(t, r.Step(pb.Message{From: 2, To: 1, Term: r.Term, Type: pb.MsgVoteResp}))
+	require.Equal(t, StateLeader, r.state)
+	r.readMessages()
+
+	ack := func(from pb.PeerID, index uint64) {
+		require.NoError(t, r.Step(pb.Message{From: from, To: 1, Term: r.Term, Type: pb.MsgAppResp, Index: index}))
+	}
+	// The followers acknowledge the empty entry of the leader.
+	ack(2, 1)
+	ack(3, 1)
+	r.readMessages()
+
+	for i := 0; i < 4; i++ {
+		require.NoError(t, r.Step(pb.Message{From: 1, To: 1, Type: pb.MsgProp, Entries: []pb.Entry{{Data: []byte("somedata")}}}))
+		r.readMessages()
+		index := r.raftLog.lastIndex()
+		ack(2, index)
+		for j := 0; j < 3; j++ {
+			r.tick()
+		}
+		ack(3, index)
+	}
+
+	st := getStatus(r).Replication
+	require.NotNil(t, st)
+	require.Equal(t, uint64(10), st.MsgAppSizes.Count)
+	require.Equal(t, uint64(5), st.AckLatencies[2].Count)
+	require.Equal(t, uint64(0), st.AckLatencies[2].Max)
+	require.Equal(t, uint64(5), st.AckLatencies[3].Count)
+	require.Equal(t, uint64(3), st.AckLatencies[3].Max)
+	require.Equal(t, pb.PeerID(3), st.SlowestFollower())
+
+	// The statistics are reset when the leader steps down.
+	r.becomeFollower(r.Term+1, None)
+	require.Nil(t, getStatus(r).Replication)
+}
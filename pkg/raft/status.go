@@ -32,14 +32,39 @@ type Status struct {
 	BasicStatus
 	Config           quorum.Config
 	Progress         map[pb.PeerID]tracker.Progress
+	Followers        map[pb.PeerID]FollowerStatus
 	LeadSupportUntil hlc.Timestamp
+	// ReadyAllocs counts the allocations made when assembling Ready structs.
+	ReadyAllocs ReadyAllocStats
+	// Log contains the statistics of the stable log, as reported by the Storage.
+	// It is only populated if the Storage implements StorageStats. Entries that
+	// have not yet been persisted are not accounted for.
+	Log *LogStats
+	// Replication contains the replication statistics sampled by the leader. It
+	// is only populated on the leader, if Config.SampleReplicationStats is set.
+	Replication *ReplicationStats
 }
 
 // SparseStatus is a variant of Status without Config or Progress.Inflights,
 // which are expensive to copy.
 type SparseStatus struct {
 	BasicStatus
-	Progress map[pb.PeerID]tracker.Progress
+	Progress  map[pb.PeerID]tracker.Progress
+	Followers map[pb.PeerID]FollowerStatus
+}
+
+// ReadyAllocStats counts the allocations made by a RawNode when assembling
+// Ready structs. It helps performance-sensitive applications find allocation
+// regressions, in particular in combination with Config.ReuseReady.
+type ReadyAllocStats struct {
+	// Readies is the number of Ready structs handed out to the application.
+	Readies uint64
+	// SoftStates is the number of SoftState structs allocated for Ready.
+	SoftStates uint64
+	// MessageBufs is the number of times a buffer holding outbound messages was
+	// allocated or grown. A buffer growing multiple times while assembling a
+	// single Ready is counted once.
+	MessageBufs uint64
 }
 
 // LeadSupportStatus is a variant of Status without Config or Progress, which
@@ -66,6 +91,125 @@ func (b BasicStatus) Empty() bool {
 	return b == BasicStatus{}
 }
 
+// FollowerState is the leader's classification of a follower. It is derived
+// from the follower's Progress and its recent activity, and allows the layers
+// above raft (e.g. replica circuit breakers) to react to unhealthy followers
+// without re-deriving this from the raw Progress fields.
+type FollowerState uint8
+
+const (
+	// FollowerHealthy is a follower in StateReplicate which is either caught up
+	// with the leader's log, or has recently made progress towards it.
+	FollowerHealthy FollowerState = iota
+	// FollowerProbing is a follower in StateProbe.
+	FollowerProbing
+	// FollowerSnapshot is a follower in StateSnapshot.
+	FollowerSnapshot
+	// FollowerUnreachable is a follower which the leader has not heard from for
+	// at least an election timeout, or which has been reported unreachable (see
+	// RawNode.ReportUnreachable) and has not responded since.
+	FollowerUnreachable
+	// FollowerIdle is a follower in StateReplicate which lags behind the
+	// leader's log, and whose Match index has not advanced for at least an
+	// election timeout.
+	FollowerIdle
+)
+
+var followerStateMap = [...]string{
+	"FollowerHealthy",
+	"FollowerProbing",
+	"FollowerSnapshot",
+	"FollowerUnreachable",
+	"FollowerIdle",
+}
+
+func (st FollowerState) String() string { return followerStateMap[st] }
+
+// FollowerStatus is the leader's view of a follower's health.
+type FollowerStatus struct {
+	State FollowerState
+	// Since is the tick at which the follower transitioned into State. Ticks are
+	// counted from the creation of the raft instance, see RawNode.Tick.
+	Since uint64
+}
+
+// followerTracker tracks the information needed to classify a follower.
+type followerTracker struct {
+	status FollowerStatus
+	// match is the highest Match index observed for the follower, and matchTick
+	// is the tick at which it was first observed.
+	match     uint64
+	matchTick uint64
+	// unreachable is set when the follower is reported unreachable, or when the
+	// leader did not hear from it for an election timeout. It is cleared when a
+	// response from the follower is received.
+	unreachable bool
+}
+
+// follower returns the followerTracker for the given peer, creating it if
+// necessary. Must only be called on the leader.
+func (r *raft) follower(id pb.PeerID) *followerTracker {
+	ft, ok := r.followers[id]
+	if !ok {
+		if r.followers == nil {
+			r.followers = map[pb.PeerID]*followerTracker{}
+		}
+		ft = &followerTracker{
+			status:    FollowerStatus{State: FollowerProbing, Since: r.ticks},
+			matchTick: r.ticks,
+		}
+		r.followers[id] = ft
+	}
+	return ft
+}
+
+// classifyFollower returns the current FollowerState of the given follower.
+func (r *raft) classifyFollower(pr *tracker.Progress, ft *followerTracker) FollowerState {
+	switch {
+	case ft.unreachable:
+		return FollowerUnreachable
+	case pr.State == tracker.StateSnapshot:
+		return FollowerSnapshot
+	case pr.State == tracker.StateProbe:
+		return FollowerProbing
+	case pr.Match < r.raftLog.lastIndex() && r.ticks-ft.matchTick >= uint64(r.electionTimeout):
+		return FollowerIdle
+	default:
+		return FollowerHealthy
+	}
+}
+
+// updateFollowers reclassifies all the followers, and records the tick of the
+// transition for those whose FollowerState changed. Must only be called on the
+// leader.
+func (r *raft) updateFollowers() {
+	for id := range r.followers {
+		if r.trk.Progress(id) == nil {
+			delete(r.followers, id)
+		}
+	}
+	r.trk.Visit(func(id pb.PeerID, pr *tracker.Progress) {
+		if id == r.id {
+			return
+		}
+		ft := r.follower(id)
+		if pr.Match > ft.match {
+			ft.match, ft.matchTick = pr.Match, r.ticks
+		}
+		if st := r.classifyFollower(pr, ft); st != ft.status.State {
+			ft.status = FollowerStatus{State: st, Since: r.ticks}
+		}
+	})
+}
+
+func getFollowersCopy(r *raft) map[pb.PeerID]FollowerStatus {
+	m := make(map[pb.PeerID]FollowerStatus, len(r.followers))
+	for id, ft := range r.followers {
+		m[id] = ft.status
+	}
+	return m
+}
+
 // withProgress calls the supplied visitor to introspect the progress for the
 // supplied raft group. Cannot be used to introspect p.Inflights.
 func withProgress(r *raft, visitor func(id pb.PeerID, typ ProgressType, pr tracker.Progress)) {
@@ -126,8 +270,16 @@ func getStatus(r *raft) Status {
 	s.BasicStatus = getBasicStatus(r)
 	if s.RaftState == StateLeader {
 		s.Progress = getProgressCopy(r)
+		s.Followers = getFollowersCopy(r)
+		if r.replStats != nil {
+			st := r.replStats.stats()
+			s.Replication = &st
+		}
 	}
 	s.Config = r.config.Clone()
+	if st, ok := r.raftLog.stableStats(); ok {
+		s.Log = &st
+	}
 	// NOTE: we assign to LeadSupportUntil even if RaftState is not currently
 	// StateLeader. The replica may have been the leader and stepped down to a
 	// follower before its lead support ran out.
@@ -146,6 +298,7 @@ func getSparseStatus(r *raft) SparseStatus {
 		withProgress(r, func(id pb.PeerID, _ ProgressType, pr tracker.Progress) {
 			s.Progress[id] = pr
 		})
+		s.Followers = getFollowersCopy(r)
 	}
 	return s
 }
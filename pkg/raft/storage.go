@@ -92,6 +92,48 @@ type Storage interface {
 	Snapshot() (pb.Snapshot, error)
 }
 
+// LogStats contains statistics about the log entries held by a Storage.
+type LogStats struct {
+	// Bytes is the total encoding size of the entries in the log.
+	Bytes uint64
+	// Entries is the number of entries in the log.
+	Entries uint64
+	// FirstIndex and LastIndex are the bounds of the log, as returned by
+	// Storage.FirstIndex and Storage.LastIndex. The log is empty if FirstIndex is
+	// LastIndex+1.
+	FirstIndex, LastIndex uint64
+}
+
+// StorageStats is an optional interface which may be implemented by a Storage
+// to expose statistics about the log it holds. If the Storage implements it,
+// the stats are included in the raft Status, which lets the application drive
+// its log truncation policy from raft's own accounting.
+type StorageStats interface {
+	// Stats returns the statistics of the log held by the Storage.
+	Stats() (LogStats, error)
+}
+
+// RetentionPolicy configures how many applied log entries a MemoryStorage
+// retains, see MemoryStorage.SetRetentionPolicy. A zero limit means that the
+// corresponding dimension is unlimited.
+type RetentionPolicy struct {
+	// Entries is the maximum number of applied entries to retain.
+	Entries uint64
+	// Bytes is the maximum total encoding size of the applied entries to retain.
+	Bytes uint64
+}
+
+// enabled returns true if the policy limits the retained entries.
+func (p RetentionPolicy) enabled() bool {
+	return p.Entries != 0 || p.Bytes != 0
+}
+
+// appliedObserver is implemented by storages which need to be notified when the
+// applied index advances.
+type appliedObserver interface {
+	appliedTo(index uint64)
+}
+
 type inMemStorageCallStats struct {
 	initialState, firstIndex, lastIndex, entries, term, snapshot int
 }
@@ -110,8 +152,13 @@ type MemoryStorage struct {
 	ents []pb.Entry
 
 	callStats inMemStorageCallStats
+
+	// retention is the policy by which applied entries are compacted.
+	retention RetentionPolicy
 }
 
+var _ appliedObserver = (*MemoryStorage)(nil)
+
 // NewMemoryStorage creates an empty MemoryStorage.
 func NewMemoryStorage() *MemoryStorage {
 	return &MemoryStorage{
@@ -197,6 +244,18 @@ func (ms *MemoryStorage) firstIndex() uint64 {
 	return ms.ents[0].Index + 1
 }
 
+// Stats implements the StorageStats interface.
+func (ms *MemoryStorage) Stats() (LogStats, error) {
+	ms.Lock()
+	defer ms.Unlock()
+	return LogStats{
+		Bytes:      uint64(entsSize(ms.ents[1:])),
+		Entries:    uint64(len(ms.ents) - 1),
+		FirstIndex: ms.firstIndex(),
+		LastIndex:  ms.lastIndex(),
+	}, nil
+}
+
 // Snapshot implements the Storage interface.
 func (ms *MemoryStorage) Snapshot() (pb.Snapshot, error) {
 	ms.Lock()
@@ -206,7 +265,8 @@ func (ms *MemoryStorage) Snapshot() (pb.Snapshot, error) {
 }
 
 // ApplySnapshot overwrites the contents of this Storage object with
-// those of the given snapshot.
+// those of the given snapshot. Returns a *pb.SnapshotIntegrityError if the
+// snapshot data does not match its metadata.
 func (ms *MemoryStorage) ApplySnapshot(snap pb.Snapshot) error {
 	ms.Lock()
 	defer ms.Unlock()
@@ -217,6 +277,9 @@ func (ms *MemoryStorage) ApplySnapshot(snap pb.Snapshot) error {
 	if msIndex >= snapIndex {
 		return ErrSnapOutOfDate
 	}
+	if err := snap.VerifyIntegrity(); err != nil {
+		return err
+	}
 
 	ms.snapshot = snap
 	ms.ents = []pb.Entry{{Term: snap.Metadata.Term, Index: snap.Metadata.Index}}
@@ -256,6 +319,10 @@ func (ms *MemoryStorage) CreateSnapshot(
 func (ms *MemoryStorage) Compact(compactIndex uint64) error {
 	ms.Lock()
 	defer ms.Unlock()
+	return ms.compact(compactIndex)
+}
+
+func (ms *MemoryStorage) compact(compactIndex uint64) error {
 	offset := ms.ents[0].Index
 	if compactIndex <= offset {
 		return ErrCompacted
@@ -276,6 +343,56 @@ func (ms *MemoryStorage) Compact(compactIndex uint64) error {
 	return nil
 }
 
+// SetRetentionPolicy configures the MemoryStorage to automatically compact
+// the log when the applied index advances, so that at most the given number of
+// applied entries, or bytes of applied entries, are retained. Unapplied entries
+// are never compacted. A zero policy disables automatic compaction.
+//
+// The storage does not create snapshots on compaction. If lagging followers
+// may need the compacted entries, it is the application's responsibility to
+// make sure a snapshot covering them is available.
+func (ms *MemoryStorage) SetRetentionPolicy(p RetentionPolicy) {
+	ms.Lock()
+	defer ms.Unlock()
+	ms.retention = p
+}
+
+// appliedTo compacts the log according to the retention policy, given that all
+// the entries up to the given index have been applied.
+func (ms *MemoryStorage) appliedTo(index uint64) {
+	ms.Lock()
+	defer ms.Unlock()
+	if !ms.retention.enabled() {
+		return
+	}
+	// The applied entries may not have been appended to storage yet.
+	applied := min(index, ms.lastIndex())
+	offset := ms.ents[0].Index
+	if applied <= offset {
+		return
+	}
+	// Find the highest compaction index, such that the retained applied entries
+	// in (compactIndex, applied] are within the limits.
+	compactIndex := offset
+	if n := ms.retention.Entries; n != 0 && applied-offset > n {
+		compactIndex = applied - n
+	}
+	if limit := ms.retention.Bytes; limit != 0 {
+		var size uint64
+		for i := applied; i > compactIndex; i-- {
+			if size += uint64(ms.ents[i-offset].Size()); size > limit {
+				compactIndex = i
+				break
+			}
+		}
+	}
+	if compactIndex > offset {
+		if err := ms.compact(compactIndex); err != nil {
+			getLogger().Panicf("unexpected compaction error: %v", err)
+		}
+	}
+}
+
 // Append the new entries to storage.
 // TODO (xiangli): ensure the entries are continuous and
 // entries[0].Index > ms.entries[0].Index
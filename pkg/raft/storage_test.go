@@ -146,6 +146,75 @@ func TestStorageCompact(t *testing.T) {
 	}
 }
 
+func TestStorageStats(t *testing.T) {
+	s := &MemoryStorage{ents: index(3).terms(3, 4, 5)}
+	st, err := s.Stats()
+	require.NoError(t, err)
+	require.Equal(t, LogStats{
+		Bytes:      uint64(entsSize(index(4).terms(4, 5))),
+		Entries:    2,
+		FirstIndex: 4,
+		LastIndex:  5,
+	}, st)
+
+	require.NoError(t, s.Compact(5))
+	st, err = s.Stats()
+	require.NoError(t, err)
+	require.Equal(t, LogStats{FirstIndex: 6, LastIndex: 5}, st)
+
+	// The stats are exposed in the raft Status.
+	r := newTestRaft(1, 10, 1, newTestMemoryStorage(withPeers(1)))
+	require.NoError(t, r.raftLog.storage.(*MemoryStorage).Append(index(1).terms(1, 1)))
+	status := getStatus(r)
+	require.NotNil(t, status.Log)
+	require.Equal(t, uint64(2), status.Log.Entries)
+	require.Equal(t, uint64(2), status.Log.LastIndex)
+}
+
+func TestStorageRetentionPolicy(t *testing.T) {
+	ents := index(3).terms(3, 4, 5, 6, 7, 8)
+	entSize := uint64(ents[1].Size())
+	for _, tt := range []struct {
+		policy  RetentionPolicy
+		applied uint64
+		wfirst  uint64
+	}{
+		// The policy is disabled.
+		{RetentionPolicy{}, 8, 4},
+		{RetentionPolicy{Entries: 2}, 3, 4},
+		{RetentionPolicy{Entries: 2}, 5, 4},
+		{RetentionPolicy{Entries: 2}, 7, 6},
+		// Entries that are not in storage yet are ignored.
+		{RetentionPolicy{Entries: 2}, 100, 7},
+		{RetentionPolicy{Bytes: 2 * entSize}, 7, 6},
+		{RetentionPolicy{Bytes: 2*entSize + 1}, 7, 6},
+		{RetentionPolicy{Bytes: 2*entSize - 1}, 7, 7},
+		// The tighter of the limits applies.
+		{RetentionPolicy{Entries: 1, Bytes: 2 * entSize}, 7, 7},
+		{RetentionPolicy{Entries: 3, Bytes: 2 * entSize}, 7, 6},
+	} {
+		t.Run("", func(t *testing.T) {
+			s := &MemoryStorage{ents: ents}
+			s.SetRetentionPolicy(tt.policy)
+			s.appliedTo(tt.applied)
+			first, err := s.FirstIndex()
+			require.NoError(t, err)
+			require.Equal(t, tt.wfirst, first)
+		})
+	}
+
+	// The compaction is triggered when the applied index of the raft log
+	// advances.
+	s := &MemoryStorage{ents: ents}
+	s.SetRetentionPolicy(RetentionPolicy{Entries: 1})
+	l := newLog(s, discardLogger)
+	l.committed = 8
+	l.appliedTo(6, 0)
+	first, err := s.FirstIndex()
+	require.NoError(t, err)
+	require.Equal(t, uint64(6), first)
+}
+
 func TestStorageCreateSnapshot(t *testing.T) {
 	ents := index(3).terms(3, 4, 5)
 	cs := &pb.ConfState{Voters: []pb.PeerID{1, 2, 3}}
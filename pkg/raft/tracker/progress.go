@@ -122,8 +122,18 @@ type Progress struct {
 
 	// IsLearner is true if this progress is tracked for a learner.
 	IsLearner bool
+
+	// Metadata is opaque application-defined information about the peer, such
+	// as its store ID or locality. It is never interpreted by raft, and is
+	// retained for as long as the peer is tracked.
+	Metadata PeerMetadata
 }
 
+// PeerMetadata is opaque application-defined information attached to the
+// Progress of a peer. Copies of the Progress share it, so implementations
+// should be immutable.
+type PeerMetadata interface{}
+
 // ResetState moves the Progress into the specified State, resetting MsgAppProbesPaused,
 // PendingSnapshot, and Inflights.
 func (pr *Progress) ResetState(state StateType) {
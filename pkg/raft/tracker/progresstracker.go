@@ -79,9 +79,15 @@ func (l matchAckIndexer) AckedIndex(id pb.PeerID) (quorum.Index, bool) {
 }
 
 // Committed returns the largest log index known to be committed based on what
-// the voting members of the group have acknowledged.
+// the voting members of the group have acknowledged. If the configuration has
+// a FailureDomains commit policy, it is also taken into account.
 func (p *ProgressTracker) Committed() uint64 {
-	return uint64(p.config.Voters.CommittedIndex(matchAckIndexer(p.progress)))
+	acked := matchAckIndexer(p.progress)
+	idx := p.config.Voters.CommittedIndex(acked)
+	if len(p.config.FailureDomains) != 0 {
+		idx = min(idx, p.config.FailureDomains.CommittedIndex(p.config.Voters, acked))
+	}
+	return uint64(idx)
 }
 
 // Visit invokes the supplied closure for all tracked progresses in stable order.
@@ -0,0 +1,99 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package tracker
+
+import (
+	"github.com/cockroachdb/cockroach/pkg/raft/quorum"
+	pb "github.com/cockroachdb/cockroach/pkg/raft/raftpb"
+	"github.com/cockroachdb/cockroach/pkg/raft/raftstoreliveness"
+)
+
+// SupportTracker is used by the leader to track which peers' stores currently
+// support the leader's store in Store Liveness.
+//
+// A peer whose store supported the leader's store since the last Reset, but no
+// longer does, has most likely crashed or been partitioned away. The leader can
+// avoid constructing messages for such a peer until the support is restored.
+type SupportTracker struct {
+	config        *quorum.Config
+	storeLiveness raftstoreliveness.StoreLiveness
+
+	// support contains the peers whose stores supported the leader's store since
+	// the last Reset, mapped to whether the support is still provided.
+	support map[pb.PeerID]bool
+}
+
+// MakeSupportTracker initializes a SupportTracker. The storeLiveness can be
+// nil, in which case no peer is considered as lacking support.
+func MakeSupportTracker(
+	config *quorum.Config, storeLiveness raftstoreliveness.StoreLiveness,
+) SupportTracker {
+	return SupportTracker{
+		config:        config,
+		storeLiveness: storeLiveness,
+		support:       map[pb.PeerID]bool{},
+	}
+}
+
+// Reset forgets the support recorded so far. It is called when the peer
+// becomes the leader.
+func (st *SupportTracker) Reset() {
+	clear(st.support)
+}
+
+// Refresh queries Store Liveness for the support provided to the leader's
+// store by each peer in the configuration.
+func (st *SupportTracker) Refresh() {
+	if st.storeLiveness == nil {
+		return
+	}
+	for _, ids := range [...]map[pb.PeerID]struct{}{
+		st.config.Voters[0], st.config.Voters[1], st.config.Learners,
+	} {
+		for id := range ids {
+			st.refresh(id)
+		}
+	}
+	// Forget the peers that were removed from the configuration.
+	for id := range st.support {
+		if !st.inConfig(id) {
+			delete(st.support, id)
+		}
+	}
+}
+
+func (st *SupportTracker) inConfig(id pb.PeerID) bool {
+	_, in0 := st.config.Voters[0][id]
+	_, in1 := st.config.Voters[1][id]
+	_, inL := st.config.Learners[id]
+	return in0 || in1 || inL
+}
+
+func (st *SupportTracker) refresh(id pb.PeerID) {
+	_, exp, ok := st.storeLiveness.SupportFrom(uint64(id))
+	if ok && !st.storeLiveness.SupportExpired(exp) {
+		st.support[id] = true
+	} else if _, seen := st.support[id]; seen {
+		st.support[id] = false
+	}
+}
+
+// LostSupport returns true if the given peer's store supported the leader's
+// store since the last Reset, but no longer does as of the last Refresh. Peers
+// which never provided support are not considered, because the support might
+// not have been established yet.
+func (st *SupportTracker) LostSupport(id pb.PeerID) bool {
+	if st.storeLiveness == nil || !st.storeLiveness.SupportFromEnabled() {
+		return false
+	}
+	supported, seen := st.support[id]
+	return seen && !supported
+}
@@ -279,6 +279,24 @@ func limitSize(ents []pb.Entry, maxSize entryEncodingSize) []pb.Entry {
 	return ents
 }
 
+// limitSizeFunc is like limitSize, but computes the entry sizes with the given
+// function rather than using their encoding size.
+func limitSizeFunc(
+	ents []pb.Entry, maxSize entryEncodingSize, size func(e *pb.Entry) uint64,
+) []pb.Entry {
+	if len(ents) == 0 {
+		return ents
+	}
+	total := size(&ents[0])
+	for limit := 1; limit < len(ents); limit++ {
+		total += size(&ents[limit])
+		if entryEncodingSize(total) > maxSize {
+			return ents[:limit]
+		}
+	}
+	return ents
+}
+
 // entryPayloadSize represents the size of one or more entries' payloads.
 // Notably, it does not depend on its Index or Term. Entries with empty
 // payloads, like those proposed after a leadership change, are considered
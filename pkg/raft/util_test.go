@@ -70,6 +70,27 @@ func TestLimitSize(t *testing.T) {
 	}
 }
 
+func TestLimitSizeFunc(t *testing.T) {
+	ents := index(4).terms(4, 5, 6)
+	size := func(e *pb.Entry) uint64 { return e.Index * 10 } // 40, 50, 60
+	for _, tt := range []struct {
+		maxSize uint64
+		want    int
+	}{
+		{math.MaxUint64, 3},
+		{0, 1}, // the first entry is always returned
+		{89, 1},
+		{90, 2},
+		{149, 2},
+		{150, 3},
+	} {
+		t.Run("", func(t *testing.T) {
+			got := limitSizeFunc(ents, entryEncodingSize(tt.maxSize), size)
+			require.Equal(t, ents[:tt.want], got)
+		})
+	}
+}
+
 func TestIsLocalMsg(t *testing.T) {
 	tests := []struct {
 		msgt    pb.MessageType
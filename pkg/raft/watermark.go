@@ -0,0 +1,108 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package raft
+
+import "slices"
+
+// WatermarkKind identifies a log index which only moves forward, and can be
+// waited for with RawNode.WaitFor.
+type WatermarkKind uint8
+
+const (
+	// WatermarkCommitted is the commit index of the log.
+	WatermarkCommitted WatermarkKind = iota
+	// WatermarkApplied is the applied index of the log, i.e. the index of the
+	// last entry whose application was acknowledged to raft.
+	WatermarkApplied
+
+	numWatermarkKinds
+)
+
+func (k WatermarkKind) String() string {
+	switch k {
+	case WatermarkCommitted:
+		return "committed"
+	case WatermarkApplied:
+		return "applied"
+	default:
+		return "unknown"
+	}
+}
+
+// watermarkWaiter is a channel closed once a watermark reaches the index.
+type watermarkWaiter struct {
+	index uint64
+	ch    chan struct{}
+}
+
+// watermarks contains the waiters for each WatermarkKind, ordered by index.
+type watermarks struct {
+	waiters [numWatermarkKinds][]watermarkWaiter
+}
+
+// closedChan is returned to the waiters whose watermark is already reached.
+var closedChan = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
+// wait returns a channel which is closed once the watermark of the given kind
+// reaches the index. The current value of the watermark is cur.
+func (w *watermarks) wait(kind WatermarkKind, index, cur uint64) <-chan struct{} {
+	if index <= cur {
+		return closedChan
+	}
+	waiters := w.waiters[kind]
+	i, found := slices.BinarySearchFunc(waiters, index, func(ww watermarkWaiter, index uint64) int {
+		if ww.index < index {
+			return -1
+		} else if ww.index > index {
+			return 1
+		}
+		return 0
+	})
+	if found {
+		// Share the channel with the existing waiter for this index.
+		return waiters[i].ch
+	}
+	ww := watermarkWaiter{index: index, ch: make(chan struct{})}
+	w.waiters[kind] = slices.Insert(waiters, i, ww)
+	return ww.ch
+}
+
+// advance notifies the waiters for the watermark of the given kind which has
+// advanced to the index. It is a no-op on a nil receiver.
+func (w *watermarks) advance(kind WatermarkKind, index uint64) {
+	if w == nil {
+		return
+	}
+	waiters := w.waiters[kind]
+	n := 0
+	for ; n < len(waiters) && waiters[n].index <= index; n++ {
+		close(waiters[n].ch)
+	}
+	if n == 0 {
+		return
+	}
+	// Shift the remaining waiters rather than reslicing, so that the buffer is
+	// reused by subsequent waits.
+	w.waiters[kind] = waiters[:copy(waiters, waiters[n:])]
+	clear(waiters[len(waiters)-n:])
+}
+
+// count returns the number of outstanding waiters for the watermark.
+func (w *watermarks) count(kind WatermarkKind) int {
+	if w == nil {
+		return 0
+	}
+	return len(w.waiters[kind])
+}
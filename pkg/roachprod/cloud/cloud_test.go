@@ -14,6 +14,7 @@ import (
 	"testing"
 
 	ec2types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
+	"github.com/cockroachdb/cockroach/pkg/roachprod/vm"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -88,3 +89,15 @@ func TestGetIAMUserNameFromKeyname(t *testing.T) {
 		})
 	}
 }
+
+func TestHasWorkloadPrometheusConfig(t *testing.T) {
+	c := &Cluster{Name: "test", VMs: vm.List{
+		{Name: "test-0001", Labels: map[string]string{vm.TagCluster: "test"}},
+		{Name: "test-0002", Labels: map[string]string{vm.TagCluster: "test"}},
+	}}
+	// Clusters without workload targets have no workload config to delete.
+	assert.False(t, hasWorkloadPrometheusConfig(c))
+
+	c.VMs[1].Labels[vm.TagWorkloadPrometheus] = "true"
+	assert.True(t, hasWorkloadPrometheusConfig(c))
+}
@@ -407,6 +407,17 @@ func ShrinkCluster(l *logger.Logger, c *Cluster, numNodes int) error {
 	})
 }
 
+// hasWorkloadPrometheusConfig returns whether workload targets of the cluster
+// were registered with the managed Prometheus, in a config of their own.
+func hasWorkloadPrometheusConfig(c *Cluster) bool {
+	for _, v := range c.VMs {
+		if v.Labels[vm.TagWorkloadPrometheus] == "true" {
+			return true
+		}
+	}
+	return false
+}
+
 // DestroyCluster TODO(peter): document
 func DestroyCluster(l *logger.Logger, c *Cluster) error {
 	// check if any node is supported as promhelper cluster
@@ -427,6 +438,12 @@ func DestroyCluster(l *logger.Logger, c *Cluster) error {
 					l.Errorf("Failed to delete the cluster config with cluster as secure: %v", err)
 				}
 			}
+			if hasWorkloadPrometheusConfig(c) {
+				if err := promhelperclient.NewPromClient().DeleteClusterConfig(context.Background(),
+					promhelperclient.WorkloadConfigName(c.Name), false, true /* insecure */, l); err != nil {
+					l.Errorf("Failed to delete the workload config: %v", err)
+				}
+			}
 			break
 		}
 	}
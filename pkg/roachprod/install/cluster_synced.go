@@ -18,6 +18,7 @@ import (
 	"io"
 	"io/fs"
 	"math"
+	"net"
 	"os"
 	"os/exec"
 	"os/signal"
@@ -25,6 +26,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"text/template"
 	"time"
@@ -128,6 +130,15 @@ var defaultSCPShouldRetryFn = func(res *RunResultDetails) bool {
 	return true
 }
 
+// retries counts the retries performed by runWithMaybeRetry.
+var retries atomic.Int64
+
+// Retries returns the number of times remote commands and file transfers were
+// retried by this process after transient errors, e.g. SSH flakes.
+func Retries() int64 {
+	return retries.Load()
+}
+
 // runWithMaybeRetry will run the specified function `f` at least once, or only
 // once if `retryOpts` is nil
 //
@@ -162,6 +173,9 @@ func runWithMaybeRetry(
 	var cmdErr, err error
 
 	for r := retry.StartWithCtx(ctx, *retryOpts); r.Next(); {
+		if r.CurrentAttempt() > 0 {
+			retries.Add(1)
+		}
 		res, err = f(ctx)
 		if err != nil {
 			// non retryable roachprod error
@@ -202,6 +216,21 @@ func (c *SyncedCluster) Host(n Node) string {
 	return c.VMs[n-1].PublicIP
 }
 
+// hostPort returns the host:port address of the given host, which is bracketed
+// if it's an IPv6 address.
+func hostPort(host string, port int) string {
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// remotePath returns the user@host:path target of scp and rsync for the given
+// path on the given host, which is bracketed if it's an IPv6 address.
+func remotePath(user, host, path string) string {
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+	return fmt.Sprintf("%s@%s:%s", user, host, path)
+}
+
 func (c *SyncedCluster) user(n Node) string {
 	return c.VMs[n-1].RemoteUser
 }
@@ -1280,6 +1309,16 @@ func (c *SyncedCluster) Run(
 		return result, err
 	})
 
+	if options.OnResults != nil {
+		var completed []*RunResultDetails
+		for _, r := range results {
+			if r != nil {
+				completed = append(completed, r)
+			}
+		}
+		options.OnResults(completed)
+	}
+
 	if err != nil {
 		return err
 	}
@@ -1845,7 +1884,7 @@ func (c *SyncedCluster) getFileFromFirstNode(
 			_ = os.Remove(tmpfile.Name()) // clean up
 		}
 
-		srcFileName := fmt.Sprintf("%s@%s:%s", c.user(1), c.Host(1), name)
+		srcFileName := remotePath(c.user(1), c.Host(1), name)
 		if res, _ := scpWithRetry(ctx, l, srcFileName, tmpfile.Name()); res.Err != nil {
 			cleanup()
 			return "", nil, res.Err
@@ -1905,12 +1944,16 @@ func (c *SyncedCluster) createNodeCertArguments(l *logger.Logger) ([]string, err
 	// Gather the internal and external IP addresse and hostname for every node in the cluster, even
 	// if it won't be added to the cluster itself we still add the IP address
 	// to the node cert.
+	var ipv6 bool
 	for _, n := range allNodes(len(c.VMs)) {
 		ip, err := c.GetInternalIP(n)
 		if err != nil {
 			return nil, err
 		}
 		nodeNames = append(nodeNames, ip, c.Host(n), fmt.Sprintf("%s-%04d", c.Name, n))
+		if parsed := net.ParseIP(ip); parsed != nil && parsed.To4() == nil {
+			ipv6 = true
+		}
 		// AWS nodes internally have a DNS name in the form ip-<ip-addresss>
 		// where dots are replaced with dashes.
 		// See https://docs.aws.amazon.com/vpc/latest/userguide/vpc-dns.html#vpc-dns-hostnames
@@ -1918,6 +1961,11 @@ func (c *SyncedCluster) createNodeCertArguments(l *logger.Logger) ([]string, err
 			nodeNames = append(nodeNames, "ip-"+strings.ReplaceAll(ip, ".", "-"))
 		}
 	}
+	// IPv6-only nodes reach themselves over the IPv6 loopback address rather
+	// than localhost's 127.0.0.1.
+	if ipv6 {
+		nodeNames = append(nodeNames, "::1")
+	}
 	// Add any load balancers IPs to the list of names.
 	lbAddresses, err := c.ListLoadBalancers(l)
 	if err != nil {
@@ -2096,7 +2144,7 @@ func (c *SyncedCluster) Put(
 		if err != nil {
 			return "", err
 		}
-		return fmt.Sprintf("%s@%s:%s", c.user(nodes[i]), c.Host(nodes[i]), dest), nil
+		return remotePath(c.user(nodes[i]), c.Host(nodes[i]), dest), nil
 	}
 
 	spinner := ui.NewDefaultTaskSpinner(l, "")
@@ -2270,7 +2318,7 @@ func (c *SyncedCluster) Logs(
 			if !filepath.IsAbs(logDir) && user != "" && user != sshUser {
 				logDir = "~" + user + "/" + logDir
 			}
-			remote = fmt.Sprintf("%s@%s:%s/", c.user(node), c.Host(node), logDir)
+			remote = remotePath(c.user(node), c.Host(node), logDir+"/")
 			// Use control master to mitigate SSH connection setup cost.
 			rsyncArgs = append(rsyncArgs, "--rsh", "ssh "+
 				"-o StrictHostKeyChecking=no "+
@@ -2510,7 +2558,7 @@ func (c *SyncedCluster) Get(
 				return
 			}
 
-			res, _ := scpWithRetry(ctx, l, fmt.Sprintf("%s@%s:%s", c.user(nodes[0]), c.Host(nodes[i]), src), dest)
+			res, _ := scpWithRetry(ctx, l, remotePath(c.user(nodes[0]), c.Host(nodes[i]), src), dest)
 			if res.Err == nil {
 				// Make sure all created files and directories are world readable.
 				// The CRDB process intentionally sets a 0007 umask (resulting in
@@ -2939,7 +2987,7 @@ func (c *SyncedCluster) allPublicAddrs(ctx context.Context) (string, error) {
 		if err != nil {
 			return "", err
 		}
-		addrs = append(addrs, fmt.Sprintf("%s:%d", c.Host(node), port))
+		addrs = append(addrs, hostPort(c.Host(node), port))
 	}
 
 	return strings.Join(addrs, ","), nil
@@ -203,3 +203,11 @@ func TestGenFilenameFromArgs(t *testing.T) {
 	require.Equal(t, exp, GenFilenameFromArgs(20, "mkdir", "-p logs/redacted", "&& ./cockroach"))
 	require.Equal(t, exp, GenFilenameFromArgs(20, "mkdir    -p logs/redacted && ./cockroach    "))
 }
+
+func TestHostPortIPv6(t *testing.T) {
+	require.Equal(t, "10.0.0.1:26257", hostPort("10.0.0.1", 26257))
+	require.Equal(t, "[2600:1900::1]:26257", hostPort("2600:1900::1", 26257))
+	require.Equal(t, ":8080", hostPort("", 8080))
+	require.Equal(t, "ubuntu@10.0.0.1:logs/", remotePath("ubuntu", "10.0.0.1", "logs/"))
+	require.Equal(t, "ubuntu@[2600:1900::1]:logs/", remotePath("ubuntu", "2600:1900::1", "logs/"))
+}
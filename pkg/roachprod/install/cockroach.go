@@ -623,7 +623,7 @@ func (c *SyncedCluster) NodeURL(
 	var u url.URL
 	u.Scheme = "postgres"
 	u.User = url.User("root")
-	u.Host = fmt.Sprintf("%s:%d", host, port)
+	u.Host = hostPort(host, port)
 	u.Path = database
 	v := url.Values{}
 	if c.Secure {
@@ -1001,7 +1001,7 @@ func (c *SyncedCluster) generateStartArgs(
 			return nil, err
 		}
 		sqlPort = desc.Port
-		args = append(args, fmt.Sprintf("--sql-addr=%s:%d", listenHost, sqlPort))
+		args = append(args, "--sql-addr="+hostPort(listenHost, sqlPort))
 	} else {
 		virtualClusterName = SystemInterfaceName
 		// System interface instance is always 0.
@@ -1011,13 +1011,13 @@ func (c *SyncedCluster) generateStartArgs(
 			return nil, err
 		}
 		sqlPort = desc.Port
-		args = append(args, fmt.Sprintf("--listen-addr=%s:%d", listenHost, sqlPort))
+		args = append(args, "--listen-addr="+hostPort(listenHost, sqlPort))
 	}
 	desc, err := c.DiscoverService(ctx, node, virtualClusterName, ServiceTypeUI, instance)
 	if err != nil {
 		return nil, err
 	}
-	args = append(args, fmt.Sprintf("--http-addr=%s:%d", listenHost, desc.Port))
+	args = append(args, "--http-addr="+hostPort(listenHost, desc.Port))
 
 	if !c.IsLocal() {
 		advertiseHost := ""
@@ -1027,7 +1027,7 @@ func (c *SyncedCluster) generateStartArgs(
 			advertiseHost = c.VMs[node-1].PrivateIP
 		}
 		args = append(args,
-			fmt.Sprintf("--advertise-addr=%s:%d", advertiseHost, sqlPort),
+			"--advertise-addr="+hostPort(advertiseHost, sqlPort),
 		)
 	}
 
@@ -1040,7 +1040,7 @@ func (c *SyncedCluster) generateStartArgs(
 			if err != nil {
 				return nil, err
 			}
-			addresses[i] = fmt.Sprintf("%s:%d", c.Host(joinNode), desc.Port)
+			addresses[i] = hostPort(c.Host(joinNode), desc.Port)
 		}
 		args = append(args, fmt.Sprintf("--join=%s", strings.Join(addresses, ",")))
 	}
@@ -26,6 +26,14 @@ import (
 )
 
 var installCmds = map[string]string{
+	"delve": `
+sudo apt-get update;
+sudo apt-get install -y build-essential;
+curl -fsSL https://dl.google.com/go/go1.22.5.linux-$(dpkg --print-architecture).tar.gz | sudo tar -C /usr/local -xz;
+sudo GOBIN=/usr/local/bin GOPATH=/tmp/delve-gopath GOTOOLCHAIN=local \
+  /usr/local/go/bin/go install github.com/go-delve/delve/cmd/dlv@v1.23.1;
+`,
+
 	"docker": `
 # Add Docker's official GPG key:
 sudo apt-get update;
@@ -76,6 +84,11 @@ sudo apt-get install -y \
 	"sysbench": `
 sudo apt-get update;
 sudo apt-get install -y sysbench;
+`,
+
+	"stress-ng": `
+sudo apt-get update;
+sudo apt-get install -y stress-ng;
 `,
 
 	"zfs": `
@@ -27,6 +27,10 @@ type RunOptions struct {
 	// recommended to check the documentation of the function you are using to see
 	// what the default behaviour is.
 	FailOption FailOption
+	// OnResults, if set, is called by Run with the results of the nodes on
+	// which the command completed, e.g. to record them. The nodes on which the
+	// command was abandoned, because it failed on another node, have none.
+	OnResults func([]*RunResultDetails)
 
 	// These are private to roachprod
 	Nodes       Nodes
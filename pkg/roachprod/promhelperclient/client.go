@@ -179,6 +179,14 @@ func (c *PromClient) DeleteClusterConfig(
 	return nil
 }
 
+// WorkloadConfigName returns the name of the config holding the workload
+// targets of the given cluster. The workload targets are kept in their own
+// config so that they survive the updates of the config of the cluster, which
+// only knows about the cockroach nodes.
+func WorkloadConfigName(clusterName string) string {
+	return clusterName + "-workload"
+}
+
 func getUrl(promUrl, clusterName string) string {
 	return fmt.Sprintf("%s/%s/%s/%s", promUrl, resourceVersion, resourceName, clusterName)
 }
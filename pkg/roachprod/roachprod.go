@@ -817,7 +817,7 @@ func updatePrometheusTargets(
 					l.Errorf("error getting the port for node %d: %v", index, err)
 					return
 				}
-				nodeInfo := fmt.Sprintf("%s:%d", v.PrivateIP, desc.Port)
+				nodeInfo := net.JoinHostPort(v.PrivateIP, strconv.Itoa(desc.Port))
 				nodeIPPortsMutex.Lock()
 				// ensure atomicity in map update
 				nodeIPPorts[index] = &promhelperclient.NodeInfo{Target: nodeInfo, CustomLabels: createLabels(v)}
@@ -835,6 +835,56 @@ func updatePrometheusTargets(
 	return nil
 }
 
+// UpdateWorkloadPrometheusTargets registers the given ports of the given nodes
+// of the cluster, on which `workload run` exposes its histograms (see its
+// --prometheus-port flag), with the Prometheus instance of the cluster. The
+// targets replace the ones registered previously.
+func UpdateWorkloadPrometheusTargets(
+	ctx context.Context, l *logger.Logger, clusterName string, ports []int,
+) error {
+	c, err := newCluster(l, clusterName)
+	if err != nil {
+		return err
+	}
+
+	targets := make(map[int]*promhelperclient.NodeInfo)
+	for _, node := range c.Nodes {
+		v := c.VMs[node-1]
+		if _, ok := promhelperclient.SupportedPromProjects[v.Project]; !ok ||
+			v.Provider != gce.ProviderName {
+			continue
+		}
+		for _, port := range ports {
+			labels := createLabels(v)
+			labels["job"] = "workload"
+			labels["node"] = strconv.Itoa(int(node))
+			delete(labels, "tenant")
+			// The targets are keyed by node, so multiple workloads on the same
+			// node are told apart by their index.
+			targets[len(targets)+1] = &promhelperclient.NodeInfo{
+				Target:       net.JoinHostPort(v.PrivateIP, strconv.Itoa(port)),
+				CustomLabels: labels,
+			}
+		}
+	}
+	if len(targets) == 0 {
+		return nil
+	}
+	// The workload serves its metrics over plain HTTP.
+	if err := promhelperclient.DefaultPromClient.UpdatePrometheusTargets(ctx,
+		promhelperclient.WorkloadConfigName(c.Name), false, targets, true /* insecure */, l); err != nil {
+		return err
+	}
+	// The VMs are labeled so that the config is deleted along with the
+	// cluster, see cloud.DestroyCluster.
+	for _, v := range c.VMs {
+		if v.Labels[vm.TagWorkloadPrometheus] != "true" {
+			return AddLabels(l, c.Name, map[string]string{vm.TagWorkloadPrometheus: "true"})
+		}
+	}
+	return nil
+}
+
 // regionRegEx is the regex to extract the region label from zone available as vm property
 var regionRegEx = regexp.MustCompile("(^.+[0-9]+)(-[a-f]$)")
 
@@ -48,6 +48,9 @@ const (
 	defaultImageProject = "ubuntu-os-cloud"
 	FIPSImageProject    = "ubuntu-os-pro-cloud"
 	ManagedLabel        = "managed"
+	// defaultIPv6Subnet is the subnet IPv6-only instances are created in by
+	// default.
+	defaultIPv6Subnet = "ipv6-only"
 )
 
 // providerInstance is the instance to be registered into vm.Providers by Init.
@@ -155,6 +158,11 @@ type jsonVM struct {
 			Name  string
 			NatIP string
 		}
+		// Ipv6Address and Ipv6AccessConfigs are set on IPv6 stack interfaces.
+		Ipv6Address       string
+		Ipv6AccessConfigs []struct {
+			ExternalIpv6 string
+		}
 	}
 	Scheduling struct {
 		AutomaticRestart          bool
@@ -198,14 +206,23 @@ func (jsonVM *jsonVM) toVM(
 	var publicIP, privateIP, vpc string
 	if len(jsonVM.NetworkInterfaces) == 0 {
 		vmErrors = append(vmErrors, vm.ErrBadNetwork)
+	} else if nic := jsonVM.NetworkInterfaces[0]; nic.NetworkIP == "" && nic.Ipv6Address != "" {
+		// IPv6-only VM, see --gce-ipv6-only.
+		privateIP = nic.Ipv6Address
+		if len(nic.Ipv6AccessConfigs) == 0 {
+			vmErrors = append(vmErrors, vm.ErrBadNetwork)
+		} else {
+			publicIP = nic.Ipv6AccessConfigs[0].ExternalIpv6
+			vpc = lastComponent(nic.Network)
+		}
 	} else {
-		privateIP = jsonVM.NetworkInterfaces[0].NetworkIP
-		if len(jsonVM.NetworkInterfaces[0].AccessConfigs) == 0 {
+		privateIP = nic.NetworkIP
+		if len(nic.AccessConfigs) == 0 {
 			vmErrors = append(vmErrors, vm.ErrBadNetwork)
 		} else {
-			_ = jsonVM.NetworkInterfaces[0].AccessConfigs[0].Name // silence unused warning
-			publicIP = jsonVM.NetworkInterfaces[0].AccessConfigs[0].NatIP
-			vpc = lastComponent(jsonVM.NetworkInterfaces[0].Network)
+			_ = nic.AccessConfigs[0].Name // silence unused warning
+			publicIP = nic.AccessConfigs[0].NatIP
+			vpc = lastComponent(nic.Network)
 		}
 	}
 	if jsonVM.Scheduling.OnHostMaintenance == "" {
@@ -317,6 +334,7 @@ func DefaultProviderOpts() *ProviderOpts {
 		PDVolumeSize:         500,
 		TerminateOnMigration: false,
 		UseSpot:              false,
+		IPv6Subnet:           defaultIPv6Subnet,
 		useSharedUser:        true,
 		preemptible:          false,
 	}
@@ -347,6 +365,10 @@ type ProviderOpts struct {
 	Managed bool
 	// Enable the cron service. It is disabled by default.
 	EnableCron bool
+	// IPv6Only creates the VMs with an IPv6 stack only, in the IPv6Subnet
+	// subnet, which must exist in the regions of the zones of the cluster.
+	IPv6Only   bool
+	IPv6Subnet string
 
 	// GCE allows two availability policies in case of a maintenance event (see --maintenance-policy via gcloud),
 	// 'TERMINATE' or 'MIGRATE'. The default is 'MIGRATE' which we denote by 'TerminateOnMigration == false'.
@@ -1074,6 +1096,10 @@ func (o *ProviderOpts) ConfigureCreateFlags(flags *pflag.FlagSet) {
 		"use a managed instance group (enables resizing, load balancing, and health monitoring)")
 	flags.BoolVar(&o.EnableCron, ProviderName+"-enable-cron",
 		false, "Enables the cron service (it is disabled by default)")
+	flags.BoolVar(&o.IPv6Only, ProviderName+"-ipv6-only", false,
+		"create IPv6-only instances (no IPv4 addresses), in the --gce-ipv6-subnet subnet")
+	flags.StringVar(&o.IPv6Subnet, ProviderName+"-ipv6-subnet", defaultIPv6Subnet,
+		"IPv6-only subnet the instances are created in with --gce-ipv6-only")
 }
 
 // ConfigureClusterFlags implements vm.ProviderFlags.
@@ -1430,6 +1456,19 @@ func (p *Provider) computeInstanceArgs(
 	return args, cleanUpFn, nil
 }
 
+// networkArgs returns the arguments which configure the network interface of
+// the instances.
+func networkArgs(providerOpts *ProviderOpts) []string {
+	if providerOpts.IPv6Only {
+		return []string{
+			"--subnet", providerOpts.IPv6Subnet,
+			"--stack-type", "IPV6_ONLY",
+			"--ipv6-network-tier", "PREMIUM",
+		}
+	}
+	return []string{"--subnet", "default"}
+}
+
 func instanceTemplateName(clusterName string) string {
 	return fmt.Sprintf("%s-template", clusterName)
 }
@@ -1549,6 +1588,9 @@ func (p *Provider) Create(
 			return err
 		}
 	}
+	if providerOpts.IPv6Only && providerOpts.Managed {
+		return errors.New("IPv6-only instances are not supported in managed instance groups")
+	}
 
 	instanceArgs, cleanUpFn, err := p.computeInstanceArgs(l, opts, providerOpts)
 	if cleanUpFn != nil {
@@ -1618,7 +1660,7 @@ func (p *Provider) Create(
 		}
 	default:
 		var g errgroup.Group
-		createArgs := []string{"compute", "instances", "create", "--subnet", "default"}
+		createArgs := append([]string{"compute", "instances", "create"}, networkArgs(providerOpts)...)
 		createArgs = append(createArgs, "--labels", labels)
 		createArgs = append(createArgs, instanceArgs...)
 
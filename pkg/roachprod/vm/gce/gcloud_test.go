@@ -11,6 +11,7 @@
 package gce
 
 import (
+	"encoding/json"
 	"math"
 	"math/rand"
 	"reflect"
@@ -265,3 +266,31 @@ func TestComputeGrowDistribution(t *testing.T) {
 		t.Error(err)
 	}
 }
+
+func TestToVMIPv6Only(t *testing.T) {
+	var jsonVMs []jsonVM
+	assert.NoError(t, json.Unmarshal([]byte(`[{
+  "name": "foo-0001",
+  "labels": {"lifetime": "12h0m0s"},
+  "networkInterfaces": [{
+    "network": "https://www.googleapis.com/compute/v1/projects/p/global/networks/default",
+    "ipv6Address": "2600:1900:4000:1::1",
+    "ipv6AccessConfigs": [{"externalIpv6": "2600:1900:4000:2::1"}]
+  }],
+  "scheduling": {"onHostMaintenance": "MIGRATE"},
+  "zone": "https://www.googleapis.com/compute/v1/projects/p/zones/us-east1-b"
+}]`), &jsonVMs))
+
+	v := jsonVMs[0].toVM("p", nil /* disks */, DefaultProviderOpts(), "roachprod.crdb.io")
+	assert.Empty(t, v.Errors)
+	assert.Equal(t, "2600:1900:4000:1::1", v.PrivateIP)
+	assert.Equal(t, "2600:1900:4000:2::1", v.PublicIP)
+	assert.Equal(t, "default", v.VPC)
+
+	assert.Equal(t, []string{"--subnet", "default"}, networkArgs(DefaultProviderOpts()))
+	opts := DefaultProviderOpts()
+	opts.IPv6Only = true
+	assert.Equal(t, []string{
+		"--subnet", "ipv6-only", "--stack-type", "IPV6_ONLY", "--ipv6-network-tier", "PREMIUM",
+	}, networkArgs(opts))
+}
@@ -12,6 +12,7 @@ package vm
 
 import (
 	"fmt"
+	"net"
 	"regexp"
 	"sort"
 	"strconv"
@@ -42,6 +43,9 @@ const (
 	TagUsage = "usage"
 	// TagArch is the CPU architecture tag const.
 	TagArch = "arch"
+	// TagWorkloadPrometheus is added, with value true, to the VMs of clusters
+	// whose workload targets were registered with the managed Prometheus.
+	TagWorkloadPrometheus = "workload-prometheus"
 
 	ArchARM64   = CPUArch("arm64")
 	ArchAMD64   = CPUArch("amd64")
@@ -219,7 +223,12 @@ func (vm *VM) ZoneEntry() (string, error) {
 	}
 	// TODO(rail): We should probably skip local VMs too. They add a bunch of
 	// entries for localhost.roachprod.crdb.io pointing to 127.0.0.1.
-	return fmt.Sprintf("%s 60 IN A %s\n", vm.Name, vm.PublicIP), nil
+	recordType := "A"
+	if ip := net.ParseIP(vm.PublicIP); ip != nil && ip.To4() == nil {
+		// IPv6-only VMs have an IPv6 public address.
+		recordType = "AAAA"
+	}
+	return fmt.Sprintf("%s 60 IN %s %s\n", vm.Name, recordType, vm.PublicIP), nil
 }
 
 func (vm *VM) AttachVolume(l *logger.Logger, v Volume) (deviceName string, _ error) {
@@ -83,6 +83,11 @@ func TestVM_ZoneEntry(t *testing.T) {
 			vm:          VM{Name: "just_a_test", PublicIP: "1.1.1.1"},
 			expected:    "just_a_test 60 IN A 1.1.1.1\n",
 		},
+		{
+			description: "IPv6",
+			vm:          VM{Name: "just_a_test", PublicIP: "2600:1900:4000:1::1"},
+			expected:    "just_a_test 60 IN AAAA 2600:1900:4000:1::1\n",
+		},
 		{
 			description: "Too long name",
 			vm: VM{
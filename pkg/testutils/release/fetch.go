@@ -0,0 +1,63 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package release
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/cockroachdb/cockroach/pkg/util/httputil"
+)
+
+// ReleaseDataURL is the location of the cockroach_releases.yaml file
+// on the master branch, which is kept up to date as new releases are
+// published.
+const ReleaseDataURL = "https://raw.githubusercontent.com/cockroachdb/cockroach/master/pkg/testutils/release/cockroach_releases.yaml"
+
+// LoadReleaseData downloads release data in the format of the
+// cockroach_releases.yaml file from the given URL, and uses it instead
+// of the data embedded in the binary, which goes stale as new releases
+// are published. Like `WithReleaseData`, it is not safe for concurrent
+// use, and should be called before any other function in this package.
+func LoadReleaseData(ctx context.Context, url string) error {
+	resp, err := httputil.Get(ctx, url)
+	if err != nil {
+		return fmt.Errorf("could not download release data: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d when downloading %s", resp.StatusCode, url)
+	}
+
+	raw, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error reading response body: %w", err)
+	}
+
+	data, err := parseReleases(raw)
+	if err != nil {
+		return err
+	}
+
+	// The downloaded data is expected to be a superset of the embedded
+	// data, as releases are never removed from it.
+	for series := range releaseData {
+		if _, ok := data[series]; !ok {
+			return fmt.Errorf("downloaded release data is missing the %q series", series)
+		}
+	}
+
+	releaseData = data
+	return nil
+}
@@ -0,0 +1,65 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package release
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestLoadReleaseData(t *testing.T) {
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if body == nil {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write(body)
+	}))
+	defer srv.Close()
+
+	ctx := context.Background()
+	_ = WithReleaseData(testReleaseData, func() error {
+		require.ErrorContains(t, LoadReleaseData(ctx, srv.URL), "unexpected status code 404")
+
+		// Release series are never removed from the release data.
+		missing := make(map[string]Series)
+		for name, series := range testReleaseData {
+			if name != "19.2" {
+				missing[name] = series
+			}
+		}
+		var err error
+		body, err = yaml.Marshal(missing)
+		require.NoError(t, err)
+		require.ErrorContains(t, LoadReleaseData(ctx, srv.URL), "missing the \"19.2\" series")
+
+		updated := map[string]Series{"24.2": {Latest: "24.2.0", Predecessor: "24.1"}}
+		for name, series := range testReleaseData {
+			updated[name] = series
+		}
+		updated["24.1"] = Series{Latest: "24.1.3", Predecessor: "23.2"}
+		body, err = yaml.Marshal(updated)
+		require.NoError(t, err)
+		require.NoError(t, LoadReleaseData(ctx, srv.URL))
+		require.Equal(t, updated, releaseData)
+
+		latest, err := LatestPatch("24.1")
+		require.NoError(t, err)
+		require.Equal(t, "24.1.3", latest)
+		return nil
+	})
+}
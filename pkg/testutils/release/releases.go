@@ -37,7 +37,7 @@ var (
 	// releaseData contains the parsed release data as contained in the
 	// cockroach_releases.yaml file embedded in the binary.
 	releaseData = func() map[string]Series {
-		releases, err := parseReleases()
+		releases, err := parseReleases(rawReleases)
 		if err != nil {
 			panic(err)
 		}
@@ -46,9 +46,9 @@ var (
 	}()
 )
 
-func parseReleases() (map[string]Series, error) {
+func parseReleases(raw []byte) (map[string]Series, error) {
 	var result map[string]Series
-	err := yaml.UnmarshalStrict(rawReleases, &result)
+	err := yaml.UnmarshalStrict(raw, &result)
 	if err != nil {
 		return nil, fmt.Errorf("invalid cockroach_releases.yaml: %w", err)
 	}
@@ -0,0 +1,84 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package release
+
+import (
+	"fmt"
+
+	"github.com/cockroachdb/cockroach/pkg/util/version"
+)
+
+// minSkipVersionSeries is the oldest release series from which "skip
+// version" upgrades are supported, i.e., upgrades that skip the
+// release series that immediately follows it.
+var minSkipVersionSeries = mustParseVersion("24.1.0")
+
+// SupportsSkipVersionUpgrade returns whether clusters running the
+// version passed can skip the next release series when upgrading.
+func SupportsSkipVersionUpgrade(v *version.Version) bool {
+	return mustParseVersion(VersionSeries(v) + ".0").AtLeast(minSkipVersionSeries)
+}
+
+// UpgradeSources returns the release series from which a cluster can
+// be upgraded directly to the release series of the version passed,
+// from most to least recent. The first element is always the
+// predecessor series; the predecessor's predecessor is also returned
+// if it supports skip-version upgrades.
+func UpgradeSources(v *version.Version) ([]string, error) {
+	pred, err := predecessorSeries(v)
+	if err != nil {
+		return nil, err
+	}
+	predVersion := mustParseVersion(pred.Latest)
+	sources := []string{VersionSeries(predVersion)}
+
+	if pred.Predecessor == "" {
+		return sources, nil
+	}
+	predPred, ok := releaseData[pred.Predecessor]
+	if !ok {
+		return nil, fmt.Errorf("no release information for %q (predecessor of %q)", pred.Predecessor, predVersion)
+	}
+	if SupportsSkipVersionUpgrade(mustParseVersion(predPred.Latest)) {
+		sources = append(sources, pred.Predecessor)
+	}
+
+	return sources, nil
+}
+
+// UpgradePaths returns every sequence of release series that can be
+// upgraded, one series at a time, to the release series of the
+// version passed in exactly `numUpgrades` upgrades. Each path is
+// ordered from least to most recent, and does not include the series
+// of the version passed.
+func UpgradePaths(v *version.Version, numUpgrades int) ([][]string, error) {
+	if numUpgrades == 0 {
+		return [][]string{{}}, nil
+	}
+
+	sources, err := UpgradeSources(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var paths [][]string
+	for _, source := range sources {
+		sourcePaths, err := UpgradePaths(mustParseVersion(source+".0"), numUpgrades-1)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range sourcePaths {
+			paths = append(paths, append(append([]string(nil), p...), source))
+		}
+	}
+
+	return paths, nil
+}
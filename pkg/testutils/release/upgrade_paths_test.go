@@ -0,0 +1,97 @@
+// Copyright 2024 The Cockroach Authors.
+//
+// Use of this software is governed by the Business Source License
+// included in the file licenses/BSL.txt.
+//
+// As of the Change Date specified in that file, in accordance with
+// the Business Source License, use of this software will be governed
+// by the Apache License, Version 2.0, included in the file
+// licenses/APL.txt.
+
+package release
+
+import (
+	"testing"
+
+	"github.com/cockroachdb/cockroach/pkg/util/version"
+	"github.com/stretchr/testify/require"
+)
+
+var skipVersionReleaseData = map[string]Series{
+	"23.1": {Latest: "23.1.20"},
+	"23.2": {Latest: "23.2.8", Predecessor: "23.1"},
+	"24.1": {Latest: "24.1.3", Predecessor: "23.2"},
+	"24.2": {Latest: "24.2.1", Predecessor: "24.1"},
+	"24.3": {Predecessor: "24.2"},
+}
+
+func TestUpgradeSources(t *testing.T) {
+	testCases := []struct {
+		name          string
+		v             string
+		expected      []string
+		expectedError string
+	}{
+		{
+			name:     "predecessor's predecessor does not support skip-version upgrades",
+			v:        "v24.1.2",
+			expected: []string{"23.2"},
+		},
+		{
+			name:     "predecessor is the oldest release series",
+			v:        "v23.2.0",
+			expected: []string{"23.1"},
+		},
+		{
+			name:     "skip-version upgrade from the predecessor's predecessor",
+			v:        "v24.3.0-alpha.00000000",
+			expected: []string{"24.2", "24.1"},
+		},
+		{
+			name:          "no known predecessor",
+			v:             "v23.1.4",
+			expectedError: `no known predecessor for "v23.1.4"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var sources []string
+			err := WithReleaseData(skipVersionReleaseData, func() error {
+				var err error
+				sources, err = UpgradeSources(version.MustParse(tc.v))
+				return err
+			})
+
+			if tc.expectedError != "" {
+				require.ErrorContains(t, err, tc.expectedError)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, tc.expected, sources)
+			}
+		})
+	}
+}
+
+func TestUpgradePaths(t *testing.T) {
+	v := version.MustParse("v24.3.0-alpha.00000000")
+	_ = WithReleaseData(skipVersionReleaseData, func() error {
+		paths, err := UpgradePaths(v, 2)
+		require.NoError(t, err)
+		require.Equal(t, [][]string{
+			{"24.1", "24.2"},
+			{"23.2", "24.1"},
+		}, paths)
+
+		paths, err = UpgradePaths(v, 3)
+		require.NoError(t, err)
+		require.Equal(t, [][]string{
+			{"23.2", "24.1", "24.2"},
+			{"23.1", "23.2", "24.1"},
+		}, paths)
+
+		_, err = UpgradePaths(v, 6)
+		require.ErrorContains(t, err, "no known predecessor")
+		return nil
+	})
+}
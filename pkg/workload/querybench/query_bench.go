@@ -15,10 +15,14 @@ import (
 	"bytes"
 	"context"
 	gosql "database/sql"
+	"embed"
+	"io"
 	"os"
 	"regexp"
 	"strings"
+	"time"
 
+	"github.com/cockroachdb/cockroach/pkg/util/log"
 	"github.com/cockroachdb/cockroach/pkg/util/timeutil"
 	"github.com/cockroachdb/cockroach/pkg/workload"
 	"github.com/cockroachdb/cockroach/pkg/workload/histogram"
@@ -26,6 +30,13 @@ import (
 	"github.com/spf13/pflag"
 )
 
+// QueryFiles holds the query files in this package (2.1-sql-20 and
+// tpch-queries), so that they can be used without a checkout of the
+// repository, e.g. by roachtests.
+//
+//go:embed 2.1-sql-20 tpch-queries
+var QueryFiles embed.FS
+
 type queryBench struct {
 	flags           workload.Flags
 	connFlags       *workload.ConnFlags
@@ -33,8 +44,27 @@ type queryBench struct {
 	separator       string
 	numRunsPerQuery int
 	verbose         bool
+	queryTimeout    time.Duration
+	continueOnError bool
+
+	stmts []NamedStmt
+}
+
+// failedSuffix is the suffix of the names of the histograms which record the
+// executions of the queries which failed. See --continue-on-error.
+const failedSuffix = ".failed"
+
+// FailedHistogramName returns the name of the histogram which records the
+// failed executions of the query with the given histogram name when
+// --continue-on-error is set.
+func FailedHistogramName(name string) string {
+	return name + failedSuffix
+}
 
-	stmts []namedStmt
+// IsFailedHistogramName returns whether the given histogram records failed
+// executions, and if so, the name of the histogram of the query.
+func IsFailedHistogramName(name string) (query string, ok bool) {
+	return strings.CutSuffix(name, failedSuffix)
 }
 
 func init() {
@@ -63,15 +93,20 @@ UPDATE bar SET foo = 'baz'
 		g := &queryBench{}
 		g.flags.FlagSet = pflag.NewFlagSet(`querybench`, pflag.ContinueOnError)
 		g.flags.Meta = map[string]workload.FlagMeta{
-			`query-file`: {RuntimeOnly: true},
-			`separator`:  {RuntimeOnly: true},
-			`num-runs`:   {RuntimeOnly: true},
+			`query-file`:        {RuntimeOnly: true},
+			`separator`:         {RuntimeOnly: true},
+			`num-runs`:          {RuntimeOnly: true},
+			`query-timeout`:     {RuntimeOnly: true},
+			`continue-on-error`: {RuntimeOnly: true},
 		}
 		g.flags.StringVar(&g.queryFile, `query-file`, ``, `File of newline separated queries to run`)
 		g.flags.StringVar(&g.separator, `separator`, ``, `String separating queries (defaults to newline)`)
 		g.flags.IntVar(&g.numRunsPerQuery, `num-runs`, 0, `Specifies the number of times each query in the query file to be run `+
 			`(note that --duration and --max-ops take precedence, so if duration or max-ops is reached, querybench will exit without honoring --num-runs)`)
 		g.flags.BoolVar(&g.verbose, `verbose`, true, `Prints out the queries being run as well as histograms`)
+		g.flags.DurationVar(&g.queryTimeout, `query-timeout`, 0, `Timeout of each execution of a query (0 for no timeout)`)
+		g.flags.BoolVar(&g.continueOnError, `continue-on-error`, false, `Keep running when a query fails or times out, `+
+			`recording its execution in a separate histogram named after the query with a `+failedSuffix+` suffix`)
 		g.connFlags = workload.NewConnFlags(&g.flags)
 		return g
 	},
@@ -104,6 +139,9 @@ func (g *queryBench) Hooks() workload.Hooks {
 			if g.numRunsPerQuery < 0 {
 				return errors.New("negative --num-runs specified")
 			}
+			if g.queryTimeout < 0 {
+				return errors.New("negative --query-timeout specified")
+			}
 			return nil
 		},
 	}
@@ -142,11 +180,13 @@ func (g *queryBench) Ops(
 	ql := workload.QueryLoad{}
 	for i := 0; i < g.connFlags.Concurrency; i++ {
 		op := queryBenchWorker{
-			hists:       reg.GetHandle(),
-			db:          db,
-			stmts:       stmts,
-			verbose:     g.verbose,
-			maxNumStmts: maxNumStmts,
+			hists:           reg.GetHandle(),
+			db:              db,
+			stmts:           stmts,
+			verbose:         g.verbose,
+			queryTimeout:    g.queryTimeout,
+			continueOnError: g.continueOnError,
+			maxNumStmts:     maxNumStmts,
 		}
 		ql.WorkerFns = append(ql.WorkerFns, op.run)
 	}
@@ -155,13 +195,18 @@ func (g *queryBench) Ops(
 
 // GetQueries returns the queries in a file as a slice of named statements. If
 // no separator is given, splits by newlines.
-func GetQueries(path, separator string) ([]namedStmt, error) {
+func GetQueries(path, separator string) ([]NamedStmt, error) {
 	file, err := os.Open(path)
 	if err != nil {
 		return nil, err
 	}
 	defer file.Close()
+	return ParseQueries(file, separator)
+}
 
+// ParseQueries returns the queries read from r as a slice of named statements.
+// See GetQueries.
+func ParseQueries(r io.Reader, separator string) ([]NamedStmt, error) {
 	// reComments removes comments at start of line. Don't attempt to parse the
 	// query, just naïvely interpret # and -- as comments.
 	reComments := regexp.MustCompile(`(?m)^\s*(#|--).*`)
@@ -172,7 +217,7 @@ func GetQueries(path, separator string) ([]namedStmt, error) {
 	reNamedQuery := regexp.MustCompile(`(?s)^\s*(\S+):\s*(.*)$`)
 
 	// Scan queries up to 1 MB in size with the given separator.
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(r)
 	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
 	if separator := []byte(separator); len(separator) > 0 {
 		scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
@@ -185,7 +230,7 @@ func GetQueries(path, separator string) ([]namedStmt, error) {
 		})
 	}
 
-	var stmts []namedStmt
+	var stmts []NamedStmt
 	for scanner.Scan() {
 		query := scanner.Text()
 		query = reComments.ReplaceAllLiteralString(query, ``)
@@ -196,7 +241,7 @@ func GetQueries(path, separator string) ([]namedStmt, error) {
 			name, query = m[1], m[2]
 		}
 		if len(query) > 0 {
-			stmts = append(stmts, namedStmt{
+			stmts = append(stmts, NamedStmt{
 				name:  name,
 				query: query,
 			})
@@ -205,7 +250,8 @@ func GetQueries(path, separator string) ([]namedStmt, error) {
 	return stmts, scanner.Err()
 }
 
-type namedStmt struct {
+// NamedStmt is a query read from a query file. See GetQueries.
+type NamedStmt struct {
 	name string
 	// We will try to Prepare the statement, and if that succeeds, the prepared
 	// statement will be stored in `preparedStmt', otherwise, we will store
@@ -214,14 +260,28 @@ type namedStmt struct {
 	query        string
 }
 
+// Name returns the name of the statement, which is the query itself for
+// unnamed statements.
+func (s NamedStmt) Name() string {
+	return s.name
+}
+
+// Query returns the query of the statement.
+func (s NamedStmt) Query() string {
+	return s.query
+}
+
 type queryBenchWorker struct {
 	hists *histogram.Histograms
 	db    *gosql.DB
-	stmts []namedStmt
+	stmts []NamedStmt
 
 	stmtIdx int
 	verbose bool
 
+	queryTimeout    time.Duration
+	continueOnError bool
+
 	// maxNumStmts indicates the maximum number of statements for the worker to
 	// execute. It is non-zero only when --num-runs flag is specified for the
 	// workload.
@@ -240,6 +300,12 @@ func (o *queryBenchWorker) run(ctx context.Context) error {
 	stmt := o.stmts[o.stmtIdx%len(o.stmts)]
 	o.stmtIdx++
 
+	queryCtx := ctx
+	if o.queryTimeout > 0 {
+		var cancel context.CancelFunc
+		queryCtx, cancel = context.WithTimeout(ctx, o.queryTimeout)
+		defer cancel()
+	}
 	exhaustRows := func(execFn func() (*gosql.Rows, error)) error {
 		rows, err := execFn()
 		if err != nil {
@@ -253,24 +319,30 @@ func (o *queryBenchWorker) run(ctx context.Context) error {
 		}
 		return nil
 	}
+	var err error
 	if stmt.preparedStmt != nil {
-		if err := exhaustRows(func() (*gosql.Rows, error) {
-			return stmt.preparedStmt.Query()
-		}); err != nil {
-			return err
-		}
+		err = exhaustRows(func() (*gosql.Rows, error) {
+			return stmt.preparedStmt.QueryContext(queryCtx)
+		})
 	} else {
-		if err := exhaustRows(func() (*gosql.Rows, error) {
-			return o.db.Query(stmt.query)
-		}); err != nil {
-			return err
-		}
+		err = exhaustRows(func() (*gosql.Rows, error) {
+			return o.db.QueryContext(queryCtx, stmt.query)
+		})
 	}
 	elapsed := timeutil.Since(start)
+	name := ""
 	if o.verbose {
-		o.hists.Get(stmt.name).Record(elapsed)
-	} else {
-		o.hists.Get("").Record(elapsed)
+		name = stmt.name
+	}
+	if err != nil {
+		// Errors due to the end of the workload are never isolated.
+		if !o.continueOnError || ctx.Err() != nil {
+			return err
+		}
+		log.Warningf(ctx, "query %s failed after %s: %v", stmt.name, elapsed, err)
+		o.hists.Get(FailedHistogramName(name)).Record(elapsed)
+		return nil
 	}
+	o.hists.Get(name).Record(elapsed)
 	return nil
 }